@@ -0,0 +1,221 @@
+// Command wikilex prints the text of the articles in article.txt, one
+// article per line, as seen by the lexer.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/completion"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// var inputFile = flag.String("infile", "enwiki-latest-pages-articles.xml", "Input file path")
+var printLex = flag.Bool("print-lex", false, "Print output from lexer")
+var printCitations = flag.Bool("print-citations", false, "Print the citations of the article instead of its text")
+var printSections = flag.Bool("print-sections", false, "Print the section tree of the article instead of its text")
+var dropComments = flag.Bool("drop-comments", false, "Leave out the comments of the article")
+var completionFlags = completion.Register(flag.CommandLine)
+
+// An itemReader reads the items of a lexer, those read ahead and pushed
+// back first.
+type itemReader struct {
+	lexer   *wikitext.Lexer
+	pending []wikitext.Item
+}
+
+func (r *itemReader) next() wikitext.Item {
+	if n := len(r.pending); n > 0 {
+		s := r.pending[n-1]
+		r.pending = r.pending[:n-1]
+		return s
+	}
+	return r.lexer.NextItem()
+}
+
+func (r *itemReader) unread(s wikitext.Item) {
+	r.pending = append(r.pending, s)
+}
+
+// braces returns the number of braces c in the run that starts with the
+// item s, reading the "{{" or "}}" and the single braces right after it.
+func (r *itemReader) braces(s wikitext.Item, c string) int {
+	n := strings.Count(s.Val, c)
+	for {
+		t := r.next()
+		if t.Type != wikitext.ItemLeftMeta && t.Type != wikitext.ItemRightMeta && t.Type != wikitext.ItemMark || strings.Trim(t.Val, c) != "" {
+			r.unread(t)
+			return n
+		}
+		n += len(t.Val)
+	}
+}
+
+// parseBracket skips over the template or template parameter whose first
+// "{{" is s, and those nested in it. Like MediaWiki, a run of closing
+// braces closes the innermost open run three braces at a time for
+// parameters like {{{1|default}}} and two at a time for templates, and
+// braces left over are text: those before the construct are ignored and
+// those after it are read again. Braces in comments and <nowiki> are
+// items of their own and not counted. It returns an error wrapping
+// wikitext.ErrMalformedTemplate if the input ends before the closing
+// braces, and one wrapping wikitext.ErrDepthExceeded (after skipping to
+// the closing braces) if the braces nest too deeply.
+func parseBracket(r *itemReader, s wikitext.Item) error {
+	open := []int{r.braces(s, "{")} // the braces of the open runs
+	exceeded := false
+	for s := r.next(); s.Type != wikitext.ItemEOF; s = r.next() {
+		switch s.Type {
+		case wikitext.ItemLeftMeta:
+			open = append(open, r.braces(s, "{"))
+			exceeded = exceeded || len(open) > wikitext.MaxNestingDepth
+		case wikitext.ItemRightMeta:
+			n := r.braces(s, "}")
+			for n >= 2 && len(open) > 0 {
+				top := len(open) - 1
+				k := min(n, open[top], 3)
+				if k < 2 {
+					break
+				}
+				n -= k
+				if open[top] -= k; open[top] < 2 {
+					open = open[:top]
+				}
+			}
+			if len(open) > 0 {
+				continue
+			}
+			for range n {
+				r.unread(wikitext.Item{Type: wikitext.ItemMark, Val: "}"})
+			}
+			if exceeded {
+				return fmt.Errorf("%w: more than %d levels", wikitext.ErrDepthExceeded, wikitext.MaxNestingDepth)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unclosed at end of input", wikitext.ErrMalformedTemplate)
+}
+
+func parseLink(r *itemReader) []wikitext.Item {
+	text := make([]wikitext.Item, 0, 10)
+	for s := r.next(); s.Type != wikitext.ItemEOF; s = r.next() {
+		text = append(text, s)
+		if s.Type == wikitext.ItemMark && s.Val == "|" {
+			text = text[0:0]
+		}
+		if s.Type == wikitext.ItemRightTag {
+			break
+		}
+	}
+	return text
+}
+
+func parseTitle(r *itemReader, level int) []wikitext.Item {
+	result := make([]wikitext.Item, 0, 10)
+	for s := r.next(); s.Type != wikitext.ItemEOF; s = r.next() {
+		result = append(result, s)
+		if s.Type == wikitext.ItemTitle {
+			break
+		}
+	}
+	return result
+}
+
+func printElement(elt wikitext.Item) {
+	switch elt.Type {
+	case wikitext.ItemWord, wikitext.ItemSpace, wikitext.ItemMark, wikitext.ItemList, wikitext.ItemURL, wikitext.ItemEntity, wikitext.ItemRaw:
+		fmt.Print(elt.Val)
+	}
+}
+
+func printSectionTree(sections []wikitext.Section, indent int) {
+	for _, s := range sections {
+		fmt.Printf("%s%d %q [%d:%d]\n", strings.Repeat("  ", indent), s.Level, s.Heading, s.Start, s.End)
+		printSectionTree(s.Children, indent+1)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if exit, err := completionFlags.Handle(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration: -completion:", err)
+		os.Exit(2)
+	} else if exit {
+		return
+	}
+
+	file, err := os.Open("article.txt")
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		str := scanner.Text()
+		options := make([]wikitext.ParseOption, 0, 1)
+		if *dropComments {
+			options = append(options, wikitext.DropComments())
+		}
+		if *printCitations {
+			doc, _ := wikitext.Parse(str, options...)
+			for _, c := range wikitext.Citations(doc) {
+				fmt.Printf("%s\t%s\t%s\t%s\t%s\n", c.Template, c.Title, c.URL, c.Author, c.Date)
+			}
+			continue
+		}
+		if *printSections {
+			doc, _ := wikitext.Parse(str, options...)
+			printSectionTree(wikitext.Sections(doc), 0)
+			continue
+		}
+		lexer := &itemReader{lexer: wikitext.Lex(str)}
+		// lexer = wikitext.Lex("<ref name=\"Best\"/> name")
+		count := 0
+		for s := lexer.next(); s.Type != wikitext.ItemEOF; s = lexer.next() {
+			if s.Type == wikitext.ItemComment && *dropComments {
+				continue
+			}
+			if s.Type == wikitext.ItemLeftMeta {
+				if err := parseBracket(lexer, s); err != nil {
+					fmt.Fprintln(os.Stderr, "Error parsing template:", err)
+				}
+			} else if s.Type == wikitext.ItemLeftTag {
+				for _, s := range parseLink(lexer) {
+					count += 1
+					if *printLex {
+						fmt.Print("(", s.Type, " ")
+						fmt.Print(s.Val, ")  ")
+					} else {
+						printElement(s)
+					}
+				}
+			} else if s.Type == wikitext.ItemTitle {
+				fmt.Println()
+				for _, s := range parseTitle(lexer, len(s.Val)) {
+					printElement(s)
+				}
+				fmt.Println()
+			} else {
+				count += 1
+				if *printLex {
+					fmt.Print("(", s.Type, " ")
+					fmt.Print(s.Val, ")  ")
+				} else {
+					printElement(s)
+				}
+			}
+		}
+		fmt.Println("count ", count)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// TestTemplateParameters checks that wikilex skips template parameters
+// like {{{1}}} and the templates around them as MediaWiki matches their
+// braces, and not the braces in comments and <nowiki>, on lines of real
+// articles.
+func TestTemplateParameters(t *testing.T) {
+	dir := t.TempDir()
+	wikilex := filepath.Join(dir, "wikilex")
+	if out, err := exec.Command("go", "build", "-o", wikilex, ".").CombinedOutput(); err != nil {
+		t.Fatalf("building wikilex: %v\n%s", err, out)
+	}
+	article := "{{Infobox settlement|name={{{name|Ulm}}}}} Ulm is a city.\n" +
+		"{{Use dmy dates|date=May 2020<!-- }} -->}} Paris is the capital.\n" +
+		"A template starts with <nowiki>{{</nowiki> and {{tl|foo}} shows it.\n"
+	if err := os.WriteFile(filepath.Join(dir, "article.txt"), []byte(article), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(wikilex)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("wikilex: %v\n%s", err, out)
+	}
+	for _, pattern := range []string{
+		`^ Ulm is a city\.count`,
+		`^ Paris is the capital\.count`,
+		`^A template starts with \{\{ and  shows it\.count`,
+	} {
+		if !regexp.MustCompile("(?m)" + pattern).Match(out) {
+			t.Errorf("wikilex printed no line matching %s:\n%s", pattern, out)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Delta debugging: reduction of a failing input to a small one
+
+package main
+
+// chunks splits units into n parts of nearly equal length.
+func chunks(units []string, n int) [][]string {
+	parts := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		parts = append(parts, units[i*len(units)/n:(i+1)*len(units)/n])
+	}
+	return parts
+}
+
+// without returns units with parts[i] left out.
+func without(parts [][]string, i int) []string {
+	rest := make([]string, 0, 10)
+	for j, p := range parts {
+		if j != i {
+			rest = append(rest, p...)
+		}
+	}
+	return rest
+}
+
+// ddmin returns a subsequence of units for which fails still holds and
+// from which no single part can be removed at the finest granularity
+// tried, following Zeller's delta debugging algorithm.
+func ddmin(units []string, fails func([]string) bool) []string {
+	n := 2
+	for len(units) >= 2 {
+		parts := chunks(units, n)
+		reduced := false
+		for _, p := range parts {
+			if len(p) > 0 && fails(p) {
+				units, n, reduced = p, 2, true
+				break
+			}
+		}
+		if !reduced {
+			for i := range parts {
+				if rest := without(parts, i); len(rest) < len(units) && fails(rest) {
+					units, n, reduced = rest, max(n-1, 2), true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n >= len(units) {
+				break
+			}
+			n = min(2*n, len(units))
+		}
+	}
+	return units
+}
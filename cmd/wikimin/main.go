@@ -0,0 +1,153 @@
+// Command wikimin reduces wikitext that fails to parse to a small
+// snippet failing the same way, to be attached to bug reports.
+//
+// The wikitext is read from the file given as argument, or from stdin,
+// or taken from the page named by -title in the dump given by -infile.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/completion"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var inputFile = flag.String("infile", "", "dump to take the page given by -title from")
+var title = flag.String("title", "", "title of the page to minimize, with -infile")
+var obfuscate = flag.Bool("obfuscate", false, "replace the letters and digits left by x and 0 where the failure allows")
+var completionFlags = completion.Register(flag.CommandLine)
+
+// failure returns the error of parsing text, turning a panic of the
+// parser into an error as well.
+func failure(text string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	_, err = wikitext.Parse(text)
+	return err
+}
+
+// failsLike returns a predicate telling whether text fails like the
+// original failure: with a syntax error of the same kind, or a panic.
+func failsLike(original error) func(text string) bool {
+	var syntaxErr *wikitext.SyntaxError
+	if errors.As(original, &syntaxErr) {
+		return func(text string) bool {
+			return errors.Is(failure(text), syntaxErr.Kind)
+		}
+	}
+	return func(text string) bool {
+		err := failure(text)
+		return err != nil && strings.HasPrefix(err.Error(), "panic:")
+	}
+}
+
+// splitLines splits text into lines, keeping their line breaks.
+func splitLines(text string) []string {
+	return strings.SplitAfter(text, "\n")
+}
+
+// splitRunes splits text into its characters.
+func splitRunes(text string) []string {
+	return strings.Split(text, "")
+}
+
+// minimize reduces the lines of text and then its characters.
+func minimize(text string, fails func(string) bool) string {
+	joined := func(units []string) bool {
+		return fails(strings.Join(units, ""))
+	}
+	text = strings.Join(ddmin(splitLines(text), joined), "")
+	return strings.Join(ddmin(splitRunes(text), joined), "")
+}
+
+// obfuscateText replaces letters by 'x' and digits by '0', all at once
+// if the failure allows, else one character at a time.
+func obfuscateText(text string, fails func(string) bool) string {
+	replace := func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) && r != 'x':
+			return 'x'
+		case unicode.IsDigit(r) && r != '0':
+			return '0'
+		}
+		return r
+	}
+	if all := strings.Map(replace, text); fails(all) {
+		return all
+	}
+	runes := []rune(text)
+	for i, r := range runes {
+		if s := replace(r); s != r {
+			runes[i] = s
+			if !fails(string(runes)) {
+				runes[i] = r
+			}
+		}
+	}
+	return string(runes)
+}
+
+// readText returns the wikitext to minimize.
+func readText() (string, error) {
+	if *inputFile != "" {
+		file, err := os.Open(*inputFile)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		for p := range dump.Pages(file) {
+			if p.Title == *title {
+				return p.Text, nil
+			}
+		}
+		return "", fmt.Errorf("no page %q in %s", *title, *inputFile)
+	}
+	if flag.NArg() > 0 {
+		b, err := os.ReadFile(flag.Arg(0))
+		return string(b), err
+	}
+	b, err := io.ReadAll(os.Stdin)
+	return string(b), err
+}
+
+func main() {
+	flag.Parse()
+	if exit, err := completionFlags.Handle(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration: -completion:", err)
+		os.Exit(2)
+	} else if exit {
+		return
+	}
+	if (*inputFile == "") != (*title == "") {
+		fmt.Fprintln(os.Stderr, "Invalid configuration: -infile and -title have to be given together")
+		os.Exit(2)
+	}
+
+	text, err := readText()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading input:", err)
+		os.Exit(1)
+	}
+	original := failure(text)
+	if original == nil {
+		fmt.Fprintln(os.Stderr, "The input parses without errors, there is nothing to minimize")
+		os.Exit(1)
+	}
+	fails := failsLike(original)
+	small := minimize(text, fails)
+	if *obfuscate {
+		small = obfuscateText(small, fails)
+	}
+	fmt.Println(small)
+	fmt.Fprintf(os.Stderr, "Reduced %d to %d bytes, failing with: %v\n", len(text), len(small), failure(small))
+}
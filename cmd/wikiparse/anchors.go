@@ -0,0 +1,107 @@
+// The anchors command: the dictionary of the anchor texts of the links in
+// a dump and the pages they link to, for entity linking
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/tally"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	anchorFile     = flag.String("anchorfile", "", "output file for the anchors command (stdout if empty)")
+	anchorBuffer   = flag.Int("anchorbuffer", 5000000, "number of distinct anchor text and target pairs the anchors command counts in memory before it writes them to a temporary file")
+	anchorMinCount = flag.Int("anchormincount", 1, "least number of links from an anchor text to a target for the anchors command to write the pair")
+)
+
+// extractAnchors writes how often each anchor text of the links between
+// articles links to each target, as lines "anchor, target, count" grouped
+// by anchor, the targets by descending count. Unpiped links count with
+// their target as anchor. The pairs are counted in a tally.Counter, in
+// temporary files beyond -anchorbuffer of them. Redirects are collected
+// into redirects.
+func extractAnchors(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	var resolve *dump.RedirectTable
+	if *resolveFile != "" {
+		var err error
+		if resolve, err = loadRedirects(*resolveFile); err != nil {
+			return err
+		}
+	}
+	writer, finish, err := openOutput(*anchorFile, "anchors", run)
+	if err != nil {
+		return err
+	}
+
+	counter := tally.NewCounter(*anchorBuffer, "")
+	defer counter.Close()
+	links := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		source := siteInfo.CanonicalizeTitle(p.Title)
+		for _, link := range wikitext.Links(doc) {
+			if link.Class != wikitext.LinkArticle || link.Target == "" || link.Anchor == "" {
+				continue
+			}
+			// Anchors have no tabs or other space than single blanks, and
+			// no NUL, which separates them from the target.
+			counter.Add(link.Anchor+"\x00"+linkTarget(source, link, resolve), 1)
+			links++
+		}
+	}
+	if ctx.Err() != nil {
+		return finish()
+	}
+
+	// The totals come in the order of the anchors, so those of an anchor
+	// are written together once the next anchor starts.
+	type target struct {
+		title string
+		count int64
+	}
+	anchor, targets := "", make([]target, 0, 16)
+	anchors, pairs := 0, 0
+	flush := func() {
+		slices.SortStableFunc(targets, func(a, b target) int { return cmp.Compare(b.count, a.count) })
+		for _, t := range targets {
+			fmt.Fprintf(writer, "%s\t%s\t%d\n", anchor, t.title, t.count)
+		}
+		if len(targets) > 0 {
+			anchors++
+			pairs += len(targets)
+		}
+		targets = targets[:0]
+	}
+	runs := counter.Runs()
+	err = counter.Totals(func(key string, n int64) error {
+		a, title, _ := strings.Cut(key, "\x00")
+		if a != anchor {
+			flush()
+			anchor = a
+		}
+		if n >= int64(*anchorMinCount) {
+			targets = append(targets, target{title, n})
+		}
+		return nil
+	})
+	flush()
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "links", links, "anchors", anchors, "anchor_targets", pairs, "temporary_files", runs)
+	return err
+}
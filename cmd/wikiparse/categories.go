@@ -0,0 +1,112 @@
+// The categories command: extraction of article categories and of the
+// category hierarchy
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var categoryFile = flag.String("categoryfile", "", "(article, category) output file for the categories command (stdout if empty)")
+var categoryTreeFile = flag.String("categorytreefile", "", "category hierarchy output file for the categories command (none if empty)")
+var ancestorsFile = flag.String("ancestorsfile", "", "category hierarchy used to also output the ancestors of categories (see -categorytreefile)")
+var maxCategoryDepth = flag.Int("maxcategorydepth", 10, "maximum number of levels of ancestors followed")
+
+func loadCategoryTree(path string) (*dump.CategoryTree, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return dump.ReadCategoryTree(file)
+}
+
+func writeCategoryTree(path string, tree *dump.CategoryTree) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = tree.WriteTo(outFile)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// extractCategoryPairs writes "article\tcategory\tdepth" lines for every
+// article of the dump, and collects the hierarchy of category pages.
+// With -ancestorsfile, the ancestors of each category are included with
+// their depth; otherwise only the direct categories at depth 1.
+func extractCategoryPairs(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	var ancestors *dump.CategoryTree
+	if *ancestorsFile != "" {
+		var err error
+		if ancestors, err = loadCategoryTree(*ancestorsFile); err != nil {
+			return err
+		}
+	}
+	writer, finish, err := openOutput(*categoryFile, "categories", run)
+	if err != nil {
+		return err
+	}
+
+	tree := dump.NewCategoryTree()
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		categories := wikitext.Categories(doc)
+		if number, name := site.Split(p.Title); number == wikitext.NamespaceCategory {
+			name := dump.CanonicalizeTitle(name)
+			for _, c := range categories {
+				tree.Add(name, dump.CanonicalizeTitle(c.Name))
+			}
+			continue
+		}
+		if ancestors == nil {
+			for _, c := range categories {
+				writer.WriteString(title + "\t" + dump.CanonicalizeTitle(c.Name) + "\t1\n")
+				total++
+			}
+			continue
+		}
+		// Ancestors shared by several categories are output once, at
+		// their shortest distance from the article.
+		depths := make(map[string]int)
+		order := make([]string, 0, 10)
+		for _, c := range categories {
+			for _, a := range ancestors.Ancestors(dump.CanonicalizeTitle(c.Name), *maxCategoryDepth) {
+				if d, ok := depths[a.Name]; !ok || a.Depth < d {
+					if !ok {
+						order = append(order, a.Name)
+					}
+					depths[a.Name] = a.Depth
+				}
+			}
+		}
+		for _, name := range order {
+			writer.WriteString(title + "\t" + name + "\t" + strconv.Itoa(depths[name]) + "\n")
+			total++
+		}
+	}
+	err = finish()
+	logger.Info("Totals", "category_assignments", total)
+	if err == nil && *categoryTreeFile != "" {
+		if err = writeCategoryTree(*categoryTreeFile, tree); err == nil {
+			err = run.AddFile(*categoryTreeFile, "categorytree")
+		}
+	}
+	return err
+}
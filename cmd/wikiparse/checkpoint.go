@@ -0,0 +1,107 @@
+// Checkpoints of the extraction of articles, to resume interrupted runs
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+)
+
+var checkpointFile = flag.String("checkpoint", "", "checkpoint file recording the pages extracted so far, removed when the run completes (none if empty)")
+var resume = flag.Bool("resume", false, "with -checkpoint, skip the pages extracted by the interrupted run that wrote the checkpoint")
+
+// checkpointInterval is the number of pages between checkpoints.
+const checkpointInterval = 10000
+
+// A checkpoint records how far a run got through the dump.
+type checkpoint struct {
+	Key    string // the settings of the run, as for the manifest
+	Pages  int64  // the number of pages of the dump processed
+	PageID int64  // the id of the last page processed
+	Offset int64  // the bytes of the dump read
+}
+
+// write replaces the checkpoint file, so that an interruption while
+// writing leaves the previous checkpoint.
+func (c *checkpoint) write(path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	schema.WriteHeader(writer, "checkpoint")
+	fmt.Fprintf(writer, "key\t%s\npages\t%d\npageid\t%d\noffset\t%d\n", c.Key, c.Pages, c.PageID, c.Offset)
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readCheckpoint reads a checkpoint written by write.
+func readCheckpoint(path string) (*checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	c := &checkpoint{}
+	scanner := schema.NewScanner(file)
+	for {
+		ok, err := scanner.Scan("checkpoint")
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint %v", err)
+		}
+		if !ok {
+			return c, nil
+		}
+		name, value, _ := strings.Cut(scanner.Text(), "\t")
+		var n int64
+		if name != "key" {
+			if n, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("checkpoint line %d: %v", scanner.Line, err)
+			}
+		}
+		switch name {
+		case "key":
+			c.Key = value
+		case "pages":
+			c.Pages = n
+		case "pageid":
+			c.PageID = n
+		case "offset":
+			c.Offset = n
+		}
+	}
+}
+
+// loadCheckpoint returns the number of pages to skip with -resume and the
+// id of the last of them. Without a checkpoint, or one written with other
+// settings, nothing is skipped.
+func loadCheckpoint(path string, key string) (int64, int64) {
+	c, err := readCheckpoint(path)
+	if os.IsNotExist(err) {
+		logger.Info("No checkpoint to resume from, starting from the beginning", "checkpoint", path)
+		return 0, 0
+	}
+	if err != nil {
+		logger.Warn("Ignoring the checkpoint", "err", err)
+		return 0, 0
+	}
+	if c.Key != key {
+		logger.Warn("Ignoring the checkpoint, it was written with other settings or code", "checkpoint", path)
+		return 0, 0
+	}
+	logger.Info("Resuming after the checkpoint", "pages", c.Pages, "offset", c.Offset)
+	return c.Pages, c.PageID
+}
@@ -0,0 +1,270 @@
+// The commands of wikiparse, with the flags each of them takes after its
+// name, like "wikiparse links -infile dump.xml -format csv"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/completion"
+	"github.com/pcmoritz/wikipedia/sink"
+)
+
+// A command is a subcommand of wikiparse. Its flags are those of
+// flag.CommandLine, which can be given before its name as well, so that
+// "wikiparse -linkfile out/links.tsv links" still works.
+type command struct {
+	name    string
+	args    string // the arguments after the flags, for the usage message
+	summary string
+	flags   []string // the names of the flags it takes
+	format  string   // the flag that -format stands for, if any
+
+	// pipeline writes the outputs of the command for the dump read from r,
+	// collecting its redirects; nil for commands that read no dump.
+	pipeline func(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error
+	failure  string // how an error of pipeline is reported, like "Error writing links"
+}
+
+// inputFlags are the flags of all commands that read a dump: which dump
+// and wiki, which of its articles, read through which page index, and
+// what is recorded and logged of the run.
+var inputFlags = flags([]string{"infile", "sitefile", "match", "titleprefix", "titlefile", "pageindex", "filter", "namespaces", "sample", "seed", "redirectfile", "progress", "statusfile", "auditfile", "reproducible", "metricsaddr", "config"}, logFlags)
+
+// apiFlags are the flags of fetching the pages from the API of a wiki
+// instead of reading a dump.
+var apiFlags = []string{"apiurl", "apiinterval", "apimaxlag", "apicache", "apicacheage"}
+
+// parseFlags are the flags of the commands that parse every article, in
+// -workers goroutines, of XML or Enterprise HTML dumps or fetched from the
+// API.
+var parseFlags = flags([]string{"workers", "informat", "cirrustext", "mmap", "multistreamindex", "pagestore", "pipelinefile", "dedup", "dedupthreshold", "dedupdrop", "maxchars", "maxwords", "maxsentences", "limit", "strip", "stripfile"}, apiFlags, inputFlags)
+
+// flags joins the names of groups of flags.
+func flags(groups ...[]string) []string {
+	names := make([]string, 0, 20)
+	for _, g := range groups {
+		names = append(names, g...)
+	}
+	return names
+}
+
+var commands = []*command{
+	{name: "extract", summary: "Write every article of the dump to out/docs, the default command",
+		flags: flags(parseFlags, []string{"indexfile", "articleformat", "abstract", "abstractsentences", "keepentities", "skeleton", "toc",
+			"expandtemplates", "templatedepth", "manifest", "incremental", "checkpoint", "resume"}),
+		format: "articleformat", pipeline: extractArticles, failure: "Error writing articles"},
+	{name: "links", summary: "Write the link graph of the articles",
+		flags:  flags(parseFlags, []string{"linkfile", "linkformat", "linkclasses", "linkscope", "resolvefile"}),
+		format: "linkformat", pipeline: extractLinkGraph, failure: "Error writing links"},
+	{name: "anchors", summary: "Write how often the anchor texts of the links link to each page, for entity linking",
+		flags:    flags(parseFlags, []string{"anchorfile", "anchorbuffer", "anchormincount", "resolvefile"}),
+		pipeline: extractAnchors, failure: "Error writing anchors"},
+	{name: "pagerank", summary: "Write the PageRank and the link degrees of the articles",
+		flags:    flags(parseFlags, []string{"pagerankfile", "damping", "pagerankiterations", "pageranktolerance"}),
+		pipeline: computePageRank, failure: "Error computing PageRank"},
+	{name: "externallinks", summary: "Write the external links of the articles",
+		flags:    flags(parseFlags, []string{"externallinkfile"}),
+		pipeline: extractExternalLinks, failure: "Error writing external links"},
+	{name: "categories", summary: "Write the categories of the articles and the category hierarchy",
+		flags:    flags(parseFlags, []string{"categoryfile", "categorytreefile", "ancestorsfile", "maxcategorydepth"}),
+		pipeline: extractCategoryPairs, failure: "Error writing categories"},
+	{name: "images", summary: "Write the images of the articles",
+		flags:    flags(parseFlags, []string{"imagefile"}),
+		pipeline: extractImages, failure: "Error writing images"},
+	{name: "languagelinks", summary: "Write the interlanguage links of the articles, their titles in other languages",
+		flags:    flags(parseFlags, []string{"languagelinkfile"}),
+		pipeline: extractLanguageLinks, failure: "Error writing language links"},
+	{name: "triples", summary: "Write RDF triples of the infoboxes, categories and links of the articles, like DBpedia",
+		flags:  flags(parseFlags, []string{"triplefile", "tripleformat", "triplemapping"}),
+		format: "tripleformat", pipeline: extractTriples, failure: "Error writing triples"},
+	{name: "sections", summary: "Write the section headings of the articles",
+		flags:    flags(parseFlags, []string{"sectionfile", "wikiurl"}),
+		pipeline: extractSections, failure: "Error writing sections"},
+	{name: "sentences", summary: "Write the plain text of the articles one sentence per line, with its section",
+		flags:    flags(parseFlags, []string{"sentencefile"}),
+		pipeline: extractSentences, failure: "Error writing sentences"},
+	{name: "quality", summary: "Write the features of the articles telling substantive articles from stubs",
+		flags:  flags(parseFlags, []string{"qualityfile", "qualityformat"}),
+		format: "qualityformat", pipeline: extractQuality, failure: "Error writing quality features"},
+	{name: "history", summary: "Write the revisions of the articles in a full-history dump",
+		flags:    flags(inputFlags, []string{"historyfile", "difffile", "diffcontext", "changefile"}),
+		pipeline: extractHistory, failure: "Error writing history"},
+	{name: "wikidata", summary: "Write the Wikidata items of the articles",
+		flags:    flags(inputFlags, []string{"informat", "wikidatafile", "pagepropsfile"}),
+		pipeline: extractWikidataItems, failure: "Error writing Wikidata items"},
+	{name: "sqlite", summary: "Write the articles to a SQLite database",
+		flags:    flags(parseFlags, []string{"sqlitefile"}),
+		pipeline: writeSQLite, failure: "Error writing database"},
+	{name: "parquet", summary: "Write the articles to a Parquet file",
+		flags:    flags(parseFlags, []string{"parquetfile", "linkclasses", "linkscope"}),
+		pipeline: writeParquet, failure: "Error writing parquet file"},
+	{name: "elasticsearch", summary: "Index the articles in Elasticsearch or OpenSearch",
+		flags:    flags(parseFlags, []string{"esurl", "esindex", "esmapping", "esuser", "esbatch", "esretries"}),
+		pipeline: indexElasticsearch, failure: "Error indexing articles"},
+	{name: "export", summary: "Write the articles to the output sinks of -sink, like jsonl:out/articles.jsonl",
+		flags:    flags(parseFlags, []string{"sink", "filesformat", "filesshards", "wikiextractorbytes", "wikiextractorjson", "wikiurl", "esmapping", "esuser", "esbatch", "esretries"}),
+		pipeline: exportArticles, failure: "Error exporting articles"},
+	{name: "protobuf", summary: "Write the parsed articles as length-prefixed protobuf messages",
+		flags:    flags(parseFlags, []string{"protofile"}),
+		pipeline: writeProtobuf, failure: "Error writing protobuf messages"},
+	{name: "kafka", summary: "Publish the articles, or their links or citations, to a Kafka topic",
+		flags: flags(parseFlags, []string{"kafkabrokers", "kafkatopic", "kafkamessages", "kafkakey", "kafkaformat", "kafkaregistry",
+			"kafkabatch", "kafkaretries", "kafkatls", "kafkacafile", "kafkauser"}),
+		format: "kafkaformat", pipeline: publishKafka, failure: "Error publishing messages"},
+	{name: "index", summary: "Build the search index of the articles",
+		flags:    flags(parseFlags, []string{"searchindex"}),
+		pipeline: buildSearchIndex, failure: "Error writing search index"},
+	{name: "pageindex", summary: "Build the index of the pages by title and page id that the get command reads",
+		flags:    inputFlags,
+		pipeline: buildPageIndex, failure: "Error writing page index"},
+	{name: "stats", summary: "Write the counts of the items, nodes and syntax errors of the articles",
+		flags:  flags(parseFlags, []string{"statsfile", "statsformat", "errorfile"}),
+		format: "statsformat", pipeline: collectStats, failure: "Error writing statistics"},
+	{name: "terms", summary: "Write the frequencies of the words and word pairs of the articles, and their term vectors",
+		flags:  flags(parseFlags, []string{"termfile", "termformat", "termtopk", "termmincount", "termbuffer", "termvectorfile", "stopwordfile", "tokenizer"}),
+		format: "termformat", pipeline: collectTerms, failure: "Error writing terms"},
+	{name: "search", args: "query", summary: "Print the articles of the search index best matching the query",
+		flags: flags([]string{"searchindex", "searchresults", "searchformat"}, logFlags), format: "searchformat"},
+	{name: "get", args: "title...", summary: "Print pages of the dump, found by the page index without reading all of it",
+		flags: flags([]string{"infile", "sitefile", "pageindex", "getformat", "byid", "wikiurl"}, logFlags), format: "getformat"},
+	{name: "serve", summary: "Answer requests for the articles, links and search results of the dump over HTTP",
+		flags: flags([]string{"infile", "sitefile", "match", "titleprefix", "titlefile", "filter", "namespaces", "workers", "searchindex", "searchresults", "addr"}, logFlags)},
+	{name: "grpc", summary: "Stream the parsed articles of the dump to gRPC clients",
+		flags: flags([]string{"infile", "sitefile", "match", "titleprefix", "titlefile", "filter", "namespaces", "workers", "informat", "cirrustext", "grpcaddr", "metricsaddr"}, logFlags)},
+	{name: "watch", args: "file", summary: "Preview the wikitext file as HTML in the browser, reloaded whenever the file changes",
+		flags: flags([]string{"sitefile", "wikiurl", "addr"}, logFlags)},
+	{name: "doctor", summary: "Check the configuration, the dump and the resources of the machine",
+		flags: flags(inputFlags, []string{"checksumfile"})},
+	{name: "download", args: "wiki date kind [command [flags]]", summary: "Download a dump from the mirrors of Wikimedia, verify it and run the command on it",
+		flags: flags([]string{"mirrors", "downloaddir"}, logFlags)},
+	{name: "estimate", args: "[command [flags]]", summary: "Project the runtime, output size and memory of a run from a sample",
+		flags: flags(inputFlags, []string{"samplefraction"})},
+}
+
+// completionCommands returns the commands, as completed by the shell.
+func completionCommands() []completion.Command {
+	completed := make([]completion.Command, len(commands))
+	for i, c := range commands {
+		completed[i] = completion.Command{Name: c.name, Summary: c.summary, Flags: c.flags}
+	}
+	return completed
+}
+
+// completionValues returns the values the shell completes for the flags
+// taking one of a list, the sinks of -sink from their registry among them.
+func completionValues() map[string][]string {
+	return map[string][]string{
+		"sink":          sink.Names(),
+		"articleformat": articleFormats,
+		"filesformat":   sink.FileFormats,
+		"informat":      inputFormats,
+		"logformat":     logFormats,
+		"loglevel":      logLevels,
+		"dedup":         dedupModes,
+		"linkformat":    linkFormats,
+		"linkscope":     linkScopes,
+		"qualityformat": qualityFormats,
+		"searchformat":  searchFormats,
+		"getformat":     articleFormatsServed,
+		"statsformat":   statsFormats,
+		"termformat":    termFormats,
+		"tripleformat":  tripleFormats,
+		"kafkamessages": kafkaMessageKinds,
+		"kafkakey":      kafkaKeys,
+		"kafkaformat":   kafkaFormats,
+	}
+}
+
+// lookupCommand returns the command with the name, or nil.
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// commandNames returns the names of the commands, like "extract, links
+// or stats".
+func commandNames(commands []*command) string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// takes reports whether the command takes the flag.
+func (c *command) takes(name string) bool {
+	for _, f := range c.flags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// flagSet returns the flags of the command, which set the values of the
+// flags of flag.CommandLine.
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("wikiparse "+c.name, flag.ContinueOnError)
+	for _, name := range c.flags {
+		f := flag.CommandLine.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.DefValue
+	}
+	if c.format != "" {
+		f := flag.CommandLine.Lookup(c.format)
+		fs.Var(f.Value, "format", "the same as -"+f.Name+": "+f.Usage)
+		fs.Lookup("format").DefValue = f.DefValue
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: wikiparse %s\n\n%s.\n\nFlags:\n", strings.TrimSpace(c.name+" [flags] "+c.args), c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseCommand returns the command named by the first of args, or
+// extract if there is none, and the arguments after the flags that follow
+// its name.
+func parseCommand(args []string) (*command, []string, error) {
+	name := "extract"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	c := lookupCommand(name)
+	if c == nil {
+		return nil, nil, &configError{name, "unknown command, expected " + commandNames(commands)}
+	}
+	fs := c.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		givenFlags[f.Name] = true
+	})
+	return c, fs.Args(), nil
+}
+
+// usage prints the commands and the flags all of them take before their
+// name.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: wikiparse [flags] [command] [flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"wikiparse <command> -h\" for the flags of a command. All flags can also be\ngiven before the command.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
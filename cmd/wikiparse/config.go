@@ -0,0 +1,142 @@
+// Job files: the command and flags of a run kept in a YAML or TOML file
+// given with -config, so that it can be repeated and shared, whose flags
+// those given on the command line override
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/toml"
+	"github.com/pcmoritz/wikipedia/internal/yaml"
+	"github.com/pcmoritz/wikipedia/processor"
+)
+
+var configFile = flag.String("config", "", "YAML or TOML `file` of the job, by the .toml extension: its command and flags by name, like infile: dump.xml, which the flags given override (none if empty)")
+
+// givenFlags are the flags given on the command line after the name of
+// the command; those before it are those of flag.Visit.
+var givenFlags = make(map[string]bool)
+
+// given reports whether the flag was given on the command line.
+func given(name string) bool {
+	found := givenFlags[name]
+	flag.Visit(func(f *flag.Flag) {
+		found = found || f.Name == name
+	})
+	return found
+}
+
+// readJob returns the settings of the job file: a mapping of "command",
+// the flags by name and "processors", a list like that of -pipelinefile.
+func readJob(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".toml") {
+		return toml.Parse(data)
+	}
+	v, err := yaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	job, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("expected a mapping of the command and flags")
+	}
+	return job, nil
+}
+
+// loadJob reads the job file of -config. Its command is run unless one is
+// given on the command line, and its flags are set unless given there
+// too. A list of values sets a flag given once per value, like -sink, to
+// each of them, and other flags to the values separated by commas.
+func loadJob() error {
+	job, err := readJob(*configFile)
+	if err != nil {
+		return &configError{"-config", err.Error()}
+	}
+	switch name := job["command"].(type) {
+	case nil:
+	case string:
+		if flag.NArg() == 0 {
+			if activeCommand, commandArgs, err = parseCommand([]string{name}); err != nil {
+				return err
+			}
+		}
+	default:
+		return &configError{"-config", "command must be the name of a command"}
+	}
+	takes := func(name string) bool {
+		return activeCommand.takes(name) || (nextCommand != nil && nextCommand.takes(name))
+	}
+	names := make([]string, 0, len(job))
+	for name := range job {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switch name {
+		case "command":
+			continue
+		case "config":
+			return &configError{"-config", "a job file cannot name another"}
+		case "processors":
+			list, ok := job[name].([]any)
+			if !ok {
+				return &configError{"-config", "processors must be a list"}
+			}
+			if !takes("pipelinefile") {
+				return &configError{"-config", "processors do not apply to the " + activeCommand.name + " command"}
+			}
+			if *pipelineFile != "" {
+				// Those of the pipeline file given instead.
+				continue
+			}
+			if processors, err = processor.ParsePipeline(list); err != nil {
+				return &configError{"-config", err.Error()}
+			}
+			continue
+		}
+		f := flag.CommandLine.Lookup(name)
+		if f == nil {
+			return &configError{"-config", fmt.Sprintf("unknown flag %q", name)}
+		}
+		if !takes(name) {
+			return &configError{"-config", fmt.Sprintf("%s does not apply to the %s command", name, activeCommand.name)}
+		}
+		if given(name) {
+			continue
+		}
+		var values []string
+		switch v := job[name].(type) {
+		case string:
+			values = []string{v}
+		case []any:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return &configError{"-config", name + " must be a list of values"}
+				}
+				values = append(values, s)
+			}
+			if _, repeated := f.Value.(*sinkList); !repeated {
+				values = []string{strings.Join(values, ",")}
+			}
+		default:
+			return &configError{"-config", name + " must be a value or a list of values"}
+		}
+		for _, value := range values {
+			if err := flag.CommandLine.Set(name, value); err != nil {
+				return &configError{"-config", fmt.Sprintf("%s: %v", name, err)}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Finding the articles whose plain text duplicates that of one before
+// them with -dedup, signed in the -workers goroutines and compared in the
+// order of the dump
+
+package main
+
+import (
+	"flag"
+	"math"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/dedup"
+)
+
+var dedupMode = flag.String("dedup", "", "find the articles duplicating one before them in the dump: exact for those of the same words, near for those of a -dedupthreshold similarity too (none if empty)")
+var dedupThreshold = flag.Float64("dedupthreshold", 0.8, "with -dedup near, the estimated Jaccard similarity from 0 to 1 of the shingles of five words of two plain texts from which they are duplicates")
+var dedupDrop = flag.Bool("dedupdrop", false, "with -dedup, drop the duplicates instead of annotating them with the title of the article they duplicate as duplicate_of and the similarity as duplicate_similarity")
+
+var dedupModes = []string{"exact", "near"}
+
+// duplicates are the articles of -dedup, nil without it.
+var duplicates *dedup.Index
+
+var articlesDuplicate = runMetrics.Counter("wikiparse_articles_duplicate_total", "Articles duplicating one before them, by -dedup.")
+
+// newDuplicates returns the index of -dedup.
+func newDuplicates() *dedup.Index {
+	if *dedupMode == "exact" {
+		return dedup.NewIndex(1)
+	}
+	return dedup.NewIndex(*dedupThreshold)
+}
+
+// unique adds the article of the signature to the duplicates, reporting
+// whether it is kept: unless it is a duplicate dropped by -dedupdrop.
+// The duplicates kept are annotated.
+func unique(p *dump.Page, s *dedup.Signature) bool {
+	original, similarity, found := duplicates.Add(p.Title, s)
+	if !found {
+		return true
+	}
+	articlesDuplicate.Inc()
+	if *dedupDrop {
+		return false
+	}
+	p.Annotate("duplicate_of", original)
+	p.Annotate("duplicate_similarity", math.Round(similarity*100)/100)
+	return true
+}
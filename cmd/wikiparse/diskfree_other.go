@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+import (
+	"errors"
+)
+
+// freeDiskSpace is not supported on this platform.
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("free disk space unknown on this platform")
+}
@@ -0,0 +1,236 @@
+// The doctor command: checks of the environment and the dump before a
+// long run
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var checksumFile = flag.String("checksumfile", "", "checksum list of the dump (like enwiki-*-sha1sums.txt) checked by the doctor command")
+
+// Rough ratios used to estimate the resources a run needs: the size of
+// the uncompressed XML relative to its bzip2 compression, and the memory
+// needed for the redirect table relative to the XML.
+const (
+	bzip2Ratio  = 5
+	memoryRatio = 50
+)
+
+// A check is one result of the doctor command.
+type check struct {
+	status string // "ok", "FAIL" or "skip"
+	name   string
+	msg    string
+}
+
+// checkChecksum compares the checksum of the dump with the entry for it
+// in the checksum list. The hash function is told by the length of the
+// checksum: MD5, SHA-1 or SHA-256.
+func checkChecksum(path string, list string) check {
+	if list == "" {
+		return check{"skip", "checksum", "no -checksumfile given"}
+	}
+	want := ""
+	file, err := os.Open(list)
+	if err != nil {
+		return check{"FAIL", "checksum", err.Error()}
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filepath.Base(path) {
+			want = strings.ToLower(fields[0])
+		}
+	}
+	var h hash.Hash
+	switch len(want) {
+	case 0:
+		return check{"FAIL", "checksum", fmt.Sprintf("no entry for %s in %s", filepath.Base(path), list)}
+	case 32:
+		h = md5.New()
+	case 40:
+		h = sha1.New()
+	case 64:
+		h = sha256.New()
+	default:
+		return check{"FAIL", "checksum", fmt.Sprintf("unknown checksum %q", want)}
+	}
+	dumpFile, err := os.Open(path)
+	if err != nil {
+		return check{"FAIL", "checksum", err.Error()}
+	}
+	defer dumpFile.Close()
+	if _, err := io.Copy(h, dumpFile); err != nil {
+		return check{"FAIL", "checksum", err.Error()}
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return check{"FAIL", "checksum", fmt.Sprintf("got %s, expected %s", got, want)}
+	}
+	return check{"ok", "checksum", want}
+}
+
+// The start of a bzip2 stream and its first block.
+var bzip2Stream = regexp.MustCompile("BZh[1-9]1AY&SY")
+
+// tailSize is how much of the end of a dump is searched for its last
+// multistream block, which holds about a hundred pages.
+const tailSize = 16 << 20
+
+// readPrefix reads up to n bytes of the decompressed stream or XML.
+func readPrefix(r io.Reader, n int) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return b, err
+}
+
+// checkBlocks checks that the start and the end of the dump can be read:
+// for a bzip2 multistream dump its first and last stream, for XML that it
+// starts and ends with the <mediawiki> element.
+func checkBlocks(path string) []check {
+	file, err := os.Open(path)
+	if err != nil {
+		return []check{{"FAIL", "first block", err.Error()}}
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return []check{{"FAIL", "first block", err.Error()}}
+	}
+	if !strings.HasSuffix(path, ".bz2") {
+		checks := make([]check, 0, 2)
+		head, _ := readPrefix(file, 4096)
+		if bytes.Contains(head, []byte("<mediawiki")) {
+			checks = append(checks, check{"ok", "first block", "starts with <mediawiki>"})
+		} else {
+			checks = append(checks, check{"FAIL", "first block", "no <mediawiki> element at the start"})
+		}
+		tail := make([]byte, min(4096, info.Size()))
+		file.ReadAt(tail, info.Size()-int64(len(tail)))
+		if bytes.HasSuffix(bytes.TrimSpace(tail), []byte("</mediawiki>")) {
+			checks = append(checks, check{"ok", "last block", "ends with </mediawiki>"})
+		} else {
+			checks = append(checks, check{"FAIL", "last block", "no </mediawiki> at the end, the dump may be truncated"})
+		}
+		return checks
+	}
+	checks := make([]check, 0, 2)
+	if b, err := readPrefix(bzip2.NewReader(file), 4096); err != nil {
+		checks = append(checks, check{"FAIL", "first block", err.Error()})
+	} else {
+		checks = append(checks, check{"ok", "first block", fmt.Sprintf("%d bytes decompressed", len(b))})
+	}
+	start := max(0, info.Size()-tailSize)
+	tail := make([]byte, info.Size()-start)
+	if _, err := file.ReadAt(tail, start); err != nil && err != io.EOF {
+		return append(checks, check{"FAIL", "last block", err.Error()})
+	}
+	streams := bzip2Stream.FindAllIndex(tail, -1)
+	if len(streams) == 0 {
+		return append(checks, check{"FAIL", "last block", "no bzip2 stream found at the end"})
+	}
+	last := streams[len(streams)-1][0]
+	if _, err := io.Copy(io.Discard, bzip2.NewReader(bytes.NewReader(tail[last:]))); err != nil {
+		return append(checks, check{"FAIL", "last block", fmt.Sprintf("stream at offset %d: %v", start+int64(last), err)})
+	}
+	return append(checks, check{"ok", "last block", fmt.Sprintf("stream at offset %d decompressed", start+int64(last))})
+}
+
+// availableMemory returns the memory available on Linux, from
+// /proc/meminfo.
+func availableMemory() (uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			return kb << 10, err
+		}
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
+
+// checkResources compares the free disk space of the output directory
+// and the available memory with rough estimates of what the run needs.
+func checkResources(path string) []check {
+	info, err := os.Stat(path)
+	if err != nil {
+		return []check{{"FAIL", "resources", err.Error()}}
+	}
+	xmlSize := uint64(info.Size())
+	if strings.HasSuffix(path, ".bz2") {
+		xmlSize *= bzip2Ratio
+	}
+	checks := make([]check, 0, 2)
+	// Articles take about as much space as the XML, abstracts, skeletons
+	// and tables of contents a fraction of it.
+	needDisk := xmlSize
+	if *abstract || *skeleton || *toc {
+		needDisk = xmlSize / 10
+	}
+	if free, err := freeDiskSpace("out"); err != nil {
+		checks = append(checks, check{"skip", "disk", err.Error()})
+	} else if free < needDisk {
+		checks = append(checks, check{"FAIL", "disk", fmt.Sprintf("%d MiB free in out, about %d MiB needed", free>>20, needDisk>>20)})
+	} else {
+		checks = append(checks, check{"ok", "disk", fmt.Sprintf("%d MiB free in out, about %d MiB needed", free>>20, needDisk>>20)})
+	}
+	needMemory := xmlSize / memoryRatio
+	if avail, err := availableMemory(); err != nil {
+		checks = append(checks, check{"skip", "memory", err.Error()})
+	} else if avail < needMemory {
+		checks = append(checks, check{"FAIL", "memory", fmt.Sprintf("%d MiB available, about %d MiB needed", avail>>20, needMemory>>20)})
+	} else {
+		checks = append(checks, check{"ok", "memory", fmt.Sprintf("%d MiB available, about %d MiB needed", avail>>20, needMemory>>20)})
+	}
+	return checks
+}
+
+// runDoctor checks the configuration, the dump and the resources of the
+// machine, prints the results and returns the exit status: 1 if any
+// check failed.
+func runDoctor(configErrs []error) int {
+	checks := make([]check, 0, 10)
+	for _, err := range configErrs {
+		checks = append(checks, check{"FAIL", "config", err.Error()})
+	}
+	if len(configErrs) == 0 {
+		checks = append(checks, check{"ok", "config", "all outputs are writable"})
+	}
+	if checkInputFile("-infile", *inputFile) == nil {
+		checks = append(checks, checkChecksum(*inputFile, *checksumFile))
+		checks = append(checks, checkBlocks(*inputFile)...)
+		checks = append(checks, checkResources(*inputFile)...)
+	}
+	status := 0
+	for _, c := range checks {
+		fmt.Printf("%-4s  %-11s  %s\n", c.status, c.name, c.msg)
+		if c.status == "FAIL" {
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,65 @@
+// The download command: fetching a dump from the mirrors of Wikimedia,
+// to be read by the command following it
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/download"
+)
+
+var (
+	mirrorList  = flag.String("mirrors", download.Mirror, "comma separated base URLs of the mirrors of the dumps the download command fetches from, the fastest first")
+	downloadDir = flag.String("downloaddir", ".", "directory the download command writes the dump to")
+)
+
+// runDownload downloads the dump named by the arguments of the command,
+// wiki, date and kind, like "enwiki latest pages-articles-multistream",
+// with the index of a multistream dump, from the fastest of -mirrors that
+// has it, resuming files partly downloaded by earlier runs, and verifies
+// them with the checksums published with the dump. Files downloaded and
+// verified before are not downloaded again. The dump becomes -infile of
+// the command following download, if any.
+func runDownload() int {
+	d := download.Dump{Wiki: commandArgs[0], Date: commandArgs[1], Kind: commandArgs[2]}
+	client := download.NewClient(strings.Split(*mirrorList, ","))
+	client.Logger = logger
+	files := d.Files()
+	mirrors, err := client.SelectMirrors(ctx, files[0])
+	if err != nil {
+		logger.Error("Error selecting a mirror", "err", err)
+		return 1
+	}
+	logger.Info("Mirrors having the dump, the fastest first", "mirrors", strings.Join(mirrors, ","))
+	sums, err := client.Checksums(ctx, mirrors, d)
+	if err != nil {
+		logger.Error("Error reading checksums", "err", err)
+		return 1
+	}
+	for _, f := range files {
+		name := path.Base(f)
+		dest := filepath.Join(*downloadDir, name)
+		if _, err := os.Stat(dest); err == nil && download.Verify(dest, sums[name]) == nil {
+			logger.Info("Already downloaded", "file", dest)
+			continue
+		}
+		if err := client.Fetch(ctx, mirrors, f, dest); err != nil {
+			logger.Error("Error downloading", "err", err)
+			return 1
+		}
+		if err := download.Verify(dest, sums[name]); err != nil {
+			// The next run downloads all of it again.
+			os.Remove(dest)
+			logger.Error("Error verifying download", "err", err)
+			return 1
+		}
+		logger.Info("Downloaded and verified", "file", dest)
+	}
+	*inputFile = filepath.Join(*downloadDir, path.Base(files[0]))
+	return 0
+}
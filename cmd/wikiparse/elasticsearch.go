@@ -0,0 +1,29 @@
+// The elasticsearch command: bulk indexing of the articles in a dump in
+// Elasticsearch or OpenSearch, for full-text search
+
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+)
+
+var esURL = flag.String("esurl", "http://localhost:9200", "URL of the Elasticsearch or OpenSearch cluster for the elasticsearch command")
+var esIndex = flag.String("esindex", "wikipedia", "index the elasticsearch command writes to")
+var esMapping = flag.String("esmapping", "", "JSON file with the settings and mappings of the index, if it is created (built-in mapping if empty)")
+var esUser = flag.String("esuser", "", "user name for the cluster, with the password from WIKI_ES_PASSWORD (no authentication if empty)")
+var esBatch = flag.Int("esbatch", 500, "number of articles per bulk request")
+var esRetries = flag.Int("esretries", 5, "how often failed bulk requests and rejected articles are retried, with exponential backoff")
+
+// indexElasticsearch indexes every article of the dump with its plain
+// text and categories in the index -esindex with the elasticsearch sink,
+// creating it if needed. Articles are identified by their canonical
+// title, so that indexing a newer dump updates them. Redirects are
+// collected into redirects.
+func indexElasticsearch(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	return writeSinks(r, redirects, run, []string{"elasticsearch:" + strings.TrimRight(*esURL, "/") + "/" + *esIndex})
+}
@@ -0,0 +1,167 @@
+// The estimate command: projection of the runtime, output size and memory
+// of a run from a sample of the dump
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/internal/audit"
+)
+
+var sampleFraction = flag.Float64("samplefraction", 0.01, "fraction of the dump the estimate command samples")
+
+// minSample is the least number of bytes sampled, so that small
+// fractions of small dumps still measure something.
+const minSample = 1 << 20
+
+// A countingReader counts the bytes read through it, also for
+// goroutines other than the one reading.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// heapPeak samples the heap in use until stop is closed and then sends the
+// largest value seen.
+func heapPeak(stop chan struct{}, peak chan uint64) {
+	var m runtime.MemStats
+	max := uint64(0)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		runtime.ReadMemStats(&m)
+		if m.HeapInuse > max {
+			max = m.HeapInuse
+		}
+		select {
+		case <-stop:
+			peak <- max
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dirSize returns the total size of the files below dir.
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// runEstimate runs the pipeline of the command given after "estimate" on
+// the first -samplefraction of the dump, with all outputs written to a
+// temporary directory, and projects the measurements to the whole dump.
+// Memory is projected as if everything held in memory, like the redirect
+// table, grew with the dump.
+func runEstimate() int {
+	command := nextCommand
+	tmp, err := os.MkdirTemp("", "wikiparse-estimate")
+	if err != nil {
+		logger.Error("Error creating temporary directory", "err", err)
+		return 1
+	}
+	defer os.RemoveAll(tmp)
+	docsDir = filepath.Join(tmp, "docs")
+	os.Mkdir(docsDir, 0755)
+	for _, f := range []*string{linkFile, externalLinkFile, categoryFile, imageFile, sectionFile, statsFile, sqliteFile, parquetFile, searchIndex, wikidataFile, historyFile} {
+		*f = filepath.Join(tmp, "output")
+	}
+	for _, f := range []*string{categoryTreeFile, redirectFile, manifestFile, checkpointFile, diffFile, changeFile, errorFile} {
+		if *f != "" {
+			*f = filepath.Join(tmp, filepath.Base(*f))
+		}
+	}
+
+	file, err := os.Open(*inputFile)
+	if err != nil {
+		logger.Error("Error opening file", "err", err)
+		return 1
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		logger.Error("Error opening file", "err", err)
+		return 1
+	}
+	size := info.Size()
+	sample := min(size, max(int64(*sampleFraction*float64(size)), minSample))
+	reader := &countingReader{r: io.LimitReader(file, sample)}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	stop, peak := make(chan struct{}), make(chan uint64)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		heapPeak(stop, peak)
+	}()
+	start := time.Now()
+	redirects := newRedirectTable()
+	// The sample of a compressed dump is decompressed, its size being
+	// compared with that of the compressed dump.
+	err = command.pipeline(dumpReader(*inputFile, reader), redirects, audit.New(command.name, flag.CommandLine, ""))
+	if err == nil && *redirectFile != "" {
+		err = writeRedirects(*redirectFile, redirects)
+	}
+	elapsed := time.Since(start)
+	close(stop)
+	heap := <-peak
+	wg.Wait()
+	if err != nil {
+		logger.Error("Error running the sample", "err", err)
+		return 1
+	}
+	if reader.n.Load() == 0 {
+		logger.Error("Error running the sample: nothing read")
+		return 1
+	}
+
+	factor := float64(size) / float64(reader.n.Load())
+	output := dirSize(tmp)
+	grown := uint64(0)
+	if heap > before.HeapInuse {
+		grown = heap - before.HeapInuse
+	}
+	fmt.Printf("Sampled %s of %s in %v (%s/s)\n", formatBytes(float64(reader.n.Load())), formatBytes(float64(size)),
+		elapsed.Round(time.Millisecond), formatBytes(float64(reader.n.Load())/elapsed.Seconds()))
+	fmt.Printf("Estimated runtime: %v\n", time.Duration(float64(elapsed)*factor).Round(time.Second))
+	fmt.Printf("Estimated output size: %s\n", formatBytes(float64(output)*factor))
+	fmt.Printf("Estimated peak memory: %s\n", formatBytes(float64(before.HeapInuse)+float64(grown)*factor))
+	return 0
+}
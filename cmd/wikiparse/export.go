@@ -0,0 +1,173 @@
+// The export command: writing the articles to output sinks, the built-in
+// ones of package sink and those other packages register, which the
+// sqlite and elasticsearch commands write through as well
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/secret"
+	"github.com/pcmoritz/wikipedia/sink"
+)
+
+// sinkSpecs are the sinks of -sink, each "name" or "name:target".
+var sinkSpecs sinkList
+
+var filesFormat = flag.String("filesformat", "txt", "`format` of the files the files sink writes: "+strings.Join(sink.FileFormats, ", "))
+var wikiExtractorBytes = flag.Int64("wikiextractorbytes", 1<<20, "bytes of the files the wikiextractor sink writes, like the -b of WikiExtractor")
+var wikiExtractorJSON = flag.Bool("wikiextractorjson", false, "make the wikiextractor sink write a JSON object per article, like the --json of WikiExtractor")
+var filesShards = flag.Int("filesshards", 0, "spread the files of the files sink over `n` levels of subdirectories of 256 each, at most 4, for dumps of millions of articles")
+
+func init() {
+	flag.Var(&sinkSpecs, "sink", "output sink of the export command, as `name[:target]`, like jsonl:out/articles.jsonl; repeated for several (sinks: "+strings.Join(sink.Names(), ", ")+")")
+}
+
+// A sinkList is the value of a flag given once per sink.
+type sinkList []string
+
+func (l *sinkList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *sinkList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitSink splits the spec of a sink into its name and target.
+func splitSink(spec string) (string, string) {
+	name, target, _ := strings.Cut(spec, ":")
+	return name, target
+}
+
+// An openedSink is a sink of a run with its name and target.
+type openedSink struct {
+	sink.OutputSink
+	name, target string
+}
+
+// configureSink sets the settings of the built-in sinks that have flags
+// of their own, like those of the files, wikiextractor and elasticsearch
+// sinks.
+func configureSink(s sink.OutputSink) error {
+	if files, ok := s.(*sink.Files); ok {
+		files.Format, files.Shards = *filesFormat, *filesShards
+		files.BaseURL = strings.TrimRight(*wikiURL, "/") + "/wiki/"
+		return nil
+	}
+	if wx, ok := s.(*sink.WikiExtractor); ok {
+		wx.MaxBytes, wx.JSON = *wikiExtractorBytes, *wikiExtractorJSON
+		wx.URLBase = strings.TrimRight(*wikiURL, "/") + "/wiki?curid="
+		return nil
+	}
+	es, ok := s.(*sink.Elasticsearch)
+	if !ok {
+		return nil
+	}
+	if *esMapping != "" {
+		mapping, err := os.ReadFile(*esMapping)
+		if err != nil {
+			return err
+		}
+		es.Mapping = mapping
+	}
+	es.Batch, es.Retries = *esBatch, *esRetries
+	if *esUser != "" {
+		password, err := secret.Read("ES_PASSWORD")
+		if err != nil {
+			return err
+		}
+		es.Username, es.Password = *esUser, password.Value()
+	}
+	apiKey, err := secret.Read("ES_API_KEY")
+	if err != nil {
+		return err
+	}
+	es.APIKey = apiKey.Value()
+	return nil
+}
+
+// openSinks opens the sinks of the specs. Those opened are closed again
+// if one fails to open.
+func openSinks(specs []string) ([]openedSink, error) {
+	sinks := make([]openedSink, 0, len(specs))
+	for _, spec := range specs {
+		name, target := splitSink(spec)
+		s, err := sink.New(name)
+		if err == nil {
+			if err = configureSink(s); err == nil {
+				err = s.Open(ctx, sink.Options{Target: target, Site: siteInfo})
+			}
+		}
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		sinks = append(sinks, openedSink{s, name, target})
+	}
+	return sinks, nil
+}
+
+// writeSinks writes every page of the dump, with the document of the
+// articles, to the sinks of the specs, in the order of the dump. It stops
+// at the first page a sink fails to write. Redirects are collected into
+// redirects, and the files written to are recorded in the audit record.
+func writeSinks(r io.Reader, redirects *dump.RedirectTable, run *audit.Record, specs []string) error {
+	sinks, err := openSinks(specs)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		for _, s := range sinks {
+			if e := s.WritePage(p, doc); e != nil {
+				err = fmt.Errorf("%s: %w", s.name, e)
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		if doc != nil {
+			total++
+		}
+	}
+	for _, s := range sinks {
+		if e := s.Close(); e != nil && err == nil {
+			err = fmt.Errorf("%s: %w", s.name, e)
+		}
+		if info, e := os.Stat(s.target); e == nil && info.Mode().IsRegular() {
+			run.AddFile(s.target, s.name)
+		}
+		if es, ok := s.OutputSink.(*sink.Elasticsearch); ok {
+			logger.Info("Totals", "articles_indexed", es.Indexed(), "duration", time.Since(start).Round(time.Second))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("Totals", "articles", total)
+	return nil
+}
+
+// exportArticles writes the articles of the dump to the sinks of -sink.
+func exportArticles(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	return writeSinks(r, redirects, run, sinkSpecs)
+}
@@ -0,0 +1,45 @@
+// The externallinks command: extraction of the outbound URLs of the
+// articles in a dump
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var externalLinkFile = flag.String("externallinkfile", "", "output file for the externallinks command (stdout if empty)")
+
+// extractExternalLinks writes the external links of every article in the
+// dump as lines "article\turl\tlabel". Redirects are collected into
+// redirects.
+func extractExternalLinks(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*externalLinkFile, "externallinks", run)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		for _, link := range wikitext.ExternalLinks(doc) {
+			label := strings.Join(strings.Fields(link.Label), " ")
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", title, link.URL, label)
+			total++
+		}
+	}
+	err = finish()
+	logger.Info("Totals", "external_links", total)
+	return err
+}
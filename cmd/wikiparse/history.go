@@ -0,0 +1,109 @@
+// The history command: the revisions of the articles in a full-history
+// dump, with the changes between them
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/diff"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var historyFile = flag.String("historyfile", "", "revision table output file for the history command (stdout if empty)")
+var diffFile = flag.String("difffile", "", "with the history command, also write the changes of every revision to the one before as unified diffs (none if empty)")
+var diffContext = flag.Int("diffcontext", 3, "lines of context around the changes of -difffile")
+var changeFile = flag.String("changefile", "", "with the history command, also write the words, links, templates, categories, external links and images each revision added, removed or modified (none if empty)")
+
+// oneLine replaces the tabs and newlines of a field of a table.
+var oneLine = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// extractHistory writes "article\trevision\tparent\ttimestamp\tcontributor\t
+// minor\tbytes\tsha1\tcomment" lines for every revision of the articles
+// of a history dump, oldest first, with the user name or IP address of
+// the contributor. With -difffile and -changefile, each revision is
+// compared to the revision before it in the dump, by lines and with
+// "article\trevision\tkind\top\tvalue" lines of wikitext.Diff. Redirects
+// are collected into redirects, by the latest revision.
+func extractHistory(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*historyFile, "history", run)
+	if err != nil {
+		return err
+	}
+	var diffs, changes *bufio.Writer
+	var finishDiffs, finishChanges func() error
+	if *diffFile != "" {
+		if diffs, finishDiffs, err = openOutput(*diffFile, "diffs", run); err != nil {
+			return err
+		}
+	}
+	if *changeFile != "" {
+		if changes, finishChanges, err = openOutput(*changeFile, "changes", run); err != nil {
+			return err
+		}
+	}
+	empty, _ := wikitext.Parse("")
+
+	var last *dump.Page
+	var previous *dump.Revision // the revision before in the dump
+	var previousDoc *wikitext.Document
+	pages, total := 0, 0
+	for p, rev := range dump.RevisionsContext(ctx, r) {
+		if p != last {
+			if p.Redir.Title != "" {
+				redirects.Add(p.Title, p.Redir.Title)
+			}
+			last, previous, previousDoc = p, nil, empty
+			pages++
+		}
+		if !isArticle(p) {
+			continue
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		timestamp := ""
+		if !rev.Timestamp.IsZero() {
+			timestamp = rev.Timestamp.Format(time.RFC3339)
+		}
+		writer.WriteString(strings.Join([]string{
+			title, strconv.FormatInt(rev.ID, 10), strconv.FormatInt(rev.ParentID, 10), timestamp,
+			oneLine.Replace(rev.Contributor.Name()), strconv.FormatBool(rev.Minor),
+			strconv.Itoa(len(rev.Text)), rev.SHA1, oneLine.Replace(rev.Comment),
+		}, "\t") + "\n")
+		if diffs != nil {
+			from, text := "/dev/null", ""
+			if previous != nil {
+				from, text = title+" "+strconv.FormatInt(previous.ID, 10), previous.Text
+			}
+			if err = diff.Unified(diffs, from, title+" "+strconv.FormatInt(rev.ID, 10), text, rev.Text, *diffContext); err != nil {
+				break
+			}
+		}
+		if changes != nil {
+			doc, _ := wikitext.Parse(rev.Text, wikitext.WithSite(site))
+			id := strconv.FormatInt(rev.ID, 10)
+			for _, c := range wikitext.Diff(previousDoc, doc).Changes {
+				changes.WriteString(title + "\t" + id + "\t" + c.Kind.String() + "\t" + c.Op.String() + "\t" + oneLine.Replace(c.Value) + "\n")
+			}
+			previousDoc = doc
+		}
+		previous = rev
+		total++
+	}
+	for _, f := range []func() error{finish, finishDiffs, finishChanges} {
+		if f == nil {
+			continue
+		}
+		if finishErr := f(); err == nil {
+			err = finishErr
+		}
+	}
+	logger.Info("Totals", "revisions", total, "pages", pages)
+	return err
+}
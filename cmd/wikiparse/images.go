@@ -0,0 +1,51 @@
+// The images command: extraction of the images of the articles in a dump
+// with their captions
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var imageFile = flag.String("imagefile", "", "output file for the images command (stdout if empty)")
+
+// plainCaption returns the caption or alternative text of an image as
+// plain text on one line.
+func plainCaption(caption string) string {
+	doc, _ := wikitext.Parse(caption, wikitext.WithSite(site))
+	return strings.Join(strings.Fields(wikitext.PlainText(doc)), " ")
+}
+
+// extractImages writes the images of every article in the dump as lines
+// "article\tfile\tcaption\talt", with caption and alternative text as
+// plain text. Redirects are collected into redirects.
+func extractImages(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*imageFile, "images", run)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		for _, m := range wikitext.Images(doc) {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", title, strings.Replace(m.File, "\t", " ", -1), plainCaption(m.Caption), plainCaption(m.Alt))
+			total++
+		}
+	}
+	err = finish()
+	logger.Info("Totals", "images", total)
+	return err
+}
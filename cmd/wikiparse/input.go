@@ -0,0 +1,260 @@
+// The formats of the dumps read, XML dumps, the HTML dumps of Wikimedia
+// Enterprise or CirrusSearch dumps, XML dumps mapped into memory or bzip2
+// compressed, and the pages fetched from the API of a wiki instead of a
+// dump
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"flag"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/mmap"
+	"github.com/pcmoritz/wikipedia/internal/multistream"
+	"github.com/pcmoritz/wikipedia/internal/mwapi"
+)
+
+var inputFormat = flag.String("informat", "xml", "input `format`: xml for the XML dumps, enterprise for the NDJSON of Wikimedia Enterprise HTML dumps, a .tar.gz archive or a single file, or cirrus for the NDJSON of CirrusSearch dumps, gzipped or not")
+var cirrusText = flag.Bool("cirrustext", false, "with -informat cirrus, read the plain text the wiki rendered as the text of the articles instead of their wikitext")
+
+var inputFormats = []string{"xml", "enterprise", "cirrus"}
+
+var mapInput = flag.Bool("mmap", false, "map the uncompressed XML dump of -infile into memory and take the texts of the pages from it instead of copying them")
+
+var multistreamIndex = flag.String("multistreamindex", "", "index of the bzip2 multistream dump of -infile, by which its streams are decompressed in -workers goroutines at once (the index published beside the dump if empty)")
+
+// mappedDump is the dump of -infile mapped into memory with -mmap.
+var mappedDump []byte
+
+// closeDump, if not nil, releases the dump once the outputs are written:
+// it unmaps it, as the pages refer to the mapping, or stops the
+// goroutines decompressing it.
+var closeDump func() error
+
+// compressedDump reports whether the dump at path is bzip2 compressed,
+// like the .xml.bz2 dumps published by Wikimedia, by its name.
+func compressedDump(path string) bool {
+	return strings.HasSuffix(path, ".bz2")
+}
+
+// dumpReader returns the reader of the XML of the dump at path read from
+// r: r itself, or one decompressing it for a compressed dump.
+func dumpReader(path string, r io.Reader) io.Reader {
+	if compressedDump(path) {
+		return bzip2.NewReader(r)
+	}
+	return r
+}
+
+// decompressedDump returns the reader of the XML of the compressed dump
+// file of -infile. The streams of a multistream dump are decompressed in
+// -workers goroutines at once, as told by its index, -multistreamindex or
+// the one beside it; without an index, the dump is decompressed in one.
+func decompressedDump(file *os.File) (io.Reader, error) {
+	path := *multistreamIndex
+	if path == "" {
+		path = multistream.IndexPath(*inputFile)
+		if _, err := os.Stat(path); path == "" || err != nil {
+			if *workers > 1 {
+				logger.Warn("Decompressing the dump in one goroutine, as it has no multistream index", "workers", *workers)
+			}
+			return bzip2.NewReader(file), nil
+		}
+	}
+	offsets, err := multistream.ReadIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r := multistream.NewReader(file, info.Size(), offsets, *workers)
+	closeDump = r.Close
+	return r, nil
+}
+
+var (
+	apiURL      = flag.String("apiurl", "", "fetch the current revisions of the pages of -titlefile from the Action API at this `URL`, like https://en.wikipedia.org/w/api.php, instead of reading -infile (none if empty)")
+	apiInterval = flag.Duration("apiinterval", time.Second, "with -apiurl, the least time between two requests")
+	apiMaxLag   = flag.Int("apimaxlag", 5, "with -apiurl, the `seconds` of replication lag from which the wiki turns requests away, to be retried later (not sent if 0)")
+	apiCache    = flag.String("apicache", "", "with -apiurl, the directory the responses of the API are cached in (none if empty)")
+	apiCacheAge = flag.Duration("apicacheage", 24*time.Hour, "with -apicache, how long a cached response is used (always if 0)")
+)
+
+// api is the client of -apiurl, made by wikiAPI.
+var api *mwapi.Client
+
+// wikiAPI returns the client of the API of -apiurl, the same for all
+// requests of the run so that they are spaced out by -apiinterval.
+func wikiAPI() *mwapi.Client {
+	if api == nil {
+		api = mwapi.NewClient(*apiURL)
+		api.Interval, api.MaxLag = *apiInterval, *apiMaxLag
+		api.CacheDir, api.CacheAge = *apiCache, *apiCacheAge
+	}
+	return api
+}
+
+// readTitleLines returns the titles of the file, one per line, as
+// written but with blanks for underscores. Empty lines and those starting
+// with "#" are skipped, as by readTitleFile.
+func readTitleLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	titles := make([]string, 0, 100)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if title := strings.TrimSpace(scanner.Text()); title != "" && !strings.HasPrefix(title, "#") {
+			titles = append(titles, strings.ReplaceAll(title, "_", " "))
+		}
+	}
+	return titles, scanner.Err()
+}
+
+// A failingReader records the first error of reading r but the end,
+// which the readers of dumps take for the end of the dump.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err != nil && err != io.EOF && f.err == nil {
+		f.err = err
+	}
+	return n, err
+}
+
+// openInput returns the dump of the run: the file of -infile, read from
+// its mapping into memory with -mmap or decompressed if it is compressed,
+// or with -apiurl the exports of the pages of -titlefile fetched from the
+// API, as a failingReader without a file.
+func openInput() (*os.File, io.Reader, error) {
+	if *apiURL != "" {
+		titles, err := readTitleLines(*titleFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &failingReader{r: wikiAPI().Reader(ctx, titles)}, nil
+	}
+	file, err := os.Open(*inputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case compressedDump(*inputFile):
+		r, err := decompressedDump(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return file, r, nil
+	case *mapInput:
+		if mappedDump, closeDump, err = mmap.Map(file); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return file, bytes.NewReader(mappedDump), nil
+	}
+	return file, file, nil
+}
+
+// inputEntry returns the audit entry of the dump read, with its digest.
+func inputEntry(digest *audit.Digest) audit.Entry {
+	if *apiURL != "" {
+		return audit.Entry{Path: *apiURL, Kind: "api", SHA256: digest.Sum(), Bytes: digest.Bytes}
+	}
+	return audit.Entry{Path: *inputFile, Kind: "dump", SHA256: digest.Sum(), Bytes: digest.Bytes}
+}
+
+// dumpErr is the error reading the XML dump that ended the pages of
+// dumpPages, with which the run fails.
+var dumpErr error
+
+// dumpPages returns the pages of the dump read from r, as allPages does,
+// up to the article of -limit. An error reading the dump is kept in
+// dumpErr.
+func dumpPages(r io.Reader) iter.Seq[*dump.Page] {
+	pages, err := allPages(r)
+	return func(yield func(*dump.Page) bool) {
+		defer func() { dumpErr = err() }()
+		for p := range limitArticles(pages) {
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+// allPages returns the pages of the dump read from r in the format of
+// -informat, until the run is interrupted, and a function returning the
+// error reading the XML dump that ended them, if any. With -mmap, they
+// are those of the mapped dump, and r, which reads it, is only read
+// along. Redirects given by the aliases of #REDIRECT of -sitefile, which
+// the dump package does not know, are recorded as those of the dump. With
+// -cirrustext, the text of the articles is the plain text of the
+// CirrusSearch dump. The pages are counted for the metrics, and with
+// -loglevel debug, every page is logged as it is read.
+func allPages(r io.Reader) (iter.Seq[*dump.Page], func() error) {
+	var pages iter.Seq[*dump.Page]
+	err := func() error { return nil }
+	switch {
+	case mappedDump != nil:
+		d := dump.NewMappedReader(mappedDump)
+		pages, err = mappedPages(d, r), d.Err
+	case *inputFormat == "enterprise":
+		pages = dump.EnterprisePages(ctx, r)
+	case *inputFormat == "cirrus":
+		pages = dump.CirrusPages(ctx, r)
+	default:
+		d := dump.NewReader(r)
+		pages, err = d.Pages(ctx), d.Err
+	}
+	debug := logger.Enabled(ctx, slog.LevelDebug)
+	return func(yield func(*dump.Page) bool) {
+		for p := range pages {
+			pagesRead.Inc()
+			markRedirect(p)
+			if *cirrusText && p.Redir.Title == "" {
+				p.Text = p.RenderedText
+			}
+			if debug {
+				pageLogger(p).Debug("Page read", "namespace", p.Namespace, "revision_id", p.RevisionID, "bytes", len(p.Text))
+			}
+			if !yield(p) {
+				return
+			}
+		}
+	}, err
+}
+
+// mappedPages returns the pages of the mapped dump of d, reading r up to
+// the end of each page before it is yielded, so that the dump is hashed
+// and its progress reported as when the pages are read from r.
+func mappedPages(d *dump.Reader, r io.Reader) iter.Seq[*dump.Page] {
+	return func(yield func(*dump.Page) bool) {
+		read := int64(0)
+		for end, p := range d.MappedPages(ctx) {
+			n, _ := io.CopyN(io.Discard, r, end-read)
+			read += n
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// The kafka command: the parsed articles of a dump, or their links or
+// citations, as messages of a Kafka topic
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/kafka"
+	"github.com/pcmoritz/wikipedia/internal/secret"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	kafkaBrokers  = flag.String("kafkabrokers", "localhost:9092", "comma-separated `addresses` of the Kafka brokers asked for the partitions of -kafkatopic")
+	kafkaTopic    = flag.String("kafkatopic", "wikipedia", "Kafka topic the kafka command publishes to")
+	kafkaMessages = flag.String("kafkamessages", "pages", "what a message of the kafka command is: pages, links or citations")
+	kafkaKey      = flag.String("kafkakey", "id", "key of the messages of the kafka command: id (the page id), title or none")
+	kafkaFormat   = flag.String("kafkaformat", "json", "serialization of the messages of the kafka command: json or avro")
+	kafkaRegistry = flag.String("kafkaregistry", "", "`URL` of the schema registry the Avro schema of the messages is registered in, for -kafkaformat avro")
+	kafkaBatch    = flag.Int("kafkabatch", 500, "messages the kafka command sends per request")
+	kafkaRetries  = flag.Int("kafkaretries", 5, "how often the kafka command retries a batch whose partition has no leader")
+	kafkaTLS      = flag.Bool("kafkatls", false, "connect to the Kafka brokers over TLS")
+	kafkaCAFile   = flag.String("kafkacafile", "", "PEM `file` of the certificates of the authorities the Kafka brokers are verified with, for -kafkatls (the system's if empty)")
+	kafkaUser     = flag.String("kafkauser", "", "user name the kafka command authenticates as with SASL/PLAIN, with the password from WIKI_KAFKA_PASSWORD (no authentication if empty)")
+)
+
+var (
+	kafkaMessageKinds = []string{"pages", "links", "citations"}
+	kafkaKeys         = []string{"id", "title", "none"}
+	kafkaFormats      = []string{"json", "avro"}
+)
+
+// A kafkaRecord is a message of the kafka command. Its JSON fields and
+// its Avro encoding are those of the schema of its kind, in the same
+// order.
+type kafkaRecord interface {
+	appendAvro(a *kafka.Avro)
+}
+
+type pageRecord struct {
+	PageID     int64    `json:"page_id"`
+	Title      string   `json:"title"`
+	Namespace  int      `json:"namespace"`
+	Revision   int64    `json:"revision"`
+	Text       string   `json:"text"`
+	Categories []string `json:"categories"`
+}
+
+func (r *pageRecord) appendAvro(a *kafka.Avro) {
+	a.Long(r.PageID)
+	a.String(r.Title)
+	a.Long(int64(r.Namespace))
+	a.Long(r.Revision)
+	a.String(r.Text)
+	a.Strings(r.Categories)
+}
+
+type linkRecord struct {
+	PageID  int64  `json:"page_id"`
+	Title   string `json:"title"`
+	Target  string `json:"target"`
+	Section string `json:"section"`
+	Class   string `json:"class"`
+	Anchor  string `json:"anchor"`
+}
+
+func (r *linkRecord) appendAvro(a *kafka.Avro) {
+	a.Long(r.PageID)
+	a.String(r.Title)
+	a.String(r.Target)
+	a.String(r.Section)
+	a.String(r.Class)
+	a.String(r.Anchor)
+}
+
+type citationRecord struct {
+	PageID     int64  `json:"page_id"`
+	Title      string `json:"title"`
+	Template   string `json:"template"`
+	CitedTitle string `json:"cited_title"`
+	URL        string `json:"url"`
+	Author     string `json:"author"`
+	Date       string `json:"date"`
+}
+
+func (r *citationRecord) appendAvro(a *kafka.Avro) {
+	a.Long(r.PageID)
+	a.String(r.Title)
+	a.String(r.Template)
+	a.String(r.CitedTitle)
+	a.String(r.URL)
+	a.String(r.Author)
+	a.String(r.Date)
+}
+
+// avroSchemas are the Avro schemas of the messages, by -kafkamessages.
+var avroSchemas = map[string]string{
+	"pages": `{"type": "record", "name": "Page", "namespace": "wikipedia", "fields": [
+  {"name": "page_id", "type": "long"}, {"name": "title", "type": "string"},
+  {"name": "namespace", "type": "int"}, {"name": "revision", "type": "long"},
+  {"name": "text", "type": "string"}, {"name": "categories", "type": {"type": "array", "items": "string"}}]}`,
+	"links": `{"type": "record", "name": "Link", "namespace": "wikipedia", "fields": [
+  {"name": "page_id", "type": "long"}, {"name": "title", "type": "string"},
+  {"name": "target", "type": "string"}, {"name": "section", "type": "string"},
+  {"name": "class", "type": "string"}, {"name": "anchor", "type": "string"}]}`,
+	"citations": `{"type": "record", "name": "Citation", "namespace": "wikipedia", "fields": [
+  {"name": "page_id", "type": "long"}, {"name": "title", "type": "string"},
+  {"name": "template", "type": "string"}, {"name": "cited_title", "type": "string"},
+  {"name": "url", "type": "string"}, {"name": "author", "type": "string"}, {"name": "date", "type": "string"}]}`,
+}
+
+// kafkaRecords returns the messages of -kafkamessages for the article.
+func kafkaRecords(p *dump.Page, doc *wikitext.Document) []kafkaRecord {
+	switch *kafkaMessages {
+	case "links":
+		links := wikitext.Links(doc)
+		records := make([]kafkaRecord, len(links))
+		for i, l := range links {
+			records[i] = &linkRecord{p.ID, p.Title, l.Target, l.Section, l.Class.String(), l.Anchor}
+		}
+		return records
+	case "citations":
+		citations := wikitext.Citations(doc)
+		records := make([]kafkaRecord, len(citations))
+		for i, c := range citations {
+			records[i] = &citationRecord{p.ID, p.Title, c.Template, c.Title, c.URL, c.Author, c.Date}
+		}
+		return records
+	}
+	categories := make([]string, 0, 4)
+	for _, c := range wikitext.Categories(doc) {
+		categories = append(categories, c.Name)
+	}
+	return []kafkaRecord{&pageRecord{p.ID, p.Title, p.Namespace, p.RevisionID, wikitext.PlainText(doc), categories}}
+}
+
+// configureKafka sets up TLS and SASL/PLAIN authentication of the
+// producer from the flags and WIKI_KAFKA_PASSWORD.
+func configureKafka(producer *kafka.Producer) error {
+	if *kafkaTLS {
+		producer.TLS = &tls.Config{}
+		if *kafkaCAFile != "" {
+			pem, err := os.ReadFile(*kafkaCAFile)
+			if err != nil {
+				return err
+			}
+			producer.TLS.RootCAs = x509.NewCertPool()
+			if !producer.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("%s: no PEM certificates", *kafkaCAFile)
+			}
+		}
+	}
+	if *kafkaUser != "" {
+		password, err := secret.Read("KAFKA_PASSWORD")
+		if err != nil {
+			return err
+		}
+		producer.Username, producer.Password = *kafkaUser, password.Value()
+	}
+	return nil
+}
+
+// publishKafka publishes a message for every article of the dump, or for
+// every link or citation of the articles with -kafkamessages, to the
+// topic -kafkatopic, keyed by -kafkakey and serialized as -kafkaformat.
+// The Avro schema is registered in -kafkaregistry under the subject
+// "<topic>-value" first. Redirects are collected into redirects.
+func publishKafka(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	var schemaID int32
+	if *kafkaFormat == "avro" {
+		var err error
+		if schemaID, err = kafka.RegisterSchema(*kafkaRegistry, *kafkaTopic+"-value", avroSchemas[*kafkaMessages]); err != nil {
+			return err
+		}
+	}
+	producer := kafka.NewProducer(strings.Split(*kafkaBrokers, ","), *kafkaTopic)
+	producer.Batch, producer.Retries = *kafkaBatch, *kafkaRetries
+	if err := configureKafka(producer); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var avro kafka.Avro
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		var key []byte
+		switch *kafkaKey {
+		case "id":
+			key = strconv.AppendInt(nil, p.ID, 10)
+		case "title":
+			key = []byte(p.Title)
+		}
+		for _, record := range kafkaRecords(p, doc) {
+			var value []byte
+			if *kafkaFormat == "avro" {
+				avro.Reset()
+				record.appendAvro(&avro)
+				value = avro.Framed(schemaID)
+			} else {
+				var err error
+				if value, err = json.Marshal(record); err != nil {
+					producer.Close()
+					return err
+				}
+			}
+			if err := producer.Send(key, value); err != nil {
+				producer.Close()
+				return err
+			}
+		}
+	}
+	err := producer.Close()
+	logger.Info("Totals", "messages_published", producer.Produced, "duration", time.Since(start).Round(time.Second))
+	return err
+}
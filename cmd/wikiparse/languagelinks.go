@@ -0,0 +1,49 @@
+// The languagelinks command: extraction of the interlanguage links of the
+// articles in a dump, which map their titles to those of other languages
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var languageLinkFile = flag.String("languagelinkfile", "", "output file for the languagelinks command (stdout if empty)")
+
+// extractLanguageLinks writes the interlanguage links of every article in
+// the dump as lines "article\tlanguage\ttitle", with the title in the
+// other language as written, so that the articles on the same subject in
+// several languages can be aligned. Redirects are collected into
+// redirects.
+func extractLanguageLinks(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*languageLinkFile, "languagelinks", run)
+	if err != nil {
+		return err
+	}
+	total, articles := 0, 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		links := wikitext.LanguageLinks(doc)
+		for _, l := range links {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", title, l.Language, oneLine.Replace(l.Title))
+		}
+		if len(links) > 0 {
+			articles++
+		}
+		total += len(links)
+	}
+	err = finish()
+	logger.Info("Totals", "language_links", total, "articles_with_language_links", articles)
+	return err
+}
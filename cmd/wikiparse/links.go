@@ -0,0 +1,147 @@
+// The links command: extraction of the internal link graph of a dump
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var linkFile = flag.String("linkfile", "", "link graph output file for the links command (stdout if empty)")
+var linkFormat = flag.String("linkformat", "tsv", "link graph output `format`: tsv, csv or adjacency")
+var resolveFile = flag.String("resolvefile", "", "redirect table used to resolve link targets (see -redirectfile)")
+var linkClasses = flag.String("linkclasses", "article,category,file,interwiki,namespace", "comma separated `classes` of links written by the links command: article, category, file, media, interwiki, special or namespace")
+var linkScope = flag.String("linkscope", "all", "`links` written by the links and parquet commands: all, body for those of the content, or nav for those of the navboxes and succession boxes")
+
+var linkFormats = []string{"tsv", "csv", "adjacency"}
+var linkScopes = []string{"all", "body", "nav"}
+
+// inLinkScope reports whether the link is of those of -linkscope.
+func inLinkScope(link wikitext.Link) bool {
+	switch *linkScope {
+	case "body":
+		return !link.Navigation
+	case "nav":
+		return link.Navigation
+	}
+	return true
+}
+
+// parseLinkClasses parses the value of -linkclasses.
+func parseLinkClasses(value string) (map[wikitext.LinkClass]bool, error) {
+	classes := make(map[wikitext.LinkClass]bool)
+	for _, name := range strings.Split(value, ",") {
+		c, ok := wikitext.ParseLinkClass(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown link class %q", strings.TrimSpace(name))
+		}
+		classes[c] = true
+	}
+	return classes, nil
+}
+
+// linkTarget returns the canonical title of the page the link points
+// to, resolving redirects for links to the local wiki. Titles of the
+// local wiki are canonicalized by its case rules.
+func linkTarget(source string, link wikitext.Link, redirects *dump.RedirectTable) string {
+	if link.Target == "" {
+		return source
+	}
+	if link.Interwiki != "" {
+		return dump.CanonicalizeTitle(link.Target)
+	}
+	if redirects == nil {
+		return siteInfo.CanonicalizeTitle(link.Target)
+	}
+	return redirects.ResolveRedirect(link.Target)
+}
+
+func loadRedirects(path string) (*dump.RedirectTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	t, err := dump.ReadRedirectTable(file)
+	if err != nil {
+		return nil, err
+	}
+	t.UseSite(siteInfo)
+	return t, nil
+}
+
+// extractLinkGraph writes the links of every article in the dump of the
+// classes given by -linkclasses and the scope given by -linkscope in the
+// format given by -linkformat. Redirects are collected into redirects.
+func extractLinkGraph(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	classes, err := parseLinkClasses(*linkClasses)
+	if err != nil {
+		return err
+	}
+	var resolve *dump.RedirectTable
+	if *resolveFile != "" {
+		var err error
+		if resolve, err = loadRedirects(*resolveFile); err != nil {
+			return err
+		}
+	}
+	writer, finish, err := openOutput(*linkFile, "links-"+*linkFormat, run)
+	if err != nil {
+		return err
+	}
+	// Write errors are sticky in the csv writer too.
+	csvWriter := csv.NewWriter(writer)
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		source := siteInfo.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		links := make([]wikitext.Link, 0, 10)
+		for _, link := range wikitext.Links(doc) {
+			if classes[link.Class] && inLinkScope(link) {
+				links = append(links, link)
+			}
+		}
+		total += len(links)
+		switch *linkFormat {
+		case "csv":
+			for _, link := range links {
+				target := linkTarget(source, link, resolve)
+				csvWriter.Write([]string{source, target, link.Section, link.Interwiki, link.Class.String(), link.Anchor})
+			}
+		case "adjacency":
+			writer.WriteString(source)
+			for _, link := range links {
+				if link.Interwiki == "" {
+					writer.WriteString("\t" + linkTarget(source, link, resolve))
+				}
+			}
+			writer.WriteString("\n")
+		default:
+			for _, link := range links {
+				target := linkTarget(source, link, resolve)
+				anchor := strings.Replace(link.Anchor, "\t", " ", -1)
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", source, target, link.Section, link.Interwiki, link.Class, anchor)
+			}
+		}
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "links", total)
+	return err
+}
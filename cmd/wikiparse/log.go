@@ -0,0 +1,61 @@
+// The log of a run: errors, notices and the totals of the commands, as
+// structured records on stderr that long batch runs can be searched and
+// correlated by
+
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/secret"
+)
+
+var logFormat = flag.String("logformat", "text", "`format` of the log records on stderr: text, as key=value pairs, or json, one object per line")
+var logLevel = flag.String("loglevel", "info", "least `level` of the records logged: debug (with a record per page read), info, warn or error")
+
+var logFormats = []string{"text", "json"}
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// logFlags are the flags of the log, which every command takes.
+var logFlags = []string{"logformat", "loglevel"}
+
+// logger is the log of the run, set up by setupLogger.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// setupLogger makes logger log to stderr in -logformat the records of
+// -loglevel and above, with the credentials read by the run redacted. A
+// format or level that is not known, which validateConfig reports, is
+// taken to be the default.
+func setupLogger() {
+	var level slog.Level
+	if level.UnmarshalText([]byte(*logLevel)) != nil {
+		level = slog.LevelInfo
+	}
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if *logFormat == "json" {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+	}
+}
+
+// redactAttr redacts the credentials in the strings and errors logged.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch v := a.Value.Any().(type) {
+	case string:
+		a.Value = slog.StringValue(secret.Redact(v))
+	case error:
+		a.Value = slog.StringValue(secret.Redact(v.Error()))
+	}
+	return a
+}
+
+// pageLogger returns logger with the id and title of the page, for the
+// records about it.
+func pageLogger(p *dump.Page) *slog.Logger {
+	return logger.With("page_id", p.ID, "title", p.Title)
+}
@@ -0,0 +1,641 @@
+// Command wikiparse extracts articles, links and categories from
+// Wikipedia XML dumps, with a subcommand for each kind of output, like
+// "wikiparse links"; "wikiparse -h" lists them.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/completion"
+	"github.com/pcmoritz/wikipedia/internal/filename"
+	"github.com/pcmoritz/wikipedia/internal/filter"
+	"github.com/pcmoritz/wikipedia/internal/pagestore"
+	"github.com/pcmoritz/wikipedia/internal/progress"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var inputFile = flag.String("infile", "enwiki-latest-pages-articles.xml", "Input file path")
+var indexFile = flag.String("indexfile", "out/article_list.txt", "article list output file")
+var redirectFile = flag.String("redirectfile", "", "redirect table output file (none if empty)")
+var abstract = flag.Bool("abstract", false, "write only the first paragraph of each article, without markup")
+var abstractSentences = flag.Int("abstractsentences", 0, "with -abstract, write only the first `n` sentences (all if 0)")
+var keepEntities = flag.Bool("keepentities", false, "with -abstract, keep character references like &nbsp; instead of decoding them")
+var maxChars = flag.Int("maxchars", 0, "end the plain text of each article at the last sentence within `n` characters (no limit if 0)")
+var maxWords = flag.Int("maxwords", 0, "end the plain text of each article at the last sentence within `n` words (no limit if 0)")
+var maxSentences = flag.Int("maxsentences", 0, "end the plain text of each article after `n` sentences (no limit if 0)")
+var skeleton = flag.Bool("skeleton", false, "write only the headings, links and categories of each article, without text")
+var toc = flag.Bool("toc", false, "write only the table of contents of each article, with the anchors of its headings")
+var titleFilter = flag.String("match", "", "process only the pages whose title matches the `regexp` (all if empty)")
+var titlePrefix = flag.String("titleprefix", "", "process only the pages whose title starts with the `prefix`, with underscores for spaces (all if empty)")
+var titleFile = flag.String("titlefile", "", "process only the pages with the titles in this file, one per line, compared as canonical titles (all if empty)")
+var filterExpr = flag.String("filter", "", "process only the pages for which the `expression` is true, like 'namespace == 0 && len(text) > 2000 && hasTemplate(\"Infobox person\")' (all if empty)")
+var namespaceList = flag.String("namespaces", "", "process only the pages in the namespaces of the comma-separated `numbers`, like 0,14 (all if empty)")
+var workers = flag.Int("workers", 1, "number of articles parsed at once, in `n` goroutines")
+var auditFile = flag.String("auditfile", "out/audit.jsonl", "append-only JSONL log of runs (disabled if empty)")
+var progressInterval = flag.Duration("progress", 0, "report the progress to stderr every `interval`, like 30s (never if 0)")
+var statusFile = flag.String("statusfile", "", "with -progress, also write the progress as JSON to this file (none if empty)")
+var completionFlags = completion.Register(flag.CommandLine)
+
+func init() {
+	flag.Var(articleFormat{}, "articleformat", "articles output `format`: text, abstract (like -abstract), skeleton (like -skeleton) or toc (like -toc)")
+}
+
+var articleFormats = []string{"text", "abstract", "skeleton", "toc"}
+
+// An articleFormat is the value of -articleformat, which sets -abstract,
+// -skeleton and -toc.
+type articleFormat struct{}
+
+func (articleFormat) String() string {
+	switch {
+	case abstract != nil && *abstract:
+		return "abstract"
+	case skeleton != nil && *skeleton:
+		return "skeleton"
+	case toc != nil && *toc:
+		return "toc"
+	}
+	return "text"
+}
+
+func (articleFormat) Set(value string) error {
+	if err := checkChoice("-articleformat", value, articleFormats); err != nil {
+		return err
+	}
+	*abstract, *skeleton, *toc = value == "abstract", value == "skeleton", value == "toc"
+	return nil
+}
+
+// titlePattern is the compiled -match, nil if all pages are processed.
+var titlePattern *regexp.Regexp
+
+// selectedTitles are the canonical titles of -titlefile, nil if all
+// pages are processed.
+var selectedTitles map[string]bool
+
+// selectedNamespaces are the namespaces of -namespaces, nil if pages of
+// all namespaces are processed.
+var selectedNamespaces map[int]bool
+
+// pageFilter is the compiled -filter, nil if all pages are processed.
+var pageFilter *filter.Filter
+
+// The command of the run with the arguments after its flags, and the
+// command given after estimate or download, set by main.
+var activeCommand, nextCommand *command
+var commandArgs []string
+
+// docsDir is the directory articles are written to.
+var docsDir = "out/docs"
+
+// ctx is cancelled when the run is interrupted, which makes the commands
+// stop reading the dump after the page they are at.
+var ctx = context.Background()
+
+func WritePage(name string, text string) error {
+	outFile, err := os.Create(filepath.Join(docsDir, name))
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(outFile)
+	writer.WriteString(text)
+	if err := writer.Flush(); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
+
+func writeRedirects(path string, redirects *dump.RedirectTable) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := redirects.WriteTo(outFile); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
+
+// headerless are the kinds of outputs of openOutput in formats of their
+// own, which start without a schema header.
+var headerless = map[string]bool{"diffs": true, "protobuf": true, "stats": true}
+
+// openOutput creates the output file at path, or uses stdout if path is
+// empty, for a table of the given kind and writes its header. Write
+// errors are sticky in the returned writer: the returned function
+// flushes it, closes the file, records the output in run and returns the
+// first error.
+func openOutput(path string, kind string, run *audit.Record) (*bufio.Writer, func() error, error) {
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if path != "" {
+		var err error
+		if file, err = os.Create(path); err != nil {
+			return nil, nil, err
+		}
+		out = file
+	} else {
+		path = "-"
+	}
+	digest := audit.NewDigest()
+	writer := bufio.NewWriter(io.MultiWriter(out, digest))
+	switch {
+	case kind == "terms-binary":
+		// Binary term files start with their magic instead.
+		writer.WriteString(termsMagic)
+	case !headerless[kind]:
+		schema.WriteHeader(writer, kind)
+	}
+	finish := func() error {
+		err := writer.Flush()
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		run.AddOutput(path, kind, digest)
+		return err
+	}
+	return writer, finish, nil
+}
+
+// isArticle reports whether the page is an article, i.e. neither a
+// redirect nor in a namespace of nonArticleNamespaces of the site, that
+// isSelected.
+func isArticle(p *dump.Page) bool {
+	number, _ := site.Split(p.Title)
+	return !nonArticleNamespaces[number] && p.Redir.Title == "" && isSelected(p)
+}
+
+// isSelected reports whether the page has a selected title, is in one of
+// -namespaces and matches -filter.
+func isSelected(p *dump.Page) bool {
+	if selectedNamespaces != nil {
+		if number, _ := site.Split(p.Title); !selectedNamespaces[number] {
+			return false
+		}
+	}
+	return isSelectedTitle(p.Title) && (pageFilter == nil || pageFilter.Match(p, site))
+}
+
+// isSelectedTitle reports whether the title matches -match, starts with
+// -titleprefix, is one of -titlefile and in the sample of -sample.
+func isSelectedTitle(title string) bool {
+	return (titlePattern == nil || titlePattern.MatchString(title)) &&
+		strings.HasPrefix(title, strings.ReplaceAll(*titlePrefix, "_", " ")) &&
+		(selectedTitles == nil || selectedTitles[dump.CanonicalizeTitle(title)]) &&
+		sampled(title)
+}
+
+// parseNamespaces returns the namespaces of a list of their numbers
+// separated by commas, like -namespaces.
+func parseNamespaces(list string) (map[int]bool, error) {
+	namespaces := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not the number of a namespace", field)
+		}
+		namespaces[number] = true
+	}
+	return namespaces, nil
+}
+
+// textLimit returns the limit of the plain text of -maxchars, -maxwords
+// and -maxsentences.
+func textLimit() wikitext.TextLimit {
+	return wikitext.TextLimit{Chars: *maxChars, Words: *maxWords, Sentences: *maxSentences}
+}
+
+// extractArticles writes every article of the dump to out/docs, or its
+// abstract with -abstract, its skeleton with -skeleton or its table of
+// contents with -toc, named by the canonical title made safe for all file
+// systems and rendered in -workers goroutines. With -expandtemplates,
+// templates are expanded first. With -manifest, articles whose revision
+// is unchanged since the previous run keep their file. With -checkpoint,
+// the progress is recorded every checkpointInterval pages, and with
+// -resume the articles extracted before the last checkpoint keep their
+// file too. With -incremental, the dump only holds the pages changed
+// since the run that wrote the manifest: the articles of all other pages
+// keep their file, and those of pages that are no longer articles are
+// removed. Articles that cannot be written are logged, and fail the run
+// once all others are.
+func extractArticles(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	docs := audit.NewDigest()
+	total, skipped, removed, failed := 0, 0, 0, 0
+	var templates *wikitext.TemplateStore
+	templatesSum := ""
+	if *expandTemplates {
+		store, sum, err := loadTemplates(*inputFile)
+		if err != nil {
+			return fmt.Errorf("reading templates: %w", err)
+		}
+		templates, templatesSum = store, sum
+		logger.Info("Templates read", "templates", store.Len())
+	}
+	names := filename.NewNamer()
+	key := manifestKey(run, templatesSum)
+	var previous, current *dump.Manifest
+	if *manifestFile != "" {
+		previous, current = loadManifest(*manifestFile, key), dump.NewManifest(key)
+	}
+	if previous != nil {
+		// The articles keep the names of the previous run, and new ones
+		// get others.
+		for title, name := range previous.Names() {
+			if name != "" {
+				names.Reserve(title, name)
+			}
+		}
+	}
+	if *incremental {
+		if previous.Len() == 0 {
+			return fmt.Errorf("applying the incremental dump: no manifest %s of a previous run with the same settings", *manifestFile)
+		}
+		// The manifest of the snapshot is updated by the pages changed.
+		current = previous
+	}
+	var resumePages, resumeID int64
+	if *resume {
+		resumePages, resumeID = loadCheckpoint(*checkpointFile, key)
+	}
+	// reuse digests the file already written for an article.
+	reuse := func(title string, name string) bool {
+		text, err := os.ReadFile(filepath.Join(docsDir, name))
+		if err == nil {
+			io.WriteString(docs, title+"\n"+string(text))
+			total++
+		}
+		return err == nil
+	}
+	// render returns the text written for the article with the exact title.
+	render := func(exact string, text string) string {
+		if templates != nil {
+			text = templates.Expand(text, *templateDepth, wikitext.ExpandTitle(exact),
+				wikitext.ExpandDepthExceeded(func(string) { templateDepthHits.Inc() }), wikitext.ExpandStrip(stripList))
+		}
+		switch {
+		case *abstract:
+			doc := parseArticle(exact, text)
+			doc.KeepEntities = *keepEntities
+			doc.Limit = textLimit()
+			text = wikitext.Abstract(doc, *abstractSentences) + "\n"
+		case *skeleton:
+			doc := parseArticle(exact, text)
+			text = wikitext.Skeleton(doc) + "\n"
+		case *toc:
+			doc := parseArticle(exact, text)
+			text = wikitext.FormatTOC(wikitext.TOC(doc)) + "\n"
+		}
+		return text
+	}
+	// The articles are rendered in -workers goroutines, but for those likely
+	// to keep their file, which are rendered if they do not.
+	type rendered struct {
+		text string
+		ok   bool
+	}
+	renderAhead := func(i int, p *dump.Page) rendered {
+		if !isArticle(p) || int64(i) < resumePages ||
+			(previous != nil && !*incremental && previous.Unchanged(p.Title, p.SHA1)) {
+			return rendered{}
+		}
+		return rendered{render(p.Title, p.Text), true}
+	}
+	input := &countingReader{r: r}
+	pages, lastID := int64(0), int64(0)
+	for p, out := range inParallel(dumpPages(input), renderAhead) {
+		if *checkpointFile != "" && pages > 0 && pages%checkpointInterval == 0 {
+			c := &checkpoint{Key: key, Pages: pages, PageID: lastID, Offset: input.n.Load()}
+			if err := c.write(*checkpointFile); err != nil {
+				logger.Error("Error writing checkpoint", "err", err)
+			}
+		}
+		pages, lastID = pages+1, p.ID
+		if pages == resumePages && p.ID != resumeID {
+			return fmt.Errorf("resuming: page %d of the dump, %q, has the id %d instead of %d as in the checkpoint; is it the same dump?", pages, p.Title, p.ID, resumeID)
+		}
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		} else if *incremental {
+			redirects.Remove(p.Title)
+		}
+		exact, article := p.Title, isArticle(p)
+		p.Title = dump.CanonicalizeTitle(p.Title)
+		if !article && *incremental && current.Has(exact) {
+			name := current.Name(exact)
+			if name == "" {
+				name = filename.Safe(p.Title)
+			}
+			os.Remove(filepath.Join(docsDir, name))
+			current.Remove(exact)
+			removed++
+		}
+		if article {
+			name := names.Name(exact, p.Title)
+			if current != nil {
+				unchanged := previous.Unchanged(exact, p.SHA1)
+				current.Add(exact, p.SHA1, name)
+				if unchanged && reuse(p.Title, name) {
+					skipped++
+					continue
+				}
+			}
+			if pages <= resumePages && reuse(p.Title, name) {
+				continue
+			}
+			if !out.ok {
+				out.text = render(exact, p.Text)
+			}
+			if err := WritePage(name, out.text); err != nil {
+				pageLogger(p).Error("Error writing article", "err", err)
+				failed++
+			}
+			io.WriteString(docs, p.Title+"\n"+out.text)
+			total++
+		}
+	}
+	run.AddOutput(docsDir, "docs", docs)
+	if ctx.Err() != nil {
+		// The checkpoint of an interrupted run lets -resume go on from here.
+		if *checkpointFile != "" {
+			c := &checkpoint{Key: key, Pages: pages, PageID: lastID, Offset: input.n.Load()}
+			if err := c.write(*checkpointFile); err != nil {
+				logger.Error("Error writing checkpoint", "err", err)
+			}
+		}
+		logger.Info("Totals", "articles", total)
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d articles not written", failed)
+	}
+	if current != nil {
+		if err := writeManifest(*manifestFile, current); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+		run.AddFile(*manifestFile, "manifest")
+		logger.Info("Unchanged articles skipped", "articles", skipped)
+	}
+	if *incremental {
+		logger.Info("Articles removed", "articles", removed)
+	}
+	if *checkpointFile != "" {
+		os.Remove(*checkpointFile)
+	}
+	logger.Info("Totals", "articles", total)
+	return nil
+}
+
+func main() {
+	os.Exit(runCommand())
+}
+
+// runCommand runs the command of the arguments and returns the exit
+// status of the run: 1 if it failed, 2 for an invalid configuration and
+// 130 if it was interrupted.
+func runCommand() int {
+	flag.Usage = usage
+	flag.Parse()
+	completionFlags.Commands, completionFlags.Values = completionCommands(), completionValues()
+	if exit, err := completionFlags.Handle(); err != nil {
+		setupLogger()
+		logger.Error("Invalid configuration", "err", &configError{"-completion", err.Error()})
+		return 2
+	} else if exit {
+		return 0
+	}
+	var err error
+	if activeCommand, commandArgs, err = parseCommand(flag.Args()); err == nil {
+		switch {
+		case activeCommand.name == "estimate":
+			// The flags of the command estimated follow its name.
+			nextCommand, commandArgs, err = parseCommand(commandArgs)
+		case activeCommand.name == "download" && len(commandArgs) > 3:
+			// So do those of the command reading the dump downloaded.
+			nextCommand, _, err = parseCommand(commandArgs[3:])
+			commandArgs = commandArgs[:3]
+		}
+	}
+	if err == nil && *configFile != "" {
+		err = loadJob()
+	}
+	setupLogger()
+	if err == flag.ErrHelp {
+		return 0
+	} else if _, ok := err.(*configError); ok {
+		logger.Error("Invalid configuration", "err", err)
+		return 2
+	} else if err != nil {
+		// The flag set has reported the error with the usage.
+		return 2
+	}
+
+	// The first interrupt stops the run cleanly, a second one at once.
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	switch activeCommand.name {
+	case "search":
+		return runSearch()
+	case "watch":
+		return runWatch()
+	case "get":
+		return runGet()
+	case "doctor":
+		return runDoctor(validateConfig())
+	}
+	if errs := validateConfig(); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Error("Invalid configuration", "err", err)
+		}
+		return 2
+	}
+	if activeCommand.name == "download" {
+		if code := runDownload(); code != 0 || nextCommand == nil {
+			return code
+		}
+		// The command following download reads the dump downloaded.
+		activeCommand = nextCommand
+	}
+	if *metricsAddr != "" {
+		stop := serveMetrics()
+		defer stop()
+	}
+	if *titleFilter != "" {
+		titlePattern = regexp.MustCompile(*titleFilter)
+	}
+	if *titleFile != "" {
+		if selectedTitles, err = readTitleFile(*titleFile); err != nil {
+			logger.Error("Error reading titles", "err", err)
+			return 1
+		}
+	}
+	if *namespaceList != "" {
+		// Valid, as checked by validateConfig.
+		selectedNamespaces, _ = parseNamespaces(*namespaceList)
+	}
+	if *sampleRate > 0 {
+		sampleSalt = newRand().Uint64()
+	}
+	if *filterExpr != "" {
+		// Valid, as checked by validateConfig.
+		pageFilter, _ = filter.Compile(*filterExpr)
+	}
+	if *pipelineFile != "" {
+		if processors, err = readPipeline(*pipelineFile); err != nil {
+			logger.Error("Error reading pipeline", "err", err)
+			return 1
+		}
+	}
+	if *dedupMode != "" {
+		duplicates = newDuplicates()
+	}
+	if *strip {
+		if stripList, err = readStripList(); err != nil {
+			logger.Error("Error reading strip list", "err", err)
+			return 1
+		}
+	}
+	if site, siteInfo, err = loadSite(*inputFile); err != nil {
+		logger.Error("Error reading site information", "err", err)
+		return 1
+	}
+	if activeCommand.name == "estimate" {
+		return runEstimate()
+	}
+	switch activeCommand.name {
+	case "serve":
+		return runServe()
+	case "grpc":
+		return runGRPC()
+	}
+
+	xmlFile, source, err := openInput()
+	if err != nil {
+		logger.Error("Error opening file", "err", err)
+		return 1
+	}
+	if xmlFile != nil {
+		defer xmlFile.Close()
+	}
+	if closeDump != nil {
+		// Once the outputs are written, as the pages refer to a mapping.
+		defer closeDump()
+	}
+
+	// The dump is hashed while it is read, for the audit log.
+	run := audit.New(activeCommand.name, flag.CommandLine, timestamp())
+	if *pageStoreFile != "" {
+		// Items lexed by other code are lexed again.
+		if pageStore, err = pagestore.Open(*pageStoreFile, run.Version); err != nil {
+			logger.Error("Error opening page store", "err", err)
+			return 1
+		}
+	}
+	input := audit.NewDigest()
+	reader := io.TeeReader(source, input)
+	indexed := false
+	if pages := indexedPages(xmlFile); pages != nil {
+		// Only the pages selected are read, and hashed.
+		reader, indexed = io.TeeReader(pages, input), true
+	}
+	reader = meteredReader{reader}
+	if *progressInterval > 0 {
+		size := int64(0)
+		if info, err := xmlFile.Stat(); err == nil && !compressedDump(*inputFile) {
+			// The size of the XML of compressed dumps is unknown.
+			size = info.Size()
+		}
+		counter := progress.NewReader(reader, size)
+		reader = counter
+		stop := counter.Report(*progressInterval, logger, *statusFile)
+		defer stop()
+	}
+
+	redirects := newRedirectTable()
+	if *incremental && *redirectFile != "" {
+		// The redirects of the snapshot, updated by the incremental dump.
+		if previous, err := loadRedirects(*redirectFile); err == nil {
+			redirects = previous
+		}
+	}
+	failed := false
+	if err := activeCommand.pipeline(reader, redirects, run); err != nil {
+		logger.Error(activeCommand.failure, "err", err)
+		failed = true
+	}
+	if fetched, ok := source.(*failingReader); ok && fetched.err != nil && ctx.Err() == nil {
+		// The outputs lack the pages after the failed request.
+		logger.Error("Error fetching pages", "err", fetched.err)
+		failed = true
+	} else if dumpErr != nil {
+		// The outputs lack the pages after the error, like those of a
+		// truncated dump.
+		logger.Error("Error reading dump", "err", dumpErr)
+		failed = true
+	}
+	if pageStore != nil {
+		if err := pageStore.Close(); err != nil {
+			logger.Error("Error writing page store", "err", err)
+			failed = true
+		}
+		logger.Info("Articles read from the page store", "stored", storedArticles.Load(), "lexed", lexedArticles.Load())
+	}
+	if ctx.Err() != nil {
+		// The outputs are incomplete, so neither the redirects nor the
+		// run are recorded.
+		logger.Warn("Interrupted")
+		return 130
+	}
+	if failed {
+		// So are those of a failed run.
+		return 1
+	}
+
+	if *redirectFile != "" {
+		if err := writeRedirects(*redirectFile, redirects); err != nil {
+			logger.Error("Error writing redirects", "err", err)
+			return 1
+		}
+		run.AddFile(*redirectFile, "redirects")
+	}
+
+	if *auditFile != "" {
+		// Only what was read of a dump cut short by -limit, or of the
+		// pages selected in the page index, is hashed, so that the rest of
+		// it is not read after all.
+		partial := stoppedAtLimit || indexed
+		if xmlFile != nil && !partial {
+			// The rest of the dump, not read by the pipeline.
+			io.Copy(input, source)
+		}
+		run.Input = inputEntry(input)
+		run.Input.Partial = partial
+		if err := run.AppendTo(*auditFile); err != nil {
+			logger.Error("Error writing audit log", "err", err)
+			return 1
+		}
+	}
+
+	logger.Info("Totals", "redirects", redirects.Len())
+	return 0
+}
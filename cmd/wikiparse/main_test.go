@@ -0,0 +1,680 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tests run every command of wikiparse, built by TestMain, on the
+// dumps of testdata, writing to a temporary directory, and check what
+// they write.
+
+// wikiparse is the path of the command built by TestMain.
+var wikiparse string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "wikiparse-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	wikiparse = filepath.Join(dir, "wikiparse")
+	if out, err := exec.Command("go", "build", "-o", wikiparse, ".").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error building wikiparse: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testdata returns the absolute path of the file of testdata.
+func testdata(t *testing.T, name string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("..", "..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// workDir returns a temporary directory with out/docs to run in.
+func workDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "out", "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// run runs wikiparse with the arguments in dir, failing the test unless
+// it succeeds, and returns what it printed and what it logged.
+func run(t *testing.T, dir string, args ...string) (string, string) {
+	t.Helper()
+	cmd := exec.Command(wikiparse, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("wikiparse %s: %v\n%s", strings.Join(args, " "), err, stderr.Bytes())
+	}
+	return stdout.String(), stderr.String()
+}
+
+// expectText reports an error unless a line of the text matches the
+// regular expression.
+func expectText(t *testing.T, name string, text string, pattern string) {
+	t.Helper()
+	if !regexp.MustCompile("(?m)" + pattern).MatchString(text) {
+		t.Errorf("%s has no line matching %s", name, pattern)
+	}
+}
+
+// expect reports an error unless a line of the file matches the regular
+// expression.
+func expect(t *testing.T, path string, pattern string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expectText(t, path, string(data), pattern)
+}
+
+// lines returns the lines of the file besides its schema header.
+func lines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	all := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		all = nil
+	}
+	return slices.DeleteFunc(all, func(line string) bool { return strings.HasPrefix(line, "#schema") })
+}
+
+// count reports an error unless the file has n lines besides its schema
+// header.
+func count(t *testing.T, path string, n int) {
+	t.Helper()
+	if got := len(lines(t, path)); got != n {
+		t.Errorf("%s has %d lines, expected %d", path, got, n)
+	}
+}
+
+// prefixed returns the number of lines of the file starting with prefix.
+func prefixed(t *testing.T, path string, prefix string) int {
+	t.Helper()
+	n := 0
+	for _, line := range lines(t, path) {
+		if strings.HasPrefix(line, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// same reports an error unless the files have the same content.
+func same(t *testing.T, a string, b string) {
+	t.Helper()
+	x, err := os.ReadFile(a)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	y, err := os.ReadFile(b)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(x, y) {
+		t.Errorf("%s and %s differ", a, b)
+	}
+}
+
+// entries returns the number of files in the directory.
+func entries(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Error(err)
+	}
+	return len(files)
+}
+
+// writeFile writes the file, failing the test if it cannot.
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lastLine returns the last line of the file.
+func lastLine(t *testing.T, path string) string {
+	t.Helper()
+	all := lines(t, path)
+	if len(all) == 0 {
+		return ""
+	}
+	return all[len(all)-1]
+}
+
+// TestMinidump runs every command on testdata/minidump.xml, in the order
+// of a pipeline whose later commands read what earlier ones wrote.
+func TestMinidump(t *testing.T) {
+	dir := workDir(t)
+	dump := testdata(t, "minidump.xml")
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	en := func(args ...string) string {
+		t.Helper()
+		_, stderr := run(t, dir, append([]string{"-infile", dump, "-auditfile", "out/audit.jsonl"}, args...)...)
+		return stderr
+	}
+	other := func(dump string, args ...string) {
+		t.Helper()
+		run(t, dir, append([]string{"-infile", dump, "-auditfile", "out/audit.jsonl"}, args...)...)
+	}
+
+	en("-redirectfile", "out/redirects.tsv", "-expandtemplates")
+	expect(t, out("docs/venus"), `^'''Venus''' is planet number 2 from the \[\[Sun\]\]`)
+	expect(t, out("docs/venus"), `^Venus has no moons\.$`)
+	expect(t, out("redirects.tsv"), `^apollo_xi\tapollo_11$`)
+	if n := entries(t, out("docs")); n != 26 {
+		t.Errorf("extracted %d articles, expected 26", n)
+	}
+
+	en("-linkfile", "out/links.tsv", "links")
+	expect(t, out("links.tsv"), `^apollo_11\tmoon\t\t\tarticle\tlunar$`)
+	expect(t, out("links.tsv"), `^apollo_11\tapollo_11\t\tde\tinterwiki\tde:Apollo 11$`)
+	en("-linkfile", "out/links.csv", "-linkformat", "csv", "links")
+	expect(t, out("links.csv"), `^apollo_11,moon,,,article,lunar$`)
+	// The flags of a command can follow its name.
+	en("links", "-linkfile", "out/links-parallel.csv", "-format", "csv", "-workers", "4", "-match", "^Apollo")
+	expect(t, out("links-parallel.csv"), `^apollo_11,moon,,,article,lunar$`)
+	count(t, out("links-parallel.csv"), prefixed(t, out("links.csv"), "apollo_11,"))
+	// However the workers are scheduled, the output is in the order of the dump.
+	en("links", "-linkfile", "out/links-workers.csv", "-linkformat", "csv", "-workers", "8")
+	same(t, out("links.csv"), out("links-workers.csv"))
+	// The log as JSON lines, with a record per page read.
+	status := en("links", "-linkfile", "out/links-logged.csv", "-linkformat", "csv", "-logformat", "json", "-loglevel", "debug")
+	expectText(t, "the log", status, `"level":"DEBUG","msg":"Page read","page_id":1,"title":"Apollo 11",`)
+	expectText(t, "the log", status, `"level":"INFO","msg":"Totals","links":53\}$`)
+	en("links", "-linkfile", "out/links-mapped.csv", "-linkformat", "csv", "-workers", "4", "-mmap")
+	same(t, out("links.csv"), out("links-mapped.csv"))
+	// The mini dump in bzip2 streams of four pages, decompressed four at a
+	// time as told by the index beside it.
+	en("links", "-infile", testdata(t, "minidump-multistream.xml.bz2"), "-linkfile", "out/links-multistream.csv", "-linkformat", "csv", "-workers", "4")
+	same(t, out("links.csv"), out("links-multistream.csv"))
+	en("links", "-linkfile", "out/links-filtered.tsv", "-filter", `namespace == 0 && hasCategory("Planets of the Solar System") && len(text) > 100`)
+	expect(t, out("links-filtered.tsv"), `^mars\t`)
+	sources := make([]string, 0, 3)
+	for _, line := range lines(t, out("links-filtered.tsv")) {
+		if source, _, _ := strings.Cut(line, "\t"); !strings.HasPrefix(line, "#") && !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	if slices.Sort(sources); !slices.Equal(sources, []string{"earth", "mars", "venus"}) {
+		t.Errorf("-filter selected %v, expected earth, mars and venus", sources)
+	}
+	en("-externallinkfile", "out/externallinks.tsv", "externallinks")
+	count(t, out("externallinks.tsv"), 4)
+	en("-categoryfile", "out/categories.tsv", "-categorytreefile", "out/categorytree.tsv", "categories")
+	expect(t, out("categories.tsv"), `^mars\tplanets_of_the_solar_system\t1$`)
+	en("-imagefile", "out/images.tsv", "images")
+	expect(t, out("images.tsv"), `^mars\tOSIRIS Mars true color\.jpg\tThe red planet\tA red planet$`)
+	en("-languagelinkfile", "out/languagelinks.tsv", "languagelinks")
+	expect(t, out("languagelinks.tsv"), `^apollo_11\tde\tApollo 11$`)
+	expect(t, out("languagelinks.tsv"), `^moon\tfr\tLune$`)
+	en("-triplefile", "out/triples.nt", "triples")
+	expect(t, out("triples.nt"), `^<http://dbpedia.org/resource/Apollo_11> <http://dbpedia.org/property/crew> <http://dbpedia.org/resource/Buzz_Aldrin> \.$`)
+	expect(t, out("triples.nt"), `^<http://dbpedia.org/resource/Moon> <http://purl.org/dc/terms/subject> <http://dbpedia.org/resource/Category:Moon> \.$`)
+	en("triples", "-triplefile", "out/triples.ttl", "-tripleformat", "turtle", "-triplemapping", testdata(t, "triples.json"))
+	expect(t, out("triples.ttl"), `^<http://dbpedia.org/resource/Moon> a <http://dbpedia.org/ontology/Planet> ;$`)
+	expect(t, out("triples.ttl"), `^    <http://dbpedia.org/ontology/satelliteOf> <http://dbpedia.org/resource/Earth> ;$`)
+	en("-sectionfile", "out/sections.tsv", "sections")
+	expect(t, out("sections.tsv"), `^earth\t2\tOrbit\tOrbit_2\thttps://en.wikipedia.org/w/index.php\?oldid=1007#Orbit_2$`)
+	en("-statsfile", "out/stats.tsv", "-errorfile", "out/errors.tsv", "stats")
+	expect(t, out("stats.tsv"), `^total\tdocuments\t26$`)
+	expect(t, out("stats.tsv"), `^errors\tmalformed link\t1$`)
+	expect(t, out("errors.tsv"), `^stub_article\t1\t24\tmalformed link\tunclosed "\[\["$`)
+	en("stats", "-statsfile", "out/stats.json", "-format", "json")
+	expect(t, out("stats.json"), `"documents":26`)
+	// The second run reads the items of all articles from the page store.
+	status = en("stats", "-statsfile", "out/stats-stored.tsv", "-pagestore", "out/pages.store")
+	expectText(t, "the log", status, `msg="Articles read from the page store" stored=0 lexed=26$`)
+	status = en("stats", "-statsfile", "out/stats-stored.tsv", "-pagestore", "out/pages.store")
+	expectText(t, "the log", status, `msg="Articles read from the page store" stored=26 lexed=0$`)
+	same(t, out("stats.tsv"), out("stats-stored.tsv"))
+	en("-wikidatafile", "out/wikidata.tsv", "-pagepropsfile", testdata(t, "page_props.sql"), "wikidata")
+	expect(t, out("wikidata.tsv"), `^neil_armstrong\tQ1615\tpageprops$`)
+	expect(t, out("wikidata.tsv"), `^apollo_11\tQ43653\ttemplate$`)
+	count(t, out("wikidata.tsv"), 2)
+	other(testdata(t, "history.xml"), "-historyfile", "out/history.tsv", "-difffile", "out/diffs.txt", "-changefile", "out/changes.tsv", "history")
+	count(t, out("history.tsv"), 3)
+	expect(t, out("history.tsv"), `^lunar_orbit\t102\t101\t2004-03-02T11:30:00Z\t192\.0\.2\.1\tfalse\t142\th102\t$`)
+	expect(t, out("history.tsv"), `^lunar_orbit\t103\t102\t2004-03-05T08:15:00Z\tExample\ttrue\t146\th103\ttypo and link$`)
+	expect(t, out("diffs.txt"), `^\+A '''lunar orbit''' is an \[\[orbit\]\] around the \[\[Moon\]\]\.$`)
+	expect(t, out("changes.tsv"), `^lunar_orbit\t102\ttext\tadded\t10 in 1966\. Apollo 8 was the first crewed one\.$`)
+	expect(t, out("changes.tsv"), `^lunar_orbit\t103\tlink\tadded\torbit$`)
+	en("sentences", "-sentencefile", "out/sentences.tsv")
+	expect(t, out("sentences.tsv"), `^neil_armstrong\tEarly_life\t0\tArmstrong was born in Wapakoneta, Ohio\.$`)
+	expect(t, out("sentences.tsv"), `^neil_armstrong\t\t0\tHe commanded Apollo 11\.$`)
+	en("-qualityfile", "out/quality.tsv", "quality")
+	expect(t, out("quality.tsv"), `^apollo_11\tfalse\t1\t5\ttrue\t210$`)
+	expect(t, out("quality.tsv"), `^stub_article\ttrue\t0\t0\tfalse\t50$`)
+	// A small -anchorbuffer merges the counts from temporary files.
+	en("anchors", "-anchorfile", "out/anchors.tsv", "-anchorbuffer", "3")
+	expect(t, out("anchors.tsv"), `^Moon\tmoon\t`)
+	en("pagerank", "-pagerankfile", "out/pagerank.tsv")
+	expect(t, out("pagerank.tsv"), `^apollo_11\t[0-9.e-]*\t4\t4$`)
+	count(t, out("pagerank.tsv"), 26)
+	writeFile(t, out("stopwords.txt"), "the\nof\n")
+	en("terms", "-termfile", "out/terms.tsv", "-termvectorfile", "out/termvectors.tsv", "-stopwordfile", "out/stopwords.txt", "-termbuffer", "10")
+	expect(t, out("terms.tsv"), `^apollo 11\t2\t6\t5$`)
+	expect(t, out("termvectors.tsv"), `^apollo_11\tlanding\t2$`)
+	if prefixed(t, out("terms.tsv"), "the\t") > 0 {
+		t.Errorf("%s has a stopword", out("terms.tsv"))
+	}
+	en("terms", "-termfile", "out/terms-top.tsv", "-termtopk", "2")
+	count(t, out("terms-top.tsv"), 4)
+	en("-sqlitefile", "out/wiki.db", "sqlite")
+	if db, err := os.ReadFile(out("wiki.db")); err != nil {
+		t.Error(err)
+	} else if !bytes.HasPrefix(db, []byte("SQLite format 3\x00")) || !bytes.Contains(db, []byte("Apollo 11")) {
+		t.Errorf("%s is no SQLite database of the articles", out("wiki.db"))
+	}
+	en("export", "-sink", "jsonl:out/articles.jsonl", "-sink", "files:out/articles")
+	count(t, out("articles.jsonl"), 26)
+	expect(t, out("articles.jsonl"), `^\{"title":"Apollo 11","page_id":1,"namespace":0,"revision":1001,"text":"Apollo 11 was the first crewed lunar landing\.`)
+	if n := entries(t, out("articles")); n != 27 {
+		t.Errorf("%s has %d files, expected 26 article files and the index", out("articles"), n)
+	}
+	en("export", "-sink", "wikiextractor:out/text", "-wikiextractorbytes", "2000")
+	expect(t, out("text/AA/wiki_00"), `^<doc id="1" url="https://en.wikipedia.org/wiki\?curid=1" title="Apollo 11">$`)
+	files, _ := filepath.Glob(out("text/AA/wiki_*"))
+	docs := 0
+	for _, f := range files {
+		docs += prefixed(t, f, "</doc>")
+	}
+	if docs != 26 {
+		t.Errorf("%s has %d documents, expected 26", out("text"), docs)
+	}
+	if len(files) < 2 {
+		t.Errorf("%s is not split into files of -wikiextractorbytes", out("text/AA"))
+	}
+	en("export", "-sink", "files:out/sharded", "-filesformat", "html", "-filesshards", "2")
+	count(t, out("sharded/_index.tsv"), 26)
+	expect(t, out("sharded/_index.tsv"), `^Apollo 11\t1\t1001\t[0-9a-f][0-9a-f]/[0-9a-f][0-9a-f]/apollo_11\.html$`)
+	for _, line := range lines(t, out("sharded/_index.tsv")) {
+		if fields := strings.Split(line, "\t"); fields[0] == "Apollo 11" && len(fields) == 4 {
+			expect(t, filepath.Join(out("sharded"), fields[3]), `^<p><b>Apollo 11</b> was the first crewed`)
+		}
+	}
+	expect(t, out("audit.jsonl"), `"kind":"jsonl"`)
+	writeFile(t, out("pipeline.yaml"), `# Planets, but those with broken templates
+processors:
+  - name: category
+    category: Planets of the Solar System
+  - name: notemplate
+    template: Unknown template
+`)
+	en("export", "-pipelinefile", "out/pipeline.yaml", "-sink", "jsonl:out/planets.jsonl")
+	count(t, out("planets.jsonl"), 2)
+	expect(t, out("planets.jsonl"), `^\{"title":"Mars",`)
+	writeFile(t, out("languages.yaml"), `processors:
+  - name: language
+    sections: true
+    keep: en
+`)
+	en("export", "-pipelinefile", "out/languages.yaml", "-sink", "jsonl:out/languages.jsonl")
+	expect(t, out("languages.jsonl"), `^\{"title":"Apollo 11",.*"annotations":\{"language":"en","language_confidence":[0-9.]*,"section_languages":\[\{"section":"","language":"en"\}`)
+	duplicates := testdata(t, "duplicates.xml")
+	other(duplicates, "export", "-dedup", "near", "-sink", "jsonl:out/duplicates.jsonl")
+	count(t, out("duplicates.jsonl"), 4)
+	expect(t, out("duplicates.jsonl"), `^\{"title":"Neil A\. Armstrong",.*"annotations":\{"duplicate_of":"Neil Armstrong","duplicate_similarity":1\}\}$`)
+	expect(t, out("duplicates.jsonl"), `^\{"title":"Armstrong, Neil",.*"annotations":\{"duplicate_of":"Neil Armstrong","duplicate_similarity":0\.[89][0-9]*\}\}$`)
+	other(duplicates, "export", "-dedup", "exact", "-dedupdrop", "-sink", "jsonl:out/duplicates.jsonl")
+	count(t, out("duplicates.jsonl"), 3)
+	en("export", "-match", "^Apollo 11$", "-maxwords", "12", "-sink", "jsonl:out/bounded.jsonl")
+	expect(t, out("bounded.jsonl"), `"text":"Apollo 11 was the first crewed lunar landing\.","categories"`)
+	en("export", "-match", "^Apollo 11$", "-maxsentences", "2", "-sink", "jsonl:out/bounded.jsonl")
+	expect(t, out("bounded.jsonl"), `"text":"Apollo 11 was the first crewed lunar landing\.\\n\\nMission\\n\\nThe Saturn V launched from Kennedy Space Center\.","categories"`)
+	en("-match", "^Venus$", "-abstract", "-maxchars", "12")
+	expect(t, out("docs/venus"), `^orbits the$`)
+	en("export", "-limit", "3", "-sink", "jsonl:out/limited.jsonl")
+	count(t, out("limited.jsonl"), 3)
+	expect(t, out("limited.jsonl"), `^\{"title":"Buzz Aldrin",`)
+	expectText(t, "the last audit record", lastLine(t, out("audit.jsonl")),
+		`"input":\{"path":"[^"]*","kind":"dump","sha256":"[0-9a-f]*","bytes":4096,"partial":true\}`)
+	en("export", "-sample", "0.5", "-seed", "7", "-sink", "jsonl:out/sample.jsonl")
+	en("export", "-sample", "0.5", "-seed", "7", "-workers", "4", "-sink", "jsonl:out/sample-again.jsonl")
+	same(t, out("sample.jsonl"), out("sample-again.jsonl"))
+	// 13 of the 26 articles are expected, with a standard deviation of
+	// √(26·0.5·0.5) ≈ 2.5: three of them allow 13 ± 7.
+	if n := len(lines(t, out("sample.jsonl"))); n < 6 || n > 20 {
+		t.Errorf("-sample 0.5 kept %d of 26 articles, expected 13 ± 7", n)
+	}
+	en("-match", "^Wapakoneta", "-expandtemplates", "-strip")
+	expect(t, out("docs/wapakoneta%2C_ohio"), `^'''Wapakoneta''' is a city in \[\[Ohio\]\]\.$`)
+	writeFile(t, out("strip.txt"), "# Project categories\nCategory:Project *\n")
+	en("export", "-match", "^Gemini 8$", "-strip", "-stripfile", "out/strip.txt", "-sink", "jsonl:out/stripped.jsonl")
+	expect(t, out("stripped.jsonl"), `"categories":\[\]\}$`)
+	navboxes := testdata(t, "navboxes.xml")
+	other(navboxes, "export", "-sink", "jsonl:out/navboxes.jsonl")
+	expect(t, out("navboxes.jsonl"), `"text":"Neil Alden Armstrong \(1930–2012\) was an American astronaut who became the first person to walk on the Moon\.\\n\\nExternal links","categories"`)
+	expect(t, out("navboxes.jsonl"), `"navboxes":\[\{"name":"S-start","links":\["Apollo 11","Pete Conrad"\]\},\{"name":"Navbox",.*\{"name":"div","links":\["Gemini 8"\]\}\]\}$`)
+	other(navboxes, "links", "-linkscope", "body", "-linkfile", "out/links-body.tsv")
+	count(t, out("links-body.tsv"), 1)
+	expect(t, out("links-body.tsv"), `^neil_armstrong\tmoon\t`)
+	other(navboxes, "links", "-linkscope", "nav", "-linkfile", "out/links-nav.tsv")
+	count(t, out("links-nav.tsv"), 6)
+	writeFile(t, out("job.yaml"), `command: export
+namespaces: [0]
+workers: 2
+sink:
+  - jsonl:out/job.jsonl
+processors:
+  - name: category
+    category: Planets of the Solar System
+`)
+	en("-config", "out/job.yaml")
+	count(t, out("job.jsonl"), 3)
+	writeFile(t, out("job.toml"), `command = "export"
+sink = ["jsonl:out/job.jsonl"]
+
+[[processors]]
+name = "notemplate"
+template = "Unknown template"
+`)
+	en("-config", "out/job.toml", "export", "-namespaces", "14")
+	count(t, out("job.jsonl"), 4)
+	en("-parquetfile", "out/articles.parquet", "parquet")
+	if parquet, err := os.ReadFile(out("articles.parquet")); err != nil {
+		t.Error(err)
+	} else if !bytes.HasPrefix(parquet, []byte("PAR1")) || !bytes.HasSuffix(parquet, []byte("PAR1")) {
+		t.Errorf("%s is no Parquet file", out("articles.parquet"))
+	}
+	en("protobuf", "-protofile", "out/articles.pb")
+	if pb, err := os.ReadFile(out("articles.pb")); err != nil {
+		t.Error(err)
+	} else if !bytes.Contains(pb, []byte("Apollo 11")) {
+		t.Errorf("%s lacks Apollo 11", out("articles.pb"))
+	}
+	en("-searchindex", "out/search.idx", "index")
+	results, _ := run(t, dir, "-searchindex", "out/search.idx", "-searchresults", "1", "search", "lunar landing")
+	expectText(t, "the search results", results, `^1\. Apollo 11 `)
+	results, _ = run(t, dir, "search", "-searchindex", "out/search.idx", "-searchresults", "1", "-format", "json", "lunar landing")
+	expectText(t, "the search results", results, `"title":"Apollo 11"`)
+	en("-pageindex", "out/pages.idx", "pageindex")
+	page, _ := run(t, dir, "get", "-infile", dump, "-pageindex", "out/pages.idx", "-format", "text", "Apollo XI")
+	expectText(t, "the page", page, `^Apollo 11 was the first crewed lunar landing`)
+	page, _ = run(t, dir, "get", "-infile", dump, "-pageindex", "out/pages.idx", "-format", "json", "-byid", "6")
+	expectText(t, "the page", page, `"title":"Moon"`)
+	// The titles selected are read at their offsets in the page index.
+	en("links", "-linkfile", "out/links-indexed.csv", "-format", "csv", "-match", "^Apollo", "-pageindex", "out/pages.idx")
+	same(t, out("links-indexed.csv"), out("links-parallel.csv"))
+	expectText(t, "the last audit record", lastLine(t, out("audit.jsonl")), `"partial":true`)
+	writeFile(t, out("titles.txt"), "Moon\nApollo_XI\nNo such page\n")
+	en("links", "-linkfile", "out/links-titles.tsv", "-titlefile", "out/titles.txt", "-pageindex", "out/pages.idx")
+	count(t, out("links-titles.tsv"), prefixed(t, out("links.tsv"), "moon\t"))
+	en("links", "-linkfile", "out/links-prefix.tsv", "-titleprefix", "Apollo_")
+	count(t, out("links-prefix.tsv"), prefixed(t, out("links.tsv"), "apollo_11\t"))
+
+	serve(t, dir, dump)
+
+	// One JSONL audit record per run.
+	count(t, out("audit.jsonl"), 56)
+	expect(t, out("audit.jsonl"), `"command":"sqlite"`)
+}
+
+// serve runs the serve command in dir and checks its answers.
+func serve(t *testing.T, dir string, dump string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	cmd := exec.Command(wikiparse, "serve", "-infile", dump, "-searchindex", "out/search.idx", "-addr", addr)
+	cmd.Dir = dir
+	var log bytes.Buffer
+	cmd.Stderr = &log
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) (string, error) {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("GET %s: %s", path, resp.Status)
+		}
+		return string(body), err
+	}
+	var moon string
+	for range 50 {
+		if moon, err = get("/article/Moon"); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	answers := map[string]string{"/article/Moon": moon}
+	for _, path := range []string{"/article/Apollo_11?format=html", "/links/Apollo_XI", "/metrics"} {
+		answer, err := get(path)
+		if err != nil {
+			t.Error(err)
+		}
+		answers[path] = answer
+	}
+	cmd.Process.Signal(os.Interrupt)
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("wikiparse serve: %v\n%s", err, log.Bytes())
+	}
+	expectText(t, "/article/Moon", answers["/article/Moon"], `"title":"Moon"`)
+	expectText(t, "/article/Apollo_11", answers["/article/Apollo_11?format=html"], `<a href="/article/Saturn_V">Saturn V</a>`)
+	expectText(t, "/links/Apollo_XI", answers["/links/Apollo_XI"], `"target":"Neil Armstrong"`)
+	expectText(t, "/metrics", answers["/metrics"], `^wikiparse_pages_read_total 35$`)
+	expectText(t, "/metrics", answers["/metrics"], `^# TYPE wikiparse_http_requests_total counter$`)
+}
+
+// TestGerman reads the German mini dump, with the namespaces of its
+// siteinfo and the aliases, image options and redirects of dewiki.json.
+func TestGerman(t *testing.T) {
+	dir := workDir(t)
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	de := func(args ...string) {
+		t.Helper()
+		run(t, dir, append([]string{"-infile", testdata(t, "minidump-de.xml"), "-sitefile", testdata(t, "dewiki.json"), "-auditfile", ""}, args...)...)
+	}
+	de("-expandtemplates", "-redirectfile", "out/redirects.tsv")
+	expect(t, out("docs/mond"), `^Der Mond ist ein Erdtrabant\.$`)
+	for _, name := range []string{"diskussion%3Amond", "datei%3Avollmond.jpg", "erdtrabant"} {
+		if _, err := os.Stat(out("docs/" + name)); err == nil {
+			t.Errorf("the German talk or file page or redirect %s was extracted as an article", name)
+		}
+	}
+	expect(t, out("redirects.tsv"), `^erdtrabant\tmond$`)
+	de("-linkfile", "out/links.tsv", "links")
+	expect(t, out("links.tsv"), `^mond\tkategorie%3Amond\t\t\tcategory\tKategorie:Mond$`)
+	expect(t, out("links.tsv"), `^mond\tdiskussion%3Amond\t\t\tnamespace\tDiskussion:Mond$`)
+	de("-categoryfile", "out/categories.tsv", "-categorytreefile", "out/categorytree.tsv", "categories")
+	expect(t, out("categories.tsv"), `^erde\thimmelsk%C3%B6rper\t1$`)
+	expect(t, out("categorytree.tsv"), `^mond\thimmelsk%C3%B6rper$`)
+	de("-imagefile", "out/images.tsv", "images")
+	expect(t, out("images.tsv"), `^mond\tVollmond\.jpg\tDer Vollmond`)
+}
+
+func TestTableOfContents(t *testing.T) {
+	dir := workDir(t)
+	run(t, dir, "-infile", testdata(t, "minidump.xml"), "-auditfile", "", "-toc")
+	toc := filepath.Join(dir, "out", "docs", "apollo_11")
+	expect(t, toc, `^1 Mission #Mission$`)
+	expect(t, toc, `^  1\.1 Landing #Landing$`)
+	expect(t, toc, `^2 Crew #Crew$`)
+}
+
+// gzipped writes the file gzipped to path, into a tar archive if inTar.
+func gzipped(t *testing.T, file string, path string, inTar bool) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if inTar {
+		tw := tar.NewWriter(zw)
+		tw.WriteHeader(&tar.Header{Name: filepath.Base(file), Mode: 0o644, Size: int64(len(data))})
+		tw.Write(data)
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		zw.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, b.String())
+}
+
+// TestEnterprise reads the Enterprise HTML dump, as published in a
+// gzipped tar archive, with the redirects its articles list.
+func TestEnterprise(t *testing.T) {
+	dir := workDir(t)
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	gzipped(t, testdata(t, "enterprise.ndjson"), filepath.Join(dir, "enwiki_namespace_0.tar.gz"), true)
+	run(t, dir, "-infile", "enwiki_namespace_0.tar.gz", "-informat", "enterprise", "-auditfile", "", "-redirectfile", "out/redirects.tsv")
+	expect(t, out("docs/apollo_11"), `was the first crewed \[\[Moon\|lunar\]\] landing\.$`)
+	expect(t, out("docs/moon"), `^\[\[Category:Moon\]\]$`)
+	expect(t, out("redirects.tsv"), `^apollo_xi\tapollo_11$`)
+}
+
+// TestCirrus reads the gzipped CirrusSearch dump, of the wikitext and of
+// the plain text the wiki rendered.
+func TestCirrus(t *testing.T) {
+	dir := workDir(t)
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	gzipped(t, testdata(t, "cirrus.ndjson"), filepath.Join(dir, "enwiki-cirrussearch-content.json.gz"), false)
+	run(t, dir, "-infile", "enwiki-cirrussearch-content.json.gz", "-informat", "cirrus", "-auditfile", "",
+		"-redirectfile", "out/redirects.tsv", "export", "-sink", "jsonl:out/wikitext.jsonl")
+	run(t, dir, "-infile", "enwiki-cirrussearch-content.json.gz", "-informat", "cirrus", "-cirrustext", "-auditfile", "",
+		"export", "-sink", "jsonl:out/text.jsonl")
+	count(t, out("wikitext.jsonl"), 3)
+	expect(t, out("wikitext.jsonl"), `^\{"title":"Apollo 11","page_id":662,"namespace":0,"revision":1001,"text":"Apollo 11 was the first crewed lunar landing\.","categories":\["Apollo program"\]\}$`)
+	expect(t, out("wikitext.jsonl"), `^\{"title":"Category:Apollo program","page_id":700,"namespace":14,`)
+	expect(t, out("text.jsonl"), `"text":"The Moon is Earth's only natural satellite\. The first crewed landing was Apollo 11\.","categories":\[\]\}$`)
+	expect(t, out("redirects.tsv"), `^apollo_xi\tapollo_11$`)
+}
+
+// TestSchema05 reads a dump of schema version 0.5, whose pages have no
+// <ns> and redirects no target in <redirect>, so that namespaces are told
+// by the titles.
+func TestSchema05(t *testing.T) {
+	dir := workDir(t)
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	run(t, dir, "-infile", testdata(t, "minidump-0.5.xml"), "-auditfile", "", "-linkfile", "out/links.tsv", "-redirectfile", "out/redirects.tsv", "links")
+	expect(t, out("links.tsv"), `^moon\tearth\t\t\tarticle\tEarth$`)
+	count(t, out("links.tsv"), 1)
+	expect(t, out("redirects.tsv"), `^luna\tmoon$`)
+}
+
+// TestCaseSensitive reads the titles of a case-sensitive wiki, as its
+// siteinfo tells, which keep their case in the redirect table and the
+// links it resolves.
+func TestCaseSensitive(t *testing.T) {
+	dir := workDir(t)
+	out := func(name string) string { return filepath.Join(dir, "out", name) }
+	wiktionary := testdata(t, "miniwiktionary.xml")
+	run(t, dir, "-infile", wiktionary, "-auditfile", "", "-redirectfile", "out/redirects.tsv", "links")
+	run(t, dir, "-infile", wiktionary, "-auditfile", "", "-resolvefile", "out/redirects.tsv", "-linkfile", "out/links.tsv", "links")
+	expect(t, out("redirects.tsv"), `^apples\tapple$`)
+	expect(t, out("links.tsv"), `^apple\tApple\t\t\tarticle\tApple$`)
+	expect(t, out("links.tsv"), `^apple\tapple\t\t\tarticle\tapples$`)
+	expect(t, out("links.tsv"), `^Apple\tapple\t\t\tarticle\tapple$`)
+}
+
+// TestReproducible runs the same commands with -reproducible in two
+// directories and checks that they write byte-identical outputs, the
+// audit log included.
+func TestReproducible(t *testing.T) {
+	dump := testdata(t, "minidump.xml")
+	outputs := []string{"sample.jsonl", "articles.parquet", "wiki.db", "stats.tsv", "audit.jsonl"}
+	dirs := []string{workDir(t), workDir(t)}
+	for _, dir := range dirs {
+		rep := func(args ...string) {
+			t.Helper()
+			run(t, dir, append([]string{"-infile", dump, "-auditfile", "out/audit.jsonl", "-reproducible"}, args...)...)
+		}
+		rep("export", "-sample", "0.5", "-workers", "4", "-sink", "jsonl:out/sample.jsonl")
+		rep("parquet", "-parquetfile", "out/articles.parquet")
+		rep("sqlite", "-sqlitefile", "out/wiki.db")
+		rep("stats", "-statsfile", "out/stats.tsv")
+	}
+	for _, name := range outputs {
+		same(t, filepath.Join(dirs[0], "out", name), filepath.Join(dirs[1], "out", name))
+	}
+	count(t, filepath.Join(dirs[0], "out", "audit.jsonl"), 4)
+	if data, err := os.ReadFile(filepath.Join(dirs[0], "out", "audit.jsonl")); err == nil && bytes.Contains(data, []byte(`"time"`)) {
+		t.Errorf("the audit log of -reproducible has timestamps")
+	}
+}
+
+// TestCredentials checks that the commands authenticating with a user
+// name fail with status 2 before reading the dump when the password is
+// missing, and that SASL/PLAIN needs TLS.
+func TestCredentials(t *testing.T) {
+	dump := testdata(t, "minidump.xml")
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want string
+	}{
+		{"es password", nil, []string{"elasticsearch", "-esuser", "elastic"},
+			`-esuser: needs a password in WIKI_ES_PASSWORD or WIKI_ES_PASSWORD_FILE`},
+		{"kafka password", []string{"WIKI_KAFKA_PASSWORD_FILE=missing.txt"}, []string{"kafka", "-kafkauser", "producer", "-kafkatls"},
+			`-kafkauser: reading WIKI_KAFKA_PASSWORD_FILE: open missing.txt: no such file or directory`},
+		{"kafka tls", []string{"WIKI_KAFKA_PASSWORD=secret"}, []string{"kafka", "-kafkauser", "producer"},
+			`-kafkauser: needs -kafkatls, as SASL/PLAIN sends the password in the clear`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(wikiparse, append([]string{"-infile", dump, "-auditfile", ""}, tt.args...)...)
+			cmd.Dir = workDir(t)
+			cmd.Env = append(os.Environ(), "WIKI_ES_PASSWORD=", "WIKI_ES_PASSWORD_FILE=", "WIKI_KAFKA_PASSWORD=", "WIKI_KAFKA_PASSWORD_FILE=")
+			cmd.Env = append(cmd.Env, tt.env...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			cmd.Run()
+			if code := cmd.ProcessState.ExitCode(); code != 2 {
+				t.Errorf("wikiparse %s exited with %d, expected 2", strings.Join(tt.args, " "), code)
+			}
+			expectText(t, "the log", stderr.String(), regexp.QuoteMeta(tt.want))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Skipping of unchanged pages by the manifest of the previous run
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+)
+
+var manifestFile = flag.String("manifest", "", "manifest of page revisions: articles unchanged since the run that wrote it are skipped, and it is rewritten for the next run (none if empty)")
+var incremental = flag.Bool("incremental", false, "with -manifest, apply an incremental (adds-changes) dump to the articles of the run that wrote the manifest")
+
+// manifestKey identifies the settings that change the articles written,
+// together with the code version and the digest of the templates
+// expanded, if any.
+func manifestKey(run *audit.Record, templates string) string {
+	return fmt.Sprintf("abstract=%t abstractsentences=%d keepentities=%t maxchars=%d maxwords=%d maxsentences=%d skeleton=%t toc=%t expandtemplates=%t templatedepth=%d strip=%t stripfile=%q match=%q titleprefix=%q titlefile=%q filter=%q templates=%s version=%s",
+		*abstract, *abstractSentences, *keepEntities, *maxChars, *maxWords, *maxSentences, *skeleton, *toc, *expandTemplates, *templateDepth, *strip, *stripFile, *titleFilter, *titlePrefix, *titleFile, *filterExpr, templates, run.Version)
+}
+
+// loadManifest reads the manifest of the previous run. Without one, or if
+// it was written with other settings, nothing is skipped.
+func loadManifest(path string, key string) *dump.Manifest {
+	file, err := os.Open(path)
+	if err != nil {
+		return dump.NewManifest(key)
+	}
+	defer file.Close()
+	m, err := dump.ReadManifest(file)
+	if err != nil {
+		logger.Warn("Ignoring the manifest", "err", err)
+		return dump.NewManifest(key)
+	}
+	if m.Key != key {
+		logger.Warn("Ignoring the manifest, it was written with other settings or code", "manifest", path)
+		return dump.NewManifest(key)
+	}
+	return m
+}
+
+// writeManifest replaces the manifest by the one of this run.
+func writeManifest(path string, m *dump.Manifest) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
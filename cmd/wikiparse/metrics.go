@@ -0,0 +1,56 @@
+// The metrics of a run, served for Prometheus at /metrics: of -addr by
+// the serve command, and of -metricsaddr while the other commands run
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+
+	"github.com/pcmoritz/wikipedia/internal/metrics"
+)
+
+var metricsAddr = flag.String("metricsaddr", "", "serve the metrics of the run for Prometheus at http://`address`/metrics while it runs, like localhost:9100 (not if empty)")
+
+var (
+	runMetrics = metrics.NewRegistry()
+
+	pagesRead         = runMetrics.Counter("wikiparse_pages_read_total", "Pages read from the dump.")
+	dumpBytesRead     = runMetrics.Counter("wikiparse_dump_bytes_read_total", "Bytes of the dump read, decompressed.")
+	articlesParsed    = runMetrics.Counter("wikiparse_articles_parsed_total", "Articles whose wikitext was parsed.")
+	parseErrors       = runMetrics.Counter("wikiparse_parse_errors_total", "Syntax errors found in the wikitext of the articles parsed.")
+	templateDepthHits = runMetrics.Counter("wikiparse_template_depth_exceeded_total", "Transclusions left unexpanded, as they are nested deeper than -templatedepth.")
+	workerQueueDepth  = runMetrics.Gauge("wikiparse_worker_queue_depth", "Pages sent to the -workers whose results are not yet written.")
+	httpRequests      = runMetrics.Counter("wikiparse_http_requests_total", "Requests answered by the serve command.")
+)
+
+// meteredReader counts the bytes of the dump read through it.
+type meteredReader struct {
+	r io.Reader
+}
+
+func (m meteredReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	dumpBytesRead.Add(int64(n))
+	return n, err
+}
+
+// serveMetrics serves the metrics at /metrics of -metricsaddr until the
+// returned function is called.
+func serveMetrics() func() {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", runMetrics)
+	server := &http.Server{Addr: *metricsAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Error serving metrics", "err", err)
+		}
+	}()
+	logger.Info("Serving the metrics", "url", "http://"+*metricsAddr+"/metrics")
+	return func() {
+		server.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,262 @@
+// The pageindex and get commands: an index of the pages of a dump by
+// title and page id, and single pages read from the dump through it
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/pageindex"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	pageIndex = flag.String("pageindex", "out/pages.idx", "index of the pages of the dump written by the pageindex command, read by the get command and to select pages by title")
+	getFormat = flag.String("getformat", "raw", "output `format` of the get command: json, raw (the wikitext), text or html")
+	getByID   = flag.Bool("byid", false, "with the get command, look the pages up by page id instead of title")
+)
+
+// buildPageIndex writes the index of the pages of the dump that
+// isSelected, redirects included, by title and page id with their
+// offsets in the dump, to -pageindex. Redirects are collected into redirects.
+func buildPageIndex(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	index := pageindex.NewBuilder()
+	d := dump.NewReader(r)
+	for offset, p := range d.PageOffsets(ctx) {
+		markRedirect(p)
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isSelected(p) {
+			continue
+		}
+		index.Add(pageindex.Entry{ID: p.ID, Offset: offset, Title: p.Title, Redirect: p.Redir.Title})
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := d.Err(); err != nil {
+		return err
+	}
+	if err := index.Write(*pageIndex); err != nil {
+		return err
+	}
+	run.AddFile(*pageIndex, "pageindex")
+	logger.Info("Totals", "pages_indexed", index.Len())
+	return nil
+}
+
+// readTitleFile returns the canonical titles of the file, one per line.
+// Empty lines and those starting with "#", which no title does, are
+// skipped.
+func readTitleFile(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	titles := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if title := strings.TrimSpace(scanner.Text()); title != "" && !strings.HasPrefix(title, "#") {
+			titles[dump.CanonicalizeTitle(strings.ReplaceAll(title, "_", " "))] = true
+		}
+	}
+	return titles, scanner.Err()
+}
+
+// indexedPages returns the pages of the dump file with the titles
+// selected by -match, -titleprefix and -titlefile, found in the page
+// index -pageindex and read from their offsets, as a dump of them alone.
+// It returns nil to read all of the dump instead: if no titles are
+// selected, there is no index or it is older than the dump, for the
+// pageindex command and for runs with -checkpoint, whose offsets are
+// those of the dump, for Enterprise dumps and pages fetched from the API,
+// which have no index, for dumps mapped with -mmap, whose pages are read
+// from the mapping, and for compressed dumps, which cannot be read at the
+// offsets.
+func indexedPages(file *os.File) io.Reader {
+	if titlePattern == nil && *titlePrefix == "" && selectedTitles == nil ||
+		activeCommand.name == "pageindex" || *checkpointFile != "" || *inputFormat != "xml" || file == nil ||
+		mappedDump != nil || compressedDump(*inputFile) {
+		return nil
+	}
+	info, err := os.Stat(*pageIndex)
+	if err != nil {
+		return nil
+	}
+	if dumpInfo, err := file.Stat(); err != nil || dumpInfo.ModTime().After(info.ModTime()) {
+		logger.Warn("Reading all of the dump, as the page index is older", "pageindex", *pageIndex)
+		return nil
+	}
+	index, err := pageindex.Open(*pageIndex)
+	if err != nil {
+		logger.Warn("Reading all of the dump, as the page index cannot be opened", "err", err)
+		return nil
+	}
+	defer index.Close()
+	offsets := make([]int64, 0, 100)
+	if selectedTitles != nil {
+		// The titles are looked up rather than all of the index read.
+		for title := range selectedTitles {
+			e, ok, err := index.Lookup(title)
+			if err != nil {
+				logger.Warn("Reading all of the dump, as the page index cannot be read", "err", err)
+				return nil
+			}
+			if ok && isSelectedTitle(e.Title) {
+				offsets = append(offsets, e.Offset)
+			}
+		}
+		slices.Sort(offsets)
+	} else {
+		for e, err := range index.Entries() {
+			if err != nil {
+				logger.Warn("Reading all of the dump, as the page index cannot be read", "err", err)
+				return nil
+			}
+			if isSelectedTitle(e.Title) {
+				offsets = append(offsets, e.Offset)
+			}
+		}
+	}
+	logger.Info("Reading the pages selected in the page index", "pages", len(offsets), "pageindex", *pageIndex)
+	return &indexedReader{file: file, offsets: offsets, pending: []byte("<mediawiki>\n")}
+}
+
+// An indexedReader reads the <page> elements at the offsets of a dump
+// file, within a <mediawiki> element.
+type indexedReader struct {
+	file    *os.File
+	offsets []int64
+	pending []byte // what is read next
+	done    bool
+}
+
+func (r *indexedReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		switch {
+		case r.done:
+			return 0, io.EOF
+		case len(r.offsets) == 0:
+			r.pending, r.done = []byte("</mediawiki>\n"), true
+		default:
+			page, err := readPageElement(r.file, r.offsets[0])
+			if err != nil {
+				return 0, err
+			}
+			r.pending, r.offsets = page, r.offsets[1:]
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// readPageElement returns the lines of the dump file from the <page>
+// element at the offset to its end.
+func readPageElement(file *os.File, offset int64) ([]byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(file, offset, math.MaxInt64-offset))
+	page := make([]byte, 0, 4096)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, fmt.Errorf("no end of the page at offset %d", offset)
+		}
+		if len(page) == 0 && !bytes.HasPrefix(line, []byte("<page")) {
+			return nil, fmt.Errorf("no page at offset %d; is %s an index of this dump?", offset, *pageIndex)
+		}
+		page = append(page, line...)
+		if err == nil && bytes.Contains(line, []byte("</page>")) {
+			return page, nil
+		}
+	}
+}
+
+// runGet prints the pages given after "get" by title, or by page id with
+// -byid, read from -infile at their offsets in the index -pageindex, as
+// -getformat. Redirects are followed to their target. It returns the
+// exit status.
+func runGet() int {
+	if len(commandArgs) == 0 {
+		logger.Error("Invalid configuration", "err", `get: expected titles, like get "Albert Einstein"`)
+		return 2
+	}
+	if err := checkChoice("-getformat", *getFormat, articleFormatsServed); err != nil {
+		logger.Error("Invalid configuration", "err", err)
+		return 2
+	}
+	var err error
+	if site, siteInfo, err = loadSite(*inputFile); err != nil {
+		logger.Error("Error reading site information", "err", err)
+		return 1
+	}
+	index, err := pageindex.Open(*pageIndex)
+	if err != nil {
+		logger.Error("Error opening page index", "err", err)
+		return 1
+	}
+	defer index.Close()
+	file, err := os.Open(*inputFile)
+	if err != nil {
+		logger.Error("Error opening file", "err", err)
+		return 1
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	status := 0
+	for _, arg := range commandArgs {
+		var e pageindex.Entry
+		var ok bool
+		if *getByID {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				logger.Error("Invalid configuration", "err", fmt.Sprintf("get: %q is no page id", arg))
+				return 2
+			}
+			if e, ok, err = index.LookupID(id); err == nil && ok && e.Redirect != "" {
+				e, ok, err = index.Resolve(e.Title)
+			}
+		} else {
+			e, ok, err = index.Resolve(strings.ReplaceAll(arg, "_", " "))
+		}
+		if err != nil {
+			logger.Error("Error reading page index", "err", err)
+			return 1
+		}
+		if !ok {
+			logger.Warn("No page", "title", arg)
+			status = 1
+			continue
+		}
+		p, err := dump.ReadPageAt(file, e.Offset)
+		if err == nil && p.ID != e.ID {
+			err = fmt.Errorf("page %d instead of %d at offset %d; is %s an index of this dump?", p.ID, e.ID, e.Offset, *pageIndex)
+		}
+		if err != nil {
+			logger.Error("Error reading the page from the dump", "title", e.Title, "err", err)
+			return 1
+		}
+		doc, _ := wikitext.Parse(p.Text, wikitext.WithSite(site))
+		var b strings.Builder
+		writeArticle(&b, p, doc, *getFormat, strings.TrimRight(*wikiURL, "/")+"/wiki/")
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		w.WriteString(b.String())
+	}
+	return status
+}
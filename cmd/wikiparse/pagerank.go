@@ -0,0 +1,116 @@
+// The pagerank command: the PageRank and the link degrees of the articles
+// in a dump, for ranking them by relevance
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/pagerank"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	pageRankFile       = flag.String("pagerankfile", "", "output file for the pagerank command (stdout if empty)")
+	damping            = flag.Float64("damping", 0.85, "probability of following a link rather than jumping to a random article, for the pagerank command")
+	pageRankIterations = flag.Int("pagerankiterations", 100, "most iterations of the pagerank command")
+	pageRankTolerance  = flag.Float64("pageranktolerance", 1e-9, "change of the ranks between iterations, summed over all articles, below which the pagerank command stops")
+)
+
+// computePageRank writes the PageRank of every article over the links
+// between articles, with the links into and out of it, as lines
+// "article, pagerank, in, out" by descending rank. Links to redirects
+// count for their target, as resolved by the redirects of the dump, and
+// several links of an article to the same page as one. The links are
+// kept in a temporary file by pagerank.Graph, so that only the titles and
+// a few numbers per article are held in memory. Redirects are collected
+// into redirects.
+func computePageRank(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	// Titles get ids as they are seen, as the source or the target of a
+	// link, and are mapped to the articles once all are known.
+	ids := make(map[string]int32)
+	titles := make([]string, 0, 1024)
+	article := make([]bool, 0, 1024)
+	id := func(title string) int32 {
+		i, ok := ids[title]
+		if !ok {
+			i = int32(len(titles))
+			ids[title] = i
+			titles, article = append(titles, title), append(article, false)
+		}
+		return i
+	}
+	graph := pagerank.NewGraph("")
+	defer graph.Close()
+	targets := make(map[int32]bool)
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		source := id(dump.CanonicalizeTitle(p.Title))
+		article[source] = true
+		clear(targets)
+		for _, link := range wikitext.Links(doc) {
+			if link.Class != wikitext.LinkArticle || link.Target == "" || link.Interwiki != "" {
+				continue
+			}
+			target := id(dump.CanonicalizeTitle(link.Target))
+			if !targets[target] {
+				targets[target] = true
+				graph.AddEdge(int(source), int(target))
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// The articles are the nodes of the graph, and the other titles map to
+	// the article they redirect to, if any.
+	node := make([]int32, len(titles))
+	articles := make([]string, 0, len(titles))
+	for i, title := range titles {
+		node[i] = -1
+		if article[i] {
+			node[i] = int32(len(articles))
+			articles = append(articles, title)
+		}
+	}
+	for i, title := range titles {
+		if j, ok := ids[redirects.ResolveRedirect(title)]; !article[i] && ok && article[j] {
+			node[i] = node[j]
+		}
+	}
+	res, err := graph.Rank(node, len(articles), pagerank.Options{Damping: *damping, Iterations: *pageRankIterations, Tolerance: *pageRankTolerance})
+	if err != nil {
+		return err
+	}
+
+	order := make([]int, len(articles))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Or(cmp.Compare(res.Rank[b], res.Rank[a]), cmp.Compare(articles[a], articles[b]))
+	})
+	writer, finish, err := openOutput(*pageRankFile, "pagerank", run)
+	if err != nil {
+		return err
+	}
+	for _, i := range order {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\n", articles[i], strconv.FormatFloat(res.Rank[i], 'g', 6, 64), res.In[i], res.Out[i])
+	}
+	err = finish()
+	logger.Info("Totals", "articles", len(articles), "links", graph.Edges(), "iterations", res.Iterations, "converged", res.Converged)
+	return err
+}
@@ -0,0 +1,46 @@
+// The store of the items lexed from the articles, which later runs read
+// instead of lexing the articles again
+
+package main
+
+import (
+	"flag"
+	"sync/atomic"
+
+	"github.com/pcmoritz/wikipedia/internal/pagestore"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var pageStoreFile = flag.String("pagestore", "", "file keeping the items lexed from the articles, with an index in the file with .idx appended, which later runs read instead of lexing the articles again (none if empty)")
+
+// pageStore is the store of -pagestore, opened for the run.
+var pageStore *pagestore.Store
+
+// The articles whose items were read from the page store, and those that
+// were lexed and added to it.
+var storedArticles, lexedArticles atomic.Int64
+
+// parseArticle parses the wikitext of the article with the title with
+// the site. With -pagestore, the items are read from the store if it has
+// them for the text, and added to it otherwise.
+func parseArticle(title string, text string) *wikitext.Document {
+	if pageStore != nil {
+		if items, ok, err := pageStore.Get(title, text); err == nil && ok {
+			storedArticles.Add(1)
+			doc, _ := wikitext.Parse(text, wikitext.WithSite(site), wikitext.WithItems(items))
+			articlesParsed.Inc()
+			parseErrors.Add(int64(len(doc.Errors)))
+			return doc
+		}
+	}
+	doc, _ := wikitext.Parse(text, wikitext.WithSite(site))
+	articlesParsed.Inc()
+	parseErrors.Add(int64(len(doc.Errors)))
+	if pageStore != nil {
+		// The first error adding items is returned when the store is
+		// closed.
+		pageStore.Put(title, text, doc.Items)
+		lexedArticles.Add(1)
+	}
+	return doc
+}
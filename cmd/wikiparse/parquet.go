@@ -0,0 +1,78 @@
+// The parquet command: a Parquet file of the articles in a dump with
+// their links and categories, for analytics tools
+
+package main
+
+import (
+	"flag"
+	"io"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/parquet"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var parquetFile = flag.String("parquetfile", "out/articles.parquet", "output file for the parquet command")
+
+// The columns of the file, documented in the README.
+var parquetColumns = []parquet.Column{
+	{Name: "page_id", Kind: parquet.Int64},
+	{Name: "namespace", Kind: parquet.Int32},
+	{Name: "title", Kind: parquet.String},
+	{Name: "revision", Kind: parquet.Int64},
+	{Name: "text", Kind: parquet.String},
+	{Name: "links", Kind: parquet.StringList},
+	{Name: "categories", Kind: parquet.StringList},
+}
+
+// writeParquet writes a row for every article of the dump to the file at
+// -parquetfile, with its plain text, the canonical targets of its links
+// of the classes given by -linkclasses and the scope given by -linkscope,
+// and its categories. Redirects are collected into redirects.
+func writeParquet(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	classes, err := parseLinkClasses(*linkClasses)
+	if err != nil {
+		return err
+	}
+	out, err := parquet.Create(*parquetFile, parquetColumns)
+	if err != nil {
+		return err
+	}
+	out.SetMetadata("schema_version", strconv.Itoa(schema.Version))
+
+	// Write errors are sticky in the writer and returned by Close.
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := siteInfo.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		links := make([]string, 0, 10)
+		for _, link := range wikitext.Links(doc) {
+			if classes[link.Class] && inLinkScope(link) {
+				links = append(links, linkTarget(title, link, nil))
+			}
+		}
+		categories := make([]string, 0, 4)
+		for _, c := range wikitext.Categories(doc) {
+			categories = append(categories, c.Name)
+		}
+		if err := out.Write(p.ID, p.Namespace, p.Title, p.RevisionID, wikitext.PlainText(doc), links, categories); err != nil {
+			out.Close()
+			return err
+		}
+		total++
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	run.AddFile(*parquetFile, "parquet")
+	logger.Info("Totals", "articles", total)
+	return nil
+}
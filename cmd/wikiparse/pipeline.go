@@ -0,0 +1,46 @@
+// The processors of -pipelinefile, run on every article parsed in the
+// -workers goroutines
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/processor"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var pipelineFile = flag.String("pipelinefile", "", "YAML `file` listing the processors run on every article parsed, which may change or drop it (none if empty; processors: "+strings.Join(processor.Names(), ", ")+")")
+
+// processors is the chain of -pipelinefile, empty without one.
+var processors processor.Chain
+
+var articlesDropped = runMetrics.Counter("wikiparse_articles_dropped_total", "Articles dropped by the processors of -pipelinefile.")
+
+// readPipeline returns the chain of processors of the pipeline file.
+func readPipeline(path string) (processor.Chain, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return processor.ReadPipeline(f)
+}
+
+// process runs the processors on the article, reporting whether it is
+// kept. Articles a processor fails on are logged and dropped.
+func process(p *dump.Page, doc *wikitext.Document) bool {
+	err := processors.Run(p, doc)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, processor.ErrSkip) {
+		pageLogger(p).Error("Error processing article", "err", err)
+	}
+	articlesDropped.Inc()
+	return false
+}
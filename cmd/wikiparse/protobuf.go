@@ -0,0 +1,177 @@
+// The protobuf and grpc commands: the parsed articles of a dump as
+// messages of proto/wikipedia.proto, in a file or streamed over gRPC
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/protobuf"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	protoFile = flag.String("protofile", "out/articles.pb", "output file for the protobuf command, - for stdout")
+	grpcAddr  = flag.String("grpcaddr", "localhost:50051", "`address` the grpc command listens on")
+)
+
+// streamArticlesMethod is the method of the Dump service of the schema.
+const streamArticlesMethod = "/wikipedia.v1.Dump/StreamArticles"
+
+// articleMessage returns the Article message of the page and its
+// document.
+func articleMessage(p *dump.Page, doc *wikitext.Document) *protobuf.Message {
+	var m, sub protobuf.Message
+	m.Int64(1, p.ID)
+	m.String(2, p.Title)
+	m.Int64(3, int64(p.Namespace))
+	m.Int64(4, p.RevisionID)
+	m.String(5, wikitext.PlainText(doc))
+	var visit func(sections []wikitext.Section)
+	visit = func(sections []wikitext.Section) {
+		for _, s := range sections {
+			if s.Heading != "" {
+				sub.Reset()
+				sub.String(1, strings.Join(strings.Fields(s.Heading), " "))
+				sub.Int64(2, int64(s.Level))
+				sub.String(3, s.Anchor)
+				m.Message(6, &sub)
+			}
+			visit(s.Children)
+		}
+	}
+	visit(wikitext.Sections(doc))
+	for _, l := range wikitext.Links(doc) {
+		sub.Reset()
+		sub.String(1, l.Target)
+		sub.String(2, l.Section)
+		sub.String(3, l.Interwiki)
+		sub.String(4, l.Class.String())
+		sub.String(5, l.Anchor)
+		m.Message(7, &sub)
+	}
+	for _, c := range wikitext.Categories(doc) {
+		m.String(8, c.Name)
+	}
+	var param protobuf.Message
+	for _, t := range wikitext.Templates(doc, 1) {
+		sub.Reset()
+		sub.String(1, t.Name)
+		for _, p := range t.Params {
+			param.Reset()
+			param.String(1, p.Name)
+			param.String(2, p.Value.Text)
+			sub.Message(2, &param)
+		}
+		m.Message(9, &sub)
+	}
+	m.Int64(10, schema.Version)
+	return &m
+}
+
+// writeProtobuf writes an Article message for every article of the dump
+// to -protofile, each prefixed with its length as a varint. Redirects are
+// collected into redirects.
+func writeProtobuf(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	path := *protoFile
+	if path == "-" {
+		path = ""
+	}
+	writer, finish, err := openOutput(path, "protobuf", run)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		protobuf.WriteDelimited(writer, articleMessage(p, doc))
+		total++
+	}
+	err = finish()
+	logger.Info("Totals", "articles", total)
+	return err
+}
+
+// streamArticles answers a StreamArticles call with the articles of
+// -infile whose titles match the match of the request, up to its limit.
+// Every call reads the dump anew.
+func streamArticles(ctx context.Context, request []byte, send func(*protobuf.Message) error) error {
+	fields, err := protobuf.Fields(request)
+	if err != nil {
+		return protobuf.Errorf(protobuf.CodeInvalidArgument, "%v", err)
+	}
+	var match *regexp.Regexp
+	limit := int64(-1)
+	for _, f := range fields {
+		switch f.Number {
+		case 1:
+			if match, err = regexp.Compile(string(f.Bytes)); err != nil {
+				return protobuf.Errorf(protobuf.CodeInvalidArgument, "match: %v", err)
+			}
+		case 2:
+			limit = int64(f.Varint)
+		}
+	}
+	file, err := os.Open(*inputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	pages := inParallel(dumpPages(file), func(_ int, p *dump.Page) *protobuf.Message {
+		if !isArticle(p) || match != nil && !match.MatchString(p.Title) {
+			return nil
+		}
+		doc, _ := wikitext.Parse(p.Text, wikitext.WithSite(site))
+		return articleMessage(p, doc)
+	})
+	for _, m := range pages {
+		if limit == 0 {
+			break
+		}
+		if m == nil {
+			continue
+		}
+		if err := send(m); err != nil {
+			return err
+		}
+		limit--
+	}
+	return nil
+}
+
+// runGRPC serves the StreamArticles method of the schema on -grpcaddr
+// until it is interrupted, which ends the calls in progress. It returns
+// the exit status.
+func runGRPC() int {
+	server := protobuf.NewServer()
+	server.Handle(streamArticlesMethod, streamArticles)
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	s := &http.Server{
+		Addr:        *grpcAddr,
+		Handler:     server,
+		Protocols:   protocols,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	logger.Info("Streaming the articles", "infile", *inputFile, "addr", *grpcAddr, "method", streamArticlesMethod)
+	if *metricsAddr != "" {
+		stop := serveMetrics()
+		defer stop()
+	}
+	return serveUntilInterrupted(s)
+}
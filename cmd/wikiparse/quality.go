@@ -0,0 +1,62 @@
+// The quality command: the features of the articles in a dump that tell
+// substantive articles from stubs
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var qualityFile = flag.String("qualityfile", "", "output file for the quality command (stdout if empty)")
+var qualityFormat = flag.String("qualityformat", "tsv", "quality output `format`: tsv or csv")
+
+var qualityFormats = []string{"tsv", "csv"}
+
+// extractQuality writes the quality features of every article in the
+// dump as wikitext.Assess finds them, as rows
+// "article, is_stub, references, sections, infobox, length" in the
+// format given by -qualityformat. Redirects are collected into redirects.
+func extractQuality(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*qualityFile, "quality-"+*qualityFormat, run)
+	if err != nil {
+		return err
+	}
+	// Write errors are sticky in the csv writer too.
+	csvWriter := csv.NewWriter(writer)
+	total, stubs := 0, 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		q := wikitext.Assess(doc)
+		row := []string{dump.CanonicalizeTitle(p.Title), strconv.FormatBool(q.Stub), strconv.Itoa(q.References),
+			strconv.Itoa(q.Sections), strconv.FormatBool(q.Infobox), strconv.Itoa(q.Length)}
+		if *qualityFormat == "csv" {
+			csvWriter.Write(row)
+		} else {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", row[0], row[1], row[2], row[3], row[4], row[5])
+		}
+		total++
+		if q.Stub {
+			stubs++
+		}
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "articles", total, "stubs", stubs)
+	return err
+}
@@ -0,0 +1,43 @@
+// Reproducibility mode: two runs over the same dump with the same
+// configuration produce byte-identical outputs, including the audit log.
+
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+)
+
+var reproducible = flag.Bool("reproducible", false, "make the outputs of identical runs byte-identical (no timestamps, fixed random seeds)")
+var seed = flag.Int64("seed", 0, "seed of the random choices of the run, like the pages of -sample, so that runs with the same one make the same (one of the current time if 0, unless -reproducible)")
+
+// The seed used for all randomness in reproducible mode.
+const reproducibleSeed = 1
+
+// randomSeed returns the seed of the randomness of the run: that of
+// -seed, the fixed one in reproducible mode, or one of the current time.
+func randomSeed() int64 {
+	switch {
+	case *seed != 0:
+		return *seed
+	case *reproducible:
+		return reproducibleSeed
+	}
+	return time.Now().UnixNano()
+}
+
+// newRand returns the source of randomness for sampling and similar
+// decisions. Everything random in a run must be drawn from it.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(randomSeed()))
+}
+
+// timestamp returns the current time for recording in outputs, or an
+// empty string in reproducible mode.
+func timestamp() string {
+	if *reproducible {
+		return ""
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,61 @@
+// Subsets of a dump for experiments: a random sample of its pages with
+// -sample and its first articles with -limit
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"flag"
+	"iter"
+
+	"github.com/pcmoritz/wikipedia/dump"
+)
+
+var sampleRate = flag.Float64("sample", 0, "process only a random share of the pages from 0 to 1, like 0.01, chosen by their title and -seed, so that a seed chooses the same pages of every dump and command (all if 0)")
+var articleLimit = flag.Int("limit", 0, "stop reading the dump after `n` articles, those of -sample if given (all if 0)")
+
+// sampleSalt is hashed with the titles of the pages to choose those of
+// -sample, drawn from newRand.
+var sampleSalt uint64
+
+// sampled reports whether the page of the title is in the sample of
+// -sample: whether the SHA-256 of sampleSalt and the title, as a number
+// from 0 to 1, is below it. Every bit of the hash depends on every byte of
+// the title, so titles sharing a prefix are not chosen together.
+func sampled(title string) bool {
+	if *sampleRate == 0 {
+		return true
+	}
+	h := sha256.New()
+	h.Write(binary.LittleEndian.AppendUint64(nil, sampleSalt))
+	h.Write([]byte(dump.CanonicalizeTitle(title)))
+	return float64(binary.BigEndian.Uint64(h.Sum(nil))>>11)/(1<<53) < *sampleRate
+}
+
+// stoppedAtLimit is set once the pages read stop at the article of
+// -limit, before the end of the dump.
+var stoppedAtLimit bool
+
+// limitArticles returns the pages up to the article of -limit, all of
+// them without it. The pages after it are not read.
+func limitArticles(pages iter.Seq[*dump.Page]) iter.Seq[*dump.Page] {
+	if *articleLimit == 0 {
+		return pages
+	}
+	return func(yield func(*dump.Page) bool) {
+		n := 0
+		for p := range pages {
+			if !yield(p) {
+				return
+			}
+			if isArticle(p) {
+				if n++; n == *articleLimit {
+					logger.Info("Stopped at the limit of articles", "limit", n)
+					stoppedAtLimit = true
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// The index and search commands: an inverted index of the articles in a
+// dump, and ranked search in it
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/search"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var searchIndex = flag.String("searchindex", "out/search.idx", "index file written by the index command and read by the search command")
+var searchResults = flag.Int("searchresults", 10, "number of results of the search and serve commands")
+var searchFormat = flag.String("searchformat", "text", "search results output `format`: text or json")
+
+var searchFormats = []string{"text", "json"}
+
+// A searchResult is an article found as -searchformat json and the serve
+// command write it.
+type searchResult struct {
+	Rank    int     `json:"rank"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchResultsJSON returns the results in the form written as JSON.
+func searchResultsJSON(results []search.Result) []searchResult {
+	found := make([]searchResult, len(results))
+	for i, r := range results {
+		found[i] = searchResult{i + 1, r.Title, r.Score, r.Snippet}
+	}
+	return found
+}
+
+// buildSearchIndex writes an inverted index of the titles and plain text
+// of all articles of the dump to -searchindex. Redirects are collected
+// into redirects.
+func buildSearchIndex(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	index := search.NewBuilder()
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		index.Add(p.Title, wikitext.PlainText(doc))
+	}
+	if err := index.Write(*searchIndex); err != nil {
+		return err
+	}
+	run.AddFile(*searchIndex, "searchindex")
+	logger.Info("Totals", "articles_indexed", index.Len())
+	return nil
+}
+
+// runSearch searches the index -searchindex for the query given after
+// "search" and prints the best -searchresults articles with snippets, as
+// text or with -searchformat json as a JSON array. It returns the exit
+// status.
+func runSearch() int {
+	if len(commandArgs) != 1 {
+		logger.Error("Invalid configuration", "err", `search: expected one query, like search "apollo moon landing"`)
+		return 2
+	}
+	if *searchResults < 1 {
+		logger.Error("Invalid configuration", "err", "-searchresults: must be at least 1")
+		return 2
+	}
+	if err := checkChoice("-searchformat", *searchFormat, searchFormats); err != nil {
+		logger.Error("Invalid configuration", "err", err)
+		return 2
+	}
+	index, err := search.Open(*searchIndex)
+	if err != nil {
+		logger.Error("Error opening index", "err", err)
+		return 1
+	}
+	defer index.Close()
+	results, err := index.Search(commandArgs[0], *searchResults)
+	if err != nil {
+		logger.Error("Error searching", "err", err)
+		return 1
+	}
+	if *searchFormat == "json" {
+		json.NewEncoder(os.Stdout).Encode(searchResultsJSON(results))
+	} else {
+		for i, r := range results {
+			fmt.Printf("%d. %s (%.2f)\n   %s\n", i+1, r.Title, r.Score, r.Snippet)
+		}
+	}
+	if len(results) == 0 {
+		logger.Info("No articles found", "query", commandArgs[0])
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+// The sections command: extraction of the section headings of the
+// articles in a dump, with the anchors and permalinks MediaWiki uses
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var sectionFile = flag.String("sectionfile", "", "output file for the sections command (stdout if empty)")
+var wikiURL = flag.String("wikiurl", "https://en.wikipedia.org", "base URL of the wiki the dump is from, for permalinks")
+
+// extractSections writes the sections of every article in the dump, in
+// document order, as lines "article\tlevel\theading\tanchor\tpermalink".
+// The permalink points to the section in the revision of the dump.
+// Redirects are collected into redirects.
+func extractSections(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*sectionFile, "sections", run)
+	if err != nil {
+		return err
+	}
+	total := 0
+	var visit func(p *dump.Page, title string, sections []wikitext.Section)
+	visit = func(p *dump.Page, title string, sections []wikitext.Section) {
+		for _, s := range sections {
+			if s.Heading != "" {
+				heading := strings.Join(strings.Fields(s.Heading), " ")
+				fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\n", title, s.Level, heading, s.Anchor, p.Permalink(*wikiURL, s.Anchor))
+				total++
+			}
+			visit(p, title, s.Children)
+		}
+	}
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		visit(p, title, wikitext.Sections(doc))
+	}
+	err = finish()
+	logger.Info("Totals", "sections", total)
+	return err
+}
@@ -0,0 +1,47 @@
+// The sentences command: the plain text of the articles in a dump, one
+// sentence per line, for NLP corpora
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var sentenceFile = flag.String("sentencefile", "", "output file for the sentences command (stdout if empty)")
+
+// extractSentences writes the sentences of the plain text of every
+// article in the dump, as wikitext.Sentences splits it, as lines
+// "article\tsection\tparagraph\tsentence" in document order, with the
+// anchor of the section, empty in the lead. Redirects are collected into
+// redirects.
+func extractSentences(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	writer, finish, err := openOutput(*sentenceFile, "sentences", run)
+	if err != nil {
+		return err
+	}
+	// Paragraphs have their white space normalized, so that sentences
+	// have no tabs or newlines.
+	total := 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		for _, s := range wikitext.Sentences(doc) {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", title, s.Section, s.Paragraph, s.Text)
+			total++
+		}
+	}
+	err = finish()
+	logger.Info("Totals", "sentences", total)
+	return err
+}
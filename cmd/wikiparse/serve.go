@@ -0,0 +1,317 @@
+// The serve command: the articles of a dump, their links and the search
+// index over HTTP
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/search"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var serveAddr = flag.String("addr", "localhost:8080", "`address` the serve command listens on")
+
+// articleFormatsServed are the formats of /article, by the format
+// parameter.
+var articleFormatsServed = []string{"json", "raw", "text", "html"}
+
+// shutdownTimeout bounds the time the requests in progress are given to
+// finish once the server is interrupted.
+const shutdownTimeout = 5 * time.Second
+
+// A pageTable finds the pages of a dump file by their titles, to read
+// them again from the file when they are asked for.
+type pageTable struct {
+	file      *os.File
+	articles  map[string]pageEntry // by canonical title
+	redirects *dump.RedirectTable
+}
+
+// A pageEntry is where an article is in the dump file.
+type pageEntry struct {
+	title  string
+	offset int64
+}
+
+// loadPageTable reads the dump file at path and returns the table of its
+// articles and redirects.
+func loadPageTable(path string) (*pageTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &pageTable{file: file, articles: make(map[string]pageEntry), redirects: dump.NewRedirectTable()}
+	d := dump.NewReader(file)
+	for offset, p := range d.PageOffsets(ctx) {
+		pagesRead.Inc()
+		markRedirect(p)
+		if p.Redir.Title != "" {
+			t.redirects.Add(p.Title, p.Redir.Title)
+		}
+		if isArticle(p) {
+			t.articles[dump.CanonicalizeTitle(p.Title)] = pageEntry{p.Title, offset}
+		}
+	}
+	if err := cmp.Or(ctx.Err(), d.Err()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// lookup returns the article with the title, or the title of the article
+// it redirects to if it is a redirect; neither if there is no such page.
+func (t *pageTable) lookup(title string) (*dump.Page, string, error) {
+	title = dump.CanonicalizeTitle(strings.ReplaceAll(title, "_", " "))
+	if a, ok := t.articles[title]; ok {
+		p, err := dump.ReadPageAt(t.file, a.offset)
+		return p, "", err
+	}
+	if a, ok := t.articles[t.redirects.ResolveRedirect(title)]; ok {
+		return nil, a.title, nil
+	}
+	return nil, "", nil
+}
+
+// servedArticle is the JSON of an article at /article.
+type servedArticle struct {
+	ID         int64           `json:"id"`
+	Title      string          `json:"title"`
+	Revision   int64           `json:"revision"`
+	Wikitext   string          `json:"wikitext"`
+	Text       string          `json:"text"`
+	Sections   []servedSection `json:"sections"`
+	Categories []string        `json:"categories"`
+}
+
+type servedSection struct {
+	Heading string `json:"heading"`
+	Level   int    `json:"level"`
+	Anchor  string `json:"anchor"`
+}
+
+// servedLink is the JSON of a link at /links.
+type servedLink struct {
+	Target    string `json:"target"`
+	Section   string `json:"section,omitempty"`
+	Interwiki string `json:"interwiki,omitempty"`
+	Class     string `json:"class"`
+	Anchor    string `json:"anchor"`
+}
+
+// articleJSON returns the article with the plain text, headings and
+// categories of its document.
+func articleJSON(p *dump.Page, doc *wikitext.Document) servedArticle {
+	a := servedArticle{ID: p.ID, Title: p.Title, Revision: p.RevisionID, Wikitext: p.Text, Text: wikitext.PlainText(doc),
+		Sections: make([]servedSection, 0, 10), Categories: make([]string, 0, 4)}
+	var visit func(sections []wikitext.Section)
+	visit = func(sections []wikitext.Section) {
+		for _, s := range sections {
+			if s.Heading != "" {
+				a.Sections = append(a.Sections, servedSection{strings.Join(strings.Fields(s.Heading), " "), s.Level, s.Anchor})
+			}
+			visit(s.Children)
+		}
+	}
+	visit(wikitext.Sections(doc))
+	for _, c := range wikitext.Categories(doc) {
+		a.Categories = append(a.Categories, c.Name)
+	}
+	return a
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// pageHandler answers requests for the article with the title in the
+// path, as /article/Apollo_11 or /article/AC/DC, by calling serve with
+// its page and parsed document. Requests for redirects are redirected to
+// the path of their target.
+func pageHandler(pages *pageTable, serve func(w http.ResponseWriter, r *http.Request, p *dump.Page, doc *wikitext.Document)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, target, err := pages.lookup(r.PathValue("title"))
+		switch {
+		case err != nil:
+			http.Error(w, "error reading the dump: "+err.Error(), http.StatusInternalServerError)
+		case target != "":
+			u := *r.URL
+			u.Path = strings.TrimSuffix(r.URL.Path, r.PathValue("title")) + strings.ReplaceAll(target, " ", "_")
+			u.RawPath = ""
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		case p == nil:
+			http.Error(w, fmt.Sprintf("no article %q", r.PathValue("title")), http.StatusNotFound)
+		default:
+			doc, _ := wikitext.Parse(p.Text, wikitext.WithSite(site))
+			serve(w, r, p, doc)
+		}
+	})
+}
+
+// articleContentTypes are the content types of the formats of articles.
+var articleContentTypes = map[string]string{
+	"json": "application/json",
+	"raw":  "text/plain; charset=utf-8",
+	"text": "text/plain; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+}
+
+// writeArticle writes the article in the format, one of
+// articleFormatsServed: JSON, the wikitext, the plain text or a page of
+// HTML whose links are base followed by their target.
+func writeArticle(w io.Writer, p *dump.Page, doc *wikitext.Document, format string, base string) {
+	switch format {
+	case "json":
+		json.NewEncoder(w).Encode(articleJSON(p, doc))
+	case "raw":
+		io.WriteString(w, p.Text)
+	case "text":
+		io.WriteString(w, wikitext.PlainText(doc))
+	case "html":
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<h1>%[1]s</h1>\n", html.EscapeString(p.Title))
+		io.WriteString(w, wikitext.HTML(doc, base))
+	}
+}
+
+// serveArticle writes the article in the format of the format parameter,
+// JSON by default, with the links of HTML pointing to the articles at
+// /article.
+func serveArticle(w http.ResponseWriter, r *http.Request, p *dump.Page, doc *wikitext.Document) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if err := checkChoice("format", format, articleFormatsServed); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", articleContentTypes[format])
+	writeArticle(w, p, doc, format, "/article/")
+}
+
+// serveLinks writes the links of the article as a JSON array.
+func serveLinks(w http.ResponseWriter, r *http.Request, p *dump.Page, doc *wikitext.Document) {
+	links := make([]servedLink, 0, 100)
+	for _, l := range wikitext.Links(doc) {
+		links = append(links, servedLink{l.Target, l.Section, l.Interwiki, l.Class.String(), l.Anchor})
+	}
+	writeJSON(w, links)
+}
+
+// searchHandler answers requests like /search?q=apollo+moon&n=5 with the
+// best n articles of the index for the query, at most -searchresults, as
+// a JSON array like that of -searchformat json.
+func searchHandler(index *search.Index) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		if query == "" {
+			http.Error(w, "missing query, like /search?q=apollo", http.StatusBadRequest)
+			return
+		}
+		n := *searchResults
+		if value := r.URL.Query().Get("n"); value != "" {
+			m, err := strconv.Atoi(value)
+			if err != nil || m < 1 {
+				http.Error(w, fmt.Sprintf("invalid number of results %q", value), http.StatusBadRequest)
+				return
+			}
+			n = min(m, n)
+		}
+		results, err := index.Search(query, n)
+		if err != nil {
+			http.Error(w, "error searching: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, searchResultsJSON(results))
+	})
+}
+
+// openSearchIndex opens the index -searchindex, building it from the dump
+// first if there is none.
+func openSearchIndex() (*search.Index, error) {
+	if _, err := os.Stat(*searchIndex); errors.Is(err, os.ErrNotExist) {
+		file, err := os.Open(*inputFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		run := audit.New("index", flag.CommandLine, timestamp())
+		if err := buildSearchIndex(file, dump.NewRedirectTable(), run); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			// The index of part of the dump is not kept for the next run.
+			os.Remove(*searchIndex)
+			return nil, err
+		}
+	}
+	return search.Open(*searchIndex)
+}
+
+// runServe serves the articles of -infile at /article/{title} and their
+// links at /links/{title}, and searches in the index -searchindex at
+// /search, on -addr until it is interrupted, with the metrics of the run
+// at /metrics. It returns the exit status.
+func runServe() int {
+	index, err := openSearchIndex()
+	if err != nil {
+		logger.Error("Error opening index", "err", err)
+		return 1
+	}
+	defer index.Close()
+	pages, err := loadPageTable(*inputFile)
+	if err != nil {
+		logger.Error("Error reading the dump", "err", err)
+		return 1
+	}
+	defer pages.file.Close()
+	mux := http.NewServeMux()
+	mux.Handle("GET /article/{title...}", pageHandler(pages, serveArticle))
+	mux.Handle("GET /links/{title...}", pageHandler(pages, serveLinks))
+	mux.Handle("GET /search", searchHandler(index))
+	mux.Handle("GET /metrics", runMetrics)
+	logger.Info("Serving the articles", "articles", len(pages.articles), "url", "http://"+*serveAddr+"/article/")
+	counted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpRequests.Inc()
+		mux.ServeHTTP(w, r)
+	})
+	return serveUntilInterrupted(&http.Server{Addr: *serveAddr, Handler: counted})
+}
+
+// serveUntilInterrupted runs the server until it fails or the run is
+// interrupted, then gives the requests in progress shutdownTimeout to
+// finish. It returns the exit status.
+func serveUntilInterrupted(server *http.Server) int {
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
+	select {
+	case err := <-served:
+		logger.Error("Error serving", "err", err)
+		return 1
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Error stopping the server", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+// The conventions of the wiki of the dump, like the names of its
+// namespaces
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var siteFile = flag.String("sitefile", "", "JSON file with namespace names, aliases, image options and redirect aliases of the wiki, added to those of the dump's siteinfo (none if empty)")
+
+// site is the site of the dump, loaded by loadSite.
+var site = wikitext.NewSite()
+
+// siteInfo is the siteinfo of the dump, loaded by loadSite, whose case
+// rules canonicalize the titles of the redirect tables and of the links
+// they resolve.
+var siteInfo = &dump.SiteInfo{}
+
+// Namespaces whose pages are no articles, besides redirects. Categories
+// and templates are kept for the category hierarchy and expansion.
+var nonArticleNamespaces = map[int]bool{
+	wikitext.NamespaceSpecial: true,
+	1:                         true, // Talk
+	2:                         true, // User
+	3:                         true, // User talk
+	4:                         true, // Project, like Wikipedia:
+	wikitext.NamespaceFile:    true,
+}
+
+// warnSchema warns on stderr if the dump is of a schema version newer or
+// older than those known, which is read as if it were one of them.
+func warnSchema(info *dump.SiteInfo) {
+	if info.SchemaVersion != "" && !dump.KnownSchema(info.SchemaVersion) {
+		logger.Warn("Reading a dump of an unknown schema version", "schema", info.SchemaVersion,
+			"oldest_known", dump.OldestSchema, "newest_known", dump.NewestSchema)
+	}
+}
+
+// loadSite reads the siteinfo of the dump at path and returns it with the
+// site of the names of its namespaces and those of -sitefile. Enterprise
+// dumps have no siteinfo, so their site is that of the English Wikipedia
+// but for -sitefile. With -apiurl, the siteinfo is that of an export of
+// the API.
+func loadSite(path string) (*wikitext.Site, *dump.SiteInfo, error) {
+	s, info := wikitext.NewSite(), &dump.SiteInfo{}
+	switch {
+	case *apiURL != "":
+		export, err := wikiAPI().Export(ctx, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info, err = dump.ReadSiteInfo(bytes.NewReader(export)); err != nil {
+			return nil, nil, err
+		}
+		warnSchema(info)
+		s = info.Site()
+	case *inputFormat == "xml":
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		if info, err = dump.ReadSiteInfo(dumpReader(path, file)); err != nil {
+			return nil, nil, err
+		}
+		warnSchema(info)
+		s = info.Site()
+	}
+	if *siteFile != "" {
+		config, err := os.Open(*siteFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer config.Close()
+		if err := s.ReadJSON(config); err != nil {
+			return nil, nil, err
+		}
+	}
+	return s, info, nil
+}
+
+// markRedirect records the target of a page that redirects by an alias
+// of #REDIRECT of -sitefile, which the dump package does not know, like
+// those the dump gives.
+func markRedirect(p *dump.Page) {
+	if aliases := site.RedirectAliases(); p.Redir.Title == "" && len(aliases) > 0 {
+		if target, ok := dump.RedirectTarget(p.Text, aliases); ok {
+			p.Redir.Title = target
+		}
+	}
+}
+
+// newRedirectTable returns an empty redirect table canonicalizing titles
+// by the case rules of the dump's wiki.
+func newRedirectTable() *dump.RedirectTable {
+	t := dump.NewRedirectTable()
+	t.UseSite(siteInfo)
+	return t
+}
@@ -0,0 +1,23 @@
+// The sqlite command: a SQLite database of the articles in a dump with
+// their sections, links, categories and templates
+
+package main
+
+import (
+	"flag"
+	"io"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+)
+
+var sqliteFile = flag.String("sqlitefile", "out/wiki.db", "database output file for the sqlite command")
+
+// writeSQLite writes the articles of the dump to the database at
+// -sqlitefile with the sqlite sink, with their plain text, sections,
+// links, categories and templates in the tables of sink.SQLiteTables,
+// documented in the README. Redirects are collected into redirects and
+// written to the database too.
+func writeSQLite(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	return writeSinks(r, redirects, run, []string{"sqlite:" + *sqliteFile})
+}
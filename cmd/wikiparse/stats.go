@@ -0,0 +1,94 @@
+// The stats command: counts of the items and nodes in the articles of a
+// dump, to profile a corpus
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var statsFile = flag.String("statsfile", "", "statistics output file for the stats command (stdout if empty)")
+var statsFormat = flag.String("statsformat", "tsv", "statistics output `format`: tsv or json")
+var errorFile = flag.String("errorfile", "", "with the stats command, also write the syntax errors of every article with their line and column (none if empty)")
+
+var statsFormats = []string{"tsv", "json"}
+
+// writeStatsJSON writes the statistics as a JSON object with the same
+// counts as the lines of Stats.WriteTo, and the schema version.
+func writeStatsJSON(w io.Writer, stats *wikitext.Stats) error {
+	items := make(map[string]int, len(stats.Items))
+	for t, n := range stats.Items {
+		items[t.String()] = n
+	}
+	return json.NewEncoder(w).Encode(map[string]any{
+		"schema_version": schema.Version,
+		"documents":      stats.Documents,
+		"items":          items,
+		"nodes":          stats.Nodes,
+		"errors":         stats.Errors,
+		"max": map[string]int{
+			"templatedepth": stats.MaxTemplateDepth,
+			"sectionlevel":  stats.MaxSectionLevel,
+			"listlevel":     stats.MaxListLevel,
+		},
+	})
+}
+
+// collectStats writes the statistics of all articles in the dump, in the
+// format of -statsformat, and with -errorfile their syntax errors as
+// "article\tline\tcolumn\tkind\tmessage" lines. Redirects are collected
+// into redirects.
+func collectStats(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	var errs *bufio.Writer
+	var finishErrors func() error
+	if *errorFile != "" {
+		var err error
+		if errs, finishErrors, err = openOutput(*errorFile, "errors", run); err != nil {
+			return err
+		}
+	}
+	stats := wikitext.NewStats()
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		stats.Add(doc)
+		if errs != nil {
+			title := dump.CanonicalizeTitle(p.Title)
+			for _, e := range doc.Errors {
+				errs.WriteString(title + "\t" + strconv.Itoa(e.Line) + "\t" + strconv.Itoa(e.Column) + "\t" + e.Kind.Error() + "\t" + oneLine.Replace(e.Msg) + "\n")
+			}
+		}
+	}
+	if errs != nil {
+		if err := finishErrors(); err != nil {
+			return err
+		}
+	}
+	writer, finish, err := openOutput(*statsFile, "stats", run)
+	if err != nil {
+		return err
+	}
+	if *statsFormat == "json" {
+		err = writeStatsJSON(writer, stats)
+	} else {
+		_, err = stats.WriteTo(writer)
+	}
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "articles", stats.Documents)
+	return err
+}
@@ -0,0 +1,31 @@
+// Leaving the maintenance boilerplate of the articles out with -strip:
+// the templates of the strip list are not expanded by -expandtemplates,
+// and its hidden categories are not written
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var strip = flag.Bool("strip", false, "leave the maintenance templates, like cleanup banners, {{Citation needed}}, stub notices and navboxes, out of the templates expanded by -expandtemplates, and the hidden categories out of the categories written, by -stripfile or those of the English Wikipedia")
+var stripFile = flag.String("stripfile", "", "with -strip, the `file` of the templates and categories stripped, a title like Template:Citation needed or Category:Articles * per line, with * for any text (those of the English Wikipedia if empty)")
+
+// stripList is the strip list of -strip, nil without it.
+var stripList *wikitext.StripList
+
+// readStripList returns the strip list of -strip.
+func readStripList() (*wikitext.StripList, error) {
+	if *stripFile == "" {
+		return wikitext.EnglishStripList, nil
+	}
+	f, err := os.Open(*stripFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return wikitext.ReadStripList(f)
+}
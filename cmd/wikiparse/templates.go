@@ -0,0 +1,45 @@
+// Expansion of templates from the Template: pages of the dump
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var expandTemplates = flag.Bool("expandtemplates", false, "expand the templates of articles from the Template: pages of the dump, read in a first pass")
+var templateDepth = flag.Int("templatedepth", wikitext.DefaultExpansionDepth, "with -expandtemplates, expand templates nested up to `n` levels deep")
+
+// loadTemplates reads the template pages of the dump into a store, in a
+// pass of its own since templates may follow the articles using them. It
+// also returns a digest of the templates, for the manifest.
+func loadTemplates(path string) (*wikitext.TemplateStore, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	store := wikitext.NewTemplateStore()
+	store.Site = site
+	digest := audit.NewDigest()
+	pages, pagesErr := allPages(dumpReader(path, file))
+	for p := range pages {
+		if number, _ := site.Split(p.Title); number != wikitext.NamespaceTemplate {
+			continue
+		}
+		if p.Redir.Title != "" {
+			store.AddRedirect(p.Title, p.Redir.Title)
+		} else {
+			store.Add(p.Title, p.Text)
+		}
+		io.WriteString(digest, p.Title+"\n"+p.Redir.Title+"\n"+p.Text)
+	}
+	if err := pagesErr(); err != nil {
+		return nil, "", err
+	}
+	return store, digest.Sum(), nil
+}
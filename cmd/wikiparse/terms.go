@@ -0,0 +1,223 @@
+// The terms command: the frequencies of the words and word pairs of the
+// articles in a dump, and the term vector of every article
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/search"
+	"github.com/pcmoritz/wikipedia/internal/tally"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	termFile       = flag.String("termfile", "", "output file of the word and word pair frequencies for the terms command (stdout if empty)")
+	termFormat     = flag.String("termformat", "tsv", "terms output `format`: tsv or binary")
+	termTopK       = flag.Int("termtopk", 0, "write only the `k` most frequent words and the k most frequent word pairs, by descending frequency (all, in order, if 0)")
+	termMinCount   = flag.Int("termmincount", 1, "least frequency of the words and word pairs written by the terms command")
+	termBuffer     = flag.Int("termbuffer", 5000000, "number of distinct words and word pairs the terms command counts in memory before it writes them to a temporary file")
+	termVectorFile = flag.String("termvectorfile", "", "with the terms command, also write how often every article has each word (none if empty)")
+	stopwordFile   = flag.String("stopwordfile", "", "words left out by the terms command, one per line (none if empty)")
+	tokenizerRules = flag.String("tokenizer", "", "comma separated `rules` of how the terms command splits words: splithyphens, splitapostrophes, numbers or segment (none if empty)")
+)
+
+var termFormats = []string{"tsv", "binary"}
+
+// termsMagic starts the files of -termformat binary, which hold per word
+// or word pair: the number of words, the words joined by a blank, the
+// frequency and the number of articles (uvarints, the string as its
+// length and bytes).
+const termsMagic = "wikiterms 1\n"
+
+// A term is a word or word pair with its counts.
+type term struct {
+	ngram     string
+	frequency int64
+	documents int64
+}
+
+// topTerms holds the most frequent terms seen, the least frequent first.
+type topTerms []term
+
+func (h topTerms) Len() int { return len(h) }
+func (h topTerms) Less(i, j int) bool {
+	return cmp.Or(cmp.Compare(h[i].frequency, h[j].frequency), cmp.Compare(h[j].ngram, h[i].ngram)) < 0
+}
+func (h topTerms) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *topTerms) Push(x any)   { *h = append(*h, x.(term)) }
+func (h *topTerms) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// readStopwords returns the lowercased words of the file, one per line.
+// Empty lines and those starting with "#" are skipped.
+func readStopwords(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" && !strings.HasPrefix(word, "#") {
+			words[strings.ToLower(word)] = true
+		}
+	}
+	return words, scanner.Err()
+}
+
+// collectTerms writes how often the words and word pairs of the plain
+// text of the articles occur, and in how many articles, as lines
+// "ngram, n, frequency, documents" in the format of -termformat, with n
+// the number of words. The words are those of search.Tokenize with the
+// rules of -tokenizer, without
+// those of -stopwordfile, and the pairs are adjacent words of what is
+// left. All are written in order, or with -termtopk the most frequent of
+// either length, kept in a heap as the counts are merged. The counts
+// are kept in a tally.Counter, in temporary files beyond -termbuffer of
+// them. With -termvectorfile, the words of every article are written as
+// "article, word, count" lines too, the most frequent first. Redirects
+// are collected into redirects.
+func collectTerms(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	// Valid, as checked by validateConfig.
+	tokenizer, _ := wikitext.ParseTokenizer(*tokenizerRules)
+	var stopwords map[string]bool
+	if *stopwordFile != "" {
+		var err error
+		if stopwords, err = readStopwords(*stopwordFile); err != nil {
+			return err
+		}
+	}
+	var vectors *bufio.Writer
+	var finishVectors func() error
+	if *termVectorFile != "" {
+		var err error
+		if vectors, finishVectors, err = openOutput(*termVectorFile, "termvectors", run); err != nil {
+			return err
+		}
+	}
+
+	// Every term has two counts, keyed by the term followed by NUL and "d"
+	// for its documents or "f" for its frequency, which come one after the
+	// other in the order of the keys.
+	counter := tally.NewCounter(*termBuffer, "")
+	defer counter.Close()
+	counts := make(map[string]int64)
+	articles, tokens := 0, 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		words := search.Tokenize(wikitext.PlainText(doc), tokenizer)
+		words = slices.DeleteFunc(words, func(w string) bool { return stopwords[w] })
+		clear(counts)
+		for i, w := range words {
+			counts[w]++
+			if i > 0 {
+				counts[words[i-1]+" "+w]++
+			}
+		}
+		for ngram, n := range counts {
+			counter.Add(ngram+"\x00f", n)
+			counter.Add(ngram+"\x00d", 1)
+		}
+		if vectors != nil {
+			title := dump.CanonicalizeTitle(p.Title)
+			unigrams := slices.DeleteFunc(slices.Collect(maps.Keys(counts)), func(ngram string) bool { return strings.Contains(ngram, " ") })
+			slices.SortFunc(unigrams, func(a, b string) int { return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b)) })
+			for _, w := range unigrams {
+				fmt.Fprintf(vectors, "%s\t%s\t%d\n", title, w, counts[w])
+			}
+		}
+		articles++
+		tokens += len(words)
+	}
+	if vectors != nil {
+		if err := finishVectors(); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	writer, finish, err := openOutput(*termFile, "terms-"+*termFormat, run)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, 64)
+	write := func(t term) {
+		n := strings.Count(t.ngram, " ") + 1
+		if *termFormat == "binary" {
+			buf = binary.AppendUvarint(buf[:0], uint64(n))
+			buf = binary.AppendUvarint(buf, uint64(len(t.ngram)))
+			buf = append(buf, t.ngram...)
+			buf = binary.AppendUvarint(buf, uint64(t.frequency))
+			buf = binary.AppendUvarint(buf, uint64(t.documents))
+			writer.Write(buf)
+		} else {
+			fmt.Fprintf(writer, "%s\t%d\t%d\t%d\n", t.ngram, n, t.frequency, t.documents)
+		}
+	}
+	top := [2]topTerms{}
+	written, runs := 0, counter.Runs()
+	var current term
+	err = counter.Totals(func(key string, n int64) error {
+		ngram, count, _ := strings.Cut(key, "\x00")
+		if count == "d" {
+			current = term{ngram: ngram, documents: n}
+			return nil
+		}
+		current.frequency = n
+		if current.frequency < int64(*termMinCount) {
+			return nil
+		}
+		if *termTopK == 0 {
+			write(current)
+			written++
+			return nil
+		}
+		h := &top[min(strings.Count(ngram, " "), 1)]
+		if h.Len() < *termTopK {
+			heap.Push(h, current)
+		} else if topTerms([]term{(*h)[0], current}).Less(0, 1) {
+			(*h)[0] = current
+			heap.Fix(h, 0)
+		}
+		return nil
+	})
+	for _, h := range top {
+		slices.SortFunc(h, func(a, b term) int {
+			return cmp.Or(cmp.Compare(b.frequency, a.frequency), cmp.Compare(a.ngram, b.ngram))
+		})
+		for _, t := range h {
+			write(t)
+			written++
+		}
+	}
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "articles", articles, "words", tokens, "terms_written", written, "temporary_files", runs)
+	return err
+}
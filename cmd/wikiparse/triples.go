@@ -0,0 +1,209 @@
+// The triples command: RDF triples of the infoboxes, categories and
+// links of the articles in a dump, like those of DBpedia
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/internal/rdf"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var (
+	tripleFile        = flag.String("triplefile", "", "output file for the triples command (stdout if empty)")
+	tripleFormat      = flag.String("tripleformat", "ntriples", "triples output `format`: ntriples or turtle")
+	tripleMappingFile = flag.String("triplemapping", "", "JSON file mapping templates and their parameters to classes and properties for the triples command (the raw parameters of infoboxes if empty)")
+)
+
+var tripleFormats = []string{"ntriples", "turtle"}
+
+// A tripleMapping tells which triples the triples command writes. Keys
+// missing from the JSON file keep their defaults, and an empty predicate
+// leaves out the triples of the categories or links.
+type tripleMapping struct {
+	Base              string `json:"base"`              // of the IRIs of the articles
+	Language          string `json:"language"`          // of the literals
+	PropertyBase      string `json:"propertybase"`      // of the raw properties of infoboxes without a mapping, none if empty
+	CategoryPredicate string `json:"categorypredicate"` // linking an article to its categories
+	LinkPredicate     string `json:"linkpredicate"`     // linking an article to those it links to
+
+	// Templates maps template names to their class and the properties of
+	// their parameters. Parameters without a property are left out.
+	Templates map[string]templateMapping `json:"templates"`
+}
+
+type templateMapping struct {
+	Class      string            `json:"class"`      // the rdf:type of the article, none if empty
+	Properties map[string]string `json:"properties"` // parameter name to property
+}
+
+// defaultTripleMapping returns the mapping of DBpedia's raw infobox
+// properties.
+func defaultTripleMapping() *tripleMapping {
+	return &tripleMapping{
+		Base:              "http://dbpedia.org/resource/",
+		Language:          "en",
+		PropertyBase:      "http://dbpedia.org/property/",
+		CategoryPredicate: "http://purl.org/dc/terms/subject",
+		LinkPredicate:     "http://dbpedia.org/ontology/wikiPageWikiLink",
+	}
+}
+
+// readTripleMapping returns the default mapping with the settings of the
+// JSON file at path. Template names are normalized as by templateKey.
+func readTripleMapping(path string) (*tripleMapping, error) {
+	m := defaultTripleMapping()
+	if path == "" {
+		return m, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	templates := make(map[string]templateMapping, len(m.Templates))
+	for name, t := range m.Templates {
+		templates[templateKey(name)] = t
+	}
+	m.Templates = templates
+	return m, nil
+}
+
+// templateKey returns the name of a template as it is looked up in the
+// mapping: lower case, with underscores as blanks.
+func templateKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " "))
+}
+
+var (
+	integerValue = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	decimalValue = regexp.MustCompile(`^[-+]?[0-9]*\.[0-9]+$`)
+)
+
+// paramObjects returns the objects of a parameter value: the articles it
+// links to, or else its plain text as a number or a string, or nothing if
+// it has no text, like a value that is only a template.
+func (m *tripleMapping) paramObjects(value *wikitext.Document) []rdf.Term {
+	objects := make([]rdf.Term, 0, 2)
+	for _, l := range wikitext.Links(value) {
+		if l.Class == wikitext.LinkArticle && l.Interwiki == "" && l.Target != "" {
+			objects = append(objects, rdf.IRI(rdf.ResourceIRI(m.Base, l.Target)))
+		}
+	}
+	if len(objects) > 0 {
+		return objects
+	}
+	text := strings.Join(strings.Fields(wikitext.PlainText(value)), " ")
+	switch {
+	case text == "":
+	case integerValue.MatchString(text):
+		objects = append(objects, rdf.TypedLiteral(strings.TrimPrefix(text, "+"), rdf.XSDInteger))
+	case decimalValue.MatchString(text):
+		objects = append(objects, rdf.TypedLiteral(strings.TrimPrefix(text, "+"), rdf.XSDDecimal))
+	default:
+		objects = append(objects, rdf.Literal(text, m.Language))
+	}
+	return objects
+}
+
+// triples returns the triples of an article: the class and properties of
+// its templates in the mapping, or the raw properties of its infoboxes
+// otherwise, then the articles it links to and its categories.
+func (m *tripleMapping) triples(title string, doc *wikitext.Document) []rdf.Triple {
+	subject := rdf.ResourceIRI(m.Base, title)
+	triples := make([]rdf.Triple, 0, 20)
+	add := func(predicate string, object rdf.Term) {
+		triples = append(triples, rdf.Triple{Subject: subject, Predicate: predicate, Object: object})
+	}
+	for _, t := range wikitext.Templates(doc, 1) {
+		mapping, mapped := m.Templates[templateKey(t.Name)]
+		if !mapped && (m.PropertyBase == "" || !wikitext.IsInfobox(t.Name)) {
+			continue
+		}
+		if mapping.Class != "" {
+			add(rdf.Type, rdf.IRI(mapping.Class))
+		}
+		for _, p := range t.Params {
+			predicate := mapping.Properties[p.Name]
+			if !mapped {
+				predicate = m.PropertyBase + rdf.EscapeIRI(strings.ReplaceAll(strings.TrimSpace(p.Name), " ", "_"))
+			}
+			if predicate == "" {
+				continue
+			}
+			for _, object := range m.paramObjects(p.Value) {
+				add(predicate, object)
+			}
+		}
+	}
+	if m.LinkPredicate != "" {
+		seen := make(map[string]bool)
+		for _, l := range wikitext.Links(doc) {
+			if l.Class != wikitext.LinkArticle || l.Interwiki != "" || l.Target == "" {
+				continue
+			}
+			if object := rdf.ResourceIRI(m.Base, l.Target); !seen[object] {
+				seen[object] = true
+				add(m.LinkPredicate, rdf.IRI(object))
+			}
+		}
+	}
+	if m.CategoryPredicate != "" {
+		for _, c := range wikitext.Categories(doc) {
+			add(m.CategoryPredicate, rdf.IRI(rdf.ResourceIRI(m.Base, "Category:"+c.Name)))
+		}
+	}
+	return triples
+}
+
+// extractTriples writes RDF triples of every article in the dump as
+// N-Triples or Turtle, per -tripleformat: the classes and properties of
+// its templates as mapped by -triplemapping, the articles it links to and
+// its categories. Redirects are collected into redirects.
+func extractTriples(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	mapping, err := readTripleMapping(*tripleMappingFile)
+	if err != nil {
+		return err
+	}
+	// The header line is a comment in both formats.
+	writer, finish, err := openOutput(*tripleFile, "triples-"+*tripleFormat, run)
+	if err != nil {
+		return err
+	}
+	triples := rdf.NewWriter(writer, *tripleFormat == "turtle")
+
+	total, articles := 0, 0
+	for p, doc := range parsedPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		if !isArticle(p) {
+			continue
+		}
+		for _, t := range mapping.triples(p.Title, doc) {
+			triples.Write(t)
+			total++
+		}
+		articles++
+	}
+	err = triples.Close()
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	logger.Info("Totals", "articles", articles, "triples", total)
+	return err
+}
@@ -0,0 +1,623 @@
+// Up-front validation of the command line configuration, so that a
+// misconfigured run fails immediately rather than hours into a dump.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/filter"
+	"github.com/pcmoritz/wikipedia/internal/secret"
+	"github.com/pcmoritz/wikipedia/sink"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// A configError describes a problem with one configuration setting.
+type configError struct {
+	Setting string // the flag or path the problem refers to, e.g. "-infile"
+	Msg     string
+}
+
+func (e *configError) Error() string {
+	return e.Setting + ": " + e.Msg
+}
+
+// checkInputFile reports an error unless path names a readable regular file.
+func checkInputFile(setting string, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return &configError{setting, fmt.Sprintf("cannot read %q: %v", path, err)}
+	}
+	if info.IsDir() {
+		return &configError{setting, fmt.Sprintf("%q is a directory, expected a file", path)}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return &configError{setting, fmt.Sprintf("cannot open %q: %v", path, err)}
+	}
+	f.Close()
+	return nil
+}
+
+// checkOutputDir reports an error unless dir is an existing, writable directory.
+func checkOutputDir(setting string, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return &configError{setting, fmt.Sprintf("output directory %q does not exist", dir)}
+	}
+	if !info.IsDir() {
+		return &configError{setting, fmt.Sprintf("%q is not a directory", dir)}
+	}
+	probe, err := os.CreateTemp(dir, ".probe")
+	if err != nil {
+		return &configError{setting, fmt.Sprintf("output directory %q is not writable: %v", dir, err)}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// checkOutputFile reports an error unless the directory containing path
+// exists and is writable.
+func checkOutputFile(setting string, path string) error {
+	return checkOutputDir(setting, filepath.Dir(path))
+}
+
+// checkChoice reports an error unless value is one of choices.
+func checkChoice(setting string, value string, choices []string) error {
+	for _, c := range choices {
+		if value == c {
+			return nil
+		}
+	}
+	return &configError{setting, fmt.Sprintf("unknown value %q, expected one of %s", value, strings.Join(choices, ", "))}
+}
+
+// checkPassword reports an error unless the credential called name, read
+// with secret.Read, is set, as the user of setting needs it.
+func checkPassword(setting string, name string) error {
+	password, err := secret.Read(name)
+	if err != nil {
+		return &configError{setting, err.Error()}
+	}
+	if password.Value() == "" {
+		return &configError{setting, fmt.Sprintf("needs a password in WIKI_%s or WIKI_%s_FILE", name, name)}
+	}
+	return nil
+}
+
+// checkSinks reports an error unless there are sinks and all of them are
+// registered, with a file to write for those writing one.
+func checkSinks(specs []string) error {
+	if len(specs) == 0 {
+		return &configError{"-sink", "export needs at least one sink, like -sink jsonl:out/articles.jsonl"}
+	}
+	for _, spec := range specs {
+		name, target := splitSink(spec)
+		if _, err := sink.New(name); err != nil {
+			return &configError{"-sink", err.Error()}
+		}
+		switch {
+		case target == "":
+		case name == "jsonl" || name == "sqlite" || name == "files" || name == "wikiextractor":
+			if err := checkOutputFile("-sink", target); err != nil {
+				return err
+			}
+		case name == "elasticsearch":
+			if u, err := url.Parse(target); err != nil || u.Scheme == "" || u.Host == "" {
+				return &configError{"-sink", fmt.Sprintf("%q is not an absolute URL", target)}
+			}
+		}
+	}
+	return nil
+}
+
+// validateConfig checks all settings of the loader and returns every
+// problem found, not just the first one.
+func validateConfig() []error {
+	errs := make([]error, 0)
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	check(checkChoice("-logformat", *logFormat, logFormats))
+	check(checkChoice("-loglevel", *logLevel, logLevels))
+	if *apiURL == "" && activeCommand.name != "download" {
+		check(checkInputFile("-infile", *inputFile))
+	}
+	if *metricsAddr != "" {
+		if _, _, err := net.SplitHostPort(*metricsAddr); err != nil {
+			check(&configError{"-metricsaddr", fmt.Sprintf("%q is no host:port", *metricsAddr)})
+		}
+	}
+	if *progressInterval < 0 {
+		check(&configError{"-progress", "must not be negative"})
+	}
+	if *statusFile != "" && *progressInterval == 0 {
+		check(&configError{"-statusfile", "only applies with -progress"})
+	}
+	if *statusFile != "" {
+		check(checkOutputFile("-statusfile", *statusFile))
+	}
+	if *siteFile != "" {
+		check(checkInputFile("-sitefile", *siteFile))
+	}
+	if *maxChars < 0 {
+		check(&configError{"-maxchars", "must not be negative"})
+	}
+	if *maxWords < 0 {
+		check(&configError{"-maxwords", "must not be negative"})
+	}
+	if *maxSentences < 0 {
+		check(&configError{"-maxsentences", "must not be negative"})
+	}
+	if *abstractSentences < 0 {
+		check(&configError{"-abstractsentences", "must not be negative"})
+	}
+	if *abstractSentences > 0 && !*abstract {
+		check(&configError{"-abstractsentences", "only applies with -abstract"})
+	}
+	if *keepEntities && !*abstract {
+		check(&configError{"-keepentities", "only applies with -abstract"})
+	}
+	if *skeleton && *abstract {
+		check(&configError{"-skeleton", "cannot be combined with -abstract"})
+	}
+	if *toc && (*abstract || *skeleton) {
+		check(&configError{"-toc", "cannot be combined with -abstract or -skeleton"})
+	}
+	if *titleFilter != "" {
+		if _, err := regexp.Compile(*titleFilter); err != nil {
+			check(&configError{"-match", err.Error()})
+		}
+	}
+	if *titleFile != "" {
+		check(checkInputFile("-titlefile", *titleFile))
+	}
+	if *namespaceList != "" {
+		if _, err := parseNamespaces(*namespaceList); err != nil {
+			check(&configError{"-namespaces", err.Error()})
+		}
+	}
+	if *sampleRate < 0 || *sampleRate > 1 {
+		check(&configError{"-sample", "must be from 0 to 1"})
+	}
+	if *articleLimit < 0 {
+		check(&configError{"-limit", "must not be negative"})
+	}
+	if *filterExpr != "" {
+		if _, err := filter.Compile(*filterExpr); err != nil {
+			check(&configError{"-filter", err.Error()})
+		}
+	}
+	if *workers < 1 {
+		check(&configError{"-workers", "must be at least 1"})
+	}
+	check(checkChoice("-informat", *inputFormat, inputFormats))
+	if *cirrusText && *inputFormat != "cirrus" {
+		check(&configError{"-cirrustext", "only applies with -informat cirrus"})
+	}
+	if *apiURL != "" {
+		if u, err := url.Parse(*apiURL); err != nil || u.Scheme == "" || u.Host == "" {
+			check(&configError{"-apiurl", "must be an absolute URL, like https://en.wikipedia.org/w/api.php"})
+		}
+		if *titleFile == "" {
+			check(&configError{"-apiurl", "needs -titlefile, the titles of the pages to fetch"})
+		}
+		if *inputFormat != "xml" {
+			check(&configError{"-informat", "cannot be combined with -apiurl, which fetches XML exports"})
+		}
+		if *expandTemplates {
+			check(&configError{"-expandtemplates", "cannot be combined with -apiurl, as the templates are read from -infile"})
+		}
+		if activeCommand.name == "estimate" {
+			check(&configError{"-apiurl", "cannot be estimated, as the sample is read from -infile"})
+		}
+		if *apiCache != "" {
+			check(checkOutputFile("-apicache", *apiCache))
+		}
+	}
+	if compressedDump(*inputFile) && *apiURL == "" {
+		switch activeCommand.name {
+		case "pageindex", "get", "serve", "grpc":
+			check(&configError{"-infile", "must be uncompressed, as the " + activeCommand.name + " command reads pages at their offsets"})
+		}
+		if *inputFormat != "xml" {
+			check(&configError{"-informat", "only bzip2 compressed XML dumps are read"})
+		}
+	}
+	if *multistreamIndex != "" {
+		check(checkInputFile("-multistreamindex", *multistreamIndex))
+	}
+	if *mapInput {
+		if *inputFormat != "xml" {
+			check(&configError{"-mmap", "only maps XML dumps"})
+		}
+		if compressedDump(*inputFile) {
+			check(&configError{"-mmap", "only maps uncompressed dumps"})
+		}
+		if *apiURL != "" {
+			check(&configError{"-mmap", "cannot be combined with -apiurl, as there is no dump to map"})
+		}
+	}
+	if *pageStoreFile != "" {
+		check(checkOutputFile("-pagestore", *pageStoreFile))
+	}
+	if *pipelineFile != "" {
+		if err := checkInputFile("-pipelinefile", *pipelineFile); err != nil {
+			check(err)
+		} else if _, err := readPipeline(*pipelineFile); err != nil {
+			check(&configError{"-pipelinefile", err.Error()})
+		}
+	}
+	if *stripFile != "" {
+		if !*strip {
+			check(&configError{"-stripfile", "only applies with -strip"})
+		} else if err := checkInputFile("-stripfile", *stripFile); err != nil {
+			check(err)
+		} else if _, err := readStripList(); err != nil {
+			check(&configError{"-stripfile", err.Error()})
+		}
+	}
+	if *dedupMode != "" {
+		check(checkChoice("-dedup", *dedupMode, dedupModes))
+		if *dedupThreshold <= 0 || *dedupThreshold > 1 {
+			check(&configError{"-dedupthreshold", "must be above 0 and at most 1"})
+		}
+	} else if given("dedupthreshold") || *dedupDrop {
+		check(&configError{"-dedup", "is needed by -dedupthreshold and -dedupdrop"})
+	}
+	if *apiInterval < 0 {
+		check(&configError{"-apiinterval", "must not be negative"})
+	}
+	if *apiMaxLag < 0 {
+		check(&configError{"-apimaxlag", "must not be negative"})
+	}
+	if *apiCacheAge < 0 {
+		check(&configError{"-apicacheage", "must not be negative"})
+	}
+	// "estimate" is followed by the command whose run it estimates, and
+	// "download" by the one reading the dump downloaded, if any.
+	cmd, args := activeCommand, commandArgs
+	if cmd.name == "download" {
+		if len(args) != 3 {
+			check(&configError{"download", "needs the wiki, date and kind of the dump, like enwiki latest pages-articles-multistream"})
+		}
+		args = nil
+		for _, m := range strings.Split(*mirrorList, ",") {
+			if u, err := url.Parse(m); err != nil || u.Scheme == "" || u.Host == "" {
+				check(&configError{"-mirrors", fmt.Sprintf("%q is not an absolute URL", m)})
+			}
+		}
+		check(checkOutputDir("-downloaddir", *downloadDir))
+		if nextCommand != nil {
+			cmd = nextCommand
+			if cmd.pipeline == nil {
+				check(&configError{cmd.name, "cannot follow download, as it reads no dump"})
+			}
+		}
+	}
+	if cmd.name == "estimate" {
+		if *sampleFraction <= 0 || *sampleFraction > 1 {
+			check(&configError{"-samplefraction", "must be greater than 0 and at most 1"})
+		}
+		cmd = nextCommand
+		if cmd.pipeline == nil {
+			check(&configError{cmd.name, "cannot be estimated"})
+		}
+		if cmd.name == "elasticsearch" {
+			check(&configError{cmd.name, "cannot be estimated, as the sample would be indexed"})
+		}
+		if cmd.name == "kafka" {
+			check(&configError{cmd.name, "cannot be estimated, as the sample would be published"})
+		}
+	}
+	// Only the outputs of the command run are checked, and none of an
+	// estimate, which writes them to a temporary directory.
+	writes := func(name string) bool {
+		return activeCommand.name != "estimate" && cmd.takes(name)
+	}
+	if writes("redirectfile") && *redirectFile != "" {
+		check(checkOutputFile("-redirectfile", *redirectFile))
+	}
+	if writes("auditfile") && *auditFile != "" {
+		check(checkOutputFile("-auditfile", *auditFile))
+	}
+	// The flags given before the name of the command must be its own.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "completion" || f.Name == "man" || cmd.takes(f.Name) || activeCommand.takes(f.Name) {
+			return
+		}
+		taking := make([]*command, 0, len(commands))
+		for _, c := range commands {
+			if c.takes(f.Name) {
+				taking = append(taking, c)
+			}
+		}
+		if len(taking) == 1 {
+			check(&configError{"-" + f.Name, "only applies to the " + taking[0].name + " command"})
+		} else {
+			check(&configError{"-" + f.Name, "only applies to the " + commandNames(taking) + " commands"})
+		}
+	})
+	if *manifestFile != "" {
+		check(checkOutputFile("-manifest", *manifestFile))
+	}
+	if *checkpointFile != "" {
+		check(checkOutputFile("-checkpoint", *checkpointFile))
+	}
+	if *incremental && *manifestFile == "" {
+		check(&configError{"-incremental", "only applies with -manifest"})
+	}
+	if *resume && *checkpointFile == "" {
+		check(&configError{"-resume", "only applies with -checkpoint"})
+	}
+	if *templateDepth < 1 {
+		check(&configError{"-templatedepth", "must be at least 1"})
+	}
+	switch cmd.name {
+	case "extract":
+		if writes("indexfile") {
+			check(checkOutputFile("-indexfile", *indexFile))
+			check(checkOutputDir(docsDir, docsDir))
+		}
+		if *pipelineFile != "" || processors != nil {
+			check(&configError{"-pipelinefile", "does not apply to extract, which runs no processors; use export"})
+		}
+		if *dedupMode != "" {
+			check(&configError{"-dedup", "does not apply to extract, which writes every article; use export"})
+		}
+		if *strip && !*expandTemplates {
+			check(&configError{"-strip", "only applies to extract with -expandtemplates, as it writes the wikitext otherwise"})
+		}
+		if !*abstract {
+			limits := []struct {
+				setting string
+				value   int
+			}{{"-maxchars", *maxChars}, {"-maxwords", *maxWords}, {"-maxsentences", *maxSentences}}
+			for _, l := range limits {
+				if l.value != 0 {
+					check(&configError{l.setting, "only applies to extract with -abstract, as it writes the wikitext otherwise"})
+				}
+			}
+		}
+	case "links":
+		check(checkChoice("-linkformat", *linkFormat, linkFormats))
+		check(checkChoice("-linkscope", *linkScope, linkScopes))
+		if _, err := parseLinkClasses(*linkClasses); err != nil {
+			check(&configError{"-linkclasses", err.Error()})
+		}
+		if *linkFile != "" {
+			check(checkOutputFile("-linkfile", *linkFile))
+		}
+		if *resolveFile != "" {
+			check(checkInputFile("-resolvefile", *resolveFile))
+		}
+	case "anchors":
+		if *anchorFile != "" {
+			check(checkOutputFile("-anchorfile", *anchorFile))
+		}
+		if *resolveFile != "" {
+			check(checkInputFile("-resolvefile", *resolveFile))
+		}
+		if *anchorBuffer < 1 {
+			check(&configError{"-anchorbuffer", "must be at least 1"})
+		}
+		if *anchorMinCount < 1 {
+			check(&configError{"-anchormincount", "must be at least 1"})
+		}
+	case "pagerank":
+		if *pageRankFile != "" {
+			check(checkOutputFile("-pagerankfile", *pageRankFile))
+		}
+		if *damping < 0 || *damping >= 1 {
+			check(&configError{"-damping", "must be at least 0 and less than 1"})
+		}
+		if *pageRankIterations < 1 {
+			check(&configError{"-pagerankiterations", "must be at least 1"})
+		}
+		if *pageRankTolerance < 0 {
+			check(&configError{"-pageranktolerance", "must not be negative"})
+		}
+	case "categories":
+		if *categoryFile != "" {
+			check(checkOutputFile("-categoryfile", *categoryFile))
+		}
+		if *categoryTreeFile != "" {
+			check(checkOutputFile("-categorytreefile", *categoryTreeFile))
+		}
+		if *ancestorsFile != "" {
+			check(checkInputFile("-ancestorsfile", *ancestorsFile))
+		}
+		if *maxCategoryDepth < 1 {
+			check(&configError{"-maxcategorydepth", "must be at least 1"})
+		}
+	case "externallinks":
+		if *externalLinkFile != "" {
+			check(checkOutputFile("-externallinkfile", *externalLinkFile))
+		}
+	case "doctor":
+		if *checksumFile != "" {
+			check(checkInputFile("-checksumfile", *checksumFile))
+		}
+	case "images":
+		if *imageFile != "" {
+			check(checkOutputFile("-imagefile", *imageFile))
+		}
+	case "languagelinks":
+		if *languageLinkFile != "" {
+			check(checkOutputFile("-languagelinkfile", *languageLinkFile))
+		}
+	case "triples":
+		check(checkChoice("-tripleformat", *tripleFormat, tripleFormats))
+		if *tripleFile != "" {
+			check(checkOutputFile("-triplefile", *tripleFile))
+		}
+		if *tripleMappingFile != "" {
+			check(checkInputFile("-triplemapping", *tripleMappingFile))
+		}
+	case "sections":
+		if *sectionFile != "" {
+			check(checkOutputFile("-sectionfile", *sectionFile))
+		}
+		if u, err := url.Parse(*wikiURL); err != nil || u.Scheme == "" || u.Host == "" {
+			check(&configError{"-wikiurl", fmt.Sprintf("%q is not an absolute URL", *wikiURL)})
+		}
+	case "sentences":
+		if *sentenceFile != "" {
+			check(checkOutputFile("-sentencefile", *sentenceFile))
+		}
+	case "quality":
+		check(checkChoice("-qualityformat", *qualityFormat, qualityFormats))
+		if *qualityFile != "" {
+			check(checkOutputFile("-qualityfile", *qualityFile))
+		}
+	case "elasticsearch", "export":
+		if cmd.name == "export" {
+			check(checkSinks(sinkSpecs))
+			check(checkChoice("-filesformat", *filesFormat, sink.FileFormats))
+			if *filesShards < 0 || *filesShards > 4 {
+				check(&configError{"-filesshards", "must be from 0 to 4"})
+			}
+			if *wikiExtractorBytes < 1 {
+				check(&configError{"-wikiextractorbytes", "must be at least 1"})
+			}
+		} else if u, err := url.Parse(*esURL); err != nil || u.Scheme == "" || u.Host == "" {
+			check(&configError{"-esurl", fmt.Sprintf("%q is not an absolute URL", *esURL)})
+		}
+		if *esMapping != "" {
+			check(checkInputFile("-esmapping", *esMapping))
+		}
+		if *esBatch < 1 {
+			check(&configError{"-esbatch", "must be at least 1"})
+		}
+		if *esRetries < 0 {
+			check(&configError{"-esretries", "must not be negative"})
+		}
+		if *esUser != "" {
+			check(checkPassword("-esuser", "ES_PASSWORD"))
+		}
+	case "kafka":
+		check(checkChoice("-kafkamessages", *kafkaMessages, kafkaMessageKinds))
+		check(checkChoice("-kafkakey", *kafkaKey, kafkaKeys))
+		check(checkChoice("-kafkaformat", *kafkaFormat, kafkaFormats))
+		for _, addr := range strings.Split(*kafkaBrokers, ",") {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				check(&configError{"-kafkabrokers", fmt.Sprintf("%q is no host:port", addr)})
+			}
+		}
+		if *kafkaFormat == "avro" {
+			if u, err := url.Parse(*kafkaRegistry); err != nil || u.Scheme == "" || u.Host == "" {
+				check(&configError{"-kafkaregistry", fmt.Sprintf("%q is not an absolute URL, needed for -kafkaformat avro", *kafkaRegistry)})
+			}
+		} else if *kafkaRegistry != "" {
+			check(&configError{"-kafkaregistry", "only applies with -kafkaformat avro"})
+		}
+		if *kafkaBatch < 1 {
+			check(&configError{"-kafkabatch", "must be at least 1"})
+		}
+		if *kafkaRetries < 0 {
+			check(&configError{"-kafkaretries", "must not be negative"})
+		}
+		if *kafkaCAFile != "" {
+			if *kafkaTLS {
+				check(checkInputFile("-kafkacafile", *kafkaCAFile))
+			} else {
+				check(&configError{"-kafkacafile", "only applies with -kafkatls"})
+			}
+		}
+		if *kafkaUser != "" {
+			check(checkPassword("-kafkauser", "KAFKA_PASSWORD"))
+			if !*kafkaTLS {
+				check(&configError{"-kafkauser", "needs -kafkatls, as SASL/PLAIN sends the password in the clear"})
+			}
+		}
+	case "index":
+		check(checkOutputFile("-searchindex", *searchIndex))
+	case "pageindex":
+		check(checkOutputFile("-pageindex", *pageIndex))
+	case "serve":
+		if _, err := os.Stat(*searchIndex); err != nil {
+			// The index is built before serving.
+			check(checkOutputFile("-searchindex", *searchIndex))
+		}
+		if *searchResults < 1 {
+			check(&configError{"-searchresults", "must be at least 1"})
+		}
+	case "parquet":
+		if _, err := parseLinkClasses(*linkClasses); err != nil {
+			check(&configError{"-linkclasses", err.Error()})
+		}
+		check(checkChoice("-linkscope", *linkScope, linkScopes))
+		check(checkOutputFile("-parquetfile", *parquetFile))
+	case "sqlite":
+		check(checkOutputFile("-sqlitefile", *sqliteFile))
+	case "protobuf":
+		if *protoFile != "-" {
+			check(checkOutputFile("-protofile", *protoFile))
+		}
+	case "history":
+		if *historyFile != "" {
+			check(checkOutputFile("-historyfile", *historyFile))
+		}
+		if *diffFile != "" {
+			check(checkOutputFile("-difffile", *diffFile))
+		}
+		if *changeFile != "" {
+			check(checkOutputFile("-changefile", *changeFile))
+		}
+		if *diffContext < 0 {
+			check(&configError{"-diffcontext", "must not be negative"})
+		}
+	case "wikidata":
+		if *wikidataFile != "" {
+			check(checkOutputFile("-wikidatafile", *wikidataFile))
+		}
+		if *pagePropsFile != "" {
+			check(checkInputFile("-pagepropsfile", *pagePropsFile))
+		}
+	case "stats":
+		check(checkChoice("-statsformat", *statsFormat, statsFormats))
+		if *statsFile != "" {
+			check(checkOutputFile("-statsfile", *statsFile))
+		}
+		if *errorFile != "" {
+			check(checkOutputFile("-errorfile", *errorFile))
+		}
+	case "terms":
+		check(checkChoice("-termformat", *termFormat, termFormats))
+		if *termFile != "" {
+			check(checkOutputFile("-termfile", *termFile))
+		}
+		if *termVectorFile != "" {
+			check(checkOutputFile("-termvectorfile", *termVectorFile))
+		}
+		if *stopwordFile != "" {
+			check(checkInputFile("-stopwordfile", *stopwordFile))
+		}
+		if _, err := wikitext.ParseTokenizer(*tokenizerRules); err != nil {
+			check(&configError{"-tokenizer", err.Error()})
+		}
+		if *termTopK < 0 {
+			check(&configError{"-termtopk", "must not be negative"})
+		}
+		if *termMinCount < 1 {
+			check(&configError{"-termmincount", "must be at least 1"})
+		}
+		if *termBuffer < 1 {
+			check(&configError{"-termbuffer", "must be at least 1"})
+		}
+	}
+	if len(args) > 0 {
+		check(&configError{args[0], "unexpected argument"})
+	}
+	return errs
+}
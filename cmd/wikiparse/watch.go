@@ -0,0 +1,152 @@
+// The watch command: a live HTML preview of a wikitext file, rendered
+// again whenever the file changes
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// watchInterval is how often the watched file is checked for changes.
+const watchInterval = 300 * time.Millisecond
+
+// A preview is the rendering of the watched file, replaced whenever the
+// file changes.
+type preview struct {
+	path string
+
+	mu      sync.Mutex
+	page    string        // the HTML page
+	changed chan struct{} // closed when the page is replaced
+}
+
+// previewScript reloads the page when the server sends an event, once
+// the file has changed.
+const previewScript = `<script>new EventSource("/events").onmessage = () => location.reload()</script>`
+
+// render renders the file as an HTML page: its syntax errors, if any,
+// with their lines and columns, and its HTML as by wikitext.HTML, with
+// links to the pages of -wikiurl. It returns the number of errors.
+func (p *preview) render() (string, int) {
+	var b strings.Builder
+	name := html.EscapeString(filepath.Base(p.path))
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n%s\n", name, previewScript)
+	b.WriteString("<style>body { max-width: 50em; margin: auto; font-family: sans-serif } .errors { color: #b00 }</style>\n")
+	text, err := os.ReadFile(p.path)
+	if err != nil {
+		fmt.Fprintf(&b, "<p class=\"errors\">%s</p>\n", html.EscapeString(err.Error()))
+		return b.String(), 1
+	}
+	doc, _ := wikitext.Parse(string(text), wikitext.WithSite(site))
+	if len(doc.Errors) > 0 {
+		b.WriteString("<ol class=\"errors\">\n")
+		for _, e := range doc.Errors {
+			fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(e.Error()))
+		}
+		b.WriteString("</ol>\n")
+	}
+	b.WriteString(wikitext.HTML(doc, strings.TrimRight(*wikiURL, "/")+"/wiki/"))
+	return b.String(), len(doc.Errors)
+}
+
+// update renders the file again and tells the pages open that it changed.
+func (p *preview) update() {
+	page, n := p.render()
+	p.mu.Lock()
+	p.page = page
+	close(p.changed)
+	p.changed = make(chan struct{})
+	p.mu.Unlock()
+	logger.Info("Rendered", "file", p.path, "syntax_errors", n)
+}
+
+// current returns the page and the channel closed when it is replaced.
+func (p *preview) current() (string, chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.page, p.changed
+}
+
+// watch renders the file again whenever its size or modification time
+// changes from those of last, until ctx is done.
+func (p *preview) watch(ctx context.Context, last os.FileInfo) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(p.path)
+		if (err == nil) != (last != nil) || err == nil && (info.ModTime() != last.ModTime() || info.Size() != last.Size()) {
+			p.update()
+		}
+		last = info
+	}
+}
+
+// ServeHTTP serves the page at / and the events at /events.
+func (p *preview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page, changed := p.current()
+	if r.URL.Path != "/events" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+		return
+	}
+	// A server-sent event for the next change, after which the page
+	// reloads and listens again.
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+	select {
+	case <-changed:
+		fmt.Fprint(w, "data: changed\n\n")
+	case <-r.Context().Done():
+	}
+}
+
+// runWatch serves a preview of the file given after "watch" on -addr,
+// rendered again whenever the file changes, until it is interrupted. It
+// returns the exit status.
+func runWatch() int {
+	if len(commandArgs) != 1 {
+		logger.Error("Invalid configuration", "err", "watch: expected one file, like watch article.txt")
+		return 2
+	}
+	if *siteFile != "" {
+		config, err := os.Open(*siteFile)
+		if err != nil {
+			logger.Error("Error reading site information", "err", err)
+			return 1
+		}
+		err = site.ReadJSON(config)
+		config.Close()
+		if err != nil {
+			logger.Error("Error reading site information", "err", err)
+			return 1
+		}
+	}
+	p := &preview{path: commandArgs[0], changed: make(chan struct{})}
+	info, _ := os.Stat(p.path)
+	p.update()
+	go p.watch(ctx, info)
+	logger.Info("Previewing", "file", p.path, "url", "http://"+*serveAddr+"/")
+	return serveUntilInterrupted(&http.Server{
+		Addr:        *serveAddr,
+		Handler:     p,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	})
+}
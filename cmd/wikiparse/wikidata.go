@@ -0,0 +1,104 @@
+// The wikidata command: the Wikidata items of articles, for entity
+// linking
+
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/audit"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+var wikidataFile = flag.String("wikidatafile", "", "(article, item) output file for the wikidata command (stdout if empty)")
+var pagePropsFile = flag.String("pagepropsfile", "", "page_props table of the wiki, like enwiki-latest-page_props.sql.gz, for the wikidata command (only templates if empty)")
+
+var itemPattern = regexp.MustCompile(`^Q[1-9][0-9]*$`)
+
+// loadWikidataItems reads the Wikidata items by page id from a page_props
+// dump, gzipped if its name ends in .gz.
+func loadWikidataItems(path string) (map[int64]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return dump.ReadWikidataItems(r)
+}
+
+// templateItem returns the Wikidata item given in a template of the
+// document, like {{Wikidata|Q42}} or a wikidata= or qid= parameter of an
+// infobox, or "" if there is none.
+func templateItem(doc *wikitext.Document) string {
+	for _, t := range wikitext.Templates(doc, 1) {
+		names := []string{"wikidata", "qid"}
+		if strings.EqualFold(strings.TrimSpace(t.Name), "wikidata") {
+			names = append(names, "1")
+		}
+		for _, name := range names {
+			if p, ok := t.Param(name); ok && itemPattern.MatchString(p.Value.Text) {
+				return p.Value.Text
+			}
+		}
+	}
+	return ""
+}
+
+// extractWikidataItems writes "article\titem\tsource" lines for every
+// article of the dump with a Wikidata item, taken from the page_props
+// table of -pagepropsfile by page id, or else from its templates, with
+// the source "pageprops" or "template". Redirects are collected into
+// redirects.
+func extractWikidataItems(r io.Reader, redirects *dump.RedirectTable, run *audit.Record) error {
+	items := map[int64]string{}
+	if *pagePropsFile != "" {
+		var err error
+		if items, err = loadWikidataItems(*pagePropsFile); err != nil {
+			return err
+		}
+	}
+	writer, finish, err := openOutput(*wikidataFile, "wikidata", run)
+	if err != nil {
+		return err
+	}
+
+	total, fromTemplates := 0, 0
+	for p := range dumpPages(r) {
+		if p.Redir.Title != "" {
+			redirects.Add(p.Title, p.Redir.Title)
+		}
+		title := dump.CanonicalizeTitle(p.Title)
+		if !isArticle(p) {
+			continue
+		}
+		item, source := items[p.ID], "pageprops"
+		if item == "" {
+			doc, _ := wikitext.Parse(p.Text, wikitext.WithSite(site))
+			item, source = templateItem(doc), "template"
+			if item == "" {
+				continue
+			}
+			fromTemplates++
+		}
+		writer.WriteString(title + "\t" + item + "\t" + source + "\n")
+		total++
+	}
+	err = finish()
+	logger.Info("Totals", "articles_with_items", total, "from_templates", fromTemplates)
+	return err
+}
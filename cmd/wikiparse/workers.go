@@ -0,0 +1,131 @@
+// Parsing and rendering the pages of a dump in -workers goroutines, in
+// the order of the dump
+
+package main
+
+import (
+	"io"
+	"iter"
+	"sync"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/dedup"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// workAhead is how many pages per worker are read ahead of the one the
+// loop over the results is at.
+const workAhead = 4
+
+// inParallel returns the pages with the results of f for them, in the
+// order of pages. With more than one of -workers, f runs in that many
+// goroutines at once, given the pages with their index; the pages are
+// read in a goroutine of their own, which has stopped once the loop
+// returns. The results are yielded in the order of the pages however the
+// workers are scheduled, waiting for a page whose result is late, so
+// that the outputs are the same as with one worker; order holds the
+// pages sent to the workers but not yet yielded, at most workAhead per
+// worker, as told by the metric of the worker queue depth.
+func inParallel[T any](pages iter.Seq[*dump.Page], f func(i int, p *dump.Page) T) iter.Seq2[*dump.Page, T] {
+	return func(yield func(*dump.Page, T) bool) {
+		if *workers <= 1 {
+			i := 0
+			for p := range pages {
+				if !yield(p, f(i, p)) {
+					return
+				}
+				i++
+			}
+			return
+		}
+		type job struct {
+			i      int
+			p      *dump.Page
+			result chan T
+		}
+		jobs := make(chan job)
+		order := make(chan job, workAhead**workers)
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for range *workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					j.result <- f(j.i, j.p)
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(order)
+			defer close(jobs)
+			i := 0
+			for p := range pages {
+				j := job{i, p, make(chan T, 1)}
+				select {
+				case order <- j:
+					workerQueueDepth.Add(1)
+				case <-done:
+					return
+				}
+				select {
+				case jobs <- j:
+				case <-done:
+					return
+				}
+				i++
+			}
+		}()
+		defer wg.Wait()
+		defer close(done)
+		for j := range order {
+			workerQueueDepth.Add(-1)
+			if !yield(j.p, <-j.result) {
+				return
+			}
+		}
+	}
+}
+
+// parsedPages returns the pages of the dump read from r with the
+// documents of the articles among them, parsed by parseArticle and run
+// through the processors of -pipelinefile in -workers goroutines; the
+// other pages have none, and their plain text is bounded by -maxchars,
+// -maxwords and -maxsentences and their categories stripped by -strip. The articles the processors drop are left
+// out, and so are the duplicates of -dedup with -dedupdrop.
+func parsedPages(r io.Reader) iter.Seq2[*dump.Page, *wikitext.Document] {
+	type parsed struct {
+		doc       *wikitext.Document
+		dropped   bool
+		signature *dedup.Signature // of -dedup, nil without it
+	}
+	pages := inParallel(dumpPages(r), func(_ int, p *dump.Page) parsed {
+		if !isArticle(p) {
+			return parsed{}
+		}
+		doc := parseArticle(p.Title, p.Text)
+		doc.Strip = stripList
+		if !process(p, doc) {
+			return parsed{doc, true, nil}
+		}
+		var signature *dedup.Signature
+		if duplicates != nil {
+			signature = duplicates.Sign(wikitext.PlainText(doc))
+		}
+		// The processors and -dedup see the whole text.
+		doc.Limit = textLimit()
+		return parsed{doc, false, signature}
+	})
+	return func(yield func(*dump.Page, *wikitext.Document) bool) {
+		for p, out := range pages {
+			if out.dropped || (out.signature != nil && !unique(p, out.signature)) {
+				continue
+			}
+			if !yield(p, out.doc) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// The category hierarchy, built from the category pages of a dump
+
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// CategoryName returns the canonical name of the category described by
+// the page with the given title, and false if it is not a category page.
+func CategoryName(title string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(title), wikitext.CategoryPrefix) {
+		return "", false
+	}
+	return CanonicalizeTitle(strings.TrimSpace(title[len(wikitext.CategoryPrefix):])), true
+}
+
+// A CategoryTree records the parent categories of each category. It is
+// not necessarily a tree, since categories can form cycles.
+type CategoryTree struct {
+	parents map[string][]string
+}
+
+// NewCategoryTree returns an empty tree.
+func NewCategoryTree() *CategoryTree {
+	return &CategoryTree{parents: make(map[string][]string)}
+}
+
+// Add records that the category is a subcategory of parent. Both are
+// canonical category names without the Category: prefix.
+func (t *CategoryTree) Add(category string, parent string) {
+	t.parents[category] = append(t.parents[category], parent)
+}
+
+// A CategoryAncestor is a category together with its distance from the
+// category whose ancestors were requested.
+type CategoryAncestor struct {
+	Name  string
+	Depth int
+}
+
+// Ancestors returns the category and all its ancestors up to maxDepth
+// levels, each at the shortest distance it is found at. The category
+// itself has depth 1, as an article is one level below its categories.
+func (t *CategoryTree) Ancestors(category string, maxDepth int) []CategoryAncestor {
+	seen := map[string]bool{category: true}
+	result := []CategoryAncestor{{category, 1}}
+	for i := 0; i < len(result); i++ {
+		a := result[i]
+		if a.Depth >= maxDepth {
+			continue
+		}
+		for _, p := range t.parents[a.Name] {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, CategoryAncestor{p, a.Depth + 1})
+			}
+		}
+	}
+	return result
+}
+
+// WriteTo writes the tree as tab separated lines "category\tparent",
+// sorted by category.
+func (t *CategoryTree) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]string, 0, len(t.parents))
+	for k := range t.parents {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	writer := bufio.NewWriter(w)
+	m, err := schema.WriteHeader(writer, "categorytree")
+	n := int64(m)
+	if err != nil {
+		return n, err
+	}
+	for _, k := range keys {
+		for _, p := range t.parents[k] {
+			m, err := fmt.Fprintf(writer, "%s\t%s\n", k, p)
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, writer.Flush()
+}
+
+// ReadCategoryTree reads a tree in the format written by WriteTo, of the
+// current or an earlier schema version.
+func ReadCategoryTree(r io.Reader) (*CategoryTree, error) {
+	t := NewCategoryTree()
+	scanner := schema.NewScanner(r)
+	for {
+		ok, err := scanner.Scan("categorytree")
+		if err != nil {
+			return nil, fmt.Errorf("category tree %v", err)
+		}
+		if !ok {
+			return t, nil
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("category tree line %d: expected 2 fields, got %d", scanner.Line, len(fields))
+		}
+		t.Add(fields[0], fields[1])
+	}
+}
@@ -0,0 +1,118 @@
+// The CirrusSearch dumps of Wikimedia, the documents of the search index
+// of a wiki as gzipped NDJSON, with the wikitext of the articles and the
+// plain text the wiki rendered from it
+
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"strconv"
+)
+
+// A cirrusDocument is a line of a CirrusSearch dump, which follows a line
+// like {"index": {"_type": "page", "_id": "662"}} of the bulk API of
+// Elasticsearch:
+//
+//	{"namespace": 0, "namespace_text": "", "title": "Apollo 11", "version": 1001,
+//	 "text": "Apollo 11 was ...", "source_text": "'''Apollo 11''' was ...",
+//	 "redirect": [{"namespace": 0, "title": "Apollo XI"}], ...}
+//
+// of which only the fields below are read.
+type cirrusDocument struct {
+	Index *struct {
+		ID string `json:"_id"`
+	} `json:"index"`
+	PageID        int64  `json:"page_id"`
+	Namespace     int    `json:"namespace"`
+	NamespaceText string `json:"namespace_text"`
+	Title         string `json:"title"`
+	Version       int64  `json:"version"`
+	ContentModel  string `json:"content_model"`
+	Text          string `json:"text"`
+	SourceText    string `json:"source_text"`
+	Redirect      []struct {
+		Namespace int    `json:"namespace"`
+		Title     string `json:"title"`
+	} `json:"redirect"`
+}
+
+// CirrusPages is like PagesContext for the CirrusSearch dump in r, gzipped
+// as published or not. Every document becomes a page with its wikitext as
+// Text and the plain text the wiki rendered as RenderedText, its id that
+// of the line before it, and its redirects become redirect pages before
+// it, as for EnterprisePages. The titles of pages of other namespaces get
+// the name of their namespace as the document has it, as do redirects in
+// the same namespace. As in XML dumps, the SHA1 of a page is that of its
+// text in base 36.
+func CirrusPages(ctx context.Context, r io.Reader) iter.Seq[*Page] {
+	return func(yield func(*Page) bool) {
+		buffered := bufio.NewReader(r)
+		var in io.Reader = buffered
+		if magic, _ := buffered.Peek(2); string(magic) == "\x1f\x8b" {
+			gz, err := gzip.NewReader(buffered)
+			if err != nil {
+				return
+			}
+			defer gz.Close()
+			in = gz
+		}
+		decoder := json.NewDecoder(in)
+		id := int64(0)
+		for ctx.Err() == nil {
+			var d cirrusDocument
+			if err := decoder.Decode(&d); err != nil {
+				// Like a malformed XML dump, a malformed line ends the
+				// pages.
+				return
+			}
+			if d.Index != nil {
+				id, _ = strconv.ParseInt(d.Index.ID, 10, 64)
+				continue
+			}
+			if d.PageID != 0 {
+				id = d.PageID
+			}
+			title := d.Title
+			if d.NamespaceText != "" {
+				title = d.NamespaceText + ":" + title
+			}
+			for _, redirect := range d.Redirect {
+				source := redirect.Title
+				if redirect.Namespace == d.Namespace && d.NamespaceText != "" {
+					source = d.NamespaceText + ":" + source
+				}
+				p := &Page{Title: source, Namespace: redirect.Namespace, Redir: Redirect{Title: title}}
+				if !yield(p) {
+					return
+				}
+			}
+			model := d.ContentModel
+			if model == "" {
+				model = ModelWikitext
+			}
+			p := &Page{
+				ID:           id,
+				Title:        title,
+				Namespace:    d.Namespace,
+				Text:         d.SourceText,
+				RenderedText: d.Text,
+				SHA1:         revisionSHA1(d.SourceText),
+				RevisionID:   d.Version,
+				Model:        model,
+				Format:       FormatWikitext,
+			}
+			if target, ok := RedirectTarget(p.Text, nil); ok {
+				p.Redir.Title = target
+			}
+			if !yield(p) {
+				return
+			}
+			id = 0
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// The HTML dumps of Wikimedia Enterprise, which hold a JSON object per
+// article and line, with its wikitext along with the HTML MediaWiki
+// renders from it
+
+package dump
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"iter"
+	"math/big"
+	"strings"
+)
+
+// An enterpriseArticle is a line of an Enterprise HTML dump, like
+//
+//	{"name": "Apollo 11", "identifier": 662, "namespace": {"identifier": 0},
+//	 "version": {"identifier": 1001}, "article_body": {"html": "...", "wikitext": "..."},
+//	 "redirects": [{"name": "Apollo XI"}], ...}
+//
+// of which only the fields below are read.
+type enterpriseArticle struct {
+	Name       string `json:"name"`
+	Identifier int64  `json:"identifier"`
+	Namespace  struct {
+		Identifier int `json:"identifier"`
+	} `json:"namespace"`
+	Version struct {
+		Identifier int64 `json:"identifier"`
+	} `json:"version"`
+	ArticleBody struct {
+		Wikitext string `json:"wikitext"`
+	} `json:"article_body"`
+	Redirects []struct {
+		Name string `json:"name"`
+	} `json:"redirects"`
+}
+
+// revisionSHA1 returns the checksum of a revision text as MediaWiki
+// writes it to the <sha1> elements of XML dumps: the SHA-1 in base 36,
+// padded to 31 digits.
+func revisionSHA1(text string) string {
+	sum := sha1.Sum([]byte(text))
+	n, _ := new(big.Int).SetString(hex.EncodeToString(sum[:]), 16)
+	s := n.Text(36)
+	return strings.Repeat("0", max(31-len(s), 0)) + s
+}
+
+// enterpriseFiles returns the readers of the NDJSON files in r: those of
+// the tar archive if r is gzipped, as Enterprise dumps are published, or
+// r itself.
+func enterpriseFiles(r io.Reader) iter.Seq[io.Reader] {
+	return func(yield func(io.Reader) bool) {
+		buffered := bufio.NewReader(r)
+		if magic, _ := buffered.Peek(2); string(magic) != "\x1f\x8b" {
+			yield(buffered)
+			return
+		}
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return
+		}
+		defer gz.Close()
+		archive := tar.NewReader(gz)
+		for {
+			header, err := archive.Next()
+			if err != nil {
+				return
+			}
+			if header.Typeflag == tar.TypeReg && !yield(archive) {
+				return
+			}
+		}
+	}
+}
+
+// EnterprisePages is like PagesContext for the Enterprise HTML dump in r,
+// either a gzipped tar archive of NDJSON files or a single NDJSON file.
+// Every article becomes a page with its wikitext, and its redirects,
+// which these dumps list with their target instead of as pages of their
+// own, become redirect pages before it. As in XML dumps, the SHA1 of a
+// page is that of its text in base 36. The HTML is not read.
+func EnterprisePages(ctx context.Context, r io.Reader) iter.Seq[*Page] {
+	return func(yield func(*Page) bool) {
+		for file := range enterpriseFiles(r) {
+			decoder := json.NewDecoder(file)
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				var a enterpriseArticle
+				if err := decoder.Decode(&a); err != nil {
+					// Like a malformed XML dump, a malformed file ends the
+					// pages read from it.
+					break
+				}
+				for _, redirect := range a.Redirects {
+					p := &Page{Title: redirect.Name, Namespace: a.Namespace.Identifier, Redir: Redirect{Title: a.Name}}
+					if !yield(p) {
+						return
+					}
+				}
+				p := &Page{
+					ID:         a.Identifier,
+					Title:      a.Name,
+					Namespace:  a.Namespace.Identifier,
+					Text:       a.ArticleBody.Wikitext,
+					SHA1:       revisionSHA1(a.ArticleBody.Wikitext),
+					RevisionID: a.Version.Identifier,
+					Model:      ModelWikitext,
+					Format:     FormatWikitext,
+				}
+				if target, ok := RedirectTarget(p.Text, nil); ok {
+					p.Redir.Title = target
+				}
+				if !yield(p) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Reading of the revisions of pages in full-history dumps, like
+// enwiki-latest-pages-meta-history1.xml
+
+package dump
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"iter"
+	"time"
+)
+
+// A Contributor is the author of a revision: a user with name and id,
+// or an anonymous editor by IP address. Both are empty if the dump
+// hides them.
+type Contributor struct {
+	Username string `xml:"username"`
+	ID       int64  `xml:"id"`
+	IP       string `xml:"ip"`
+}
+
+// Name returns the user name of the contributor, or the IP address of
+// an anonymous one.
+func (c Contributor) Name() string {
+	if c.Username != "" {
+		return c.Username
+	}
+	return c.IP
+}
+
+// A Revision is a version of a page in a history dump.
+type Revision struct {
+	ID          int64
+	ParentID    int64 // the previous revision, 0 for the first
+	Timestamp   time.Time
+	Contributor Contributor
+	Comment     string
+	Minor       bool
+	SHA1        string
+	Text        string
+}
+
+type xmlRevision struct {
+	ID          int64       `xml:"id"`
+	ParentID    int64       `xml:"parentid"`
+	Timestamp   string      `xml:"timestamp"`
+	Contributor Contributor `xml:"contributor"`
+	Comment     string      `xml:"comment"`
+	Minor       *struct{}   `xml:"minor"`
+	SHA1        string      `xml:"sha1"`
+	Text        string      `xml:"text"`
+}
+
+// Revisions returns an iterator over the revisions of the pages of the
+// history dump in r, in the order of the dump, which is oldest first
+// within a page, as in
+//
+//	for p, rev := range dump.Revisions(f) {
+//		...
+//	}
+//
+// Unlike Pages, it reads one revision into memory at a time, since pages
+// can have hundreds of thousands. The Page is the same for all revisions
+// of a page; its RevisionID, Text and SHA1 are those of the revision,
+// and its Redir is the redirect of the latest revision.
+func Revisions(r io.Reader) iter.Seq2[*Page, *Revision] {
+	return RevisionsContext(context.Background(), r)
+}
+
+// RevisionsContext is like Revisions, but the iteration stops before the
+// next revision once ctx is cancelled.
+func RevisionsContext(ctx context.Context, r io.Reader) iter.Seq2[*Page, *Revision] {
+	return func(yield func(*Page, *Revision) bool) {
+		decoder := xml.NewDecoder(r)
+		var page *Page
+		for {
+			t, _ := decoder.Token()
+			if t == nil {
+				break
+			}
+			se, ok := t.(xml.StartElement)
+			if !ok {
+				continue
+			}
+			if se.Name.Local == "page" {
+				page = &Page{}
+				continue
+			}
+			if page == nil {
+				continue
+			}
+			switch se.Name.Local {
+			case "title":
+				decoder.DecodeElement(&page.Title, &se)
+			case "ns":
+				decoder.DecodeElement(&page.Namespace, &se)
+			case "id":
+				decoder.DecodeElement(&page.ID, &se)
+			case "redirect":
+				decoder.DecodeElement(&page.Redir, &se)
+			case "revision":
+				if ctx.Err() != nil {
+					return
+				}
+				var x xmlRevision
+				decoder.DecodeElement(&x, &se)
+				// Timestamps are like "2001-01-15T13:15:00Z".
+				timestamp, _ := time.Parse(time.RFC3339, x.Timestamp)
+				rev := &Revision{x.ID, x.ParentID, timestamp, x.Contributor, x.Comment, x.Minor != nil, x.SHA1, x.Text}
+				page.RevisionID, page.Text, page.SHA1 = rev.ID, rev.Text, rev.SHA1
+				if !yield(page, rev) {
+					return
+				}
+			}
+		}
+	}
+}
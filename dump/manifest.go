@@ -0,0 +1,142 @@
+// Manifests of the page revisions a run has written outputs for
+
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+)
+
+// A Manifest records the revision, by its sha1, of every page a run has
+// written output for and the name of the file it was written to, so that
+// a run on a newer dump can skip the pages that did not change and keep
+// their previous outputs. Pages are recorded by their exact title, so
+// that titles differing only in case, like "AIDS" and "Aids", are told
+// apart.
+type Manifest struct {
+	// Key identifies the settings and code version the outputs were
+	// written with; outputs are only reusable under the same Key.
+	Key     string
+	entries map[string]manifestEntry
+}
+
+// A manifestEntry is the revision of a page and the name of its file.
+type manifestEntry struct {
+	sha1 string
+	name string
+}
+
+// NewManifest creates an empty manifest for outputs written with key.
+func NewManifest(key string) *Manifest {
+	return &Manifest{Key: key, entries: make(map[string]manifestEntry)}
+}
+
+// Add records the revision of the page with the given exact title and
+// the name of the file written for it.
+func (m *Manifest) Add(title string, sha1 string, name string) {
+	m.entries[title] = manifestEntry{sha1, name}
+}
+
+// Name returns the name of the file written for the page, or "" if the
+// manifest does not record it.
+func (m *Manifest) Name(title string) string {
+	return m.entries[title].name
+}
+
+// Names returns the pages of the manifest by title with the names of
+// their files, in no particular order.
+func (m *Manifest) Names() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for title, e := range m.entries {
+			if !yield(title, e.name) {
+				return
+			}
+		}
+	}
+}
+
+// Has reports whether the manifest records a revision of the page.
+func (m *Manifest) Has(title string) bool {
+	_, ok := m.entries[title]
+	return ok
+}
+
+// Remove removes the page from the manifest.
+func (m *Manifest) Remove(title string) {
+	delete(m.entries, title)
+}
+
+// Len returns the number of pages in the manifest.
+func (m *Manifest) Len() int {
+	return len(m.entries)
+}
+
+// Unchanged reports whether the manifest records the revision sha1 for
+// the page. Pages without a sha1 in the dump are never unchanged.
+func (m *Manifest) Unchanged(title string, sha1 string) bool {
+	return sha1 != "" && m.entries[title].sha1 == sha1
+}
+
+// WriteTo writes the manifest as tab separated lines
+// "title\tsha1\tname", sorted by title, after a line "#key\t<key>".
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	titles := make([]string, 0, len(m.entries))
+	for t := range m.entries {
+		titles = append(titles, t)
+	}
+	sort.Strings(titles)
+	writer := bufio.NewWriter(w)
+	k, err := schema.WriteHeader(writer, "manifest")
+	n := int64(k)
+	if err != nil {
+		return n, err
+	}
+	k, err = fmt.Fprintf(writer, "#key\t%s\n", m.Key)
+	n += int64(k)
+	if err != nil {
+		return n, err
+	}
+	for _, t := range titles {
+		e := m.entries[t]
+		k, err := fmt.Fprintf(writer, "%s\t%s\t%s\n", t, e.sha1, e.name)
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, writer.Flush()
+}
+
+// ReadManifest reads a manifest in the format written by WriteTo. Lines
+// of manifests written before the file names were recorded have no name.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	m := NewManifest("")
+	scanner := schema.NewScanner(r)
+	for {
+		ok, err := scanner.Scan("manifest")
+		if err != nil {
+			return nil, fmt.Errorf("manifest %v", err)
+		}
+		if !ok {
+			return m, nil
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if fields[0] == "#key" && len(fields) == 2 {
+			m.Key = fields[1]
+			continue
+		}
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("manifest line %d: expected 3 fields, got %d", scanner.Line, len(fields))
+		}
+		m.entries[fields[0]] = manifestEntry{sha1: fields[1]}
+		if len(fields) == 3 {
+			m.entries[fields[0]] = manifestEntry{fields[1], fields[2]}
+		}
+	}
+}
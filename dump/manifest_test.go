@@ -0,0 +1,37 @@
+package dump
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestManifestCaseDistinctTitles(t *testing.T) {
+	m := NewManifest("key")
+	m.Add("AIDS", "sha-disease", "aids")
+	m.Add("Aids", "sha-band", "aids~bb1847ad")
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadManifest(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		title, sha1, name string
+	}{
+		{"AIDS", "sha-disease", "aids"},
+		{"Aids", "sha-band", "aids~bb1847ad"},
+	}
+	if read.Key != "key" || read.Len() != len(tests) {
+		t.Fatalf("read key %q and %d pages, want %q and %d", read.Key, read.Len(), "key", len(tests))
+	}
+	for _, tt := range tests {
+		if !read.Unchanged(tt.title, tt.sha1) {
+			t.Errorf("Unchanged(%q, %q) = false", tt.title, tt.sha1)
+		}
+		if got := read.Name(tt.title); got != tt.name {
+			t.Errorf("Name(%q) = %q, want %q", tt.title, got, tt.name)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Reading the pages of an uncompressed XML dump mapped into memory, with
+// their texts taken from the mapping instead of copied
+
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+var (
+	pageStart = []byte("<page>")
+	pageEnd   = []byte("</page>")
+	textStart = []byte("<text")
+	textEnd   = []byte("</text>")
+)
+
+// NewMappedReader returns a Reader of the XML dump in data, like a dump
+// file mapped into memory, for MappedPages.
+func NewMappedReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// MappedPages is like PageOffsets for the dump of a Reader made by
+// NewMappedReader, but yields the offset in data of the end of every
+// page instead of its start. The text of a page without character
+// references, like "&lt;", is not copied from data but refers to it, and
+// those of other pages are copied only once, as they are unescaped, so
+// the pages and the strings taken from their texts must not be used once
+// data is changed or unmapped. The other fields are decoded as by
+// PageOffsets.
+func (d *Reader) MappedPages(ctx context.Context) iter.Seq2[int64, *Page] {
+	return func(yield func(int64, *Page) bool) {
+		data := d.data
+		pos := 0
+		var c compat
+		if first := bytes.Index(data, pageStart); first > 0 {
+			c.header(data[:first])
+		}
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			// Markup in the text is escaped, so the first "<page>" and
+			// "</page>" are the tags of the next page.
+			start := bytes.Index(data[pos:], pageStart)
+			if start < 0 {
+				return
+			}
+			start += pos
+			end := bytes.Index(data[start:], pageEnd)
+			if end < 0 {
+				d.err = fmt.Errorf("page at dump offset %d: no </page>, the dump is truncated", start)
+				return
+			}
+			end += start + len(pageEnd)
+			p, err := decodeMappedPage(data[start:end])
+			if err != nil {
+				d.err = fmt.Errorf("page at dump offset %d: %w", start, err)
+				return
+			}
+			c.complete(p)
+			pos = end
+			if !yield(int64(end), p) {
+				return
+			}
+		}
+	}
+}
+
+// decodeMappedPage decodes the <page> element in b, decoding its text
+// itself and the rest of it with encoding/xml.
+func decodeMappedPage(b []byte) (*Page, error) {
+	var p Page
+	content, from, to := textContent(b)
+	if content == nil {
+		// No text, or one encoding/xml is left to decode.
+		if err := xml.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+	} else {
+		text, ok := unescapeText(content)
+		if !ok {
+			if err := xml.Unmarshal(b, &p); err != nil {
+				return nil, err
+			}
+		} else {
+			rest := make([]byte, 0, len(b)-(to-from))
+			rest = append(append(rest, b[:from]...), b[to:]...)
+			if err := xml.Unmarshal(rest, &p); err != nil {
+				return nil, err
+			}
+			p.Text = text
+		}
+	}
+	if p.Redir.Title == "" {
+		if target, ok := RedirectTarget(p.Text, nil); ok {
+			p.Redir.Title = target
+		}
+	}
+	return &p, nil
+}
+
+// textContent returns the content of the <text> element of the page in b
+// and where it starts and ends in b, or nil if the page has none or its
+// text is empty.
+func textContent(b []byte) ([]byte, int, int) {
+	for i := 0; ; {
+		start := bytes.Index(b[i:], textStart)
+		if start < 0 {
+			return nil, 0, 0
+		}
+		start += i
+		i = start + len(textStart)
+		if i >= len(b) || b[i] != ' ' && b[i] != '>' && b[i] != '/' {
+			// Another element, like <textfoo>.
+			continue
+		}
+		tag := bytes.IndexByte(b[i:], '>')
+		if tag < 0 || b[i+tag-1] == '/' {
+			return nil, 0, 0
+		}
+		from := i + tag + 1
+		to := bytes.Index(b[from:], textEnd)
+		if to <= 0 {
+			return nil, 0, 0
+		}
+		return b[from : from+to], from, from + to
+	}
+}
+
+// unescapeText returns the text of the content of a <text> element: the
+// content itself if it has no character references, or else a copy with
+// them replaced. It returns false for content encoding/xml is left to
+// decode, with markup like CDATA sections, carriage returns or references
+// it does not know.
+func unescapeText(content []byte) (string, bool) {
+	if bytes.ContainsAny(content, "<\r") {
+		return "", false
+	}
+	amp := bytes.IndexByte(content, '&')
+	if amp < 0 {
+		return unsafe.String(&content[0], len(content)), true
+	}
+	var text strings.Builder
+	text.Grow(len(content))
+	for amp >= 0 {
+		text.Write(content[:amp])
+		content = content[amp:]
+		semicolon := bytes.IndexByte(content, ';')
+		if semicolon < 0 {
+			return "", false
+		}
+		r, ok := characterReference(string(content[1:semicolon]))
+		if !ok {
+			return "", false
+		}
+		text.WriteRune(r)
+		content = content[semicolon+1:]
+		amp = bytes.IndexByte(content, '&')
+	}
+	text.Write(content)
+	return text.String(), true
+}
+
+// characterReference returns the character of a reference without its
+// "&" and ";": one of the entities predefined by XML or a character
+// number.
+func characterReference(name string) (rune, bool) {
+	switch name {
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "amp":
+		return '&', true
+	case "quot":
+		return '"', true
+	case "apos":
+		return '\'', true
+	}
+	digits, found := strings.CutPrefix(name, "#x")
+	radix := 16
+	if !found {
+		digits, found = strings.CutPrefix(name, "#")
+		radix = 10
+	}
+	if !found || digits == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(digits, radix, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+	return rune(n), true
+}
@@ -0,0 +1,240 @@
+// This streaming XML parser is from http://blog.davidsingleton.org/parsing-huge-xml-files-with-go/
+
+// Package dump reads Wikipedia XML dumps, the HTML dumps of Wikimedia
+// Enterprise and CirrusSearch dumps page by page and builds the tables
+// derived from whole dumps, like redirects and the category hierarchy.
+package dump
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Here is an example article from the Wikipedia XML dump
+//
+// <page>
+// 	<title>Apollo 11</title>
+//      <redirect title="Foo bar" />
+// 	...
+// 	<revision>
+// 	...
+// 	  <text xml:space="preserve">
+// 	  {{Infobox Space mission
+// 	  |mission_name=&lt;!--See above--&gt;
+// 	  |insignia=Apollo_11_insignia.png
+// 	...
+// 	  </text>
+// 	</revision>
+// </page>
+//
+// Note how the tags on the fields of Page and Redirect below
+// describe the XML schema structure.
+
+type Redirect struct {
+	Title string `xml:"title,attr"`
+}
+
+type Page struct {
+	ID         int64    `xml:"id"`
+	Title      string   `xml:"title"`
+	Namespace  int      `xml:"ns"` // the namespace number, 0 for articles
+	Redir      Redirect `xml:"redirect"`
+	Text       string   `xml:"revision>text"`
+	SHA1       string   `xml:"revision>sha1"` // the checksum of the revision text
+	RevisionID int64    `xml:"revision>id"`
+	Model      string   `xml:"revision>model"`  // the content model of the text, like "wikitext"
+	Format     string   `xml:"revision>format"` // its serialization format, like "text/x-wiki"
+
+	// RenderedText is the plain text the wiki rendered from Text, of the
+	// pages of CirrusSearch dumps; empty for those of other dumps.
+	RenderedText string `xml:"-"`
+
+	// Annotations are the values the processors of a pipeline set on the
+	// page, like its language, by name; nil until one does.
+	Annotations map[string]any `xml:"-"`
+}
+
+// Annotate sets the annotation of the name to the value.
+func (p *Page) Annotate(name string, value any) {
+	if p.Annotations == nil {
+		p.Annotations = make(map[string]any)
+	}
+	p.Annotations[name] = value
+}
+
+// Permalink returns the URL of the revision of the page on the wiki at
+// base, like "https://en.wikipedia.org", followed by the anchor of a
+// section if it is not empty. Without a revision id, the URL is that of
+// the current page.
+func (p *Page) Permalink(base string, anchor string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	if p.RevisionID != 0 {
+		u = u.JoinPath("w", "index.php")
+		u.RawQuery = "oldid=" + strconv.FormatInt(p.RevisionID, 10)
+	} else {
+		u = u.JoinPath("wiki", strings.ReplaceAll(p.Title, " ", "_"))
+	}
+	u.Fragment = anchor
+	return u.String()
+}
+
+func CanonicalizeTitle(title string) string {
+	can := strings.ToLower(title)
+	can = strings.Replace(can, " ", "_", -1)
+	can = url.QueryEscape(can)
+	return can
+}
+
+// Pages returns an iterator over the pages of the dump in r, to be used
+// as in
+//
+//	for p := range dump.Pages(f) {
+//		...
+//	}
+//
+// Redirects given as "#REDIRECT [[Target]]" in the text are recorded in
+// the Redir field like those given by a <redirect> element; RedirectTarget
+// also finds those given by the aliases of #REDIRECT of a wiki. Pages of
+// dumps of schema versions before 0.8 get the fields they lack: their
+// namespace by the prefix of the title, the SHA1 of their text and the
+// wikitext content model.
+func Pages(r io.Reader) iter.Seq[*Page] {
+	return PagesContext(context.Background(), r)
+}
+
+// PagesContext is like Pages, but the iteration stops before the next
+// page once ctx is cancelled; callers tell an interrupted iteration from
+// the end of the dump by ctx.Err. Like Pages, it ends at the first error
+// reading the dump without reporting it; a Reader reports it.
+func PagesContext(ctx context.Context, r io.Reader) iter.Seq[*Page] {
+	return NewReader(r).Pages(ctx)
+}
+
+// A Reader reads the pages of the XML dump in r. Its iterations end at
+// the first error reading or decoding the XML, which Err returns then, so
+// that a truncated or corrupt dump is told apart from a complete one.
+type Reader struct {
+	r    io.Reader
+	data []byte // the dump of NewMappedReader
+	err  error
+}
+
+// NewReader returns a Reader of the dump in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Err returns the error that ended the pages read, nil at the end of the
+// dump.
+func (d *Reader) Err() error {
+	return d.err
+}
+
+// Pages is like PagesContext, but records the error ending the pages for
+// Err.
+func (d *Reader) Pages(ctx context.Context) iter.Seq[*Page] {
+	return func(yield func(*Page) bool) {
+		for _, p := range d.PageOffsets(ctx) {
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+// PageOffsets is like Pages, but also yields the byte offset in the dump
+// of the <page> element of every page, from which ReadPageAt reads the
+// page again.
+func (d *Reader) PageOffsets(ctx context.Context) iter.Seq2[int64, *Page] {
+	return func(yield func(int64, *Page) bool) {
+		decoder := xml.NewDecoder(d.r)
+		var c compat
+		for {
+			// Read tokens from the XML document in a stream.
+			offset := decoder.InputOffset()
+			t, err := decoder.Token()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				d.err = fmt.Errorf("dump offset %d: %w", decoder.InputOffset(), err)
+				return
+			}
+			se, ok := t.(xml.StartElement)
+			if !ok {
+				continue
+			}
+			if se.Name.Local != "page" {
+				if err := c.start(decoder, &se); err != nil {
+					d.err = fmt.Errorf("dump offset %d: %w", offset, err)
+					return
+				}
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			p, err := decodePage(decoder, &se)
+			if err != nil {
+				d.err = fmt.Errorf("page at dump offset %d: %w", offset, err)
+				return
+			}
+			c.complete(p)
+			if !yield(offset, p) {
+				return
+			}
+		}
+	}
+}
+
+// decodePage decodes the page whose <page> element the decoder just read.
+func decodePage(decoder *xml.Decoder, start *xml.StartElement) (*Page, error) {
+	var p Page
+	if err := decoder.DecodeElement(&p, start); err != nil {
+		return nil, err
+	}
+	if p.Redir.Title == "" {
+		if target, ok := RedirectTarget(p.Text, nil); ok {
+			p.Redir.Title = target
+		}
+	}
+	return &p, nil
+}
+
+// ReadPageAt reads the page whose <page> element starts at the offset in
+// r, as given by Reader.PageOffsets for an uncompressed dump. Pages of dumps
+// before schema version 0.6 keep namespace 0, as the names of the
+// namespaces are not read.
+func ReadPageAt(r io.ReaderAt, offset int64) (*Page, error) {
+	decoder := xml.NewDecoder(io.NewSectionReader(r, offset, math.MaxInt64-offset))
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if se, ok := t.(xml.StartElement); ok && se.Name.Local == "page" {
+		p, err := decodePage(decoder, &se)
+		if err != nil {
+			return nil, err
+		}
+		new(compat).complete(p)
+		return p, nil
+	}
+	return nil, fmt.Errorf("no page at offset %d", offset)
+}
+
+// ReadPages streams the pages of the dump in r and calls fn for each of
+// them, like ranging over Pages.
+func ReadPages(r io.Reader, fn func(p *Page)) {
+	for p := range Pages(r) {
+		fn(p)
+	}
+}
@@ -0,0 +1,50 @@
+package dump
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReaderErr(t *testing.T) {
+	data, err := os.ReadFile("../testdata/minidump.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := string(data)
+	truncated := whole[:strings.Index(whole, "</page>")+len("</page>")+200]
+	tests := []struct {
+		name    string
+		dump    string
+		wantErr bool
+	}{
+		{"complete", whole, false},
+		{"truncated", truncated, true},
+		{"corrupt", strings.Replace(whole, "</title>", "</titel>", 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readers := map[string]*Reader{
+				"xml":    NewReader(strings.NewReader(tt.dump)),
+				"mapped": NewMappedReader([]byte(tt.dump)),
+			}
+			for kind, d := range readers {
+				n := 0
+				pages := d.PageOffsets(context.Background())
+				if kind == "mapped" {
+					pages = d.MappedPages(context.Background())
+				}
+				for range pages {
+					n++
+				}
+				if gotErr := d.Err() != nil; gotErr != tt.wantErr {
+					t.Errorf("%s: Err() = %v after %d pages, want an error: %t", kind, d.Err(), n, tt.wantErr)
+				}
+				if !tt.wantErr && n == 0 {
+					t.Errorf("%s: no pages read", kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Detection of redirect pages and a table to resolve them
+
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+)
+
+// Redirects can be chained, but MediaWiki itself only follows a single
+// hop. We follow a few more to canonicalize double redirects.
+const maxRedirectHops = 5
+
+// redirectLink matches the link to the target of a redirect after its
+// magic word, like " [[Target]]" or ": [[Target|label]]".
+var redirectLink = regexp.MustCompile(`^\s*:?\s*\[\[([^\]|]+)`)
+
+// RedirectTarget returns the target of a page whose text starts with
+// "#REDIRECT [[Target]]", or with one of the aliases of #REDIRECT of its
+// wiki instead, like "#WEITERLEITUNG [[Ziel]]" on the German Wikipedia.
+// The magic words are matched in any case. Section links are reduced to
+// their page.
+func RedirectTarget(text string, aliases []string) (string, bool) {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	if target, ok := redirectAfter(text, "#REDIRECT"); ok {
+		return target, true
+	}
+	for _, word := range aliases {
+		if target, ok := redirectAfter(text, word); ok {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// redirectAfter returns the target of the redirect if the text starts
+// with the magic word and a link.
+func redirectAfter(text string, word string) (string, bool) {
+	if len(text) < len(word) || !strings.EqualFold(text[:len(word)], word) {
+		return "", false
+	}
+	m := redirectLink.FindStringSubmatch(text[len(word):])
+	if m == nil {
+		return "", false
+	}
+	target := m[1]
+	if i := strings.Index(target, "#"); i >= 0 {
+		target = target[:i]
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", false
+	}
+	return target, true
+}
+
+// A RedirectTable maps canonical titles of redirect pages to the
+// canonical title of their target.
+type RedirectTable struct {
+	targets map[string]string
+	site    *SiteInfo // of the case rules of the titles, if not nil
+}
+
+// NewRedirectTable returns an empty table.
+func NewRedirectTable() *RedirectTable {
+	return &RedirectTable{targets: make(map[string]string)}
+}
+
+// UseSite makes the table canonicalize titles as those of the wiki of
+// info, by its case rules, instead of as by CanonicalizeTitle.
+func (t *RedirectTable) UseSite(info *SiteInfo) {
+	t.site = info
+}
+
+// Add records that the page from redirects to the page to.
+func (t *RedirectTable) Add(from string, to string) {
+	t.targets[t.site.CanonicalizeTitle(from)] = t.site.CanonicalizeTitle(to)
+}
+
+// Remove removes the redirect of the page from, if it is one.
+func (t *RedirectTable) Remove(from string) {
+	delete(t.targets, t.site.CanonicalizeTitle(from))
+}
+
+// Len returns the number of redirects in the table.
+func (t *RedirectTable) Len() int {
+	return len(t.targets)
+}
+
+// ResolveRedirect returns the canonical title of the page that title
+// eventually redirects to, or the canonical title itself if it is not a
+// redirect. Cycles and overly long chains stop at the last title seen.
+func (t *RedirectTable) ResolveRedirect(title string) string {
+	can := t.site.CanonicalizeTitle(title)
+	for i := 0; i < maxRedirectHops; i++ {
+		next, ok := t.targets[can]
+		if !ok || next == can {
+			break
+		}
+		can = next
+	}
+	return can
+}
+
+// WriteTo writes the table as tab separated lines "from\tto", sorted by
+// source title.
+func (t *RedirectTable) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]string, 0, len(t.targets))
+	for k := range t.targets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	writer := bufio.NewWriter(w)
+	m, err := schema.WriteHeader(writer, "redirects")
+	n := int64(m)
+	if err != nil {
+		return n, err
+	}
+	for _, k := range keys {
+		m, err := fmt.Fprintf(writer, "%s\t%s\n", k, t.targets[k])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, writer.Flush()
+}
+
+// ReadRedirectTable reads a table in the format written by WriteTo, of
+// the current or an earlier schema version.
+func ReadRedirectTable(r io.Reader) (*RedirectTable, error) {
+	t := NewRedirectTable()
+	scanner := schema.NewScanner(r)
+	for {
+		ok, err := scanner.Scan("redirects")
+		if err != nil {
+			return nil, fmt.Errorf("redirect table %v", err)
+		}
+		if !ok {
+			return t, nil
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("redirect table line %d: expected 2 fields, got %d", scanner.Line, len(fields))
+		}
+		t.targets[fields[0]] = fields[1]
+	}
+}
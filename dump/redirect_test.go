@@ -0,0 +1,35 @@
+package dump
+
+import "testing"
+
+func TestRedirectTarget(t *testing.T) {
+	german := []string{"#WEITERLEITUNG"}
+	french := []string{"#REDIRECTION"}
+	tests := []struct {
+		text    string
+		aliases []string
+		want    string // the target, "" for no redirect
+	}{
+		{"#REDIRECT [[Apollo 11]]", nil, "Apollo 11"},
+		{"  #redirect:[[Apollo 11#Landing|the landing]]\n{{R from move}}", nil, "Apollo 11"},
+		{"#REDIRECT [[#Landing]]", nil, ""},
+		{"#REDIRECT Apollo 11", nil, ""},
+		{"See #REDIRECT [[Apollo 11]]", nil, ""},
+		{"#WEITERLEITUNG [[Mond]]", nil, ""},
+		{"#WEITERLEITUNG [[Mond]]", german, "Mond"},
+		{"\n#weiterleitung [[Mond#Umlauf]]", german, "Mond"},
+		{"#REDIRECT [[Moon]]", german, "Moon"},
+		{"#WEITERLEITUNGEN [[Mond]]", german, ""},
+		// #REDIRECTION starts with #REDIRECT, which does not match.
+		{"#REDIRECTION [[Lune]]", french, "Lune"},
+		{"#REDIRECTION [[Lune]]", nil, ""},
+		{"#ПЕРЕНАПРАВЛЕНИЕ [[Луна]]", []string{"#перенаправление"}, "Луна"},
+		{"#WEI", german, ""},
+	}
+	for _, tt := range tests {
+		target, ok := RedirectTarget(tt.text, tt.aliases)
+		if target != tt.want || ok != (tt.want != "") {
+			t.Errorf("RedirectTarget(%q, %q) = %q, %v, want %q", tt.text, tt.aliases, target, ok, tt.want)
+		}
+	}
+}
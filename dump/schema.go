@@ -0,0 +1,126 @@
+// The versions of the XML schema of the dumps, and the fields the pages
+// of older ones lack
+
+package dump
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// The schema versions whose dumps are read, from the first with a
+// <siteinfo> to the current one. Pages of versions before 0.8 lack fields
+// of the later ones, which are completed as told by Page.
+const (
+	OldestSchema = "0.3"
+	NewestSchema = "0.11"
+)
+
+// The content model and format of the text of pages of dumps before 0.8,
+// which were all wikitext.
+const (
+	ModelWikitext  = "wikitext"
+	FormatWikitext = "text/x-wiki"
+)
+
+// schemaMinor returns the minor number of a schema version, like 10 for
+// "0.10", or 0 if it is not one of a 0.x version.
+func schemaMinor(version string) int {
+	minor, ok := strings.CutPrefix(version, "0.")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(minor)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// KnownSchema reports whether the schema version, as given by
+// SiteInfo.SchemaVersion, is one from OldestSchema to NewestSchema.
+func KnownSchema(version string) bool {
+	n := schemaMinor(version)
+	return n >= schemaMinor(OldestSchema) && n <= schemaMinor(NewestSchema)
+}
+
+// rootSchema returns the schema version of the <mediawiki> element of a
+// dump: that of its version attribute, or else of its namespace, like
+// "http://www.mediawiki.org/xml/export-0.10/".
+func rootSchema(root *xml.StartElement) string {
+	for _, a := range root.Attr {
+		if a.Name.Local == "version" {
+			return a.Value
+		}
+	}
+	_, version, _ := strings.Cut(strings.TrimSuffix(root.Name.Space, "/"), "/export-")
+	return version
+}
+
+// A compat completes the pages of a dump of an older schema version as
+// they are read, with what the start of the dump tells.
+type compat struct {
+	minor      int            // of the schema version, 0 if unknown
+	namespaces map[string]int // by lower case name, for versions without <ns>
+}
+
+// start takes in an element that is no page, which decoder just read the
+// start of: the <mediawiki> element with the schema version, or the
+// <siteinfo> with the namespaces of the titles of pages without <ns>.
+func (c *compat) start(decoder *xml.Decoder, se *xml.StartElement) error {
+	switch se.Name.Local {
+	case "mediawiki":
+		c.minor = schemaMinor(rootSchema(se))
+	case "siteinfo":
+		if c.minor == 0 || c.minor >= 6 {
+			return nil
+		}
+		var info SiteInfo
+		if err := decoder.DecodeElement(&info, se); err != nil {
+			return err
+		}
+		c.namespaces = make(map[string]int, len(info.Namespaces))
+		for _, ns := range info.Namespaces {
+			if ns.Name != "" {
+				c.namespaces[strings.ToLower(ns.Name)] = ns.Key
+			}
+		}
+	}
+	return nil
+}
+
+// header takes in the elements of the start of a dump before its first
+// page.
+func (c *compat) header(b []byte) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			c.start(decoder, &se)
+		}
+	}
+}
+
+// complete adds the fields the page lacks in the schema of the dump: the
+// namespace of the page by the prefix of its title before 0.6, the SHA1
+// of its text before 0.7 and its content model and format before 0.8.
+// Redirects without the target in a <redirect> element, as before 0.6,
+// are found in the text of the page, as in all dumps.
+func (c *compat) complete(p *Page) {
+	if c.namespaces != nil && p.Namespace == 0 {
+		if prefix, _, ok := strings.Cut(p.Title, ":"); ok {
+			p.Namespace = c.namespaces[strings.ToLower(prefix)]
+		}
+	}
+	if p.SHA1 == "" && p.Text != "" {
+		p.SHA1 = revisionSHA1(p.Text)
+	}
+	if p.Model == "" {
+		p.Model, p.Format = ModelWikitext, FormatWikitext
+	}
+}
@@ -0,0 +1,135 @@
+// The site information at the start of a dump, like the names of the
+// namespaces of the wiki and the case rule of its titles
+
+package dump
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// A SiteInfo is the <siteinfo> element of a dump:
+//
+//	<siteinfo>
+//	  <sitename>Wikipedia</sitename>
+//	  <dbname>dewiki</dbname>
+//	  <base>https://de.wikipedia.org/wiki/Wikipedia:Hauptseite</base>
+//	  <generator>MediaWiki 1.41.0-wmf.4</generator>
+//	  <case>first-letter</case>
+//	  <namespaces>
+//	    <namespace key="14" case="first-letter">Kategorie</namespace>
+//	    ...
+type SiteInfo struct {
+	SiteName   string      `xml:"sitename"`
+	DBName     string      `xml:"dbname"`    // like "dewiki"
+	Base       string      `xml:"base"`      // the URL of the main page
+	Generator  string      `xml:"generator"` // the MediaWiki version
+	Case       string      `xml:"case"`      // the case rule of titles, CaseFirstLetter if empty
+	Namespaces []Namespace `xml:"namespaces>namespace"`
+
+	// SchemaVersion is that of the export schema of the dump, like "0.10",
+	// from its <mediawiki> element, empty if it has none.
+	SchemaVersion string `xml:"-"`
+}
+
+// A Namespace is a namespace of a wiki, by number and name.
+type Namespace struct {
+	Key  int    `xml:"key,attr"`
+	Case string `xml:"case,attr"` // the case rule of its titles, that of the wiki if empty
+	Name string `xml:",chardata"`
+}
+
+// The case rules of the titles of a wiki.
+const (
+	// CaseFirstLetter makes the first letter of titles upper case, so that
+	// "apple" and "Apple" are the same page, as on most wikis.
+	CaseFirstLetter = "first-letter"
+	// CaseSensitive keeps titles as written, so that "apple" and "Apple"
+	// are different pages, as on Wiktionary.
+	CaseSensitive = "case-sensitive"
+)
+
+// ReadSiteInfo reads the <siteinfo> element of the dump in r, which
+// comes before the pages. Dumps without one have an empty SiteInfo.
+func ReadSiteInfo(r io.Reader) (*SiteInfo, error) {
+	var info SiteInfo
+	decoder := xml.NewDecoder(r)
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			return &info, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			switch se.Name.Local {
+			case "mediawiki":
+				info.SchemaVersion = rootSchema(&se)
+			case "siteinfo":
+				err := decoder.DecodeElement(&info, &se)
+				return &info, err
+			case "page":
+				return &info, nil
+			}
+		}
+	}
+}
+
+// namespace returns the namespace of the wiki whose name is the prefix
+// of a title, in any case and with underscores or blanks.
+func (info *SiteInfo) namespace(prefix string) (Namespace, bool) {
+	prefix = strings.ReplaceAll(prefix, "_", " ")
+	for _, ns := range info.Namespaces {
+		if ns.Name != "" && strings.EqualFold(ns.Name, prefix) {
+			return ns, true
+		}
+	}
+	return Namespace{}, false
+}
+
+// TitleCase returns the case rule of the title: that of its namespace,
+// or else that of the wiki.
+func (info *SiteInfo) TitleCase(title string) string {
+	rule := info.Case
+	if prefix, _, ok := strings.Cut(title, ":"); ok {
+		if ns, ok := info.namespace(prefix); ok && ns.Case != "" {
+			rule = ns.Case
+		}
+	}
+	if rule == "" {
+		return CaseFirstLetter
+	}
+	return rule
+}
+
+// CanonicalizeTitle is like the function CanonicalizeTitle for the
+// titles of the wiki, which it is for titles of the first-letter rule,
+// lower case as a whole. Case-sensitive titles keep their case but for
+// the prefix of their namespace, whose names are not case-sensitive. A
+// nil SiteInfo canonicalizes as the function does.
+func (info *SiteInfo) CanonicalizeTitle(title string) string {
+	if info == nil || info.TitleCase(title) != CaseSensitive {
+		return CanonicalizeTitle(title)
+	}
+	if prefix, rest, ok := strings.Cut(title, ":"); ok {
+		if _, ok := info.namespace(prefix); ok {
+			title = strings.ToLower(prefix) + ":" + rest
+		}
+	}
+	return url.QueryEscape(strings.ReplaceAll(title, " ", "_"))
+}
+
+// Site returns the site of the English Wikipedia with the names of the
+// namespaces of the dump's wiki.
+func (info *SiteInfo) Site() *wikitext.Site {
+	site := wikitext.NewSite()
+	for _, ns := range info.Namespaces {
+		site.SetNamespace(ns.Key, ns.Name)
+	}
+	return site
+}
@@ -0,0 +1,169 @@
+// Reading of the tables of the MySQL dumps published with the XML dumps,
+// like enwiki-latest-page_props.sql.gz
+
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// SQLRows returns the rows of the INSERT statements for the table in a
+// MySQL dump, gzipped or not, with the values unquoted and NULL as the
+// empty string. The second value of the sequence is the error that ended
+// it, if any.
+func SQLRows(r io.Reader, table string) iter.Seq2[[]string, error] {
+	return sqlRows(r, table, nil)
+}
+
+// sqlRows is SQLRows, which also sets the columns, if not nil, to the
+// names of the columns of the table as the CREATE TABLE statement before
+// the rows has them.
+func sqlRows(r io.Reader, table string, columns *[]string) iter.Seq2[[]string, error] {
+	prefix := "INSERT INTO `" + table + "` VALUES "
+	create := "CREATE TABLE `" + table + "` ("
+	return func(yield func([]string, error) bool) {
+		reader := bufio.NewReaderSize(r, 1<<20)
+		if magic, _ := reader.Peek(2); string(magic) == "\x1f\x8b" {
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			defer gz.Close()
+			reader = bufio.NewReaderSize(gz, 1<<20)
+		}
+		inCreate := false
+		for n := 1; ; n++ {
+			line, err := reader.ReadString('\n')
+			switch {
+			case strings.HasPrefix(line, create):
+				inCreate = columns != nil
+			case inCreate && strings.HasPrefix(strings.TrimSpace(line), "`"):
+				name, _, _ := strings.Cut(strings.TrimSpace(line)[1:], "`")
+				*columns = append(*columns, name)
+			case inCreate:
+				// The keys after the columns, or the end of the statement.
+				inCreate = false
+			case strings.HasPrefix(line, prefix):
+				stopped := false
+				perr := parseInsert(line[len(prefix):], func(row []string) bool {
+					stopped = !yield(row, nil)
+					return !stopped
+				})
+				if perr != nil {
+					yield(nil, fmt.Errorf("line %d: %v", n, perr))
+					return
+				}
+				if stopped {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// parseInsert parses the tuples "(1,'a',NULL),(2,'b',NULL);" of an
+// INSERT statement, calling row for each until it returns false.
+func parseInsert(s string, row func([]string) bool) error {
+	values := make([]string, 0, 8)
+	var value strings.Builder
+	i := 0
+	for i < len(s) {
+		if s[i] != '(' {
+			return fmt.Errorf("expected ( at offset %d", i)
+		}
+		i++
+		values = values[:0]
+		for {
+			value.Reset()
+			if i < len(s) && s[i] == '\'' {
+				i++
+				for ; i < len(s) && s[i] != '\''; i++ {
+					c := s[i]
+					if c == '\\' && i+1 < len(s) {
+						i++
+						switch c = s[i]; c {
+						case 'n':
+							c = '\n'
+						case 'r':
+							c = '\r'
+						case 't':
+							c = '\t'
+						case '0':
+							c = 0
+						case 'Z':
+							c = 26
+						}
+					}
+					value.WriteByte(c)
+				}
+				i++ // the closing quote
+			} else {
+				end := strings.IndexAny(s[i:], ",)")
+				if end < 0 {
+					return errors.New("unterminated tuple")
+				}
+				if v := s[i : i+end]; v != "NULL" {
+					value.WriteString(v)
+				}
+				i += end
+			}
+			values = append(values, value.String())
+			if i >= len(s) {
+				return errors.New("unterminated tuple")
+			}
+			if s[i] == ')' {
+				i++
+				break
+			}
+			if s[i] != ',' {
+				return fmt.Errorf("expected , at offset %d", i)
+			}
+			i++
+		}
+		if !row(values) {
+			return nil
+		}
+		if i < len(s) && s[i] == ',' {
+			i++
+			continue
+		}
+		break
+	}
+	return nil
+}
+
+// ReadWikidataItems reads the Wikidata items of pages, like "Q42", from
+// the page_props table of a MySQL dump, by page id.
+func ReadWikidataItems(r io.Reader) (map[int64]string, error) {
+	items := make(map[int64]string)
+	for row, err := range SQLRows(r, "page_props") {
+		if err != nil {
+			return nil, fmt.Errorf("page_props %v", err)
+		}
+		// The columns are pp_page, pp_propname, pp_value and pp_sortkey.
+		if len(row) < 3 || row[1] != "wikibase_item" {
+			continue
+		}
+		id, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("page_props: invalid page id %q", row[0])
+		}
+		items[id] = row[2]
+	}
+	return items, nil
+}
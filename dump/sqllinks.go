@@ -0,0 +1,188 @@
+// The link tables of the MySQL dumps, like enwiki-latest-pagelinks.sql.gz,
+// -redirect.sql.gz, -categorylinks.sql.gz and -linktarget.sql.gz: the
+// links, redirects and categories as MediaWiki recorded them
+
+package dump
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+)
+
+// A PageLinkRow is a row of the pagelinks table: a link from the page
+// with the id From. Dumps of MediaWiki before 1.43 name the target by its
+// Namespace and Title, with underscores for spaces and without the name
+// of the namespace; later ones by its TargetID, a row of the linktarget
+// table.
+type PageLinkRow struct {
+	From          int64
+	FromNamespace int
+	Namespace     int
+	Title         string
+	TargetID      int64
+}
+
+// A LinkTargetRow is a row of the linktarget table: the title, with
+// underscores for spaces and without the name of the namespace, that the
+// rows of the pagelinks and categorylinks tables of recent dumps refer to
+// by its ID.
+type LinkTargetRow struct {
+	ID        int64
+	Namespace int
+	Title     string
+}
+
+// A RedirectRow is a row of the redirect table: the page with the id From
+// redirects to the title in the namespace, with underscores for spaces, of
+// the wiki of the interwiki prefix if any, at the fragment if any.
+type RedirectRow struct {
+	From      int64
+	Namespace int
+	Title     string
+	Interwiki string
+	Fragment  string
+}
+
+// A CategoryLinkRow is a row of the categorylinks table: the page with the
+// id From is in the category To, without the namespace and with
+// underscores for spaces, under the sort key. Type is "page", "subcat" or
+// "file". Dumps of MediaWiki since 1.45 name the category by its TargetID
+// in the linktarget table instead of To.
+type CategoryLinkRow struct {
+	From          int64
+	To            string
+	SortKey       string
+	SortKeyPrefix string
+	Timestamp     string
+	Type          string
+	TargetID      int64
+}
+
+// The columns of the tables in the order of the dumps without a CREATE
+// TABLE statement, as of the MediaWiki versions whose rows name their
+// targets by title.
+var (
+	pageLinkColumns     = []string{"pl_from", "pl_namespace", "pl_title", "pl_from_namespace"}
+	linkTargetColumns   = []string{"lt_id", "lt_namespace", "lt_title"}
+	redirectColumns     = []string{"rd_from", "rd_namespace", "rd_title", "rd_interwiki", "rd_fragment"}
+	categoryLinkColumns = []string{"cl_from", "cl_to", "cl_sortkey", "cl_timestamp", "cl_sortkey_prefix", "cl_collation", "cl_type"}
+)
+
+// An sqlRecord is a row of a table with the names of its columns.
+type sqlRecord struct {
+	columns []string
+	values  []string
+	err     error // the first error converting a value
+}
+
+// value returns the value of the column, or the empty string if the table
+// has none of the name.
+func (r *sqlRecord) value(column string) string {
+	if i := slices.Index(r.columns, column); i >= 0 && i < len(r.values) {
+		return r.values[i]
+	}
+	return ""
+}
+
+// number returns the value of the column as a number, 0 if empty.
+func (r *sqlRecord) number(column string) int64 {
+	s := r.value(column)
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("invalid %s %q", column, s)
+	}
+	return n
+}
+
+// sqlRecords returns the rows of the table of a MySQL dump, as SQLRows
+// does, with the names of their columns: those of the CREATE TABLE
+// statement of the dump, or defaults if there is none. The rows are made
+// into values by convert, whose errors end the sequence as those of the
+// dump do.
+func sqlRecords[T any](r io.Reader, table string, defaults []string, convert func(rec *sqlRecord) T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var columns []string
+		var zero T
+		for row, err := range sqlRows(r, table, &columns) {
+			if err != nil {
+				yield(zero, fmt.Errorf("%s %v", table, err))
+				return
+			}
+			rec := &sqlRecord{columns: columns, values: row}
+			if len(columns) == 0 {
+				rec.columns = defaults
+			}
+			v := convert(rec)
+			if rec.err != nil {
+				yield(zero, fmt.Errorf("%s: %v", table, rec.err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// PageLinkRows returns the rows of the pagelinks table of a MySQL dump,
+// gzipped or not, as they are read. The second value of the sequence is
+// the error that ended it, if any.
+func PageLinkRows(r io.Reader) iter.Seq2[PageLinkRow, error] {
+	return sqlRecords(r, "pagelinks", pageLinkColumns, func(rec *sqlRecord) PageLinkRow {
+		return PageLinkRow{
+			From:          rec.number("pl_from"),
+			FromNamespace: int(rec.number("pl_from_namespace")),
+			Namespace:     int(rec.number("pl_namespace")),
+			Title:         rec.value("pl_title"),
+			TargetID:      rec.number("pl_target_id"),
+		}
+	})
+}
+
+// LinkTargetRows returns the rows of the linktarget table of a MySQL
+// dump, as PageLinkRows does.
+func LinkTargetRows(r io.Reader) iter.Seq2[LinkTargetRow, error] {
+	return sqlRecords(r, "linktarget", linkTargetColumns, func(rec *sqlRecord) LinkTargetRow {
+		return LinkTargetRow{
+			ID:        rec.number("lt_id"),
+			Namespace: int(rec.number("lt_namespace")),
+			Title:     rec.value("lt_title"),
+		}
+	})
+}
+
+// RedirectRows returns the rows of the redirect table of a MySQL dump, as
+// PageLinkRows does.
+func RedirectRows(r io.Reader) iter.Seq2[RedirectRow, error] {
+	return sqlRecords(r, "redirect", redirectColumns, func(rec *sqlRecord) RedirectRow {
+		return RedirectRow{
+			From:      rec.number("rd_from"),
+			Namespace: int(rec.number("rd_namespace")),
+			Title:     rec.value("rd_title"),
+			Interwiki: rec.value("rd_interwiki"),
+			Fragment:  rec.value("rd_fragment"),
+		}
+	})
+}
+
+// CategoryLinkRows returns the rows of the categorylinks table of a MySQL
+// dump, as PageLinkRows does.
+func CategoryLinkRows(r io.Reader) iter.Seq2[CategoryLinkRow, error] {
+	return sqlRecords(r, "categorylinks", categoryLinkColumns, func(rec *sqlRecord) CategoryLinkRow {
+		return CategoryLinkRow{
+			From:          rec.number("cl_from"),
+			To:            rec.value("cl_to"),
+			SortKey:       rec.value("cl_sortkey"),
+			SortKeyPrefix: rec.value("cl_sortkey_prefix"),
+			Timestamp:     rec.value("cl_timestamp"),
+			Type:          rec.value("cl_type"),
+			TargetID:      rec.number("cl_target_id"),
+		}
+	})
+}
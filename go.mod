@@ -0,0 +1,3 @@
+module github.com/pcmoritz/wikipedia
+
+go 1.24
@@ -0,0 +1,177 @@
+// Package audit writes an append-only log of pipeline runs for data
+// lineage: which code ran with which configuration on which dump, and
+// what it produced.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/internal/secret"
+)
+
+// A Digest computes the SHA-256 of everything written to it.
+type Digest struct {
+	hash  hash.Hash
+	Bytes int64
+}
+
+// NewDigest returns an empty Digest.
+func NewDigest() *Digest {
+	return &Digest{hash: sha256.New()}
+}
+
+func (d *Digest) Write(p []byte) (int, error) {
+	d.Bytes += int64(len(p))
+	return d.hash.Write(p)
+}
+
+// Sum returns the hex encoded SHA-256 of the data written so far.
+func (d *Digest) Sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// An Entry identifies an input or output of a run by its content.
+type Entry struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind,omitempty"`
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+
+	// Partial is set for an input the run read only part of, like the
+	// start of a dump with -limit: SHA256 and Bytes are of what was read.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// A Record is one line of the audit log.
+type Record struct {
+	SchemaVersion  int               `json:"schema_version"`
+	MigrationNotes []string          `json:"migration_notes,omitempty"`
+	Time           string            `json:"time,omitempty"`
+	Command        string            `json:"command"`
+	Version        string            `json:"version"`
+	Config         map[string]string `json:"config"`
+	ConfigHash     string            `json:"config_hash"`
+	Input          Entry             `json:"input"`
+	Outputs        []Entry           `json:"outputs"`
+}
+
+// codeVersion returns the VCS revision the binary was built from, if
+// the go tool recorded it.
+func codeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			version = s.Value
+		}
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.modified" && s.Value == "true" {
+			version += "+dirty"
+		}
+	}
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
+
+// New starts the record of a run of command with the flags of fs, at
+// the given time. Flag values are redacted with secret.Redact.
+func New(command string, fs *flag.FlagSet, time string) *Record {
+	config := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		config[f.Name] = secret.Redact(f.Value.String())
+	})
+	// Maps are marshalled with sorted keys, so the hash is stable.
+	data, _ := json.Marshal(config)
+	configHash := sha256.Sum256(data)
+	return &Record{
+		SchemaVersion:  schema.Version,
+		MigrationNotes: schema.MigrationNotes(),
+		Time:           time,
+		Command:        command,
+		Version:        codeVersion(),
+		Config:         config,
+		ConfigHash:     hex.EncodeToString(configHash[:]),
+		Outputs:        make([]Entry, 0, 4),
+	}
+}
+
+// AddOutput records an output of the given kind whose content was
+// written to d.
+func (a *Record) AddOutput(path string, kind string, d *Digest) {
+	a.Outputs = append(a.Outputs, Entry{Path: path, Kind: kind, SHA256: d.Sum(), Bytes: d.Bytes})
+}
+
+// AddFile records the file at path as an output of the given kind.
+func (a *Record) AddFile(path string, kind string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	d := NewDigest()
+	if _, err := io.Copy(d, file); err != nil {
+		return err
+	}
+	a.AddOutput(path, kind, d)
+	return nil
+}
+
+// AppendTo appends the record as a single JSON line to the log at path.
+func (a *Record) AppendTo(path string) error {
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(a); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line.Bytes())
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadLog reads all records of an audit log. Records of version 1 had
+// no schema_version field and are upgraded.
+func ReadLog(r io.Reader) ([]Record, error) {
+	records := make([]Record, 0, 10)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %v", line, err)
+		}
+		if record.SchemaVersion == 0 {
+			record.SchemaVersion = 1
+		}
+		if record.SchemaVersion > schema.Version {
+			return nil, fmt.Errorf("audit log line %d: schema version %d is newer than supported version %d", line, record.SchemaVersion, schema.Version)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,288 @@
+// Package completion generates shell completion scripts and man pages
+// from the flag definitions of a command.
+package completion
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+func visitFlags(fs *flag.FlagSet) []*flag.Flag {
+	flags := make([]*flag.Flag, 0, 10)
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// valueFlags returns the names of the flags of fs that take a value, with
+// their dash, so that the completion skips their values when looking for
+// the command.
+func valueFlags(fs *flag.FlagSet) []string {
+	names := make([]string, 0, 10)
+	for _, f := range visitFlags(fs) {
+		if !isBoolFlag(f) {
+			names = append(names, "-"+f.Name)
+		}
+	}
+	return names
+}
+
+// dashed returns the names with a dash before each.
+func dashed(names []string) []string {
+	words := make([]string, len(names))
+	for i, n := range names {
+		words[i] = "-" + n
+	}
+	return words
+}
+
+// writeBashValues writes the completion of the values of the flags
+// having them, after the flag.
+func writeBashValues(w io.Writer, fs *flag.FlagSet, values map[string][]string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\tcase ${COMP_WORDS[COMP_CWORD-1]} in\n")
+	for _, f := range visitFlags(fs) {
+		if v, ok := values[f.Name]; ok {
+			fmt.Fprintf(w, "\t-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.Name, strings.Join(v, " "))
+		}
+	}
+	fmt.Fprintf(w, "\tesac\n")
+}
+
+func writeBashCompletion(w io.Writer, prog string, fs *flag.FlagSet, commands []Command, values map[string][]string) {
+	words := make([]string, 0, 10)
+	for _, f := range visitFlags(fs) {
+		words = append(words, "-"+f.Name)
+	}
+	name := strings.Replace(prog, "-", "_", -1)
+	fmt.Fprintf(w, "_%s() {\n", name)
+	fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]}\n")
+	writeBashValues(w, fs, values)
+	if len(commands) == 0 {
+		fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(words, " "))
+		fmt.Fprintf(w, "}\n")
+		fmt.Fprintf(w, "complete -o default -F _%s %s\n", name, prog)
+		return
+	}
+	// The command is the first word that is neither a flag nor its value.
+	fmt.Fprintf(w, "\tlocal valued=%q command=\"\" words i\n", " "+strings.Join(valueFlags(fs), " ")+" ")
+	fmt.Fprintf(w, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "\t\tcase ${COMP_WORDS[i]} in\n")
+	fmt.Fprintf(w, "\t\t-*=*) ;;\n")
+	fmt.Fprintf(w, "\t\t-*) [[ $valued == *\" ${COMP_WORDS[i]} \"* ]] && ((i++)) ;;\n")
+	fmt.Fprintf(w, "\t\t*) command=${COMP_WORDS[i]}; break ;;\n")
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\tdone\n")
+	fmt.Fprintf(w, "\tcase $command in\n")
+	for _, c := range commands {
+		words = append(words, c.Name)
+	}
+	fmt.Fprintf(w, "\t\"\") words=%q ;;\n", strings.Join(words, " "))
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%s) words=%q ;;\n", c.Name, strings.Join(dashed(c.Flags), " "))
+	}
+	fmt.Fprintf(w, "\t*) words=\"\" ;;\n")
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F _%s %s\n", name, prog)
+}
+
+// zshSpec returns the _arguments spec of the flag, whose values are
+// completed from values if it has them and as files otherwise.
+func zshSpec(f *flag.Flag, values map[string][]string) string {
+	usage := strings.Replace(f.Usage, "'", "'\\''", -1)
+	usage = strings.NewReplacer("[", "\\[", "]", "\\]", "`", "").Replace(usage)
+	if isBoolFlag(f) {
+		return fmt.Sprintf("'-%s[%s]'", f.Name, usage)
+	}
+	if v, ok := values[f.Name]; ok {
+		return fmt.Sprintf("'-%s[%s]:value:(%s)'", f.Name, usage, strings.Join(v, " "))
+	}
+	return fmt.Sprintf("'-%s[%s]:value:_files'", f.Name, usage)
+}
+
+func writeZshCompletion(w io.Writer, prog string, fs *flag.FlagSet, commands []Command, values map[string][]string) {
+	if len(commands) == 0 {
+		fmt.Fprintf(w, "#compdef %s\n\n_arguments \\\n", prog)
+		for _, f := range visitFlags(fs) {
+			fmt.Fprintf(w, "\t%s \\\n", zshSpec(f, values))
+		}
+		fmt.Fprintf(w, "\t'*:file:_files'\n")
+		return
+	}
+	name := strings.Replace(prog, "-", "_", -1)
+	fmt.Fprintf(w, "#compdef %s\n\n_%s() {\n", prog, name)
+	fmt.Fprintf(w, "\tlocal -a commands\n\tlocal state\n\tcommands=(\n")
+	for _, c := range commands {
+		summary := strings.NewReplacer("'", "'\\''", ":", "\\:").Replace(c.Summary)
+		fmt.Fprintf(w, "\t\t'%s:%s'\n", c.Name, summary)
+	}
+	fmt.Fprintf(w, "\t)\n\t_arguments -C \\\n")
+	for _, f := range visitFlags(fs) {
+		fmt.Fprintf(w, "\t\t%s \\\n", zshSpec(f, values))
+	}
+	fmt.Fprintf(w, "\t\t'1:command:->command' \\\n\t\t'*::arg:->args'\n")
+	fmt.Fprintf(w, "\tcase $state in\n")
+	fmt.Fprintf(w, "\tcommand) _describe command commands ;;\n")
+	fmt.Fprintf(w, "\targs)\n\t\tcase $words[1] in\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t%s) _arguments", c.Name)
+		for _, n := range c.Flags {
+			if f := fs.Lookup(n); f != nil {
+				fmt.Fprintf(w, " %s", zshSpec(f, values))
+			}
+		}
+		fmt.Fprintf(w, " '*:file:_files' ;;\n")
+	}
+	fmt.Fprintf(w, "\t\tesac ;;\n\tesac\n}\n\n_%s \"$@\"\n", name)
+}
+
+// writeFishFlag writes the completion of the flag, under the condition
+// if not empty, with its values from values if it has them.
+func writeFishFlag(w io.Writer, prog string, f *flag.Flag, condition string, values map[string][]string) {
+	usage := strings.Replace(f.Usage, "'", "\\'", -1)
+	usage = strings.Replace(usage, "`", "", -1)
+	if condition != "" {
+		condition = " -n '" + condition + "'"
+	}
+	if isBoolFlag(f) {
+		fmt.Fprintf(w, "complete -c %s%s -o %s -d '%s'\n", prog, condition, f.Name, usage)
+	} else if v, ok := values[f.Name]; ok {
+		fmt.Fprintf(w, "complete -c %s%s -o %s -x -a '%s' -d '%s'\n", prog, condition, f.Name, strings.Join(v, " "), usage)
+	} else {
+		fmt.Fprintf(w, "complete -c %s%s -o %s -r -d '%s'\n", prog, condition, f.Name, usage)
+	}
+}
+
+func writeFishCompletion(w io.Writer, prog string, fs *flag.FlagSet, commands []Command, values map[string][]string) {
+	condition := ""
+	if len(commands) > 0 {
+		condition = "__fish_use_subcommand"
+	}
+	for _, f := range visitFlags(fs) {
+		writeFishFlag(w, prog, f, condition, values)
+	}
+	for _, c := range commands {
+		summary := strings.Replace(c.Summary, "'", "\\'", -1)
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -f -a %s -d '%s'\n", prog, c.Name, summary)
+		for _, n := range c.Flags {
+			if f := fs.Lookup(n); f != nil {
+				writeFishFlag(w, prog, f, "__fish_seen_subcommand_from "+c.Name, values)
+			}
+		}
+	}
+}
+
+// roffEscape escapes backslashes, dashes and leading control characters
+// for man page text.
+func roffEscape(s string) string {
+	s = strings.Replace(s, "\\", "\\e", -1)
+	s = strings.Replace(s, "-", "\\-", -1)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = "\\&" + s
+	}
+	return s
+}
+
+func writeManPage(w io.Writer, prog string, fs *flag.FlagSet, commands []Command) {
+	fmt.Fprintf(w, ".TH %s 1\n", strings.ToUpper(roffEscape(prog)))
+	fmt.Fprintf(w, ".SH NAME\n%s \\- process Wikipedia XML dumps\n", roffEscape(prog))
+	if len(commands) == 0 {
+		fmt.Fprintf(w, ".SH SYNOPSIS\n.B %s\n[\\fIoptions\\fR]\n", roffEscape(prog))
+	} else {
+		fmt.Fprintf(w, ".SH SYNOPSIS\n.B %s\n[\\fIoptions\\fR] \\fIcommand\\fR [\\fIoptions\\fR]\n", roffEscape(prog))
+		fmt.Fprintf(w, ".SH COMMANDS\n")
+		for _, c := range commands {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(c.Name), roffEscape(c.Summary))
+		}
+	}
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, f := range visitFlags(fs) {
+		name, usage := flag.UnquoteUsage(f)
+		fmt.Fprintf(w, ".TP\n")
+		if name == "" {
+			fmt.Fprintf(w, ".B \\-%s\n", roffEscape(f.Name))
+		} else {
+			fmt.Fprintf(w, ".BI \\-%s \" %s\"\n", roffEscape(f.Name), roffEscape(name))
+		}
+		if f.DefValue != "" && !isBoolFlag(f) {
+			usage += fmt.Sprintf(" (default %q)", f.DefValue)
+		}
+		fmt.Fprintf(w, "%s\n", roffEscape(usage))
+	}
+}
+
+// A Command is a subcommand of the program, like "links" of wikiparse,
+// with the names of the flags it takes.
+type Command struct {
+	Name    string
+	Summary string
+	Flags   []string
+}
+
+// Flags are the flags requesting a completion script or the man page.
+type Flags struct {
+	fs    *flag.FlagSet
+	shell *string
+	man   *bool
+
+	// Commands are the subcommands of the program, completed as its
+	// first argument and followed by their own flags; none if empty.
+	Commands []Command
+
+	// Values are the values completed after the flags by their names,
+	// like the formats of a format flag; those of other flags are
+	// completed as files.
+	Values map[string][]string
+}
+
+// Register defines the -completion and -man flags in fs.
+func Register(fs *flag.FlagSet) *Flags {
+	return &Flags{
+		fs:    fs,
+		shell: fs.String("completion", "", "Print a completion script for `shell` (bash, zsh or fish) and exit"),
+		man:   fs.Bool("man", false, "Print a man page and exit"),
+	}
+}
+
+// Handle prints the requested completion script or man page for the
+// flags of the flag set. It returns true if the program should exit, and
+// an error for an unknown shell, after which it should exit with a
+// failure.
+func (f *Flags) Handle() (bool, error) {
+	prog := filepath.Base(os.Args[0])
+	switch *f.shell {
+	case "":
+	case "bash":
+		writeBashCompletion(os.Stdout, prog, f.fs, f.Commands, f.Values)
+		return true, nil
+	case "zsh":
+		writeZshCompletion(os.Stdout, prog, f.fs, f.Commands, f.Values)
+		return true, nil
+	case "fish":
+		writeFishCompletion(os.Stdout, prog, f.fs, f.Commands, f.Values)
+		return true, nil
+	default:
+		return true, fmt.Errorf("unknown shell %q, expected bash, zsh or fish", *f.shell)
+	}
+	if *f.man {
+		writeManPage(os.Stdout, prog, f.fs, f.Commands)
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,60 @@
+package completion
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestHandleUnknownShell(t *testing.T) {
+	fs := flag.NewFlagSet("wikiparse", flag.ContinueOnError)
+	f := Register(fs)
+	if err := fs.Parse([]string{"-completion", "tcsh"}); err != nil {
+		t.Fatal(err)
+	}
+	if exit, err := f.Handle(); !exit || err == nil {
+		t.Errorf("Handle() = %t, %v, want true and an error", exit, err)
+	}
+}
+
+func TestCommandCompletion(t *testing.T) {
+	fs := flag.NewFlagSet("wikiparse", flag.ContinueOnError)
+	fs.String("infile", "", "input file")
+	fs.String("linkfile", "", "link output file")
+	fs.String("linkformat", "tsv", "link output format")
+	fs.Bool("reproducible", false, "reproducible mode")
+	commands := []Command{{Name: "links", Summary: "Write the links", Flags: []string{"infile", "linkfile", "linkformat"}}}
+	values := map[string][]string{"linkformat": {"tsv", "csv"}}
+	tests := []struct {
+		shell string
+		write func(*bytes.Buffer)
+		want  []string
+	}{
+		{"bash", func(b *bytes.Buffer) { writeBashCompletion(b, "wikiparse", fs, commands, values) }, []string{
+			`-linkformat) COMPREPLY=($(compgen -W "tsv csv" -- "$cur")); return ;;`,
+			`local valued=" -infile -linkfile -linkformat "`,
+			`"") words="-infile -linkfile -linkformat -reproducible links" ;;`,
+			`links) words="-infile -linkfile -linkformat" ;;`,
+		}},
+		{"zsh", func(b *bytes.Buffer) { writeZshCompletion(b, "wikiparse", fs, commands, values) }, []string{
+			`'links:Write the links'`,
+			`links) _arguments '-infile[input file]:value:_files' '-linkfile[link output file]:value:_files' '-linkformat[link output format]:value:(tsv csv)' '*:file:_files' ;;`,
+		}},
+		{"fish", func(b *bytes.Buffer) { writeFishCompletion(b, "wikiparse", fs, commands, values) }, []string{
+			`complete -c wikiparse -n '__fish_use_subcommand' -o reproducible -d 'reproducible mode'`,
+			`complete -c wikiparse -n __fish_use_subcommand -f -a links -d 'Write the links'`,
+			`complete -c wikiparse -n '__fish_seen_subcommand_from links' -o linkfile -r -d 'link output file'`,
+			`complete -c wikiparse -n '__fish_seen_subcommand_from links' -o linkformat -x -a 'tsv csv' -d 'link output format'`,
+		}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		tt.write(&b)
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s completion lacks %s:\n%s", tt.shell, want, b.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package dedup finds the texts that duplicate one seen before: those of
+// the same content, by a hash of their words, and, above a threshold of
+// similarity, those that are nearly the same, by MinHash signatures of
+// their shingles of five words and locality-sensitive hashing of the
+// signatures in bands, as common among the revisions of history dumps
+// or the articles of several wikis.
+//
+// An Index keeps the signatures of the texts added, 256 bytes each and
+// their bands, so one for the 7 million articles of the English Wikipedia
+// needs about 3 GB; one for exact duplicates only keeps a hash.
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	shingleWords = 5  // the words of a shingle
+	hashes       = 64 // the MinHash values of a signature
+	bandRows     = 4  // the values of a signature in a band
+)
+
+// The multipliers and increments of the permutations of the shingle
+// hashes, one per MinHash value, the same in every run.
+var permutations = func() (p [hashes][2]uint64) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range p {
+		p[i] = [2]uint64{r.Uint64() | 1, r.Uint64()}
+	}
+	return p
+}()
+
+// A Signature sums up a text for an Index.
+type Signature struct {
+	sum    [sha256.Size]byte
+	minima []uint32 // nil for exact duplicates only
+}
+
+// An Index holds the signatures of the texts added, by their names. It
+// is not safe for concurrent use, but Sign is.
+type Index struct {
+	threshold float64
+	names     []string
+	sums      map[[sha256.Size]byte]int32
+	minima    [][]uint32
+	bands     []map[uint64][]int32 // the texts by the hash of each band
+}
+
+// NewIndex returns an index of texts duplicated by those of at least the
+// estimated Jaccard similarity of their shingles from 0 to 1, or only by
+// those of the same words with a threshold of 1. Below about 0.6, near
+// duplicates are increasingly missed by the bands.
+func NewIndex(threshold float64) *Index {
+	ix := &Index{threshold: threshold, sums: make(map[[sha256.Size]byte]int32)}
+	if threshold < 1 {
+		ix.bands = make([]map[uint64][]int32, hashes/bandRows)
+		for i := range ix.bands {
+			ix.bands[i] = make(map[uint64][]int32)
+		}
+	}
+	return ix
+}
+
+// Sign returns the signature of the text, lowercased and split into
+// words at white space, or nil for a text without words, which is no
+// duplicate.
+func (ix *Index) Sign(text string) *Signature {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return nil
+	}
+	s := &Signature{sum: sha256.Sum256([]byte(strings.Join(words, " ")))}
+	if ix.bands == nil {
+		return s
+	}
+	s.minima = make([]uint32, hashes)
+	for i := range s.minima {
+		s.minima[i] = ^uint32(0)
+	}
+	for i := 0; i == 0 || i+shingleWords <= len(words); i++ {
+		h := fnv.New64a()
+		for _, w := range words[i:min(i+shingleWords, len(words))] {
+			h.Write([]byte(w))
+			h.Write([]byte{' '})
+		}
+		x := h.Sum64()
+		for j, p := range permutations {
+			if v := uint32((x*p[0] + p[1]) >> 32); v < s.minima[j] {
+				s.minima[j] = v
+			}
+		}
+	}
+	return s
+}
+
+// Add adds the text of the name with its signature, unless it duplicates
+// one added before, whose name it returns with their similarity, 1 for
+// texts of the same words, and true.
+func (ix *Index) Add(name string, s *Signature) (string, float64, bool) {
+	if i, ok := ix.sums[s.sum]; ok {
+		return ix.names[i], 1, true
+	}
+	n := int32(len(ix.names))
+	if ix.bands != nil {
+		var keys [hashes / bandRows]uint64
+		best, bestSimilarity := int32(-1), 0.0
+		for b := range ix.bands {
+			var buf [bandRows * 4]byte
+			for r := range bandRows {
+				binary.LittleEndian.PutUint32(buf[r*4:], s.minima[b*bandRows+r])
+			}
+			h := fnv.New64a()
+			h.Write(buf[:])
+			keys[b] = h.Sum64()
+			for _, i := range ix.bands[b][keys[b]] {
+				if similarity := estimate(s.minima, ix.minima[i]); similarity > bestSimilarity {
+					best, bestSimilarity = i, similarity
+				}
+			}
+		}
+		if best >= 0 && bestSimilarity >= ix.threshold {
+			return ix.names[best], bestSimilarity, true
+		}
+		for b, key := range keys {
+			ix.bands[b][key] = append(ix.bands[b][key], n)
+		}
+		ix.minima = append(ix.minima, s.minima)
+	}
+	ix.sums[s.sum] = n
+	ix.names = append(ix.names, name)
+	return "", 0, false
+}
+
+// Len returns the number of texts added that duplicate none before.
+func (ix *Index) Len() int {
+	return len(ix.names)
+}
+
+// estimate returns the share of the values two signatures agree on, the
+// estimate of the Jaccard similarity of their shingles.
+func estimate(a, b []uint32) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
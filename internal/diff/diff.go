@@ -0,0 +1,198 @@
+// Package diff computes the differences between the lines of two texts,
+// with Myers' algorithm, and writes them in the unified format of
+// "diff -u".
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Op is what an Edit does with its line.
+type Op int
+
+const (
+	Equal  Op = iota // the line is in both texts
+	Delete           // the line is only in the old text
+	Insert           // the line is only in the new text
+)
+
+// An Edit is a line of the old or the new text, or of both.
+type Edit struct {
+	Op   Op
+	Line string // with its newline, if it has one
+}
+
+// maxEdits bounds the number of lines deleted and inserted searched for
+// a shortest diff, which takes quadratic memory in it. Beyond it, the
+// lines between the common beginning and end of the texts are replaced
+// as a whole, as for vandalism blanking a page.
+const maxEdits = 2000
+
+// SplitLines splits a text after its newlines.
+func SplitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the edits turning the lines a into the lines b, with as
+// few deletions and insertions as possible for small changes.
+func Lines(a []string, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	middle, ok := shortest(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		middle = middle[:0]
+		for _, line := range a[prefix : len(a)-suffix] {
+			middle = append(middle, Edit{Delete, line})
+		}
+		for _, line := range b[prefix : len(b)-suffix] {
+			middle = append(middle, Edit{Insert, line})
+		}
+	}
+	edits = append(edits, middle...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	return edits
+}
+
+// shortest returns a shortest edit script from a to b, or false if it
+// has more than maxEdits deletions and insertions.
+func shortest(a []string, b []string) ([]Edit, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	// v[offset+k] is the furthest x reached on diagonal k = x-y; trace
+	// holds v before each round, to walk the path back.
+	v := make([]int, 2*limit+3)
+	trace := make([][]int, 0, 16)
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the path of d rounds found by shortest back from the
+// end of both texts.
+func backtrack(a []string, b []string, trace [][]int, d int) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		// trace[d] holds v[offset-d-1:offset+d+2] before round d.
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		previous := k - 1
+		if k == -d || k != d && v(k-1) < v(k+1) {
+			previous = k + 1
+		}
+		px := v(previous)
+		py := px - previous
+		for x > px && y > py {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == px {
+			edits = append(edits, Edit{Insert, b[y-1]})
+			y--
+		} else {
+			edits = append(edits, Edit{Delete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, Edit{Equal, a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Unified writes the differences from the text a to b as a unified diff
+// with the given lines of context around changes, under the names from
+// and to. It writes nothing if the texts are equal.
+func Unified(w io.Writer, from string, to string, a string, b string, context int) error {
+	edits := Lines(SplitLines(a), SplitLines(b))
+	// lines[i] counts the lines of a and b before edits[i].
+	lines := make([][2]int, len(edits)+1)
+	changes := make([]int, 0, 8)
+	for i, e := range edits {
+		lines[i+1] = lines[i]
+		if e.Op != Insert {
+			lines[i+1][0]++
+		}
+		if e.Op != Delete {
+			lines[i+1][1]++
+		}
+		if e.Op != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", from, to)
+	for c := 0; c < len(changes); {
+		start := max(changes[c]-context, 0)
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*context {
+			last++
+		}
+		end := min(changes[last]+1+context, len(edits))
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lines[start][0], lines[end][0]), hunkRange(lines[start][1], lines[end][1]))
+		for _, e := range edits[start:end] {
+			out.WriteString([]string{" ", "-", "+"}[e.Op])
+			out.WriteString(e.Line)
+			if !strings.HasSuffix(e.Line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		c = last + 1
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// hunkRange formats the lines [start, end) of a text for a hunk header.
+func hunkRange(start int, end int) string {
+	switch end - start {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
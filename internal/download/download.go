@@ -0,0 +1,322 @@
+// Package download fetches the dumps of Wikimedia from its mirrors, which
+// share the layout of https://dumps.wikimedia.org, like
+//
+//	<mirror>/enwiki/latest/enwiki-latest-pages-articles-multistream.xml.bz2
+//
+// resuming files partly downloaded before and verifying them with the
+// MD5 and SHA-1 checksums published with the dump.
+package download
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Mirror is the main site of the dumps.
+const Mirror = "https://dumps.wikimedia.org"
+
+// A Dump names the files of a dump of a wiki, like the
+// pages-articles-multistream dump of enwiki of the date latest.
+type Dump struct {
+	Wiki string // the database name of the wiki, like "enwiki"
+	Date string // like "20240601" or "latest"
+	Kind string // like "pages-articles" or "pages-articles-multistream"
+}
+
+// prefix returns the start of the paths of the files of the dump.
+func (d Dump) prefix() string {
+	return d.Wiki + "/" + d.Date + "/" + d.Wiki + "-" + d.Date + "-"
+}
+
+// Files returns the paths of the files of the dump on a mirror: the XML,
+// and for a multistream dump its index.
+func (d Dump) Files() []string {
+	files := []string{d.prefix() + d.Kind + ".xml.bz2"}
+	if strings.HasSuffix(d.Kind, "multistream") {
+		files = append(files, d.prefix()+d.Kind+"-index.txt.bz2")
+	}
+	return files
+}
+
+// checksumLists returns the paths of the lists of the checksums of the
+// files of the dump, lines "checksum  file name", by the names of their
+// hash functions.
+func (d Dump) checksumLists() map[string]string {
+	return map[string]string{
+		"md5":  d.prefix() + "md5sums.txt",
+		"sha1": d.prefix() + "sha1sums.txt",
+	}
+}
+
+// fileName returns the name of the file of the dump listed in a checksum
+// list as listed. The lists of latest name the files by the date of the
+// run, like enwiki-20240601-pages-articles.xml.bz2 for
+// enwiki-latest-pages-articles.xml.bz2.
+func (d Dump) fileName(listed string) string {
+	rest, ok := strings.CutPrefix(listed, d.Wiki+"-")
+	if !ok {
+		return listed
+	}
+	if _, rest, ok = strings.Cut(rest, "-"); !ok {
+		return listed
+	}
+	return d.Wiki + "-" + d.Date + "-" + rest
+}
+
+// A Client downloads dumps from the mirrors.
+type Client struct {
+	Mirrors   []string // the base URLs of the mirrors, tried in order
+	UserAgent string
+	Client    *http.Client
+	Logger    *slog.Logger // where the downloads are logged, if not nil
+}
+
+// NewClient returns a Client for the mirrors.
+func NewClient(mirrors []string) *Client {
+	return &Client{
+		Mirrors:   mirrors,
+		UserAgent: "wikiparse (https://github.com/pcmoritz/wikipedia)",
+		// Only the headers must come in time, the body of a dump takes hours.
+		Client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: time.Minute,
+		}},
+	}
+}
+
+func (c *Client) log(level slog.Level, msg string, args ...any) {
+	if c.Logger != nil {
+		c.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// request sends a request for the path on the mirror.
+func (c *Client) request(ctx context.Context, method string, mirror string, path string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(mirror, "/")+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	return c.Client.Do(req)
+}
+
+// SelectMirrors returns the mirrors having the file at path, the one
+// answering first first. It fails if none of them has it.
+func (c *Client) SelectMirrors(ctx context.Context, path string) ([]string, error) {
+	type probe struct {
+		mirror  string
+		latency time.Duration
+		err     error
+	}
+	probes := make(chan probe, len(c.Mirrors))
+	for _, m := range c.Mirrors {
+		go func() {
+			start := time.Now()
+			resp, err := c.request(ctx, http.MethodHead, m, path, nil)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = errors.New(resp.Status)
+				}
+			}
+			probes <- probe{m, time.Since(start), err}
+		}()
+	}
+	results := make([]probe, 0, len(c.Mirrors))
+	var errs []error
+	for range c.Mirrors {
+		p := <-probes
+		if p.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", p.mirror, p.err))
+			continue
+		}
+		results = append(results, p)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no mirror has %s: %w", path, errors.Join(errs...))
+	}
+	slices.SortStableFunc(results, func(a, b probe) int { return cmp.Compare(a.latency, b.latency) })
+	mirrors := make([]string, len(results))
+	for i, p := range results {
+		mirrors[i] = p.mirror
+	}
+	return mirrors, nil
+}
+
+// Checksums returns the checksums of the files of the dump published on
+// the first of the mirrors that has them, by the names of its files, those
+// of latest for latest, and then by the name of the hash function, "md5"
+// or "sha1". It fails if none are published.
+func (c *Client) Checksums(ctx context.Context, mirrors []string, d Dump) (map[string]map[string]string, error) {
+	sums := make(map[string]map[string]string)
+	var errs []error
+	for name, path := range d.checksumLists() {
+		err := errors.New("no mirror")
+		for _, m := range mirrors {
+			var body []byte
+			if body, err = c.get(ctx, m, path); err == nil {
+				for listed, sum := range parseChecksums(string(body)) {
+					file := d.fileName(listed)
+					if sums[file] == nil {
+						sums[file] = make(map[string]string)
+					}
+					sums[file][name] = sum
+				}
+				break
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(sums) == 0 {
+		return nil, fmt.Errorf("no checksums: %w", errors.Join(errs...))
+	}
+	return sums, nil
+}
+
+// get returns the body of the file at path on the mirror.
+func (c *Client) get(ctx context.Context, mirror string, path string) ([]byte, error) {
+	resp, err := c.request(ctx, http.MethodGet, mirror, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s/%s: %s", strings.TrimRight(mirror, "/"), path, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseChecksums parses the lines "checksum  file name" of a checksum
+// list, as written by md5sum and sha1sum.
+func parseChecksums(list string) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	return sums
+}
+
+// Fetch downloads the file at path to dest, from the first of the mirrors
+// that serves it, going on to the next one if a download fails. The file
+// is written to dest+".part" first, which later runs resume, and renamed
+// to dest when it is complete.
+func (c *Client) Fetch(ctx context.Context, mirrors []string, path string, dest string) error {
+	part := dest + ".part"
+	var err error
+	for _, m := range mirrors {
+		if err = c.fetchFrom(ctx, m, path, part); err == nil {
+			return os.Rename(part, dest)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		c.log(slog.LevelWarn, "Download failed", "path", path, "mirror", m, "err", err)
+	}
+	return err
+}
+
+// fetchFrom downloads the file at path on the mirror to part, asking for
+// the bytes after those part has.
+func (c *Client) fetchFrom(ctx context.Context, mirror string, path string, part string) error {
+	file, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.request(ctx, http.MethodGet, mirror, path, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		c.log(slog.LevelInfo, "Resuming download", "path", path, "mirror", mirror, "offset", offset)
+	case http.StatusOK:
+		// The mirror sends all of the file.
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		c.log(slog.LevelInfo, "Downloading", "path", path, "mirror", mirror)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file was downloaded but not renamed.
+		return nil
+	default:
+		return errors.New(resp.Status)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Verify checks the file at path against its checksums, by the names of
+// their hash functions, "md5" or "sha1", reading it once for all of
+// them. It fails if there are none.
+func Verify(path string, sums map[string]string) error {
+	hashes := make(map[string]hash.Hash)
+	writers := make([]io.Writer, 0, 2)
+	for name := range sums {
+		var h hash.Hash
+		switch name {
+		case "md5":
+			h = md5.New()
+		case "sha1":
+			h = sha1.New()
+		default:
+			continue
+		}
+		hashes[name] = h
+		writers = append(writers, h)
+	}
+	if len(hashes) == 0 {
+		return fmt.Errorf("%s: no checksum published", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return err
+	}
+	for name, h := range hashes {
+		if got := hex.EncodeToString(h.Sum(nil)); got != sums[name] {
+			return fmt.Errorf("%s: %s checksum %s, expected %s", path, name, got, sums[name])
+		}
+	}
+	return nil
+}
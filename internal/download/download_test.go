@@ -0,0 +1,188 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dumpData is the content of the file of the dumps the tests download.
+var dumpData = bytes.Repeat([]byte("<page>Apollo 11</page>\n"), 100)
+
+func md5sum(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sha1sum(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// mirror serves the files at their paths, the dump files with ranges as
+// dumps.wikimedia.org does, and records the Range headers it is sent.
+type mirror struct {
+	files  map[string][]byte
+	ranges []string
+}
+
+func (m *mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, ok := m.files[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	m.ranges = append(m.ranges, r.Header.Get("Range"))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		d            Dump
+		listed, want string
+	}{
+		{Dump{"enwiki", "latest", "pages-articles"}, "enwiki-20240601-pages-articles.xml.bz2", "enwiki-latest-pages-articles.xml.bz2"},
+		{Dump{"enwiki", "20240601", "pages-articles"}, "enwiki-20240601-pages-articles.xml.bz2", "enwiki-20240601-pages-articles.xml.bz2"},
+		{Dump{"enwiki", "latest", "pages-articles"}, "dewiki-20240601-pages-articles.xml.bz2", "dewiki-20240601-pages-articles.xml.bz2"},
+		{Dump{"enwiki", "latest", "pages-articles"}, "enwiki-20240601", "enwiki-20240601"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.fileName(tt.listed); got != tt.want {
+			t.Errorf("%+v.fileName(%q) = %q, want %q", tt.d, tt.listed, got, tt.want)
+		}
+	}
+}
+
+func TestChecksumsLatest(t *testing.T) {
+	m := &mirror{files: map[string][]byte{
+		"enwiki/latest/enwiki-latest-md5sums.txt": []byte(fmt.Sprintf("%s  enwiki-20240601-pages-articles.xml.bz2\n%s  enwiki-20240601-stub-articles.xml.gz\n",
+			md5sum(dumpData), md5sum(nil))),
+		"enwiki/latest/enwiki-latest-sha1sums.txt": []byte(fmt.Sprintf("%s  enwiki-20240601-pages-articles.xml.bz2\n", sha1sum(dumpData))),
+	}}
+	server := httptest.NewServer(m)
+	defer server.Close()
+	d := Dump{"enwiki", "latest", "pages-articles"}
+	sums, err := NewClient(nil).Checksums(context.Background(), []string{server.URL}, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sums["enwiki-latest-pages-articles.xml.bz2"]
+	if got["md5"] != md5sum(dumpData) || got["sha1"] != sha1sum(dumpData) {
+		t.Errorf("checksums of enwiki-latest-pages-articles.xml.bz2 = %v", got)
+	}
+	if _, ok := sums["enwiki-20240601-pages-articles.xml.bz2"]; ok {
+		t.Errorf("checksums by the dated names: %v", sums)
+	}
+}
+
+func TestChecksumsMissing(t *testing.T) {
+	server := httptest.NewServer(&mirror{})
+	defer server.Close()
+	_, err := NewClient(nil).Checksums(context.Background(), []string{server.URL}, Dump{"enwiki", "latest", "pages-articles"})
+	if err == nil || !strings.Contains(err.Error(), "no checksums") {
+		t.Errorf("Checksums without lists = %v, want no checksums", err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	const path = "enwiki/latest/enwiki-latest-pages-articles.xml.bz2"
+	tests := []struct {
+		name        string
+		part        []byte // what earlier runs downloaded, if any
+		rangeHeader string
+	}{
+		{"new", nil, ""},
+		// The rest is sent with status 206.
+		{"resumed", dumpData[:1000], "bytes=1000-"},
+		// The file was downloaded but not renamed: status 416.
+		{"complete", dumpData, fmt.Sprintf("bytes=%d-", len(dumpData))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mirror{files: map[string][]byte{path: dumpData}}
+			server := httptest.NewServer(m)
+			defer server.Close()
+			dest := filepath.Join(t.TempDir(), "dump.xml.bz2")
+			if tt.part != nil {
+				if err := os.WriteFile(dest+".part", tt.part, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := NewClient(nil).Fetch(context.Background(), []string{server.URL}, path, dest); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.ranges) != 1 || m.ranges[0] != tt.rangeHeader {
+				t.Errorf("requested ranges %q, want %q", m.ranges, tt.rangeHeader)
+			}
+			if err := Verify(dest, map[string]string{"md5": md5sum(dumpData), "sha1": sha1sum(dumpData)}); err != nil {
+				t.Error(err)
+			}
+			if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+				t.Errorf("the part file is left: %v", err)
+			}
+		})
+	}
+}
+
+func TestFetchWithoutRanges(t *testing.T) {
+	const path = "enwiki/latest/enwiki-latest-pages-articles.xml.bz2"
+	// The first mirror fails, the second ignores the range and sends all
+	// of the file.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	whole := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(dumpData)
+	}))
+	defer whole.Close()
+	dest := filepath.Join(t.TempDir(), "dump.xml.bz2")
+	if err := os.WriteFile(dest+".part", []byte("stale bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewClient(nil).Fetch(context.Background(), []string{failing.URL, whole.URL}, path, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dest); err != nil || !bytes.Equal(data, dumpData) {
+		t.Errorf("downloaded %d bytes, want %d: %v", len(data), len(dumpData), err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.xml.bz2")
+	if err := os.WriteFile(path, dumpData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		sums map[string]string
+		want string // a part of the error, if any
+	}{
+		{"both", map[string]string{"md5": md5sum(dumpData), "sha1": sha1sum(dumpData)}, ""},
+		{"md5 only", map[string]string{"md5": md5sum(dumpData)}, ""},
+		{"wrong sha1", map[string]string{"md5": md5sum(dumpData), "sha1": sha1sum(nil)}, "sha1 checksum " + sha1sum(dumpData) + ", expected " + sha1sum(nil)},
+		{"unknown hash", map[string]string{"sha512": "00"}, "no checksum published"},
+		{"none", nil, "no checksum published"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(path, tt.sums)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Verify = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Verify = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package elastic indexes documents in Elasticsearch or OpenSearch with
+// the bulk API, retrying requests and documents rejected while the
+// cluster is overloaded.
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// An Indexer buffers documents and sends them to an index in bulk
+// requests of up to Batch documents.
+type Indexer struct {
+	URL      string // the URL of the cluster, like "http://localhost:9200"
+	Index    string
+	Batch    int
+	Retries  int           // how often a request or document is retried
+	Backoff  time.Duration // the wait before the first retry, doubled for each next one
+	Username string
+	Password string
+	APIKey   string // used instead of the username and password if set
+	Client   *http.Client
+
+	Indexed int // the documents indexed so far
+	Failed  int // the documents rejected for good, e.g. as they do not fit the mapping
+
+	buffer []document
+	errors []string // the errors of the first documents rejected
+}
+
+type document struct {
+	id   string
+	body []byte
+}
+
+// maxBackoff limits the wait between retries.
+const maxBackoff = 30 * time.Second
+
+// NewIndexer returns an Indexer for the index of the cluster at url.
+func NewIndexer(url string, index string) *Indexer {
+	return &Indexer{
+		URL:     strings.TrimRight(url, "/"),
+		Index:   index,
+		Batch:   500,
+		Retries: 5,
+		Backoff: time.Second,
+		Client:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (ix *Indexer) request(method string, path string, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, ix.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case ix.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+ix.APIKey)
+	case ix.Username != "":
+		req.SetBasicAuth(ix.Username, ix.Password)
+	}
+	return ix.Client.Do(req)
+}
+
+// CreateIndex creates the index with the settings and mappings given as
+// JSON, unless it exists already.
+func (ix *Indexer) CreateIndex(mapping []byte) error {
+	resp, err := ix.request(http.MethodHead, "/"+ix.Index, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	resp, err = ix.request(http.MethodPut, "/"+ix.Index, "application/json", mapping)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("creating index %s: %s: %s", ix.Index, resp.Status, msg)
+	}
+	return nil
+}
+
+// Add buffers the document with the given id, replacing a document with
+// the same id in the index, and sends the buffer once it is full.
+func (ix *Indexer) Add(id string, doc any) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	ix.buffer = append(ix.buffer, document{id, body})
+	if len(ix.buffer) >= ix.Batch {
+		return ix.Flush()
+	}
+	return nil
+}
+
+// retryable reports whether a request that failed with the status may
+// succeed later: when the cluster is overloaded or unavailable.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// A bulkResponse is the part of the response of the bulk API used.
+type bulkResponse struct {
+	Items []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// send sends the documents in one bulk request and returns those to try
+// again.
+func (ix *Indexer) send(docs []document) ([]document, error) {
+	var body bytes.Buffer
+	for _, d := range docs {
+		action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": ix.Index, "_id": d.id}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(d.body)
+		body.WriteByte('\n')
+	}
+	resp, err := ix.request(http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return docs, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("bulk request: %s: %s", resp.Status, msg)
+		if retryable(resp.StatusCode) {
+			return docs, err
+		}
+		return nil, err
+	}
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return docs, fmt.Errorf("bulk response: %v", err)
+	}
+	if len(result.Items) != len(docs) {
+		return docs, fmt.Errorf("bulk response: %d items for %d documents", len(result.Items), len(docs))
+	}
+	var retry []document
+	for i, item := range result.Items {
+		for _, r := range item {
+			switch {
+			case r.Status < 300:
+				ix.Indexed++
+			case retryable(r.Status):
+				retry = append(retry, docs[i])
+			default:
+				ix.Failed++
+				if len(ix.errors) < 10 {
+					ix.errors = append(ix.errors, fmt.Sprintf("document %s: %d %s", docs[i].id, r.Status, r.Error))
+				}
+			}
+		}
+	}
+	return retry, nil
+}
+
+// Flush sends the buffered documents, retrying with exponential backoff
+// the requests that fail and the documents rejected as the cluster is
+// overloaded. Documents rejected for other reasons are counted in Failed.
+func (ix *Indexer) Flush() error {
+	docs := ix.buffer
+	ix.buffer = nil
+	backoff := ix.Backoff
+	for attempt := 0; len(docs) > 0; attempt++ {
+		retry, err := ix.send(docs)
+		if len(retry) == 0 {
+			return err
+		}
+		if attempt == ix.Retries {
+			if err == nil {
+				err = fmt.Errorf("%d documents still rejected", len(retry))
+			}
+			return fmt.Errorf("giving up after %d retries: %v", ix.Retries, err)
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxBackoff)
+		docs = retry
+	}
+	return nil
+}
+
+// Close sends the buffered documents and returns an error describing the
+// first documents rejected, if any were.
+func (ix *Indexer) Close() error {
+	if err := ix.Flush(); err != nil {
+		return err
+	}
+	if ix.Failed > 0 {
+		return fmt.Errorf("%d documents rejected: %s", ix.Failed, strings.Join(ix.errors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package filename maps the canonical titles of articles to names of
+// files that can be written on Windows and on case-insensitive file
+// systems as well as on Unix.
+package filename
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// MaxLength is the longest file name in bytes, leaving room below the
+// 255 bytes most file systems allow for the suffixes of temporary files.
+const MaxLength = 200
+
+// The names reserved by Windows, with or without an extension.
+var reserved = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com0": true, "com1": true, "com2": true, "com3": true, "com4": true,
+	"com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt0": true, "lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true,
+	"lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// hash returns a short hex digest of s, to tell names apart.
+func hash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Safe returns a file name for a canonical title as made by
+// dump.CanonicalizeTitle, which escapes all characters but letters,
+// digits and "-_.~". Names reserved by Windows get an underscore after
+// their base name, which no canonical title ends in, and trailing dots,
+// which Windows drops, are escaped as "%2E". Names longer than MaxLength
+// are cut at a whole escape and end in "~" and a hash of the full name.
+// Titles other than these names map to themselves.
+func Safe(title string) string {
+	name := title
+	if name == "" {
+		name = "_"
+	}
+	if base, ext, dotted := strings.Cut(name, "."); reserved[strings.ToLower(base)] {
+		name = base + "_"
+		if dotted {
+			name += "." + ext
+		}
+	}
+	if trimmed := strings.TrimRight(name, "."); len(trimmed) < len(name) {
+		name = trimmed + strings.Repeat("%2E", len(name)-len(trimmed))
+	}
+	if len(name) > MaxLength {
+		suffix := "~" + hash(name)
+		cut := MaxLength - len(suffix)
+		// Do not split an escape like "%3A".
+		if i := strings.LastIndexByte(name[:cut], '%'); i >= 0 && i > cut-3 {
+			cut = i
+		}
+		name = name[:cut] + suffix
+	}
+	return name
+}
+
+// A Namer assigns distinct file names to the articles of a run. Titles
+// that differ only in case, like "AIDS" and "Aids", share a canonical
+// title; the first one keeps the safe file name of the canonical title and
+// later ones get "~" and a hash of their exact title appended. Names are
+// compared case-insensitively, for file systems like those of Windows and
+// macOS. The zero value is not usable; create Namers with NewNamer.
+type Namer struct {
+	owners   map[string]string // the exact title using each folded file name
+	reserved map[string]string // the name reserved for each exact title
+}
+
+// NewNamer creates a Namer that has not assigned any names.
+func NewNamer() *Namer {
+	return &Namer{owners: make(map[string]string), reserved: make(map[string]string)}
+}
+
+// Reserve records that the file name belongs to the article with the
+// given exact title, as assigned by an earlier run, so that Name returns
+// it for that title and never for another.
+func (n *Namer) Reserve(title string, name string) {
+	n.owners[strings.ToLower(name)] = title
+	n.reserved[title] = name
+}
+
+// Name returns the file name for the article with the given exact title
+// and canonical title. It returns the same name if called again for the
+// same title, and the name reserved for it if any.
+func (n *Namer) Name(title string, canonical string) string {
+	if name, ok := n.reserved[title]; ok {
+		return name
+	}
+	name := Safe(canonical)
+	for attempt := title; ; attempt += "~" {
+		folded := strings.ToLower(name)
+		owner, used := n.owners[folded]
+		if !used {
+			n.owners[folded] = title
+			return name
+		}
+		if owner == title {
+			return name
+		}
+		// Hashes of long names cover the suffix, so names stay distinct.
+		// On the unlikely collision of hashes, the title is hashed again.
+		name = Safe(canonical + "~" + hash(attempt))
+	}
+}
+
+// Shard returns the path of the file name below levels of subdirectories,
+// like "3f/a2/Apollo_11" for two levels, so that no directory holds more
+// than a few thousand files of a million. The subdirectories are named by
+// two hex digits each of a hash of the name folded to lower case, so that
+// names a Namer tells apart by case only share a directory. The path has
+// slashes as separators.
+func Shard(name string, levels int) string {
+	sum := hash(strings.ToLower(name))
+	var b strings.Builder
+	for i := range min(levels, len(sum)/2) {
+		b.WriteString(sum[2*i : 2*i+2])
+		b.WriteByte('/')
+	}
+	return b.String() + name
+}
@@ -0,0 +1,74 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafe(t *testing.T) {
+	long := strings.Repeat("a", 190) + "%3A" + strings.Repeat("b", 20)
+	tests := []struct {
+		title, want string
+	}{
+		{"apollo_11", "apollo_11"},
+		{"", "_"},
+		{"con", "con_"},
+		{"Aux.h", "Aux_.h"},
+		{"console", "console"},
+		{"st._louis..", "st._louis%2E%2E"},
+		{long, strings.Repeat("a", 190) + "~" + hash(long)},
+	}
+	for _, tt := range tests {
+		got := Safe(tt.title)
+		if got != tt.want {
+			t.Errorf("Safe(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+		if len(got) > MaxLength {
+			t.Errorf("Safe(%q) is %d bytes long", tt.title, len(got))
+		}
+	}
+}
+
+func TestNamer(t *testing.T) {
+	// The titles in the order of the dump, with their canonical titles.
+	tests := []struct {
+		title, canonical, want string
+	}{
+		{"AIDS", "aids", "aids"},
+		{"Aids", "aids", "aids~" + hash("Aids")},
+		{"AIDS", "aids", "aids"},
+		{"Aids", "aids", "aids~" + hash("Aids")},
+		{"CON", "con", "con_"},
+		{"Con", "con", "con~" + hash("Con")},
+		// Different canonical titles whose names differ in case only.
+		{"Moon", "moon", "moon"},
+		{"MOON", "MOON", "MOON~" + hash("MOON")},
+	}
+	n := NewNamer()
+	for _, tt := range tests {
+		if got := n.Name(tt.title, tt.canonical); got != tt.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", tt.title, tt.canonical, got, tt.want)
+		}
+	}
+}
+
+func TestNamerReserve(t *testing.T) {
+	// The names of an earlier run, where "Aids" came before "AIDS".
+	n := NewNamer()
+	n.Reserve("Aids", "aids")
+	n.Reserve("AIDS", "aids~6b1c6a39")
+	tests := []struct {
+		title, canonical, want string
+	}{
+		{"Aids", "aids", "aids"},
+		{"AIDS", "aids", "aids~6b1c6a39"},
+	}
+	for _, tt := range tests {
+		if got := n.Name(tt.title, tt.canonical); got != tt.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", tt.title, tt.canonical, got, tt.want)
+		}
+	}
+	if got := n.Name("AiDS", "aids"); got == "aids" || got == "aids~6b1c6a39" {
+		t.Errorf("Name(%q) = %q, the name of another article", "AiDS", got)
+	}
+}
@@ -0,0 +1,496 @@
+// Package filter compiles expressions selecting the pages of a dump, like
+//
+//	namespace == 0 && len(text) > 2000 && hasTemplate("Infobox person")
+//
+// which are checked for their types when they are compiled and evaluated
+// for every page. Values are integers, strings and booleans:
+//
+//	id, namespace, revision      the page id, namespace number and revision id
+//	title, text, redirect        the title, the wikitext and the target of a redirect
+//	len(s)                       the length of s in bytes
+//	lower(s)                     s in lower case
+//	contains(s, t), hasPrefix(s, t), hasSuffix(s, t)
+//	matches(s, "regexp")         whether s matches the regular expression
+//	hasTemplate(name), hasCategory(name), hasLink(target)
+//	                             whether the page has the template, category or
+//	                             link, by canonical title; these parse the page
+//
+// with the operators ||, &&, !, ==, !=, <, <=, >, >=, + and - (of
+// integers), in the order of precedence of Go, and parentheses. Strings
+// are quoted as in Go.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// A kind is the type of a value.
+type kind int
+
+const (
+	kindBool kind = iota
+	kindInt
+	kindString
+)
+
+var kindNames = map[kind]string{kindBool: "bool", kindInt: "int", kindString: "string"}
+
+func (k kind) String() string {
+	return kindNames[k]
+}
+
+type value struct {
+	b bool
+	i int64
+	s string
+}
+
+// A page is what an expression is evaluated for, with the document of
+// the page parsed once a function needs it.
+type page struct {
+	p    *dump.Page
+	site *wikitext.Site
+	doc  *wikitext.Document
+}
+
+func (pg *page) document() *wikitext.Document {
+	if pg.doc == nil {
+		pg.doc, _ = wikitext.Parse(pg.p.Text, wikitext.WithSite(pg.site))
+	}
+	return pg.doc
+}
+
+// A node is a compiled expression of a kind.
+type node struct {
+	kind kind
+	eval func(pg *page) value
+}
+
+// A Filter is a compiled expression. It is safe for use in several
+// goroutines at once.
+type Filter struct {
+	expr string
+	root node
+}
+
+// An Error is a syntax or type error of an expression.
+type Error struct {
+	Pos int // the byte offset in the expression
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at %d: %s", e.Pos+1, e.Msg)
+}
+
+// The variables of the page.
+var variables = map[string]node{
+	"id":        {kindInt, func(pg *page) value { return value{i: pg.p.ID} }},
+	"revision":  {kindInt, func(pg *page) value { return value{i: pg.p.RevisionID} }},
+	"title":     {kindString, func(pg *page) value { return value{s: pg.p.Title} }},
+	"text":      {kindString, func(pg *page) value { return value{s: pg.p.Text} }},
+	"redirect":  {kindString, func(pg *page) value { return value{s: pg.p.Redir.Title} }},
+	"namespace": {kindInt, func(pg *page) value { number, _ := pg.site.Split(pg.p.Title); return value{i: int64(number)} }},
+}
+
+// A function of expressions, with the kinds of its arguments and result.
+type function struct {
+	args   []kind
+	result kind
+	// compile returns the evaluation of the function with its compiled
+	// arguments, of which constant strings are given as well, or an error
+	// message.
+	compile func(args []node, constants []*string) (func(pg *page) value, string)
+}
+
+// hasName returns a function telling whether the page has one of the
+// names returned by names, compared as canonical titles.
+func hasName(names func(doc *wikitext.Document) []string) function {
+	return function{[]kind{kindString}, kindBool, func(args []node, _ []*string) (func(pg *page) value, string) {
+		return func(pg *page) value {
+			name := dump.CanonicalizeTitle(strings.TrimSpace(args[0].eval(pg).s))
+			for _, n := range names(pg.document()) {
+				if dump.CanonicalizeTitle(strings.TrimSpace(n)) == name {
+					return value{b: true}
+				}
+			}
+			return value{}
+		}, ""
+	}}
+}
+
+// stringTest returns a function of two strings testing them with test.
+func stringTest(test func(s string, t string) bool) function {
+	return function{[]kind{kindString, kindString}, kindBool, func(args []node, _ []*string) (func(pg *page) value, string) {
+		return func(pg *page) value { return value{b: test(args[0].eval(pg).s, args[1].eval(pg).s)} }, ""
+	}}
+}
+
+// The functions, by name.
+var functions = map[string]function{
+	"len": {[]kind{kindString}, kindInt, func(args []node, _ []*string) (func(pg *page) value, string) {
+		return func(pg *page) value { return value{i: int64(len(args[0].eval(pg).s))} }, ""
+	}},
+	"lower": {[]kind{kindString}, kindString, func(args []node, _ []*string) (func(pg *page) value, string) {
+		return func(pg *page) value { return value{s: strings.ToLower(args[0].eval(pg).s)} }, ""
+	}},
+	"contains":  stringTest(strings.Contains),
+	"hasPrefix": stringTest(strings.HasPrefix),
+	"hasSuffix": stringTest(strings.HasSuffix),
+	"matches": {[]kind{kindString, kindString}, kindBool, func(args []node, constants []*string) (func(pg *page) value, string) {
+		if constants[1] == nil {
+			return nil, "the regular expression of matches must be a string constant"
+		}
+		re, err := regexp.Compile(*constants[1])
+		if err != nil {
+			return nil, err.Error()
+		}
+		return func(pg *page) value { return value{b: re.MatchString(args[0].eval(pg).s)} }, ""
+	}},
+	"hasTemplate": hasName(func(doc *wikitext.Document) []string {
+		var names []string
+		for _, t := range wikitext.Templates(doc, 1) {
+			names = append(names, t.Name)
+		}
+		return names
+	}),
+	"hasCategory": hasName(func(doc *wikitext.Document) []string {
+		var names []string
+		for _, c := range wikitext.Categories(doc) {
+			names = append(names, c.Name)
+		}
+		return names
+	}),
+	"hasLink": hasName(func(doc *wikitext.Document) []string {
+		var names []string
+		for _, l := range wikitext.Links(doc) {
+			names = append(names, l.Target)
+		}
+		return names
+	}),
+}
+
+// The precedences of the binary operators, as in Go.
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+}
+
+var twoCharOperators = map[string]bool{"||": true, "&&": true, "==": true, "!=": true, "<=": true, ">=": true}
+
+// A token of an expression: an operator or parenthesis, a name, an
+// integer or a quoted string.
+type token struct {
+	text string
+	pos  int
+}
+
+// tokenize splits an expression into tokens.
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0, 16)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, token{s[i:j], i})
+			i = j
+		case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] < utf8.RuneSelf && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])))) {
+				j++
+			}
+			tokens = append(tokens, token{s[i:j], i})
+			i = j
+		case c == '"' || c == '`':
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, &Error{i, "unterminated string"}
+			}
+			tokens = append(tokens, token{quoted, i})
+			i += len(quoted)
+		case len(s) > i+1 && twoCharOperators[s[i:i+2]]:
+			tokens = append(tokens, token{s[i : i+2], i})
+			i += 2
+		case strings.IndexByte("!<>+-(),", c) >= 0:
+			tokens = append(tokens, token{s[i : i+1], i})
+			i++
+		default:
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return nil, &Error{i, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return tokens, nil
+}
+
+// A parser compiles a tokenized expression by precedence climbing.
+type parser struct {
+	tokens []token
+	pos    int
+	end    int // the length of the expression, the position of its end
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{"", p.end}
+}
+
+// expect consumes the token with the text or reports an error.
+func (p *parser) expect(text string) error {
+	if t := p.peek(); t.text != text {
+		return p.unexpected(t, "expected "+text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) unexpected(t token, msg string) error {
+	if t.text == "" {
+		return &Error{t.pos, "unexpected end, " + msg}
+	}
+	return &Error{t.pos, fmt.Sprintf("unexpected %s, %s", t.text, msg)}
+}
+
+// operand compiles a constant, variable, call, parenthesized expression
+// or negation.
+func (p *parser) operand() (node, error) {
+	t := p.peek()
+	p.pos++
+	switch {
+	case t.text == "":
+		return node{}, p.unexpected(t, "expected an operand")
+	case t.text == "(":
+		n, err := p.expr(1)
+		if err != nil {
+			return node{}, err
+		}
+		return n, p.expect(")")
+	case t.text == "!" || t.text == "-":
+		n, err := p.operand()
+		if err != nil {
+			return node{}, err
+		}
+		if t.text == "!" {
+			if n.kind != kindBool {
+				return node{}, &Error{t.pos, "! of " + n.kind.String()}
+			}
+			return node{kindBool, func(pg *page) value { return value{b: !n.eval(pg).b} }}, nil
+		}
+		if n.kind != kindInt {
+			return node{}, &Error{t.pos, "- of " + n.kind.String()}
+		}
+		return node{kindInt, func(pg *page) value { return value{i: -n.eval(pg).i} }}, nil
+	case t.text[0] >= '0' && t.text[0] <= '9':
+		i, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return node{}, &Error{t.pos, "integer out of range"}
+		}
+		return node{kindInt, func(*page) value { return value{i: i} }}, nil
+	case t.text[0] == '"' || t.text[0] == '`':
+		s, err := strconv.Unquote(t.text)
+		if err != nil {
+			return node{}, &Error{t.pos, "invalid string " + t.text}
+		}
+		return node{kindString, func(*page) value { return value{s: s} }}, nil
+	case t.text == "true" || t.text == "false":
+		b := t.text == "true"
+		return node{kindBool, func(*page) value { return value{b: b} }}, nil
+	}
+	if p.peek().text == "(" {
+		return p.call(t)
+	}
+	if v, ok := variables[t.text]; ok {
+		return v, nil
+	}
+	if _, ok := functions[t.text]; ok {
+		return node{}, &Error{t.pos, t.text + " is a function, expected " + t.text + "(...)"}
+	}
+	return node{}, &Error{t.pos, "unknown name " + t.text}
+}
+
+// call compiles the call of the function named by t, whose arguments
+// follow.
+func (p *parser) call(t token) (node, error) {
+	f, ok := functions[t.text]
+	if !ok {
+		return node{}, &Error{t.pos, "unknown function " + t.text}
+	}
+	p.pos++
+	args := make([]node, 0, len(f.args))
+	constants := make([]*string, 0, len(f.args))
+	for p.peek().text != ")" {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return node{}, err
+			}
+		}
+		start := p.peek()
+		if start.text == "" {
+			return node{}, p.unexpected(start, "expected an argument")
+		}
+		var constant *string
+		if start.text[0] == '"' || start.text[0] == '`' {
+			if next := p.pos + 1; next < len(p.tokens) && (p.tokens[next].text == "," || p.tokens[next].text == ")") {
+				s, _ := strconv.Unquote(start.text)
+				constant = &s
+			}
+		}
+		n, err := p.expr(1)
+		if err != nil {
+			return node{}, err
+		}
+		if len(args) < len(f.args) && n.kind != f.args[len(args)] {
+			return node{}, &Error{start.pos, fmt.Sprintf("argument %d of %s must be %s, not %s", len(args)+1, t.text, f.args[len(args)], n.kind)}
+		}
+		args = append(args, n)
+		constants = append(constants, constant)
+	}
+	p.pos++
+	if len(args) != len(f.args) {
+		return node{}, &Error{t.pos, fmt.Sprintf("%s takes %d arguments, not %d", t.text, len(f.args), len(args))}
+	}
+	eval, msg := f.compile(args, constants)
+	if msg != "" {
+		return node{}, &Error{t.pos, msg}
+	}
+	return node{f.result, eval}, nil
+}
+
+// expr compiles the operators of at least the given precedence, left to
+// right.
+func (p *parser) expr(precedence int) (node, error) {
+	x, err := p.operand()
+	if err != nil {
+		return node{}, err
+	}
+	for {
+		op := p.peek()
+		prec, ok := binaryPrecedence[op.text]
+		if !ok || prec < precedence {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.expr(prec + 1)
+		if err != nil {
+			return node{}, err
+		}
+		if x, err = binary(op, x, y); err != nil {
+			return node{}, err
+		}
+	}
+}
+
+// binary compiles the operator applied to x and y.
+func binary(op token, x node, y node) (node, error) {
+	if x.kind != y.kind {
+		return node{}, &Error{op.pos, fmt.Sprintf("%s of %s and %s", op.text, x.kind, y.kind)}
+	}
+	k := x.kind
+	switch op.text {
+	case "||", "&&":
+		if k != kindBool {
+			return node{}, &Error{op.pos, op.text + " of " + k.String()}
+		}
+		if op.text == "||" {
+			return node{kindBool, func(pg *page) value { return value{b: x.eval(pg).b || y.eval(pg).b} }}, nil
+		}
+		return node{kindBool, func(pg *page) value { return value{b: x.eval(pg).b && y.eval(pg).b} }}, nil
+	case "+", "-":
+		if k != kindInt {
+			return node{}, &Error{op.pos, op.text + " of " + k.String()}
+		}
+		if op.text == "+" {
+			return node{kindInt, func(pg *page) value { return value{i: x.eval(pg).i + y.eval(pg).i} }}, nil
+		}
+		return node{kindInt, func(pg *page) value { return value{i: x.eval(pg).i - y.eval(pg).i} }}, nil
+	}
+	if k == kindBool && op.text != "==" && op.text != "!=" {
+		return node{}, &Error{op.pos, op.text + " of bool"}
+	}
+	compare := func(a value, b value) int {
+		switch k {
+		case kindInt:
+			return cmpInt(a.i, b.i)
+		case kindString:
+			return strings.Compare(a.s, b.s)
+		}
+		if a.b == b.b {
+			return 0
+		}
+		return 1
+	}
+	var test func(c int) bool
+	switch op.text {
+	case "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	default:
+		test = func(c int) bool { return c >= 0 }
+	}
+	return node{kindBool, func(pg *page) value { return value{b: test(compare(x.eval(pg), y.eval(pg)))} }}, nil
+}
+
+func cmpInt(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compile compiles the expression, which must be of type bool.
+func Compile(expr string) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, end: len(expr)}
+	root, err := p.expr(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.text != "" {
+		return nil, p.unexpected(t, "expected an operator")
+	}
+	if root.kind != kindBool {
+		return nil, &Error{0, "the expression is " + root.kind.String() + ", not bool"}
+	}
+	return &Filter{expr, root}, nil
+}
+
+// String returns the expression of the filter.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Match reports whether the page matches the filter, with the namespaces
+// of the site.
+func (f *Filter) Match(p *dump.Page, site *wikitext.Site) bool {
+	return f.root.eval(&page{p: p, site: site}).b
+}
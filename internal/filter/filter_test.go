@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr, want string
+	}{
+		{"", "at 1: unexpected end, expected an operand"},
+		{"len(", "at 5: unexpected end, expected an argument"},
+		{`hasTemplate("a",`, "at 17: unexpected end, expected an argument"},
+		{`hasTemplate("a"`, "at 16: unexpected end, expected ,"},
+		{"len(title", "at 10: unexpected end, expected ,"},
+		{"(namespace == 0", "at 16: unexpected end, expected )"},
+		{"namespace ==", "at 13: unexpected end, expected an operand"},
+		{"namespace 0", "at 11: unexpected 0, expected an operator"},
+		{"namespace = 0", "at 11: unexpected character '='"},
+		{`title == "Moon`, "at 10: unterminated string"},
+		{"size > 0", "at 1: unknown name size"},
+		{"len > 0", "at 1: len is a function, expected len(...)"},
+		{"size(text) > 0", "at 1: unknown function size"},
+		{"len(text, title) > 0", "at 1: len takes 1 arguments, not 2"},
+		{"len(id) > 0", "at 5: argument 1 of len must be string, not int"},
+		{"matches(title, lower(text))", "at 1: the regular expression of matches must be a string constant"},
+		{`matches(title, "(")`, "at 1: error parsing regexp: missing closing ): `(`"},
+		{"namespace", "at 1: the expression is int, not bool"},
+		{`!title`, "at 1: ! of string"},
+		{`title + 1 > 0`, "at 7: + of string and int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := Compile(tt.expr)
+			if err == nil {
+				t.Fatalf("Compile(%q) = %q, want an error", tt.expr, f)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Compile(%q) fails with %q, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	page := &dump.Page{
+		ID:    7,
+		Title: "Talk:Apollo 11",
+		Text:  "{{Infobox mission}} The [[Moon]] landing.\n[[Category:Spaceflight]]",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"namespace == 1 && id == 7", true},
+		{"namespace == 0 || id != 7", false},
+		{`title == "Talk:Apollo 11"`, true},
+		{`hasPrefix(lower(title), "talk:")`, true},
+		{`contains(text, "landing") && !hasSuffix(text, "landing")`, true},
+		{`matches(title, "^Talk:Apollo [0-9]+$")`, true},
+		{"len(text) - 60 > 0 == (len(text) > 60)", true},
+		{"-id + 8 == 1", true},
+		{`hasTemplate("infobox_mission")`, true},
+		{`hasCategory("Spaceflight") && hasLink("moon")`, true},
+		{`hasLink("Sun")`, false},
+		{"redirect == \"\"", true},
+	}
+	site := wikitext.NewSite()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Match(page, site); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
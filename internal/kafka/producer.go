@@ -0,0 +1,338 @@
+// Package kafka publishes messages to a topic of a Kafka cluster,
+// partitioned by their key like the Java client, in batches per partition
+// that are retried while their leader is unavailable.
+package kafka
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The error codes of the protocol that are handled or named.
+const (
+	errCorruptMessage        = 2
+	errUnknownTopic          = 3
+	errLeaderNotAvailable    = 5
+	errNotLeader             = 6
+	errRequestTimedOut       = 7
+	errMessageTooLarge       = 10
+	errNotEnoughReplicas     = 19
+	errNotEnoughReplicasLate = 20
+	errTopicAuthorization    = 29
+	errUnsupportedMechanism  = 33
+	errIllegalSaslState      = 34
+	errSaslAuthentication    = 58
+)
+
+var errorNames = map[int16]string{
+	errCorruptMessage:        "corrupt message",
+	errUnknownTopic:          "unknown topic or partition",
+	errLeaderNotAvailable:    "leader not available",
+	errNotLeader:             "not the leader",
+	errRequestTimedOut:       "request timed out",
+	errMessageTooLarge:       "message too large",
+	errNotEnoughReplicas:     "not enough replicas",
+	errNotEnoughReplicasLate: "not enough replicas after append",
+	errTopicAuthorization:    "topic authorization failed",
+	errUnsupportedMechanism:  "unsupported SASL mechanism",
+	errIllegalSaslState:      "illegal SASL state",
+	errSaslAuthentication:    "SASL authentication failed",
+}
+
+// An Error is an error code returned by a broker.
+type Error struct {
+	Code int16
+	What string // what it is about, like "topic wikipedia"
+}
+
+func (e *Error) Error() string {
+	name, ok := errorNames[e.Code]
+	if !ok {
+		name = fmt.Sprintf("error code %d", e.Code)
+	}
+	return "kafka: " + e.What + ": " + name
+}
+
+// retryable reports whether a request that failed with the code may
+// succeed later, once the partition has a leader again.
+func retryable(code int16) bool {
+	switch code {
+	case errLeaderNotAvailable, errNotLeader, errRequestTimedOut, errNotEnoughReplicas, errNotEnoughReplicasLate:
+		return true
+	}
+	return false
+}
+
+// maxBatchBytes bounds the size of the batch of a partition, below the
+// default limit of brokers of a megabyte. Larger messages are sent alone.
+const maxBatchBytes = 900 << 10
+
+// maxBackoff limits the wait between retries.
+const maxBackoff = 30 * time.Second
+
+// A Producer buffers messages and sends them to the leaders of their
+// partitions once Batch of them are buffered.
+type Producer struct {
+	Brokers  []string // the addresses of the brokers asked for the partitions, like "localhost:9092"
+	Topic    string
+	Batch    int
+	Retries  int           // how often a batch is retried
+	Backoff  time.Duration // the wait before the first retry, doubled for each next one
+	Timeout  time.Duration // the timeout of requests and of the acknowledgement of all replicas
+	ClientID string
+	TLS      *tls.Config // the configuration of TLS connections to the brokers, plain TCP if nil
+	// Username and Password authenticate the connections with
+	// SASL/PLAIN if Username is not empty. The password is sent in the
+	// clear unless TLS is set too.
+	Username string
+	Password string
+
+	Produced int // the messages acknowledged so far
+	Failed   int // the messages rejected for good, e.g. as they are too large
+
+	metadata *topicMetadata
+	conns    map[int32]*conn    // by broker id
+	buffer   map[int32][]record // by partition
+	sizes    map[int32]int
+	buffered int
+	errors   []string // the errors of the first messages rejected
+}
+
+// NewProducer returns a Producer for the topic of the cluster with the
+// brokers.
+func NewProducer(brokers []string, topic string) *Producer {
+	return &Producer{
+		Brokers:  brokers,
+		Topic:    topic,
+		Batch:    500,
+		Retries:  5,
+		Backoff:  time.Second,
+		Timeout:  30 * time.Second,
+		ClientID: "wikiparse",
+		conns:    make(map[int32]*conn),
+		buffer:   make(map[int32][]record),
+		sizes:    make(map[int32]int),
+	}
+}
+
+// dial connects to the broker at addr, over TLS and authenticated if
+// the producer is configured so.
+func (p *Producer) dial(addr string) (*conn, error) {
+	c, err := dial(addr, p.ClientID, p.Timeout, p.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if p.Username != "" {
+		if err := c.saslPlain(p.Username, p.Password); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// refresh asks the brokers for the partitions of the topic and their
+// leaders, dropping the connections to the old leaders.
+func (p *Producer) refresh() error {
+	var err error
+	for _, addr := range p.Brokers {
+		var c *conn
+		if c, err = p.dial(addr); err != nil {
+			continue
+		}
+		var m *topicMetadata
+		m, err = c.metadata(p.Topic)
+		c.close()
+		if err == nil {
+			p.closeConns()
+			p.metadata = m
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *Producer) closeConns() {
+	for id, c := range p.conns {
+		c.close()
+		delete(p.conns, id)
+	}
+}
+
+// leader returns the connection to the leader of the partition.
+func (p *Producer) leader(partition int32) (*conn, error) {
+	if int(partition) >= len(p.metadata.leaders) {
+		return nil, &Error{Code: errUnknownTopic, What: fmt.Sprintf("partition %d", partition)}
+	}
+	id := p.metadata.leaders[partition]
+	if id < 0 {
+		return nil, &Error{Code: errLeaderNotAvailable, What: fmt.Sprintf("partition %d", partition)}
+	}
+	if c, ok := p.conns[id]; ok {
+		return c, nil
+	}
+	addr, ok := p.metadata.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("kafka: partition %d: unknown leader %d", partition, id)
+	}
+	c, err := p.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[id] = c
+	return c, nil
+}
+
+// Send buffers the message with the key for the partition of the key, or
+// a partition in turn without one, and sends the buffer once it is full.
+func (p *Producer) Send(key []byte, value []byte) error {
+	if p.metadata == nil {
+		if err := p.refresh(); err != nil {
+			return err
+		}
+	}
+	n := int32(len(p.metadata.leaders))
+	partition := int32(p.buffered+p.Produced+p.Failed) % n
+	if key != nil {
+		partition = int32(murmur2(key)&0x7fffffff) % n
+	}
+	size := len(key) + len(value) + 32
+	if p.sizes[partition] > 0 && p.sizes[partition]+size > maxBatchBytes {
+		if err := p.Flush(); err != nil {
+			return err
+		}
+	}
+	p.buffer[partition] = append(p.buffer[partition], record{key, value})
+	p.sizes[partition] += size
+	p.buffered++
+	if p.buffered >= p.Batch {
+		return p.Flush()
+	}
+	return nil
+}
+
+// send sends the batches to the leaders of their partitions and returns
+// those to try again. Connections that fail are dropped.
+func (p *Producer) send(batches map[int32][]record) (map[int32][]record, error) {
+	byLeader := make(map[int32]map[int32][]record)
+	retry := make(map[int32][]record)
+	var err error
+	for partition, records := range batches {
+		if _, leaderErr := p.leader(partition); leaderErr != nil {
+			retry[partition] = records
+			err = leaderErr
+			continue
+		}
+		id := p.metadata.leaders[partition]
+		if byLeader[id] == nil {
+			byLeader[id] = make(map[int32][]record)
+		}
+		byLeader[id][partition] = records
+	}
+	for id, batches := range byLeader {
+		codes, sendErr := p.conns[id].produce(p.Topic, batches, p.Timeout)
+		if sendErr != nil {
+			p.conns[id].close()
+			delete(p.conns, id)
+			for partition, records := range batches {
+				retry[partition] = records
+			}
+			err = sendErr
+			continue
+		}
+		for partition, records := range batches {
+			switch code := codes[partition]; {
+			case code == 0:
+				p.Produced += len(records)
+			case retryable(code):
+				retry[partition] = records
+				err = &Error{Code: code, What: fmt.Sprintf("partition %d", partition)}
+			default:
+				p.Failed += len(records)
+				if len(p.errors) < 10 {
+					p.errors = append(p.errors, (&Error{Code: code, What: fmt.Sprintf("%d messages of partition %d", len(records), partition)}).Error())
+				}
+			}
+		}
+	}
+	return retry, err
+}
+
+// Flush sends the buffered messages, retrying with exponential backoff
+// the batches whose partitions have no leader or failed to be sent,
+// after asking for the leaders again. Messages rejected for other reasons
+// are counted in Failed.
+func (p *Producer) Flush() error {
+	batches := p.buffer
+	p.buffer = make(map[int32][]record)
+	clear(p.sizes)
+	p.buffered = 0
+	backoff := p.Backoff
+	for attempt := 0; len(batches) > 0; attempt++ {
+		retry, err := p.send(batches)
+		if len(retry) == 0 {
+			return nil
+		}
+		if attempt == p.Retries {
+			if err == nil {
+				err = errors.New("partitions still without a leader")
+			}
+			return fmt.Errorf("giving up after %d retries: %v", p.Retries, err)
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxBackoff)
+		if err := p.refresh(); err != nil {
+			return err
+		}
+		batches = retry
+	}
+	return nil
+}
+
+// Close sends the buffered messages, closes the connections and returns
+// an error describing the first messages rejected, if any were.
+func (p *Producer) Close() error {
+	defer p.closeConns()
+	if p.metadata != nil {
+		if err := p.Flush(); err != nil {
+			return err
+		}
+	}
+	if p.Failed > 0 {
+		return fmt.Errorf("%d messages rejected: %s", p.Failed, strings.Join(p.errors, "; "))
+	}
+	return nil
+}
+
+// murmur2 is the hash the Java client partitions keys by.
+func murmur2(data []byte) uint32 {
+	const m = 0x5bd1e995
+	h := uint32(0x9747b28c) ^ uint32(len(data))
+	n := len(data) &^ 3
+	for i := 0; i < n; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(data) & 3 {
+	case 3:
+		h ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[n])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
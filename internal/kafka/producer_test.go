@@ -0,0 +1,219 @@
+package kafka
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A cluster is a fake cluster of one broker with the partitions of the
+// topic wikipedia, whose handle implements the requests of a producer.
+type cluster struct {
+	*fakeBroker
+	partitions int
+	sasl       bool // whether the connections must authenticate first
+
+	mu         sync.Mutex
+	leaderless int                // Metadata responses left without a leader for partition 0
+	codes      map[int32]int16    // the error code returned for a partition, once
+	messages   map[int32][]string // the values produced, by partition
+	authed     map[int]bool       // the connections authenticated
+}
+
+func newCluster(t *testing.T, partitions int) *cluster {
+	c := &cluster{partitions: partitions, codes: make(map[int32]int16), messages: make(map[int32][]string), authed: make(map[int]bool)}
+	c.fakeBroker = newFakeBroker(t, c.handle)
+	return c
+}
+
+func (c *cluster) handle(r request) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var e encoder
+	if c.sasl && !c.authed[r.conn] && r.apiKey != apiSaslHandshake && r.apiKey != apiSaslAuthenticate {
+		c.t.Errorf("request %d on connection %d before authenticating", r.apiKey, r.conn)
+		return nil
+	}
+	switch r.apiKey {
+	case apiSaslHandshake:
+		e.int16(0)
+		e.int32(1)
+		e.string("PLAIN")
+	case apiSaslAuthenticate:
+		d := &decoder{buf: r.body}
+		if auth := string(d.take(int(d.int32()))); auth == "\x00wiki\x00s3cret" {
+			c.authed[r.conn] = true
+			e.int16(0)
+		} else {
+			e.int16(errSaslAuthentication)
+		}
+		e.int16(-1)
+		e.int32(0)
+	case apiMetadata:
+		host, port := c.hostPort()
+		e.int32(1)
+		e.int32(7)
+		e.string(host)
+		e.int32(port)
+		e.int16(-1)
+		e.int32(7)
+		e.int32(1)
+		e.int16(0)
+		e.string("wikipedia")
+		e.int8(0)
+		e.int32(int32(c.partitions))
+		for i := range int32(c.partitions) {
+			leader := int32(7)
+			if i == 0 && c.leaderless > 0 {
+				leader = -1
+			}
+			e.int16(0)
+			e.int32(i)
+			e.int32(leader)
+			e.int32(0)
+			e.int32(0)
+		}
+		if c.leaderless > 0 {
+			c.leaderless--
+		}
+	case apiProduce:
+		d := &decoder{buf: r.body}
+		if id := d.int16(); id != -1 {
+			c.t.Errorf("transactional id of %d bytes", id)
+		}
+		if acks := d.int16(); acks != -1 {
+			c.t.Errorf("acks %d", acks)
+		}
+		d.int32() // the timeout
+		e.int32(1)
+		for range d.array() {
+			topic := d.string()
+			e.string(topic)
+			n := d.array()
+			e.int32(int32(n))
+			for range n {
+				partition := d.int32()
+				records, _ := readBatch(c.t, d.take(int(d.int32())))
+				code := c.codes[partition]
+				delete(c.codes, partition)
+				if code == 0 {
+					for _, rec := range records {
+						c.messages[partition] = append(c.messages[partition], string(rec.value))
+					}
+				}
+				e.int32(partition)
+				e.int16(code)
+				e.int64(0)
+				e.int64(-1)
+			}
+		}
+		e.int32(0) // the throttle time
+	default:
+		c.t.Errorf("unexpected request %d", r.apiKey)
+		return nil
+	}
+	return e.buf
+}
+
+// produced returns the values produced to the partitions, sorted.
+func (c *cluster) produced() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var values []string
+	for partition, messages := range c.messages {
+		for _, m := range messages {
+			values = append(values, fmt.Sprintf("%d %s", partition, m))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func TestProducer(t *testing.T) {
+	tests := []struct {
+		name       string
+		sasl       bool
+		password   string
+		leaderless int
+		codes      map[int32]int16 // returned once for the partitions
+		want       string          // the error of Close, if any
+		rejected   []string        // the keys of the messages rejected
+	}{
+		{name: "plain"},
+		{name: "sasl", sasl: true, password: "s3cret"},
+		{name: "wrong password", sasl: true, password: "secret", want: "kafka: user wiki: SASL authentication failed"},
+		{name: "no leader at first", leaderless: 2},
+		{name: "not the leader", codes: map[int32]int16{1: errNotLeader}},
+		{name: "too large", codes: map[int32]int16{2: errMessageTooLarge}, rejected: []string{"Moon", "Apollo 12"},
+			want: "2 messages rejected: kafka: 2 messages of partition 2: message too large"},
+		{name: "no leader for good", leaderless: 10, want: "giving up after 3 retries: kafka: partition 0: leader not available"},
+	}
+	keys := []string{"Apollo 11", "Moon", "Saturn V", "Apollo 12", "Neil Armstrong", "Buzz Aldrin"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCluster(t, 3)
+			c.sasl, c.leaderless, c.codes = tt.sasl, tt.leaderless, tt.codes
+			if c.codes == nil {
+				c.codes = make(map[int32]int16)
+			}
+			p := NewProducer([]string{c.addr()}, "wikipedia")
+			p.Batch, p.Retries, p.Backoff, p.Timeout = 4, 3, time.Millisecond, time.Second
+			if tt.sasl {
+				p.Username, p.Password = "wiki", tt.password
+			}
+			var err error
+			var want []string
+			for _, key := range keys {
+				if err = p.Send([]byte(key), []byte("value of "+key)); err != nil {
+					break
+				}
+				if !slices.Contains(tt.rejected, key) {
+					partition := int32(murmur2([]byte(key))&0x7fffffff) % 3
+					want = append(want, fmt.Sprintf("%d value of %s", partition, key))
+				}
+			}
+			if closeErr := p.Close(); err == nil {
+				err = closeErr
+			}
+			if errorString(err) != tt.want {
+				t.Fatalf("producing fails with %q, want %q", errorString(err), tt.want)
+			}
+			if tt.want != "" && tt.rejected == nil {
+				return
+			}
+			sort.Strings(want)
+			if got := c.produced(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("produced\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			if p.Produced != len(want) || p.Failed != len(tt.rejected) {
+				t.Errorf("Produced %d, Failed %d, want %d and %d", p.Produced, p.Failed, len(want), len(tt.rejected))
+			}
+			for _, r := range c.received() {
+				if r.clientID != "wikiparse" {
+					t.Errorf("client id %q", r.clientID)
+				}
+			}
+		})
+	}
+}
+
+func TestKeylessPartitions(t *testing.T) {
+	c := newCluster(t, 3)
+	p := NewProducer([]string{c.addr()}, "wikipedia")
+	for i := range 6 {
+		if err := p.Send(nil, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "0 0\n0 3\n1 1\n1 4\n2 2\n2 5"
+	if got := strings.Join(c.produced(), "\n"); got != want {
+		t.Errorf("produced\n%s\nwant\n%s", got, want)
+	}
+}
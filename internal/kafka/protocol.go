@@ -0,0 +1,377 @@
+// The parts of the Kafka protocol a producer needs: the Metadata and
+// Produce requests, record batches of the message format v2, and the
+// SaslHandshake and SaslAuthenticate requests of SASL/PLAIN
+
+package kafka
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+// The API keys and versions of the requests sent.
+const (
+	apiProduce              = 0
+	apiMetadata             = 3
+	apiSaslHandshake        = 17
+	apiSaslAuthenticate     = 36
+	produceVersion          = 3
+	metadataVersion         = 1
+	saslHandshakeVersion    = 1
+	saslAuthenticateVersion = 0
+)
+
+// maxResponse bounds the size of responses read.
+const maxResponse = 64 << 20
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// An encoder appends the primitive types of the protocol.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8) { e.buf = append(e.buf, byte(v)) }
+
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *encoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// bytes appends bytes prefixed with their length as an int32.
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// varbytes appends bytes prefixed with their length as a varint, -1 for
+// nil, as in records.
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+var errShort = errors.New("kafka: short response")
+
+// A decoder reads the primitive types of the protocol. Reading past the
+// end sets err and returns zeros.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errShort
+		return make([]byte, max(n, 0))
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 { return int8(d.take(1)[0]) }
+
+func (d *decoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.take(2))) }
+
+func (d *decoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.take(4))) }
+
+func (d *decoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.take(8))) }
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// array returns the number of elements of an array, reading them is up
+// to the caller.
+func (d *decoder) array() int {
+	n := d.int32()
+	if n < 0 || int(n) > len(d.buf) {
+		// Every element takes at least a byte.
+		if n > 0 {
+			d.err = errShort
+		}
+		return 0
+	}
+	return int(n)
+}
+
+// A conn is a connection to a broker, on which requests are sent one at
+// a time.
+type conn struct {
+	c        net.Conn
+	r        *bufio.Reader
+	clientID string
+	timeout  time.Duration
+	id       int32 // the correlation id of the last request
+}
+
+// dial connects to the broker at addr, over TLS with the config if it
+// is not nil.
+func dial(addr string, clientID string, timeout time.Duration, config *tls.Config) (*conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var c net.Conn
+	var err error
+	if config != nil {
+		c, err = tls.DialWithDialer(dialer, "tcp", addr, config)
+	} else {
+		c, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c: c, r: bufio.NewReader(c), clientID: clientID, timeout: timeout}, nil
+}
+
+// roundTrip sends the request with the body and returns the body of the
+// response.
+func (c *conn) roundTrip(apiKey int16, version int16, body []byte) ([]byte, error) {
+	c.id++
+	var e encoder
+	e.int32(0) // the size, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(c.id)
+	e.string(c.clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	c.c.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.c.Write(e.buf); err != nil {
+		return nil, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if size < 4 || size > maxResponse {
+		return nil, fmt.Errorf("kafka: response of %d bytes", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:])); id != c.id {
+		return nil, fmt.Errorf("kafka: response %d to request %d", id, c.id)
+	}
+	response := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *conn) close() error {
+	return c.c.Close()
+}
+
+// saslPlain authenticates the connection as the user with SASL/PLAIN:
+// a SaslHandshake request for the mechanism, then a SaslAuthenticate
+// request with the credentials.
+func (c *conn) saslPlain(username, password string) error {
+	var e encoder
+	e.string("PLAIN")
+	response, err := c.roundTrip(apiSaslHandshake, saslHandshakeVersion, e.buf)
+	if err != nil {
+		return err
+	}
+	d := &decoder{buf: response}
+	code := d.int16()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		return &Error{Code: code, What: "SASL mechanism PLAIN"}
+	}
+	e = encoder{}
+	e.bytes([]byte("\x00" + username + "\x00" + password))
+	if response, err = c.roundTrip(apiSaslAuthenticate, saslAuthenticateVersion, e.buf); err != nil {
+		return err
+	}
+	d = &decoder{buf: response}
+	code = d.int16()
+	message := d.string()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		err := &Error{Code: code, What: "user " + username}
+		if message != "" {
+			return fmt.Errorf("%w (%s)", err, message)
+		}
+		return err
+	}
+	return nil
+}
+
+// topicMetadata is what a Metadata response tells of a topic: the
+// brokers and the leaders of its partitions, by partition index.
+type topicMetadata struct {
+	brokers map[int32]string
+	leaders []int32 // -1 for partitions without a leader
+}
+
+// metadata asks for the partitions of the topic.
+func (c *conn) metadata(topic string) (*topicMetadata, error) {
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	response, err := c.roundTrip(apiMetadata, metadataVersion, e.buf)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: response}
+	m := &topicMetadata{brokers: make(map[int32]string)}
+	for range d.array() {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // the rack
+		m.brokers[id] = net.JoinHostPort(host, fmt.Sprint(port))
+	}
+	d.int32() // the controller
+	code := int16(0)
+	for range d.array() {
+		topicCode := d.int16()
+		name := d.string()
+		d.int8() // is internal
+		if name == topic {
+			code = topicCode
+		}
+		for range d.array() {
+			d.int16() // the error code; partitions without a leader are retried
+			index := d.int32()
+			leader := d.int32()
+			for range d.array() {
+				d.int32()
+			}
+			for range d.array() {
+				d.int32()
+			}
+			if name == topic && index >= 0 && int(index) < 1<<16 {
+				for len(m.leaders) <= int(index) {
+					m.leaders = append(m.leaders, -1)
+				}
+				m.leaders[index] = leader
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if code != 0 {
+		return nil, &Error{Code: code, What: "topic " + topic}
+	}
+	if len(m.leaders) == 0 {
+		return nil, &Error{Code: errLeaderNotAvailable, What: "topic " + topic}
+	}
+	return m, nil
+}
+
+// A record is a message of a batch.
+type record struct {
+	key   []byte
+	value []byte
+}
+
+// appendBatch appends a record batch of the message format v2 with the
+// records, uncompressed, timestamped now.
+func appendBatch(buf []byte, records []record, now time.Time) []byte {
+	var e encoder
+	e.buf = buf
+	e.int64(0) // the base offset, assigned by the broker
+	lengthAt := len(e.buf)
+	e.int32(0) // the length of the rest of the batch, set below
+	e.int32(-1)
+	e.int8(2) // the magic number of the format
+	crcAt := len(e.buf)
+	e.int32(0) // the CRC-32C of the rest, set below
+	e.int16(0) // attributes: no compression, create time
+	e.int32(int32(len(records) - 1))
+	ms := now.UnixMilli()
+	e.int64(ms)
+	e.int64(ms)
+	e.int64(-1) // no producer id
+	e.int16(-1)
+	e.int32(-1)
+	e.int32(int32(len(records)))
+	var r encoder
+	for i, rec := range records {
+		r.buf = r.buf[:0]
+		r.int8(0)
+		r.varint(0) // the timestamp delta
+		r.varint(int64(i))
+		r.varbytes(rec.key)
+		r.varbytes(rec.value)
+		r.varint(0) // no headers
+		e.varint(int64(len(r.buf)))
+		e.buf = append(e.buf, r.buf...)
+	}
+	binary.BigEndian.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-lengthAt-4))
+	binary.BigEndian.PutUint32(e.buf[crcAt:], crc32.Checksum(e.buf[crcAt+4:], castagnoli))
+	return e.buf
+}
+
+// produce sends the batches of the partitions in one Produce request,
+// waiting for all in-sync replicas, and returns the error code of every
+// partition.
+func (c *conn) produce(topic string, batches map[int32][]record, timeout time.Duration) (map[int32]int16, error) {
+	var e encoder
+	e.int16(-1) // no transactional id
+	e.int16(-1) // acks from all in-sync replicas
+	e.int32(int32(timeout.Milliseconds()))
+	e.int32(1)
+	e.string(topic)
+	e.int32(int32(len(batches)))
+	now := time.Now()
+	batch := make([]byte, 0, 1<<16)
+	for partition, records := range batches {
+		e.int32(partition)
+		batch = appendBatch(batch[:0], records, now)
+		e.int32(int32(len(batch)))
+		e.buf = append(e.buf, batch...)
+	}
+	response, err := c.roundTrip(apiProduce, produceVersion, e.buf)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: response}
+	codes := make(map[int32]int16, len(batches))
+	for range d.array() {
+		d.string()
+		for range d.array() {
+			partition := d.int32()
+			codes[partition] = d.int16()
+			d.int64() // the base offset
+			d.int64() // the log append time
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	for partition := range batches {
+		if _, ok := codes[partition]; !ok {
+			return nil, fmt.Errorf("kafka: no response for partition %d", partition)
+		}
+	}
+	return codes, nil
+}
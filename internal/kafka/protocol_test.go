@@ -0,0 +1,396 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A request is a request received by a fakeBroker.
+type request struct {
+	raw      []byte // all of it, with the size
+	apiKey   int16
+	version  int16
+	clientID string
+	body     []byte
+	conn     int // the number of the connection, from 1
+}
+
+// A fakeBroker answers the requests on its connections with the bodies
+// returned by handle, and records them.
+type fakeBroker struct {
+	t      *testing.T
+	ln     net.Listener
+	handle func(r request) []byte
+
+	mu       sync.Mutex
+	requests []request
+	conns    int
+}
+
+func newFakeBroker(t *testing.T, handle func(r request) []byte) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, handle: handle}
+	go b.serve()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeBroker) addr() string { return b.ln.Addr().String() }
+
+// hostPort returns the host and port of the broker, as in the Metadata
+// responses.
+func (b *fakeBroker) hostPort() (string, int32) {
+	a := b.ln.Addr().(*net.TCPAddr)
+	return a.IP.String(), int32(a.Port)
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		c, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns++
+		n := b.conns
+		b.mu.Unlock()
+		go b.serveConn(c, n)
+	}
+}
+
+func (b *fakeBroker) serveConn(c net.Conn, n int) {
+	defer c.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(c, size[:]); err != nil {
+			return
+		}
+		raw := make([]byte, 4+binary.BigEndian.Uint32(size[:]))
+		copy(raw, size[:])
+		if _, err := io.ReadFull(c, raw[4:]); err != nil {
+			return
+		}
+		d := &decoder{buf: raw[4:]}
+		r := request{raw: raw, apiKey: d.int16(), version: d.int16(), conn: n}
+		id := d.int32()
+		r.clientID = d.string()
+		r.body = d.buf
+		b.mu.Lock()
+		b.requests = append(b.requests, r)
+		b.mu.Unlock()
+		body := b.handle(r)
+		if body == nil {
+			return
+		}
+		var e encoder
+		e.int32(int32(4 + len(body)))
+		e.int32(id)
+		e.buf = append(e.buf, body...)
+		if _, err := c.Write(e.buf); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) received() []request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]request(nil), b.requests...)
+}
+
+// frame returns the bytes of a request sent by the client wikiparse as
+// the first on its connection.
+func frame(apiKey, version int16, body ...byte) []byte {
+	var e encoder
+	e.int32(int32(2 + 2 + 4 + 2 + len("wikiparse") + len(body)))
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(1)
+	e.string("wikiparse")
+	return append(e.buf, body...)
+}
+
+func TestSaslPlain(t *testing.T) {
+	tests := []struct {
+		name      string
+		handshake []byte // the body of the response
+		auth      []byte
+		want      string // the error, if any
+	}{
+		{"ok", []byte{0, 0, 0, 0, 0, 1, 0, 5, 'P', 'L', 'A', 'I', 'N'}, []byte{0, 0, 0xff, 0xff, 0, 0, 0, 0}, ""},
+		{"unsupported", []byte{0, 33, 0, 0, 0, 1, 0, 5, 'S', 'C', 'R', 'A', 'M'}, nil,
+			"kafka: SASL mechanism PLAIN: unsupported SASL mechanism"},
+		{"wrong password", []byte{0, 0, 0, 0, 0, 0}, []byte{0, 58, 0, 12, 'b', 'a', 'd', ' ', 'p', 'a', 's', 's', 'w', 'o', 'r', 'd', 0, 0, 0, 0},
+			"kafka: user wiki: SASL authentication failed (bad password)"},
+		{"short", []byte{0}, nil, "kafka: short response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBroker(t, func(r request) []byte {
+				if r.apiKey == apiSaslHandshake {
+					return tt.handshake
+				}
+				return tt.auth
+			})
+			c, err := dial(b.addr(), "wikiparse", time.Second, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.close()
+			err = c.saslPlain("wiki", "s3cret")
+			if got := errorString(err); got != tt.want {
+				t.Fatalf("saslPlain = %q, want %q", got, tt.want)
+			}
+			requests := b.received()
+			if want := frame(apiSaslHandshake, 1, 0, 5, 'P', 'L', 'A', 'I', 'N'); !bytes.Equal(requests[0].raw, want) {
+				t.Errorf("SaslHandshake request\n% x\nwant\n% x", requests[0].raw, want)
+			}
+			if tt.auth == nil {
+				if len(requests) != 1 {
+					t.Errorf("%d requests after the handshake failed", len(requests))
+				}
+				return
+			}
+			want := []byte{0, 0, 0, 19, 0, 36, 0, 0, 0, 0, 0, 2, 0, 9}
+			want = append(want, "wikiparse"...)
+			want = append(want, 0, 0, 0, 12)
+			want = append(want, "\x00wiki\x00s3cret"...)
+			binary.BigEndian.PutUint32(want, uint32(len(want)-4))
+			if !bytes.Equal(requests[1].raw, want) {
+				t.Errorf("SaslAuthenticate request\n% x\nwant\n% x", requests[1].raw, want)
+			}
+		})
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestRoundTripErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte // written as is, with the size and correlation id
+		want     string
+	}{
+		{"other request", []byte{0, 0, 0, 4, 0, 0, 0, 7}, "kafka: response 7 to request 1"},
+		{"too small", []byte{0, 0, 0, 2, 0, 0, 0, 1}, "kafka: response of 2 bytes"},
+		{"too large", []byte{0x7f, 0, 0, 0, 0, 0, 0, 1}, "kafka: response of 2130706432 bytes"},
+		{"cut off", []byte{0, 0, 0, 8, 0, 0, 0, 1, 0}, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go func() {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+				c.Read(make([]byte, 64))
+				c.Write(tt.response)
+			}()
+			c, err := dial(ln.Addr().String(), "wikiparse", time.Second, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.close()
+			if _, err := c.roundTrip(apiMetadata, metadataVersion, nil); errorString(err) != tt.want {
+				t.Errorf("roundTrip = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	var e encoder
+	e.int32(2) // brokers
+	e.int32(1)
+	e.string("kafka-1")
+	e.int32(9092)
+	e.int16(-1) // no rack
+	e.int32(2)
+	e.string("kafka-2")
+	e.int32(9093)
+	e.string("rack-b")
+	e.int32(1) // the controller
+	e.int32(2) // topics
+	e.int16(0)
+	e.string("other")
+	e.int8(0)
+	e.int32(0)
+	e.int16(0)
+	e.string("wikipedia")
+	e.int8(0)
+	e.int32(2) // partitions, out of order
+	for _, p := range [][2]int32{{2, 1}, {0, 2}} {
+		e.int16(0)
+		e.int32(p[0])
+		e.int32(p[1])
+		e.int32(1) // replicas
+		e.int32(p[1])
+		e.int32(0) // in-sync replicas
+	}
+	response := e.buf
+	b := newFakeBroker(t, func(r request) []byte { return response })
+	c, err := dial(b.addr(), "wikiparse", time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	m, err := c.metadata("wikipedia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := frame(apiMetadata, 1, 0, 0, 0, 1, 0, 9, 'w', 'i', 'k', 'i', 'p', 'e', 'd', 'i', 'a')
+	if got := b.received()[0].raw; !bytes.Equal(got, want) {
+		t.Errorf("Metadata request\n% x\nwant\n% x", got, want)
+	}
+	if m.brokers[1] != "kafka-1:9092" || m.brokers[2] != "kafka-2:9093" || len(m.brokers) != 2 {
+		t.Errorf("brokers %v", m.brokers)
+	}
+	if len(m.leaders) != 3 || m.leaders[0] != 2 || m.leaders[1] != -1 || m.leaders[2] != 1 {
+		t.Errorf("leaders %v, want [2 -1 1]", m.leaders)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		response []byte
+		want     string
+	}{
+		{"unknown topic", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 3, 0, 9, 'w', 'i', 'k', 'i', 'p', 'e', 'd', 'i', 'a', 0, 0, 0, 0, 0},
+			"kafka: topic wikipedia: unknown topic or partition"},
+		{"no partitions", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "kafka: topic wikipedia: leader not available"},
+		{"short", []byte{0, 0, 0, 1, 0, 0}, "kafka: short response"},
+		{"huge array", []byte{0x7f, 0xff, 0xff, 0xff}, "kafka: short response"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBroker(t, func(r request) []byte { return tt.response })
+			c, err := dial(b.addr(), "wikiparse", time.Second, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.close()
+			if _, err := c.metadata("wikipedia"); errorString(err) != tt.want {
+				t.Errorf("metadata = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// readBatch decodes a record batch written by appendBatch, checking its
+// length and CRC, and returns its records and timestamp.
+func readBatch(t *testing.T, batch []byte) ([]record, time.Time) {
+	t.Helper()
+	d := &decoder{buf: batch}
+	if offset := d.int64(); offset != 0 {
+		t.Errorf("base offset %d", offset)
+	}
+	if length := d.int32(); int(length) != len(d.buf) {
+		t.Fatalf("batch length %d, %d bytes follow", length, len(d.buf))
+	}
+	d.int32() // the leader epoch
+	if magic := d.int8(); magic != 2 {
+		t.Fatalf("magic %d", magic)
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)) {
+		t.Errorf("CRC %#x does not match", crc)
+	}
+	if attributes := d.int16(); attributes != 0 {
+		t.Errorf("attributes %#x", attributes)
+	}
+	last := d.int32()
+	first, max := d.int64(), d.int64()
+	if first != max {
+		t.Errorf("timestamps %d and %d", first, max)
+	}
+	if id, epoch, seq := d.int64(), d.int16(), d.int32(); id != -1 || epoch != -1 || seq != -1 {
+		t.Errorf("producer id %d, epoch %d, sequence %d", id, epoch, seq)
+	}
+	n := d.int32()
+	if last != n-1 {
+		t.Errorf("last offset delta %d of %d records", last, n)
+	}
+	varint := func() int64 {
+		v, k := binary.Varint(d.buf)
+		if k <= 0 {
+			t.Fatal("bad varint")
+		}
+		d.buf = d.buf[k:]
+		return v
+	}
+	varbytes := func() []byte {
+		n := varint()
+		if n < 0 {
+			return nil
+		}
+		return d.take(int(n))
+	}
+	var records []record
+	for i := range n {
+		size := varint()
+		rest := len(d.buf)
+		d.int8() // attributes
+		if delta := varint(); delta != 0 {
+			t.Errorf("timestamp delta %d", delta)
+		}
+		if delta := varint(); delta != int64(i) {
+			t.Errorf("offset delta %d of record %d", delta, i)
+		}
+		records = append(records, record{varbytes(), varbytes()})
+		if headers := varint(); headers != 0 {
+			t.Errorf("%d headers", headers)
+		}
+		if int(size) != rest-len(d.buf) {
+			t.Errorf("record %d of %d bytes has %d", i, size, rest-len(d.buf))
+		}
+	}
+	if d.err != nil || len(d.buf) > 0 {
+		t.Fatalf("batch: %v, %d bytes left", d.err, len(d.buf))
+	}
+	return records, time.UnixMilli(first)
+}
+
+func TestBatch(t *testing.T) {
+	records := []record{
+		{[]byte("Apollo 11"), []byte(`{"title":"Apollo 11"}`)},
+		{nil, []byte(`{"title":"Moon"}`)},
+		{[]byte(""), nil},
+		{[]byte("Saturn V"), bytes.Repeat([]byte("x"), 300)},
+	}
+	now := time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC)
+	prefix := []byte("kept")
+	batch := appendBatch(append([]byte(nil), prefix...), records, now)
+	if !bytes.HasPrefix(batch, prefix) {
+		t.Fatalf("appendBatch overwrote the buffer")
+	}
+	got, timestamp := readBatch(t, batch[len(prefix):])
+	if !timestamp.Equal(now) {
+		t.Errorf("timestamp %v, want %v", timestamp, now)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("%d records, want %d", len(got), len(records))
+	}
+	for i, r := range got {
+		if !bytes.Equal(r.key, records[i].key) || (r.key == nil) != (records[i].key == nil) ||
+			!bytes.Equal(r.value, records[i].value) || (r.value == nil) != (records[i].value == nil) {
+			t.Errorf("record %d = %q %q, want %q %q", i, r.key, r.value, records[i].key, records[i].value)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package metrics keeps the counters and gauges of a run, like the pages
+// read and the syntax errors found, and writes them in the text format
+// Prometheus scrapes, served at /metrics for monitoring production
+// pipelines.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// A Counter is a value that only goes up, like the number of pages read.
+// It is safe for use in several goroutines at once.
+type Counter struct {
+	v atomic.Int64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add adds n, which must not be negative, to the counter.
+func (c *Counter) Add(n int64) {
+	c.v.Add(n)
+}
+
+// Value returns the value of the counter.
+func (c *Counter) Value() int64 {
+	return c.v.Load()
+}
+
+// A Gauge is a value that goes up and down, like the number of pages
+// queued. It is safe for use in several goroutines at once.
+type Gauge struct {
+	v atomic.Int64
+}
+
+// Add adds n, which may be negative, to the gauge.
+func (g *Gauge) Add(n int64) {
+	g.v.Add(n)
+}
+
+// Set sets the gauge to n.
+func (g *Gauge) Set(n int64) {
+	g.v.Store(n)
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() int64 {
+	return g.v.Load()
+}
+
+// A metric is a counter or gauge of a registry.
+type metric struct {
+	name  string
+	help  string
+	kind  string // "counter" or "gauge"
+	value func() int64
+}
+
+// A Registry holds the metrics of a run, in the order they were added.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.metrics {
+		if other.name == m.name {
+			panic("metrics: " + m.name + " added twice")
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// Counter adds a counter with the name, like "wikiparse_pages_read_total",
+// and help text.
+func (r *Registry) Counter(name string, help string) *Counter {
+	c := &Counter{}
+	r.add(metric{name, help, "counter", c.Value})
+	return c
+}
+
+// Gauge adds a gauge with the name and help text.
+func (r *Registry) Gauge(name string, help string) *Gauge {
+	g := &Gauge{}
+	r.add(metric{name, help, "gauge", g.Value})
+	return g
+}
+
+// WriteTo writes the metrics in the text exposition format of
+// Prometheus, with the help and type of every metric before its value.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := r.metrics
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	n := int64(0)
+	for _, m := range metrics {
+		k, _ := fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			m.name, escapeHelp(m.help), m.name, m.kind, m.name, strconv.FormatInt(m.value(), 10))
+		n += int64(k)
+	}
+	return n, bw.Flush()
+}
+
+// escapeHelp escapes the backslashes and line breaks of a help text.
+func escapeHelp(help string) string {
+	b := make([]byte, 0, len(help))
+	for i := 0; i < len(help); i++ {
+		switch help[i] {
+		case '\\':
+			b = append(b, `\\`...)
+		case '\n':
+			b = append(b, `\n`...)
+		default:
+			b = append(b, help[i])
+		}
+	}
+	return string(b)
+}
+
+// ServeHTTP answers a scrape with the metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
@@ -0,0 +1,3 @@
+// Package mmap maps files into memory read-only, or reads them into
+// memory on the platforms without mmap.
+package mmap
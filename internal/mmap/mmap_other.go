@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd)
+
+package mmap
+
+import (
+	"io"
+	"os"
+)
+
+// Map reads the file into memory, as it cannot be mapped on this
+// platform, and returns its contents with a function doing nothing.
+func Map(file *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
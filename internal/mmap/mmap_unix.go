@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package mmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// Map maps the file into memory read-only and returns its contents with
+// the function unmapping them.
+func Map(file *os.File) ([]byte, func() error, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
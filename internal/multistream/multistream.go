@@ -0,0 +1,179 @@
+// Package multistream reads the bzip2 multistream dumps of Wikimedia,
+// like enwiki-latest-pages-articles-multistream.xml.bz2, decompressing
+// several of their streams at once. The streams of these dumps, of a
+// hundred pages each but the first, with the siteinfo, and the last, are
+// independent, and the index published with the dump,
+// enwiki-latest-pages-articles-multistream-index.txt.bz2, tells where
+// they start, in lines "offset:page id:title" per page.
+package multistream
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IndexPath returns the path of the index published with the dump at
+// path, or "" if path is not that of a multistream dump.
+func IndexPath(path string) string {
+	base, ok := strings.CutSuffix(path, "-multistream.xml.bz2")
+	if !ok {
+		return ""
+	}
+	return base + "-multistream-index.txt.bz2"
+}
+
+// ReadIndex returns the offsets of the streams told by the index at
+// path, bzip2 compressed if its name ends in .bz2, in ascending order.
+func ReadIndex(path string) ([]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(file)
+	}
+	offsets := make([]int64, 0, 1024)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		field, _, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		offset, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("%s:%d: bad offset %q", path, line, field)
+		}
+		// The pages of a stream are listed one after the other.
+		if n := len(offsets); n == 0 || offsets[n-1] != offset {
+			offsets = append(offsets, offset)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	slices.Sort(offsets)
+	return slices.Compact(offsets), nil
+}
+
+// A chunk is the result of decompressing the streams between two
+// offsets.
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// A Reader reads the XML of a multistream dump, decompressed by several
+// goroutines at once, in the order of the dump.
+type Reader struct {
+	order <-chan chan chunk // the results of the streams, in order
+	done  chan struct{}
+	once  sync.Once // closing done
+	wg    sync.WaitGroup
+	data  []byte // the rest of the current chunk
+	err   error
+}
+
+// ahead is how many streams per worker are decompressed ahead of the one
+// being read.
+const ahead = 2
+
+// NewReader returns a reader of the dump in r of size bytes, whose
+// streams start at the offsets, as read by ReadIndex, decompressing up to
+// workers of them at once. The bytes before the first offset and after
+// the last, which hold the first and the last streams of the dump, are
+// decompressed too. The reader is to be closed to stop its goroutines
+// before it is read to the end.
+func NewReader(r io.ReaderAt, size int64, offsets []int64, workers int) *Reader {
+	bounds := make([]int64, 0, len(offsets)+2)
+	bounds = append(bounds, 0)
+	for _, o := range offsets {
+		if o > bounds[len(bounds)-1] && o < size {
+			bounds = append(bounds, o)
+		}
+	}
+	bounds = append(bounds, size)
+
+	type job struct {
+		start, end int64
+		result     chan chunk
+	}
+	workers = max(workers, 1)
+	jobs := make(chan job)
+	order := make(chan chan chunk, ahead*workers)
+	m := &Reader{order: order, done: make(chan struct{})}
+	for range workers {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			for j := range jobs {
+				data, err := io.ReadAll(bzip2.NewReader(io.NewSectionReader(r, j.start, j.end-j.start)))
+				if err != nil {
+					err = fmt.Errorf("bzip2 stream at offset %d: %v", j.start, err)
+				}
+				j.result <- chunk{data, err}
+			}
+		}()
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(order)
+		defer close(jobs)
+		for i := 1; i < len(bounds); i++ {
+			j := job{bounds[i-1], bounds[i], make(chan chunk, 1)}
+			select {
+			case order <- j.result:
+			case <-m.done:
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-m.done:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// Read reads the decompressed XML. It fails with the error of the first
+// stream that cannot be decompressed.
+func (m *Reader) Read(p []byte) (int, error) {
+	for len(m.data) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		result, ok := <-m.order
+		if !ok {
+			m.err = io.EOF
+			continue
+		}
+		c := <-result
+		m.data, m.err = c.data, c.err
+		if m.err != nil {
+			m.data = nil
+		}
+	}
+	n := copy(p, m.data)
+	m.data = m.data[n:]
+	return n, nil
+}
+
+// Close stops the goroutines of the reader, once those decompressing a
+// stream have finished it.
+func (m *Reader) Close() error {
+	m.once.Do(func() { close(m.done) })
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,226 @@
+// Package mwapi fetches the current revisions of pages from the Action
+// API of a MediaWiki wiki as XML exports, the format of the dumps, so
+// that they are read like a dump of those pages alone. Requests are
+// spaced out, wait while the replicas of the wiki lag behind, as the
+// maxlag parameter asks bots to, and their responses can be cached.
+package mwapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBatch is the most titles the API exports in one request to clients
+// without the apihighlimits right.
+const MaxBatch = 50
+
+// A Client fetches pages from the API at URL, like
+// "https://en.wikipedia.org/w/api.php".
+type Client struct {
+	URL       string
+	UserAgent string        // identifies the client to the wiki, as Wikimedia requires
+	Batch     int           // the titles per request, at most MaxBatch
+	Interval  time.Duration // the least time between the starts of two requests
+	MaxLag    int           // the seconds of replication lag from which the wiki turns requests away (not sent if 0)
+	Retries   int           // how often a request turned away or failed is retried
+	CacheDir  string        // the directory responses are cached in (none if empty)
+	CacheAge  time.Duration // how long a cached response is used (always if 0)
+	Client    *http.Client
+
+	last time.Time // the start of the last request
+}
+
+// maxWait limits the wait before a retry.
+const maxWait = time.Minute
+
+// NewClient returns a Client for the API at url.
+func NewClient(url string) *Client {
+	return &Client{
+		URL:       url,
+		UserAgent: "wikiparse (https://github.com/pcmoritz/wikipedia)",
+		Batch:     MaxBatch,
+		Interval:  time.Second,
+		MaxLag:    5,
+		Retries:   5,
+		Client:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// exportQuery returns the query of the export of the pages with the
+// titles, all of the response being the XML.
+func exportQuery(titles []string) url.Values {
+	return url.Values{
+		"action":       {"query"},
+		"export":       {"1"},
+		"exportnowrap": {"1"},
+		"titles":       {strings.Join(titles, "|")},
+		"format":       {"json"}, // of errors
+	}
+}
+
+// cachePath returns the file the response to the query is cached in.
+func (c *Client) cachePath(query url.Values) string {
+	sum := sha256.Sum256([]byte(c.URL + "?" + query.Encode()))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(sum[:16])+".xml")
+}
+
+// cached returns the cached response to the query, or false if there is
+// none or it is older than CacheAge.
+func (c *Client) cached(query url.Values) ([]byte, bool) {
+	if c.CacheDir == "" {
+		return nil, false
+	}
+	path := c.cachePath(query)
+	info, err := os.Stat(path)
+	if err != nil || c.CacheAge > 0 && time.Since(info.ModTime()) > c.CacheAge {
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	return body, err == nil
+}
+
+// cache stores the response to the query, replacing the file at once so
+// that an interrupted run leaves no partial response.
+func (c *Client) cache(query url.Values, body []byte) error {
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return err
+	}
+	path := c.cachePath(query)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sleep waits for d, or returns the error of ctx if it is cancelled
+// first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter returns the wait the response asks for, or d if it does not.
+func retryAfter(resp *http.Response, d time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxWait)
+	}
+	return d
+}
+
+// get sends the query once Interval has passed since the last request
+// and returns the body of the response. Requests turned away for
+// replication lag or load are retried after the wait the wiki asks for,
+// failed ones after Interval doubled for each retry.
+func (c *Client) get(ctx context.Context, query url.Values) ([]byte, error) {
+	if c.MaxLag > 0 {
+		query = maps.Clone(query)
+		query.Set("maxlag", strconv.Itoa(c.MaxLag))
+	}
+	backoff := max(c.Interval, time.Second)
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, time.Until(c.last.Add(c.Interval))); err != nil {
+			return nil, err
+		}
+		c.last = time.Now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", c.UserAgent)
+		var wait time.Duration
+		resp, err := c.Client.Do(req)
+		if err == nil {
+			var body []byte
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			code := resp.Header.Get("MediaWiki-API-Error")
+			switch {
+			case err != nil:
+			case code == "maxlag" || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+				err = fmt.Errorf("%s: turned away (%s)", c.URL, strings.TrimSpace(resp.Status+" "+code))
+				wait = retryAfter(resp, backoff)
+			case code != "":
+				// Errors of the request itself are not retried.
+				return nil, fmt.Errorf("%s: %s: %.200s", c.URL, code, body)
+			case resp.StatusCode != http.StatusOK:
+				err = fmt.Errorf("%s: %s", c.URL, resp.Status)
+			default:
+				return body, nil
+			}
+		}
+		if ctx.Err() != nil || attempt >= c.Retries {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = backoff
+		}
+		backoff = min(2*backoff, maxWait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Export returns the XML export of the current revisions of the pages
+// with the titles, at most Batch of them, which starts with the siteinfo
+// of the wiki; without titles, it has no pages. Titles of pages that do
+// not exist are left out. Responses younger than CacheAge are read from
+// CacheDir instead of the wiki.
+func (c *Client) Export(ctx context.Context, titles []string) ([]byte, error) {
+	query := exportQuery(titles)
+	if body, ok := c.cached(query); ok {
+		return body, nil
+	}
+	body, err := c.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if c.CacheDir != "" {
+		if err := c.cache(query, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// Reader returns a reader of the exports of the pages with the titles,
+// Batch of them at a time, one after the other. The pages are read from
+// it as from a dump, like by dump.Pages. Reading it fails with the error
+// of the first request that fails for good. It is to be read to its end
+// or until ctx is cancelled.
+func (c *Client) Reader(ctx context.Context, titles []string) io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		batch := max(min(c.Batch, MaxBatch), 1)
+		for start := 0; start < len(titles); start += batch {
+			body, err := c.Export(ctx, titles[start:min(start+batch, len(titles))])
+			if err == nil {
+				_, err = w.Write(body)
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		w.Close()
+	}()
+	return r
+}
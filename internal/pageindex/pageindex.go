@@ -0,0 +1,292 @@
+// Package pageindex builds an on-disk index of the pages of a dump by
+// title and by page id, with the offset of every page in the dump file,
+// so that single pages are read from the dump without scanning it. The
+// index file is mapped into memory where the platform allows it, and a
+// lookup reads a slot and an entry.
+//
+// An index file holds, after the magic line padded to 16 bytes:
+//
+//	the header         uint64 page count and slot count, a power of two
+//	the title slots    uint64 per slot, the offset of an entry plus one,
+//	                   or 0 if empty, by the FNV-1a hash of its canonical
+//	                   title and linear probing
+//	the id slots       the same, by the hash of its page id
+//	the entries        per page: int64 page id and dump offset, then its
+//	                   title and the title of its redirect target, empty
+//	                   for other pages (uvarint lengths and bytes)
+//
+// Integers of fixed size are little-endian.
+package pageindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"iter"
+	"os"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/mmap"
+)
+
+const magic = "wikipages 1\n"
+
+// headerSize is the size of the magic line, padded, and the counts.
+const headerSize = 16 + 2*8
+
+// maxRedirectHops bounds the redirects Resolve follows, like those of
+// dump.RedirectTable.
+const maxRedirectHops = 5
+
+var errCorrupt = errors.New("pageindex: corrupt index file")
+
+// An Entry is a page of the index.
+type Entry struct {
+	ID       int64
+	Offset   int64  // of the <page> element in the dump, for dump.ReadPageAt
+	Title    string // as in the dump
+	Redirect string // the title of the target of a redirect, empty for other pages
+}
+
+// A Builder collects the entries of the pages added in memory and writes
+// the index.
+type Builder struct {
+	entries []Entry
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Len returns the number of pages added.
+func (b *Builder) Len() int {
+	return len(b.entries)
+}
+
+// Add adds a page. Of pages with the same canonical title or id, the
+// first added is found.
+func (b *Builder) Add(e Entry) {
+	b.entries = append(b.entries, e)
+}
+
+func titleHash(title string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(dump.CanonicalizeTitle(title)))
+	return h.Sum64()
+}
+
+func idHash(id int64) uint64 {
+	return uint64(id) * 0x9e3779b97f4a7c15
+}
+
+// slotCount returns the number of slots for n pages: a power of two at
+// least twice n, so that probes stay short.
+func slotCount(n int) uint64 {
+	slots := uint64(16)
+	for slots < 2*uint64(n) {
+		slots *= 2
+	}
+	return slots
+}
+
+// entrySize returns the size of the entry in the file.
+func entrySize(e *Entry) uint64 {
+	size := 16
+	for _, s := range []string{e.Title, e.Redirect} {
+		size += len(binary.AppendUvarint(nil, uint64(len(s)))) + len(s)
+	}
+	return uint64(size)
+}
+
+// Write writes the index to the file at path, replacing it.
+func (b *Builder) Write(path string) error {
+	slots := slotCount(len(b.entries))
+	titles := make([]uint64, slots)
+	ids := make([]uint64, slots)
+	insert := func(table []uint64, h uint64, offset uint64) {
+		i := h & (slots - 1)
+		for table[i] != 0 {
+			i = (i + 1) & (slots - 1)
+		}
+		table[i] = offset + 1
+	}
+	offset := uint64(headerSize) + 16*slots
+	for _, e := range b.entries {
+		insert(titles, titleHash(e.Title), offset)
+		insert(ids, idHash(e.ID), offset)
+		offset += entrySize(&e)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	buf := make([]byte, 16, 64)
+	copy(buf, magic)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(b.entries)))
+	buf = binary.LittleEndian.AppendUint64(buf, slots)
+	w.Write(buf)
+	for _, table := range [][]uint64{titles, ids} {
+		for _, o := range table {
+			w.Write(binary.LittleEndian.AppendUint64(buf[:0], o))
+		}
+	}
+	for _, e := range b.entries {
+		buf = binary.LittleEndian.AppendUint64(buf[:0], uint64(e.ID))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.Offset))
+		buf = binary.AppendUvarint(buf, uint64(len(e.Title)))
+		w.Write(buf)
+		w.WriteString(e.Title)
+		w.Write(binary.AppendUvarint(buf[:0], uint64(len(e.Redirect))))
+		w.WriteString(e.Redirect)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// An Index is an index file opened for lookups. It is safe for use in
+// several goroutines at once.
+type Index struct {
+	data   []byte
+	unmap  func() error
+	pages  uint64
+	slots  uint64
+	titles []byte // the title slots
+	ids    []byte // the id slots
+}
+
+// Open opens the index file at path.
+func Open(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, unmap, err := mmap.Map(file)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{data: data, unmap: unmap}
+	if err := ix.load(); err != nil {
+		unmap()
+		return nil, err
+	}
+	return ix, nil
+}
+
+func (ix *Index) load() error {
+	if len(ix.data) < headerSize || string(ix.data[:len(magic)]) != magic {
+		return errCorrupt
+	}
+	ix.pages = binary.LittleEndian.Uint64(ix.data[16:])
+	ix.slots = binary.LittleEndian.Uint64(ix.data[24:])
+	if ix.slots == 0 || ix.slots&(ix.slots-1) != 0 || ix.slots < ix.pages ||
+		ix.slots > uint64(len(ix.data)-headerSize)/16 {
+		return errCorrupt
+	}
+	ix.titles = ix.data[headerSize : headerSize+8*ix.slots]
+	ix.ids = ix.data[headerSize+8*ix.slots : headerSize+16*ix.slots]
+	return nil
+}
+
+// Close closes the index file.
+func (ix *Index) Close() error {
+	return ix.unmap()
+}
+
+// Len returns the number of pages of the index.
+func (ix *Index) Len() int {
+	return int(ix.pages)
+}
+
+// entry decodes the entry at the offset.
+func (ix *Index) entry(offset uint64) (Entry, error) {
+	if offset+16 > uint64(len(ix.data)) {
+		return Entry{}, errCorrupt
+	}
+	e := Entry{
+		ID:     int64(binary.LittleEndian.Uint64(ix.data[offset:])),
+		Offset: int64(binary.LittleEndian.Uint64(ix.data[offset+8:])),
+	}
+	rest := ix.data[offset+16:]
+	for _, s := range []*string{&e.Title, &e.Redirect} {
+		n, size := binary.Uvarint(rest)
+		if size <= 0 || n > uint64(len(rest)-size) {
+			return Entry{}, errCorrupt
+		}
+		*s = string(rest[size : size+int(n)])
+		rest = rest[size+int(n):]
+	}
+	return e, nil
+}
+
+// Entries returns an iterator over the pages of the index in the order
+// they were added, that of the dump for an index built by reading it. A
+// corrupt entry ends the iteration with errCorrupt.
+func (ix *Index) Entries() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		offset := uint64(headerSize) + 16*ix.slots
+		for range ix.pages {
+			e, err := ix.entry(offset)
+			if !yield(e, err) || err != nil {
+				return
+			}
+			offset += entrySize(&e)
+		}
+	}
+}
+
+// probe returns the first entry from the slot of the hash on in the
+// table for which match is true.
+func (ix *Index) probe(table []byte, h uint64, match func(e *Entry) bool) (Entry, bool, error) {
+	for i, n := h&(ix.slots-1), uint64(0); n < ix.slots; i, n = (i+1)&(ix.slots-1), n+1 {
+		offset := binary.LittleEndian.Uint64(table[8*i:])
+		if offset == 0 {
+			break
+		}
+		e, err := ix.entry(offset - 1)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		if match(&e) {
+			return e, true, nil
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// Lookup returns the page with the title, compared as canonical titles.
+func (ix *Index) Lookup(title string) (Entry, bool, error) {
+	can := dump.CanonicalizeTitle(title)
+	return ix.probe(ix.titles, titleHash(title), func(e *Entry) bool {
+		return dump.CanonicalizeTitle(e.Title) == can
+	})
+}
+
+// LookupID returns the page with the page id.
+func (ix *Index) LookupID(id int64) (Entry, bool, error) {
+	return ix.probe(ix.ids, idHash(id), func(e *Entry) bool {
+		return e.ID == id
+	})
+}
+
+// Resolve is like Lookup, but follows redirects to the page they
+// eventually redirect to, if it is in the index. Cycles and overly long
+// chains stop at the last page found.
+func (ix *Index) Resolve(title string) (Entry, bool, error) {
+	e, ok, err := ix.Lookup(title)
+	for i := 0; ok && e.Redirect != "" && i < maxRedirectHops; i++ {
+		next, found, err := ix.Lookup(e.Redirect)
+		if err != nil || !found || next.Offset == e.Offset {
+			return e, ok, err
+		}
+		e = next
+	}
+	return e, ok, err
+}
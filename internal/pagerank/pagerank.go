@@ -0,0 +1,176 @@
+// Package pagerank computes the PageRank and the degrees of the nodes of
+// a directed graph with more edges than fit in memory: the edges are
+// written to a temporary file as they are added and read again once per
+// iteration, so that only a few numbers per node are held in memory.
+//
+// The edge file holds, per edge: the ids of its source and target
+// (uvarints).
+package pagerank
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+var errCorrupt = errors.New("pagerank: corrupt edge file")
+
+// A Graph collects the edges between ids, which are mapped to the nodes
+// ranked only when the ranks are computed. The zero value is not usable;
+// create Graphs with NewGraph.
+type Graph struct {
+	dir   string
+	file  *os.File // the edge file, created with the first edge
+	w     *bufio.Writer
+	buf   []byte
+	edges int64
+	err   error // of writing the edge file, returned by Rank
+}
+
+// NewGraph returns a Graph without edges, writing its edge file to dir,
+// or the default directory for temporary files if dir is empty.
+func NewGraph(dir string) *Graph {
+	return &Graph{dir: dir, buf: make([]byte, 0, 2*binary.MaxVarintLen64)}
+}
+
+// AddEdge adds an edge from the id from to the id to.
+func (g *Graph) AddEdge(from int, to int) {
+	if g.err != nil {
+		return
+	}
+	if g.file == nil {
+		if g.file, g.err = os.CreateTemp(g.dir, "edges"); g.err != nil {
+			return
+		}
+		g.w = bufio.NewWriterSize(g.file, 1<<16)
+	}
+	g.buf = binary.AppendUvarint(g.buf[:0], uint64(from))
+	g.buf = binary.AppendUvarint(g.buf, uint64(to))
+	if _, err := g.w.Write(g.buf); err != nil {
+		g.err = err
+	}
+	g.edges++
+}
+
+// Edges returns the number of edges added.
+func (g *Graph) Edges() int64 {
+	return g.edges
+}
+
+// Options are the parameters of the computation.
+type Options struct {
+	Damping    float64 // the probability of following a link, like 0.85
+	Iterations int     // the most iterations done
+	Tolerance  float64 // the L1 change of the ranks below which they have converged
+}
+
+// A Result holds the ranks and degrees of the nodes.
+type Result struct {
+	Rank       []float64 // summing to 1
+	In, Out    []int32   // the edges into and out of the node
+	Iterations int       // done until the ranks converged
+	Converged  bool
+}
+
+// Rank computes the PageRank of n nodes, those the ids of the edges are
+// mapped to by node: the edges from and to the id i are those of
+// node[i], unless it is negative, which drops them, as do ids beyond
+// node. Edges from a node to itself are dropped too. The rank of nodes
+// without edges out of them is spread over all nodes.
+func (g *Graph) Rank(node []int32, n int, opts Options) (*Result, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	res := &Result{Rank: make([]float64, n), In: make([]int32, n), Out: make([]int32, n)}
+	if n == 0 {
+		return res, nil
+	}
+	// The degrees are counted in a first pass over the edges.
+	err := g.scan(node, func(from, to int32) {
+		res.Out[from]++
+		res.In[to]++
+	})
+	if err != nil {
+		return nil, err
+	}
+	share := make([]float64, n) // the rank a node passes along each edge
+	next := make([]float64, n)
+	for i := range res.Rank {
+		res.Rank[i] = 1 / float64(n)
+	}
+	for res.Iterations < opts.Iterations && !res.Converged {
+		dangling := 0.0
+		for i, r := range res.Rank {
+			if res.Out[i] == 0 {
+				dangling += r
+				share[i] = 0
+			} else {
+				share[i] = r / float64(res.Out[i])
+			}
+		}
+		clear(next)
+		if err := g.scan(node, func(from, to int32) { next[to] += share[from] }); err != nil {
+			return nil, err
+		}
+		base := (1-opts.Damping)/float64(n) + opts.Damping*dangling/float64(n)
+		change := 0.0
+		for i := range next {
+			next[i] = base + opts.Damping*next[i]
+			change += math.Abs(next[i] - res.Rank[i])
+		}
+		res.Rank, next = next, res.Rank
+		res.Iterations++
+		res.Converged = change < opts.Tolerance
+	}
+	return res, nil
+}
+
+// scan calls fn with the nodes of every edge kept by node.
+func (g *Graph) scan(node []int32, fn func(from, to int32)) error {
+	if g.file == nil {
+		return nil
+	}
+	if err := g.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := g.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(g.file, 1<<16)
+	lookup := func(id uint64) int32 {
+		if id >= uint64(len(node)) {
+			return -1
+		}
+		return node[id]
+	}
+	for {
+		from, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errCorrupt
+		}
+		to, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errCorrupt
+		}
+		if f, t := lookup(from), lookup(to); f >= 0 && t >= 0 && f != t {
+			fn(f, t)
+		}
+	}
+}
+
+// Close removes the edge file.
+func (g *Graph) Close() error {
+	if g.file == nil {
+		return nil
+	}
+	g.file.Close()
+	err := os.Remove(g.file.Name())
+	g.file = nil
+	return err
+}
@@ -0,0 +1,352 @@
+// Package pagestore keeps the items lexed from the wikitext of pages in
+// an append-only file of compressed records, so that later passes over
+// the same dump read them instead of lexing the pages again. An index
+// file beside it maps the titles to their records, along with a digest
+// of the wikitext the items were lexed from, so that pages whose text
+// changed are lexed again.
+//
+// The store file holds, after the magic line and the key of the store
+// (a uvarint length and bytes), per record: its uvarint length and the
+// DEFLATE compressed items, per item its type, value, start and end
+// position, and for an ItemError the kind of its SyntaxError (uvarints,
+// the value as its length and bytes). The index file holds, after the
+// magic line, per record: its title (a uvarint length and bytes), the
+// SHA-256 of its wikitext and the uvarint offset of the record. Both are
+// only appended to; a title added again refers to its last record.
+//
+// The standard library has no zstd, so the records are DEFLATE
+// compressed, the compression of gzip.
+package pagestore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+const (
+	magic      = "wikistore 1\n"
+	indexMagic = "wikistore index 1\n"
+)
+
+var errCorrupt = errors.New("pagestore: corrupt record")
+
+// maxValue bounds the values of items read, which cannot be longer than
+// the wikitext of a page.
+const maxValue = 1 << 30
+
+// errorKinds are the kinds of syntax errors of ItemError items, by the
+// number they are stored as.
+var errorKinds = []error{
+	nil, // not one of those below
+	wikitext.ErrBadXML,
+	wikitext.ErrBadNumber,
+	wikitext.ErrMalformedTemplate,
+	wikitext.ErrMalformedLink,
+	wikitext.ErrUnclosedComment,
+	wikitext.ErrDepthExceeded,
+}
+
+type entry struct {
+	digest [sha256.Size]byte
+	offset int64
+}
+
+// A Store is a store file opened for reading and adding records. It is
+// safe for use in several goroutines at once.
+type Store struct {
+	mu      sync.RWMutex
+	data    *os.File
+	index   *os.File
+	size    int64 // of the store file
+	entries map[string]entry
+	err     error // of the first record that could not be added
+}
+
+// Open opens the store at path, with its index at path+".idx", creating
+// them if they do not exist. A store written with another key, like the
+// version of the code whose lexer made the items, is emptied, as are a
+// store and index that do not match.
+func Open(path string, key string) (*Store, error) {
+	data, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(path+".idx", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	s := &Store{data: data, index: index, entries: make(map[string]entry)}
+	if err := s.load(key); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// header returns the start of the store file with the key.
+func header(key string) []byte {
+	h := append([]byte(magic), binary.AppendUvarint(nil, uint64(len(key)))...)
+	return append(h, key...)
+}
+
+// load reads the index, dropping the entries from the first one that is
+// incomplete or whose record is, and the records not indexed, which an
+// interrupted run may leave. A store of another key is emptied.
+func (s *Store) load(key string) error {
+	h := header(key)
+	start := make([]byte, len(h))
+	if n, _ := io.ReadFull(s.data, start); n < len(h) || !bytes.Equal(start, h) {
+		return s.reset(h)
+	}
+	info, err := s.data.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(s.index)
+	line := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(r, line); err != nil || string(line) != indexMagic {
+		return s.reset(h)
+	}
+	valid := int64(len(indexMagic)) // the bytes of the index read
+	s.size = int64(len(h))          // the bytes of the records indexed
+	for {
+		var e entry
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > 1<<20 {
+			break
+		}
+		title := make([]byte, n)
+		if _, err := io.ReadFull(r, title); err != nil {
+			break
+		}
+		if _, err := io.ReadFull(r, e.digest[:]); err != nil {
+			break
+		}
+		offset, err := binary.ReadUvarint(r)
+		if err != nil {
+			break
+		}
+		e.offset = int64(offset)
+		length, err := s.recordLength(e.offset)
+		if err != nil || e.offset+length > info.Size() {
+			break
+		}
+		s.entries[string(title)] = e
+		s.size = max(s.size, e.offset+length)
+		valid += int64(uvarintLen(n)) + int64(n) + sha256.Size + int64(uvarintLen(offset))
+	}
+	if err := s.index.Truncate(valid); err != nil {
+		return err
+	}
+	if _, err := s.index.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	return s.data.Truncate(s.size)
+}
+
+// uvarintLen returns the number of bytes of x as a uvarint.
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// reset empties the store and its index.
+func (s *Store) reset(h []byte) error {
+	clear(s.entries)
+	for _, f := range []*os.File{s.data, s.index} {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+	}
+	if _, err := s.data.WriteAt(h, 0); err != nil {
+		return err
+	}
+	if _, err := s.index.WriteAt([]byte(indexMagic), 0); err != nil {
+		return err
+	}
+	if _, err := s.index.Seek(int64(len(indexMagic)), io.SeekStart); err != nil {
+		return err
+	}
+	s.size = int64(len(h))
+	return nil
+}
+
+// recordLength returns the length of the record at offset with its
+// uvarint length.
+func (s *Store) recordLength(offset int64) (int64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n, _ := s.data.ReadAt(buf[:], offset)
+	length, size := binary.Uvarint(buf[:n])
+	if size <= 0 {
+		return 0, errCorrupt
+	}
+	return int64(size) + int64(length), nil
+}
+
+// Len returns the number of pages in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Get returns the items of the page with the title, or false if the store
+// has none for the wikitext, as when the text of the page changed.
+func (s *Store) Get(title string, text string) ([]wikitext.Item, bool, error) {
+	s.mu.RLock()
+	e, ok := s.entries[title]
+	s.mu.RUnlock()
+	if !ok || e.digest != sha256.Sum256([]byte(text)) {
+		return nil, false, nil
+	}
+	length, err := s.recordLength(e.offset)
+	if err != nil {
+		return nil, false, err
+	}
+	record := make([]byte, length)
+	if _, err := s.data.ReadAt(record, e.offset); err != nil {
+		return nil, false, err
+	}
+	_, size := binary.Uvarint(record)
+	items, err := decodeItems(flate.NewReader(bytes.NewReader(record[size:])))
+	if err != nil {
+		return nil, false, err
+	}
+	return items, true, nil
+}
+
+// Put adds the items lexed from the wikitext of the page with the title.
+func (s *Store) Put(title string, text string, items []wikitext.Item) error {
+	var compressed bytes.Buffer
+	w, _ := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err := encodeItems(w, items); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	record := binary.AppendUvarint(make([]byte, 0, compressed.Len()+binary.MaxVarintLen64), uint64(compressed.Len()))
+	record = append(record, compressed.Bytes()...)
+	digest := sha256.Sum256([]byte(text))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	offset := s.size
+	if _, err := s.data.WriteAt(record, offset); err != nil {
+		s.err = err
+		return err
+	}
+	e := binary.AppendUvarint(nil, uint64(len(title)))
+	e = append(e, title...)
+	e = append(e, digest[:]...)
+	e = binary.AppendUvarint(e, uint64(offset))
+	if _, err := s.index.Write(e); err != nil {
+		s.err = err
+		return err
+	}
+	s.size += int64(len(record))
+	s.entries[title] = entry{digest, offset}
+	return nil
+}
+
+// Close closes the store and returns the error of the first record that
+// could not be added, if any.
+func (s *Store) Close() error {
+	err := s.err
+	for _, f := range []*os.File{s.data, s.index} {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// encodeItems writes the items as stored.
+func encodeItems(w io.Writer, items []wikitext.Item) error {
+	buf := binary.AppendUvarint(make([]byte, 0, 256), uint64(len(items)))
+	for _, s := range items {
+		buf = binary.AppendUvarint(buf, uint64(s.Type))
+		buf = binary.AppendUvarint(buf, uint64(len(s.Val)))
+		buf = append(buf, s.Val...)
+		for _, p := range []wikitext.Pos{s.Start, s.End} {
+			buf = binary.AppendUvarint(buf, uint64(p.Offset))
+			buf = binary.AppendUvarint(buf, uint64(p.Line))
+			buf = binary.AppendUvarint(buf, uint64(p.Column))
+		}
+		if s.Type == wikitext.ItemError {
+			kind := 0
+			var e *wikitext.SyntaxError
+			if errors.As(s.Err, &e) {
+				for i, k := range errorKinds {
+					if k != nil && k == e.Kind {
+						kind = i
+					}
+				}
+			}
+			buf = binary.AppendUvarint(buf, uint64(kind))
+		}
+		if len(buf) > 1<<16 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// decodeItems reads the items written by encodeItems.
+func decodeItems(r io.Reader) ([]wikitext.Item, error) {
+	br := bufio.NewReader(r)
+	var err error
+	next := func() int {
+		var n uint64
+		if err == nil {
+			n, err = binary.ReadUvarint(br)
+		}
+		return int(n)
+	}
+	n := next()
+	if err != nil || n > 1<<28 {
+		return nil, errCorrupt
+	}
+	items := make([]wikitext.Item, 0, n)
+	for range n {
+		var s wikitext.Item
+		s.Type = wikitext.ItemType(next())
+		val := make([]byte, min(next(), maxValue))
+		if err == nil {
+			_, err = io.ReadFull(br, val)
+		}
+		s.Val = string(val)
+		s.Start = wikitext.Pos{Offset: next(), Line: next(), Column: next()}
+		s.End = wikitext.Pos{Offset: next(), Line: next(), Column: next()}
+		if s.Type == wikitext.ItemError {
+			kind := next()
+			if kind >= len(errorKinds) {
+				return nil, errCorrupt
+			}
+			s.Err = &wikitext.SyntaxError{Kind: errorKinds[kind], Pos: s.Start, Msg: s.Val}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorrupt, err)
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
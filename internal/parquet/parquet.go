@@ -0,0 +1,390 @@
+// Package parquet writes Apache Parquet files with the standard library
+// only, for analytics tools like Spark, DuckDB and pandas to load the
+// outputs of the loader. Values are PLAIN encoded and uncompressed, and
+// columns are required values or required lists of strings.
+package parquet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// A Kind is the type of the values of a column.
+type Kind int
+
+const (
+	Int32      Kind = iota // int32 or int values
+	Int64                  // int64 values
+	String                 // UTF-8 strings
+	StringList             // lists of UTF-8 strings, as []string values
+)
+
+// A Column is a column of a file.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// The sizes at which pages and row groups are completed. Pages end and
+// row groups are written between rows only.
+const (
+	pageSize     = 1 << 20
+	rowGroupSize = 64 << 20
+)
+
+// The numbers of the enums of the format used here.
+const (
+	typeInt32     = 1
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionRequired = 0
+	repetitionRepeated = 2
+
+	convertedUTF8 = 0
+	convertedList = 3
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageData = 0
+)
+
+// A chunk collects the pages of a column in the current row group.
+type chunk struct {
+	values []byte  // the PLAIN encoded values of the current page
+	levels []uint8 // the repetition and definition levels of its values, of lists
+	count  int     // the number of values of the page, with empty lists
+	pages  []byte  // the finished pages of the row group, with their headers
+	total  int64   // the number of values of the row group
+}
+
+// A Writer writes a Parquet file row by row.
+type Writer struct {
+	file     *os.File
+	out      *bufio.Writer
+	offset   int64
+	columns  []Column
+	chunks   []chunk
+	rows     int64 // the rows of the current row group
+	buffered int
+	groups   []rowGroup
+	metadata [][2]string
+	err      error
+}
+
+// A rowGroup is the metadata of a row group written.
+type rowGroup struct {
+	rows    int64
+	size    int64
+	offsets []int64 // the offset of each column chunk
+	sizes   []int64
+	values  []int64
+}
+
+// Create creates the file, replacing an existing one, for rows of the
+// given columns.
+func Create(path string, columns []Column) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: file, out: bufio.NewWriter(file), columns: columns, chunks: make([]chunk, len(columns))}
+	w.write([]byte("PAR1"))
+	return w, nil
+}
+
+// SetMetadata adds a key and value to the metadata of the file.
+func (w *Writer) SetMetadata(key string, value string) {
+	w.metadata = append(w.metadata, [2]string{key, value})
+}
+
+func (w *Writer) write(p []byte) {
+	if w.err == nil {
+		_, w.err = w.out.Write(p)
+		w.offset += int64(len(p))
+	}
+}
+
+// Write appends a row with a value for each column.
+func (w *Writer) Write(row ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: got %d values for %d columns", len(row), len(w.columns))
+	}
+	for i, v := range row {
+		if !valid(w.columns[i].Kind, v) {
+			return fmt.Errorf("parquet: column %s: unexpected value of type %T", w.columns[i].Name, v)
+		}
+	}
+	for i, v := range row {
+		c := &w.chunks[i]
+		size := len(c.values)
+		switch v := v.(type) {
+		case int:
+			c.values = binary.LittleEndian.AppendUint32(c.values, uint32(int32(v)))
+			c.count++
+		case int32:
+			c.values = binary.LittleEndian.AppendUint32(c.values, uint32(v))
+			c.count++
+		case int64:
+			c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+			c.count++
+		case string:
+			c.values = appendByteArray(c.values, v)
+			c.count++
+		case []string:
+			// An empty list has a single value of definition level 0.
+			// Otherwise the definition levels are 1 and the repetition
+			// levels 1 for all but the first element, which are packed
+			// into one byte per value as repetition<<1 | definition.
+			if len(v) == 0 {
+				c.levels = append(c.levels, 0)
+				c.count++
+			}
+			for j, s := range v {
+				c.levels = append(c.levels, byte(min(j, 1))<<1|1)
+				c.values = appendByteArray(c.values, s)
+				c.count++
+			}
+		}
+		w.buffered += len(c.values) - size
+	}
+	w.rows++
+	for i := range w.chunks {
+		if len(w.chunks[i].values) >= pageSize {
+			w.finishPage(i)
+		}
+	}
+	if w.buffered >= rowGroupSize {
+		w.flushRowGroup()
+	}
+	return w.err
+}
+
+// valid reports whether v is a value of a column of the kind.
+func valid(kind Kind, v any) bool {
+	switch v.(type) {
+	case int, int32:
+		return kind == Int32
+	case int64:
+		return kind == Int64
+	case string:
+		return kind == String
+	case []string:
+		return kind == StringList
+	}
+	return false
+}
+
+func appendByteArray(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// appendLevels appends levels in the RLE encoding of the format, as runs
+// of equal levels of one bit width, prefixed by their length in bytes.
+func appendLevels(b []byte, levels []uint8, shift uint) []byte {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for i := 0; i < len(levels); {
+		level := (levels[i] >> shift) & 1
+		j := i + 1
+		for j < len(levels) && (levels[j]>>shift)&1 == level {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		b = append(b, level)
+		i = j
+	}
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start-4))
+	return b
+}
+
+// finishPage completes the current page of the i-th column.
+func (w *Writer) finishPage(i int) {
+	c := &w.chunks[i]
+	if c.count == 0 {
+		return
+	}
+	var data []byte
+	if w.columns[i].Kind == StringList {
+		data = appendLevels(data, c.levels, 1)
+		data = appendLevels(data, c.levels, 0)
+	}
+	data = append(data, c.values...)
+
+	var h thriftWriter
+	h.begin()
+	h.i32(1, pageData)
+	h.i32(2, int32(len(data)))
+	h.i32(3, int32(len(data)))
+	h.structField(5)
+	h.i32(1, int32(c.count))
+	h.i32(2, encodingPlain)
+	h.i32(3, encodingRLE)
+	h.i32(4, encodingRLE)
+	h.end()
+	h.end()
+
+	c.pages = append(c.pages, h.buf...)
+	c.pages = append(c.pages, data...)
+	c.total += int64(c.count)
+	c.values, c.levels, c.count = c.values[:0], c.levels[:0], 0
+}
+
+// flushRowGroup writes the buffered rows as a row group.
+func (w *Writer) flushRowGroup() {
+	if w.rows == 0 {
+		return
+	}
+	g := rowGroup{rows: w.rows}
+	for i := range w.chunks {
+		w.finishPage(i)
+		c := &w.chunks[i]
+		g.offsets = append(g.offsets, w.offset)
+		g.sizes = append(g.sizes, int64(len(c.pages)))
+		g.values = append(g.values, c.total)
+		g.size += int64(len(c.pages))
+		w.write(c.pages)
+		c.pages, c.total = c.pages[:0], 0
+	}
+	w.groups = append(w.groups, g)
+	w.rows, w.buffered = 0, 0
+}
+
+// physicalType returns the type the values of the column are stored as.
+func physicalType(kind Kind) int32 {
+	switch kind {
+	case Int32:
+		return typeInt32
+	case Int64:
+		return typeInt64
+	}
+	return typeByteArray
+}
+
+// path returns the path of the leaf of the column in the schema.
+func (c Column) path() []string {
+	if c.Kind == StringList {
+		return []string{c.Name, "list", "element"}
+	}
+	return []string{c.Name}
+}
+
+// writeSchema writes the schema as the flattened list of its elements,
+// with lists in the three-level structure of the format.
+func (w *Writer) writeSchema(t *thriftWriter) {
+	elements := 1
+	for _, c := range w.columns {
+		if c.Kind == StringList {
+			elements += 3
+		} else {
+			elements++
+		}
+	}
+	t.list(2, thriftStruct, elements)
+	t.begin()
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	leaf := func(name string, kind Kind) {
+		t.begin()
+		t.i32(1, physicalType(kind))
+		t.i32(3, repetitionRequired)
+		t.string(4, name)
+		if kind == String || kind == StringList {
+			t.i32(6, convertedUTF8)
+		}
+		t.end()
+	}
+	for _, c := range w.columns {
+		if c.Kind != StringList {
+			leaf(c.Name, c.Kind)
+			continue
+		}
+		t.begin()
+		t.i32(3, repetitionRequired)
+		t.string(4, c.Name)
+		t.i32(5, 1)
+		t.i32(6, convertedList)
+		t.end()
+		t.begin()
+		t.i32(3, repetitionRepeated)
+		t.string(4, "list")
+		t.i32(5, 1)
+		t.end()
+		leaf("element", c.Kind)
+	}
+}
+
+// Close writes the buffered rows and the metadata of the file and closes
+// it. It returns the first error of writing.
+func (w *Writer) Close() error {
+	w.flushRowGroup()
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1)
+	w.writeSchema(&t)
+	rows := int64(0)
+	for _, g := range w.groups {
+		rows += g.rows
+	}
+	t.i64(3, rows)
+	t.list(4, thriftStruct, len(w.groups))
+	for _, g := range w.groups {
+		t.begin()
+		t.list(1, thriftStruct, len(w.columns))
+		for i, c := range w.columns {
+			t.begin()
+			t.i64(2, g.offsets[i])
+			t.structField(3)
+			t.i32(1, physicalType(c.Kind))
+			t.list(2, thriftI32, 2)
+			t.zigzag(encodingPlain)
+			t.zigzag(encodingRLE)
+			path := c.path()
+			t.list(3, thriftBinary, len(path))
+			for _, p := range path {
+				t.varint(uint64(len(p)))
+				t.buf = append(t.buf, p...)
+			}
+			t.i32(4, 0) // uncompressed
+			t.i64(5, g.values[i])
+			t.i64(6, g.sizes[i])
+			t.i64(7, g.sizes[i])
+			t.i64(9, g.offsets[i])
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.end()
+	}
+	if len(w.metadata) > 0 {
+		t.list(5, thriftStruct, len(w.metadata))
+		for _, kv := range w.metadata {
+			t.begin()
+			t.string(1, kv[0])
+			t.string(2, kv[1])
+			t.end()
+		}
+	}
+	t.string(6, "github.com/pcmoritz/wikipedia")
+	t.end()
+
+	w.write(t.buf)
+	w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(t.buf))))
+	w.write([]byte("PAR1"))
+	if w.err == nil {
+		w.err = w.out.Flush()
+	}
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
@@ -0,0 +1,370 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A thriftReader decodes structs of the compact protocol to maps from
+// their field ids to int64, string, []any and nested struct values.
+type thriftReader struct {
+	buf []byte
+	err error
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.err = fmt.Errorf("thrift: unexpected end")
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = fmt.Errorf("thrift: bad varint")
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(kind byte) any {
+	switch kind {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		if n > len(r.buf) {
+			r.err = fmt.Errorf("thrift: binary of %d bytes beyond the end", n)
+			return ""
+		}
+		s := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return s
+	case thriftList:
+		header := r.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+		list := make([]any, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.err = fmt.Errorf("thrift: unexpected type %d", kind)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	s := make(map[int16]any)
+	var id int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			break
+		}
+		if delta := header >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(header & 0x0f)
+	}
+	return s
+}
+
+// readLevels decodes n levels of bit width 1 in the RLE encoding, with
+// their length in bytes first, and returns the rest of data.
+func readLevels(t *testing.T, data []byte, n int) ([]uint8, []byte) {
+	size := int(binary.LittleEndian.Uint32(data))
+	runs, rest := data[4:4+size], data[4+size:]
+	var levels []uint8
+	for len(runs) > 0 {
+		header, k := binary.Uvarint(runs)
+		if header&1 != 0 {
+			t.Fatalf("bit-packed run %#x, want RLE runs", header)
+		}
+		for i := uint64(0); i < header>>1; i++ {
+			levels = append(levels, runs[k])
+		}
+		runs = runs[k+1:]
+	}
+	if len(levels) != n {
+		t.Fatalf("%d levels for %d values", len(levels), n)
+	}
+	return levels, rest
+}
+
+// column is a column chunk read back, with the number of its pages.
+type column struct {
+	values []any
+	pages  int
+}
+
+// readChunk decodes the pages of a column chunk of n values to the
+// values of its rows.
+func readChunk(t *testing.T, chunk []byte, kind Kind, n int) column {
+	var c column
+	var read int
+	for len(chunk) > 0 {
+		r := &thriftReader{buf: chunk}
+		h := r.readStruct()
+		if r.err != nil {
+			t.Fatalf("page header: %v", r.err)
+		}
+		size := int(h[3].(int64))
+		data, rest := r.buf[:size], r.buf[size:]
+		chunk = rest
+		c.pages++
+		if h[1] != int64(pageData) || h[2] != int64(size) {
+			t.Fatalf("page header %v", h)
+		}
+		d := h[5].(map[int16]any)
+		count := int(d[1].(int64))
+		read += count
+		if d[2] != int64(encodingPlain) || d[3] != int64(encodingRLE) || d[4] != int64(encodingRLE) {
+			t.Fatalf("data page header %v", d)
+		}
+		var levels []uint8
+		if kind == StringList {
+			var repetition []uint8
+			repetition, data = readLevels(t, data, count)
+			levels, data = readLevels(t, data, count)
+			for i, rep := range repetition {
+				if rep == 0 {
+					c.values = append(c.values, []string{})
+				}
+				if levels[i] == 1 {
+					s := int(binary.LittleEndian.Uint32(data))
+					last := &c.values[len(c.values)-1]
+					*last = append((*last).([]string), string(data[4:4+s]))
+					data = data[4+s:]
+				}
+			}
+		} else {
+			for i := 0; i < count; i++ {
+				switch kind {
+				case Int32:
+					c.values = append(c.values, int32(binary.LittleEndian.Uint32(data)))
+					data = data[4:]
+				case Int64:
+					c.values = append(c.values, int64(binary.LittleEndian.Uint64(data)))
+					data = data[8:]
+				case String:
+					s := int(binary.LittleEndian.Uint32(data))
+					c.values = append(c.values, string(data[4:4+s]))
+					data = data[4+s:]
+				}
+			}
+		}
+		if len(data) > 0 {
+			t.Fatalf("%d bytes left after the values of a page", len(data))
+		}
+	}
+	if read != n {
+		t.Fatalf("the pages have %d values, the metadata %d", read, n)
+	}
+	return c
+}
+
+// readFile decodes a file written by a Writer to its metadata and the
+// columns of each row group.
+func readFile(t *testing.T, path string, columns []Column) (map[int16]any, [][]column) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("the file is not framed by PAR1")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	r := &thriftReader{buf: data[len(data)-8-size : len(data)-8]}
+	meta := r.readStruct()
+	if r.err != nil || len(r.buf) > 0 {
+		t.Fatalf("footer: %v, %d bytes left", r.err, len(r.buf))
+	}
+	var groups [][]column
+	for _, g := range meta[4].([]any) {
+		g := g.(map[int16]any)
+		chunks := g[1].([]any)
+		if len(chunks) != len(columns) {
+			t.Fatalf("%d column chunks for %d columns", len(chunks), len(columns))
+		}
+		var total int64
+		var group []column
+		for i, cc := range chunks {
+			cc := cc.(map[int16]any)
+			m := cc[3].(map[int16]any)
+			path := make([]string, 0, 3)
+			for _, p := range m[3].([]any) {
+				path = append(path, p.(string))
+			}
+			if m[1] != int64(physicalType(columns[i].Kind)) || !reflect.DeepEqual(path, columns[i].path()) {
+				t.Fatalf("column chunk %d has type %v and path %v", i, m[1], path)
+			}
+			offset, size := m[9].(int64), m[7].(int64)
+			if cc[2] != offset || m[6] != size {
+				t.Fatalf("column chunk %d: offsets %v and %v, sizes %v and %v", i, cc[2], offset, m[6], size)
+			}
+			total += size
+			c := readChunk(t, data[offset:offset+size], columns[i].Kind, int(m[5].(int64)))
+			if int64(len(c.values)) != g[3].(int64) {
+				t.Fatalf("column chunk %d has %d rows, the row group %d", i, len(c.values), g[3])
+			}
+			group = append(group, c)
+		}
+		if g[2] != total {
+			t.Errorf("row group size %v, the chunks sum to %d", g[2], total)
+		}
+		groups = append(groups, group)
+	}
+	return meta, groups
+}
+
+var testColumns = []Column{
+	{"id", Int32},
+	{"revision", Int64},
+	{"title", String},
+	{"categories", StringList},
+	{"text", String},
+}
+
+// testRow returns the values of row i, the text large enough for the
+// file to span many pages and two row groups.
+func testRow(i int) []any {
+	categories := make([]string, i%4)
+	for j := range categories {
+		categories[j] = fmt.Sprintf("Category %d", i+j)
+	}
+	return []any{int32(i - 1000), int64(i) << 40, fmt.Sprintf("Title %d", i), categories,
+		strings.Repeat(fmt.Sprintf("Moon %d ", i), 500)}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   int
+		groups int
+	}{
+		{"empty", 0, 0},
+		{"one row", 1, 1},
+		{"two row groups", 15000, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pages.parquet")
+			w, err := Create(path, testColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.SetMetadata("wikiparse.schema", "5")
+			for i := 0; i < tt.rows; i++ {
+				if err := w.Write(testRow(i)...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			meta, groups := readFile(t, path, testColumns)
+			if meta[1] != int64(1) || meta[3] != int64(tt.rows) || meta[6] != "github.com/pcmoritz/wikipedia" {
+				t.Errorf("file metadata version %v, rows %v, created by %v", meta[1], meta[3], meta[6])
+			}
+			kv := meta[5].([]any)[0].(map[int16]any)
+			if kv[1] != "wikiparse.schema" || kv[2] != "5" {
+				t.Errorf("key value metadata %v", kv)
+			}
+			if len(groups) != tt.groups {
+				t.Fatalf("%d row groups, want %d", len(groups), tt.groups)
+			}
+			row := 0
+			for _, g := range groups {
+				if tt.rows > 1000 && g[4].pages < 2 {
+					t.Errorf("the text of a row group has %d pages", g[4].pages)
+				}
+				for j := range g[0].values {
+					var got []any
+					for _, c := range g {
+						got = append(got, c.values[j])
+					}
+					if want := testRow(row); !reflect.DeepEqual(got, want) {
+						t.Fatalf("row %d = %.80v, want %.80v", row, got, want)
+					}
+					row++
+				}
+			}
+			if row != tt.rows {
+				t.Errorf("read %d rows, want %d", row, tt.rows)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var w Writer
+	w.columns = testColumns
+	var tw thriftWriter
+	tw.begin()
+	w.writeSchema(&tw)
+	tw.end()
+	r := &thriftReader{buf: tw.buf}
+	var got []string
+	for _, e := range r.readStruct()[2].([]any) {
+		e := e.(map[int16]any)
+		got = append(got, fmt.Sprintf("%v %v %v %v %v", e[4], e[1], e[3], e[5], e[6]))
+	}
+	want := []string{
+		"schema <nil> <nil> 5 <nil>",
+		"id 1 0 <nil> <nil>",
+		"revision 2 0 <nil> <nil>",
+		"title 6 0 <nil> 0",
+		"categories <nil> 0 1 3",
+		"list <nil> 2 1 <nil>",
+		"element 6 0 <nil> 0",
+		"text 6 0 <nil> 0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("schema elements (name, type, repetition, children, converted type)\n%s\nwant\n%s",
+			strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	tests := []struct {
+		row  []any
+		want string
+	}{
+		{[]any{1, int64(2)}, "parquet: got 2 values for 5 columns"},
+		{[]any{int64(1), int64(2), "a", []string{}, "b"}, "parquet: column id: unexpected value of type int64"},
+		{[]any{1, int64(2), "a", []any{"b"}, "c"}, "parquet: column categories: unexpected value of type []interface {}"},
+	}
+	w, err := Create(filepath.Join(t.TempDir(), "pages.parquet"), testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, tt := range tests {
+		if err := w.Write(tt.row...); err == nil || err.Error() != tt.want {
+			t.Errorf("Write(%v) = %v, want %q", tt.row, err, tt.want)
+		}
+	}
+}
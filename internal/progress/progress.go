@@ -0,0 +1,155 @@
+// Package progress reports how far a run has got through a dump: pages
+// and bytes read, throughput, percent complete and the estimated time
+// left, so that operators can monitor long runs.
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var pageTag = []byte("<page>")
+
+// A Reader counts the bytes and pages of a dump read through it. Pages
+// are counted by their <page> tags as they are read, ahead of the pages
+// processed by as much as the XML decoder buffers.
+type Reader struct {
+	r     io.Reader
+	total int64 // the size of the dump, 0 if unknown
+	start time.Time
+	bytes atomic.Int64
+	pages atomic.Int64
+	tail  []byte // the end of the last read, for tags split across reads
+}
+
+// NewReader returns a Reader reading from r, a dump of total bytes.
+func NewReader(r io.Reader, total int64) *Reader {
+	return &Reader{r: r, total: total, start: time.Now()}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.bytes.Add(int64(n))
+	if n > 0 {
+		data := p[:n]
+		pages := bytes.Count(data, pageTag)
+		// Only tags split between the reads match the joint, which is
+		// shorter than two tags.
+		joint := append(r.tail, data[:min(n, len(pageTag)-1)]...)
+		pages += bytes.Count(joint, pageTag)
+		r.pages.Add(int64(pages))
+		if n >= len(pageTag)-1 {
+			joint = data
+		}
+		r.tail = append(r.tail[:0:0], joint[max(len(joint)-len(pageTag)+1, 0):]...)
+	}
+	return n, err
+}
+
+// A Status is a snapshot of the progress, as written to status files.
+type Status struct {
+	Time           string  `json:"time"`
+	Pages          int64   `json:"pages"`
+	Bytes          int64   `json:"bytes"`
+	TotalBytes     int64   `json:"total_bytes,omitempty"`
+	Percent        float64 `json:"percent,omitempty"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	PagesPerSecond float64 `json:"pages_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	ETASeconds     float64 `json:"eta_seconds,omitempty"`
+}
+
+// Status returns the progress so far.
+func (r *Reader) Status() Status {
+	now := time.Now()
+	elapsed := now.Sub(r.start).Seconds()
+	s := Status{
+		Time:           now.UTC().Format(time.RFC3339),
+		Pages:          r.pages.Load(),
+		Bytes:          r.bytes.Load(),
+		TotalBytes:     r.total,
+		ElapsedSeconds: elapsed,
+	}
+	if elapsed > 0 {
+		s.PagesPerSecond = float64(s.Pages) / elapsed
+		s.BytesPerSecond = float64(s.Bytes) / elapsed
+	}
+	if r.total > 0 {
+		s.Percent = 100 * float64(s.Bytes) / float64(r.total)
+		if s.BytesPerSecond > 0 {
+			s.ETASeconds = float64(max(r.total-s.Bytes, 0)) / s.BytesPerSecond
+		}
+	}
+	return s
+}
+
+// LogValue returns the status as the group of attributes it is logged
+// as, without those that are unknown.
+func (s Status) LogValue() slog.Value {
+	const mb = 1 << 20
+	attrs := []slog.Attr{slog.Int64("pages", s.Pages), slog.Float64("mb", math.Round(float64(s.Bytes)/mb))}
+	if s.TotalBytes > 0 {
+		attrs = append(attrs, slog.Float64("total_mb", math.Round(float64(s.TotalBytes)/mb)),
+			slog.Float64("percent", math.Round(s.Percent*10)/10))
+	}
+	attrs = append(attrs, slog.Float64("pages_per_second", math.Round(s.PagesPerSecond)),
+		slog.Float64("mb_per_second", math.Round(s.BytesPerSecond/mb*10)/10))
+	if s.ETASeconds > 0 {
+		attrs = append(attrs, slog.Duration("eta", time.Duration(s.ETASeconds)*time.Second))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// writeStatus replaces the status file, so that readers never see a
+// partial one.
+func writeStatus(path string, s Status) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Report logs the status to logger, and writes it to the status file at
+// statusPath if it is not empty, every interval until the returned
+// function is called, which reports one last time.
+func (r *Reader) Report(interval time.Duration, logger *slog.Logger, statusPath string) func() {
+	report := func() {
+		s := r.Status()
+		logger.Info("Progress", "status", s)
+		if statusPath != "" {
+			if err := writeStatus(statusPath, s); err != nil {
+				logger.Error("Error writing status", "err", err)
+			}
+		}
+	}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		report()
+	}
+}
@@ -0,0 +1,150 @@
+// A gRPC server for methods that stream their responses, over HTTP/2
+// without TLS as gRPC clients connect to "insecure" channels
+
+package protobuf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The gRPC status codes of the errors a StreamHandler returns.
+const (
+	CodeOK              = 0
+	CodeCanceled        = 1
+	CodeInvalidArgument = 3
+	CodeUnimplemented   = 12
+	CodeInternal        = 13
+)
+
+// maxRequest bounds the size of request messages.
+const maxRequest = 4 << 20
+
+// A Status is an error with a gRPC status code.
+type Status struct {
+	Code    int
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", s.Code, s.Message)
+}
+
+// Errorf returns a Status with the code and the formatted message.
+func Errorf(code int, format string, args ...any) error {
+	return &Status{code, fmt.Sprintf(format, args...)}
+}
+
+// A StreamHandler answers a call of a server-streaming method, given the
+// encoded request message, by sending the messages of the response. An
+// error that is no Status ends the call with CodeInternal.
+type StreamHandler func(ctx context.Context, request []byte, send func(*Message) error) error
+
+// A Server answers gRPC calls of the methods it handles. It is an
+// http.Handler for a server that accepts unencrypted HTTP/2.
+type Server struct {
+	methods map[string]StreamHandler
+}
+
+// NewServer returns a server without methods.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]StreamHandler)}
+}
+
+// Handle registers the handler of the method, whose name is like
+// "/wikipedia.v1.Dump/StreamArticles".
+func (s *Server) Handle(method string, h StreamHandler) {
+	s.methods[method] = h
+}
+
+// ServeHTTP answers a call: it reads the request message, lets the
+// handler of the method send the response messages, each flushed at
+// once, and ends the call with the status in the trailers.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "expected a gRPC call", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	h, ok := s.methods[r.URL.Path]
+	if !ok {
+		writeStatus(w, &Status{CodeUnimplemented, "unknown method " + r.URL.Path})
+		return
+	}
+	request, err := readFrame(r.Body)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	// The status is sent in trailers after the messages.
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	frame := make([]byte, 0, 4096)
+	err = h(r.Context(), request, func(m *Message) error {
+		frame = binary.BigEndian.AppendUint32(append(frame[:0], 0), uint32(len(m.Bytes())))
+		frame = append(frame, m.Bytes()...)
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		return controller.Flush()
+	})
+	if err == nil && r.Context().Err() != nil {
+		err = &Status{CodeCanceled, r.Context().Err().Error()}
+	}
+	writeStatus(w, err)
+}
+
+// readFrame reads the request message of a call, which is not compressed
+// as the server announces no compression.
+func readFrame(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, &Status{CodeInvalidArgument, "reading the request: " + err.Error()}
+	}
+	if prefix[0] != 0 {
+		return nil, &Status{CodeUnimplemented, "compressed requests are not supported"}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxRequest {
+		return nil, &Status{CodeInvalidArgument, fmt.Sprintf("request of %d bytes, at most %d are accepted", size, maxRequest)}
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, &Status{CodeInvalidArgument, "reading the request: " + err.Error()}
+	}
+	return message, nil
+}
+
+// writeStatus sets the status of the call for the error, in the headers
+// if nothing was written yet, otherwise in the trailers.
+func writeStatus(w http.ResponseWriter, err error) {
+	status := &Status{Code: CodeOK}
+	if err != nil && !errors.As(err, &status) {
+		status = &Status{CodeInternal, err.Error()}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(status.Code))
+	if status.Message != "" {
+		w.Header().Set("Grpc-Message", percentEncode(status.Message))
+	}
+}
+
+// percentEncode encodes the message as the grpc-message header: bytes
+// that are not printable ASCII, and '%', as "%XX".
+func percentEncode(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
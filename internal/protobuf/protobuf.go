@@ -0,0 +1,142 @@
+// Package protobuf encodes and decodes messages in the Protocol Buffers
+// wire format, for the messages of proto/wikipedia.proto, and writes
+// them to files and gRPC streams.
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The wire types of fields.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+// A Message is an encoded message, built field by field. As in proto3,
+// fields with the zero value are left out.
+type Message struct {
+	buf []byte
+}
+
+func (m *Message) tag(field int, wire int) {
+	m.buf = binary.AppendUvarint(m.buf, uint64(field)<<3|uint64(wire))
+}
+
+// Int64 adds an int64 or int32 field.
+func (m *Message) Int64(field int, v int64) {
+	if v != 0 {
+		m.tag(field, wireVarint)
+		m.buf = binary.AppendUvarint(m.buf, uint64(v))
+	}
+}
+
+// Bool adds a bool field.
+func (m *Message) Bool(field int, v bool) {
+	if v {
+		m.Int64(field, 1)
+	}
+}
+
+// String adds a string field.
+func (m *Message) String(field int, s string) {
+	if s != "" {
+		m.tag(field, wireBytes)
+		m.buf = binary.AppendUvarint(m.buf, uint64(len(s)))
+		m.buf = append(m.buf, s...)
+	}
+}
+
+// Strings adds a repeated string field.
+func (m *Message) Strings(field int, values []string) {
+	for _, s := range values {
+		m.tag(field, wireBytes)
+		m.buf = binary.AppendUvarint(m.buf, uint64(len(s)))
+		m.buf = append(m.buf, s...)
+	}
+}
+
+// Message adds a message field, or an element of a repeated one. Unlike
+// other fields, empty messages are kept.
+func (m *Message) Message(field int, sub *Message) {
+	m.tag(field, wireBytes)
+	m.buf = binary.AppendUvarint(m.buf, uint64(len(sub.buf)))
+	m.buf = append(m.buf, sub.buf...)
+}
+
+// Bytes returns the encoded message.
+func (m *Message) Bytes() []byte {
+	return m.buf
+}
+
+// Reset empties the message, keeping its buffer.
+func (m *Message) Reset() {
+	m.buf = m.buf[:0]
+}
+
+// WriteDelimited writes the message prefixed with its length as a
+// varint, as parseDelimitedFrom in Java and protobuf.internal.decoder in
+// Python read streams of messages.
+func WriteDelimited(w io.Writer, m *Message) error {
+	var n [binary.MaxVarintLen64]byte
+	if _, err := w.Write(n[:binary.PutUvarint(n[:], uint64(len(m.buf)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(m.buf)
+	return err
+}
+
+// A Field is a field of a decoded message.
+type Field struct {
+	Number int
+	Varint uint64 // the value of an integer or bool field
+	Bytes  []byte // the value of a string, bytes or message field
+}
+
+var errTruncated = errors.New("protobuf: truncated message")
+
+// Fields decodes the fields of a message, in the order of the encoding.
+// Fixed-size fields are skipped, as no message of the schema has them.
+func Fields(b []byte) ([]Field, error) {
+	fields := make([]Field, 0, 4)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+		f := Field{Number: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			if f.Varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, errTruncated
+			}
+			b = b[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errTruncated
+			}
+			f.Bytes, b = b[n:n+int(size)], b[n+int(size):]
+		case wireI64, wireI32:
+			size := 8
+			if tag&7 == wireI32 {
+				size = 4
+			}
+			if len(b) < size {
+				return nil, errTruncated
+			}
+			b = b[size:]
+			continue
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d of field %d", tag&7, f.Number)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
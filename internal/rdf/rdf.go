@@ -0,0 +1,185 @@
+// Package rdf writes RDF triples as N-Triples or as Turtle, the formats
+// of the knowledge graphs like DBpedia that are made from the infoboxes,
+// categories and links of Wikipedia.
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Datatypes of typed literals.
+const (
+	XSDInteger = "http://www.w3.org/2001/XMLSchema#integer"
+	XSDDecimal = "http://www.w3.org/2001/XMLSchema#decimal"
+)
+
+// Type is the rdf:type predicate, which Turtle writes as "a".
+const Type = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+
+// A Term is an IRI or a literal, the latter with either a language or a
+// datatype.
+type Term struct {
+	Value    string
+	Literal  bool
+	Language string // like "en", for literals without a datatype
+	Datatype string // an IRI, for literals without a language
+}
+
+// IRI returns the term of an IRI.
+func IRI(iri string) Term { return Term{Value: iri} }
+
+// Literal returns the term of a string in the given language, or without
+// one if language is empty.
+func Literal(s string, language string) Term {
+	return Term{Value: s, Literal: true, Language: language}
+}
+
+// TypedLiteral returns the term of a value of the given datatype.
+func TypedLiteral(s string, datatype string) Term {
+	return Term{Value: s, Literal: true, Datatype: datatype}
+}
+
+// String returns the term as written in N-Triples and Turtle.
+func (t Term) String() string {
+	if !t.Literal {
+		return "<" + EscapeIRI(t.Value) + ">"
+	}
+	s := `"` + escapeLiteral(t.Value) + `"`
+	switch {
+	case t.Datatype != "":
+		s += "^^<" + EscapeIRI(t.Datatype) + ">"
+	case t.Language != "":
+		s += "@" + t.Language
+	}
+	return s
+}
+
+// A Triple is a statement of a subject, a predicate and an object.
+type Triple struct {
+	Subject   string // an IRI
+	Predicate string // an IRI
+	Object    Term
+}
+
+// escapeLiteral escapes the quotes, backslashes and control characters
+// of a string, which N-Triples writes on a single line.
+func escapeLiteral(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// EscapeIRI percent-encodes the characters that IRIs must not hold, like
+// blanks, "<", ">" and quotes, as the bytes of their UTF-8 encoding.
+func EscapeIRI(iri string) string {
+	return escape(iri, `<>"{}|^`+"`\\")
+}
+
+// escape percent-encodes the control characters, blanks and the given
+// characters of s.
+func escape(s string, unsafe string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune(unsafe, r) {
+			var buf [utf8.UTFMax]byte
+			for _, c := range buf[:utf8.EncodeRune(buf[:], r)] {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ResourceIRI returns the IRI of the page with the given title below
+// base, like DBpedia names its resources: blanks become underscores, the
+// first letter is capitalized as in MediaWiki, and "%", "?" and "#" are
+// percent-encoded along with the characters IRIs must not hold, as in
+// "http://dbpedia.org/resource/Apollo_11".
+func ResourceIRI(base string, title string) string {
+	title = strings.ReplaceAll(strings.TrimSpace(title), " ", "_")
+	if r, size := utf8.DecodeRuneInString(title); r != utf8.RuneError {
+		title = string(unicode.ToUpper(r)) + title[size:]
+	}
+	return base + escape(title, `<>"{}|^`+"`\\%?#")
+}
+
+// A Writer writes triples as N-Triples, one per line, or as Turtle, where
+// the triples of a subject that follow one another share the subject.
+// Write errors are returned by Close.
+type Writer struct {
+	w       io.Writer
+	turtle  bool
+	subject string // of the last triple written in Turtle
+	err     error
+}
+
+// NewWriter returns a Writer of N-Triples, or of Turtle if turtle is set,
+// to w.
+func NewWriter(w io.Writer, turtle bool) *Writer {
+	return &Writer{w: w, turtle: turtle}
+}
+
+// Write writes a triple.
+func (w *Writer) Write(t Triple) {
+	if w.err != nil {
+		return
+	}
+	object := t.Object.String()
+	switch {
+	case !w.turtle:
+		_, w.err = fmt.Fprintf(w.w, "<%s> <%s> %s .\n", EscapeIRI(t.Subject), EscapeIRI(t.Predicate), object)
+	case t.Subject == w.subject:
+		_, w.err = fmt.Fprintf(w.w, " ;\n    %s %s", w.predicate(t.Predicate), object)
+	default:
+		if w.subject != "" {
+			_, w.err = io.WriteString(w.w, " .\n")
+		}
+		if w.err == nil {
+			_, w.err = fmt.Fprintf(w.w, "<%s> %s %s", EscapeIRI(t.Subject), w.predicate(t.Predicate), object)
+		}
+		w.subject = t.Subject
+	}
+}
+
+// predicate returns the predicate as written in Turtle.
+func (w *Writer) predicate(iri string) string {
+	if iri == Type {
+		return "a"
+	}
+	return "<" + EscapeIRI(iri) + ">"
+}
+
+// Close ends the statement of the last subject in Turtle and returns the
+// first error of writing. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err == nil && w.turtle && w.subject != "" {
+		_, w.err = io.WriteString(w.w, " .\n")
+		w.subject = ""
+	}
+	return w.err
+}
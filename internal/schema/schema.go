@@ -0,0 +1,96 @@
+// Package schema versions the output formats. All tables start with a
+// header line "#schema <version> <kind>" and audit records carry
+// schema_version, so that readers can recognize and upgrade outputs of
+// older versions.
+package schema
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of all output formats written. Whenever a
+// format changes, increment it and describe the change in Migrations,
+// and keep the readers able to read the old format.
+const Version = 5
+
+// Migrations describes the changes of each version.
+var Migrations = map[int]string{
+	2: "tables start with a '#schema <version> <kind>' line; audit records carry schema_version, migration_notes and the kind of each output",
+	3: "link tables (tsv and csv) have a class column after the interwiki prefix",
+	4: "manifests are keyed by the exact title rather than the canonical one and have a column with the file name of each article",
+	5: "the input of an audit record is marked partial if the run read only part of it, and its sha256 and bytes are of that part",
+}
+
+// MigrationNotes returns the notes of all schema changes, oldest first.
+func MigrationNotes() []string {
+	versions := make([]int, 0, len(Migrations))
+	for v := range Migrations {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	notes := make([]string, 0, len(versions))
+	for _, v := range versions {
+		notes = append(notes, fmt.Sprintf("v%d: %s", v, Migrations[v]))
+	}
+	return notes
+}
+
+// WriteHeader writes the header line of a table of the given kind.
+func WriteHeader(w io.Writer, kind string) (int, error) {
+	return fmt.Fprintf(w, "#schema %d %s\n", Version, kind)
+}
+
+// ParseHeader parses a header line written by WriteHeader.
+func ParseHeader(line string) (int, string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "#schema" {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, "", false
+	}
+	return version, fields[2], true
+}
+
+// A Scanner reads the lines of a table of any schema version. Up to
+// version 1, tables had no header line.
+type Scanner struct {
+	*bufio.Scanner
+	Version int
+	Line    int
+	first   bool
+}
+
+// NewScanner returns a Scanner reading the table from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{Scanner: bufio.NewScanner(r), Version: 1, first: true}
+}
+
+// Scan advances to the next data line, checking the header line of the
+// table against the expected kind.
+func (s *Scanner) Scan(kind string) (bool, error) {
+	for s.Scanner.Scan() {
+		s.Line++
+		if s.first {
+			s.first = false
+			if version, k, ok := ParseHeader(s.Text()); ok {
+				if k != kind {
+					return false, fmt.Errorf("line 1: expected a %s table, got %s", kind, k)
+				}
+				if version > Version {
+					return false, fmt.Errorf("line 1: schema version %d is newer than supported version %d", version, Version)
+				}
+				s.Version = version
+				continue
+			}
+		}
+		return true, nil
+	}
+	return false, s.Err()
+}
@@ -0,0 +1,220 @@
+// Package search builds an on-disk inverted index of articles and ranks
+// them for queries with BM25, without external services.
+//
+// An index file holds, after the magic line:
+//
+//	the documents      title, snippet (uvarint lengths and bytes)
+//	the lengths        uint32 per document, in weighted tokens
+//	the offsets        uint64 per document, of its entry above
+//	the postings       per term: uvarint count, then uvarint pairs of
+//	                   document delta and weighted frequency
+//	the dictionary     per term in order: term, offset of its postings,
+//	                   number of documents (uvarints)
+//	the samples        uvarint count, then every sampleEvery-th term
+//	                   with the offset of its dictionary entry
+//	the footer         uint64 document count, total length and the
+//	                   offsets of the lengths, dictionary and samples
+//
+// Integers of fixed size are little-endian.
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+const magic = "wikisearch 1\n"
+
+// TitleWeight is how many times a token of a title counts as often as a
+// token of the text.
+const TitleWeight = 3
+
+// SnippetSize is how many bytes of the beginning of the text are stored
+// for the snippets of results.
+const SnippetSize = 2000
+
+// sampleEvery is the distance between the terms of the dictionary kept
+// in memory while searching.
+const sampleEvery = 128
+
+const footerSize = 5 * 8
+
+// Tokens returns the words of the text as lexed by wikitext, lowercased
+// and without leading or trailing hyphens and apostrophes.
+func Tokens(text string) []string {
+	return Tokenize(text, wikitext.Tokenizer{})
+}
+
+// Tokenize is like Tokens, splitting the words as the tokenizer does.
+func Tokenize(text string, tok wikitext.Tokenizer) []string {
+	tokens := make([]string, 0, len(text)/8)
+	for item := range tok.LexContext(context.Background(), text).Items() {
+		if item.Type != wikitext.ItemWord && item.Type != wikitext.ItemNumber {
+			continue
+		}
+		if t := strings.Trim(strings.TrimSpace(item.Val), "-'"); t != "" {
+			tokens = append(tokens, strings.ToLower(t))
+		}
+	}
+	return tokens
+}
+
+type posting struct {
+	doc uint32
+	tf  uint32
+}
+
+// A Builder collects the postings of the documents added in memory and
+// writes the index.
+type Builder struct {
+	titles   []string
+	snippets []string
+	lengths  []uint32
+	total    uint64
+	postings map[string][]posting
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{postings: make(map[string][]posting)}
+}
+
+// Len returns the number of documents added.
+func (b *Builder) Len() int {
+	return len(b.titles)
+}
+
+// Add adds an article with its title and plain text.
+func (b *Builder) Add(title string, text string) {
+	doc := uint32(len(b.titles))
+	freq := make(map[string]uint32)
+	length := uint32(0)
+	for _, t := range Tokens(title) {
+		freq[t] += TitleWeight
+		length += TitleWeight
+	}
+	for _, t := range Tokens(text) {
+		freq[t]++
+		length++
+	}
+	for t, tf := range freq {
+		b.postings[t] = append(b.postings[t], posting{doc, tf})
+	}
+	snippet := text
+	if len(snippet) > SnippetSize {
+		snippet = snippet[:SnippetSize]
+		for !utf8.ValidString(snippet) {
+			snippet = snippet[:len(snippet)-1]
+		}
+	}
+	b.titles = append(b.titles, title)
+	b.snippets = append(b.snippets, snippet)
+	b.lengths = append(b.lengths, length)
+	b.total += uint64(length)
+}
+
+// An indexWriter writes the sections of an index, counting the offset.
+type indexWriter struct {
+	*bufio.Writer
+	offset uint64
+	buf    []byte
+}
+
+func (w *indexWriter) bytes(p []byte) {
+	w.Write(p)
+	w.offset += uint64(len(p))
+}
+
+func (w *indexWriter) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf[:0], v)
+	w.bytes(w.buf)
+}
+
+func (w *indexWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.Writer.WriteString(s)
+	w.offset += uint64(len(s))
+}
+
+func (w *indexWriter) uint32(v uint32) {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf[:0], v)
+	w.bytes(w.buf)
+}
+
+func (w *indexWriter) uint64(v uint64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf[:0], v)
+	w.bytes(w.buf)
+}
+
+// Write writes the index to the file at path, replacing it.
+func (b *Builder) Write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := &indexWriter{Writer: bufio.NewWriter(file)}
+	w.bytes([]byte(magic))
+
+	offsets := make([]uint64, len(b.titles))
+	for i := range b.titles {
+		offsets[i] = w.offset
+		w.string(b.titles[i])
+		w.string(b.snippets[i])
+	}
+	lengths := w.offset
+	for _, l := range b.lengths {
+		w.uint32(l)
+	}
+	for _, o := range offsets {
+		w.uint64(o)
+	}
+
+	terms := make([]string, 0, len(b.postings))
+	for t := range b.postings {
+		terms = append(terms, t)
+	}
+	sort.Strings(terms)
+	starts := make([]uint64, len(terms))
+	for i, t := range terms {
+		starts[i] = w.offset
+		list := b.postings[t]
+		w.uvarint(uint64(len(list)))
+		last := uint32(0)
+		for _, p := range list {
+			w.uvarint(uint64(p.doc - last))
+			w.uvarint(uint64(p.tf))
+			last = p.doc
+		}
+	}
+	dictionary := w.offset
+	entries := make([]uint64, len(terms))
+	for i, t := range terms {
+		entries[i] = w.offset
+		w.string(t)
+		w.uvarint(starts[i])
+		w.uvarint(uint64(len(b.postings[t])))
+	}
+	samples := w.offset
+	w.uvarint(uint64((len(terms) + sampleEvery - 1) / sampleEvery))
+	for i := 0; i < len(terms); i += sampleEvery {
+		w.string(terms[i])
+		w.uvarint(entries[i])
+	}
+	w.uint64(uint64(len(b.titles)))
+	w.uint64(b.total)
+	w.uint64(lengths)
+	w.uint64(dictionary)
+	w.uint64(samples)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,327 @@
+// Ranking of the articles of an index for queries
+
+package search
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The parameters of BM25.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// snippetLength is the length of the snippets of results in bytes.
+const snippetLength = 200
+
+// An Index is an index file opened for searching. Only the lengths of
+// the documents and every sampleEvery-th term of the dictionary are held
+// in memory; postings and documents are read as needed, so an Index can
+// be searched in several goroutines at once.
+type Index struct {
+	file       *os.File
+	docs       uint64
+	avgLength  float64
+	lengths    []uint32
+	offsets    uint64 // the offset of the document offsets
+	dictionary uint64
+	samples    uint64
+	sampled    []string // the sampled terms, in order
+	entries    []uint64 // the offsets of their dictionary entries
+}
+
+// A Result is an article found, with a snippet of its text around the
+// first term of the query in the beginning of the text.
+type Result struct {
+	Title   string
+	Score   float64
+	Snippet string
+}
+
+var errCorrupt = errors.New("search: corrupt index file")
+
+// Open opens the index file at path.
+func Open(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index{file: file}
+	if err := ix.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return ix, nil
+}
+
+func (ix *Index) load() error {
+	info, err := ix.file.Stat()
+	if err != nil {
+		return err
+	}
+	head := make([]byte, len(magic))
+	if _, err := ix.file.ReadAt(head, 0); err != nil || string(head) != magic {
+		return errCorrupt
+	}
+	footer := make([]byte, footerSize)
+	if _, err := ix.file.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return errCorrupt
+	}
+	field := func(i int) uint64 {
+		return binary.LittleEndian.Uint64(footer[8*i:])
+	}
+	ix.docs = field(0)
+	if ix.docs > 0 {
+		ix.avgLength = float64(field(1)) / float64(ix.docs)
+	}
+	lengths := field(2)
+	ix.offsets = lengths + 4*ix.docs
+	ix.dictionary, ix.samples = field(3), field(4)
+
+	data := make([]byte, 4*ix.docs)
+	if _, err := ix.file.ReadAt(data, int64(lengths)); err != nil {
+		return errCorrupt
+	}
+	ix.lengths = make([]uint32, ix.docs)
+	for i := range ix.lengths {
+		ix.lengths[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	r := bufio.NewReader(io.NewSectionReader(ix.file, int64(ix.samples), info.Size()-footerSize-int64(ix.samples)))
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return errCorrupt
+	}
+	for i := uint64(0); i < n; i++ {
+		term, err := readString(r)
+		if err != nil {
+			return errCorrupt
+		}
+		entry, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errCorrupt
+		}
+		ix.sampled = append(ix.sampled, term)
+		ix.entries = append(ix.entries, entry)
+	}
+	return nil
+}
+
+// Close closes the index file.
+func (ix *Index) Close() error {
+	return ix.file.Close()
+}
+
+// Len returns the number of documents of the index.
+func (ix *Index) Len() int {
+	return int(ix.docs)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<30 {
+		return "", errCorrupt
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+// reader returns a reader of the index file from offset to end.
+func (ix *Index) reader(offset uint64, end uint64) *bufio.Reader {
+	return bufio.NewReader(io.NewSectionReader(ix.file, int64(offset), int64(end-offset)))
+}
+
+// lookup returns the offset of the postings of the term and the number
+// of documents it occurs in, 0 if none.
+func (ix *Index) lookup(term string) (uint64, uint64, error) {
+	i := sort.SearchStrings(ix.sampled, term)
+	if i == len(ix.sampled) || ix.sampled[i] != term {
+		i--
+	}
+	if i < 0 {
+		return 0, 0, nil
+	}
+	end := ix.samples
+	if i+1 < len(ix.entries) {
+		end = ix.entries[i+1]
+	}
+	r := ix.reader(ix.entries[i], end)
+	for {
+		t, err := readString(r)
+		if err == io.EOF {
+			return 0, 0, nil
+		}
+		if err != nil {
+			return 0, 0, errCorrupt
+		}
+		postings, err1 := binary.ReadUvarint(r)
+		df, err2 := binary.ReadUvarint(r)
+		if err1 != nil || err2 != nil {
+			return 0, 0, errCorrupt
+		}
+		if t == term {
+			return postings, df, nil
+		}
+		if t > term {
+			return 0, 0, nil
+		}
+	}
+}
+
+// document reads the title and snippet of a document.
+func (ix *Index) document(doc uint32) (string, string, error) {
+	buf := make([]byte, 8)
+	if _, err := ix.file.ReadAt(buf, int64(ix.offsets+8*uint64(doc))); err != nil {
+		return "", "", errCorrupt
+	}
+	r := ix.reader(binary.LittleEndian.Uint64(buf), ix.lengthsStart())
+	title, err := readString(r)
+	if err != nil {
+		return "", "", errCorrupt
+	}
+	snippet, err := readString(r)
+	if err != nil {
+		return "", "", errCorrupt
+	}
+	return title, snippet, nil
+}
+
+// lengthsStart returns the offset of the lengths, where the documents
+// end.
+func (ix *Index) lengthsStart() uint64 {
+	return ix.offsets - 4*ix.docs
+}
+
+// Search returns up to n articles matching any term of the query, best
+// first, ranked with BM25.
+func (ix *Index) Search(query string, n int) ([]Result, error) {
+	terms := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	for _, t := range Tokens(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	scores := make(map[uint32]float64)
+	for _, t := range terms {
+		offset, df, err := ix.lookup(t)
+		if err != nil {
+			return nil, err
+		}
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (float64(ix.docs)-float64(df)+0.5)/(float64(df)+0.5))
+		r := ix.reader(offset, ix.dictionary)
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errCorrupt
+		}
+		doc := uint64(0)
+		for i := uint64(0); i < count; i++ {
+			delta, err1 := binary.ReadUvarint(r)
+			tf, err2 := binary.ReadUvarint(r)
+			if err1 != nil || err2 != nil {
+				return nil, errCorrupt
+			}
+			doc += delta
+			if doc >= ix.docs {
+				return nil, errCorrupt
+			}
+			f := float64(tf)
+			norm := k1 * (1 - b + b*float64(ix.lengths[doc])/ix.avgLength)
+			scores[uint32(doc)] += idf * f * (k1 + 1) / (f + norm)
+		}
+	}
+	docs := make([]uint32, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+	results := make([]Result, 0, min(n, len(docs)))
+	for _, doc := range docs[:min(n, len(docs))] {
+		title, text, err := ix.document(doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Result{title, scores[doc], snippet(text, terms)})
+	}
+	return results, nil
+}
+
+// isWordBoundary reports whether text has no letter or digit before i.
+func isWordBoundary(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return i == 0 || !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// snippet returns about snippetLength bytes of the text on one line,
+// starting a little before the first occurrence of a term.
+func snippet(text string, terms []string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Offsets in lower must be those in text.
+		lower = text
+	}
+	first := -1
+	for _, t := range terms {
+		for i := 0; i+len(t) <= len(lower); {
+			j := strings.Index(lower[i:], t)
+			if j < 0 {
+				break
+			}
+			if isWordBoundary(lower, i+j) && (first < 0 || i+j < first) {
+				first = i + j
+				break
+			}
+			i += j + 1
+		}
+	}
+	start := 0
+	if first > snippetLength/4 {
+		start = first - snippetLength/4
+		if k := strings.IndexByte(text[start:first], ' '); k >= 0 {
+			start += k + 1
+		}
+		for !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	end := min(start+snippetLength, len(text))
+	if end < len(text) {
+		if k := strings.LastIndexByte(text[start:end], ' '); k > 0 {
+			end = start + k
+		}
+	}
+	s := text[start:end]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(text) {
+		s += "..."
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+// Package secret reads credentials for output sinks from the environment
+// or from files and keeps them out of logs and run summaries.
+package secret
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// A Secret holds a credential. It formats as [REDACTED] with all verbs
+// of the fmt package, so it can be logged by accident without leaking.
+type Secret struct {
+	value string
+}
+
+func (s Secret) String() string {
+	if s.value == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// Value returns the credential itself, for handing it to a client.
+func (s Secret) Value() string {
+	return s.value
+}
+
+var knownSecrets struct {
+	sync.Mutex
+	values []string
+}
+
+// Read reads the credential called name, e.g. "ES_PASSWORD", from
+// the environment variable WIKI_<name>, or from the file named by
+// WIKI_<name>_FILE (as used for docker and kubernetes secrets). A
+// missing credential is not an error and yields an empty secret.
+func Read(name string) (Secret, error) {
+	env := "WIKI_" + name
+	value := os.Getenv(env)
+	if path := os.Getenv(env + "_FILE"); path != "" {
+		if value != "" {
+			return Secret{}, fmt.Errorf("both %s and %s_FILE are set", env, env)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Secret{}, fmt.Errorf("reading %s_FILE: %v", env, err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value != "" {
+		knownSecrets.Lock()
+		knownSecrets.values = append(knownSecrets.values, value)
+		knownSecrets.Unlock()
+	}
+	return Secret{value}, nil
+}
+
+// Redact replaces every credential read so far by Read, as well as
+// passwords in URLs, so that s can be logged.
+func Redact(s string) string {
+	knownSecrets.Lock()
+	for _, v := range knownSecrets.values {
+		s = strings.Replace(s, v, redacted, -1)
+	}
+	knownSecrets.Unlock()
+	fields := strings.Fields(s)
+	for _, f := range fields {
+		if u, err := url.Parse(f); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				s = strings.Replace(s, f, u.Redacted(), -1)
+			}
+		}
+	}
+	return s
+}
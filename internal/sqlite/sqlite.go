@@ -0,0 +1,363 @@
+// Package sqlite writes SQLite database files without a driver, so that
+// the loader can produce queryable databases with the standard library
+// only. It creates new databases of rowid tables whose rows are appended
+// in order; indexes can be added afterwards with the sqlite3 shell.
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// PageSize is the size of the pages of the databases written.
+const PageSize = 4096
+
+// The b-tree page types of the file format.
+const (
+	interiorTable = 0x05
+	leafTable     = 0x0d
+)
+
+// interiorChildren is the most children of an interior page, whose
+// cells take up to 15 bytes with their pointers.
+const interiorChildren = 200
+
+// The limits of the payload stored in a leaf cell before the rest goes
+// to overflow pages, as defined by the file format for table b-trees.
+const (
+	maxLocal = PageSize - 35
+	minLocal = (PageSize-12)*32/255 - 23
+)
+
+// A DB is a database being written. Rows are kept in memory only until
+// their page is full; the b-tree pages above the leaves and the schema
+// are written by Close.
+type DB struct {
+	file   *os.File
+	next   uint32 // the number of the next page to allocate
+	tables []*Table
+	err    error
+
+	userVersion uint32
+}
+
+// A Table is a table of a DB, to which rows are appended with Insert.
+type Table struct {
+	db     *DB
+	name   string
+	sql    string
+	rowid  int64
+	leaf   *page
+	leaves []child
+}
+
+// A child is a page of a b-tree with the largest rowid stored below it.
+type child struct {
+	page  uint32
+	rowid int64
+}
+
+// A page is a b-tree page being filled with cells.
+type page struct {
+	data    []byte
+	header  int // the offset of the page header, 100 on page 1
+	cells   int
+	content int // the start of the cell content area
+}
+
+func newPage(kind byte, header int) *page {
+	p := &page{data: make([]byte, PageSize), header: header, content: PageSize}
+	p.data[header] = kind
+	binary.BigEndian.PutUint16(p.data[header+5:], PageSize)
+	return p
+}
+
+func (p *page) headerSize() int {
+	if p.data[p.header] == interiorTable {
+		return 12
+	}
+	return 8
+}
+
+// fits reports whether a cell of n bytes fits into the page.
+func (p *page) fits(n int) bool {
+	return p.header+p.headerSize()+2*(p.cells+1) <= p.content-n
+}
+
+// add appends a cell, which must fit.
+func (p *page) add(cell []byte) {
+	p.content -= len(cell)
+	copy(p.data[p.content:], cell)
+	binary.BigEndian.PutUint16(p.data[p.header+p.headerSize()+2*p.cells:], uint16(p.content))
+	p.cells++
+	binary.BigEndian.PutUint16(p.data[p.header+3:], uint16(p.cells))
+	binary.BigEndian.PutUint16(p.data[p.header+5:], uint16(p.content))
+}
+
+// Create creates the database file, replacing an existing one.
+func Create(path string) (*DB, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	// Page 1 holds the header and the schema, written by Close.
+	return &DB{file: file, next: 2}, nil
+}
+
+// CreateTable adds a table with the given name, created by the SQL
+// statement, like "CREATE TABLE pages(id INTEGER, title TEXT)". Columns
+// declared INTEGER PRIMARY KEY are not supported, as they alias the
+// rowid.
+func (db *DB) CreateTable(name string, sql string) *Table {
+	t := &Table{db: db, name: name, sql: sql, leaf: newPage(leafTable, 0)}
+	db.tables = append(db.tables, t)
+	return t
+}
+
+// SetUserVersion sets the version returned by PRAGMA user_version, by
+// which readers can recognize the schema of the database.
+func (db *DB) SetUserVersion(v int) {
+	db.userVersion = uint32(v)
+}
+
+// writePage writes the page with the given number.
+func (db *DB) writePage(number uint32, data []byte) {
+	if db.err == nil {
+		_, db.err = db.file.WriteAt(data, int64(number-1)*PageSize)
+	}
+}
+
+func (db *DB) allocate() uint32 {
+	db.next++
+	return db.next - 1
+}
+
+// putVarint appends the variable length integer of the file format: up
+// to nine bytes, big-endian, with seven bits in all but the ninth byte.
+func putVarint(b []byte, v uint64) []byte {
+	if v <= 0x7f {
+		return append(b, byte(v))
+	}
+	if v > 0x00ffffffffffffff {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var buf [8]byte
+	n := 0
+	for ; v > 0; v >>= 7 {
+		buf[n] = byte(v & 0x7f)
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		if i > 0 {
+			buf[i] |= 0x80
+		}
+		b = append(b, buf[i])
+	}
+	return b
+}
+
+// integerType returns the serial type and size of an integer.
+func integerType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// record encodes the values in the record format: a header of serial
+// types followed by the values.
+func record(values []any) ([]byte, error) {
+	types := make([]byte, 0, 2*len(values))
+	body := make([]byte, 0, 64)
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = putVarint(types, 0)
+		case int:
+			types, body = appendInteger(types, body, int64(v))
+		case int64:
+			types, body = appendInteger(types, body, v)
+		case bool:
+			if v {
+				types = putVarint(types, 9)
+			} else {
+				types = putVarint(types, 8)
+			}
+		case float64:
+			types = putVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = putVarint(types, uint64(2*len(v)+13))
+			body = append(body, v...)
+		case []byte:
+			types = putVarint(types, uint64(2*len(v)+12))
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("sqlite: unsupported value of type %T", v)
+		}
+	}
+	// The header size counts itself, which may take two bytes.
+	size := len(types) + 1
+	if size > 0x7f {
+		size++
+	}
+	rec := putVarint(make([]byte, 0, size+len(body)), uint64(size))
+	rec = append(rec, types...)
+	return append(rec, body...), nil
+}
+
+func appendInteger(types []byte, body []byte, v int64) ([]byte, []byte) {
+	t, n := integerType(v)
+	types = putVarint(types, t)
+	for i := n - 1; i >= 0; i-- {
+		body = append(body, byte(v>>(8*i)))
+	}
+	return types, body
+}
+
+// leafCell encodes a cell of a leaf page, writing the end of a large
+// payload to overflow pages.
+func (db *DB) leafCell(rowid int64, payload []byte) []byte {
+	cell := putVarint(make([]byte, 0, 18+min(len(payload), maxLocal)), uint64(len(payload)))
+	cell = putVarint(cell, uint64(rowid))
+	if len(payload) <= maxLocal {
+		return append(cell, payload...)
+	}
+	local := minLocal + (len(payload)-minLocal)%(PageSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+	rest := payload[local:]
+	first := db.next
+	cell = binary.BigEndian.AppendUint32(cell, first)
+	for len(rest) > 0 {
+		number := db.allocate()
+		data := make([]byte, PageSize)
+		n := copy(data[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(data, db.next)
+		}
+		db.writePage(number, data)
+	}
+	return cell
+}
+
+// Insert appends a row of values, which may be nil, int, int64, bool,
+// float64, string or []byte.
+func (t *Table) Insert(values ...any) error {
+	if t.db.err != nil {
+		return t.db.err
+	}
+	payload, err := record(values)
+	if err != nil {
+		return err
+	}
+	t.rowid++
+	cell := t.db.leafCell(t.rowid, payload)
+	if !t.leaf.fits(len(cell)) {
+		t.flushLeaf()
+	}
+	t.leaf.add(cell)
+	return t.db.err
+}
+
+// flushLeaf writes the current leaf page and starts a new one.
+func (t *Table) flushLeaf() {
+	number := t.db.allocate()
+	t.db.writePage(number, t.leaf.data)
+	t.leaves = append(t.leaves, child{number, t.rowid - 1})
+	t.leaf = newPage(leafTable, 0)
+}
+
+// finish writes the last leaf and the interior pages of the table and
+// returns the number of its root page.
+func (t *Table) finish() uint32 {
+	number := t.db.allocate()
+	t.db.writePage(number, t.leaf.data)
+	level := append(t.leaves, child{number, t.rowid})
+	for len(level) > 1 {
+		// Interior pages need a cell besides the right-most pointer, so
+		// the children are spread evenly over the pages of the level.
+		pages := (len(level) + interiorChildren - 1) / interiorChildren
+		parents := make([]child, 0, pages)
+		for i := 0; i < pages; i++ {
+			group := level[i*len(level)/pages : (i+1)*len(level)/pages]
+			p := newPage(interiorTable, 0)
+			for _, c := range group[:len(group)-1] {
+				cell := binary.BigEndian.AppendUint32(make([]byte, 0, 13), c.page)
+				p.add(putVarint(cell, uint64(c.rowid)))
+			}
+			last := group[len(group)-1]
+			binary.BigEndian.PutUint32(p.data[8:], last.page)
+			number := t.db.allocate()
+			t.db.writePage(number, p.data)
+			parents = append(parents, child{number, last.rowid})
+		}
+		level = parents
+	}
+	return level[0].page
+}
+
+// Close writes the remaining pages, the schema and the header of the
+// database and closes its file. It returns the first error of writing.
+func (db *DB) Close() error {
+	schema := newPage(leafTable, 100)
+	for i, t := range db.tables {
+		root := t.finish()
+		payload, err := record([]any{"table", t.name, t.name, int64(root), t.sql})
+		if err != nil {
+			return err
+		}
+		cell := putVarint(nil, uint64(len(payload)))
+		cell = putVarint(cell, uint64(i+1))
+		cell = append(cell, payload...)
+		if len(payload) > maxLocal-100 || !schema.fits(len(cell)) {
+			db.file.Close()
+			return errors.New("sqlite: the schema does not fit on the first page")
+		}
+		schema.add(cell)
+	}
+	header := schema.data[:100]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], PageSize)
+	header[18], header[19] = 1, 1 // legacy journal mode
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1) // the file change counter
+	binary.BigEndian.PutUint32(header[28:], db.next-1)
+	binary.BigEndian.PutUint32(header[40:], 1) // the schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // the schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[60:], db.userVersion)
+	binary.BigEndian.PutUint32(header[92:], 1) // valid for change 1
+	binary.BigEndian.PutUint32(header[96:], 3046000)
+	db.writePage(1, schema.data)
+	if err := db.file.Close(); db.err == nil {
+		db.err = err
+	}
+	return db.err
+}
@@ -0,0 +1,280 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readVarint decodes a variable length integer of the file format and
+// returns it with its size.
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+func TestVarint(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{0x00ffffffffffffff, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{0x0100000000000000, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{math.MaxUint64, bytes.Repeat([]byte{0xff}, 9)},
+	}
+	for _, tt := range tests {
+		got := putVarint(nil, tt.v)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("putVarint(%#x) = % x, want % x", tt.v, got, tt.want)
+		}
+		if v, n := readVarint(got); v != tt.v || n != len(got) {
+			t.Errorf("readVarint(% x) = %#x, %d, want %#x, %d", got, v, n, tt.v, len(got))
+		}
+	}
+}
+
+// A row is a row read back from a table.
+type row struct {
+	rowid  int64
+	values []any
+}
+
+// reader decodes the tables of a database file written by a DB.
+type reader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *reader) page(number uint32) []byte {
+	if number < 1 || int(number)*PageSize > len(r.data) {
+		r.t.Fatalf("page %d is out of the file of %d bytes", number, len(r.data))
+	}
+	return r.data[int(number-1)*PageSize : int(number)*PageSize]
+}
+
+// rows returns the rows of the b-tree with the root page, in order.
+func (r *reader) rows(number uint32) []row {
+	p := r.page(number)
+	header := 0
+	if number == 1 {
+		header = 100
+	}
+	cells := int(binary.BigEndian.Uint16(p[header+3:]))
+	switch p[header] {
+	case interiorTable:
+		var rows []row
+		for i := 0; i < cells; i++ {
+			cell := p[binary.BigEndian.Uint16(p[header+12+2*i:]):]
+			rows = append(rows, r.rows(binary.BigEndian.Uint32(cell))...)
+		}
+		return append(rows, r.rows(binary.BigEndian.Uint32(p[header+8:]))...)
+	case leafTable:
+		rows := make([]row, 0, cells)
+		for i := 0; i < cells; i++ {
+			cell := p[binary.BigEndian.Uint16(p[header+8+2*i:]):]
+			size, n := readVarint(cell)
+			rowid, m := readVarint(cell[n:])
+			rows = append(rows, row{int64(rowid), r.record(r.payload(cell[n+m:], int(size)))})
+		}
+		return rows
+	}
+	r.t.Fatalf("page %d has the unknown type %#x", number, p[header])
+	return nil
+}
+
+// payload returns the payload of size bytes starting in a leaf cell,
+// following its overflow pages.
+func (r *reader) payload(cell []byte, size int) []byte {
+	if size <= maxLocal {
+		return cell[:size]
+	}
+	local := minLocal + (size-minLocal)%(PageSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	payload := append([]byte(nil), cell[:local]...)
+	for next := binary.BigEndian.Uint32(cell[local:]); len(payload) < size; {
+		if next == 0 {
+			r.t.Fatalf("the overflow pages end after %d of %d bytes", len(payload), size)
+		}
+		p := r.page(next)
+		payload = append(payload, p[4:min(PageSize, 4+size-len(payload))]...)
+		next = binary.BigEndian.Uint32(p)
+	}
+	return payload
+}
+
+// record decodes a record to values of the types Insert takes.
+func (r *reader) record(rec []byte) []any {
+	size, n := readVarint(rec)
+	types, body := rec[n:size], rec[size:]
+	var values []any
+	for len(types) > 0 {
+		t, n := readVarint(types)
+		types = types[n:]
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t >= 1 && t <= 6:
+			width := []int{0, 1, 2, 3, 4, 6, 8}[t]
+			v := int64(int8(body[0]))
+			for _, b := range body[1:width] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+			body = body[width:]
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case t == 8, t == 9:
+			values = append(values, int64(t-8))
+		case t >= 12 && t%2 == 0:
+			values = append(values, append([]byte(nil), body[:(t-12)/2]...))
+			body = body[(t-12)/2:]
+		case t >= 13:
+			values = append(values, string(body[:(t-13)/2]))
+			body = body[(t-13)/2:]
+		default:
+			r.t.Fatalf("the reserved serial type %d", t)
+		}
+	}
+	if len(body) > 0 {
+		r.t.Fatalf("%d bytes left after the values of a record", len(body))
+	}
+	return values
+}
+
+// testRow returns the values inserted as row i of the pages table,
+// covering all types and sizes of integers, and the values read back.
+func testRow(i int) (inserted []any, read []any) {
+	id := int64(1)<<(i%64) - int64(i%3)
+	text := strings.Repeat(fmt.Sprintf("Apollo %d ", i), 1+i%7)
+	switch i % 500 {
+	case 0:
+		// Spills to several overflow pages.
+		text = strings.Repeat("Moon landing ", 2000)
+	case 1:
+		// Just too large for the leaf.
+		text = strings.Repeat("x", maxLocal)
+	}
+	var redirect any
+	if i%4 == 0 {
+		redirect = "Apollo"
+	}
+	inserted = []any{id, fmt.Sprintf("Title %d", i), redirect, i%2 == 0, float64(i) / 3, []byte{byte(i)}, text}
+	read = []any{id, fmt.Sprintf("Title %d", i), redirect, int64(1 - i%2), float64(i) / 3, []byte{byte(i)}, text}
+	return inserted, read
+}
+
+func TestRoundTrip(t *testing.T) {
+	const rows = 20000
+	path := filepath.Join(t.TempDir(), "pages.sqlite")
+	db, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetUserVersion(3)
+	pages := db.CreateTable("pages", "CREATE TABLE pages(id INTEGER, title TEXT, redirect TEXT, article INTEGER, score REAL, flags BLOB, text TEXT)")
+	db.CreateTable("empty", "CREATE TABLE empty(x)")
+	links := db.CreateTable("links", "CREATE TABLE links(source INTEGER, target TEXT)")
+	for i := 0; i < rows; i++ {
+		values, _ := testRow(i)
+		if err := pages.Insert(values...); err != nil {
+			t.Fatal(err)
+		}
+		if err := links.Insert(i, "Moon"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		t.Fatalf("the file starts with %q", data[:16])
+	}
+	if got := binary.BigEndian.Uint32(data[28:]); int(got)*PageSize != len(data) {
+		t.Errorf("the header counts %d pages, the file has %d", got, len(data)/PageSize)
+	}
+	if got := binary.BigEndian.Uint32(data[60:]); got != 3 {
+		t.Errorf("user version %d, want 3", got)
+	}
+
+	r := &reader{t, data}
+	schema := r.rows(1)
+	if len(schema) != 3 {
+		t.Fatalf("the schema has %d tables, want 3", len(schema))
+	}
+	roots := make(map[string]uint32)
+	for i, s := range schema {
+		name := s.values[1].(string)
+		roots[name] = uint32(s.values[3].(int64))
+		if want := db.tables[i].sql; s.values[4] != want {
+			t.Errorf("the schema of %s is %q, want %q", name, s.values[4], want)
+		}
+	}
+	if got := r.rows(roots["empty"]); len(got) != 0 {
+		t.Errorf("empty has %d rows", len(got))
+	}
+	got := r.rows(roots["pages"])
+	if len(got) != rows {
+		t.Fatalf("pages has %d rows, want %d", len(got), rows)
+	}
+	for i, row := range got {
+		if _, want := testRow(i); row.rowid != int64(i+1) || !reflect.DeepEqual(row.values, want) {
+			t.Fatalf("row %d = %d %.80v, want %d %.80v", i, row.rowid, row.values, i+1, want)
+		}
+	}
+	if got := r.rows(roots["links"]); len(got) != rows || got[rows-1].values[0] != int64(rows-1) {
+		t.Errorf("links has %d rows", len(got))
+	}
+
+	// The sqlite3 shell checks the rest of the format.
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("no sqlite3 to check the database with")
+	}
+	out, err := exec.Command(sqlite3, path,
+		"PRAGMA integrity_check; PRAGMA user_version;",
+		"SELECT count(*), sum(article), sum(length(text)) FROM pages WHERE redirect IS NULL;",
+		"SELECT title FROM pages WHERE rowid = 12345;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3: %v\n%s", err, out)
+	}
+	want := "ok\n3\n"
+	var count, articles, length int
+	for i := 0; i < rows; i++ {
+		if values, _ := testRow(i); values[2] == nil {
+			count++
+			if values[3] == true {
+				articles++
+			}
+			length += len(values[6].(string))
+		}
+	}
+	want += fmt.Sprintf("%d|%d|%d\nTitle 12344\n", count, articles, length)
+	if string(out) != want {
+		t.Errorf("sqlite3 printed\n%s\nwant\n%s", out, want)
+	}
+}
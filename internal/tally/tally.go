@@ -0,0 +1,197 @@
+// Package tally counts strings, more of them than fit in memory: once
+// too many distinct strings are counted, their counts are written to a
+// temporary file in the order of the strings, and the files are merged
+// when the totals are read, in order as well.
+//
+// A run file holds, per string in order: the string and its count
+// (uvarint lengths and bytes, then a uvarint).
+package tally
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+var errCorrupt = errors.New("tally: corrupt run file")
+
+// A Counter counts strings, holding up to Limit distinct ones in memory.
+// The zero value is not usable; create Counters with NewCounter.
+type Counter struct {
+	Limit int
+
+	dir    string // the directory of the run files, created once needed
+	counts map[string]int64
+	runs   []string
+	err    error // of writing a run, returned by Close or Totals
+}
+
+// NewCounter returns an empty Counter holding up to limit distinct
+// strings in memory, writing its run files to a new directory in dir, or
+// the default directory for temporary files if dir is empty.
+func NewCounter(limit int, dir string) *Counter {
+	return &Counter{Limit: max(limit, 1), dir: dir, counts: make(map[string]int64)}
+}
+
+// Add adds n to the count of the string.
+func (c *Counter) Add(s string, n int64) {
+	c.counts[s] += n
+	if len(c.counts) >= c.Limit && c.err == nil {
+		c.err = c.spill()
+	}
+}
+
+// Runs returns the number of run files written so far.
+func (c *Counter) Runs() int {
+	return len(c.runs)
+}
+
+// spill writes the counts in memory to a new run file and empties them.
+func (c *Counter) spill() error {
+	if len(c.runs) == 0 {
+		dir, err := os.MkdirTemp(c.dir, "tally")
+		if err != nil {
+			return err
+		}
+		c.dir = dir
+	}
+	path := filepath.Join(c.dir, "run"+strconv.Itoa(len(c.runs)))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64)
+	for _, s := range slices.Sorted(maps.Keys(c.counts)) {
+		w.Write(binary.AppendUvarint(buf[:0], uint64(len(s))))
+		w.WriteString(s)
+		w.Write(binary.AppendUvarint(buf[:0], uint64(c.counts[s])))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	c.runs = append(c.runs, path)
+	clear(c.counts)
+	return file.Close()
+}
+
+// A run is a source of counts in order: a run file or those in memory.
+type run struct {
+	r     *bufio.Reader // nil for the counts in memory
+	keys  []string      // of the counts in memory, in order
+	count map[string]int64
+	s     string // the current string
+	n     int64
+}
+
+// next reads the next count of the run and reports whether there is one.
+func (r *run) next() (bool, error) {
+	if r.r == nil {
+		if len(r.keys) == 0 {
+			return false, nil
+		}
+		r.s, r.n, r.keys = r.keys[0], r.count[r.keys[0]], r.keys[1:]
+		return true, nil
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil || size > 1<<30 {
+		return false, errCorrupt
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return false, errCorrupt
+	}
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return false, errCorrupt
+	}
+	r.s, r.n = string(buf), int64(n)
+	return true, nil
+}
+
+// runHeap orders the runs by their current string.
+type runHeap []*run
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].s < h[j].s }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*run)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// Totals calls fn with every string counted and its total count, in the
+// order of the strings, merging the run files with the counts in memory.
+// An error of fn stops the iteration and is returned. The Counter is
+// closed afterwards.
+func (c *Counter) Totals(fn func(s string, n int64) error) error {
+	defer c.Close()
+	if c.err != nil {
+		return c.err
+	}
+	h := make(runHeap, 0, len(c.runs)+1)
+	add := func(r *run) error {
+		ok, err := r.next()
+		if ok {
+			h = append(h, r)
+		}
+		return err
+	}
+	for _, path := range c.runs {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := add(&run{r: bufio.NewReaderSize(file, 1<<16)}); err != nil {
+			return err
+		}
+	}
+	if err := add(&run{keys: slices.Sorted(maps.Keys(c.counts)), count: c.counts}); err != nil {
+		return err
+	}
+	heap.Init(&h)
+	for h.Len() > 0 {
+		s, total := h[0].s, int64(0)
+		for h.Len() > 0 && h[0].s == s {
+			total += h[0].n
+			ok, err := h[0].next()
+			if err != nil {
+				return err
+			}
+			if ok {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+		}
+		if err := fn(s, total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close removes the run files and forgets the counts.
+func (c *Counter) Close() error {
+	clear(c.counts)
+	if len(c.runs) == 0 {
+		return nil
+	}
+	c.runs = nil
+	return os.RemoveAll(c.dir)
+}
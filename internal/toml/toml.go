@@ -0,0 +1,177 @@
+// Package toml parses the subset of TOML that the job files of wikiparse
+// are written in: keys with values, tables and arrays of tables, with
+// basic and literal strings, arrays of values on one line and comments.
+// Dotted keys, inline tables and multi-line strings are not supported.
+// Values are returned as the strings they are written as, numbers and
+// booleans too, for the caller to convert as it expects.
+package toml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse returns the values of the document in data by key: strings,
+// []any of strings, a map[string]any of a table or a []any of the tables
+// of an array of tables.
+func Parse(data []byte) (map[string]any, error) {
+	top := make(map[string]any)
+	table := top
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		errorf := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", n+1, fmt.Sprintf(format, args...))
+		}
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, errorf("unclosed [[ of an array of tables")
+			}
+			name, err := key(strings.TrimSpace(line[2 : len(line)-2]))
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			tables, ok := top[name].([]any)
+			if _, exists := top[name]; exists && !ok {
+				return nil, errorf("%s is no array of tables", name)
+			}
+			table = make(map[string]any)
+			top[name] = append(tables, table)
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, errorf("unclosed [ of a table")
+			}
+			name, err := key(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			if _, dup := top[name]; dup {
+				return nil, errorf("table %s defined twice", name)
+			}
+			table = make(map[string]any)
+			top[name] = table
+		default:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, errorf("expected a key = value")
+			}
+			name, err := key(strings.TrimSpace(k))
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			if _, dup := table[name]; dup {
+				return nil, errorf("key %q given twice", name)
+			}
+			if table[name], err = value(strings.TrimSpace(v)); err != nil {
+				return nil, errorf("%v", err)
+			}
+		}
+	}
+	return top, nil
+}
+
+// key returns the name of a bare or quoted key.
+func key(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		v, err := value(s)
+		if err != nil {
+			return "", err
+		}
+		return v.(string), nil
+	}
+	if s == "" || strings.ContainsAny(s, " .\t\"'") {
+		return "", fmt.Errorf("bad key %q, dotted keys are not supported", s)
+	}
+	return s, nil
+}
+
+// value returns the value of a string, an array or another scalar.
+func value(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unclosed [ in %s, arrays must be on one line", s)
+		}
+		items := make([]any, 0, 4)
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		for _, item := range splitArray(inner) {
+			if item = strings.TrimSpace(item); item == "" {
+				// A trailing comma.
+				continue
+			}
+			v, err := value(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad basic string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") || strings.Contains(s[1:len(s)-1], "'") {
+			return nil, fmt.Errorf("bad literal string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, "{"):
+		return nil, fmt.Errorf("inline tables like %s are not supported", s)
+	}
+	return s, nil
+}
+
+// stripComment removes the comment from the line, from a "#" outside of
+// strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitArray splits the items of an array at the commas outside of
+// strings.
+func splitArray(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
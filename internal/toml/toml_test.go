@@ -0,0 +1,82 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want map[string]any
+	}{
+		{"empty", "", map[string]any{}},
+		{"comments only", "# a job\n\n   # nothing yet\n", map[string]any{}},
+		{"scalars", "workers = 4\nratio = 0.5\nresume = true\nname=extract\n",
+			map[string]any{"workers": "4", "ratio": "0.5", "resume": "true", "name": "extract"}},
+		{"basic string", `title = "Apollo 11 \"the landing\"\t#1 = x"`, map[string]any{"title": "Apollo 11 \"the landing\"\t#1 = x"}},
+		{"literal string", `path = 'C:\dumps\enwiki # not a comment'`, map[string]any{"path": `C:\dumps\enwiki # not a comment`}},
+		{"quoted keys", "\"a b\" = 1\n'c' = 2", map[string]any{"a b": "1", "c": "2"}},
+		{"comments", "name = x # the name\n# [table]\n", map[string]any{"name": "x"}},
+		{"array", `namespaces = [0, 14, "a, b", 'c',]`, map[string]any{"namespaces": []any{"0", "14", "a, b", "c"}}},
+		{"empty array", "namespaces = []", map[string]any{"namespaces": []any{}}},
+		{"table", "name = x\n[sink]\nkind = \"file\"\npath = \"out.jsonl\"\n[filter]\n",
+			map[string]any{"name": "x", "sink": map[string]any{"kind": "file", "path": "out.jsonl"}, "filter": map[string]any{}}},
+		{"array of tables", "[[processors]]\nname = \"strip\"\n[[processors]]\nname = \"limit\"\nchars = 500\n",
+			map[string]any{"processors": []any{
+				map[string]any{"name": "strip"},
+				map[string]any{"name": "limit", "chars": "500"},
+			}}},
+		{"quoted table", "[\"my sink\"]\nkind = x", map[string]any{"my sink": map[string]any{"kind": "x"}}},
+		{"windows line ends", "a = 1\r\nb = 2\r\n", map[string]any{"a": "1", "b": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		doc, want string
+	}{
+		{"name", "line 1: expected a key = value"},
+		{"name =", "line 1: missing value"},
+		{"a = 1\na = 2", `line 2: key "a" given twice`},
+		{"sink.kind = file", `line 1: bad key "sink.kind", dotted keys are not supported`},
+		{"my key = 1", `line 1: bad key "my key", dotted keys are not supported`},
+		{"= 1", `line 1: bad key "", dotted keys are not supported`},
+		{`title = "Apollo`, `line 1: bad basic string "Apollo`},
+		{"title = 'Apollo", "line 1: bad literal string 'Apollo"},
+		{"ids = [0, 14", "line 1: unclosed [ in [0, 14, arrays must be on one line"},
+		{"ids = [0,\n14]", "line 1: unclosed [ in [0,, arrays must be on one line"},
+		{`ids = [0, "a]`, `line 1: bad basic string "a`},
+		{"sink = {kind = 1}", "line 1: inline tables like {kind = 1} are not supported"},
+		{"[sink", "line 1: unclosed [ of a table"},
+		{"[sink]\n[sink]", "line 2: table sink defined twice"},
+		{"[sink.options]", `line 1: bad key "sink.options", dotted keys are not supported`},
+		{"[[processors]", "line 1: unclosed [[ of an array of tables"},
+		{"processors = 1\n[[processors]]", "line 2: processors is no array of tables"},
+		{"[processors]\n[[processors]]", "line 2: processors is no array of tables"},
+		{"[[processors]]\n[processors]", "line 2: table processors defined twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			v, err := Parse([]byte(tt.doc))
+			if err == nil {
+				t.Fatalf("Parse(%q) = %#v, want an error", tt.doc, v)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse(%q) fails with %q, want %q", tt.doc, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package yaml parses the subset of YAML that the pipeline and job files
+// of wikiparse are written in: block mappings and sequences nested by
+// indentation, flow sequences like [0, 14] of scalars, plain, single and
+// double quoted scalars and comments. Anchors, tags, multi-line scalars
+// and flow mappings are not supported. Scalars are returned as strings,
+// for the caller to convert as it expects.
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A line is a line of the document that is neither blank nor a comment.
+type line struct {
+	number  int // counting from 1
+	indent  int
+	content string // without the indentation and comment
+}
+
+type parser struct {
+	lines []line
+	i     int
+}
+
+// Parse returns the value of the document in data: a map[string]any, a
+// []any or a string, whose values are those again. An empty document is
+// nil.
+func Parse(data []byte) (any, error) {
+	p := &parser{}
+	for n, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripComment(text), " \t\r")
+		content := strings.TrimLeft(text, " ")
+		if content == "" || content == "---" {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n+1)
+		}
+		p.lines = append(p.lines, line{n + 1, len(text) - len(content), content})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.node(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.lines[p.i].number, fmt.Sprintf(format, args...))
+}
+
+// node parses the value starting at the current line, indented by
+// indent.
+func (p *parser) node(indent int) (any, error) {
+	l := p.lines[p.i]
+	switch {
+	case l.content == "-" || strings.HasPrefix(l.content, "- "):
+		return p.sequence(indent)
+	case mappingColon(l.content) >= 0:
+		return p.mapping(indent)
+	}
+	p.i++
+	v, err := scalar(l.content)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", l.number, err)
+	}
+	return v, nil
+}
+
+// mapping parses the keys and values of a block mapping indented by
+// indent.
+func (p *parser) mapping(indent int) (any, error) {
+	m := make(map[string]any)
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		l := p.lines[p.i]
+		colon := mappingColon(l.content)
+		if colon < 0 {
+			return nil, p.errorf("expected a key, like name: value")
+		}
+		key, err := scalar(strings.TrimSpace(l.content[:colon]))
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, p.errorf("a key must be a scalar")
+		}
+		if _, dup := m[k]; dup {
+			return nil, p.errorf("key %q given twice", k)
+		}
+		rest := strings.TrimSpace(l.content[colon+1:])
+		p.i++
+		var v any = ""
+		switch {
+		case rest != "":
+			if v, err = scalar(rest); err != nil {
+				return nil, fmt.Errorf("line %d: %v", l.number, err)
+			}
+		case p.i < len(p.lines) && p.lines[p.i].indent > indent:
+			if v, err = p.node(p.lines[p.i].indent); err != nil {
+				return nil, err
+			}
+		case p.i < len(p.lines) && p.lines[p.i].indent == indent && strings.HasPrefix(p.lines[p.i].content, "-"):
+			// A sequence may be indented as its key.
+			if v, err = p.sequence(indent); err != nil {
+				return nil, err
+			}
+		}
+		m[k] = v
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+// sequence parses the items of a block sequence indented by indent.
+func (p *parser) sequence(indent int) (any, error) {
+	s := make([]any, 0, 4)
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		l := p.lines[p.i]
+		if l.content != "-" && !strings.HasPrefix(l.content, "- ") {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.content, "-"), " ")
+		if rest == "" {
+			p.i++
+			if p.i >= len(p.lines) || p.lines[p.i].indent <= indent {
+				s = append(s, "")
+				continue
+			}
+			v, err := p.node(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+		// The item starts after the dash, as if on a line of its own
+		// indented that far, like a mapping whose next keys line up with
+		// its first.
+		p.lines[p.i].indent, p.lines[p.i].content = indent+len(l.content)-len(rest), rest
+		v, err := p.node(p.lines[p.i].indent)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return s, nil
+}
+
+// mappingColon returns the index of the colon ending the key of the
+// content, one followed by a space or the end outside of quotes, or -1.
+func mappingColon(content string) int {
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == '[' && i == 0:
+			return -1
+		case c == ':' && (i+1 == len(content) || content[i+1] == ' '):
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes the comment from the line, a "#" at its start or
+// after a space outside of quotes.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// scalar returns the value of a scalar or a flow sequence of scalars.
+func scalar(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unclosed [ in %s", s)
+		}
+		items := make([]any, 0, 4)
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, item := range splitFlow(inner) {
+			v, err := scalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad double quoted scalar %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("bad single quoted scalar %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case strings.HasPrefix(s, "{"):
+		return nil, fmt.Errorf("flow mappings like %s are not supported", s)
+	}
+	return s, nil
+}
+
+// splitFlow splits the items of a flow sequence at the commas outside of
+// quotes.
+func splitFlow(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
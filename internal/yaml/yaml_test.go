@@ -0,0 +1,83 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want any
+	}{
+		{"empty", "", nil},
+		{"comments only", "# a pipeline\n\n  # nothing yet\n---\n", nil},
+		{"plain scalar", "Apollo 11", "Apollo 11"},
+		{"mapping", "name: extract\nworkers: 4\n", map[string]any{"name": "extract", "workers": "4"}},
+		{"empty value", "filter:\nname: x", map[string]any{"filter": "", "name": "x"}},
+		{"nested mapping", "sink:\n  kind: file\n  options:\n    path: out.jsonl\nname: x\n",
+			map[string]any{"sink": map[string]any{"kind": "file", "options": map[string]any{"path": "out.jsonl"}}, "name": "x"}},
+		{"sequence", "- a\n- b\n-\n- c", []any{"a", "b", "", "c"}},
+		{"sequence under a key", "namespaces:\n  - 0\n  - 14\n", map[string]any{"namespaces": []any{"0", "14"}}},
+		{"sequence indented as its key", "namespaces:\n- 0\n- 14\nname: x", map[string]any{"namespaces": []any{"0", "14"}, "name": "x"}},
+		{"mappings in a sequence", "processors:\n  - name: strip\n    keep: [refs]\n  - name: limit\n",
+			map[string]any{"processors": []any{
+				map[string]any{"name": "strip", "keep": []any{"refs"}},
+				map[string]any{"name": "limit"},
+			}}},
+		{"item on the next line", "-\n  name: a\n- b", []any{map[string]any{"name": "a"}, "b"}},
+		{"nested sequences", "- - a\n  - b\n- c", []any{[]any{"a", "b"}, "c"}},
+		{"flow sequence", `ids: [0, 14, "a, b", 'c', []]`, map[string]any{"ids": []any{"0", "14", "a, b", "c", []any{}}}},
+		{"empty flow sequence", "ids: [ ]", map[string]any{"ids": []any{}}},
+		{"double quoted", `title: "Apollo: 11 \"the landing\"\t#1"`, map[string]any{"title": "Apollo: 11 \"the landing\"\t#1"}},
+		{"single quoted", `title: 'it''s # not a comment'`, map[string]any{"title": "it's # not a comment"}},
+		{"quoted key", `"a: b": c`, map[string]any{"a: b": "c"}},
+		{"comments", "# header\nname: x # the name\nurl: http://host/#anchor\n", map[string]any{"name": "x", "url": "http://host/#anchor"}},
+		{"colon in a value", "time: 12:30\nurl: http://example.org", map[string]any{"time": "12:30", "url": "http://example.org"}},
+		{"windows line ends", "a: 1\r\nb: 2\r\n", map[string]any{"a": "1", "b": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		doc, want string
+	}{
+		{"a: 1\n\tb: 2", "line 2: tabs are not allowed for indentation"},
+		{"a: 1\n  b: 2", "line 2: unexpected indentation"},
+		{"a:\n    b: 1\n  c: 2", "line 3: unexpected indentation"},
+		{"- a\n  - b", "line 2: unexpected indentation"},
+		{"a: 1\nb", "line 2: expected a key, like name: value"},
+		{"a: 1\na: 2", `line 2: key "a" given twice`},
+		{"[a]: 1", "line 1: unclosed [ in [a]: 1"},
+		{`"a: 1`, `line 1: bad double quoted scalar "a: 1`},
+		{`a: "b`, `line 1: bad double quoted scalar "b`},
+		{"a: 'b", "line 1: bad single quoted scalar 'b"},
+		{"a: [1, 2", "line 1: unclosed [ in [1, 2"},
+		{"a: [1, 'b]", "line 1: bad single quoted scalar 'b"},
+		{"a: {b: 1}", "line 1: flow mappings like {b: 1} are not supported"},
+		{"a:\n  - 1\n  - {b}", "line 3: flow mappings like {b} are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			v, err := Parse([]byte(tt.doc))
+			if err == nil {
+				t.Fatalf("Parse(%q) = %#v, want an error", tt.doc, v)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse(%q) fails with %q, want %q", tt.doc, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Package langid identifies the language of a text, in pure Go: by its
+// script for the languages with one of their own, like Greek or Japanese,
+// and by the frequencies of the trigrams of its letters for those written
+// in the Latin and Cyrillic alphabets, against profiles built from samples
+// of the languages.
+package langid
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxRunes is the length of the start of a text Detect looks at, which
+// is enough to tell the language of all but the shortest.
+const maxRunes = 10000
+
+// minLetters is the fewest letters of a text whose language Detect tells.
+const minLetters = 10
+
+// A profile is the relative frequencies of the trigrams of a language.
+type profile struct {
+	code   string
+	freqs  map[string]float64
+	length float64 // the Euclidean norm of freqs
+}
+
+// The profiles of the languages of the Latin and Cyrillic alphabets, by
+// their script.
+var latinProfiles, cyrillicProfiles []profile
+
+// The scripts of the languages told apart by them alone.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+func init() {
+	for code, text := range samples {
+		p := newProfile(code, text)
+		if unicode.Is(unicode.Cyrillic, []rune(text)[0]) {
+			cyrillicProfiles = append(cyrillicProfiles, p)
+		} else {
+			latinProfiles = append(latinProfiles, p)
+		}
+	}
+	byCode := func(a, b profile) int { return strings.Compare(a.code, b.code) }
+	slices.SortFunc(latinProfiles, byCode)
+	slices.SortFunc(cyrillicProfiles, byCode)
+}
+
+// newProfile returns the profile of the language of the code from the
+// text.
+func newProfile(code string, text string) profile {
+	counts := trigrams(text)
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	p := profile{code: code, freqs: make(map[string]float64, len(counts))}
+	for t, n := range counts {
+		f := float64(n) / float64(total)
+		p.freqs[t] = f
+		p.length += f * f
+	}
+	p.length = math.Sqrt(p.length)
+	return p
+}
+
+// trigrams returns the number of each trigram of the words of the text,
+// lowercased and padded with a space on either side.
+func trigrams(text string) map[string]int {
+	counts := make(map[string]int, 512)
+	word := make([]rune, 0, 32)
+	add := func() {
+		if len(word) == 0 {
+			return
+		}
+		padded := append(append([]rune{' '}, word...), ' ')
+		for i := 0; i+3 <= len(padded); i++ {
+			counts[string(padded[i:i+3])]++
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			word = append(word, unicode.ToLower(r))
+		} else {
+			add()
+		}
+	}
+	add()
+	return counts
+}
+
+// Languages returns the codes of the languages Detect tells, sorted.
+func Languages() []string {
+	codes := make([]string, 0, len(samples)+len(scriptLanguages))
+	for code := range samples {
+		codes = append(codes, code)
+	}
+	for _, s := range scriptLanguages {
+		codes = append(codes, s.code)
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
+
+// Detect returns the ISO 639-1 code of the language of the text, like
+// "en", and the confidence in it from 0 to 1: how much better the
+// language fits than the next best. A text of a script of one language
+// has the confidence of the share of its letters in that script. It
+// returns "" and 0 for texts of too few letters or of none of the
+// languages of Languages.
+func Detect(text string) (code string, confidence float64) {
+	letters, latin, cyrillic := 0, 0, 0
+	scripts := make([]int, len(scriptLanguages))
+	var b strings.Builder
+	runes := 0
+	for _, r := range text {
+		if runes++; runes > maxRunes {
+			break
+		}
+		b.WriteRune(r)
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		default:
+			for i, s := range scriptLanguages {
+				if unicode.Is(s.table, r) {
+					scripts[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters < minLetters {
+		return "", 0
+	}
+	// The script most letters are in. Japanese is written in kana with
+	// Han, which alone is Chinese.
+	byCode := make(map[string]int, len(scriptLanguages))
+	for i, s := range scriptLanguages {
+		byCode[s.code] += scripts[i]
+	}
+	if byCode["ja"] > 0 {
+		byCode["ja"] += byCode["zh"]
+		byCode["zh"] = 0
+	}
+	code, most := "", max(latin, cyrillic)
+	for _, s := range scriptLanguages {
+		if byCode[s.code] > most {
+			code, most = s.code, byCode[s.code]
+		}
+	}
+	if code != "" {
+		return code, float64(most) / float64(letters)
+	}
+	profiles := latinProfiles
+	if cyrillic > latin {
+		profiles = cyrillicProfiles
+	}
+	return closest(profiles, b.String())
+}
+
+// closest returns the code of the profile the trigrams of the text are
+// most similar to by their cosine, and the confidence in it.
+func closest(profiles []profile, text string) (string, float64) {
+	counts := trigrams(text)
+	length := 0.0
+	for _, n := range counts {
+		length += float64(n * n)
+	}
+	length = math.Sqrt(length)
+	code, best, second := "", 0.0, 0.0
+	for _, p := range profiles {
+		dot := 0.0
+		for t, n := range counts {
+			dot += float64(n) * p.freqs[t]
+		}
+		similarity := dot / (length * p.length)
+		switch {
+		case similarity > best:
+			code, best, second = p.code, similarity, best
+		case similarity > second:
+			second = similarity
+		}
+	}
+	if best == 0 {
+		return "", 0
+	}
+	return code, 1 - second/best
+}
@@ -0,0 +1,55 @@
+package langid
+
+// samples are texts of the languages identified by their trigrams, by
+// their code: the first two articles of the Universal Declaration of
+// Human Rights and a few sentences in the style of an encyclopedia.
+var samples = map[string]string{
+	"cs": "Všichni lidé rodí se svobodní a sobě rovní co do důstojnosti i práv. Jsou nadáni rozumem a svědomím a mají spolu jednat v duchu bratrství. " +
+		"Každý má všechna práva a všechny svobody stanovené touto deklarací bez jakéhokoli rozlišování, zejména podle rasy, barvy, pohlaví, jazyka, náboženství, politického nebo jiného smýšlení, národnostního nebo sociálního původu, majetku, rodu nebo jiného postavení. " +
+		"Město je hlavním městem země a největším městem regionu. Bylo založeno ve třináctém století a má přibližně milion obyvatel, což z něj činí jedno z nejvýznamnějších měst světa.",
+	"de": "Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie sind mit Vernunft und Gewissen begabt und sollen einander im Geist der Brüderlichkeit begegnen. " +
+		"Jeder hat Anspruch auf alle in dieser Erklärung verkündeten Rechte und Freiheiten ohne irgendeinen Unterschied, etwa nach Rasse, Hautfarbe, Geschlecht, Sprache, Religion, politischer oder sonstiger Überzeugung, nationaler oder sozialer Herkunft, Vermögen, Geburt oder sonstigem Stand. " +
+		"Die Stadt ist die Hauptstadt des Landes und die größte Stadt der Region. Sie wurde im dreizehnten Jahrhundert gegründet und hat etwa eine Million Einwohner, womit sie zu den wichtigsten Städten der Welt gehört.",
+	"en": "All human beings are born free and equal in dignity and rights. They are endowed with reason and conscience and should act towards one another in a spirit of brotherhood. " +
+		"Everyone is entitled to all the rights and freedoms set forth in this Declaration, without distinction of any kind, such as race, colour, sex, language, religion, political or other opinion, national or social origin, property, birth or other status. " +
+		"The city is the capital of the country and the largest city of the region. It was founded in the thirteenth century and has a population of about one million people, which makes it one of the most important cities in the world.",
+	"es": "Todos los seres humanos nacen libres e iguales en dignidad y derechos y, dotados como están de razón y conciencia, deben comportarse fraternalmente los unos con los otros. " +
+		"Toda persona tiene todos los derechos y libertades proclamados en esta Declaración, sin distinción alguna de raza, color, sexo, idioma, religión, opinión política o de cualquier otra índole, origen nacional o social, posición económica, nacimiento o cualquier otra condición. " +
+		"La ciudad es la capital del país y la ciudad más grande de la región. Fue fundada en el siglo trece y tiene una población de alrededor de un millón de habitantes, lo que la convierte en una de las ciudades más importantes del mundo.",
+	"fi": "Kaikki ihmiset syntyvät vapaina ja tasavertaisina arvoltaan ja oikeuksiltaan. Heille on annettu järki ja omatunto, ja heidän on toimittava toisiaan kohtaan veljeyden hengessä. " +
+		"Jokainen on oikeutettu kaikkiin tässä julistuksessa esitettyihin oikeuksiin ja vapauksiin ilman minkäänlaista rotuun, väriin, sukupuoleen, kieleen, uskontoon, poliittiseen tai muuhun mielipiteeseen, kansalliseen tai yhteiskunnalliseen alkuperään, omaisuuteen, syntyperään tai muuhun tekijään perustuvaa erotusta. " +
+		"Kaupunki on maan pääkaupunki ja alueen suurin kaupunki. Se perustettiin kolmetoista sataluvulla, ja siellä asuu noin miljoona ihmistä, mikä tekee siitä yhden maailman tärkeimmistä kaupungeista.",
+	"fr": "Tous les êtres humains naissent libres et égaux en dignité et en droits. Ils sont doués de raison et de conscience et doivent agir les uns envers les autres dans un esprit de fraternité. " +
+		"Chacun peut se prévaloir de tous les droits et de toutes les libertés proclamés dans la présente Déclaration, sans distinction aucune, notamment de race, de couleur, de sexe, de langue, de religion, d'opinion politique ou de toute autre opinion, d'origine nationale ou sociale, de fortune, de naissance ou de toute autre situation. " +
+		"La ville est la capitale du pays et la plus grande ville de la région. Elle a été fondée au treizième siècle et compte environ un million d'habitants, ce qui en fait l'une des villes les plus importantes du monde.",
+	"hu": "Minden emberi lény szabadon születik és egyenlő méltósága és joga van. Az emberek, ésszel és lelkiismerettel bírván, egymással szemben testvéri szellemben kell hogy viseltessenek. " +
+		"Mindenki, bármely megkülönböztetésre, nevezetesen fajra, színre, nemre, nyelvre, vallásra, politikai vagy bármely más véleményre, nemzeti vagy társadalmi eredetre, vagyonra, születésre, vagy bármely más körülményre való tekintet nélkül hivatkozhat a jelen Nyilatkozatban kinyilvánított összes jogokra és szabadságokra. " +
+		"A város az ország fővárosa és a régió legnagyobb városa. A tizenharmadik században alapították, és körülbelül egymillió lakosa van, ami a világ egyik legfontosabb városává teszi.",
+	"id": "Semua orang dilahirkan merdeka dan mempunyai martabat dan hak-hak yang sama. Mereka dikaruniai akal dan hati nurani dan hendaknya bergaul satu sama lain dalam semangat persaudaraan. " +
+		"Setiap orang berhak atas semua hak dan kebebasan yang tercantum di dalam Pernyataan ini dengan tidak ada kekecualian apa pun, seperti ras, warna kulit, jenis kelamin, bahasa, agama, politik atau pendapat yang berlainan, asal mula kebangsaan atau kemasyarakatan, hak milik, kelahiran ataupun kedudukan lain. " +
+		"Kota ini adalah ibu kota negara dan kota terbesar di wilayah tersebut. Kota ini didirikan pada abad ketiga belas dan memiliki penduduk sekitar satu juta jiwa, yang menjadikannya salah satu kota terpenting di dunia.",
+	"it": "Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti. Essi sono dotati di ragione e di coscienza e devono agire gli uni verso gli altri in spirito di fratellanza. " +
+		"Ad ogni individuo spettano tutti i diritti e tutte le libertà enunciate nella presente Dichiarazione, senza distinzione alcuna, per ragioni di razza, di colore, di sesso, di lingua, di religione, di opinione politica o di altro genere, di origine nazionale o sociale, di ricchezza, di nascita o di altra condizione. " +
+		"La città è la capitale del paese e la città più grande della regione. Fu fondata nel tredicesimo secolo e ha una popolazione di circa un milione di abitanti, il che la rende una delle città più importanti del mondo.",
+	"nl": "Alle mensen worden vrij en gelijk in waardigheid en rechten geboren. Zij zijn begiftigd met verstand en geweten, en behoren zich jegens elkander in een geest van broederschap te gedragen. " +
+		"Een ieder heeft aanspraak op alle rechten en vrijheden, in deze Verklaring opgesomd, zonder enig onderscheid van welke aard ook, zoals ras, kleur, geslacht, taal, godsdienst, politieke of andere overtuiging, nationale of maatschappelijke afkomst, eigendom, geboorte of andere status. " +
+		"De stad is de hoofdstad van het land en de grootste stad van de regio. Zij werd in de dertiende eeuw gesticht en heeft ongeveer een miljoen inwoners, waarmee het een van de belangrijkste steden van de wereld is.",
+	"pl": "Wszyscy ludzie rodzą się wolni i równi pod względem swej godności i swych praw. Są oni obdarzeni rozumem i sumieniem i powinni postępować wobec innych w duchu braterstwa. " +
+		"Każdy człowiek posiada wszystkie prawa i wolności zawarte w niniejszej Deklaracji bez względu na jakiekolwiek różnice rasy, koloru skóry, płci, języka, wyznania, poglądów politycznych i innych, narodowości, pochodzenia społecznego, majątku, urodzenia lub jakiegokolwiek innego stanu. " +
+		"Miasto jest stolicą kraju i największym miastem regionu. Zostało założone w trzynastym wieku i liczy około miliona mieszkańców, co czyni je jednym z najważniejszych miast świata.",
+	"pt": "Todos os seres humanos nascem livres e iguais em dignidade e em direitos. Dotados de razão e de consciência, devem agir uns para com os outros em espírito de fraternidade. " +
+		"Todos os seres humanos podem invocar os direitos e as liberdades proclamados na presente Declaração, sem distinção alguma, nomeadamente de raça, de cor, de sexo, de língua, de religião, de opinião política ou outra, de origem nacional ou social, de fortuna, de nascimento ou de qualquer outra situação. " +
+		"A cidade é a capital do país e a maior cidade da região. Foi fundada no século treze e tem uma população de cerca de um milhão de habitantes, o que faz dela uma das cidades mais importantes do mundo.",
+	"ru": "Все люди рождаются свободными и равными в своем достоинстве и правах. Они наделены разумом и совестью и должны поступать в отношении друг друга в духе братства. " +
+		"Каждый человек должен обладать всеми правами и всеми свободами, провозглашенными настоящей Декларацией, без какого бы то ни было различия, как-то в отношении расы, цвета кожи, пола, языка, религии, политических или иных убеждений, национального или социального происхождения, имущественного, сословного или иного положения. " +
+		"Город является столицей страны и крупнейшим городом региона. Он был основан в тринадцатом веке, и его население составляет около миллиона человек, что делает его одним из важнейших городов мира.",
+	"sv": "Alla människor är födda fria och lika i värde och rättigheter. De har utrustats med förnuft och samvete och bör handla gentemot varandra i en anda av broderskap. " +
+		"Var och en är berättigad till alla de fri- och rättigheter som uttalas i denna förklaring utan åtskillnad av något slag, såsom ras, hudfärg, kön, språk, religion, politisk eller annan uppfattning, nationellt eller socialt ursprung, egendom, börd eller ställning i övrigt. " +
+		"Staden är landets huvudstad och den största staden i regionen. Den grundades på tolvhundratalet och har omkring en miljon invånare, vilket gör den till en av de viktigaste städerna i världen.",
+	"tr": "Bütün insanlar hür, haysiyet ve haklar bakımından eşit doğarlar. Akıl ve vicdana sahiptirler ve birbirlerine karşı kardeşlik zihniyeti ile hareket etmelidirler. " +
+		"Herkes, ırk, renk, cinsiyet, dil, din, siyasi veya diğer herhangi bir akide, milli veya içtimai menşe, servet, doğuş veya herhangi diğer bir fark gözetilmeksizin işbu Beyannamede ilan olunan tekmil haklardan ve bütün hürriyetlerden istifade edebilir. " +
+		"Şehir ülkenin başkenti ve bölgenin en büyük şehridir. On üçüncü yüzyılda kurulmuştur ve yaklaşık bir milyon nüfusa sahiptir, bu da onu dünyanın en önemli şehirlerinden biri yapar.",
+	"uk": "Всі люди народжуються вільними і рівними у своїй гідності та правах. Вони наділені розумом і совістю і повинні діяти у відношенні один до одного в дусі братерства. " +
+		"Кожна людина повинна мати всі права і всі свободи, проголошені цією Декларацією, незалежно від раси, кольору шкіри, статі, мови, релігії, політичних або інших переконань, національного чи соціального походження, майнового, станового або іншого становища. " +
+		"Місто є столицею країни і найбільшим містом регіону. Воно було засноване в тринадцятому столітті, і його населення становить близько мільйона людей, що робить його одним із найважливіших міст світу.",
+}
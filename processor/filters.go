@@ -0,0 +1,82 @@
+// The built-in processors, which drop the articles not wanted
+
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// checkParams returns an error if params has others than those allowed.
+func checkParams(params map[string]string, allowed ...string) error {
+	for k := range params {
+		if !slices.Contains(allowed, k) {
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	return nil
+}
+
+// newCategory returns a processor keeping only the articles in the
+// category of the parameter "category", without the namespace.
+func newCategory(params map[string]string) (Func, error) {
+	if err := checkParams(params, "category"); err != nil {
+		return nil, err
+	}
+	name := dump.CanonicalizeTitle(params["category"])
+	if name == "" {
+		return nil, errors.New("needs the name of the category")
+	}
+	return func(_ *dump.Page, doc *wikitext.Document) error {
+		for _, c := range wikitext.Categories(doc) {
+			if dump.CanonicalizeTitle(c.Name) == name {
+				return nil
+			}
+		}
+		return ErrSkip
+	}, nil
+}
+
+// newMinBytes returns a processor keeping only the articles with at least
+// the parameter "bytes" of wikitext.
+func newMinBytes(params map[string]string) (Func, error) {
+	if err := checkParams(params, "bytes"); err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(params["bytes"])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("bytes must be a number of bytes, not %q", params["bytes"])
+	}
+	return func(p *dump.Page, _ *wikitext.Document) error {
+		if len(p.Text) < n {
+			return ErrSkip
+		}
+		return nil
+	}, nil
+}
+
+// newNoTemplate returns a processor dropping the articles that transclude
+// the template of the parameter "template", like "Disambiguation",
+// without the namespace.
+func newNoTemplate(params map[string]string) (Func, error) {
+	if err := checkParams(params, "template"); err != nil {
+		return nil, err
+	}
+	name := dump.CanonicalizeTitle(params["template"])
+	if name == "" {
+		return nil, errors.New("needs the name of the template")
+	}
+	return func(_ *dump.Page, doc *wikitext.Document) error {
+		for _, t := range wikitext.Templates(doc, 1) {
+			if dump.CanonicalizeTitle(t.Name) == name {
+				return ErrSkip
+			}
+		}
+		return nil
+	}, nil
+}
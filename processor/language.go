@@ -0,0 +1,85 @@
+// The language processor, which annotates the articles with the language
+// of their text
+
+package processor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/langid"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// A SectionLanguage is the language of the text of a section, by the
+// anchor of the section, empty for the lead.
+type SectionLanguage struct {
+	Section  string `json:"section"`
+	Language string `json:"language"`
+}
+
+// newLanguage returns a processor annotating the articles with the code
+// of the language of their plain text as "language" and the confidence in
+// it as "language_confidence", from langid.Detect. With the parameter
+// "sections" true it annotates them with the languages of their sections
+// as "section_languages" too, a []SectionLanguage of the sections of text
+// enough to tell, and with "keep", a list of codes separated by commas
+// like "en,simple", it drops the articles of other languages.
+func newLanguage(params map[string]string) (Func, error) {
+	if err := checkParams(params, "sections", "keep"); err != nil {
+		return nil, err
+	}
+	sections := false
+	switch params["sections"] {
+	case "", "false":
+	case "true":
+		sections = true
+	default:
+		return nil, fmt.Errorf("sections must be true or false, not %q", params["sections"])
+	}
+	var keep map[string]bool
+	if params["keep"] != "" {
+		keep = make(map[string]bool)
+		for _, code := range strings.Split(params["keep"], ",") {
+			keep[strings.TrimSpace(code)] = true
+		}
+	}
+	return func(p *dump.Page, doc *wikitext.Document) error {
+		code, confidence := langid.Detect(wikitext.PlainText(doc))
+		if keep != nil && !keep[code] {
+			return ErrSkip
+		}
+		p.Annotate("language", code)
+		p.Annotate("language_confidence", math.Round(confidence*100)/100)
+		if sections {
+			p.Annotate("section_languages", sectionLanguages(doc))
+		}
+		return nil
+	}, nil
+}
+
+// sectionLanguages returns the languages of the text of the sections of
+// the document, in document order.
+func sectionLanguages(doc *wikitext.Document) []SectionLanguage {
+	languages := make([]SectionLanguage, 0, 8)
+	var text strings.Builder
+	section := ""
+	flush := func() {
+		if code, _ := langid.Detect(text.String()); code != "" {
+			languages = append(languages, SectionLanguage{section, code})
+		}
+		text.Reset()
+	}
+	for _, s := range wikitext.Sentences(doc) {
+		if s.Section != section {
+			flush()
+			section = s.Section
+		}
+		text.WriteString(s.Text)
+		text.WriteByte(' ')
+	}
+	flush()
+	return languages
+}
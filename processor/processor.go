@@ -0,0 +1,178 @@
+// Package processor runs functions over the parsed articles of a dump,
+// like language detection, scrubbing personal data or custom extraction,
+// each given the page and its document and run in order as a Chain. A
+// processor may change the document, or drop the article by returning
+// ErrSkip. Other packages add processors with Register, by which a
+// pipeline file names them; the built-in ones are category, language,
+// minbytes and notemplate:
+//
+//	func init() {
+//		processor.Register("scrub", func(params map[string]string) (processor.Func, error) {
+//			return scrubEmails, nil
+//		})
+//	}
+//
+// A pipeline file lists the processors of a chain in YAML, each by its
+// name or as a mapping of its name and parameters:
+//
+//	processors:
+//	  - scrub
+//	  - name: minbytes
+//	    bytes: 2000
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/yaml"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// A Func processes an article with its parsed document. It may be called
+// from several goroutines at once, for different articles.
+type Func func(p *dump.Page, doc *wikitext.Document) error
+
+// ErrSkip is returned by a Func to drop the article, without running the
+// processors after it.
+var ErrSkip = errors.New("processor: skip the article")
+
+// A Factory returns a new processor with the parameters given in a
+// pipeline file, which it checks.
+type Factory func(params map[string]string) (Func, error)
+
+var processors = struct {
+	sync.Mutex
+	m map[string]Factory
+}{m: make(map[string]Factory)}
+
+// Register makes the processors returned by newFunc available by the
+// name, like "minbytes". It panics if the name is registered twice.
+func Register(name string, newFunc Factory) {
+	processors.Lock()
+	defer processors.Unlock()
+	if newFunc == nil {
+		panic("processor: Register of a nil processor " + name)
+	}
+	if _, dup := processors.m[name]; dup {
+		panic("processor: Register called twice for " + name)
+	}
+	processors.m[name] = newFunc
+}
+
+// New returns a new processor of the name with the parameters.
+func New(name string, params map[string]string) (Func, error) {
+	processors.Lock()
+	newFunc, ok := processors.m[name]
+	processors.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	f, err := newFunc(params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return f, nil
+}
+
+// Names returns the names of the processors registered, sorted.
+func Names() []string {
+	processors.Lock()
+	defer processors.Unlock()
+	names := make([]string, 0, len(processors.m))
+	for name := range processors.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// A Chain is a list of processors run one after the other.
+type Chain []Func
+
+// Run runs the processors on the article in order, stopping at the first
+// that returns an error, which it returns.
+func (c Chain) Run(p *dump.Page, doc *wikitext.Document) error {
+	for _, f := range c {
+		if err := f(p, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPipeline returns the chain of the processors listed by the pipeline
+// file read from r.
+func ReadPipeline(r io.Reader) (Chain, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := yaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	top, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("expected a mapping with processors")
+	}
+	for key := range top {
+		if key != "processors" {
+			return nil, fmt.Errorf("unknown key %q, expected processors", key)
+		}
+	}
+	list, ok := top["processors"].([]any)
+	if !ok {
+		return nil, errors.New("processors must be a list")
+	}
+	return ParsePipeline(list)
+}
+
+// ParsePipeline returns the chain of the processors of a list parsed from
+// YAML, like that of a pipeline file, whose items are names or mappings of
+// "name" and the parameters.
+func ParsePipeline(list []any) (Chain, error) {
+	chain := make(Chain, 0, len(list))
+	for i, item := range list {
+		name, params := "", make(map[string]string)
+		switch item := item.(type) {
+		case string:
+			name = item
+		case map[string]any:
+			for k, v := range item {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("processor %d: parameter %s must be a scalar", i+1, k)
+				}
+				if k == "name" {
+					name = s
+				} else {
+					params[k] = s
+				}
+			}
+		default:
+			return nil, fmt.Errorf("processor %d: expected a name or a mapping", i+1)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("processor %d: has no name", i+1)
+		}
+		f, err := New(name, params)
+		if err != nil {
+			return nil, fmt.Errorf("processor %d: %w", i+1, err)
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
+
+func init() {
+	Register("category", newCategory)
+	Register("language", newLanguage)
+	Register("minbytes", newMinBytes)
+	Register("notemplate", newNoTemplate)
+}
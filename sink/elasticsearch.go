@@ -0,0 +1,91 @@
+// The elasticsearch sink: bulk indexing of the articles in Elasticsearch
+// or OpenSearch, for full-text search
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/elastic"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// DefaultMapping is the mapping of the indexes the elasticsearch sink
+// creates without one of its own: the text is analyzed in English and
+// titles are also matched exactly.
+const DefaultMapping = `{
+  "mappings": {
+    "properties": {
+      "title": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+      "page_id": {"type": "long"},
+      "namespace": {"type": "integer"},
+      "revision": {"type": "long"},
+      "text": {"type": "text", "analyzer": "english"},
+      "categories": {"type": "keyword"}
+    }
+  }
+}`
+
+// Elasticsearch indexes every article as an Article in the index at the
+// target, the URL of the cluster followed by the name of the index,
+// http://localhost:9200/wikipedia by default, creating the index if
+// needed. Articles are identified by their canonical title, so that
+// indexing a newer dump updates them. The fields are set before Open;
+// New returns one of batches of 500 articles, retried 5 times.
+type Elasticsearch struct {
+	Mapping  []byte // the settings and mappings of the index, DefaultMapping if nil
+	Batch    int    // articles per bulk request
+	Retries  int    // how often failed requests and rejected articles are retried
+	Username string
+	Password string
+	APIKey   string // used instead of the username and password if set
+
+	ix   *elastic.Indexer
+	site *dump.SiteInfo
+}
+
+func (e *Elasticsearch) Open(_ context.Context, opts Options) error {
+	target := opts.Target
+	if target == "" {
+		target = "http://localhost:9200/wikipedia"
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	index := path.Base(u.Path)
+	if u.Scheme == "" || u.Host == "" || index == "/" || index == "." {
+		return fmt.Errorf("elasticsearch: %q is no URL of an index, like http://localhost:9200/wikipedia", target)
+	}
+	u.Path = strings.TrimSuffix(path.Dir(u.Path), "/")
+	e.ix = elastic.NewIndexer(u.String(), index)
+	e.ix.Batch, e.ix.Retries = e.Batch, e.Retries
+	e.ix.Username, e.ix.Password, e.ix.APIKey = e.Username, e.Password, e.APIKey
+	e.site = opts.Site
+	mapping := e.Mapping
+	if mapping == nil {
+		mapping = []byte(DefaultMapping)
+	}
+	return e.ix.CreateIndex(mapping)
+}
+
+func (e *Elasticsearch) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if doc == nil {
+		return nil
+	}
+	return e.ix.Add(e.site.CanonicalizeTitle(p.Title), NewArticle(p, doc))
+}
+
+func (e *Elasticsearch) Close() error {
+	return e.ix.Close()
+}
+
+// Indexed returns the number of articles indexed so far.
+func (e *Elasticsearch) Indexed() int {
+	return e.ix.Indexed
+}
@@ -0,0 +1,139 @@
+// Package sink writes the parsed pages of a dump to a destination, like a
+// JSONL file, a SQLite database or an Elasticsearch index, through the
+// OutputSink interface. The built-in sinks are stdout, files, jsonl,
+// sqlite, elasticsearch and wikiextractor; other packages add their own
+// with Register, like database/sql drivers, and wikiparse export writes to
+// them by name:
+//
+//	func init() {
+//		sink.Register("mysink", func() sink.OutputSink { return new(MySink) })
+//	}
+package sink
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// An OutputSink writes the pages of a run. Open is called once before
+// the first page and Close once after the last, whose error tells
+// whether all pages were written. WritePage is called for every page in
+// the order of the dump, from one goroutine, with the parsed document of
+// the articles among them; other pages, like redirects, have none.
+type OutputSink interface {
+	Open(ctx context.Context, opts Options) error
+	WritePage(p *dump.Page, doc *wikitext.Document) error
+	Close() error
+}
+
+// Options configure a sink as it is opened.
+type Options struct {
+	// Target is where the sink writes: a file, a directory or a URL, as
+	// the sink expects; its default if empty.
+	Target string
+	// Site canonicalizes the titles by the case rules of the wiki; those
+	// of the case-insensitive first letter of Wikipedia if nil.
+	Site *dump.SiteInfo
+}
+
+var sinks = struct {
+	sync.Mutex
+	m map[string]func() OutputSink
+}{m: make(map[string]func() OutputSink)}
+
+// Register makes the sinks returned by newSink available by the name,
+// like "jsonl". It panics if the name is registered twice.
+func Register(name string, newSink func() OutputSink) {
+	sinks.Lock()
+	defer sinks.Unlock()
+	if newSink == nil {
+		panic("sink: Register of a nil sink " + name)
+	}
+	if _, dup := sinks.m[name]; dup {
+		panic("sink: Register called twice for " + name)
+	}
+	sinks.m[name] = newSink
+}
+
+// New returns a new sink of the name, not yet opened.
+func New(name string) (OutputSink, error) {
+	sinks.Lock()
+	newSink, ok := sinks.m[name]
+	sinks.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return newSink(), nil
+}
+
+// Names returns the names of the sinks registered, sorted.
+func Names() []string {
+	sinks.Lock()
+	defer sinks.Unlock()
+	names := make([]string, 0, len(sinks.m))
+	for name := range sinks.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// An Article is an article as the jsonl and elasticsearch sinks write it.
+type Article struct {
+	Title      string   `json:"title"`
+	PageID     int64    `json:"page_id"`
+	Namespace  int      `json:"namespace"`
+	Revision   int64    `json:"revision"`
+	Text       string   `json:"text"`
+	Categories []string `json:"categories"`
+
+	// Navboxes are the navigational boxes left out of the text, if any.
+	Navboxes []Navbox `json:"navboxes,omitempty"`
+
+	// Annotations are those of the processors, if any.
+	Annotations map[string]any `json:"annotations,omitempty"`
+}
+
+// A Navbox is a navigational box of an article, like {{Navbox}} or a
+// succession box, with the targets of its links to articles.
+type Navbox struct {
+	Name  string   `json:"name"`
+	Links []string `json:"links"`
+}
+
+// NewArticle returns the article of the page with its plain text,
+// categories and navboxes and the annotations of the processors.
+func NewArticle(p *dump.Page, doc *wikitext.Document) Article {
+	categories := make([]string, 0, 4)
+	for _, c := range wikitext.Categories(doc) {
+		categories = append(categories, c.Name)
+	}
+	var navboxes []Navbox
+	for _, n := range wikitext.Navboxes(doc) {
+		links := make([]string, 0, len(n.Links))
+		for _, l := range n.Links {
+			if l.Class == wikitext.LinkArticle && l.Interwiki == "" && l.Target != "" {
+				links = append(links, l.Target)
+			}
+		}
+		navboxes = append(navboxes, Navbox{n.Name, links})
+	}
+	return Article{p.Title, p.ID, p.Namespace, p.RevisionID, wikitext.PlainText(doc), categories, navboxes, p.Annotations}
+}
+
+func init() {
+	Register("stdout", func() OutputSink { return new(Stdout) })
+	Register("files", func() OutputSink { return new(Files) })
+	Register("jsonl", func() OutputSink { return new(JSONL) })
+	Register("sqlite", func() OutputSink { return new(SQLite) })
+	Register("elasticsearch", func() OutputSink { return &Elasticsearch{Batch: 500, Retries: 5} })
+	Register("wikiextractor", func() OutputSink {
+		return &WikiExtractor{MaxBytes: 1 << 20, URLBase: "https://en.wikipedia.org/wiki?curid="}
+	})
+}
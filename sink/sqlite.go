@@ -0,0 +1,109 @@
+// The sqlite sink: a SQLite database of the articles with their
+// sections, links, categories and templates
+
+package sink
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/internal/sqlite"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// SQLiteTables are the tables of the databases of the sqlite sink, by
+// name and the SQL creating them. Rows refer to their article by its page
+// id. The positions of sections and templates count from 1 in document
+// order.
+var SQLiteTables = []struct{ Name, SQL string }{
+	{"pages", "CREATE TABLE pages(id INTEGER, title TEXT, canonical TEXT, revision INTEGER, text TEXT)"},
+	{"redirects", "CREATE TABLE redirects(source TEXT, target TEXT)"},
+	{"sections", "CREATE TABLE sections(page INTEGER, position INTEGER, level INTEGER, heading TEXT, anchor TEXT)"},
+	{"links", "CREATE TABLE links(page INTEGER, target TEXT, section TEXT, interwiki TEXT, class TEXT, anchor TEXT)"},
+	{"categories", "CREATE TABLE categories(page INTEGER, category TEXT, sortkey TEXT)"},
+	{"templates", "CREATE TABLE templates(page INTEGER, position INTEGER, name TEXT)"},
+	{"template_params", "CREATE TABLE template_params(page INTEGER, template INTEGER, name TEXT, value TEXT)"},
+}
+
+// SQLite writes the articles to a new database at the target, out/wiki.db
+// by default, with their plain text, sections, links, categories and
+// templates, and the redirects of the pages to the redirects table. Link
+// targets are canonical titles, of the wiki by its case rules, and
+// redirects are not resolved.
+type SQLite struct {
+	db     *sqlite.DB
+	tables map[string]*sqlite.Table
+	site   *dump.SiteInfo
+}
+
+func (s *SQLite) Open(_ context.Context, opts Options) error {
+	path := opts.Target
+	if path == "" {
+		path = "out/wiki.db"
+	}
+	db, err := sqlite.Create(path)
+	if err != nil {
+		return err
+	}
+	db.SetUserVersion(schema.Version)
+	s.db, s.site = db, opts.Site
+	s.tables = make(map[string]*sqlite.Table, len(SQLiteTables))
+	for _, t := range SQLiteTables {
+		s.tables[t.Name] = db.CreateTable(t.Name, t.SQL)
+	}
+	return nil
+}
+
+// WritePage adds the rows of the page. Write errors are sticky in the
+// database and returned by Close.
+func (s *SQLite) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if p.Redir.Title != "" {
+		s.tables["redirects"].Insert(p.Title, p.Redir.Title)
+	}
+	if doc == nil {
+		return nil
+	}
+	title := s.site.CanonicalizeTitle(p.Title)
+	s.tables["pages"].Insert(p.ID, p.Title, title, p.RevisionID, wikitext.PlainText(doc))
+	position := 0
+	s.insertSections(p.ID, &position, wikitext.Sections(doc))
+	for _, link := range wikitext.Links(doc) {
+		target := title
+		switch {
+		case link.Interwiki != "":
+			target = dump.CanonicalizeTitle(link.Target)
+		case link.Target != "":
+			target = s.site.CanonicalizeTitle(link.Target)
+		}
+		s.tables["links"].Insert(p.ID, target, link.Section, link.Interwiki, link.Class.String(), link.Anchor)
+	}
+	for _, c := range wikitext.Categories(doc) {
+		s.tables["categories"].Insert(p.ID, c.Name, c.SortKey)
+	}
+	for i, t := range wikitext.Templates(doc, 1) {
+		s.tables["templates"].Insert(p.ID, i+1, t.Name)
+		for _, param := range t.Params {
+			s.tables["template_params"].Insert(p.ID, i+1, param.Name, param.Value.Text)
+		}
+	}
+	return nil
+}
+
+// insertSections adds the sections with a heading, numbered from the
+// position on, and their subsections.
+func (s *SQLite) insertSections(id int64, position *int, sections []wikitext.Section) {
+	for _, sec := range sections {
+		if sec.Heading != "" {
+			*position++
+			heading := strings.Join(strings.Fields(sec.Heading), " ")
+			s.tables["sections"].Insert(id, *position, sec.Level, heading, sec.Anchor)
+		}
+		s.insertSections(id, position, sec.Children)
+	}
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
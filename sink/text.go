@@ -0,0 +1,172 @@
+// The sinks of the text of the articles: to stdout, a file per article
+// and a JSONL file
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/internal/filename"
+	"github.com/pcmoritz/wikipedia/internal/schema"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// Stdout writes the title and plain text of every article to stdout,
+// followed by a blank line. It has no target.
+type Stdout struct {
+	w *bufio.Writer
+}
+
+func (s *Stdout) Open(_ context.Context, _ Options) error {
+	s.w = bufio.NewWriter(os.Stdout)
+	return nil
+}
+
+func (s *Stdout) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if doc == nil {
+		return nil
+	}
+	_, err := io.WriteString(s.w, p.Title+"\n"+wikitext.PlainText(doc)+"\n\n")
+	return err
+}
+
+func (s *Stdout) Close() error {
+	return s.w.Flush()
+}
+
+// FileFormats are the formats of the files the files sink writes.
+var FileFormats = []string{"txt", "json", "html"}
+
+// Files writes every article to a file of its own in the target
+// directory, out/articles by default, named by its canonical title made
+// safe for all file systems: its plain text, its Article as JSON with the
+// extension .json or its HTML with the extension .html, as Format tells.
+// With Shards, the files are spread over that many levels of
+// subdirectories, as by filename.Shard. The directory also gets the
+// manifest _index.tsv, which no file of an article is named, of the title,
+// page id, revision and path of every article, relative to it. The fields
+// are set before Open.
+type Files struct {
+	Format  string // one of FileFormats, txt if empty
+	Shards  int    // levels of subdirectories, at most 4
+	BaseURL string // the URL wiki links of HTML are relative to, like https://en.wikipedia.org/wiki/
+
+	dir   string
+	names *filename.Namer
+	site  *dump.SiteInfo
+	index *os.File
+	w     *bufio.Writer
+}
+
+func (f *Files) Open(_ context.Context, opts Options) error {
+	if f.Format == "" {
+		f.Format = "txt"
+	}
+	if !slices.Contains(FileFormats, f.Format) {
+		return fmt.Errorf("files: unknown format %q, expected one of %s", f.Format, strings.Join(FileFormats, ", "))
+	}
+	if f.Shards < 0 || f.Shards > 4 {
+		return fmt.Errorf("files: %d levels of subdirectories, expected 0 to 4", f.Shards)
+	}
+	f.dir = opts.Target
+	if f.dir == "" {
+		f.dir = "out/articles"
+	}
+	f.names, f.site = filename.NewNamer(), opts.Site
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	index, err := os.Create(filepath.Join(f.dir, "_index.tsv"))
+	if err != nil {
+		return err
+	}
+	f.index, f.w = index, bufio.NewWriter(index)
+	_, err = schema.WriteHeader(f.w, "files-index")
+	return err
+}
+
+func (f *Files) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if doc == nil {
+		return nil
+	}
+	var data []byte
+	name := f.names.Name(p.Title, f.site.CanonicalizeTitle(p.Title))
+	switch f.Format {
+	case "txt":
+		data = []byte(wikitext.PlainText(doc) + "\n")
+	case "json":
+		var err error
+		if data, err = json.Marshal(NewArticle(p, doc)); err != nil {
+			return err
+		}
+		data, name = append(data, '\n'), name+".json"
+	case "html":
+		data, name = []byte(wikitext.HTML(doc, f.BaseURL)), name+".html"
+	}
+	path := filename.Shard(name, f.Shards)
+	if f.Shards > 0 {
+		if err := os.MkdirAll(filepath.Join(f.dir, filepath.Dir(filepath.FromSlash(path))), 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, filepath.FromSlash(path)), data, 0644); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(f.w, "%s\t%d\t%d\t%s\n", p.Title, p.ID, p.RevisionID, path)
+	return err
+}
+
+func (f *Files) Close() error {
+	err := f.w.Flush()
+	if e := f.index.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// JSONL writes every article as a JSON object, an Article, per line to
+// the target file, out/articles.jsonl by default.
+type JSONL struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func (j *JSONL) Open(_ context.Context, opts Options) error {
+	path := opts.Target
+	if path == "" {
+		path = "out/articles.jsonl"
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	j.file, j.w = file, bufio.NewWriter(file)
+	j.enc = json.NewEncoder(j.w)
+	j.enc.SetEscapeHTML(false)
+	return nil
+}
+
+func (j *JSONL) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if doc == nil {
+		return nil
+	}
+	return j.enc.Encode(NewArticle(p, doc))
+}
+
+func (j *JSONL) Close() error {
+	err := j.w.Flush()
+	if e := j.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
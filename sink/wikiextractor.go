@@ -0,0 +1,127 @@
+// The wikiextractor sink: the output of the Python WikiExtractor, for the
+// pipelines that read it
+
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/dump"
+	"github.com/pcmoritz/wikipedia/wikitext"
+)
+
+// WikiExtractor writes the articles as the WikiExtractor of Python does,
+// to files of at most about MaxBytes named wiki_00 to wiki_99 in the
+// directories AA, AB and so on of the target, out/text by default. Each
+// article is a block of its title and plain text, with "&", "<" and ">"
+// escaped as by the html-safe output of WikiExtractor:
+//
+//	<doc id="12" url="https://en.wikipedia.org/wiki?curid=12" title="Anarchism">
+//	Anarchism
+//
+//	Anarchism is a political philosophy ...
+//	</doc>
+//
+// or with JSON a JSON object per line of the id, revid, url, title and
+// text, all strings. The fields are set before Open; New returns one of
+// files of 1 MiB with the URLs of the English Wikipedia.
+type WikiExtractor struct {
+	MaxBytes int64
+	JSON     bool
+	URLBase  string // the URL the ids are appended to, like https://en.wikipedia.org/wiki?curid=
+
+	dir     string
+	file    *os.File
+	w       *bufio.Writer
+	files   int   // the files opened so far
+	written int64 // bytes written to the current file
+}
+
+// wikiExtractorEscaper escapes the text of the html-safe output.
+var wikiExtractorEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (e *WikiExtractor) Open(_ context.Context, opts Options) error {
+	e.dir = opts.Target
+	if e.dir == "" {
+		e.dir = "out/text"
+	}
+	if e.MaxBytes < 1 {
+		return fmt.Errorf("wikiextractor: files of %d bytes, expected at least 1", e.MaxBytes)
+	}
+	return e.next()
+}
+
+// next closes the current file, if any, and creates the next one.
+func (e *WikiExtractor) next() error {
+	if e.file != nil {
+		if err := e.Close(); err != nil {
+			return err
+		}
+	}
+	dirs := e.files / 100
+	if dirs >= 26*26 {
+		return fmt.Errorf("wikiextractor: more than %d files", 26*26*100)
+	}
+	dir := filepath.Join(e.dir, string(rune('A'+dirs/26))+string(rune('A'+dirs%26)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(dir, fmt.Sprintf("wiki_%02d", e.files%100)))
+	if err != nil {
+		return err
+	}
+	e.file, e.w, e.written = file, bufio.NewWriter(file), 0
+	e.files++
+	return nil
+}
+
+func (e *WikiExtractor) WritePage(p *dump.Page, doc *wikitext.Document) error {
+	if doc == nil {
+		return nil
+	}
+	if e.written >= e.MaxBytes {
+		if err := e.next(); err != nil {
+			return err
+		}
+	}
+	id := strconv.FormatInt(p.ID, 10)
+	url := e.URLBase + id
+	text := wikitext.PlainText(doc)
+	var block bytes.Buffer
+	if e.JSON {
+		enc := json.NewEncoder(&block)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(struct {
+			ID    string `json:"id"`
+			RevID string `json:"revid"`
+			URL   string `json:"url"`
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		}{id, strconv.FormatInt(p.RevisionID, 10), url, p.Title, text})
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(&block, "<doc id=\"%s\" url=\"%s\" title=\"%s\">\n%s\n\n%s\n</doc>\n",
+			id, url, p.Title, wikiExtractorEscaper.Replace(p.Title), wikiExtractorEscaper.Replace(text))
+	}
+	n, err := e.w.Write(block.Bytes())
+	e.written += int64(n)
+	return err
+}
+
+func (e *WikiExtractor) Close() error {
+	err := e.w.Flush()
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+-- MySQL dump of the page_props table of the pages of minidump.xml
+
+DROP TABLE IF EXISTS `page_props`;
+CREATE TABLE `page_props` (
+  `pp_page` int(10) unsigned NOT NULL,
+  `pp_propname` varbinary(60) NOT NULL,
+  `pp_value` blob NOT NULL,
+  `pp_sortkey` float DEFAULT NULL,
+  PRIMARY KEY (`pp_page`,`pp_propname`)
+) ENGINE=InnoDB DEFAULT CHARSET=binary;
+
+INSERT INTO `page_props` VALUES (2,'wikibase_item','Q43653',NULL),(4,'displaytitle','Neil \'Alden\' Armstrong',NULL),(4,'wikibase_item','Q1615',NULL);
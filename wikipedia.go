@@ -0,0 +1,26 @@
+// Package wikipedia is the root of the module. The work is done by its
+// packages:
+//
+//   - dump reads XML dumps page by page and resolves redirects and
+//     category hierarchies,
+//   - wikitext lexes the wikitext of articles and extracts sections,
+//     links, categories and citations.
+//
+// This package only forwards the names the code had before it was split
+// into packages. They are deprecated and will be removed in v2.
+package wikipedia
+
+import (
+	"github.com/pcmoritz/wikipedia/dump"
+)
+
+// Deprecated: Use dump.Page.
+type Page = dump.Page
+
+// Deprecated: Use dump.Redirect.
+type Redirect = dump.Redirect
+
+// Deprecated: Use dump.CanonicalizeTitle.
+func CanonicalizeTitle(title string) string {
+	return dump.CanonicalizeTitle(title)
+}
@@ -0,0 +1,119 @@
+package wikitext
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// repeat joins n variations of a paragraph, numbered by i.
+func repeat(n int, paragraph func(i int) string) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = paragraph(i)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// leadArticle is prose, like the lead and body of most articles.
+func leadArticle() string {
+	return "'''Apollo 11''' was the American [[spaceflight]] that first landed humans on the [[Moon]].\n\n" +
+		repeat(200, func(i int) string {
+			return fmt.Sprintf("== Section %d ==\nCommander [[Neil Armstrong]] and [[Lunar Module]] pilot "+
+				"[[Buzz Aldrin|Aldrin]] landed the ''Eagle'' on July 20, 1969, at 20:17 [[Coordinated Universal Time|UTC]]. "+
+				"Armstrong became the first person to step onto the surface %d hours later; Aldrin joined him "+
+				"19 minutes later &ndash; they spent about two and a quarter hours together outside the spacecraft.", i, i)
+		}) + "\n\n[[Category:Apollo program]]\n[[Category:Neil Armstrong]]"
+}
+
+// tableArticle is mostly tables, like lists and sports results.
+func tableArticle() string {
+	return "The missions of the program:\n\n" + repeat(20, func(i int) string {
+		rows := repeat(40, func(j int) string {
+			return fmt.Sprintf("|-\n| %d || [[Apollo %d]] || %d July 1969 || [[Kennedy Space Center|KSC]] || style=\"text-align:right\" | %d.%d", j, j, j%28+1, i, j)
+		})
+		return "{| class=\"wikitable sortable\"\n|+ Table " + fmt.Sprint(i) + "\n! No. !! Mission !! Launch !! Site !! Duration\n" + rows + "\n|}"
+	})
+}
+
+// templateArticle has an infobox and many references with citation
+// templates, like biographies.
+func templateArticle() string {
+	infobox := "{{Infobox person\n| name = Neil Armstrong\n| birth_date = {{birth date|1930|8|5}}\n" +
+		"| birth_place = [[Wapakoneta, Ohio]], U.S.\n| occupation = {{hlist|Astronaut|engineer}}\n}}\n"
+	return infobox + repeat(200, func(i int) string {
+		return fmt.Sprintf("He flew on [[Gemini %d]].<ref name=\"r%d\">{{cite web |url=https://www.nasa.gov/%d "+
+			"|title=Biography %d |publisher=[[NASA]] |date=July %d, 2012 |access-date=2020-01-01}}</ref> "+
+			"{{citation needed|date=May 2020}} {{convert|%d|km|mi}}", i, i, i, i, i%28+1, i)
+	}) + "\n\n== References ==\n{{reflist}}"
+}
+
+// dumpArticles returns the text of the articles of testdata/minidump.xml,
+// read without the dump package, which imports this one.
+func dumpArticles(b *testing.B) string {
+	data, err := os.ReadFile("../testdata/minidump.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var dump struct {
+		Pages []struct {
+			Redirect *struct{} `xml:"redirect"`
+			Text     string    `xml:"revision>text"`
+		} `xml:"page"`
+	}
+	if err := xml.Unmarshal(data, &dump); err != nil {
+		b.Fatal(err)
+	}
+	texts := make([]string, 0, len(dump.Pages))
+	for _, p := range dump.Pages {
+		if p.Redirect == nil {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// corpora returns the articles the benchmarks run on, by name.
+func corpora(b *testing.B) []struct{ name, text string } {
+	return []struct{ name, text string }{
+		{"lead", leadArticle()},
+		{"table", tableArticle()},
+		{"template", templateArticle()},
+		{"dump", dumpArticles(b)},
+	}
+}
+
+// benchmark runs f on the text of every corpus as a sub-benchmark.
+func benchmark(b *testing.B, f func(text string)) {
+	for _, c := range corpora(b) {
+		b.Run(c.name, func(b *testing.B) {
+			b.SetBytes(int64(len(c.text)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f(c.text)
+			}
+		})
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	benchmark(b, func(text string) {
+		for range Lex(text).Items() {
+		}
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmark(b, func(text string) {
+		Parse(text)
+	})
+}
+
+func BenchmarkPlainText(b *testing.B) {
+	benchmark(b, func(text string) {
+		doc, _ := Parse(text)
+		PlainText(doc)
+	})
+}
@@ -0,0 +1,56 @@
+// Formulas and source code embedded in articles
+
+package wikitext
+
+// BlockKind identifies what a Block contains.
+type BlockKind int
+
+const (
+	MathBlock BlockKind = iota // a LaTeX formula in <math>
+	ChemBlock                  // a chemical formula in <chem> or <ce>
+	CodeBlock                  // source code in <syntaxhighlight> or <source>
+)
+
+// Kinds of blocks by element name.
+var blockKinds = map[string]BlockKind{
+	"math":            MathBlock,
+	"chem":            ChemBlock,
+	"ce":              ChemBlock,
+	"syntaxhighlight": CodeBlock,
+	"source":          CodeBlock,
+}
+
+// A Block is an element whose content is not wikitext but a formula or
+// source code, taken as is.
+type Block struct {
+	Kind    BlockKind
+	Tag     string            // the element name, like "syntaxhighlight"
+	Attr    map[string]string // the attributes, like lang="python"
+	Content string            // the raw content
+	Start   int               // byte offset in the document text of the content
+	End     int               // byte offset after the content
+}
+
+// Blocks returns the formulas and code blocks of the document in order.
+// Self-closing elements have no content and are left out.
+func Blocks(doc *Document) []Block {
+	blocks := make([]Block, 0, 4)
+	offsets := itemOffsets(doc.Items)
+	for i, s := range doc.Items {
+		if s.Type != ItemXML {
+			continue
+		}
+		tag, ok := parseTag(s.Val)
+		kind, block := blockKinds[tag.Name]
+		if !ok || !block || tag.Closing || tag.SelfClosing {
+			continue
+		}
+		b := Block{Kind: kind, Tag: tag.Name, Attr: tag.Attr, Start: offsets[i+1], End: offsets[i+1]}
+		if i+1 < len(doc.Items) && doc.Items[i+1].Type == ItemRaw {
+			b.Content = doc.Items[i+1].Val
+			b.End = offsets[i+2]
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
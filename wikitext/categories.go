@@ -0,0 +1,62 @@
+// Extraction of the categories of a document
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// CategoryPrefix is the lower case prefix of category page titles on the
+// English Wikipedia.
+const CategoryPrefix = "category:"
+
+// A CategoryLink is a category assignment [[Category:Name|SortKey]].
+type CategoryLink struct {
+	Name    string
+	SortKey string
+	Start   int // byte offset of the "[[" in the text of its document
+	End     int // byte offset after the "]]"
+}
+
+// Categories returns the categories the document assigns its page to,
+// but those of doc.Strip. Links to categories such as [[:Category:Foo]]
+// are not included.
+func Categories(doc *Document) []CategoryLink {
+	categories := make([]CategoryLink, 0, 10)
+	for i := 0; i < len(doc.Items); i++ {
+		if doc.Items[i].Type != ItemLeftTag {
+			continue
+		}
+		body := make([]string, 0, 10)
+		start := markupStart(doc.Items[i]).Offset
+		nested := false
+		for i++; i < len(doc.Items) && doc.Items[i].Type != ItemRightTag; i++ {
+			if doc.Items[i].Type == ItemLeftTag {
+				nested = true
+			}
+			body = append(body, doc.Items[i].Val)
+		}
+		if nested {
+			continue
+		}
+		if c, ok := parseCategoryBody(doc.siteOf(), strings.Join(body, "")); ok && !doc.Strip.StripsCategory(c.Name) {
+			c.Start, c.End = start, doc.Items[min(i, len(doc.Items)-1)].End.Offset
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+func parseCategoryBody(site *Site, body string) (CategoryLink, bool) {
+	var c CategoryLink
+	prefix, body, ok := strings.Cut(strings.TrimSpace(body), ":")
+	if number, known := site.Namespace(prefix); !ok || !known || number != NamespaceCategory {
+		return c, false
+	}
+	if i := strings.Index(body, "|"); i >= 0 {
+		c.SortKey = strings.TrimSpace(body[i+1:])
+		body = body[:i]
+	}
+	c.Name = strings.TrimSpace(body)
+	return c, c.Name != ""
+}
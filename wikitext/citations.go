@@ -0,0 +1,187 @@
+// Extraction of references (<ref>...</ref>) and parsing of the citation
+// templates they contain.
+
+package wikitext
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Citation is the body of a <ref> tag. If the body contains a citation
+// template such as {{cite web}} or {{cite book}}, its common parameters
+// are available as separate fields.
+type Citation struct {
+	Name     string            // the name attribute of the <ref> tag
+	Body     string            // the wikitext between <ref> and </ref>
+	Template string            // the citation template used, e.g. "cite web"
+	Fields   map[string]string // all parameters of the template
+	Title    string
+	URL      string
+	Author   string
+	Date     string
+	Start    int // byte offset of the <ref> tag in the text of its document
+	End      int // byte offset after the </ref> tag
+}
+
+// An xmlTag is an XML tag as lexed into an ItemXML.
+type xmlTag struct {
+	Name        string
+	Attr        map[string]string
+	Closing     bool // a tag like </ref>
+	SelfClosing bool // a tag like <ref name="a" />
+}
+
+// parseTag parses the tag in the value of an ItemXML. It returns false
+// for comments, processing instructions and the like.
+func parseTag(val string) (xmlTag, bool) {
+	var tag xmlTag
+	// Line breaks before the tag are lexed into its value.
+	val = strings.TrimLeftFunc(val, unicode.IsSpace)
+	decoder := xml.NewDecoder(strings.NewReader(val))
+	decoder.Strict = false
+	t, err := decoder.RawToken()
+	if err != nil {
+		return tag, false
+	}
+	switch t := t.(type) {
+	case xml.StartElement:
+		tag.Name = strings.ToLower(t.Name.Local)
+		tag.Attr = make(map[string]string)
+		for _, a := range t.Attr {
+			tag.Attr[strings.ToLower(a.Name.Local)] = a.Value
+		}
+		tag.SelfClosing = strings.HasSuffix(val, "/>")
+		return tag, true
+	case xml.EndElement:
+		tag.Name = strings.ToLower(t.Name.Local)
+		tag.Closing = true
+		return tag, true
+	}
+	return tag, false
+}
+
+// splitPipes returns the wikitext of the items between the separators
+// "|" that are not nested in templates or links.
+func splitPipes(items []Item) []string {
+	parts := make([]string, 0, 10)
+	for _, part := range splitItems(items) {
+		parts = append(parts, itemText(part))
+	}
+	return parts
+}
+
+// splitTemplate splits the items of a template, without the enclosing
+// "{{" and "}}", into its name and parameters. Positional parameters are
+// numbered from "1" like in MediaWiki. Separators inside nested
+// templates and links are not split on.
+func splitTemplate(items []Item) (string, map[string]string) {
+	parts := splitPipes(items)
+	params := make(map[string]string)
+	position := 1
+	for _, part := range parts[1:] {
+		if i := strings.Index(part, "="); i >= 0 {
+			params[strings.TrimSpace(part[:i])] = strings.TrimSpace(part[i+1:])
+		} else {
+			params[strconv.Itoa(position)] = strings.TrimSpace(part)
+			position++
+		}
+	}
+	return strings.TrimSpace(parts[0]), params
+}
+
+// isCitationTemplate reports whether the template is one of the citation
+// templates, like "cite web", "Cite book" or "citation".
+func isCitationTemplate(name string) bool {
+	name = strings.ToLower(strings.Replace(name, "_", " ", -1))
+	return strings.HasPrefix(name, "cite ") || name == "citation"
+}
+
+// firstField returns the first non-empty parameter of the given names.
+func firstField(fields map[string]string, names ...string) string {
+	for _, n := range names {
+		if v := fields[n]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// citationAuthor returns the author of a citation, joining first and
+// last names given separately.
+func citationAuthor(fields map[string]string) string {
+	if a := firstField(fields, "author", "author1", "authors"); a != "" {
+		return a
+	}
+	last := firstField(fields, "last", "last1")
+	first := firstField(fields, "first", "first1")
+	if last != "" && first != "" {
+		return last + ", " + first
+	}
+	return last
+}
+
+// parseCitation fills in the template fields of c from the items of the
+// reference body.
+func parseCitation(c *Citation, items []Item) {
+	for i := 0; i < len(items); i++ {
+		if items[i].Type != ItemLeftMeta {
+			continue
+		}
+		depth := 1
+		j := i + 1
+		for ; j < len(items) && depth > 0; j++ {
+			if items[j].Type == ItemLeftMeta {
+				depth++
+			} else if items[j].Type == ItemRightMeta {
+				depth--
+			}
+		}
+		if depth > 0 {
+			return
+		}
+		name, fields := splitTemplate(items[i+1 : j-1])
+		if isCitationTemplate(name) {
+			c.Template = strings.ToLower(name)
+			c.Fields = fields
+			c.Title = fields["title"]
+			c.URL = fields["url"]
+			c.Author = citationAuthor(fields)
+			c.Date = firstField(fields, "date", "year")
+			return
+		}
+		i = j - 1
+	}
+}
+
+// Citations returns the references of the document in order. References
+// reusing a named reference, like <ref name="a" />, are not repeated.
+func Citations(doc *Document) []Citation {
+	citations := make([]Citation, 0, 10)
+	for i := 0; i < len(doc.Items); i++ {
+		if doc.Items[i].Type != ItemXML {
+			continue
+		}
+		tag, ok := parseTag(doc.Items[i].Val)
+		if !ok || tag.Name != "ref" || tag.Closing || tag.SelfClosing {
+			continue
+		}
+		j := i + 1
+		for ; j < len(doc.Items); j++ {
+			if doc.Items[j].Type == ItemXML {
+				if end, ok := parseTag(doc.Items[j].Val); ok && end.Name == "ref" && end.Closing {
+					break
+				}
+			}
+		}
+		body := doc.Items[i+1 : j]
+		c := Citation{Name: tag.Attr["name"], Body: itemText(body)}
+		c.Start, c.End = markupStart(doc.Items[i]).Offset, doc.Items[min(j, len(doc.Items)-1)].End.Offset
+		parseCitation(&c, body)
+		citations = append(citations, c)
+		i = j
+	}
+	return citations
+}
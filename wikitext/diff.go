@@ -0,0 +1,233 @@
+// Differences between two revisions of an article, by words and by the
+// links, templates and categories added and removed
+
+package wikitext
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pcmoritz/wikipedia/internal/diff"
+)
+
+// A ChangeKind tells what part of an article a Change is about.
+type ChangeKind int
+
+const (
+	ChangeText         ChangeKind = iota // a run of words of the plain text
+	ChangeLink                           // a wiki link, by target
+	ChangeTemplate                       // a template, by name
+	ChangeCategory                       // a category assignment
+	ChangeExternalLink                   // an external link, by URL
+	ChangeImage                          // an embedded file
+)
+
+var changeKindNames = map[ChangeKind]string{
+	ChangeText:         "text",
+	ChangeLink:         "link",
+	ChangeTemplate:     "template",
+	ChangeCategory:     "category",
+	ChangeExternalLink: "externallink",
+	ChangeImage:        "image",
+}
+
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// A ChangeOp tells how a part changed.
+type ChangeOp int
+
+const (
+	Added    ChangeOp = iota
+	Removed           // in the old revision only
+	Modified          // a template of the same name with other parameters
+)
+
+var changeOpNames = map[ChangeOp]string{
+	Added:    "added",
+	Removed:  "removed",
+	Modified: "modified",
+}
+
+func (o ChangeOp) String() string {
+	if name, ok := changeOpNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChangeOp(%d)", int(o))
+}
+
+// A Change is a part of an article added, removed or modified between
+// two revisions.
+type Change struct {
+	Kind  ChangeKind
+	Op    ChangeOp
+	Value string // the words, or the link target, template name, category, URL or file name
+}
+
+// A RevisionDiff holds the changes between two revisions of an article:
+// the runs of words changed in the plain text, in order, followed by the
+// links, templates, categories, external links and images added and
+// removed, in the order of the revision they are in.
+type RevisionDiff struct {
+	WordsAdded   int
+	WordsRemoved int
+	Changes      []Change
+}
+
+// Diff compares two revisions of an article. Words are compared in the
+// plain text, so that markup does not count as words; the links and
+// other parts are compared as multisets, so that moving them is no
+// change. Templates that kept their name but not their parameters are
+// Modified.
+func Diff(old *Document, new *Document) RevisionDiff {
+	var d RevisionDiff
+	d.diffWords(strings.Fields(PlainText(old)), strings.Fields(PlainText(new)))
+
+	linkKeys := func(doc *Document) []string {
+		keys := make([]string, 0, 10)
+		for _, l := range Links(doc) {
+			target := l.Target
+			if l.Interwiki != "" {
+				target = l.Interwiki + ":" + target
+			}
+			keys = append(keys, target)
+		}
+		return keys
+	}
+	d.diffSets(ChangeLink, linkKeys(old), linkKeys(new))
+	d.diffTemplates(old, new)
+	categories := func(doc *Document) []string {
+		names := make([]string, 0, 4)
+		for _, c := range Categories(doc) {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+	d.diffSets(ChangeCategory, categories(old), categories(new))
+	urls := func(doc *Document) []string {
+		urls := make([]string, 0, 4)
+		for _, l := range ExternalLinks(doc) {
+			urls = append(urls, l.URL)
+		}
+		return urls
+	}
+	d.diffSets(ChangeExternalLink, urls(old), urls(new))
+	files := func(doc *Document) []string {
+		files := make([]string, 0, 4)
+		for _, m := range Images(doc) {
+			files = append(files, m.File)
+		}
+		return files
+	}
+	d.diffSets(ChangeImage, files(old), files(new))
+	return d
+}
+
+// diffWords adds the runs of words removed and added between the word
+// sequences.
+func (d *RevisionDiff) diffWords(old []string, new []string) {
+	edits := diff.Lines(old, new)
+	for i := 0; i < len(edits); {
+		if edits[i].Op == diff.Equal {
+			i++
+			continue
+		}
+		// A run of changes: its removed words, then its added ones.
+		var removed, added []string
+		for ; i < len(edits) && edits[i].Op != diff.Equal; i++ {
+			if edits[i].Op == diff.Delete {
+				removed = append(removed, edits[i].Line)
+			} else {
+				added = append(added, edits[i].Line)
+			}
+		}
+		if len(removed) > 0 {
+			d.Changes = append(d.Changes, Change{ChangeText, Removed, strings.Join(removed, " ")})
+		}
+		if len(added) > 0 {
+			d.Changes = append(d.Changes, Change{ChangeText, Added, strings.Join(added, " ")})
+		}
+		d.WordsRemoved += len(removed)
+		d.WordsAdded += len(added)
+	}
+}
+
+// diffSets adds the values of the kind that are more often in new than in
+// old as Added, and the others as Removed.
+func (d *RevisionDiff) diffSets(kind ChangeKind, old []string, new []string) {
+	count := make(map[string]int)
+	for _, v := range old {
+		count[v]++
+	}
+	for _, v := range new {
+		if count[v] > 0 {
+			count[v]--
+		} else {
+			d.Changes = append(d.Changes, Change{kind, Added, v})
+		}
+	}
+	for _, v := range old {
+		if count[v] > 0 {
+			count[v]--
+			d.Changes = append(d.Changes, Change{kind, Removed, v})
+		}
+	}
+}
+
+// diffTemplates adds the templates added and removed, by name, and those
+// whose wikitext changed while their name stayed.
+func (d *RevisionDiff) diffTemplates(old *Document, new *Document) {
+	type template struct{ name, text string }
+	templates := func(doc *Document) []template {
+		list := make([]template, 0, 4)
+		for _, t := range Templates(doc, 1) {
+			name := strings.Join(strings.FieldsFunc(t.Name, func(r rune) bool { return r == '_' || r == ' ' }), " ")
+			list = append(list, template{name, doc.Text[t.Start:t.End]})
+		}
+		return list
+	}
+	before, after := templates(old), templates(new)
+	// Unchanged templates cancel out, leaving those of before in the
+	// counts; of the others, a name in both revisions is a modification.
+	unchanged := make(map[template]int)
+	for _, t := range before {
+		unchanged[t]++
+	}
+	var added []string
+	for _, t := range after {
+		if unchanged[t] > 0 {
+			unchanged[t]--
+		} else {
+			added = append(added, t.name)
+		}
+	}
+	var removed []string
+	for _, t := range before {
+		if unchanged[t] > 0 {
+			unchanged[t]--
+			removed = append(removed, t.name)
+		}
+	}
+	left := make(map[string]int)
+	for _, name := range removed {
+		left[name]++
+	}
+	for _, name := range added {
+		if left[name] > 0 {
+			left[name]--
+			d.Changes = append(d.Changes, Change{ChangeTemplate, Modified, name})
+		} else {
+			d.Changes = append(d.Changes, Change{ChangeTemplate, Added, name})
+		}
+	}
+	for _, name := range removed {
+		if left[name] > 0 {
+			left[name]--
+			d.Changes = append(d.Changes, Change{ChangeTemplate, Removed, name})
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package wikitext lexes the wikitext of Wikipedia articles and extracts
+// their structure: sections, links, categories and citations.
+//
+// Parse lexes an article into a Document, which the extraction functions
+// take as input:
+//
+//	doc, err := wikitext.Parse(text)
+//	for _, s := range wikitext.Sections(doc) {
+//		fmt.Println(s.Level, s.Heading)
+//	}
+//
+// Syntax errors do not prevent parsing; the problems found are in the
+// Errors of the document, as *SyntaxError values with their positions,
+// and err joins them.
+//
+// Items carry their Start and End positions in the wikitext, with line
+// and column; the extracted links, templates, sections and the like
+// carry the byte offsets of their wikitext, which Document.Position
+// turns into lines and columns.
+//
+// Rewrite changes the wikitext of the templates, links and other nodes of
+// a document and returns the text with the changes, keeping the rest of
+// it byte for byte.
+//
+// ParseEvents reads wikitext from an io.Reader and passes on its text,
+// templates, links and headings as events, for pages too large to hold as
+// a Document.
+//
+// HTML renders the headings, paragraphs, lists, formatting and links of a
+// document as HTML, leaving out what PlainText leaves out.
+//
+// LanguageLinks returns the interlanguage links like [[de:Mond]] of a
+// document, which are not part of its text.
+//
+// TOC returns the table of contents of a document, numbered and with the
+// anchors of the headings, as MediaWiki shows it.
+//
+// MagicWords returns the behavior switches like __NOTOC__, which are
+// ItemSwitch items, and the magic words like {{DEFAULTSORT:...}}.
+//
+// Sentences splits the plain text of a document into sentences, with the
+// section and paragraph each is in.
+//
+// Assess returns the features telling substantive articles from stubs:
+// stub templates, infoboxes and the counts of references and sections.
+//
+// The names of namespaces are those of the English Wikipedia unless the
+// Site of another wiki is given, as in
+//
+//	doc, err := wikitext.Parse(text, wikitext.WithSite(site))
+package wikitext
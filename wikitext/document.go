@@ -0,0 +1,251 @@
+// Documents: the lexed wikitext of an article, as input for the
+// extraction APIs like Citations.
+
+package wikitext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Document holds the wikitext of an article together with the items
+// the lexer produced for it.
+type Document struct {
+	Text  string
+	Items []Item
+
+	// KeepEntities makes PlainText and Abstract keep character
+	// references like "&nbsp;" as they are instead of decoding them.
+	KeepEntities bool
+
+	// Limit bounds the length of the text PlainText and Abstract return,
+	// which is not bounded if zero.
+	Limit TextLimit
+
+	// Strip leaves the hidden categories of the list out of Categories,
+	// if not nil.
+	Strip *StripList
+
+	// Errors are the problems Parse found in the wikitext, in the order
+	// of their positions. None of them keeps the extraction APIs from
+	// working on the document.
+	Errors []*SyntaxError
+
+	site *Site // the site of the article, for the names of namespaces
+}
+
+// A ParseOption changes how Parse builds a Document.
+type ParseOption func(*parseConfig)
+
+type parseConfig struct {
+	dropComments bool
+	site         *Site
+	ctx          context.Context
+	tok          Tokenizer
+	items        []Item
+}
+
+// DropComments makes Parse leave out the comments "<!-- ... -->" of the
+// wikitext: the Document has no ItemComment and its Text is the wikitext
+// without them.
+func DropComments() ParseOption {
+	return func(c *parseConfig) {
+		c.dropComments = true
+	}
+}
+
+// WithSite makes the extraction APIs like Links and Categories use the
+// names of namespaces and image options of the site. Without it, those
+// of the English Wikipedia are used.
+func WithSite(site *Site) ParseOption {
+	return func(c *parseConfig) {
+		c.site = site
+	}
+}
+
+// WithContext makes Parse stop lexing when ctx is cancelled. The
+// document then holds the items lexed so far, and Parse returns the
+// error of the context instead of the syntax errors.
+func WithContext(ctx context.Context) ParseOption {
+	return func(c *parseConfig) {
+		c.ctx = ctx
+	}
+}
+
+// WithTokenizer makes Parse split the text into words and numbers as the
+// tokenizer does, rather than by the rules of the zero Tokenizer.
+func WithTokenizer(tok Tokenizer) ParseOption {
+	return func(c *parseConfig) {
+		c.tok = tok
+	}
+}
+
+// WithItems makes Parse take the items, lexed from the same wikitext
+// before, like those kept in a store of parsed pages, instead of lexing
+// it again. The items are used as they are.
+func WithItems(items []Item) ParseOption {
+	return func(c *parseConfig) {
+		c.items = items
+	}
+}
+
+// Parse lexes the wikitext of an article into a Document. Problems with
+// the wikitext are collected into the Errors of the document and also
+// returned joined into one error, which can be inspected with errors.Is
+// and errors.As; the document is usable regardless.
+func Parse(text string, options ...ParseOption) (*Document, error) {
+	config := parseConfig{site: english, ctx: context.Background()}
+	for _, o := range options {
+		o(&config)
+	}
+	doc := &Document{Text: text, site: config.site}
+	// Prose and templates have an item in about three bytes, tables in
+	// two (measured by BenchmarkParse); growing the items would cost more
+	// than the spare capacity of one in two and a half.
+	if config.items != nil {
+		doc.Items = config.items
+	} else {
+		doc.Items = lexAll(config.ctx, text, make([]Item, 0, len(text)*2/5+1), config.tok)
+	}
+	// Items leaves out the ItemEOF.
+	if n := len(doc.Items); n > 0 && doc.Items[n-1].Type == ItemEOF {
+		doc.Items = doc.Items[:n-1]
+	}
+	dropped := false
+	if config.dropComments {
+		kept := doc.Items[:0]
+		for _, s := range doc.Items {
+			if s.Type == ItemComment {
+				dropped = true
+				continue
+			}
+			kept = append(kept, s)
+		}
+		doc.Items = kept
+	}
+	if dropped {
+		doc.Text = itemText(doc.Items)
+		setPositions(doc.Items)
+	}
+	if err := config.ctx.Err(); err != nil {
+		return doc, err
+	}
+	doc.Errors = checkItems(doc.Items)
+	if len(doc.Errors) == 0 {
+		return doc, nil
+	}
+	errs := make([]error, len(doc.Errors))
+	for i, e := range doc.Errors {
+		errs[i] = e
+	}
+	return doc, errors.Join(errs...)
+}
+
+// siteOf returns the site of the document, that of the English
+// Wikipedia for documents not made by Parse.
+func (doc *Document) siteOf() *Site {
+	if doc.site == nil {
+		return english
+	}
+	return doc.site
+}
+
+// itemOffsets returns the byte offsets at which the items start in the
+// wikitext, followed by the length of the wikitext. The items may be a
+// part of those of another document, whose positions they keep.
+func itemOffsets(items []Item) []int {
+	offsets := make([]int, len(items)+1)
+	if len(items) == 0 {
+		return offsets
+	}
+	base := items[0].Start.Offset
+	for i, s := range items {
+		offsets[i] = s.Start.Offset - base
+	}
+	offsets[len(items)] = items[len(items)-1].End.Offset - base
+	return offsets
+}
+
+// itemsIn returns the items of the document lying within the byte span
+// [start, end) of the wikitext.
+func itemsIn(doc *Document, start int, end int) []Item {
+	if len(doc.Items) == 0 {
+		return doc.Items
+	}
+	base := doc.Items[0].Start.Offset
+	i := sort.Search(len(doc.Items), func(i int) bool { return doc.Items[i].Start.Offset-base >= start })
+	j := sort.Search(len(doc.Items), func(j int) bool { return doc.Items[j].End.Offset-base > end })
+	return doc.Items[i:max(i, j)]
+}
+
+// checkItems reports malformed tags, unbalanced templates and links,
+// templates nested too deeply and unclosed comments, by position.
+func checkItems(items []Item) []*SyntaxError {
+	errs := make([]*SyntaxError, 0)
+	open := make([]Pos, 0, 10)  // the unclosed "{{"
+	links := make([]Pos, 0, 10) // the unclosed "[["
+	exceeded := false
+	for _, s := range items {
+		var at Pos
+		switch s.Type {
+		case ItemMark, ItemLeftMeta, ItemRightMeta, ItemLeftTag, ItemRightTag, ItemComment:
+			at = markupStart(s)
+		}
+		switch {
+		case s.Type == ItemError:
+			var e *SyntaxError
+			if errors.As(s.Err, &e) {
+				errs = append(errs, e)
+			}
+		case s.Type == ItemMark && s.Val == "<":
+			// lexXML emits a '<' that does not start a tag as a mark.
+			errs = append(errs, &SyntaxError{ErrBadXML, at, "'<' does not start a tag"})
+		case s.Type == ItemLeftMeta:
+			open = append(open, at)
+			if len(open) > MaxNestingDepth && !exceeded {
+				exceeded = true
+				errs = append(errs, &SyntaxError{ErrDepthExceeded, at, fmt.Sprintf("templates nested more than %d levels", MaxNestingDepth)})
+			}
+		case s.Type == ItemRightMeta:
+			if len(open) == 0 {
+				errs = append(errs, &SyntaxError{ErrMalformedTemplate, at, "unexpected \"}}\""})
+			} else {
+				open = open[:len(open)-1]
+			}
+		case s.Type == ItemLeftTag:
+			links = append(links, at)
+		case s.Type == ItemRightTag:
+			if len(links) == 0 {
+				errs = append(errs, &SyntaxError{ErrMalformedLink, at, "unexpected \"]]\""})
+			} else {
+				links = links[:len(links)-1]
+			}
+		case s.Type == ItemComment && !strings.HasSuffix(s.Val, "-->"):
+			errs = append(errs, &SyntaxError{ErrUnclosedComment, at, "no \"-->\" until the end"})
+		}
+	}
+	for _, o := range open {
+		errs = append(errs, &SyntaxError{ErrMalformedTemplate, o, "unclosed \"{{\""})
+	}
+	for _, o := range links {
+		errs = append(errs, &SyntaxError{ErrMalformedLink, o, "unclosed \"[[\""})
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Offset < errs[j].Offset })
+	return errs
+}
+
+// itemText returns the wikitext the items were lexed from.
+func itemText(items []Item) string {
+	n := 0
+	for _, s := range items {
+		n += len(s.Val)
+	}
+	text := make([]byte, 0, n)
+	for _, s := range items {
+		text = append(text, s.Val...)
+	}
+	return string(text)
+}
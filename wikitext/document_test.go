@@ -0,0 +1,100 @@
+package wikitext
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantErr   error // the kind of the syntax error, if any
+		links     []string
+		templates []string
+	}{
+		{"links", "[[Moon|lunar]] and [[Mars#Orbit]] [[de:Mond]] [[Category:Moons]]", nil, []string{"Moon", "Mars", "Mond"}, nil},
+		{"templates", "{{Infobox|name=[[Armstrong]]|2}} text {{cite web|url=x}}", nil, []string{"Armstrong"}, []string{"Infobox", "cite web"}},
+		{"less than", "1 < 2 and [[Moon]]", ErrBadXML, []string{"Moon"}, nil},
+		{"unclosed tag", "a <b c [[Moon]]", ErrBadXML, []string{"Moon"}, nil},
+		{"unclosed link", "[[Moon and [[Mars]]", ErrMalformedLink, []string{"Mars"}, nil},
+		{"unclosed template", "[[Moon]] {{cite|a=b", ErrMalformedTemplate, []string{"Moon"}, nil},
+		{"unclosed comment", "[[Moon]] <!-- open", ErrUnclosedComment, []string{"Moon"}, nil},
+		{"nowiki", "<nowiki>[[Moon]]</nowiki> {{x}}", nil, nil, []string{"x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.text)
+			if doc == nil {
+				t.Fatalf("Parse(%q) returned no document", tt.text)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Parse(%q) = %v, want no error", tt.text, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.text, err, tt.wantErr)
+			}
+			var links []string
+			for _, l := range Links(doc) {
+				links = append(links, l.Target)
+			}
+			if !slices.Equal(links, tt.links) {
+				t.Errorf("Links(%q) = %q, want %q", tt.text, links, tt.links)
+			}
+			var templates []string
+			for _, tmpl := range Templates(doc, 1) {
+				templates = append(templates, tmpl.Name)
+			}
+			if !slices.Equal(templates, tt.templates) {
+				t.Errorf("Templates(%q) = %q, want %q", tt.text, templates, tt.templates)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		text string
+		want Link
+	}{
+		{"[[Moon]]", Link{Target: "Moon", Anchor: "Moon", Class: LinkArticle}},
+		{"[[fr:Lune]]", Link{Target: "Lune", Interwiki: "fr", Anchor: "fr:Lune", Class: LinkInterwiki}},
+		{"[[:wikt:moon|moon]]", Link{Target: "moon", Interwiki: "wikt", Anchor: "moon", Class: LinkInterwiki}},
+		{"[[Talk:Moon]]", Link{Target: "Talk:Moon", Anchor: "Talk:Moon", Class: LinkNamespace}},
+		// Short prefixes that are no language code are part of the title.
+		{"[[CSI: Miami]]", Link{Target: "CSI: Miami", Anchor: "CSI: Miami", Class: LinkArticle}},
+		{"[[Dad: A Novel|x]]", Link{Target: "Dad: A Novel", Anchor: "x", Class: LinkArticle}},
+	}
+	for _, tt := range tests {
+		doc, _ := Parse(tt.text)
+		links := Links(doc)
+		if len(links) != 1 {
+			t.Fatalf("Links(%q) = %+v, want one link", tt.text, links)
+		}
+		tt.want.End = len(tt.text)
+		if links[0] != tt.want {
+			t.Errorf("Links(%q) = %+v, want %+v", tt.text, links[0], tt.want)
+		}
+	}
+}
+
+func TestLanguageLinks(t *testing.T) {
+	tests := []struct {
+		text string
+		want []LanguageLink
+	}{
+		{"[[de:Mond]] [[zh-yue:月球]] [[simple:Moon]]", []LanguageLink{
+			{Language: "de", Title: "Mond", Start: 0, End: 11},
+			{Language: "zh-yue", Title: "月球", Start: 12, End: 29},
+			{Language: "simple", Title: "Moon", Start: 30, End: 45}}},
+		// Titles with a short prefix that is no language code.
+		{"[[CSI: Miami]] [[Dad: A Novel|x]] [[wikt:moon]]", nil},
+	}
+	for _, tt := range tests {
+		doc, _ := Parse(tt.text)
+		if got := LanguageLinks(doc); !slices.Equal(got, tt.want) {
+			t.Errorf("LanguageLinks(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Kinds of errors found in wikitext, to be tested with errors.Is, and
+// the SyntaxError type carrying them, to be extracted with errors.As.
+
+package wikitext
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadXML            = errors.New("malformed XML tag")
+	ErrBadNumber         = errors.New("bad number syntax")
+	ErrMalformedTemplate = errors.New("malformed template")
+	ErrMalformedLink     = errors.New("malformed link")
+	ErrUnclosedComment   = errors.New("unclosed comment")
+	ErrDepthExceeded     = errors.New("nesting depth exceeded")
+)
+
+// MaxNestingDepth is the depth of nested templates beyond which
+// ErrDepthExceeded is reported.
+const MaxNestingDepth = 100
+
+// A SyntaxError describes a problem in the wikitext of a document.
+type SyntaxError struct {
+	Kind error  // ErrBadXML, ErrMalformedTemplate, ...
+	Pos         // where the problem is in the wikitext
+	Msg  string // details, may be empty
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("%v: %v", e.Pos, e.Kind)
+	}
+	return fmt.Sprintf("%v: %v: %s", e.Pos, e.Kind, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Kind
+}
@@ -0,0 +1,385 @@
+// Parsing wikitext into a stream of events, for consumers that cannot
+// hold the items of a whole page
+
+package wikitext
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"unicode"
+)
+
+// An EventKind tells what an Event is about.
+type EventKind int
+
+const (
+	EventText          EventKind = iota // a run of text with its spaces and line breaks
+	EventStartTemplate                  // the "{{" and the name of a template
+	EventEndTemplate                    // the "}}" of the template started last
+	EventLink                           // a wiki link
+	EventHeading                        // the heading of a section
+	EventError                          // reading the input failed or was cancelled
+)
+
+var eventKindNames = map[EventKind]string{
+	EventText:          "text",
+	EventStartTemplate: "starttemplate",
+	EventEndTemplate:   "endtemplate",
+	EventLink:          "link",
+	EventHeading:       "heading",
+	EventError:         "error",
+}
+
+func (k EventKind) String() string {
+	if name, ok := eventKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// An Event is a part of wikitext passed on by ParseEvents.
+type Event struct {
+	Kind  EventKind
+	Text  string // the text, the name of the template or the heading
+	Level int    // the level of a heading
+	Link  Link   // the link of an EventLink, with the byte offsets of the input
+	Err   error  // the error of an EventError
+	Pos   Pos    // the position of the text or the markup in the input
+}
+
+// maxEventBuffer bounds the wikitext of a link, a heading or the name of
+// a template that ParseEvents holds before passing it on, and the text of
+// an EventText.
+const maxEventBuffer = 64 << 10
+
+// A pendingItem is an item that ParseEvents read ahead and parses again.
+type pendingItem struct {
+	Item
+	unclosed bool // a "[[" known to have no "]]", or a "{{" of a heading without "}}"
+	quiet    bool // in a link or heading passed on, for the templates and links in it only
+
+	linkEnd bool   // not an item but the end of a link, after the items in it
+	links   []Link // the links ending there
+	pos     []Pos  // the positions of the links
+}
+
+// An eventParser turns items into events.
+type eventParser struct {
+	lexer   *Lexer
+	site    *Site
+	yield   func(Event) bool
+	stopped bool
+
+	pending   []pendingItem // the items to parse before those of the lexer
+	text      []string      // the text not yet passed on
+	textLen   int
+	textPos   Pos
+	templates []Pos // the positions of the templates not yet closed
+}
+
+// ParseEvents reads wikitext from r and returns an iterator over its
+// events, without building a Document: the text, the start and end of
+// templates, with the text and events of their parameters in between, the
+// links, in the order of their end as by Links, and the headings at the
+// start of lines, as by Sections. Markup like tags, bold and italic is left out of the text but
+// for its line breaks; the anchor text of links is in their event only.
+// The site and context of the options apply. Only links, headings and
+// template names of up to 64 KiB are held in memory; longer ones are
+// passed on as text. Templates that are not closed end with the wikitext.
+// An EventError is the last event if reading fails or ctx is cancelled:
+//
+//	for ev := range wikitext.ParseEvents(r) {
+//		if ev.Kind == wikitext.EventLink { ... }
+//	}
+func ParseEvents(r io.Reader, options ...ParseOption) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		config := parseConfig{site: english, ctx: context.Background()}
+		for _, o := range options {
+			o(&config)
+		}
+		p := &eventParser{lexer: config.tok.LexReaderContext(config.ctx, r), site: config.site, yield: yield}
+		defer p.lexer.Stop()
+		p.parse()
+		if p.stopped {
+			return
+		}
+		p.flushText()
+		for i := len(p.templates) - 1; i >= 0 && !p.stopped; i-- {
+			p.emit(Event{Kind: EventEndTemplate, Pos: p.templates[i]})
+		}
+		if err := p.lexer.Err(); err != nil && !p.stopped {
+			p.emit(Event{Kind: EventError, Err: err})
+		} else if err := config.ctx.Err(); err != nil && !p.stopped {
+			p.emit(Event{Kind: EventError, Err: err})
+		}
+	}
+}
+
+// next returns the next item to parse, or false at the end of the input.
+func (p *eventParser) next() (pendingItem, bool) {
+	if len(p.pending) > 0 {
+		s := p.pending[0]
+		p.pending = p.pending[1:]
+		return s, true
+	}
+	s := p.lexer.NextItem()
+	return pendingItem{Item: s}, s.Type != ItemEOF
+}
+
+// unread makes the items the next ones to parse.
+func (p *eventParser) unread(items []pendingItem) {
+	p.pending = append(items, p.pending...)
+}
+
+// emit passes an event on, after the text before it.
+func (p *eventParser) emit(ev Event) {
+	if ev.Kind != EventText {
+		p.flushText()
+	}
+	if !p.stopped && !p.yield(ev) {
+		p.stopped = true
+	}
+}
+
+// addText adds text at pos to the text to pass on.
+func (p *eventParser) addText(text string, pos Pos) {
+	if text == "" {
+		return
+	}
+	if len(p.text) == 0 {
+		p.textPos = pos
+	}
+	p.text = append(p.text, text)
+	p.textLen += len(text)
+	if p.textLen >= maxEventBuffer {
+		p.flushText()
+	}
+}
+
+// flushText passes the text on.
+func (p *eventParser) flushText() {
+	if len(p.text) == 0 {
+		return
+	}
+	text := strings.Join(p.text, "")
+	p.text, p.textLen = p.text[:0], 0
+	p.emit(Event{Kind: EventText, Text: text, Pos: p.textPos})
+}
+
+// addSpace adds the line breaks and spaces the value of an item starts
+// with, those before its markup.
+func (p *eventParser) addSpace(s Item) {
+	p.addText(s.Val[:len(s.Val)-len(strings.TrimLeftFunc(s.Val, unicode.IsSpace))], s.Start)
+}
+
+// parse parses the items until the end of the input or until the events
+// are no longer wanted.
+func (p *eventParser) parse() {
+	for s, ok := p.next(); ok && !p.stopped; s, ok = p.next() {
+		if s.linkEnd {
+			for i, l := range s.links {
+				p.emit(Event{Kind: EventLink, Link: l, Text: l.Anchor, Pos: s.pos[i]})
+			}
+			continue
+		}
+		if s.quiet && s.Type != ItemLeftMeta && s.Type != ItemRightMeta && s.Type != ItemLeftTag {
+			continue
+		}
+		switch s.Type {
+		case ItemLeftMeta:
+			if !s.unclosed {
+				p.template(s.Item)
+			}
+		case ItemRightMeta:
+			if n := len(p.templates); n > 0 {
+				p.addSpace(s.Item)
+				p.templates = p.templates[:n-1]
+				p.emit(Event{Kind: EventEndTemplate, Pos: markupStart(s.Item)})
+			}
+		case ItemLeftTag:
+			if s.unclosed && !s.quiet {
+				p.addText(s.Val, s.Start)
+			} else if !s.unclosed {
+				p.link(s)
+			}
+		case ItemTitle:
+			if len(p.templates) > 0 || markupStart(s.Item).Column != 1 {
+				p.addText(s.Val, s.Start)
+			} else {
+				p.heading(s.Item)
+			}
+		case ItemComment, ItemSwitch, ItemXML, ItemQuote, ItemList, ItemRightTag:
+			p.addSpace(s.Item)
+		case ItemError:
+		default:
+			p.addText(s.Val, s.Start)
+		}
+	}
+}
+
+// template passes on the start of the template whose "{{" is s, with its
+// name, which ends at the first "|" or "}}", or before a template or link
+// nested in it, as in {{#if:{{{1|}}}|...}}, and its end if that is the
+// "}}".
+func (p *eventParser) template(s Item) {
+	p.addSpace(s)
+	pos := markupStart(s)
+	name := make([]string, 0, 4)
+	start := func() {
+		p.emit(Event{Kind: EventStartTemplate, Text: strings.TrimSpace(strings.Join(name, "")), Pos: pos})
+	}
+	for size := 0; size < maxEventBuffer; {
+		t, ok := p.next()
+		if !ok || isMark([]Item{t.Item}, 0, "|") {
+			break
+		}
+		if t.linkEnd || t.Type == ItemLeftMeta || t.Type == ItemLeftTag {
+			p.unread([]pendingItem{t})
+			break
+		}
+		if t.Type == ItemRightMeta {
+			start()
+			p.emit(Event{Kind: EventEndTemplate, Pos: markupStart(t.Item)})
+			return
+		}
+		if t.Type != ItemComment && t.Type != ItemSwitch && t.Type != ItemError {
+			name = append(name, t.Val)
+			size += len(t.Val)
+		}
+	}
+	p.templates = append(p.templates, pos)
+	start()
+}
+
+// link passes on the link whose "[[" is s and the links nested in it,
+// after the templates in them. If there is no "]]" closing it, the "[["
+// is text and the items after it are parsed again, their "[[" that are not
+// closed either as text.
+func (p *eventParser) link(s pendingItem) {
+	items := []pendingItem{s}
+	size, depth := len(s.Val), 1
+	for depth > 0 && size < maxEventBuffer {
+		t, ok := p.next()
+		if !ok {
+			break
+		}
+		if t.unclosed || t.linkEnd {
+			// No "]]" closes the "[[" an unclosed one is in.
+			p.unread([]pendingItem{t})
+			break
+		}
+		items = append(items, t)
+		size += len(t.Val)
+		switch t.Type {
+		case ItemLeftTag:
+			depth++
+		case ItemRightTag:
+			depth--
+		}
+	}
+	link := make([]Item, len(items))
+	for i, t := range items {
+		link[i] = t.Item
+	}
+	if depth > 0 {
+		if !s.quiet {
+			p.addText(s.Val, s.Start)
+		}
+		closing := closingTags(link)
+		for i := range items {
+			items[i].unclosed = items[i].Type == ItemLeftTag && closing[i] < 0
+		}
+		p.unread(items[1:])
+		return
+	}
+	if !s.quiet {
+		p.addSpace(s.Item)
+	}
+	end := pendingItem{linkEnd: true}
+	end.links, _, _ = appendLink(p.site, link, 1, nil, make([]string, 0, 10))
+	for _, l := range end.links {
+		for _, t := range link {
+			if t.Type == ItemLeftTag && markupStart(t).Offset == l.Start {
+				end.pos = append(end.pos, markupStart(t))
+				break
+			}
+		}
+	}
+	// The text of the links is in their events, and the links nested in
+	// it are passed on already.
+	body := make([]pendingItem, 0, len(items))
+	for _, t := range items[1 : len(items)-1] {
+		if t.Type != ItemLeftTag && t.Type != ItemRightTag {
+			t.quiet = true
+			body = append(body, t)
+		}
+	}
+	p.unread(append(body, end))
+}
+
+// heading passes on the heading whose opening "=" start a line, which ends
+// at a run of '=' ending the line, or is a line of only '=', as by
+// Sections. Otherwise s is text and the items after it are parsed again.
+func (p *eventParser) heading(s Item) {
+	items := make([]pendingItem, 0, 10)
+	size := 0
+	for size < maxEventBuffer {
+		t, ok := p.next()
+		if !ok {
+			break
+		}
+		items = append(items, t)
+		size += len(t.Val)
+		if t.Type == ItemTitle || strings.Contains(t.Val, "\n") {
+			break
+		}
+	}
+	// The items of the line after s, but for the closing run.
+	line, closed := items, false
+	if n := len(items); n > 0 && strings.Contains(items[n-1].Val, "\n") {
+		line = items[:n-1]
+	} else if n > 0 && items[n-1].Type == ItemTitle {
+		line, closed = items[:n-1], true
+	}
+	body := make([]Item, len(line))
+	for i := range body {
+		body[i] = line[i].Item
+	}
+	open, close := len(strings.TrimSpace(s.Val)), 0
+	if closed {
+		close = len(items[len(line)].Val)
+	}
+	level, before, after := headingLevel(open, close)
+	text := strings.TrimSpace(before + itemText(body) + after)
+	lone := !closed && size < maxEventBuffer && strings.TrimSpace(itemText(body)) == ""
+	if (closed || lone) && text != "" && level >= 1 {
+		p.addSpace(s)
+		p.emit(Event{Kind: EventHeading, Text: text, Level: level, Pos: markupStart(s)})
+		// The templates in the heading are those closed in it.
+		open := make([]int, 0, 4)
+		for i := range line {
+			line[i].quiet = true
+			switch line[i].Type {
+			case ItemLeftMeta:
+				line[i].unclosed = true
+				open = append(open, i)
+			case ItemRightMeta:
+				if len(open) > 0 {
+					line[open[len(open)-1]].unclosed = false
+					open = open[:len(open)-1]
+				}
+			}
+		}
+		if closed {
+			p.unread(line)
+		} else {
+			p.unread(items)
+		}
+		return
+	}
+	p.addText(s.Val, s.Start)
+	p.unread(items)
+}
@@ -0,0 +1,417 @@
+// Expansion of templates from a store of template pages
+
+package wikitext
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultExpansionDepth is the default limit on the nesting of
+// transclusions when expanding templates.
+const DefaultExpansionDepth = 40
+
+// A TemplateStore holds the wikitext of template pages by name, to
+// expand the transclusions of articles with. The zero value is not
+// usable; create stores with NewTemplateStore. Once filled, a store can
+// expand texts in several goroutines at once.
+type TemplateStore struct {
+	// Site gives the names of the template namespace, like "Vorlage"
+	// on the German Wikipedia; those of the English Wikipedia if nil.
+	// Set it before adding templates.
+	Site *Site
+
+	pages     map[string]string
+	redirects map[string]string
+}
+
+// NewTemplateStore creates an empty store.
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{pages: make(map[string]string), redirects: make(map[string]string)}
+}
+
+// site returns the site of the store.
+func (s *TemplateStore) site() *Site {
+	if s.Site == nil {
+		return english
+	}
+	return s.Site
+}
+
+// templateName normalizes a page title or transclusion name: without the
+// "Template:" namespace, with single spaces for runs of spaces and
+// underscores and an upper case first letter, as MediaWiki does.
+func (s *TemplateStore) templateName(name string) string {
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	}), " ")
+	if number, rest := s.site().Split(name); number == NamespaceTemplate {
+		name = rest
+	}
+	if name == "" {
+		return ""
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+// IsTemplateTitle reports whether the page title is in the Template
+// namespace of the English Wikipedia; see Site.Split for other wikis.
+func IsTemplateTitle(title string) bool {
+	number, _ := english.Split(title)
+	return number == NamespaceTemplate
+}
+
+// Add stores the wikitext of the template page with the given title,
+// like "Template:Infobox".
+func (s *TemplateStore) Add(title string, text string) {
+	s.pages[s.templateName(title)] = text
+}
+
+// AddRedirect stores a redirect from one template page to another, like
+// from "Template:Cite" to "Template:Cite web".
+func (s *TemplateStore) AddRedirect(from string, to string) {
+	s.redirects[s.templateName(from)] = s.templateName(to)
+}
+
+// Len returns the number of templates in the store, without redirects.
+func (s *TemplateStore) Len() int {
+	return len(s.pages)
+}
+
+// maxRedirects limits the chains of redirects followed, against loops.
+const maxRedirects = 5
+
+// lookup returns the normalized name and wikitext of a template,
+// following redirects.
+func (s *TemplateStore) lookup(name string) (string, string, bool) {
+	key := s.templateName(name)
+	for range maxRedirects {
+		to, ok := s.redirects[key]
+		if !ok {
+			break
+		}
+		key = to
+	}
+	text, ok := s.pages[key]
+	return key, text, ok
+}
+
+var (
+	noinclude   = regexp.MustCompile(`(?is)<noinclude\s*>.*?(?:</noinclude\s*>|\z)`)
+	includeonly = regexp.MustCompile(`(?is)<includeonly\s*>.*?(?:</includeonly\s*>|\z)`)
+	onlyinclude = regexp.MustCompile(`(?is)<onlyinclude\s*>(.*?)(?:</onlyinclude\s*>|\z)`)
+	inclusion   = regexp.MustCompile(`(?i)</?(?:noinclude|includeonly|onlyinclude)\s*>`)
+)
+
+// transcluded returns the part of a template page that is transcluded:
+// the content of its <onlyinclude> elements if it has any, else the page
+// without its <noinclude> elements.
+func transcluded(text string) string {
+	if parts := onlyinclude.FindAllStringSubmatch(text, -1); len(parts) > 0 {
+		var b strings.Builder
+		for _, p := range parts {
+			b.WriteString(p[1])
+		}
+		text = b.String()
+	} else {
+		text = noinclude.ReplaceAllString(text, "")
+	}
+	return inclusion.ReplaceAllString(text, "")
+}
+
+// shown returns the text of a page as it is shown itself: without its
+// <includeonly> elements and the tags of the others.
+func shown(text string) string {
+	return inclusion.ReplaceAllString(includeonly.ReplaceAllString(text, ""), "")
+}
+
+// A node of the brace structure of wikitext: text, a template
+// transclusion "{{name|...}}" or a parameter "{{{name|default}}}".
+type braceNode struct {
+	braces int // 0 for text, 2 for a template or 3 for a parameter
+	src    string
+	parts  [][]braceNode // the name and arguments, split at '|'
+}
+
+// An open element on the stack of parseBraces.
+type openBraces struct {
+	count int // the unmatched '{' of the element
+	start int // the offset of its first unmatched '{'
+	parts [][]braceNode
+	links int // the depth of links open in the current part
+}
+
+// opaque returns the length of a comment or raw element like <nowiki>
+// starting at s, whose braces are not markup, or 0.
+func opaque(s string) int {
+	if strings.HasPrefix(s, "<!--") {
+		if end := strings.Index(s[4:], "-->"); end >= 0 {
+			return 4 + end + 3
+		}
+		return len(s)
+	}
+	close := strings.IndexByte(s, '>')
+	if close < 0 {
+		return 0
+	}
+	tag, ok := parseTag(s[:close+1])
+	if !ok || !rawElements[tag.Name] || tag.Closing || tag.SelfClosing {
+		return 0
+	}
+	if end := indexFold(s, "</"+tag.Name); end >= 0 {
+		if close := strings.IndexByte(s[end:], '>'); close >= 0 {
+			return end + close + 1
+		}
+	}
+	return len(s)
+}
+
+// parseBraces parses the templates and parameters of wikitext. Like
+// MediaWiki, it matches runs of closing braces with the innermost open
+// run, three braces at a time for parameters and two for templates, and
+// leaves unmatched braces as text.
+func parseBraces(s string) []braceNode {
+	stack := []*openBraces{{parts: [][]braceNode{nil}}}
+	textStart := 0
+	add := func(n braceNode) {
+		top := stack[len(stack)-1]
+		top.parts[len(top.parts)-1] = append(top.parts[len(top.parts)-1], n)
+	}
+	flushText := func(end int) {
+		if end > textStart {
+			add(braceNode{src: s[textStart:end]})
+		}
+		textStart = end
+	}
+	for i := 0; i < len(s); {
+		top := stack[len(stack)-1]
+		skip := 0
+		if s[i] == '<' {
+			skip = opaque(s[i:])
+		}
+		switch {
+		case skip > 0:
+			i += skip
+		case strings.HasPrefix(s[i:], "{{"):
+			flushText(i)
+			n := 0
+			for i+n < len(s) && s[i+n] == '{' {
+				n++
+			}
+			stack = append(stack, &openBraces{count: n, start: i, parts: [][]braceNode{nil}})
+			i += n
+			textStart = i
+		case strings.HasPrefix(s[i:], "}}") && len(stack) > 1:
+			flushText(i)
+			n := 0
+			for i+n < len(s) && s[i+n] == '}' {
+				n++
+			}
+			for n >= 2 && len(stack) > 1 {
+				top = stack[len(stack)-1]
+				k := min(n, top.count, 3)
+				if k < 2 {
+					break
+				}
+				n -= k
+				i += k
+				top.count -= k
+				node := braceNode{braces: k, src: s[top.start+top.count : i], parts: top.parts}
+				stack = stack[:len(stack)-1]
+				if top.count >= 2 {
+					// The remaining braces enclose the node.
+					stack = append(stack, &openBraces{count: top.count, start: top.start, parts: [][]braceNode{{node}}})
+				} else {
+					if top.count == 1 {
+						add(braceNode{src: "{"})
+					}
+					add(node)
+				}
+			}
+			// Braces left over are text.
+			textStart = i
+			i += n
+		case s[i] == '|' && len(stack) > 1 && top.links == 0:
+			flushText(i)
+			top.parts = append(top.parts, nil)
+			i++
+			textStart = i
+		case strings.HasPrefix(s[i:], "[[") && len(stack) > 1:
+			top.links++
+			i += 2
+		case strings.HasPrefix(s[i:], "]]") && len(stack) > 1 && top.links > 0:
+			top.links--
+			i += 2
+		default:
+			i++
+		}
+	}
+	flushText(len(s))
+	// Elements left open are text.
+	for len(stack) > 1 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		add(braceNode{src: s[top.start : top.start+top.count]})
+		for j, part := range top.parts {
+			if j > 0 {
+				add(braceNode{src: "|"})
+			}
+			for _, n := range part {
+				add(n)
+			}
+		}
+	}
+	return stack[0].parts[0]
+}
+
+// An expander expands the templates of one article.
+type expander struct {
+	store    *TemplateStore
+	maxDepth int
+	exceeded func(name string) // called for transclusions nested too deep, if not nil
+	strip    *StripList        // the templates left out, if not nil
+	active   map[string]bool   // templates being expanded, to stop loops
+	title    string            // the title of the page, for magic words
+	now      time.Time
+}
+
+// A frame holds the arguments of a transclusion.
+type frame struct {
+	args  map[string]string
+	depth int
+}
+
+func (e *expander) expandNodes(nodes []braceNode, f *frame) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.braces {
+		case 0:
+			b.WriteString(n.src)
+		case 3:
+			b.WriteString(e.expandParameter(n, f))
+		default:
+			b.WriteString(e.expandTemplate(n, f))
+		}
+	}
+	return b.String()
+}
+
+// expandParameter substitutes the argument of the parameter, its default
+// or, without either, leaves it as it is.
+func (e *expander) expandParameter(n braceNode, f *frame) string {
+	name := strings.TrimSpace(e.expandNodes(n.parts[0], f))
+	if f != nil {
+		if value, ok := f.args[name]; ok {
+			return value
+		}
+	}
+	if len(n.parts) > 1 {
+		return e.expandNodes(n.parts[1], f)
+	}
+	return "{{{" + name + "}}}"
+}
+
+// splitArgument splits a named argument "name=value" at the first '='
+// outside of nested templates and parameters.
+func splitArgument(part []braceNode) (string, []braceNode, bool) {
+	for i, n := range part {
+		if n.braces != 0 {
+			continue
+		}
+		if j := strings.IndexByte(n.src, '='); j >= 0 {
+			name := make([]braceNode, 0, i+1)
+			name = append(name, part[:i]...)
+			name = append(name, braceNode{src: n.src[:j]})
+			value := make([]braceNode, 0, len(part)-i)
+			value = append(value, braceNode{src: n.src[j+1:]})
+			value = append(value, part[i+1:]...)
+			var b strings.Builder
+			for _, m := range name {
+				b.WriteString(m.src)
+			}
+			return b.String(), value, true
+		}
+	}
+	return "", nil, false
+}
+
+// unexpanded returns a transclusion that is not expanded, with the
+// templates and parameters in its name and arguments expanded.
+func (e *expander) unexpanded(n braceNode, f *frame) string {
+	parts := make([]string, len(n.parts))
+	for i, part := range n.parts {
+		parts[i] = e.expandNodes(part, f)
+	}
+	return "{{" + strings.Join(parts, "|") + "}}"
+}
+
+// expandTemplate replaces a transclusion by the expanded template page,
+// or evaluates it if it is a parser function or magic word. Unknown parser
+// functions, templates missing from the store, loops and transclusions
+// nested too deeply are left unexpanded.
+func (e *expander) expandTemplate(n braceNode, f *frame) string {
+	name := strings.TrimSpace(e.expandNodes(n.parts[0], f))
+	if fn, arg, ok := strings.Cut(name, ":"); ok {
+		if value, ok := e.parserFunction(fn, arg, n, f); ok {
+			return value
+		}
+	} else if len(n.parts) == 1 {
+		if value, ok := e.magicWord(name); ok {
+			return value
+		}
+	}
+	for _, prefix := range []string{"subst:", "safesubst:"} {
+		if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			name = strings.TrimSpace(name[len(prefix):])
+		}
+	}
+	depth := 0
+	if f != nil {
+		depth = f.depth
+	}
+	key, text, ok := e.store.lookup(name)
+	if e.strip != nil && !strings.HasPrefix(name, "#") &&
+		(e.strip.StripsTemplate(e.store.templateName(name)) || e.strip.StripsTemplate(key)) {
+		return ""
+	}
+	if strings.HasPrefix(name, "#") || !ok || depth >= e.maxDepth || e.active[key] {
+		if ok && depth >= e.maxDepth && e.exceeded != nil && !strings.HasPrefix(name, "#") {
+			e.exceeded(name)
+		}
+		return e.unexpanded(n, f)
+	}
+	args := make(map[string]string)
+	position := 1
+	for _, part := range n.parts[1:] {
+		if argName, value, named := splitArgument(part); named {
+			args[strings.TrimSpace(e.expandNodes(parseBraces(argName), f))] = strings.TrimSpace(e.expandNodes(value, f))
+		} else {
+			args[strconv.Itoa(position)] = e.expandNodes(part, f)
+			position++
+		}
+	}
+	e.active[key] = true
+	defer delete(e.active, key)
+	return e.expandNodes(parseBraces(transcluded(text)), &frame{args, depth + 1})
+}
+
+// Expand returns the wikitext of an article with its transclusions of
+// templates in the store replaced by their expansion, nested up to
+// maxDepth levels, with the arguments substituted for the parameters.
+// The core parser functions like {{#if:}}, {{#switch:}}, {{#expr:}} and
+// {{#time:}} and magic words like {{PAGENAME}} and {{CURRENTYEAR}} are
+// evaluated; other parser functions and templates not in the store are
+// left as they are. The <noinclude>, <includeonly> and <onlyinclude>
+// elements of template pages and the article are handled as in MediaWiki.
+func (s *TemplateStore) Expand(text string, maxDepth int, opts ...ExpandOption) string {
+	e := &expander{store: s, maxDepth: maxDepth, active: make(map[string]bool), now: time.Now()}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e.expandNodes(parseBraces(shown(text)), nil)
+}
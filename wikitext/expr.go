@@ -0,0 +1,242 @@
+// Evaluation of the arithmetic expressions of {{#expr:}}
+
+package wikitext
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The precedences of the binary operators of expressions, as in the
+// ParserFunctions extension of MediaWiki.
+var binaryPrecedence = map[string]int{
+	"e": 10,
+	"^": 8,
+	"*": 7, "/": 7, "div": 7, "mod": 7, "fmod": 7,
+	"+": 6, "-": 6,
+	"round": 5,
+	"=":     4, "<>": 4, "!=": 4, "<": 4, ">": 4, "<=": 4, ">=": 4,
+	"and": 3,
+	"or":  2,
+}
+
+// The functions of one operand, which bind tighter than all binary
+// operators but "e".
+var unaryFunctions = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"trunc": math.Trunc,
+	"sqrt":  math.Sqrt,
+	"ln":    math.Log,
+	"exp":   math.Exp,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"asin":  math.Asin,
+	"acos":  math.Acos,
+	"atan":  math.Atan,
+	"not": func(x float64) float64 {
+		return boolValue(x == 0)
+	},
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// tokenizeExpr splits an expression into numbers, words and operators.
+func tokenizeExpr(s string) ([]string, error) {
+	s = strings.ReplaceAll(s, "−", "-")
+	tokens := make([]string, 0, 10)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		case c < 0x80 && unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && s[j] < 0x80 && unicode.IsLetter(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, strings.ToLower(s[i:j]))
+			i = j
+		case strings.HasPrefix(s[i:], "<>"), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case strings.IndexByte("+-*/^()=<>", c) >= 0:
+			tokens = append(tokens, s[i:i+1])
+			i++
+		default:
+			return nil, errors.New("Unrecognized punctuation character \"" + s[i:i+1] + "\".")
+		}
+	}
+	return tokens, nil
+}
+
+// An exprParser evaluates a tokenized expression by precedence climbing.
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// operand evaluates a number, constant, parenthesized expression or the
+// application of a unary operator.
+func (p *exprParser) operand() (float64, error) {
+	t := p.peek()
+	p.pos++
+	switch {
+	case t == "":
+		return 0, errors.New("Missing operand.")
+	case t == "-":
+		x, err := p.operand()
+		return -x, err
+	case t == "+":
+		return p.operand()
+	case t == "(":
+		x, err := p.expr(0)
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ")" {
+			return 0, errors.New("Missing closing parenthesis.")
+		}
+		p.pos++
+		return x, nil
+	case t == "e":
+		return math.E, nil
+	case t == "pi":
+		return math.Pi, nil
+	case unaryFunctions[t] != nil:
+		x, err := p.expr(binaryPrecedence["e"])
+		return unaryFunctions[t](x), err
+	case t[0] >= '0' && t[0] <= '9' || t[0] == '.':
+		x, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return 0, errors.New("Unexpected number.")
+		}
+		return x, nil
+	}
+	return 0, errors.New("Unrecognized word \"" + t + "\".")
+}
+
+// expr evaluates the operators of at least the given precedence, left to
+// right.
+func (p *exprParser) expr(precedence int) (float64, error) {
+	x, err := p.operand()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		prec, ok := binaryPrecedence[op]
+		if !ok || prec < precedence {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.expr(prec + 1)
+		if err != nil {
+			return 0, err
+		}
+		if x, err = applyBinary(op, x, y); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func applyBinary(op string, x float64, y float64) (float64, error) {
+	switch op {
+	case "e":
+		return x * math.Pow(10, y), nil
+	case "^":
+		return math.Pow(x, y), nil
+	case "*":
+		return x * y, nil
+	case "/", "div":
+		if y == 0 {
+			return 0, errors.New("Division by zero.")
+		}
+		return x / y, nil
+	case "mod":
+		if int64(y) == 0 {
+			return 0, errors.New("Division by zero.")
+		}
+		return float64(int64(x) % int64(y)), nil
+	case "fmod":
+		if y == 0 {
+			return 0, errors.New("Division by zero.")
+		}
+		return math.Mod(x, y), nil
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "round":
+		scale := math.Pow(10, math.Trunc(y))
+		return math.Round(x*scale) / scale, nil
+	case "=":
+		return boolValue(x == y), nil
+	case "<>", "!=":
+		return boolValue(x != y), nil
+	case "<":
+		return boolValue(x < y), nil
+	case ">":
+		return boolValue(x > y), nil
+	case "<=":
+		return boolValue(x <= y), nil
+	case ">=":
+		return boolValue(x >= y), nil
+	case "and":
+		return boolValue(x != 0 && y != 0), nil
+	case "or":
+		return boolValue(x != 0 || y != 0), nil
+	}
+	return 0, errors.New("Unexpected operator " + op + ".")
+}
+
+// evalExpr evaluates an expression of {{#expr:}}. The empty expression
+// has no value, which is returned as ok false.
+func evalExpr(s string) (x float64, ok bool, err error) {
+	tokens, err := tokenizeExpr(s)
+	if err != nil || len(tokens) == 0 {
+		return 0, false, err
+	}
+	p := &exprParser{tokens: tokens}
+	if x, err = p.expr(0); err != nil {
+		return 0, false, err
+	}
+	if p.pos < len(p.tokens) {
+		return 0, false, errors.New("Unexpected " + p.peek() + ".")
+	}
+	return x, true, nil
+}
+
+// formatNumber formats the value of an expression like PHP does, with
+// up to 14 significant digits.
+func formatNumber(x float64) string {
+	if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return strings.ToUpper(strconv.FormatFloat(x, 'g', 14, 64))
+}
@@ -0,0 +1,73 @@
+// External links to URLs outside of the wiki
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// An ExternalLink is a link to a URL, either in single brackets with an
+// optional label as in "[https://example.org Example]" or a bare URL in
+// the text.
+type ExternalLink struct {
+	URL       string
+	Label     string // the wikitext of the label, empty if there is none
+	Bracketed bool
+	Start     int // byte offset of the "[" or the URL in the text of its document
+	End       int // byte offset after the "]" or the URL
+}
+
+// scanExternalLink parses the bracketed external link whose "[" is at
+// items[i]. It returns the link, the items of its label and the index
+// after the closing "]", which has to be on the same line; otherwise the
+// bracket is text, ok is false and next is the index of the item the
+// search ended at: no other link starting before it has a "]" either.
+func scanExternalLink(items []Item, i int) (link ExternalLink, label []Item, next int, ok bool) {
+	link = ExternalLink{URL: items[i+1].Val, Bracketed: true, Start: markupStart(items[i]).Offset}
+	j := i + 2
+	if j < len(items) && items[j].Type == ItemSpace {
+		j++
+	}
+	for start := j; j < len(items) && !strings.Contains(items[j].Val, "\n"); j++ {
+		if items[j].Type == ItemMark && items[j].Val == "]" {
+			label = items[start:j]
+			link.Label = itemText(label)
+			link.End = items[j].End.Offset
+			return link, label, j + 1, true
+		}
+	}
+	return ExternalLink{}, nil, j, false
+}
+
+// isExternalLink reports whether items[i] starts a bracketed external
+// link.
+func isExternalLink(items []Item, i int) bool {
+	return isMark(items, i, "[") && i+1 < len(items) && items[i+1].Type == ItemURL
+}
+
+// ExternalLinks returns the external links of the document in order,
+// including the URLs given in the parameters of templates like citations.
+func ExternalLinks(doc *Document) []ExternalLink {
+	links := make([]ExternalLink, 0, 10)
+	unclosed := 0 // the brackets before it are text
+	for i := 0; i < len(doc.Items); {
+		switch {
+		case isExternalLink(doc.Items, i) && i >= unclosed:
+			link, _, next, ok := scanExternalLink(doc.Items, i)
+			if ok {
+				links = append(links, link)
+				i = next
+			} else {
+				unclosed = next
+				i++
+			}
+		case doc.Items[i].Type == ItemURL:
+			s := doc.Items[i]
+			links = append(links, ExternalLink{URL: strings.TrimSpace(s.Val), Start: markupStart(s).Offset, End: s.End.Offset})
+			i++
+		default:
+			i++
+		}
+	}
+	return links
+}
@@ -0,0 +1,207 @@
+// Bold and italic text given by runs of apostrophes
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// Format is the formatting of text, a combination of Italic and Bold.
+type Format int
+
+const (
+	Italic Format = 1 << iota // ''italic''
+	Bold                      // '''bold'''
+)
+
+// A Span is a stretch of text of an article that is formatted as italic,
+// bold or both.
+type Span struct {
+	Format Format
+	Start  int // byte offset in the document text after the opening apostrophes
+	End    int // byte offset of the closing apostrophes or the end of the line
+}
+
+// Text returns the wikitext of the span.
+func (s Span) Text(doc *Document) string {
+	return doc.Text[s.Start:s.End]
+}
+
+// A run of apostrophes, lexed as an ItemQuote.
+type quoteRun struct {
+	item    int    // the index of the ItemQuote
+	literal int    // the number of leading apostrophes that are text
+	markup  Format // the formatting toggled by the remaining apostrophes
+	before  string // up to two bytes of the line preceding the markup
+}
+
+// A line of wikitext with runs of apostrophes in it.
+type quoteLine struct {
+	end  int // the index of the item with the line break ending the line
+	runs []quoteRun
+}
+
+// quoteLines interprets the runs of apostrophes outside of templates
+// line by line, as MediaWiki does: two apostrophes toggle italic, three
+// bold and five both. Of four apostrophes the first is text, of more than
+// five all but the last five.
+func quoteLines(items []Item) []quoteLine {
+	lines := make([]quoteLine, 0, 4)
+	line := quoteLine{}
+	before := "" // the end of the line up to the current item
+	finish := func(end int) {
+		if len(line.runs) > 0 {
+			line.end = end
+			disambiguateQuotes(line.runs)
+			lines = append(lines, line)
+		}
+		line = quoteLine{}
+	}
+	for i := 0; i < len(items); i++ {
+		s := items[i]
+		if s.Type == ItemLeftMeta {
+			i = skipTemplate(items, i) - 1
+			continue
+		}
+		val := s.Val
+		if n := strings.LastIndexByte(val, '\n'); n >= 0 {
+			finish(i)
+			before = ""
+			val = val[n+1:]
+		}
+		if s.Type == ItemQuote {
+			n := strings.Count(val, "'")
+			run := quoteRun{item: i}
+			switch {
+			case n == 1:
+				run.literal = 1
+			case n == 2:
+				run.markup = Italic
+			case n == 3:
+				run.markup = Bold
+			case n == 4:
+				run.literal, run.markup = 1, Bold
+			default:
+				run.literal, run.markup = n-5, Italic|Bold
+			}
+			run.before = lastBytes(before+strings.Repeat("'", run.literal), 2)
+			line.runs = append(line.runs, run)
+		}
+		before = lastBytes(before+val, 2)
+	}
+	finish(len(items))
+	return lines
+}
+
+func lastBytes(s string, n int) string {
+	if len(s) > n {
+		return s[len(s)-n:]
+	}
+	return s
+}
+
+// disambiguateQuotes handles a line with an odd number of both italic
+// and bold runs: one bold run is taken as an apostrophe followed by an
+// italic run. That is the first one following a single letter word, like
+// the elided "l'" of an italic French "amour", else the first one
+// following a longer word, else the first one following a space.
+func disambiguateQuotes(runs []quoteRun) {
+	italics, bolds := 0, 0
+	for _, r := range runs {
+		if r.markup&Italic != 0 {
+			italics++
+		}
+		if r.markup&Bold != 0 {
+			bolds++
+		}
+	}
+	if italics%2 == 0 || bolds%2 == 0 {
+		return
+	}
+	singleLetter, multiLetter, space := -1, -1, -1
+	for i, r := range runs {
+		if r.markup != Bold {
+			continue
+		}
+		var x1, x2 byte
+		if n := len(r.before); n > 0 {
+			x1 = r.before[n-1]
+			if n > 1 {
+				x2 = r.before[n-2]
+			}
+		}
+		switch {
+		case x1 == ' ':
+			if space < 0 {
+				space = i
+			}
+		case x2 == ' ':
+			if singleLetter < 0 {
+				singleLetter = i
+			}
+		default:
+			if multiLetter < 0 {
+				multiLetter = i
+			}
+		}
+	}
+	for _, i := range []int{singleLetter, multiLetter, space} {
+		if i >= 0 {
+			runs[i].literal++
+			runs[i].markup = Italic
+			return
+		}
+	}
+}
+
+// quoteLiterals returns the number of apostrophes that are text for the
+// ItemQuotes among the items, by index.
+func quoteLiterals(items []Item) map[int]int {
+	literals := make(map[int]int)
+	for _, line := range quoteLines(items) {
+		for _, r := range line.runs {
+			literals[r.item] = r.literal
+		}
+	}
+	return literals
+}
+
+// Formatting returns the italic and bold spans of the document, outside
+// of templates. Formatting left open is closed at the end of the line.
+// A change of formatting, as from bold and italic to bold only, starts a
+// new span.
+func Formatting(doc *Document) []Span {
+	spans := make([]Span, 0, 10)
+	offsets := itemOffsets(doc.Items)
+	for _, line := range quoteLines(doc.Items) {
+		var format Format
+		start := 0
+		toggle := func(at int, markup Format, next int) {
+			if format != 0 && at > start {
+				spans = append(spans, Span{format, start, at})
+			}
+			format ^= markup
+			start = next
+		}
+		for _, r := range line.runs {
+			if r.markup == 0 {
+				continue
+			}
+			end := offsets[r.item+1]
+			n := 2
+			switch r.markup {
+			case Bold:
+				n = 3
+			case Italic | Bold:
+				n = 5
+			}
+			toggle(end-n, r.markup, end)
+		}
+		end := len(doc.Text)
+		if line.end < len(doc.Items) {
+			end = offsets[line.end] + strings.IndexByte(doc.Items[line.end].Val, '\n')
+		}
+		toggle(end, format, end)
+	}
+	return spans
+}
@@ -0,0 +1,52 @@
+package wikitext
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The cases of the "Italics and bold" tests of MediaWiki's
+// parserTests.txt, with the spans MediaWiki renders as <i> and <b>.
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		spans string // the spans as "format:text", space separated
+		plain string
+	}{
+		{"simple italic", "''italic''", `1:"italic"`, "italic"},
+		{"simple bold", "'''bold'''", `2:"bold"`, "bold"},
+		{"bold italic", "'''''both'''''", `3:"both"`, "both"},
+		{"four quotes", "''''four''''", `2:"four'"`, "'four'"},
+		{"bold in italic", "''foo'''bar'''baz''", `1:"foo" 3:"bar" 1:"baz"`, "foobarbaz"},
+		{"italic in bold", "'''foo''bar''baz'''", `2:"foo" 3:"bar" 2:"baz"`, "foobarbaz"},
+		{"5-quote bold first", "'''''bold''' italic''", `3:"bold" 1:" italic"`, "bold italic"},
+		{"5-quote italic first", "'''''foo'' bar'''", `3:"foo" 2:" bar"`, "foo bar"},
+		// Of an odd number of both, one bold is an apostrophe and italic.
+		{"2-quote opening (2,3)", "''foo'''", `1:"foo'"`, "foo'"},
+		{"3-quote opening (3,2)", "'''foo''", `1:"foo"`, "'foo"},
+		{"possessive", "obtained by ''[[Lunar Prospector]]'''s gamma-ray spectrometer", `1:"[[Lunar Prospector]]'"`,
+			"obtained by Lunar Prospector's gamma-ray spectrometer"},
+		{"even italics", "''Mary'''s'' dog", `1:"Mary" 3:"s" 2:" dog"`, "Marys dog"},
+		{"single-letter word", "L'''amour''' x", `2:"amour"`, "Lamour x"},
+		{"unclosed", "''open", `1:"open"`, "open"},
+		{"closed at the line end", "''foo\nbar''", `1:"foo"`, "foo bar"},
+		{"plain", "plain", "", "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, _ := Parse(tt.text)
+			spans := make([]string, 0, 3)
+			for _, s := range Formatting(doc) {
+				spans = append(spans, fmt.Sprintf("%d:%q", s.Format, s.Text(doc)))
+			}
+			if got := strings.Join(spans, " "); got != tt.spans {
+				t.Errorf("Formatting(%q) = %s, want %s", tt.text, got, tt.spans)
+			}
+			if got := PlainText(doc); got != tt.plain {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.text, got, tt.plain)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package wikitext
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// The seed corpus of both targets, snippets of the markup the lexer has
+// states for and articles of testdata/minidump.xml, is in
+// testdata/fuzz/FuzzLex and testdata/fuzz/FuzzParse. Inputs the fuzzer
+// finds failing are added there, and wikimin can reduce them.
+
+// tokenizers are the tokenizers FuzzLex lexes with: the default one and
+// one with every rule.
+var tokenizers = []Tokenizer{{}, {SplitHyphens: true, SplitApostrophes: true, Numbers: true, Segment: true}}
+
+// FuzzLex checks that lexing neither panics nor loses bytes: the spans of
+// the items tile the input exactly, and lexing from a reader returning a
+// byte at a time gives the same items as lexing the string, with each of
+// the tokenizers.
+func FuzzLex(f *testing.F) {
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, tok := range tokenizers {
+			checkLex(t, tok, text)
+		}
+	})
+}
+
+// checkLex checks the items of text lexed by tok for FuzzLex.
+func checkLex(t *testing.T, tok Tokenizer, text string) {
+	t.Helper()
+	items := make([]Item, 0, len(text)/3+1)
+	for s := range tok.LexContext(context.Background(), text).Items() {
+		items = append(items, s)
+	}
+	i := 0
+	for s := range tok.LexReaderContext(context.Background(), iotest.OneByteReader(strings.NewReader(text))).Items() {
+		if i == len(items) || s.Type != items[i].Type || s.Val != items[i].Val || s.Start != items[i].Start {
+			t.Fatalf("%+v: item %d of the reader is %v %q at %v", tok, i, s.Type, s.Val, s.Start)
+		}
+		i++
+	}
+	if i != len(items) {
+		t.Fatalf("%+v: the reader lexes %d of %d items", tok, i, len(items))
+	}
+	offset := 0
+	var b strings.Builder
+	for _, s := range items {
+		if s.Type == ItemError {
+			continue
+		}
+		if s.Start.Offset != offset || s.End.Offset != offset+len(s.Val) {
+			t.Fatalf("%+v: item %v spans %d-%d, expected %d-%d", tok, s.Type, s.Start.Offset, s.End.Offset, offset, offset+len(s.Val))
+		}
+		offset = s.End.Offset
+		b.WriteString(s.Val)
+	}
+	if b.String() != text {
+		t.Fatalf("%+v: items tile %d of %d bytes", tok, b.Len(), len(text))
+	}
+}
+
+// FuzzParse checks that parsing and the extractors neither panic nor
+// hang, that the templates started by ParseEvents end in the order
+// opposite to their start, and that rewriting every node as it is keeps
+// the text.
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, text string) {
+		doc, _ := Parse(text)
+		if doc == nil {
+			t.Fatal("no document")
+		}
+		PlainText(doc)
+		Links(doc)
+		Navboxes(doc)
+		Templates(doc, 0)
+		ExternalLinks(doc)
+		Images(doc)
+		Sections(doc)
+		Citations(doc)
+		open := 0
+		for ev := range ParseEvents(strings.NewReader(text)) {
+			switch ev.Kind {
+			case EventStartTemplate:
+				open++
+			case EventEndTemplate:
+				open--
+			case EventError:
+				t.Fatalf("parsing events: %v", ev.Err)
+			}
+			if open < 0 {
+				t.Fatalf("template ending at %v was not started", ev.Pos)
+			}
+		}
+		if open != 0 {
+			t.Fatalf("%d templates started by the events do not end", open)
+		}
+		if rewritten := Rewrite(doc, func(c *Cursor) { c.Replace(c.Text()) }); rewritten != text {
+			t.Fatalf("rewriting the nodes as they are changes %d to %d bytes", len(text), len(rewritten))
+		}
+	})
+}
@@ -0,0 +1,302 @@
+// Rendering of wikitext as HTML
+
+package wikitext
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// listElements are the elements of lists and of their items, by the
+// character of the list marker.
+var listElements = map[byte][2]string{
+	'*': {"ul", "li"},
+	'#': {"ol", "li"},
+	':': {"dl", "dd"},
+	';': {"dl", "dt"},
+}
+
+// An htmlWriter renders the items of section bodies as HTML, keeping
+// track of the open paragraph, lists and formatting.
+type htmlWriter struct {
+	site     *Site
+	base     string
+	b        strings.Builder
+	para     bool     // a <p> is open
+	lists    []byte   // the markers of the open lists, outermost first
+	format   []Format // the open formatting, innermost last
+	lineDone bool     // a line of a list item ended
+	newline  bool     // a line of the paragraph ended
+	fresh    bool     // nothing was written in the paragraph or list item yet
+}
+
+// escape escapes text for HTML, with character references like "&nbsp;"
+// kept as the characters they stand for.
+func escape(text string) string {
+	return html.EscapeString(html.UnescapeString(text))
+}
+
+// href returns the URL of the page of the site the link points to.
+func (w *htmlWriter) href(l Link) string {
+	u := ""
+	if l.Target != "" {
+		u = w.base + url.PathEscape(strings.ReplaceAll(l.Target, " ", "_"))
+	}
+	if l.Section != "" {
+		u += "#" + url.PathEscape(anchorName(l.Section))
+	}
+	return u
+}
+
+// closeFormat closes the open bold and italic elements.
+func (w *htmlWriter) closeFormat() {
+	for i := len(w.format) - 1; i >= 0; i-- {
+		w.b.WriteString(formatTags[w.format[i]][1])
+	}
+	w.format = w.format[:0]
+}
+
+var formatTags = map[Format][2]string{
+	Italic: {"<i>", "</i>"},
+	Bold:   {"<b>", "</b>"},
+}
+
+// toggle opens or closes bold or italic. Elements opened within the one
+// closed are closed and opened again, so that they nest.
+func (w *htmlWriter) toggle(f Format) {
+	w.inline()
+	for i := len(w.format) - 1; i >= 0; i-- {
+		if w.format[i] == f {
+			for j := len(w.format) - 1; j >= i; j-- {
+				w.b.WriteString(formatTags[w.format[j]][1])
+			}
+			inner := w.format[i+1:]
+			w.format = append(w.format[:i], inner...)
+			for _, g := range inner {
+				w.b.WriteString(formatTags[g][0])
+			}
+			return
+		}
+	}
+	w.format = append(w.format, f)
+	w.b.WriteString(formatTags[f][0])
+}
+
+// closeLists closes the open lists down to the given depth.
+func (w *htmlWriter) closeLists(depth int) {
+	for len(w.lists) > depth {
+		elements := listElements[w.lists[len(w.lists)-1]]
+		w.b.WriteString("</" + elements[1] + "></" + elements[0] + ">\n")
+		w.lists = w.lists[:len(w.lists)-1]
+	}
+}
+
+// closeBlock closes the open paragraph or lists.
+func (w *htmlWriter) closeBlock() {
+	w.closeFormat()
+	if w.para {
+		w.b.WriteString("</p>\n")
+		w.para = false
+	}
+	w.closeLists(0)
+	w.lineDone, w.newline = false, false
+}
+
+// inline makes sure a paragraph or list item is open for text, closing
+// the lists whose items ended with the line before.
+func (w *htmlWriter) inline() {
+	if w.lineDone {
+		w.closeBlock()
+	}
+	if !w.para && len(w.lists) == 0 {
+		w.b.WriteString("<p>")
+		w.para, w.fresh = true, true
+	}
+	if w.newline {
+		w.b.WriteString("\n")
+		w.newline = false
+	}
+}
+
+// text writes text of the wikitext, escaped.
+func (w *htmlWriter) text(text string) {
+	if text != "" {
+		w.inline()
+		w.b.WriteString(escape(text))
+		w.fresh = false
+	}
+}
+
+// markup writes HTML in the paragraph or list item.
+func (w *htmlWriter) markup(html string) {
+	w.inline()
+	w.b.WriteString(html)
+	w.fresh = false
+}
+
+// space writes a space between words.
+func (w *htmlWriter) space() {
+	if !w.fresh && !w.newline && !w.lineDone && (w.para || len(w.lists) > 0) {
+		w.b.WriteString(" ")
+	}
+}
+
+// listItem starts an item of the lists given by the markers, like "*#"
+// for a numbered list in a bulleted one, continuing the lists the items
+// before share with it.
+func (w *htmlWriter) listItem(markers string) {
+	w.closeFormat()
+	if w.para {
+		w.b.WriteString("</p>\n")
+		w.para = false
+	}
+	w.lineDone, w.newline, w.fresh = false, false, true
+	same := 0
+	for same < len(w.lists) && same < len(markers) && listElements[w.lists[same]][0] == listElements[markers[same]][0] {
+		same++
+	}
+	if same == len(markers) && same > 0 {
+		// Another item of the innermost list, or a new one if it is a
+		// term or definition of a different kind.
+		same--
+	}
+	w.closeLists(same + 1)
+	if len(w.lists) > same {
+		elements := listElements[w.lists[same]]
+		w.b.WriteString("</" + elements[1] + ">\n")
+		w.lists[same] = markers[same]
+		w.b.WriteString("<" + listElements[markers[same]][1] + ">")
+		same++
+	}
+	for ; same < len(markers); same++ {
+		elements := listElements[markers[same]]
+		w.b.WriteString("<" + elements[0] + ">\n<" + elements[1] + ">")
+		w.lists = append(w.lists, markers[same])
+	}
+}
+
+// lineBreaks handles the line breaks of the white space an item starts
+// with: a blank line ends the paragraph or lists, a line break ends the
+// line of a list item and the formatting.
+func (w *htmlWriter) lineBreaks(space string) {
+	switch n := strings.Count(space, "\n"); {
+	case n >= 2:
+		w.closeBlock()
+	case n == 1:
+		w.closeFormat()
+		if len(w.lists) > 0 {
+			w.lineDone = true
+		} else if w.para {
+			w.newline = true
+		}
+	}
+}
+
+// render writes the HTML of the items.
+func (w *htmlWriter) render(items []Item) {
+	quotes := make(map[int]quoteRun)
+	for _, line := range quoteLines(items) {
+		for _, r := range line.runs {
+			quotes[r.item] = r
+		}
+	}
+	unclosed := 0 // the brackets of external links before it are text
+	for i := 0; i < len(items); {
+		s := items[i]
+		space := s.Val[:len(s.Val)-len(strings.TrimLeft(s.Val, " \t\r\n"))]
+		w.lineBreaks(space)
+		switch {
+		case s.Type == ItemLeftMeta:
+			i = skipTemplate(items, i)
+			continue
+		case isMark(items, i, "{") && isMark(items, i+1, "|"):
+			w.closeBlock()
+			i = skipTable(items, i)
+			continue
+		case s.Type == ItemLeftTag:
+			if _, next, ok := scanLanguageLink(w.site, items, i); ok {
+				i = next
+				continue
+			}
+			links, anchor, next := scanLink(w.site, items, i+1, nil)
+			start := markupStart(s).Offset
+			if n := len(links); n > 0 && links[n-1].Start == start && links[n-1].Interwiki == "" {
+				w.markup(`<a href="` + escape(w.href(links[n-1])) + `">` + escape(anchor) + "</a>")
+			} else {
+				w.text(anchor)
+			}
+			i = next
+			continue
+		case isExternalLink(items, i) && i >= unclosed:
+			link, label, next, ok := scanExternalLink(items, i)
+			if ok {
+				text := strings.TrimSpace(strings.Join(renderText(w.site, nil, label), ""))
+				if text == "" {
+					text = link.URL
+				}
+				w.markup(`<a class="external" href="` + escape(link.URL) + `">` + escape(text) + "</a>")
+				i = next
+				continue
+			}
+			unclosed = next
+			w.text(strings.TrimLeft(s.Val, " \t\r\n"))
+		case s.Type == ItemURL:
+			url := strings.TrimSpace(s.Val)
+			w.markup(`<a class="external" href="` + escape(url) + `">` + escape(url) + "</a>")
+		case s.Type == ItemXML:
+			tag, ok := parseTag(s.Val)
+			if ok && !tag.Closing && !tag.SelfClosing && hiddenElements[tag.Name] {
+				i = skipElement(items, i, tag.Name)
+				continue
+			}
+			if ok && tag.Name == "br" {
+				w.markup("<br>")
+			}
+		case s.Type == ItemList:
+			w.listItem(strings.TrimSpace(s.Val))
+		case s.Type == ItemQuote:
+			r := quotes[i]
+			w.text(strings.Repeat("'", r.literal))
+			if r.markup&Bold != 0 {
+				w.toggle(Bold)
+			}
+			if r.markup&Italic != 0 {
+				w.toggle(Italic)
+			}
+		case s.Type == ItemComment, s.Type == ItemSwitch, s.Type == ItemRightMeta, s.Type == ItemRightTag, s.Type == ItemError:
+		default:
+			if space != "" && !strings.Contains(space, "\n") {
+				w.space()
+			}
+			w.text(s.Val[len(space):])
+		}
+		i++
+	}
+	w.closeBlock()
+}
+
+// HTML returns the document rendered as HTML: the headings of its
+// sections with their anchors as ids, paragraphs and lists with bold and
+// italic text and wiki and external links. The URLs of wiki links are
+// their titles appended to base, like "https://en.wikipedia.org/wiki/";
+// links to other wikis are text. Templates, tables, references, images
+// and other markup are left out, as by PlainText.
+func HTML(doc *Document, base string) string {
+	w := &htmlWriter{site: doc.siteOf(), base: base}
+	var visit func(sections []Section)
+	visit = func(sections []Section) {
+		for _, s := range sections {
+			if s.Heading != "" {
+				level := strconv.Itoa(min(max(s.Level, 1), 6))
+				w.b.WriteString("<h" + level + ` id="` + escape(s.Anchor) + `">` + escape(s.Heading) + "</h" + level + ">\n")
+			}
+			end := s.bodyEnd()
+			w.render(itemsIn(doc, s.Start, end))
+			visit(s.Children)
+		}
+	}
+	visit(Sections(doc))
+	return w.b.String()
+}
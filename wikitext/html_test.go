@@ -0,0 +1,14 @@
+package wikitext
+
+import "testing"
+
+func TestHTMLSubsections(t *testing.T) {
+	doc, err := Parse("Born in 1930.\n\n== Life ==\nBorn in Ohio.\n\n=== Sub ===\nText.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<p>Born in 1930.</p>\n<h2 id=\"Life\">Life</h2>\n<p>Born in Ohio.</p>\n<h3 id=\"Sub\">Sub</h3>\n<p>Text.</p>\n"
+	if got := HTML(doc, "https://en.wikipedia.org/wiki/"); got != want {
+		t.Errorf("HTML = %q, want %q", got, want)
+	}
+}
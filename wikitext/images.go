@@ -0,0 +1,176 @@
+// Images embedded with File: links and in galleries
+
+package wikitext
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A Media is an image or other file embedded in an article by a link
+// like [[File:Foo.jpg|thumb|200px|Caption]] or a line of a <gallery>.
+type Media struct {
+	File    string   // the file name without namespace, like "Foo.jpg"
+	Options []string // the display options, like "thumb" or "200px"
+	Alt     string   // the alternative text given by alt=
+	Link    string   // the link target given by link=
+	Caption string   // the wikitext of the caption
+	Gallery bool     // whether the file is shown in a gallery
+	Start   int      // byte offset of the "[[" or gallery line in the text of its document
+	End     int      // byte offset after the "]]" or the line
+}
+
+// Image options without a value.
+var imageOptions = map[string]bool{
+	"thumb": true, "thumbnail": true, "frame": true, "framed": true,
+	"frameless": true, "border": true, "upright": true,
+	"left": true, "right": true, "center": true, "centre": true, "none": true,
+	"baseline": true, "sub": true, "super": true, "top": true,
+	"text-top": true, "middle": true, "bottom": true, "text-bottom": true,
+}
+
+// Image options with a value, as in "upright=1.2".
+var imageValueOptions = map[string]bool{
+	"upright": true, "page": true, "class": true, "lang": true,
+	"thumb": true, "thumbnail": true,
+}
+
+var imageSize = regexp.MustCompile(`^(\d+)?(x\d+)?\s*px$`)
+
+// fileName returns the file name of a link target in the file namespace
+// of the site.
+func fileName(site *Site, target string) (string, bool) {
+	number, name := site.Split(strings.TrimSpace(target))
+	return name, number == NamespaceFile && name != ""
+}
+
+// parseMedia builds a Media from the file name and the parameters that
+// follow it. As in MediaWiki, the last parameter that is no option is
+// the caption. Options in the language of the site are given in English,
+// like "thumb" for "mini".
+func parseMedia(site *Site, file string, params []string) Media {
+	m := Media{File: file, Options: make([]string, 0, 4)}
+	for _, p := range params {
+		p = strings.TrimSpace(p)
+		name, value, hasValue := strings.Cut(p, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if english := site.imageOption(name); english != name {
+			name, p = english, english
+			if hasValue {
+				p += "=" + strings.TrimSpace(value)
+			}
+		}
+		switch {
+		case hasValue && name == "alt":
+			m.Alt = strings.TrimSpace(value)
+		case hasValue && name == "link":
+			m.Link = strings.TrimSpace(value)
+		case hasValue && imageValueOptions[name], !hasValue && imageOptions[name], imageSize.MatchString(p):
+			m.Options = append(m.Options, p)
+		default:
+			m.Caption = p
+		}
+	}
+	return m
+}
+
+// closingTags returns the index of the "]]" closing each "[[" of the
+// items, by the index of the "[[", or -1 if it is not closed.
+func closingTags(items []Item) []int {
+	closing := make([]int, len(items))
+	open := make([]int, 0, 10)
+	for i, s := range items {
+		closing[i] = -1
+		switch s.Type {
+		case ItemLeftTag:
+			open = append(open, i)
+		case ItemRightTag:
+			if n := len(open); n > 0 {
+				closing[open[n-1]] = i
+				open = open[:n-1]
+			}
+		}
+	}
+	return closing
+}
+
+// scanFileLink parses the link whose "[[" is at items[i] and whose "]]" is
+// at items[j] if it embeds a file. It returns the index after the "]]".
+func scanFileLink(site *Site, items []Item, i int, j int) (Media, int, bool) {
+	if j < 0 {
+		return Media{}, i + 1, false
+	}
+	parts := splitPipes(items[i+1 : j])
+	file, ok := fileName(site, parts[0])
+	if !ok || strings.HasPrefix(strings.TrimSpace(parts[0]), ":") {
+		return Media{}, i + 1, false
+	}
+	m := parseMedia(site, file, parts[1:])
+	m.Start, m.End = markupStart(items[i]).Offset, items[j].End.Offset
+	return m, j + 1, true
+}
+
+// galleryMedia parses the lines "File:Foo.jpg|Caption" of a gallery. The
+// namespace may be left out.
+func galleryMedia(site *Site, content []Item) []Media {
+	media := make([]Media, 0, 10)
+	offset := 0
+	if len(content) > 0 {
+		offset = content[0].Start.Offset
+	}
+	for _, line := range strings.Split(itemText(content), "\n") {
+		start := offset
+		offset += len(line) + 1
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		items := make([]Item, 0, 10)
+		for s := range Lex(line).Items() {
+			items = append(items, s)
+		}
+		parts := splitPipes(items)
+		file, ok := fileName(site, parts[0])
+		if !ok {
+			file = strings.TrimSpace(parts[0])
+		}
+		m := parseMedia(site, file, parts[1:])
+		m.Gallery = true
+		m.Start, m.End = start, start+len(line)
+		media = append(media, m)
+	}
+	return media
+}
+
+// Images returns the files embedded in the document, by links in a file
+// namespace and in galleries, in order. Links to files like
+// [[:File:Foo.jpg]] do not embed them and are left out.
+func Images(doc *Document) []Media {
+	media := make([]Media, 0, 10)
+	closing := closingTags(doc.Items)
+	for i := 0; i < len(doc.Items); {
+		s := doc.Items[i]
+		switch s.Type {
+		case ItemLeftTag:
+			m, next, ok := scanFileLink(doc.siteOf(), doc.Items, i, closing[i])
+			if ok {
+				media = append(media, m)
+				i = next
+				continue
+			}
+		case ItemXML:
+			tag, ok := parseTag(s.Val)
+			if ok && tag.Name == "gallery" && !tag.Closing && !tag.SelfClosing {
+				end := skipElement(doc.Items, i, "gallery")
+				content := doc.Items[i+1 : end]
+				if t, ok := parseTag(doc.Items[end-1].Val); ok && t.Closing && t.Name == "gallery" {
+					content = doc.Items[i+1 : end-1]
+				}
+				media = append(media, galleryMedia(doc.siteOf(), content)...)
+				i = end
+				continue
+			}
+		}
+		i++
+	}
+	return media
+}
@@ -0,0 +1,154 @@
+// Extraction of the interlanguage links of a document
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// A LanguageLink is an interlanguage link like [[de:Mond]], which links
+// the page to the one on the same subject in the wiki of another language
+// instead of appearing in the text, much like a category assignment.
+// Links with a leading colon, like [[:de:Mond]], are interwiki links in
+// the text.
+type LanguageLink struct {
+	Language string // the lower case language code, like "de" or "zh-yue"
+	Title    string
+	Start    int // byte offset of the "[[" in the text of its document
+	End      int // byte offset after the "]]"
+}
+
+// languageCodes are the prefixes of the language editions of Wikipedia
+// and their aliases, like "nb" for "no", as in the interwiki map of
+// Wikimedia. Other short prefixes are part of the title, like the one of
+// [[CSI: Miami]].
+var languageCodes = map[string]bool{
+	"aa": true, "ab": true, "ace": true, "ady": true, "af": true,
+	"ak": true, "als": true, "alt": true, "am": true, "ami": true,
+	"an": true, "ang": true, "anp": true, "ar": true, "arc": true,
+	"ary": true, "arz": true, "as": true, "ast": true, "atj": true,
+	"av": true, "avk": true, "awa": true, "ay": true, "az": true,
+	"azb": true, "ba": true, "ban": true, "bar": true, "bat-smg": true,
+	"bbc": true, "bcl": true, "bdr": true, "be": true, "be-tarask": true,
+	"be-x-old": true, "bew": true, "bg": true, "bh": true, "bi": true,
+	"bjn": true, "blk": true, "bm": true, "bn": true, "bo": true,
+	"bpy": true, "br": true, "bs": true, "btm": true, "bug": true,
+	"bxr": true, "ca": true, "cbk-zam": true, "cdo": true, "ce": true,
+	"ceb": true, "ch": true, "cho": true, "chr": true, "chy": true,
+	"ckb": true, "co": true, "cr": true, "crh": true, "cs": true,
+	"csb": true, "cu": true, "cv": true, "cy": true, "da": true,
+	"dag": true, "de": true, "dga": true, "din": true, "diq": true,
+	"dsb": true, "dtp": true, "dty": true, "dv": true, "dz": true,
+	"ee": true, "el": true, "eml": true, "en": true, "eo": true,
+	"es": true, "et": true, "eu": true, "ext": true, "fa": true,
+	"fat": true, "ff": true, "fi": true, "fiu-vro": true, "fj": true,
+	"fo": true, "fon": true, "fr": true, "frp": true, "frr": true,
+	"fur": true, "fy": true, "ga": true, "gag": true, "gan": true,
+	"gcr": true, "gd": true, "gl": true, "glk": true, "gn": true,
+	"gom": true, "gor": true, "got": true, "gpe": true, "gu": true,
+	"guc": true, "gur": true, "guw": true, "gv": true, "ha": true,
+	"hak": true, "haw": true, "he": true, "hi": true, "hif": true,
+	"ho": true, "hr": true, "hsb": true, "ht": true, "hu": true,
+	"hy": true, "hyw": true, "hz": true, "ia": true, "iba": true,
+	"id": true, "ie": true, "ig": true, "igl": true, "ii": true,
+	"ik": true, "ilo": true, "inh": true, "io": true, "is": true,
+	"it": true, "iu": true, "ja": true, "jam": true, "jbo": true,
+	"jv": true, "ka": true, "kaa": true, "kab": true, "kbd": true,
+	"kbp": true, "kcg": true, "kg": true, "kge": true, "ki": true,
+	"kj": true, "kk": true, "kl": true, "km": true, "kn": true,
+	"knc": true, "ko": true, "koi": true, "kr": true, "krc": true,
+	"ks": true, "ksh": true, "ku": true, "kus": true, "kv": true,
+	"kw": true, "ky": true, "la": true, "lad": true, "lb": true,
+	"lbe": true, "lez": true, "lfn": true, "lg": true, "li": true,
+	"lij": true, "lld": true, "lmo": true, "ln": true, "lo": true,
+	"lrc": true, "lt": true, "ltg": true, "lv": true, "lzh": true,
+	"mad": true, "mai": true, "map-bms": true, "mdf": true, "mg": true,
+	"mh": true, "mhr": true, "mi": true, "min": true, "mk": true,
+	"ml": true, "mn": true, "mni": true, "mnw": true, "mos": true,
+	"mr": true, "mrj": true, "ms": true, "mt": true, "mus": true,
+	"mwl": true, "my": true, "myv": true, "mzn": true, "na": true,
+	"nah": true, "nan": true, "nap": true, "nb": true, "nds": true,
+	"nds-nl": true, "ne": true, "new": true, "ng": true, "nia": true,
+	"nl": true, "nn": true, "no": true, "nov": true, "nqo": true,
+	"nr": true, "nrm": true, "nso": true, "nv": true, "ny": true,
+	"oc": true, "olo": true, "om": true, "or": true, "os": true,
+	"pa": true, "pag": true, "pam": true, "pap": true, "pcd": true,
+	"pcm": true, "pdc": true, "pfl": true, "pi": true, "pih": true,
+	"pl": true, "pms": true, "pnb": true, "pnt": true, "ps": true,
+	"pt": true, "pwn": true, "qu": true, "rm": true, "rmy": true,
+	"rn": true, "ro": true, "roa-rup": true, "roa-tara": true, "rsk": true,
+	"ru": true, "rue": true, "rup": true, "rw": true, "sa": true,
+	"sah": true, "sat": true, "sc": true, "scn": true, "sco": true,
+	"sd": true, "se": true, "sg": true, "sgs": true, "sh": true,
+	"shi": true, "shn": true, "si": true, "simple": true, "sk": true,
+	"skr": true, "sl": true, "sm": true, "smn": true, "sn": true,
+	"so": true, "sq": true, "sr": true, "srn": true, "ss": true,
+	"st": true, "stq": true, "su": true, "sv": true, "sw": true,
+	"szl": true, "szy": true, "ta": true, "tay": true, "tcy": true,
+	"tdd": true, "te": true, "tet": true, "tg": true, "th": true,
+	"ti": true, "tig": true, "tk": true, "tl": true, "tly": true,
+	"tn": true, "to": true, "tpi": true, "tr": true, "trv": true,
+	"ts": true, "tt": true, "tum": true, "tw": true, "ty": true,
+	"tyv": true, "udm": true, "ug": true, "uk": true, "ur": true,
+	"uz": true, "ve": true, "vec": true, "vep": true, "vi": true,
+	"vls": true, "vo": true, "vro": true, "wa": true, "war": true,
+	"wo": true, "wuu": true, "xal": true, "xh": true, "xmf": true,
+	"yi": true, "yo": true, "yue": true, "za": true, "zea": true,
+	"zgh": true, "zh": true, "zh-classical": true, "zh-min-nan": true,
+	"zh-yue": true, "zu": true,
+}
+
+// isLanguagePrefix reports whether the lower case prefix before a colon
+// is the code of a language wiki, among them Simple English, rather than
+// of a sister project.
+func isLanguagePrefix(prefix string) bool {
+	return languageCodes[prefix]
+}
+
+// parseLanguageLink parses the text between "[[" and "]]" of a language
+// link. It returns false for all other links.
+func parseLanguageLink(site *Site, body string) (LanguageLink, bool) {
+	prefix, title, ok := strings.Cut(strings.TrimSpace(body), ":")
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if _, isNamespace := site.Namespace(prefix); !ok || isNamespace || !isLanguagePrefix(prefix) {
+		return LanguageLink{}, false
+	}
+	title, _, _ = strings.Cut(title, "|")
+	title = strings.TrimSpace(title)
+	return LanguageLink{Language: prefix, Title: title}, title != ""
+}
+
+// scanLanguageLink returns the language link whose "[[" is at items[i]
+// and the index after its "]]", or false if it is another link.
+func scanLanguageLink(site *Site, items []Item, i int) (LanguageLink, int, bool) {
+	body := make([]string, 0, 4)
+	for j := i + 1; j < len(items); j++ {
+		switch items[j].Type {
+		case ItemRightTag:
+			l, ok := parseLanguageLink(site, strings.Join(body, ""))
+			l.Start, l.End = markupStart(items[i]).Offset, items[j].End.Offset
+			return l, j + 1, ok
+		case ItemLeftTag, ItemLeftMeta:
+			return LanguageLink{}, i, false
+		}
+		body = append(body, items[j].Val)
+	}
+	return LanguageLink{}, i, false
+}
+
+// LanguageLinks returns the interlanguage links of the document in
+// order. They are among the Links too, of the class LinkInterwiki, but
+// are not part of its text.
+func LanguageLinks(doc *Document) []LanguageLink {
+	links := make([]LanguageLink, 0, 10)
+	for i := 0; i < len(doc.Items); i++ {
+		if doc.Items[i].Type != ItemLeftTag {
+			continue
+		}
+		if l, next, ok := scanLanguageLink(doc.siteOf(), doc.Items, i); ok {
+			links = append(links, l)
+			i = next - 1
+		}
+	}
+	return links
+}
@@ -0,0 +1,823 @@
+// A lexer for xml dumps from wikipedia
+// Inspired by Rob Pike's lexer for go templates
+// (c) Philipp Moritz, 2014
+
+package wikitext
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const eof = -1
+
+// stateFn represents the state of the scanner as a function that
+// returns the next state.
+type stateFn func(*Lexer) stateFn
+
+// A Lexer scans wikitext into items. It runs in its own goroutine and
+// passes the items on through a channel in batches, until the end of the
+// input or until its context is cancelled.
+type Lexer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	input  string      // the string being scanned, or the buffered part of the reader.
+	state  stateFn     // the next lexing function to enter.
+	start  int         // start position of this item.
+	pos    int         // current position in the input.
+	line   Pos         // the line and column of start.
+	width  int         // width of last rune read from input.
+	out    []Item      // the items scanned and not yet passed on.
+	items  chan []Item // channel of batches of scanned items.
+	free   chan []Item // batches the client is done with, for reuse.
+	batch  []Item      // the batch the client takes items from.
+	taken  int         // the number of items the client took from batch.
+
+	reader io.Reader // the rest of the input after input, nil for strings.
+	err    error     // the error reading from reader, other than io.EOF.
+	tok    Tokenizer // how text is split into words and numbers.
+}
+
+// batchSize is the number of items passed to the client at a time;
+// sending every item through the channel would cost more than lexing it.
+const batchSize = 256
+
+// checkInterval is the number of states after which the lexer checks
+// whether its context is cancelled.
+const checkInterval = 64
+
+// readSize is the number of bytes a Lexer reads from its reader at a
+// time, and the input it keeps buffered ahead of the item being lexed.
+const readSize = 32 << 10
+
+// ItemType identifies the type of lexed items.
+type ItemType int
+
+const (
+	ItemError ItemType = iota
+	ItemEOF
+	ItemLeftMeta
+	ItemRightMeta
+	ItemLeftTag
+	ItemRightTag
+	ItemNumber
+	ItemWord
+	ItemQuote
+	ItemSpace
+	ItemMark
+	ItemXML
+	ItemTitle
+	ItemList    // the list markers like "*" or "#:" starting a line
+	ItemURL     // an external URL like "https://example.org/"
+	ItemEntity  // a character reference like "&nbsp;" or "&#8211;"
+	ItemComment // a comment "<!-- ... -->"
+	ItemRaw     // the content of an element like <nowiki> that is not wikitext
+	ItemSwitch  // a behavior switch like "__NOTOC__"
+)
+
+var itemNames = map[ItemType]string{
+	ItemError:     "error",
+	ItemEOF:       "eof",
+	ItemLeftMeta:  "leftmeta",
+	ItemRightMeta: "rightmeta",
+	ItemLeftTag:   "lefttag",
+	ItemRightTag:  "righttag",
+	ItemNumber:    "number",
+	ItemWord:      "word",
+	ItemQuote:     "quote",
+	ItemSpace:     "space",
+	ItemMark:      "mark",
+	ItemXML:       "xml",
+	ItemTitle:     "title",
+	ItemList:      "list",
+	ItemURL:       "url",
+	ItemEntity:    "entity",
+	ItemComment:   "comment",
+	ItemRaw:       "raw",
+	ItemSwitch:    "switch",
+}
+
+func (t ItemType) String() string {
+	if name, ok := itemNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("item%d", int(t))
+}
+
+// An Item is a token of wikitext. The values of all items but ItemError
+// concatenate to the input of the lexer, from Start to End; an
+// ItemError is at the position of the problem.
+type Item struct {
+	Type  ItemType
+	Val   string
+	Err   error // the error of an ItemError
+	Start Pos
+	End   Pos // the position after the item
+}
+
+// Lex creates a new scanner for the input string.
+func Lex(input string) *Lexer {
+	return LexContext(context.Background(), input)
+}
+
+// LexContext creates a new scanner for the input string that stops when
+// ctx is cancelled: the items end early, as if the input did.
+func LexContext(ctx context.Context, input string) *Lexer {
+	return Tokenizer{}.LexContext(ctx, input)
+}
+
+func newLexer(ctx context.Context, input string, r io.Reader, tok Tokenizer) *Lexer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Lexer{
+		ctx:    ctx,
+		cancel: cancel,
+		input:  input,
+		line:   textStart,
+		state:  lexArticle,
+		out:    make([]Item, 0, batchSize),
+		items:  make(chan []Item, 1),
+		free:   make(chan []Item, 2),
+		reader: r,
+		tok:    tok,
+	}
+}
+
+// LexReader creates a new scanner for the input read from r. Unlike
+// Lex, it does not need the whole input in memory: it keeps readSize
+// bytes buffered ahead of the current item, and only comments, raw
+// elements like <math>, words and other items longer than that grow the
+// buffer. The values of the items share the buffer they were lexed from.
+func LexReader(r io.Reader) *Lexer {
+	return LexReaderContext(context.Background(), r)
+}
+
+// LexReaderContext is like LexReader but stops when ctx is cancelled,
+// like LexContext.
+func LexReaderContext(ctx context.Context, r io.Reader) *Lexer {
+	return Tokenizer{}.LexReaderContext(ctx, r)
+}
+
+// Err returns the error reading the input of a Lexer made by LexReader,
+// once its items have ended; nil at the end of the input.
+func (l *Lexer) Err() error {
+	<-l.ctx.Done()
+	return l.err
+}
+
+// run runs the state machine for the lexer
+func (l *Lexer) run() {
+	l.lex(true)
+	if len(l.out) > 0 {
+		l.flush()
+	}
+	close(l.items)
+	l.cancel()
+}
+
+// lex runs the state machine until the end of the input or until the
+// context is cancelled, passing the items on in batches if flushing, or
+// else collecting them all in out.
+func (l *Lexer) lex(flushing bool) {
+	n := 0
+	for l.state = lexArticle; l.state != nil; n++ {
+		if n%checkInterval == 0 && l.ctx.Err() != nil {
+			return
+		}
+		if flushing && len(l.out) >= batchSize {
+			l.flush()
+		}
+		l.fill(readSize)
+		l.state = l.state(l)
+	}
+}
+
+// lexAll returns the items of the input string, without a goroutine.
+func lexAll(ctx context.Context, input string, items []Item, tok Tokenizer) []Item {
+	l := newLexer(ctx, input, nil, tok)
+	l.out = items
+	l.lex(false)
+	l.cancel()
+	return l.out
+}
+
+// flush passes the scanned items on to the client and takes a new batch,
+// reusing one the client is done with.
+func (l *Lexer) flush() {
+	select {
+	case l.items <- l.out:
+	case <-l.ctx.Done():
+	}
+	select {
+	case l.out = <-l.free:
+		l.out = l.out[:0]
+	default:
+		l.out = make([]Item, 0, batchSize)
+	}
+}
+
+// NextItem returns the next item from the input. After the end of the
+// input, it keeps returning ItemEOF.
+func (l *Lexer) NextItem() Item {
+	for l.taken == len(l.batch) {
+		if l.batch != nil {
+			select {
+			case l.free <- l.batch:
+			default:
+			}
+		}
+		batch, ok := <-l.items
+		if !ok {
+			l.batch, l.taken = nil, 0
+			return Item{Type: ItemEOF}
+		}
+		l.batch, l.taken = batch, 0
+	}
+	l.taken++
+	return l.batch[l.taken-1]
+}
+
+// Items returns an iterator over the remaining items of the input, the
+// ItemEOF excluded, as an alternative to calling NextItem:
+//
+//	for s := range wikitext.Lex(text).Items() {
+//		...
+//	}
+//
+// After the loop is left early, the lexer is stopped.
+func (l *Lexer) Items() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		for s := l.NextItem(); s.Type != ItemEOF; s = l.NextItem() {
+			if !yield(s) {
+				l.Stop()
+				return
+			}
+		}
+	}
+}
+
+// Stop stops the lexer, whose goroutine exits without lexing the rest of
+// the input. NextItem returns ItemEOF after the items already lexed.
+func (l *Lexer) Stop() {
+	l.cancel()
+}
+
+// send passes an item to the client, with the next batch.
+func (l *Lexer) send(s Item) {
+	l.out = append(l.out, s)
+}
+
+// errorf passes an error item to the client, before the item with the
+// problem, which is lexed on. The error wraps kind and is positioned at
+// the start of the item.
+func (l *Lexer) errorf(kind error, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.send(Item{
+		ItemError,
+		msg,
+		&SyntaxError{kind, l.line, msg},
+		l.line,
+		l.line,
+	})
+}
+
+// fill reads from the reader of the lexer until at least n bytes of the
+// input are buffered after pos, or the input ends. It drops the input
+// before the current item but for the byte before it, which atLineStart
+// and urlAt look at. It returns false if nothing was read.
+func (l *Lexer) fill(n int) bool {
+	if l.reader == nil || len(l.input)-l.pos >= n {
+		return false
+	}
+	drop := max(l.start-1, 0)
+	var b strings.Builder
+	b.Grow(len(l.input) - drop + max(n, readSize))
+	b.WriteString(l.input[drop:])
+	buf := make([]byte, readSize)
+	read := false
+	for b.Len()-(l.pos-drop) < n {
+		m, err := l.reader.Read(buf)
+		b.Write(buf[:m])
+		read = read || m > 0
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.reader = nil
+			break
+		}
+	}
+	l.input = b.String()
+	l.start, l.pos = l.start-drop, l.pos-drop
+	return read
+}
+
+// more reads more of the input into the buffer, for items longer than
+// it. It returns false at the end of the input.
+func (l *Lexer) more() bool {
+	return l.fill(len(l.input) - l.pos + readSize)
+}
+
+// index returns the index of the first instance of sub in the input
+// after pos, reading more of the input until it is found, or -1. With
+// fold, ASCII case is ignored.
+func (l *Lexer) index(sub string, fold bool) int {
+	from := l.pos
+	for {
+		var i int
+		if fold {
+			i = indexFold(l.input[from:], sub)
+		} else {
+			i = strings.Index(l.input[from:], sub)
+		}
+		if i >= 0 {
+			return from + i - l.pos
+		}
+		// Go on where sub could have started.
+		from = max(len(l.input)-len(sub)+1, l.pos)
+		pos := l.pos
+		if !l.more() {
+			return -1
+		}
+		from -= pos - l.pos
+	}
+}
+
+// next returns the next rune in the input
+func (l *Lexer) next() rune {
+	if len(l.input)-l.pos < utf8.UTFMax {
+		l.fill(utf8.UTFMax)
+	}
+	if int(l.pos) >= len(l.input) {
+		l.width = 0
+		return eof
+	}
+	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.width = w
+	l.pos += l.width
+	return r
+}
+
+// ignore skips over the pending input before this point.
+func (l *Lexer) ignore() {
+	l.line = l.line.advance(l.input[l.start:l.pos])
+	l.start = l.pos
+}
+
+// backup steps back one rune. Can be called only once per call of next.
+func (l *Lexer) backup() {
+	l.pos -= l.width
+}
+
+// emit passes an item to the client.
+func (l *Lexer) emit(t ItemType) {
+	end := l.line.advance(l.input[l.start:l.pos])
+	l.send(Item{t, l.input[l.start:l.pos], nil, l.line, end})
+	l.start, l.line = l.pos, end
+}
+
+// peek returns but does not consume the next rune in the input.
+func (l *Lexer) peek() rune {
+	rune := l.next()
+	l.backup()
+	return rune
+}
+
+func (l *Lexer) accept(valid string) bool {
+	if strings.IndexRune(valid, l.next()) >= 0 {
+		return true
+	}
+	l.backup()
+	return false
+}
+
+func (l *Lexer) acceptRun(valid string) {
+	for strings.IndexRune(valid, l.next()) >= 0 {
+	}
+	l.backup()
+}
+
+const digits = "0123456789"
+
+func isAlphaNumeric(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// lexNumber scans a number like "42", "3.14" or "1,000", with
+// Tokenizer.Numbers. One digit has already been seen. A number followed
+// by letters, like "3rd", is a word.
+func lexNumber(l *Lexer) stateFn {
+	for {
+		l.acceptRun(digits)
+		// A separator is part of the number if a digit follows it. Reading
+		// may move the input, but not the item.
+		n := l.pos - l.start
+		if !l.accept(".,") || !l.accept(digits) {
+			l.pos = l.start + n
+			break
+		}
+	}
+	if isAlphaNumeric(l.peek()) && l.tok.Segment {
+		return lexSegment
+	} else if isAlphaNumeric(l.peek()) {
+		return lexWord
+	}
+	l.emit(ItemNumber)
+	return lexArticle
+}
+
+func lexArticle(l *Lexer) stateFn {
+	switch r := l.next(); {
+	case r == eof:
+		// Line breaks and other characters no item starts with join the
+		// item after them; at the end, they are an item of their own.
+		if l.pos > l.start {
+			l.emit(ItemSpace)
+		}
+		l.emit(ItemEOF)
+		return nil
+	case r == '{' && l.peek() == '{':
+		l.next()
+		l.emit(ItemLeftMeta)
+		return lexArticle
+	case r == '}' && l.peek() == '}':
+		l.next()
+		l.emit(ItemRightMeta)
+		return lexArticle
+	case r == '[' && l.peek() == '[':
+		l.next()
+		l.emit(ItemLeftTag)
+		return lexArticle
+	case r == ']' && l.peek() == ']':
+		l.next()
+		l.emit(ItemRightTag)
+		return lexArticle
+	case r == '\'':
+		return lexQuote
+	case r == '<':
+		l.backup()
+		return lexXML
+	case r == '&' && entityLen(l.input[l.pos-l.width:]) > 0:
+		return lexEntity
+	case r == '=':
+		return lexTitle
+	case r == '_' && switchLen(l.input[l.pos-l.width:]) > 0:
+		return lexSwitch
+	case strings.ContainsRune(listMarkers, r) && l.atLineStart():
+		return lexList
+	case isSpace(r):
+		return lexSpace
+	case (isAlphaNumeric(r) || r == '/') && l.atURL():
+		return lexURL
+	case unicode.IsMark(r) || unicode.IsSymbol(r) || unicode.IsPunct(r):
+		l.emit(ItemMark)
+		return lexArticle
+	case l.tok.Numbers && strings.ContainsRune(digits, r):
+		return lexNumber
+	case l.tok.Segment && l.tok.wordStart(r):
+		return lexSegment
+	case isAlphaNumeric(r):
+		return lexWord
+	}
+	return lexArticle
+}
+
+// lexSpace scans a run of space characters. One space has already been seen.
+func lexSpace(l *Lexer) stateFn {
+	for isSpace(l.peek()) {
+		l.next()
+	}
+	l.emit(ItemSpace)
+	return lexArticle
+}
+
+func lexWord(l *Lexer) stateFn {
+	for {
+		// Peeking moves the width backup steps back by, and reading may
+		// move the input, but not the item.
+		n := l.pos - l.start
+		r := l.next()
+		// Two apostrophes start bold or italic text rather than continue
+		// the word.
+		if !isAlphaNumeric(r) && (r != '-' || l.tok.SplitHyphens) && (r != '\'' || l.tok.SplitApostrophes || l.peek() == '\'') {
+			l.pos = l.start + n
+			l.emit(ItemWord)
+			return lexArticle
+		}
+	}
+}
+
+func lexQuote(l *Lexer) stateFn {
+	for l.peek() == '\'' {
+		l.next()
+	}
+	l.emit(ItemQuote)
+	return lexArticle
+}
+
+// The URL schemes of external links. Protocol relative URLs starting
+// with "//" are only recognized in brackets.
+var urlSchemes = []string{
+	"http://", "https://", "ftp://", "ftps://", "sftp://", "git://", "svn://",
+	"irc://", "ircs://", "gopher://", "telnet://", "nntp://", "news:", "mailto:",
+	"urn:", "//",
+}
+
+// urlEnd returns the length of the URL at the start of s, or 0. A URL ends
+// before white space, control characters, brackets, quotes and the
+// markup of templates and formatting.
+func urlEnd(s string) int {
+	// All schemes but "//" have their ':' within the first 8 bytes; most
+	// words have none, which is quicker to rule out than the schemes.
+	if strings.IndexByte(s[:min(len(s), 8)], ':') < 0 && !strings.HasPrefix(s, "//") {
+		return 0
+	}
+	scheme := ""
+	for _, p := range urlSchemes {
+		if len(s) >= len(p) && strings.EqualFold(s[:len(p)], p) {
+			scheme = p
+			break
+		}
+	}
+	if scheme == "" {
+		return 0
+	}
+	n := len(scheme)
+	for n < len(s) {
+		r, w := utf8.DecodeRuneInString(s[n:])
+		if r <= ' ' || r == 0x7f || unicode.Is(unicode.Zs, r) || strings.ContainsRune("[]<>\"|", r) ||
+			strings.HasPrefix(s[n:], "{{") || strings.HasPrefix(s[n:], "}}") || strings.HasPrefix(s[n:], "''") {
+			break
+		}
+		n += w
+	}
+	if n == len(scheme) {
+		return 0
+	}
+	return n
+}
+
+// urlAt returns the length of the URL at input[p:], or 0. Outside of
+// brackets, trailing punctuation is not part of the URL, nor is a closing
+// parenthesis if the URL has no opening one, and protocol relative URLs
+// are not recognized.
+func urlAt(input string, p int) int {
+	n := urlEnd(input[p:])
+	if p > 0 && input[p-1] == '[' || n == 0 {
+		return n
+	}
+	if strings.HasPrefix(input[p:], "//") {
+		return 0
+	}
+	for ; n > 0; n-- {
+		c := input[p+n-1]
+		if strings.IndexByte(",;.:!?", c) < 0 && (c != ')' || strings.Contains(input[p:p+n], "(")) {
+			break
+		}
+	}
+	if urlEnd(input[p:p+n]) != n {
+		return 0
+	}
+	return n
+}
+
+// atURL reports whether the rune just read starts a URL.
+func (l *Lexer) atURL() bool {
+	return urlAt(l.input, l.pos-l.width) > 0
+}
+
+// lexURL scans a URL. Its first rune has already been seen.
+func lexURL(l *Lexer) stateFn {
+	p := l.pos - l.width
+	l.pos = p + urlAt(l.input, p)
+	l.emit(ItemURL)
+	return lexArticle
+}
+
+// entityLen returns the length of the named, decimal or hexadecimal
+// character reference at the start of s, like "&nbsp;", "&#8211;" or
+// "&#x2013;", or 0.
+func entityLen(s string) int {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	isHex := func(c byte) bool { return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' }
+	isLetter := func(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+	run := func(i int, valid func(byte) bool) int {
+		for i < len(s) && valid(s[i]) {
+			i++
+		}
+		return i
+	}
+	var end int
+	switch {
+	case len(s) > 2 && s[0] == '&' && isLetter(s[1]):
+		end = run(2, func(c byte) bool { return isLetter(c) || isDigit(c) })
+	case len(s) > 2 && s[0] == '&' && s[1] == '#' && isDigit(s[2]):
+		end = run(3, isDigit)
+	case len(s) > 3 && s[0] == '&' && s[1] == '#' && (s[2] == 'x' || s[2] == 'X') && isHex(s[3]):
+		end = run(4, isHex)
+	default:
+		return 0
+	}
+	if end < len(s) && s[end] == ';' {
+		return end + 1
+	}
+	return 0
+}
+
+// behaviorSwitches are the names of the behavior switches like
+// "__NOTOC__", which change how MediaWiki renders a page rather than
+// being text.
+var behaviorSwitches = map[string]bool{
+	"NOTOC": true, "FORCETOC": true, "TOC": true, "NOEDITSECTION": true,
+	"NEWSECTIONLINK": true, "NONEWSECTIONLINK": true, "NOGALLERY": true,
+	"HIDDENCAT": true, "EXPECTUNUSEDCATEGORY": true, "NOCONTENTCONVERT": true,
+	"NOCC": true, "NOTITLECONVERT": true, "NOTC": true, "INDEX": true,
+	"NOINDEX": true, "STATICREDIRECT": true, "NOGLOBAL": true, "DISAMBIG": true,
+	"EXPECTUNUSEDTEMPLATE": true, "ARCHIVEDTALK": true, "NOTALK": true,
+}
+
+// switchLen returns the length of the behavior switch at the start of s,
+// like "__NOTOC__", or 0. The names are matched ignoring case, like
+// MediaWiki does.
+func switchLen(s string) int {
+	if !strings.HasPrefix(s, "__") {
+		return 0
+	}
+	end := strings.Index(s[2:min(len(s), 32)], "__")
+	if end < 1 || !behaviorSwitches[strings.ToUpper(s[2:2+end])] {
+		return 0
+	}
+	return end + 4
+}
+
+// lexSwitch scans a behavior switch. The first '_' has already been seen.
+func lexSwitch(l *Lexer) stateFn {
+	l.pos += switchLen(l.input[l.pos-l.width:]) - l.width
+	l.emit(ItemSwitch)
+	return lexArticle
+}
+
+// lexEntity scans a character reference. The '&' has already been seen.
+func lexEntity(l *Lexer) stateFn {
+	l.pos += entityLen(l.input[l.pos-l.width:]) - l.width
+	l.emit(ItemEntity)
+	return lexArticle
+}
+
+// The markers of bulleted, numbered and definition lists.
+const listMarkers = "*#;:"
+
+// atLineStart reports whether the rune just read starts a line.
+func (l *Lexer) atLineStart() bool {
+	p := l.pos - l.width
+	return p == 0 || l.input[p-1] == '\n'
+}
+
+// lexList scans the list markers at the start of a line. One marker has
+// already been seen.
+func lexList(l *Lexer) stateFn {
+	l.acceptRun(listMarkers)
+	l.emit(ItemList)
+	return lexArticle
+}
+
+// lexTitle scans a run of '='. It is an ItemTitle if it starts a line or
+// ends one, with only space and comments after it, as the runs around the
+// text of a heading do, and a mark otherwise, like the "=" of a template
+// parameter or of "a = b". The first '=' has already been seen.
+func lexTitle(l *Lexer) stateFn {
+	start := l.atLineStart()
+	for l.peek() == '=' {
+		l.next()
+	}
+	if start || atLineEnd(l.input[l.pos:]) {
+		l.emit(ItemTitle)
+	} else {
+		l.emit(ItemMark)
+	}
+	return lexArticle
+}
+
+// atLineEnd reports whether s starts with the end of a line, after space
+// and comments.
+func atLineEnd(s string) bool {
+	for len(s) > 0 && s[0] != '\n' {
+		switch {
+		case s[0] == ' ' || s[0] == '\t' || s[0] == '\r':
+			s = s[1:]
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				return false
+			}
+			s = s[end+3:]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Elements whose content is taken as is rather than lexed as wikitext.
+var rawElements = map[string]bool{
+	"nowiki":          true,
+	"pre":             true,
+	"math":            true,
+	"chem":            true,
+	"ce":              true,
+	"syntaxhighlight": true,
+	"source":          true,
+}
+
+// indexFold returns the index of the first instance of the ASCII string
+// sub in s, ignoring case, or -1.
+func indexFold(s string, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxTagLen bounds the length of an XML tag, so that the tags of a long
+// run of "<" without ">" are not each looked for to the end of the input.
+const maxTagLen = 2048
+
+// tagLen returns the length of the tag at the start of s, up to the first
+// ">" outside of a quoted attribute value, or 0 if there is no ">" or a
+// "<" comes first.
+func tagLen(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && strings.HasSuffix(strings.TrimRight(s[:i], " \t\n"), "="):
+			quote = c
+		case c == '>':
+			return i + 1
+		case c == '<':
+			return 0
+		}
+	}
+	return 0
+}
+
+// lexXML scans a single XML tag or comment. The '<' has not been consumed
+// yet. If the input does not parse as a tag (as in "1 < 2"), the '<' is
+// emitted as a plain mark and lexing continues after it. The content of
+// raw elements like <nowiki> is emitted as an ItemRaw up to the closing
+// tag, or the end of the input if there is none; so is the rest of the
+// input after a comment that is not closed.
+func lexXML(l *Lexer) stateFn {
+	if strings.HasPrefix(l.input[l.pos:], "<!--") {
+		l.pos += 4
+		end := l.index("-->", false)
+		if end < 0 {
+			l.pos = len(l.input)
+		} else {
+			l.pos += end + 3
+		}
+		l.emit(ItemComment)
+		return lexArticle
+	}
+	l.fill(maxTagLen)
+	n := tagLen(l.input[l.pos:min(l.pos+maxTagLen, len(l.input))])
+	reader := strings.NewReader(l.input[l.pos : l.pos+n])
+	u := reader.Len()
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	_, err := decoder.RawToken()
+	v := reader.Len()
+	if err != nil || u == v {
+		l.next()
+		l.emit(ItemMark)
+		return lexArticle
+	}
+	l.pos += u - v
+	tag, ok := parseTag(l.input[l.start:l.pos])
+	l.emit(ItemXML)
+	if ok && rawElements[tag.Name] && !tag.Closing && !tag.SelfClosing {
+		end := l.index("</"+tag.Name, true)
+		if end < 0 {
+			end = len(l.input) - l.pos
+		}
+		if end > 0 {
+			l.pos += end
+			l.emit(ItemRaw)
+		}
+	}
+	return lexArticle
+}
@@ -0,0 +1,65 @@
+package wikitext
+
+import "testing"
+
+// lexed is an item as the tests of the lexer expect it.
+type lexed struct {
+	Type ItemType
+	Val  string
+}
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []lexed
+	}{
+		{"link", "[[Moon|lunar]]", []lexed{
+			{ItemLeftTag, "[["}, {ItemWord, "Moon"}, {ItemMark, "|"}, {ItemWord, "lunar"}, {ItemRightTag, "]]"}}},
+		{"prefixed title", "[[CSI: Miami|x]]", []lexed{
+			{ItemLeftTag, "[["}, {ItemWord, "CSI"}, {ItemMark, ":"}, {ItemSpace, " "}, {ItemWord, "Miami"}, {ItemMark, "|"}, {ItemWord, "x"}, {ItemRightTag, "]]"}}},
+		{"template", "{{cite|a=b}}", []lexed{
+			{ItemLeftMeta, "{{"}, {ItemWord, "cite"}, {ItemMark, "|"}, {ItemWord, "a"}, {ItemMark, "="}, {ItemWord, "b"}, {ItemRightMeta, "}}"}}},
+		{"quotes", "'''bold''' ''it''", []lexed{
+			{ItemQuote, "'''"}, {ItemWord, "bold"}, {ItemQuote, "'''"}, {ItemSpace, " "}, {ItemQuote, "''"}, {ItemWord, "it"}, {ItemQuote, "''"}}},
+		{"heading", "== H ==\ntext", []lexed{
+			{ItemTitle, "=="}, {ItemSpace, " "}, {ItemWord, "H"}, {ItemSpace, " "}, {ItemTitle, "=="}, {ItemWord, "\ntext"}}},
+		{"lists", "* item\n# num", []lexed{
+			{ItemList, "*"}, {ItemSpace, " "}, {ItemWord, "item"}, {ItemList, "\n#"}, {ItemSpace, " "}, {ItemWord, "num"}}},
+		{"entity", "&nbsp;x", []lexed{{ItemEntity, "&nbsp;"}, {ItemWord, "x"}}},
+		{"url", "https://example.org/ y", []lexed{{ItemURL, "https://example.org/"}, {ItemSpace, " "}, {ItemWord, "y"}}},
+		{"switch", "__NOTOC__", []lexed{{ItemSwitch, "__NOTOC__"}}},
+		{"element", "<ref>unclosed", []lexed{{ItemXML, "<ref>"}, {ItemWord, "unclosed"}}},
+		{"unclosed comment", "<!-- open", []lexed{{ItemComment, "<!-- open"}}},
+		// A '<' that starts no tag is a mark, and the rest of the
+		// article is lexed as usual.
+		{"less than", "1 < 2", []lexed{
+			{ItemWord, "1"}, {ItemSpace, " "}, {ItemMark, "<"}, {ItemSpace, " "}, {ItemWord, "2"}}},
+		{"unclosed tag", "a <b c", []lexed{
+			{ItemWord, "a"}, {ItemSpace, " "}, {ItemMark, "<"}, {ItemWord, "b"}, {ItemSpace, " "}, {ItemWord, "c"}}},
+		{"unnamed closing tag", "x </ y", []lexed{
+			{ItemWord, "x"}, {ItemSpace, " "}, {ItemMark, "<"}, {ItemMark, "/"}, {ItemSpace, " "}, {ItemWord, "y"}}},
+		{"empty tag", "a<>b", []lexed{{ItemWord, "a"}, {ItemMark, "<"}, {ItemMark, ">"}, {ItemWord, "b"}}},
+		{"lone less than", "<", []lexed{{ItemMark, "<"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]lexed, 0, len(tt.want))
+			for s := range Lex(tt.input).Items() {
+				if s.Type == ItemError {
+					t.Errorf("error item %v", s.Err)
+					continue
+				}
+				got = append(got, lexed{s.Type, s.Val})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lex(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Lex(%q) item %d = %v, want %v", tt.input, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
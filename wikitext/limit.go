@@ -0,0 +1,105 @@
+// Bounding the length of the plain text of documents
+
+package wikitext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A TextLimit bounds the plain text of a document by its characters,
+// words or sentences; a zero field sets no bound. The text ends at the
+// last sentence within all bounds, or, if not even the first sentence
+// is, at the last word within them, or within the first word at the
+// bound of its characters. It is empty only if the headings before the
+// first paragraph leave no room. Headings count as characters and words
+// but not sentences, and the blank lines between paragraphs as two
+// characters.
+type TextLimit struct {
+	Chars     int
+	Words     int
+	Sentences int
+}
+
+// A budget is what is used of a TextLimit by the text so far.
+type budget struct {
+	limit                   TextLimit
+	chars, words, sentences int
+	full                    bool // set once a sentence did not fit
+}
+
+// unlimited reports whether the budget bounds nothing.
+func (b *budget) unlimited() bool {
+	return b.limit == TextLimit{}
+}
+
+// within reports whether the text so far and that much more fit.
+func (b *budget) within(chars, words, sentences int) bool {
+	l := b.limit
+	return (l.Chars == 0 || b.chars+chars <= l.Chars) &&
+		(l.Words == 0 || b.words+words <= l.Words) &&
+		(l.Sentences == 0 || b.sentences+sentences <= l.Sentences)
+}
+
+// take returns the sentences of the paragraph that fit after the
+// headings before it, each a paragraph of its own, and uses them up. It
+// returns "" if none do, and the budget is full once one does not.
+func (b *budget) take(headings []string, paragraph string) string {
+	if b.full {
+		return ""
+	}
+	chars, words := 0, 0
+	// separator is the length of the break before the next paragraph.
+	separator := 0
+	if b.chars > 0 {
+		separator = 2
+	}
+	for _, h := range headings {
+		chars += separator + utf8.RuneCountInString(h)
+		words += len(strings.Fields(h))
+		separator = 2
+	}
+	sentences := SplitSentences(paragraph)
+	// cost is the length of a sentence or word, after the break before
+	// the paragraph if first, or after a space.
+	cost := func(s string, first bool) int {
+		if first {
+			return separator + utf8.RuneCountInString(s)
+		}
+		return 1 + utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, s := range sentences {
+		c, w := cost(s, n == 0), len(strings.Fields(s))
+		if !b.within(chars+c, words+w, n+1) {
+			break
+		}
+		chars, words, n = chars+c, words+w, n+1
+	}
+	text, taken := strings.Join(sentences[:n], " "), n
+	if n < len(sentences) {
+		b.full = true
+		if n == 0 && b.chars == 0 {
+			// Not even the first sentence fits: its first words.
+			fields := strings.Fields(sentences[0])
+			for _, f := range fields {
+				c := cost(f, n == 0)
+				if !b.within(chars+c, words+1, 1) {
+					break
+				}
+				chars, words, n = chars+c, words+1, n+1
+			}
+			text, taken = strings.Join(fields[:n], " "), 1
+			if room := b.limit.Chars - chars - separator; n == 0 && len(fields) > 0 && room > 0 && b.within(chars+separator+room, words+1, 1) {
+				// Not even the first word fits: its first characters.
+				text = string([]rune(fields[0])[:room])
+				chars, words = chars+separator+room, words+1
+			}
+		}
+	}
+	if text == "" {
+		return ""
+	}
+	b.chars, b.words, b.sentences = b.chars+chars, b.words+words, b.sentences+taken
+	return text
+}
@@ -0,0 +1,202 @@
+// Extraction of the wiki links of a document
+
+package wikitext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A LinkClass tells what kind of page a link points to, by its namespace
+// or prefix.
+type LinkClass int
+
+const (
+	LinkArticle   LinkClass = iota // a page of the main namespace
+	LinkCategory                   // a category page, as in [[:Category:Foo]]
+	LinkFile                       // a file description page, as in [[:File:Foo.jpg]]
+	LinkMedia                      // a file itself, as in [[Media:Foo.jpg]]
+	LinkInterwiki                  // a page of another wiki or language
+	LinkSpecial                    // a special page like [[Special:Random]]
+	LinkNamespace                  // a page of another namespace, like Help: or Template:
+)
+
+var linkClassNames = map[LinkClass]string{
+	LinkArticle:   "article",
+	LinkCategory:  "category",
+	LinkFile:      "file",
+	LinkMedia:     "media",
+	LinkInterwiki: "interwiki",
+	LinkSpecial:   "special",
+	LinkNamespace: "namespace",
+}
+
+func (c LinkClass) String() string {
+	if name, ok := linkClassNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("LinkClass(%d)", int(c))
+}
+
+// ParseLinkClass returns the class with the given name, like "article".
+func ParseLinkClass(name string) (LinkClass, bool) {
+	for c, n := range linkClassNames {
+		if n == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// namespaceClass returns the class of links to pages of the namespace
+// with the given number.
+func namespaceClass(number int) LinkClass {
+	switch number {
+	case NamespaceMain:
+		return LinkArticle
+	case NamespaceCategory:
+		return LinkCategory
+	case NamespaceFile:
+		return LinkFile
+	case NamespaceMedia:
+		return LinkMedia
+	case NamespaceSpecial:
+		return LinkSpecial
+	}
+	return LinkNamespace
+}
+
+// Namespaces which look like interwiki prefixes but are local.
+var localNamespaces = map[string]bool{
+	"wp":  true,
+	"cat": true,
+}
+
+var interwikiProjects = map[string]bool{
+	"wikt": true, "wiktionary": true, "commons": true, "meta": true, "m": true,
+	"wikiquote": true, "q": true, "wikisource": true, "s": true,
+	"wikibooks": true, "b": true, "wikinews": true, "n": true,
+	"wikiversity": true, "v": true, "wikivoyage": true, "voy": true,
+	"wikispecies": true, "species": true, "wikidata": true, "d": true,
+	"mw": true, "simple": true, "foundation": true, "wmf": true,
+}
+
+// isInterwikiPrefix reports whether the lower case prefix before a colon
+// names another wiki: a sister project or a language of languageCodes.
+func isInterwikiPrefix(prefix string) bool {
+	if localNamespaces[prefix] {
+		return false
+	}
+	return interwikiProjects[prefix] || languageCodes[prefix]
+}
+
+// A Link is a wiki link [[Target#Section|Anchor]] found in an article.
+type Link struct {
+	Target    string // the linked page, empty for links within the page
+	Section   string // the section after '#', if any
+	Interwiki string // the interwiki or language prefix, if any
+	Anchor    string // the displayed text
+	Class     LinkClass
+	Start     int // byte offset of the "[[" in the text of its document
+	End       int // byte offset after the "]]"
+
+	// Navigation is set for the links in the navboxes of the document
+	// (see Navboxes) rather than its content.
+	Navigation bool
+}
+
+// parseLinkBody parses the text between "[[" and "]]". It returns false
+// for category assignments and embedded files, which are not links.
+// Prefixes that are no namespace of the site or other wiki, as in "Star
+// Wars: Episode I", are part of the title of an article.
+func parseLinkBody(site *Site, body string) (Link, bool) {
+	var link Link
+	target, anchor := body, ""
+	piped := false
+	if i := strings.Index(body, "|"); i >= 0 {
+		target, anchor, piped = body[:i], body[i+1:], true
+	}
+	target = strings.TrimSpace(target)
+	colon := strings.HasPrefix(target, ":")
+	target = strings.TrimPrefix(target, ":")
+	if i := strings.Index(target, ":"); i > 0 {
+		prefix := strings.ToLower(strings.TrimSpace(target[:i]))
+		number, isNamespace := site.Namespace(prefix)
+		switch {
+		case isNamespace && !colon && (number == NamespaceCategory || number == NamespaceFile):
+			return link, false
+		case isNamespace:
+			link.Class = namespaceClass(number)
+		case isInterwikiPrefix(prefix):
+			link.Interwiki, link.Class = prefix, LinkInterwiki
+			target = strings.TrimSpace(target[i+1:])
+		}
+	}
+	if !piped {
+		anchor = strings.TrimPrefix(strings.TrimSpace(body), ":")
+	} else if strings.TrimSpace(anchor) == "" {
+		// The pipe trick: [[Foo (band)|]] displays as "Foo (band)".
+		anchor = target
+	}
+	if i := strings.Index(target, "#"); i >= 0 {
+		link.Section = strings.TrimSpace(target[i+1:])
+		target = strings.TrimSpace(target[:i])
+	}
+	link.Target = target
+	link.Anchor = strings.Join(strings.Fields(anchor), " ")
+	return link, true
+}
+
+// scanLink reads the link starting after the "[[" at items[i], including
+// links nested in its anchor text. It appends all of them to links and
+// returns the text of the link and the index after its "]]".
+func scanLink(site *Site, items []Item, i int, links []Link) ([]Link, string, int) {
+	links, body, i := appendLink(site, items, i, links, make([]string, 0, 10))
+	return links, strings.Join(body, ""), i
+}
+
+// appendLink is scanLink appending the text of the link to body, so that
+// links nested without being closed, whose text is that of the rest of
+// the items, are not joined again for every level.
+func appendLink(site *Site, items []Item, i int, links []Link, body []string) ([]Link, []string, int) {
+	first := len(body)
+	start := markupStart(items[i-1]).Offset
+	for ; i < len(items); i++ {
+		switch items[i].Type {
+		case ItemRightTag:
+			link, ok := parseLinkBody(site, strings.Join(body[first:], ""))
+			if ok {
+				link.Start, link.End = start, items[i].End.Offset
+				links = append(links, link)
+			}
+			return links, append(body[:first], link.Anchor), i + 1
+		case ItemLeftTag:
+			links, body, i = appendLink(site, items, i+1, links, body)
+			i--
+		default:
+			body = append(body, items[i].Val)
+		}
+	}
+	return links, body, i
+}
+
+// Links returns all wiki links of the document in order of their end,
+// so links nested in the caption of an image come before it, classified
+// by the kind of page they point to. Category assignments and embedded
+// files are not links. The links of its navboxes are marked Navigation.
+func Links(doc *Document) []Link {
+	links := make([]Link, 0, 10)
+	for i := 0; i < len(doc.Items); {
+		if doc.Items[i].Type == ItemLeftTag {
+			links, _, i = scanLink(doc.siteOf(), doc.Items, i+1, links)
+		} else {
+			i++
+		}
+	}
+	if navboxes := navboxSpans(doc.Items); len(navboxes) > 0 {
+		for k := range links {
+			links[k].Navigation = inNavbox(navboxes, links[k])
+		}
+	}
+	return links
+}
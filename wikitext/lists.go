@@ -0,0 +1,156 @@
+// Bulleted, numbered and definition lists of articles
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// ListKind identifies the kind of a list item by its last marker.
+type ListKind int
+
+const (
+	Bulleted   ListKind = iota // "*"
+	Numbered                   // "#"
+	Term                       // ";", the term of a definition list
+	Definition                 // ":", a definition or an indented line
+)
+
+// A ListItem is a line of a list, started by list markers like "*" or
+// "#:". Its nesting level is the number of markers.
+type ListItem struct {
+	Markers  string     // the list markers at the start of the line
+	Start    int        // byte offset in the document text after the markers
+	End      int        // byte offset of the end of the line
+	Children []ListItem // the items of lists nested in this item
+}
+
+// Level returns the nesting level of the item, 1 for a top level item.
+func (li ListItem) Level() int {
+	return len(li.Markers)
+}
+
+// Kind returns the kind of the item, given by its last marker.
+func (li ListItem) Kind() ListKind {
+	switch li.Markers[len(li.Markers)-1] {
+	case '#':
+		return Numbered
+	case ';':
+		return Term
+	case ':':
+		return Definition
+	}
+	return Bulleted
+}
+
+// Text returns the wikitext of the item, without its markers and nested
+// items.
+func (li ListItem) Text(doc *Document) string {
+	return strings.TrimSpace(doc.Text[li.Start:li.End])
+}
+
+// A List is a run of list items on consecutive lines.
+type List struct {
+	Start int // byte offset in the document text of the first marker
+	End   int // byte offset of the end of the last line
+	Items []ListItem
+}
+
+// A line of a list found in the items of a document.
+type listLine struct {
+	markers string
+	start   int // offset of the markers
+	text    int // offset after the markers
+	end     int // offset of the end of the line
+}
+
+// findListLines returns the lines of the document starting with list
+// markers outside of templates and links. A line ends at the first line
+// break outside of the templates and links it contains.
+func findListLines(doc *Document) []listLine {
+	lines := make([]listLine, 0, 10)
+	offsets := itemOffsets(doc.Items)
+	depth := 0
+	for i := 0; i < len(doc.Items); i++ {
+		s := doc.Items[i]
+		switch s.Type {
+		case ItemLeftMeta, ItemLeftTag:
+			depth++
+		case ItemRightMeta, ItemRightTag:
+			if depth > 0 {
+				depth--
+			}
+		case ItemList:
+			if depth > 0 {
+				continue
+			}
+			markers := strings.TrimSpace(s.Val)
+			line := listLine{markers, offsets[i+1] - len(markers), offsets[i+1], len(doc.Text)}
+			d := 0
+			j := i + 1
+			for ; j < len(doc.Items); j++ {
+				t := doc.Items[j]
+				if n := strings.IndexByte(t.Val, '\n'); n >= 0 && d == 0 {
+					line.end = offsets[j] + n
+					break
+				}
+				switch t.Type {
+				case ItemLeftMeta, ItemLeftTag:
+					d++
+				case ItemRightMeta, ItemRightTag:
+					if d > 0 {
+						d--
+					}
+				}
+			}
+			lines = append(lines, line)
+			i = j - 1
+		}
+	}
+	return lines
+}
+
+// sameList reports whether lines started by the markers a and b belong
+// to the same list: terms and definitions form one list.
+func sameList(a byte, b byte) bool {
+	if a == ':' {
+		a = ';'
+	}
+	if b == ':' {
+		b = ';'
+	}
+	return a == b
+}
+
+// buildListItems nests the lines[i:] whose markers extend prefix and
+// returns them and the index of the first line not nested.
+func buildListItems(lines []listLine, i int, prefix string) ([]ListItem, int) {
+	items := make([]ListItem, 0, 4)
+	for i < len(lines) && len(lines[i].markers) > len(prefix) && strings.HasPrefix(lines[i].markers, prefix) {
+		l := lines[i]
+		item := ListItem{Markers: l.markers, Start: l.text, End: l.end}
+		item.Children, i = buildListItems(lines, i+1, l.markers)
+		items = append(items, item)
+	}
+	return items, i
+}
+
+// Lists returns the lists of the document with their items nested by
+// level. Lines that follow each other but start with different kinds of
+// markers, as "*" and "#", form separate lists.
+func Lists(doc *Document) []List {
+	lines := findListLines(doc)
+	lists := make([]List, 0, 4)
+	for i := 0; i < len(lines); {
+		// The lines of one list are separated by exactly one line break.
+		j := i + 1
+		for j < len(lines) && lines[j].start == lines[j-1].end+1 && sameList(lines[j].markers[0], lines[i].markers[0]) {
+			j++
+		}
+		list := List{Start: lines[i].start, End: lines[j-1].end}
+		list.Items, _ = buildListItems(lines[:j], i, "")
+		lists = append(lists, list)
+		i = j
+	}
+	return lists
+}
@@ -0,0 +1,107 @@
+// Behavior switches like __NOTOC__ and magic words like {{PAGENAME}} and
+// {{DEFAULTSORT:...}}
+
+package wikitext
+
+import (
+	"sort"
+	"strings"
+)
+
+// variables are the magic words that stand for a value of the page or the
+// site, like {{PAGENAME}}. They may take an argument after a colon, like
+// {{PAGENAME:Foo}}.
+var variables = map[string]bool{
+	"PAGENAME": true, "PAGENAMEE": true, "FULLPAGENAME": true, "FULLPAGENAMEE": true,
+	"BASEPAGENAME": true, "ROOTPAGENAME": true, "SUBPAGENAME": true, "TALKPAGENAME": true,
+	"NAMESPACE": true, "NAMESPACENUMBER": true, "TALKSPACE": true, "SUBJECTSPACE": true,
+	"SITENAME": true, "SERVER": true, "SERVERNAME": true, "CURRENTVERSION": true,
+	"CURRENTYEAR": true, "CURRENTMONTH": true, "CURRENTMONTH1": true, "CURRENTMONTH2": true,
+	"CURRENTMONTHNAME": true, "CURRENTMONTHABBREV": true, "CURRENTDAY": true,
+	"CURRENTDAY2": true, "CURRENTDAYNAME": true, "CURRENTTIME": true, "CURRENTHOUR": true,
+	"CURRENTTIMESTAMP": true, "LOCALYEAR": true, "LOCALMONTH": true, "LOCALDAY": true,
+	"LOCALTIME": true, "LOCALTIMESTAMP": true, "REVISIONID": true, "REVISIONYEAR": true,
+	"REVISIONUSER": true, "PAGEID": true, "NUMBEROFARTICLES": true, "NUMBEROFPAGES": true,
+	"NUMBEROFFILES": true, "NUMBEROFUSERS": true, "NUMBEROFEDITS": true, "PAGESIZE": true,
+	"PAGESINCATEGORY": true, "!": true,
+}
+
+// pageSettings are the magic words that set a property of the page, like
+// {{DEFAULTSORT:Doe, John}}, taking the value after the colon.
+var pageSettings = map[string]bool{
+	"DISPLAYTITLE": true, "DEFAULTSORT": true, "DEFAULTSORTKEY": true,
+	"DEFAULTCATEGORYSORT": true,
+}
+
+// formattingFunctions are the magic words that format their argument,
+// like {{lc:...}} or {{formatnum:...}}, whose names ignore case.
+var formattingFunctions = map[string]bool{
+	"lc": true, "uc": true, "lcfirst": true, "ucfirst": true, "formatnum": true,
+	"padleft": true, "padright": true, "urlencode": true, "anchorencode": true,
+	"ns": true, "fullurl": true, "localurl": true, "plural": true, "grammar": true,
+	"gender": true, "int": true,
+}
+
+// A MagicWord is a behavior switch like __NOTOC__ or a magic word in
+// braces like {{PAGENAME}} or {{DEFAULTSORT:Doe, John}} in a document.
+// Parser functions like {{#if:}} are not magic words.
+type MagicWord struct {
+	Name   string // like "NOTOC", "PAGENAME" or "lc"; behavior switches in upper case
+	Value  string // the argument after the colon, like "Doe, John", without surrounding space
+	Switch bool   // whether it is a behavior switch
+	Start  int    // byte offset of the "__" or "{{" in the text of its document
+	End    int    // byte offset after the "__" or "}}"
+}
+
+// magicWordName returns the name of the magic word of a template whose
+// name, before the first "|", is name, and the argument after its colon,
+// or false if it is a template.
+func magicWordName(name string) (string, string, bool) {
+	word, value, _ := strings.Cut(name, ":")
+	word, value = strings.TrimSpace(word), strings.TrimSpace(value)
+	switch {
+	case variables[word] || pageSettings[word]:
+		return word, value, true
+	case formattingFunctions[strings.ToLower(word)] && strings.Contains(name, ":"):
+		return strings.ToLower(word), value, true
+	}
+	return "", "", false
+}
+
+// MagicWords returns the behavior switches and magic words of the
+// document in order, including the magic words nested in templates,
+// like the {{PAGENAME}} of {{Infobox|name={{PAGENAME}}}}. Magic words in
+// braces are among the Templates too.
+func MagicWords(doc *Document) []MagicWord {
+	words := make([]MagicWord, 0, 4)
+	open := make([]int, 0, 10) // the indices of the unclosed "{{"
+	for i, s := range doc.Items {
+		switch {
+		case s.Type == ItemSwitch:
+			words = append(words, MagicWord{
+				Name:   strings.ToUpper(strings.Trim(strings.TrimLeftFunc(s.Val, isBreakingSpace), "_")),
+				Switch: true,
+				Start:  markupStart(s).Offset,
+				End:    s.End.Offset,
+			})
+		case s.Type == ItemLeftMeta:
+			open = append(open, i)
+		case s.Type == ItemRightMeta && len(open) > 0:
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+			name := itemText(splitItems(doc.Items[start+1 : i])[0])
+			if word, value, ok := magicWordName(name); ok {
+				words = append(words, MagicWord{
+					Name:  word,
+					Value: value,
+					Start: markupStart(doc.Items[start]).Offset,
+					End:   s.End.Offset,
+				})
+			}
+		}
+	}
+	// Magic words in braces are found at their end, and nested ones end
+	// before those around them.
+	sort.SliceStable(words, func(i, j int) bool { return words[i].Start < words[j].Start })
+	return words
+}
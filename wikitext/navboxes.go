@@ -0,0 +1,166 @@
+// Detection of the navigational templates of a document, like navboxes
+// and succession boxes, apart from its content
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// A Navbox is a navigational box of a document: a navbox template like
+// {{Navbox|...}} or {{Apollo program navbox}}, a succession box from
+// {{S-start}} to {{S-end}}, or, in expanded wikitext, a table or element
+// of the class "navbox" or the role "navigation". Its links lead to the
+// other articles of a topic rather than being part of the content.
+type Navbox struct {
+	Name  string // the name of the template, or "S-start", "table" or the tag name of an element
+	Start int    // byte offset of its first markup in the text of its document
+	End   int    // byte offset after its last markup
+	Links []Link
+}
+
+// navboxNames are the names of the navigational templates of the English
+// Wikipedia, matched as the patterns of a StripList.
+var navboxNames = []string{
+	"Navbox", "Navbox *", "Navboxes", "* navbox", "*-navbox", "Succession box",
+	"S-start", "S-end", "Start box", "End box", "Portal bar", "Sidebar", "Sidebar *",
+}
+
+// IsNavboxName reports whether the template of the name, without the
+// namespace, is one of the navigational templates of the English
+// Wikipedia, like "Navbox" or "Apollo program navbox".
+func IsNavboxName(name string) bool {
+	return matchesAny(navboxNames, name)
+}
+
+// isNavboxClass reports whether the attributes mark a navigational box,
+// by the class "navbox" or the role "navigation".
+func isNavboxClass(class string, role string) bool {
+	return strings.EqualFold(role, "navigation") || strings.Contains(strings.ToLower(class), "navbox")
+}
+
+// navboxParams reports whether the parts of a template after its name
+// have the parameters of the templates of the Navbox family, list1 or
+// group1.
+func navboxParams(parts [][]Item) bool {
+	for _, part := range parts {
+		if name, _, named := splitParam(part); named {
+			if name = strings.TrimSpace(name); name == "list1" || name == "group1" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipNested returns the index after the closing tag of the element whose
+// start tag is at items[i], counting the elements of the name nested in
+// it, unlike skipElement.
+func skipNested(items []Item, i int, name string) int {
+	depth := 0
+	for ; i < len(items); i++ {
+		if items[i].Type != ItemXML {
+			continue
+		}
+		tag, ok := parseTag(items[i].Val)
+		if !ok || tag.Name != name || tag.SelfClosing {
+			continue
+		}
+		if !tag.Closing {
+			depth++
+		} else if depth--; depth == 0 {
+			return i + 1
+		}
+	}
+	return i
+}
+
+// navboxEnd returns the name of the navigational box starting at
+// items[i] and the index after its end, or false if none does.
+func navboxEnd(items []Item, i int) (string, int, bool) {
+	switch s := items[i]; {
+	case s.Type == ItemLeftMeta:
+		end, ok := closeTemplate(items, i)
+		if !ok {
+			return "", 0, false
+		}
+		parts := splitItems(items[i+1 : end-1])
+		name := strings.TrimSpace(itemText(parts[0]))
+		if strings.EqualFold(name, "s-start") {
+			// The succession box ends with its {{S-end}}.
+			for j := end; j < len(items); j++ {
+				if items[j].Type != ItemLeftMeta {
+					continue
+				}
+				next, ok := closeTemplate(items, j)
+				if !ok {
+					break
+				}
+				if n := strings.TrimSpace(itemText(splitItems(items[j+1 : next-1])[0])); strings.EqualFold(n, "s-end") {
+					return name, next, true
+				}
+				j = next - 1
+			}
+			return name, end, true
+		}
+		if IsNavboxName(name) || navboxParams(parts[1:]) {
+			return name, end, true
+		}
+	case isMark(items, i, "{") && isMark(items, i+1, "|"):
+		if i+2 < len(items) {
+			attrs, _, _ := strings.Cut(items[i+2].Val, "\n")
+			if isNavboxClass(attrs, "") {
+				return "table", skipTable(items, i), true
+			}
+		}
+	case s.Type == ItemXML:
+		tag, ok := parseTag(s.Val)
+		if ok && !tag.Closing && !tag.SelfClosing && isNavboxClass(tag.Attr["class"], tag.Attr["role"]) {
+			return tag.Name, skipNested(items, i, tag.Name), true
+		}
+	}
+	return "", 0, false
+}
+
+// navboxSpans returns the navigational boxes of the items in order,
+// without their links.
+func navboxSpans(items []Item) []Navbox {
+	navboxes := make([]Navbox, 0, 2)
+	for i := 0; i < len(items); i++ {
+		name, end, ok := navboxEnd(items, i)
+		if !ok {
+			continue
+		}
+		navboxes = append(navboxes, Navbox{Name: name, Start: markupStart(items[i]).Offset, End: items[end-1].End.Offset})
+		i = end - 1
+	}
+	return navboxes
+}
+
+// inNavbox reports whether the link is within one of the navboxes.
+func inNavbox(navboxes []Navbox, link Link) bool {
+	for _, n := range navboxes {
+		if link.Start >= n.Start && link.End <= n.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Navboxes returns the navigational boxes of the document in order, with
+// their links, which are those Links marks as Navigation.
+func Navboxes(doc *Document) []Navbox {
+	navboxes := navboxSpans(doc.Items)
+	if len(navboxes) == 0 {
+		return navboxes
+	}
+	for _, link := range Links(doc) {
+		for k := range navboxes {
+			if n := &navboxes[k]; link.Start >= n.Start && link.End <= n.End {
+				n.Links = append(n.Links, link)
+				break
+			}
+		}
+	}
+	return navboxes
+}
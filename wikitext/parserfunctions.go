@@ -0,0 +1,313 @@
+// Parser functions like {{#if:}} and magic words like {{PAGENAME}}
+
+package wikitext
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An ExpandOption configures Expand.
+type ExpandOption func(*expander)
+
+// ExpandTitle sets the title of the page expanded, for magic words like
+// {{PAGENAME}}.
+func ExpandTitle(title string) ExpandOption {
+	return func(e *expander) {
+		e.title = title
+	}
+}
+
+// ExpandTime sets the current time for magic words like {{CURRENTYEAR}}
+// and {{#time:}}. It defaults to the time Expand is called.
+func ExpandTime(t time.Time) ExpandOption {
+	return func(e *expander) {
+		e.now = t
+	}
+}
+
+// ExpandDepthExceeded sets a function called with the name of every
+// template in the store left unexpanded as it is nested deeper than the
+// maxDepth of Expand, like to count them.
+func ExpandDepthExceeded(f func(name string)) ExpandOption {
+	return func(e *expander) {
+		e.exceeded = f
+	}
+}
+
+// errorText formats an error like MediaWiki does in rendered pages.
+func errorText(msg string) string {
+	return `<strong class="error">` + msg + `</strong>`
+}
+
+var numeric = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// equalValues compares the values of {{#ifeq:}} and {{#switch:}}: as
+// numbers if both are numbers, so that "01" equals "1", else as strings.
+func equalValues(a string, b string) bool {
+	if numeric.MatchString(a) && numeric.MatchString(b) {
+		x, _ := strconv.ParseFloat(a, 64)
+		y, _ := strconv.ParseFloat(b, 64)
+		return x == y
+	}
+	return a == b
+}
+
+// argument expands the i-th argument of a parser function, counting the
+// one after the colon as 0, trimmed. Missing arguments are empty.
+func (e *expander) argument(n braceNode, i int, f *frame) string {
+	if i < 1 || i >= len(n.parts) {
+		return ""
+	}
+	return strings.TrimSpace(e.expandNodes(n.parts[i], f))
+}
+
+// parserFunction evaluates the parser function fn, like "#if", whose
+// first argument arg is given after the colon. Only the branches taken
+// are expanded. It returns false for unknown functions.
+func (e *expander) parserFunction(fn string, arg string, n braceNode, f *frame) (string, bool) {
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(fn) {
+	case "#if":
+		if arg != "" {
+			return e.argument(n, 1, f), true
+		}
+		return e.argument(n, 2, f), true
+	case "#ifeq":
+		if equalValues(arg, e.argument(n, 1, f)) {
+			return e.argument(n, 2, f), true
+		}
+		return e.argument(n, 3, f), true
+	case "#switch":
+		return e.switchCases(arg, n, f), true
+	case "#expr":
+		x, ok, err := evalExpr(arg)
+		if err != nil {
+			return errorText("Expression error: " + err.Error()), true
+		}
+		if !ok {
+			return "", true
+		}
+		return formatNumber(x), true
+	case "#ifexpr":
+		x, ok, err := evalExpr(arg)
+		if err != nil {
+			return errorText("Expression error: " + err.Error()), true
+		}
+		if ok && x != 0 {
+			return e.argument(n, 1, f), true
+		}
+		return e.argument(n, 2, f), true
+	case "#time":
+		t, ok := parseTime(e.argument(n, 1, f), e.now)
+		if !ok {
+			return errorText("Error: Invalid time."), true
+		}
+		return formatTime(arg, t), true
+	case "lc":
+		return strings.ToLower(arg), true
+	case "uc":
+		return strings.ToUpper(arg), true
+	case "lcfirst":
+		return mapFirst(unicode.ToLower, arg), true
+	case "ucfirst":
+		return mapFirst(unicode.ToUpper, arg), true
+	}
+	return "", false
+}
+
+// mapFirst maps the first letter of s.
+func mapFirst(mapping func(rune) rune, s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(mapping(r)) + s[size:]
+}
+
+// switchCases evaluates {{#switch:value|case=result|...|default}}. Cases
+// without a result fall through to the next result, a last argument
+// without "=" or the case "#default" gives the default.
+func (e *expander) switchCases(value string, n braceNode, f *frame) string {
+	matched := false
+	deflt := ""
+	for i, part := range n.parts[1:] {
+		name, result, hasResult := splitArgument(part)
+		if !hasResult {
+			c := strings.TrimSpace(e.expandNodes(part, f))
+			if i == len(n.parts)-2 {
+				return c
+			}
+			if equalValues(value, c) {
+				matched = true
+			}
+			continue
+		}
+		c := strings.TrimSpace(e.expandNodes(parseBraces(name), f))
+		if matched || equalValues(value, c) {
+			return strings.TrimSpace(e.expandNodes(result, f))
+		}
+		if c == "#default" {
+			deflt = strings.TrimSpace(e.expandNodes(result, f))
+		}
+	}
+	return deflt
+}
+
+// magicWord returns the value of a variable like {{PAGENAME}}, or false if
+// name is none.
+func (e *expander) magicWord(name string) (string, bool) {
+	number, page := e.store.site().Split(e.title)
+	namespace := e.store.site().Name(number)
+	now := e.now.UTC()
+	switch name {
+	case "PAGENAME":
+		return page, true
+	case "FULLPAGENAME":
+		return e.title, true
+	case "NAMESPACE":
+		return namespace, true
+	case "CURRENTYEAR":
+		return strconv.Itoa(now.Year()), true
+	case "CURRENTMONTH", "CURRENTMONTH2":
+		return now.Format("01"), true
+	case "CURRENTMONTH1":
+		return strconv.Itoa(int(now.Month())), true
+	case "CURRENTMONTHNAME":
+		return now.Month().String(), true
+	case "CURRENTMONTHABBREV":
+		return now.Format("Jan"), true
+	case "CURRENTDAY":
+		return strconv.Itoa(now.Day()), true
+	case "CURRENTDAY2":
+		return now.Format("02"), true
+	case "CURRENTDAYNAME":
+		return now.Weekday().String(), true
+	case "CURRENTTIME":
+		return now.Format("15:04"), true
+	case "CURRENTHOUR":
+		return now.Format("15"), true
+	case "CURRENTTIMESTAMP":
+		return now.Format("20060102150405"), true
+	}
+	return "", false
+}
+
+// The layouts of dates accepted by {{#time:}}, besides "now" and "@"
+// followed by a Unix time.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102150405",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006",
+	"January 2006",
+}
+
+// parseTime parses the date argument of {{#time:}}, the current time if
+// it is empty.
+func parseTime(s string, now time.Time) (time.Time, bool) {
+	switch {
+	case s == "" || strings.EqualFold(s, "now"):
+		return now.UTC(), true
+	case strings.HasPrefix(s, "@"):
+		sec, err := strconv.ParseInt(s[1:], 10, 64)
+		return time.Unix(sec, 0).UTC(), err == nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatTime formats the time like PHP's date(), as {{#time:}} does.
+// Text in double quotes and characters after a backslash are literal.
+func formatTime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch c {
+		case '\\':
+			if i+1 < len(format) {
+				i++
+				b.WriteByte(format[i])
+			}
+		case '"':
+			if end := strings.IndexByte(format[i+1:], '"'); end >= 0 {
+				b.WriteString(format[i+1 : i+1+end])
+				i += end + 1
+			} else {
+				b.WriteByte(c)
+			}
+		case 'Y':
+			b.WriteString(strconv.Itoa(t.Year()))
+		case 'y':
+			b.WriteString(t.Format("06"))
+		case 'L':
+			y := t.Year()
+			b.WriteString(formatNumber(boolValue(y%4 == 0 && (y%100 != 0 || y%400 == 0))))
+		case 'n':
+			b.WriteString(strconv.Itoa(int(t.Month())))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'F':
+			b.WriteString(t.Month().String())
+		case 'M':
+			b.WriteString(t.Format("Jan"))
+		case 'j':
+			b.WriteString(strconv.Itoa(t.Day()))
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'z':
+			b.WriteString(strconv.Itoa(t.YearDay() - 1))
+		case 'D':
+			b.WriteString(t.Format("Mon"))
+		case 'l':
+			b.WriteString(t.Weekday().String())
+		case 'N':
+			b.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case 'w':
+			b.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'W':
+			_, week := t.ISOWeek()
+			b.WriteString(strconv.Itoa(100 + week)[1:])
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'G':
+			b.WriteString(strconv.Itoa(t.Hour()))
+		case 'h':
+			b.WriteString(t.Format("03"))
+		case 'g':
+			b.WriteString(t.Format("3"))
+		case 'i':
+			b.WriteString(t.Format("04"))
+		case 's':
+			b.WriteString(t.Format("05"))
+		case 'A':
+			b.WriteString(t.Format("PM"))
+		case 'a':
+			b.WriteString(t.Format("pm"))
+		case 'U':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'c':
+			b.WriteString(t.Format("2006-01-02T15:04:05-07:00"))
+		case 'r':
+			b.WriteString(t.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
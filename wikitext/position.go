@@ -0,0 +1,71 @@
+// Positions in wikitext, of items, of the nodes extracted from them and
+// of syntax errors
+
+package wikitext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Pos is a position in wikitext. Lines and columns count from 1;
+// columns count bytes, like byte offsets.
+type Pos struct {
+	Offset int // byte offset, from 0
+	Line   int
+	Column int
+}
+
+// textStart is the position of the first byte of wikitext.
+var textStart = Pos{0, 1, 1}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// advance returns the position after text, which starts at p.
+func (p Pos) advance(text string) Pos {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return Pos{p.Offset + len(text), p.Line + strings.Count(text, "\n"), len(text) - i}
+	}
+	return Pos{p.Offset + len(text), p.Line, p.Column + len(text)}
+}
+
+// setPositions numbers the items from the start of the wikitext they
+// concatenate to.
+func setPositions(items []Item) {
+	p := textStart
+	for i := range items {
+		items[i].Start = p
+		if items[i].Type != ItemError {
+			p = p.advance(items[i].Val)
+		}
+		items[i].End = p
+	}
+}
+
+// Position returns the line and column of a byte offset in the text of
+// the document, like the Start of a Link. Offsets past the end are at
+// the end.
+func (doc *Document) Position(offset int) Pos {
+	// The last item starting at or before the offset.
+	i := sort.Search(len(doc.Items), func(i int) bool { return doc.Items[i].Start.Offset > offset }) - 1
+	if i < 0 {
+		return textStart.advance(doc.Text[:min(max(offset, 0), len(doc.Text))])
+	}
+	s := doc.Items[i]
+	if s.Type == ItemError {
+		return s.Start
+	}
+	return s.Start.advance(s.Val[:min(offset-s.Start.Offset, len(s.Val))])
+}
+
+// markupStart returns the position of the markup of an item, like the
+// "[[" of an ItemLeftTag: the value of an item starts with the line
+// breaks before it.
+func markupStart(s Item) Pos {
+	markup := strings.TrimLeftFunc(s.Val, unicode.IsSpace)
+	return s.Start.advance(s.Val[:len(s.Val)-len(markup)])
+}
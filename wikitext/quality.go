@@ -0,0 +1,54 @@
+// Heuristics of the quality of articles, to tell substantive articles
+// from stubs
+
+package wikitext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Quality holds the features of a document that tell how substantive its
+// article is.
+type Quality struct {
+	Stub       bool // it has a stub template, like {{Stub}} or {{Physics-stub}}
+	References int  // its references, without those reusing a named one
+	Sections   int  // its sections with a heading
+	Infobox    bool // it has an infobox, a template like {{Infobox person}}
+	Length     int  // the length of its plain text in characters
+}
+
+// isStubTemplate reports whether the template marks its article as a
+// stub, as the templates of the stub categories of Wikipedia are named.
+func isStubTemplate(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(name, "_", " ")))
+	return name == "stub" || strings.HasSuffix(name, "-stub") || strings.HasSuffix(name, " stub")
+}
+
+// IsInfobox reports whether the template of the given name is an
+// infobox, like "Infobox person".
+func IsInfobox(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(name, "_", " ")))
+	return name == "infobox" || strings.HasPrefix(name, "infobox ")
+}
+
+// Assess returns the quality features of the document. Only the templates
+// of the document itself count, not those nested in their parameters.
+func Assess(doc *Document) Quality {
+	q := Quality{References: len(Citations(doc)), Length: utf8.RuneCountInString(PlainText(doc))}
+	for _, t := range Templates(doc, 1) {
+		q.Stub = q.Stub || isStubTemplate(t.Name)
+		q.Infobox = q.Infobox || IsInfobox(t.Name)
+	}
+	var count func(sections []Section)
+	count = func(sections []Section) {
+		for _, s := range sections {
+			if s.Heading != "" {
+				q.Sections++
+			}
+			count(s.Children)
+		}
+	}
+	count(Sections(doc))
+	return q
+}
@@ -0,0 +1,230 @@
+// Rewriting documents: replacing, inserting and deleting the wikitext of
+// their templates, links and other nodes
+
+package wikitext
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Node is a part of a document that Rewrite visits: a Template, one of
+// its Params, a Link, a CategoryLink, an ExternalLink, a Media or the
+// MagicWord of a behavior switch.
+type Node interface {
+	span() (start int, end int)
+}
+
+func (t Template) span() (int, int)     { return t.Start, t.End }
+func (p Param) span() (int, int)        { return p.Start, p.End }
+func (l Link) span() (int, int)         { return l.Start, l.End }
+func (c CategoryLink) span() (int, int) { return c.Start, c.End }
+func (l ExternalLink) span() (int, int) { return l.Start, l.End }
+func (m Media) span() (int, int)        { return m.Start, m.End }
+func (m MagicWord) span() (int, int)    { return m.Start, m.End }
+
+// A Cursor is the node Rewrite visits, through which the visitor changes
+// the wikitext of the node.
+type Cursor struct {
+	doc     *Document
+	node    Node
+	parent  *Cursor
+	start   int
+	end     int
+	before  []string
+	after   []string
+	text    string
+	replace bool
+}
+
+// Node returns the node, whose byte offsets are those of the text of the
+// document given to Rewrite, also for templates nested in parameters.
+func (c *Cursor) Node() Node {
+	return c.node
+}
+
+// Parent returns the innermost node the node is part of, like the template
+// of a parameter, or nil.
+func (c *Cursor) Parent() Node {
+	if c.parent == nil {
+		return nil
+	}
+	return c.parent.node
+}
+
+// Text returns the wikitext of the node as it is in the document.
+func (c *Cursor) Text() string {
+	return c.doc.Text[c.start:c.end]
+}
+
+// Replace replaces the wikitext of the node. The nodes within it are not
+// visited.
+func (c *Cursor) Replace(text string) {
+	c.text, c.replace = text, true
+}
+
+// Delete removes the wikitext of the node. The nodes within it are not
+// visited.
+func (c *Cursor) Delete() {
+	c.Replace("")
+}
+
+// InsertBefore inserts text before the node.
+func (c *Cursor) InsertBefore(text string) {
+	c.before = append(c.before, text)
+}
+
+// InsertAfter inserts text after the node, and after the text inserted
+// after the nodes within it.
+func (c *Cursor) InsertAfter(text string) {
+	c.after = append(c.after, text)
+}
+
+// An edit replaces the text between two byte offsets.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Rewrite calls visit for the nodes of the document in the order of their
+// start, outer nodes before those within them, and returns the wikitext
+// with the changes visit made through the cursors; the text outside of
+// the changes stays as it was. The nodes are the templates, down to those
+// nested in parameters, their parameters, and the links, category links,
+// external links, images and behavior switches of the document; magic
+// words in braces are among the templates. Renaming a parameter of an
+// infobox, for example:
+//
+//	text := wikitext.Rewrite(doc, func(c *wikitext.Cursor) {
+//		p, ok := c.Node().(wikitext.Param)
+//		t, _ := c.Parent().(wikitext.Template)
+//		if ok && t.Name == "Infobox person" && p.Name == "birth_place" {
+//			c.Replace(strings.Replace(c.Text(), "birth_place", "place_of_birth", 1))
+//		}
+//	})
+func Rewrite(doc *Document, visit func(c *Cursor)) string {
+	cursors := make([]*Cursor, 0, 10)
+	add := func(n Node) {
+		start, end := n.span()
+		cursors = append(cursors, &Cursor{doc: doc, node: n, start: start, end: end})
+	}
+	var addTemplates func(templates []Template, shift int)
+	addTemplates = func(templates []Template, shift int) {
+		for _, t := range templates {
+			t.Start, t.End = t.Start+shift, t.End+shift
+			add(t)
+			for _, p := range t.Params {
+				p.Start, p.End = p.Start+shift, p.End+shift
+				add(p)
+				// The value is the wikitext of the parameter after the
+				// name, without the space around it.
+				part := strings.TrimRightFunc(doc.Text[p.Start:p.End], unicode.IsSpace)
+				addTemplates(Templates(p.Value, 1), p.Start+len(part)-len(p.Value.Text))
+			}
+		}
+	}
+	addTemplates(Templates(doc, 1), 0)
+	for _, l := range Links(doc) {
+		add(l)
+	}
+	for _, c := range Categories(doc) {
+		add(c)
+	}
+	for _, l := range ExternalLinks(doc) {
+		add(l)
+	}
+	for _, m := range Images(doc) {
+		add(m)
+	}
+	for _, m := range MagicWords(doc) {
+		if m.Switch {
+			add(m)
+		}
+	}
+	// Outer nodes come first; of nodes with the same span, the one added
+	// first, like a parameter before the link that is all of its value.
+	sort.SliceStable(cursors, func(i, j int) bool {
+		if cursors[i].start != cursors[j].start {
+			return cursors[i].start < cursors[j].start
+		}
+		return cursors[i].end > cursors[j].end
+	})
+
+	edits := make([]edit, 0, 10)
+	// done adds the edits after the node, once those within it are added.
+	done := func(c *Cursor) {
+		if c.replace {
+			edits = append(edits, edit{c.start, c.end, c.text})
+		}
+		for _, text := range c.after {
+			edits = append(edits, edit{c.end, c.end, text})
+		}
+	}
+	var open []*Cursor // the nodes the visited one is within
+	for _, c := range cursors {
+		for len(open) > 0 && !(open[len(open)-1].start <= c.start && c.end <= open[len(open)-1].end) {
+			done(open[len(open)-1])
+			open = open[:len(open)-1]
+		}
+		skip := false
+		for _, o := range open {
+			skip = skip || o.replace
+		}
+		if len(open) > 0 {
+			c.parent = open[len(open)-1]
+		}
+		if !skip {
+			visit(c)
+			for _, text := range c.before {
+				edits = append(edits, edit{c.start, c.start, text})
+			}
+		}
+		open = append(open, c)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		done(open[i])
+	}
+
+	// Insertions at the start of a replaced node come before it.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end == edits[i].start && edits[j].end != edits[j].start
+	})
+	var b strings.Builder
+	at := 0
+	for _, e := range edits {
+		if e.start < at {
+			// Within a node that was replaced.
+			continue
+		}
+		b.WriteString(doc.Text[at:e.start])
+		b.WriteString(e.text)
+		at = e.end
+	}
+	b.WriteString(doc.Text[at:])
+	return b.String()
+}
+
+// Wikitext returns the wikitext of the template, as in
+// {{Name|positional|name=value}}. The space and line breaks of the
+// original wikitext are not kept; Rewrite keeps them.
+func (t Template) Wikitext() string {
+	var b strings.Builder
+	b.WriteString("{{" + t.Name)
+	position := 1
+	for _, p := range t.Params {
+		b.WriteString("|")
+		if p.Name == strconv.Itoa(position) && !strings.Contains(p.Value.Text, "=") {
+			position++
+		} else {
+			b.WriteString(p.Name + "=")
+		}
+		b.WriteString(p.Value.Text)
+	}
+	b.WriteString("}}")
+	return b.String()
+}
@@ -0,0 +1,196 @@
+// The section structure of articles, built from their headings
+
+package wikitext
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Section is a part of an article started by a heading like
+// "== History ==". The lead section before the first heading has level 0
+// and no heading.
+type Section struct {
+	Level        int    // the number of '=' around the heading, of the shorter run if they differ
+	Heading      string // the text of the heading
+	Anchor       string // the id MediaWiki gives the heading, like "Early_life"
+	HeadingStart int    // byte offset in the document text where the heading starts, Start for the lead section
+	Start        int    // byte offset in the document text where the section body starts
+	End          int    // byte offset where the section ends, including its subsections
+	Children     []Section
+}
+
+// Text returns the wikitext of the section body, including subsections.
+func (s Section) Text(doc *Document) string {
+	return doc.Text[s.Start:s.End]
+}
+
+// bodyEnd returns the byte offset where the body of the section before
+// its first subsection ends: at the heading of the subsection, which is
+// not part of it.
+func (s Section) bodyEnd() int {
+	if len(s.Children) > 0 {
+		return s.Children[0].HeadingStart
+	}
+	return s.End
+}
+
+// A heading found in the items of a document.
+type heading struct {
+	level  int
+	text   string
+	anchor string
+	start  int // offset of the heading
+	end    int // offset after the heading
+}
+
+// anchorName returns the id of a heading rendered as plain text, as in
+// MediaWiki: runs of spaces and underscores become one underscore.
+func anchorName(text string) string {
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	}), "_")
+}
+
+// maxHeadingLevel is the deepest level of a heading, as in HTML.
+const maxHeadingLevel = 6
+
+// headingLevel returns the level of a heading between runs of open and
+// close '=', and the '=' of the longer run beyond the level, which are
+// part of its text, like MediaWiki does: "=== Title ==" is a heading of
+// level 2 with the text "= Title". A line of only '=' is a heading of its
+// middle ones, if there are more than two, with close 0: "=====" is a
+// heading of level 2 with the text "=".
+func headingLevel(open int, close int) (int, string, string) {
+	if close == 0 {
+		level := min((open-1)/2, maxHeadingLevel)
+		return level, strings.Repeat("=", open-2*level), ""
+	}
+	level := min(open, close, maxHeadingLevel)
+	return level, strings.Repeat("=", open-level), strings.Repeat("=", close-level)
+}
+
+// findHeadings returns the headings of the document. A heading is a run
+// of '=' starting a line outside of templates and links, followed on the
+// same line by its text and another run of '=' ending the line, or a line
+// of only '='; headingLevel tells its level. Like MediaWiki, a heading
+// whose anchor equals that of an earlier one, ignoring case, gets a
+// suffix like "_2".
+func findHeadings(doc *Document) []heading {
+	headings := make([]heading, 0, 10)
+	anchors := make(map[string]bool)
+	suffixes := make(map[string]int) // the last number appended to an anchor
+	offsets := itemOffsets(doc.Items)
+	depth := 0
+	for i := 0; i < len(doc.Items); i++ {
+		s := doc.Items[i]
+		switch s.Type {
+		case ItemLeftMeta, ItemLeftTag:
+			depth++
+		case ItemRightMeta, ItemRightTag:
+			if depth > 0 {
+				depth--
+			}
+		case ItemTitle:
+			if depth > 0 || markupStart(s).Column != 1 {
+				continue
+			}
+			j := i + 1
+			for ; j < len(doc.Items); j++ {
+				t := doc.Items[j]
+				if t.Type == ItemTitle || strings.Contains(t.Val, "\n") {
+					break
+				}
+			}
+			open, end := len(strings.TrimSpace(s.Val)), i
+			var inner []Item
+			switch {
+			case j < len(doc.Items) && doc.Items[j].Type == ItemTitle && !strings.Contains(doc.Items[j].Val, "\n"):
+				inner, end = doc.Items[i+1:j], j
+			case strings.TrimSpace(itemText(doc.Items[i+1:j])) != "" || open < 3:
+				continue
+			}
+			close := 0
+			if end > i {
+				close = len(doc.Items[end].Val)
+			}
+			level, before, after := headingLevel(open, close)
+			text := strings.TrimSpace(before + itemText(inner) + after)
+			if text == "" || level < 1 {
+				continue
+			}
+			rendered := before + strings.Join(renderText(doc.siteOf(), nil, inner), "") + after
+			anchor := anchorName(html.UnescapeString(rendered))
+			if key := strings.ToLower(anchor); anchors[key] {
+				n := max(suffixes[key]+1, 2)
+				for anchors[key+"_"+strconv.Itoa(n)] {
+					n++
+				}
+				suffixes[key] = n
+				anchor += "_" + strconv.Itoa(n)
+				anchors[key+"_"+strconv.Itoa(n)] = true
+			} else {
+				anchors[key] = true
+			}
+			headings = append(headings, heading{level, text, anchor, offsets[i], offsets[end+1]})
+			i = end
+		}
+	}
+	return headings
+}
+
+// buildSections nests the sections of headings[i:] that are deeper than
+// level and returns them and the index of the first heading not nested.
+func buildSections(headings []heading, i int, level int, end int) ([]Section, int) {
+	sections := make([]Section, 0, 4)
+	for i < len(headings) && headings[i].level > level {
+		h := headings[i]
+		s := Section{Level: h.level, Heading: h.text, Anchor: h.anchor, HeadingStart: h.start, Start: h.end}
+		s.Children, i = buildSections(headings, i+1, h.level, end)
+		s.End = end
+		if i < len(headings) {
+			s.End = headings[i].start
+		}
+		sections = append(sections, s)
+	}
+	return sections, i
+}
+
+// Sections returns the section tree of the document: the lead section
+// followed by the top level sections, with their subsections nested.
+func Sections(doc *Document) []Section {
+	headings := findHeadings(doc)
+	leadEnd := len(doc.Text)
+	if len(headings) > 0 {
+		leadEnd = headings[0].start
+	}
+	sections := []Section{{Start: 0, End: leadEnd}}
+	// Headings of a deeper level than their predecessors still start a
+	// top level section, as in MediaWiki's table of contents.
+	for i := 0; i < len(headings); {
+		var top []Section
+		top, i = buildSections(headings, i, headings[i].level-1, len(doc.Text))
+		sections = append(sections, top...)
+	}
+	return sections
+}
+
+func findSection(sections []Section, heading string) (Section, bool) {
+	for _, s := range sections {
+		if strings.EqualFold(s.Heading, heading) {
+			return s, true
+		}
+		if c, ok := findSection(s.Children, heading); ok {
+			return c, true
+		}
+	}
+	return Section{}, false
+}
+
+// Section returns the first section, at any level, whose heading equals
+// the given one ignoring case. The lead section has the empty heading.
+func (doc *Document) Section(heading string) (Section, bool) {
+	return findSection(Sections(doc), strings.TrimSpace(heading))
+}
@@ -0,0 +1,160 @@
+// Word segmentation by the word boundary rules of Unicode Standard Annex
+// #29, for Tokenizer.Segment
+
+package wikitext
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// A wordClass is the Word_Break property of a rune, as far as the rules
+// of the segmentation tell them apart.
+type wordClass int
+
+const (
+	wbOther        wordClass = iota
+	wbALetter                // letters of alphabets and syllabaries but Katakana
+	wbNumeric                // decimal digits
+	wbKatakana               // Katakana, whose runs are words
+	wbIdeographic            // Han ideographs and Hiragana, words of their own
+	wbExtendNumLet           // connector punctuation like "_"
+	wbExtend                 // marks, format characters and joiners
+	wbMidLetter              // within letters, like the hyphen
+	wbMidNum                 // within digits, like the comma
+	wbMidNumLet              // within either, like the full stop and the apostrophe
+)
+
+// midLetters, midNums and midNumLets are the runes of wbMidLetter,
+// wbMidNum and wbMidNumLet. The colon, which UAX #29 counts among
+// midLetters, is left out, as it separates the namespaces of titles.
+var (
+	midLetters = "\u00b7\u0387\u05f4\u2027\ufe13\ufe55\uff1a"
+	midNums    = ",;\u037e\u0589\u060c\u060d\u066c\u07f8\u2044\ufe10\ufe14\ufe50\ufe54\uff0c\uff1b"
+	midNumLets = ".\u2018\u2019\u2024\ufe52\uff07\uff0e"
+)
+
+// wordClass returns the class of r. Hyphens and apostrophes are within
+// words unless the tokenizer splits them.
+func (t Tokenizer) wordClass(r rune) wordClass {
+	switch {
+	case r == '-' && !t.SplitHyphens:
+		return wbMidLetter
+	case r == '\'' && !t.SplitApostrophes:
+		return wbMidNumLet
+	case r == '_' || unicode.Is(unicode.Pc, r):
+		return wbExtendNumLet
+	case r >= '0' && r <= '9' || unicode.IsDigit(r):
+		return wbNumeric
+	case unicode.Is(unicode.Katakana, r) || r == '\u30fc' || r >= '\u3031' && r <= '\u3035' || r == '\u309b' || r == '\u309c' || r == '\u30a0' || r == '\uff70':
+		return wbKatakana
+	case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r):
+		return wbIdeographic
+	case unicode.IsLetter(r):
+		return wbALetter
+	case unicode.IsMark(r) || unicode.Is(unicode.Cf, r):
+		return wbExtend
+	case r < utf8.RuneSelf && r != '.' && r != ',' && r != ';':
+		return wbOther
+	case containsRune(midLetters, r):
+		return wbMidLetter
+	case containsRune(midNums, r):
+		return wbMidNum
+	case containsRune(midNumLets, r):
+		return wbMidNumLet
+	}
+	return wbOther
+}
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+// joins reports whether no word boundary lies between runes of the
+// classes a and b (rules WB5, WB8 to WB10 and WB13 to WB13b).
+func joins(a, b wordClass) bool {
+	switch {
+	case (a == wbALetter || a == wbNumeric) && (b == wbALetter || b == wbNumeric):
+		return true
+	case a == wbKatakana && b == wbKatakana:
+		return true
+	case b == wbExtendNumLet:
+		return a == wbALetter || a == wbNumeric || a == wbKatakana || a == wbExtendNumLet
+	case a == wbExtendNumLet:
+		return b == wbALetter || b == wbNumeric || b == wbKatakana
+	}
+	return false
+}
+
+// bridges reports whether a rune of class mid between runes of the
+// classes a and b is within a word (rules WB6, WB7, WB11 and WB12).
+func bridges(a, mid, b wordClass) bool {
+	switch mid {
+	case wbMidLetter:
+		return a == wbALetter && b == wbALetter
+	case wbMidNum:
+		return a == wbNumeric && b == wbNumeric
+	case wbMidNumLet:
+		return a == b && (a == wbALetter || a == wbNumeric)
+	}
+	return false
+}
+
+// wordStart reports whether a word of the segmentation starts with r.
+func (t Tokenizer) wordStart(r rune) bool {
+	switch t.wordClass(r) {
+	case wbALetter, wbNumeric, wbKatakana, wbIdeographic:
+		return true
+	}
+	return false
+}
+
+// nextClass returns the class of the next rune that is no wbExtend,
+// without consuming any.
+func (l *Lexer) nextClass() wordClass {
+	n := l.pos - l.start
+	c := wbExtend
+	for c == wbExtend {
+		r := l.next()
+		if r == eof {
+			c = wbOther
+			break
+		}
+		c = l.tok.wordClass(r)
+	}
+	l.pos = l.start + n
+	return c
+}
+
+// lexSegment scans a word as segmented by UAX #29, with Tokenizer.Segment.
+// Its first runes have been seen. Marks and joiners stay with the rune
+// before them, ideographs are words of their own, and full stops, commas,
+// hyphens and apostrophes between letters or digits are within the word.
+func lexSegment(l *Lexer) stateFn {
+	last, _ := utf8.DecodeLastRuneInString(l.input[l.start:l.pos])
+	prev := l.tok.wordClass(last)
+	for {
+		// Reading may move the input, but not the item.
+		n := l.pos - l.start
+		r := l.next()
+		c := l.tok.wordClass(r)
+		switch {
+		case r == eof:
+		case c == wbExtend:
+			continue
+		case prev != wbIdeographic && joins(prev, c):
+			prev = c
+			continue
+		case prev != wbIdeographic && bridges(prev, c, l.nextClass()):
+			continue
+		}
+		l.pos = l.start + n
+		l.emit(ItemWord)
+		return lexArticle
+	}
+}
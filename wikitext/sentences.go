@@ -0,0 +1,163 @@
+// Segmentation of rendered text into sentences
+
+package wikitext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations are the lower case words that a full stop follows without
+// ending the sentence, as they usually precede a name, like "Dr.".
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "mt": true, "ft": true, "vs": true, "cf": true, "gen": true, "col": true,
+	"lt": true, "sgt": true, "capt": true, "rev": true, "hon": true,
+}
+
+// numberAbbreviations are the abbreviations that end no sentence before a
+// number, like "No. 5" or "c. 1600", but do before other words.
+var numberAbbreviations = map[string]bool{
+	"no": true, "nos": true, "vol": true, "fig": true, "p": true, "pp": true, "c": true,
+	"ca": true, "approx": true, "jan": true, "feb": true, "mar": true, "apr": true,
+	"jun": true, "jul": true, "aug": true, "sep": true, "sept": true, "oct": true,
+	"nov": true, "dec": true,
+}
+
+// A Sentence is a sentence of the plain text of a document.
+type Sentence struct {
+	Section   string // the anchor of its section, empty in the lead
+	Paragraph int    // the index of its paragraph in the section, from 0
+	Text      string
+}
+
+// Sentences returns the sentences of the paragraphs of the document as
+// rendered by PlainText, in document order, without the headings.
+func Sentences(doc *Document) []Sentence {
+	sentences := make([]Sentence, 0, 32)
+	sectionParagraphs(doc, func(s Section, body []string) {
+		for i, p := range body {
+			for _, text := range SplitSentences(p) {
+				sentences = append(sentences, Sentence{s.Anchor, i, text})
+			}
+		}
+	})
+	return sentences
+}
+
+// SplitSentences splits a paragraph of plain text into its sentences. A
+// sentence ends with a full stop, question or exclamation mark, and any
+// closing quotes and brackets and reference marks like "[1]" after it,
+// that is followed by space and an upper case letter, a digit, a quote or
+// an opening bracket. Marks within parentheses or brackets end no
+// sentence, and neither do the full stops of abbreviations, like "Dr.",
+// "e.g." or "U.S.", and of initials, like "J. R. R. Tolkien".
+func SplitSentences(paragraph string) []string {
+	sentences := make([]string, 0, 4)
+	inside := bracketed(paragraph)
+	start := 0
+	for i := 0; i < len(paragraph); i++ {
+		c := paragraph[i]
+		if c != '.' && c != '!' && c != '?' || inside[i] {
+			continue
+		}
+		end := sentenceEnd(paragraph, i+1)
+		next := end
+		for next < len(paragraph) && unicode.IsSpace(rune(paragraph[next])) {
+			next++
+		}
+		if next == end || next == len(paragraph) || !startsSentence(paragraph[next:]) {
+			continue
+		}
+		if c == '.' && isAbbreviation(paragraph[start:i], paragraph[next:]) {
+			continue
+		}
+		sentences = append(sentences, strings.TrimSpace(paragraph[start:end]))
+		start, i = next, next-1
+	}
+	if rest := strings.TrimSpace(paragraph[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// bracketed returns for every byte of the text whether it is within
+// parentheses or brackets closed in the text, so that brackets left open
+// do not hide the rest of the paragraph.
+func bracketed(text string) []bool {
+	inside := make([]bool, len(text))
+	open := make([]int, 0, 4)
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(', '[':
+			open = append(open, i)
+		case ')', ']':
+			if len(open) == 0 {
+				continue
+			}
+			o := open[len(open)-1]
+			open = open[:len(open)-1]
+			if (text[o] == '(') == (text[i] == ')') {
+				for j := o + 1; j < i; j++ {
+					inside[j] = true
+				}
+			}
+		}
+	}
+	return inside
+}
+
+// sentenceEnd returns the end of the sentence whose final mark ends at i:
+// further marks, closing quotes and brackets, and reference marks like
+// "[1]" or "[note 2]" belong to it.
+func sentenceEnd(text string, i int) int {
+	for i < len(text) {
+		switch c := text[i]; {
+		case c == '.' || c == '!' || c == '?' || c == '"' || c == '\'' || c == ')' || c == ']':
+			i++
+		case c == '[':
+			j := strings.IndexByte(text[i:], ']')
+			if j < 0 || j > 12 || strings.ContainsAny(text[i+1:i+j], "[.") {
+				return i
+			}
+			i += j + 1
+		case strings.HasPrefix(text[i:], "”") || strings.HasPrefix(text[i:], "’") || strings.HasPrefix(text[i:], "»"):
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// startsSentence reports whether text starts like a sentence: with an
+// upper case letter, a digit, a quote or an opening bracket.
+func startsSentence(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsUpper(r) || unicode.IsDigit(r) || strings.ContainsRune("\"'(“‘«¿¡", r)
+}
+
+// isAbbreviation reports whether the last word of the text, followed by a
+// full stop and the text next, is an abbreviation or an initial rather
+// than the end of a sentence.
+func isAbbreviation(text string, next string) bool {
+	word := text
+	if i := strings.LastIndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && r != '.' }); i >= 0 {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		word = text[i+size:]
+	}
+	switch {
+	case word == "":
+		return false
+	case strings.Contains(word, "."):
+		// Like "e.g" or "U.S".
+		return true
+	case utf8.RuneCountInString(word) == 1 && unicode.IsUpper([]rune(word)[0]):
+		return true
+	}
+	word = strings.ToLower(word)
+	r, _ := utf8.DecodeRuneInString(next)
+	return abbreviations[word] || numberAbbreviations[word] && unicode.IsDigit(r)
+}
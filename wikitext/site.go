@@ -0,0 +1,188 @@
+// The conventions that differ between wikis, like the names of
+// namespaces, image options and redirects in their language
+
+package wikitext
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Namespace numbers, which are the same on all wikis.
+const (
+	NamespaceMedia    = -2
+	NamespaceSpecial  = -1
+	NamespaceMain     = 0
+	NamespaceFile     = 6
+	NamespaceTemplate = 10
+	NamespaceCategory = 14
+)
+
+// The canonical English names of namespaces, which every wiki accepts
+// besides its own, with aliases used on the English Wikipedia.
+var englishNamespaces = map[string]int{
+	"media": -2, "special": -1, "talk": 1, "user": 2, "user talk": 3,
+	"project": 4, "project talk": 5, "wikipedia": 4, "wikipedia talk": 5, "wp": 4,
+	"file": 6, "file talk": 7, "image": 6, "image talk": 7,
+	"mediawiki": 8, "mediawiki talk": 9, "template": 10, "template talk": 11,
+	"help": 12, "help talk": 13, "category": 14, "category talk": 15,
+	"portal": 100, "portal talk": 101, "draft": 118, "draft talk": 119,
+	"timedtext": 710, "timedtext talk": 711, "module": 828, "module talk": 829,
+}
+
+var englishNames = map[int]string{
+	-2: "Media", -1: "Special", 1: "Talk", 2: "User", 3: "User talk",
+	4: "Wikipedia", 5: "Wikipedia talk", 6: "File", 7: "File talk",
+	8: "MediaWiki", 9: "MediaWiki talk", 10: "Template", 11: "Template talk",
+	12: "Help", 13: "Help talk", 14: "Category", 15: "Category talk",
+	100: "Portal", 101: "Portal talk", 118: "Draft", 119: "Draft talk",
+	710: "TimedText", 711: "TimedText talk", 828: "Module", 829: "Module talk",
+}
+
+// A Site holds the names of the namespaces of a wiki, of the image
+// options and of redirects in its language, like "Kategorie", "mini"
+// and "#WEITERLEITUNG" on the German Wikipedia. A Site from NewSite knows those of the English Wikipedia;
+// the names of other wikis are added to them, since MediaWiki accepts
+// the English names everywhere.
+type Site struct {
+	namespaces   map[string]int    // the normalized names and aliases
+	names        map[int]string    // the name of each namespace in titles
+	imageOptions map[string]string // localized option names to English
+	redirects    []string          // the aliases of #REDIRECT
+}
+
+// NewSite returns a Site of the English Wikipedia.
+func NewSite() *Site {
+	s := &Site{namespaces: make(map[string]int), names: make(map[int]string), imageOptions: make(map[string]string)}
+	for name, number := range englishNamespaces {
+		s.namespaces[name] = number
+	}
+	for number, name := range englishNames {
+		s.names[number] = name
+	}
+	return s
+}
+
+// english is the site of documents parsed without WithSite.
+var english = NewSite()
+
+// normalizeNamespace returns the lower case name of a namespace with
+// single spaces for runs of spaces and underscores.
+func normalizeNamespace(name string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == ' '
+	}), " "))
+}
+
+// SetNamespace sets the name of a namespace in titles, like "Kategorie"
+// for NamespaceCategory. The previous name stays an alias.
+func (s *Site) SetNamespace(number int, name string) {
+	if normalizeNamespace(name) == "" {
+		return
+	}
+	s.names[number] = name
+	s.namespaces[normalizeNamespace(name)] = number
+}
+
+// AddAlias adds another name of a namespace, like "Bild" for
+// NamespaceFile.
+func (s *Site) AddAlias(name string, number int) {
+	if normalizeNamespace(name) != "" {
+		s.namespaces[normalizeNamespace(name)] = number
+	}
+}
+
+// AddImageOption adds a localized name of an image option, like "mini"
+// for "thumb".
+func (s *Site) AddImageOption(name string, english string) {
+	s.imageOptions[strings.ToLower(name)] = strings.ToLower(english)
+}
+
+// AddRedirectAlias adds another magic word of redirects besides
+// #REDIRECT, like "#WEITERLEITUNG".
+func (s *Site) AddRedirectAlias(word string) {
+	if word = strings.TrimSpace(word); word != "" && !slices.Contains(s.redirects, word) {
+		s.redirects = append(s.redirects, word)
+	}
+}
+
+// RedirectAliases returns the magic words of redirects added to
+// #REDIRECT, which every wiki accepts.
+func (s *Site) RedirectAliases() []string {
+	return s.redirects
+}
+
+// Namespace returns the number of the namespace with the given name or
+// alias, in any case and with underscores or spaces.
+func (s *Site) Namespace(name string) (int, bool) {
+	number, ok := s.namespaces[normalizeNamespace(name)]
+	return number, ok
+}
+
+// Name returns the name of the namespace in titles, "" for the main
+// namespace and namespaces the site does not know.
+func (s *Site) Name(number int) string {
+	return s.names[number]
+}
+
+// Split splits a page title into the number of its namespace and the
+// title within it. Titles without the prefix of a namespace, like "Star
+// Wars: Episode I", are in the main namespace.
+func (s *Site) Split(title string) (int, string) {
+	if prefix, rest, ok := strings.Cut(title, ":"); ok {
+		if number, ok := s.Namespace(prefix); ok {
+			return number, strings.TrimSpace(rest)
+		}
+	}
+	return NamespaceMain, title
+}
+
+// imageOption returns the English name of an image option given in the
+// language of the site.
+func (s *Site) imageOption(name string) string {
+	if english, ok := s.imageOptions[name]; ok {
+		return english
+	}
+	return name
+}
+
+// siteConfig is the JSON form of the additions to a Site:
+//
+//	{
+//	  "namespaces": {"6": "Datei", "14": "Kategorie"},
+//	  "aliases": {"Bild": 6},
+//	  "imageoptions": {"mini": "thumb", "hochkant": "upright"},
+//	  "redirects": ["#WEITERLEITUNG"]
+//	}
+type siteConfig struct {
+	Namespaces   map[int]string    `json:"namespaces"`
+	Aliases      map[string]int    `json:"aliases"`
+	ImageOptions map[string]string `json:"imageoptions"`
+	Redirects    []string          `json:"redirects"`
+}
+
+// ReadJSON adds the namespaces, aliases, image options and redirect
+// aliases of the JSON configuration in r to the site.
+func (s *Site) ReadJSON(r io.Reader) error {
+	var config siteConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return err
+	}
+	for number, name := range config.Namespaces {
+		s.SetNamespace(number, name)
+	}
+	for name, number := range config.Aliases {
+		s.AddAlias(name, number)
+	}
+	for name, english := range config.ImageOptions {
+		s.AddImageOption(name, english)
+	}
+	for _, word := range config.Redirects {
+		s.AddRedirectAlias(word)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Minimization of articles to their link structure
+
+package wikitext
+
+import (
+	"strings"
+)
+
+// skeletonLink returns the wikitext of a link without its anchor text.
+func skeletonLink(l Link) string {
+	target := l.Target
+	if l.Section != "" {
+		target += "#" + l.Section
+	}
+	if l.Class == LinkCategory || l.Class == LinkFile {
+		// Keep links to categories and files from becoming assignments.
+		target = ":" + target
+	}
+	if l.Interwiki != "" {
+		target = l.Interwiki + ":" + target
+	}
+	return "[[" + target + "]]"
+}
+
+// Skeleton returns wikitext with the section headings, links, external
+// links and categories of the document but none of its text: anchor
+// texts and labels are dropped, links are given one per line under the
+// heading of their section. It allows sharing the structure of articles
+// without their content.
+func Skeleton(doc *Document) string {
+	parts := make([]string, 0, 10)
+	var visit func(sections []Section)
+	visit = func(sections []Section) {
+		for _, s := range sections {
+			lines := make([]string, 0, 10)
+			if s.Heading != "" {
+				mark := strings.Repeat("=", s.Level)
+				lines = append(lines, mark+" "+s.Heading+" "+mark)
+			}
+			end := s.bodyEnd()
+			body := &Document{Text: doc.Text[s.Start:end], Items: itemsIn(doc, s.Start, end), site: doc.site}
+			for _, l := range Links(body) {
+				lines = append(lines, skeletonLink(l))
+			}
+			for _, l := range ExternalLinks(body) {
+				if l.Bracketed {
+					lines = append(lines, "["+l.URL+"]")
+				} else {
+					lines = append(lines, l.URL)
+				}
+			}
+			if len(lines) > 0 {
+				parts = append(parts, strings.Join(lines, "\n"))
+			}
+			visit(s.Children)
+		}
+	}
+	visit(Sections(doc))
+	categories := make([]string, 0, 10)
+	for _, c := range Categories(doc) {
+		categories = append(categories, "[[Category:"+c.Name+"]]")
+	}
+	if len(categories) > 0 {
+		parts = append(parts, strings.Join(categories, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,134 @@
+// Statistics of what the articles of a corpus contain
+
+package wikitext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pcmoritz/wikipedia/internal/schema"
+)
+
+// Stats counts the items and nodes of documents and the deepest nesting
+// seen, so that the limits of the parser like MaxNestingDepth can be
+// tuned to a corpus. The zero value is not usable; create Stats with
+// NewStats.
+type Stats struct {
+	Documents        int
+	Items            map[ItemType]int // lexed items by type
+	Nodes            map[string]int   // nodes by kind, like "section" or "link"
+	Errors           map[string]int   // syntax errors by kind, like "malformed template"
+	MaxTemplateDepth int              // the deepest nesting of templates
+	MaxSectionLevel  int              // the deepest heading level
+	MaxListLevel     int              // the deepest nesting of lists
+}
+
+// NewStats creates empty statistics.
+func NewStats() *Stats {
+	return &Stats{
+		Items:  make(map[ItemType]int),
+		Nodes:  make(map[string]int),
+		Errors: make(map[string]int),
+	}
+}
+
+// Add counts the items, nodes and syntax errors of the document.
+func (s *Stats) Add(doc *Document) {
+	s.Documents++
+	for _, e := range doc.Errors {
+		s.Errors[e.Kind.Error()]++
+	}
+	depth := 0
+	for i, item := range doc.Items {
+		s.Items[item.Type]++
+		switch {
+		case item.Type == ItemLeftMeta:
+			s.Nodes["template"]++
+			if depth++; depth > s.MaxTemplateDepth {
+				s.MaxTemplateDepth = depth
+			}
+		case item.Type == ItemRightMeta:
+			if depth > 0 {
+				depth--
+			}
+		case isMark(doc.Items, i, "{") && isMark(doc.Items, i+1, "|"):
+			s.Nodes["table"]++
+		}
+	}
+	var sections func([]Section)
+	sections = func(children []Section) {
+		for _, c := range children {
+			s.Nodes["section"]++
+			if c.Level > s.MaxSectionLevel {
+				s.MaxSectionLevel = c.Level
+			}
+			sections(c.Children)
+		}
+	}
+	sections(Sections(doc))
+	var listItems func([]ListItem)
+	listItems = func(items []ListItem) {
+		for _, li := range items {
+			s.Nodes["listitem"]++
+			if li.Level() > s.MaxListLevel {
+				s.MaxListLevel = li.Level()
+			}
+			listItems(li.Children)
+		}
+	}
+	for _, l := range Lists(doc) {
+		s.Nodes["list"]++
+		listItems(l.Items)
+	}
+	s.Nodes["link"] += len(Links(doc))
+	s.Nodes["externallink"] += len(ExternalLinks(doc))
+	s.Nodes["category"] += len(Categories(doc))
+	s.Nodes["citation"] += len(Citations(doc))
+	s.Nodes["span"] += len(Formatting(doc))
+	s.Nodes["block"] += len(Blocks(doc))
+	s.Nodes["image"] += len(Images(doc))
+}
+
+// WriteTo writes the statistics as tab separated lines
+// "group\tname\tcount", the items, nodes and errors sorted by name.
+func (s *Stats) WriteTo(w io.Writer) (int64, error) {
+	items := make([]string, 0, len(s.Items))
+	for t, n := range s.Items {
+		items = append(items, fmt.Sprintf("items\t%s\t%d\n", t, n))
+	}
+	sort.Strings(items)
+	nodes := make([]string, 0, len(s.Nodes))
+	for kind, n := range s.Nodes {
+		nodes = append(nodes, fmt.Sprintf("nodes\t%s\t%d\n", kind, n))
+	}
+	sort.Strings(nodes)
+	errs := make([]string, 0, len(s.Errors))
+	for kind, n := range s.Errors {
+		errs = append(errs, fmt.Sprintf("errors\t%s\t%d\n", kind, n))
+	}
+	sort.Strings(errs)
+	lines := []string{fmt.Sprintf("total\tdocuments\t%d\n", s.Documents)}
+	lines = append(lines, items...)
+	lines = append(lines, nodes...)
+	lines = append(lines, errs...)
+	lines = append(lines,
+		fmt.Sprintf("max\ttemplatedepth\t%d\n", s.MaxTemplateDepth),
+		fmt.Sprintf("max\tsectionlevel\t%d\n", s.MaxSectionLevel),
+		fmt.Sprintf("max\tlistlevel\t%d\n", s.MaxListLevel))
+	writer := bufio.NewWriter(w)
+	m, err := schema.WriteHeader(writer, "stats")
+	n := int64(m)
+	if err != nil {
+		return n, err
+	}
+	for _, line := range lines {
+		m, err := writer.WriteString(line)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, writer.Flush()
+}
@@ -0,0 +1,145 @@
+// Stripping the maintenance boilerplate of articles: cleanup banners,
+// inline notes, stub notices, navboxes and hidden categories
+
+package wikitext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A StripList names the templates and categories of the maintenance
+// boilerplate of a wiki: cleanup banners like {{More citations needed}},
+// inline notes like {{Citation needed}}, stub notices, navboxes, and the
+// hidden categories they and the editors add, like "All articles with
+// unsourced statements". The names are without the namespace, and a "*"
+// stands for any text, as in "*-stub". They match regardless of case and
+// of underscores for spaces.
+//
+// Expand leaves out the transclusions of its templates with ExpandStrip,
+// and Categories its categories when it is the Strip of the document.
+type StripList struct {
+	Templates  []string
+	Categories []string
+}
+
+// EnglishStripList is the strip list of the English Wikipedia.
+var EnglishStripList = &StripList{
+	Templates: []string{
+		// Inline notes.
+		"Citation needed", "Cn", "Fact", "Clarify", "Clarification needed", "Dubious",
+		"Failed verification", "Better source needed", "Verification needed", "Page needed",
+		"Full citation needed", "Dead link", "Vague", "Who", "Whom", "Which", "When", "By whom",
+		"According to whom", "Update inline", "Original research inline", "Unreliable source?",
+		"Citation needed span", "Specify", "Weasel inline", "Peacock inline",
+		// Banners of cleanup and page notices.
+		"Multiple issues", "More citations needed", "Refimprove", "Unreferenced", "BLP sources",
+		"More footnotes", "No footnotes", "Primary sources", "Original research", "POV",
+		"Advert", "Tone", "Essay-like", "Confusing", "Technical", "Very long", "Lead too short",
+		"Lead too long", "Copy edit", "Cleanup", "Cleanup *", "Expand section", "Update",
+		"Notability", "Orphan", "Dead end", "Underlinked", "Overlinked", "Globalize",
+		"Globalize/*", "Current", "In use", "Under construction", "Improve categories",
+		"Uncategorized", "Coord missing", "Pp", "Pp-*", "Good article", "Featured article",
+		"Use dmy dates", "Use mdy dates", "Use * English", "Short description", "Italic title",
+		"Authority control", "Portal", "Portal bar", "Commons category", "Commons",
+		// Stub notices.
+		"Stub", "*-stub", "* stub",
+		// Navboxes and succession boxes.
+		"Navbox", "Navbox *", "* navbox", "*-navbox", "S-start", "S-end", "S-bef", "S-aft",
+		"S-ttl", "S-off", "S-hou", "Succession box", "Start box", "End box",
+	},
+	Categories: []string{
+		"All articles *", "All pages *", "All Wikipedia articles *", "Articles *",
+		"Pages *", "Wikipedia *", "CS1*", "Webarchive template *", "Use * dates from *",
+		"Use * English from *", "Short description *", "Commons category link *",
+		"Coordinates on Wikidata", "Official website different in Wikidata and Wikipedia",
+		"Harv and Sfn no-target errors", "Good articles", "Featured articles", "*stubs",
+	},
+}
+
+// ReadStripList reads a strip list of a template or category per line,
+// by its title like "Template:Citation needed" or "Category:Articles *"
+// in the namespace names of the English Wikipedia. Blank lines and lines
+// starting with "#" are skipped.
+func ReadStripList(r io.Reader) (*StripList, error) {
+	l := &StripList{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch number, name := english.Split(line); number {
+		case NamespaceTemplate:
+			l.Templates = append(l.Templates, name)
+		case NamespaceCategory:
+			l.Categories = append(l.Categories, name)
+		default:
+			return nil, fmt.Errorf("line %d: %q is no Template: or Category: title", n, line)
+		}
+	}
+	return l, scanner.Err()
+}
+
+// StripsTemplate reports whether the template of the name, without the
+// namespace, is on the list.
+func (l *StripList) StripsTemplate(name string) bool {
+	return l != nil && matchesAny(l.Templates, name)
+}
+
+// StripsCategory reports whether the category of the name, without the
+// namespace, is on the list.
+func (l *StripList) StripsCategory(name string) bool {
+	return l != nil && matchesAny(l.Categories, name)
+}
+
+// matchesAny reports whether the name matches one of the patterns.
+func matchesAny(patterns []string, name string) bool {
+	name = stripKey(name)
+	for _, p := range patterns {
+		if matchPattern(stripKey(p), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripKey returns the name lower case with single spaces for spaces
+// and underscores.
+func stripKey(name string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == ' '
+	}), " "))
+}
+
+// matchPattern reports whether the name matches the pattern, whose "*"
+// stand for any text.
+func matchPattern(pattern string, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && strings.HasSuffix(name, last)
+}
+
+// ExpandStrip leaves out the transclusions of the templates of the strip
+// list, and of the templates redirecting to them.
+func ExpandStrip(l *StripList) ExpandOption {
+	return func(e *expander) {
+		e.strip = l
+	}
+}
@@ -0,0 +1,161 @@
+// Templates of articles with their parameters parsed as wikitext
+
+package wikitext
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A Template is a transclusion like {{Infobox person|name=...}} in a
+// document, with the values of its parameters parsed in turn, so that
+// the links, references and templates in them are available.
+type Template struct {
+	Name   string
+	Params []Param
+	Start  int // byte offset of the "{{" in the text of its document
+	End    int // byte offset after the "}}"
+}
+
+// A Param is a parameter of a template. Positional parameters are named
+// "1", "2", ... like in MediaWiki.
+type Param struct {
+	Name  string
+	Value *Document // the wikitext of the value, without surrounding space
+	Start int       // byte offset after the "|" in the text of the document of the template
+	End   int       // byte offset of the next "|" or "}}", or of the line break before it
+
+	// Templates are the templates in the value, with their parameters
+	// parsed down to the depth given to Templates.
+	Templates []Template
+}
+
+// Param returns the parameter of the template with the given name. Of
+// parameters given more than once, the last one counts, as in MediaWiki.
+func (t Template) Param(name string) (Param, bool) {
+	for i := len(t.Params) - 1; i >= 0; i-- {
+		if t.Params[i].Name == name {
+			return t.Params[i], true
+		}
+	}
+	return Param{}, false
+}
+
+// splitItems splits items at the separators "|" that are not nested in
+// templates or links.
+func splitItems(items []Item) [][]Item {
+	parts := make([][]Item, 0, 10)
+	start, depth := 0, 0
+	for i, s := range items {
+		switch {
+		case s.Type == ItemLeftMeta || s.Type == ItemLeftTag:
+			depth++
+		case s.Type == ItemRightMeta || s.Type == ItemRightTag:
+			depth--
+		case isMark(items, i, "|") && depth == 0:
+			parts = append(parts, items[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, items[start:])
+}
+
+// splitParam splits the items of a parameter "name=value" at its first
+// "=" that is not nested in templates or links. It returns false for
+// positional parameters.
+func splitParam(items []Item) (string, string, bool) {
+	depth := 0
+	for i, s := range items {
+		switch s.Type {
+		case ItemLeftMeta, ItemLeftTag:
+			depth++
+		case ItemRightMeta, ItemRightTag:
+			depth--
+		case ItemTitle, ItemMark:
+			// The "=" may be the first of a run like "==", which is a
+			// mark within a line and an ItemTitle at its start or end.
+			if depth == 0 && strings.HasPrefix(strings.TrimLeftFunc(s.Val, isBreakingSpace), "=") {
+				before, rest, _ := strings.Cut(s.Val, "=")
+				return itemText(items[:i]) + before, rest + itemText(items[i+1:]), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// closeTemplate returns the index after the "}}" closing the template
+// whose "{{" is at items[i], or false if it is not closed.
+func closeTemplate(items []Item, i int) (int, bool) {
+	depth := 0
+	for ; i < len(items); i++ {
+		switch items[i].Type {
+		case ItemLeftMeta:
+			depth++
+		case ItemRightMeta:
+			if depth--; depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return i, false
+}
+
+// parseTemplate parses the template whose "{{" is at items[i] and whose
+// "}}" is at items[end-1], parsing the templates in its parameter values
+// down to depth more levels.
+func parseTemplate(items []Item, i int, end int, offsets []int, depth int, site *Site) Template {
+	parts := splitItems(items[i+1 : end-1])
+	t := Template{
+		Name:   strings.TrimSpace(itemText(parts[0])),
+		Params: make([]Param, 0, len(parts)-1),
+		Start:  strings.IndexFunc(items[i].Val, func(r rune) bool { return r == '{' }) + offsets[i],
+		End:    offsets[end],
+	}
+	position := 1
+	at := i + 1 + len(parts[0]) // the index of the "|" before the part
+	for _, part := range parts[1:] {
+		start, end := offsets[at+1], offsets[at+1+len(part)]
+		at += 1 + len(part)
+		name, value, named := splitParam(part)
+		if named {
+			name = strings.TrimSpace(name)
+		} else {
+			name, value = strconv.Itoa(position), itemText(part)
+			position++
+		}
+		doc, _ := Parse(strings.TrimSpace(value), WithSite(site))
+		p := Param{Name: name, Value: doc, Start: start, End: end}
+		if depth > 0 {
+			p.Templates = Templates(doc, depth)
+		}
+		t.Params = append(t.Params, p)
+	}
+	return t
+}
+
+// Templates returns the templates of the document in order, not those
+// nested in them, which are found in the parameters. The values of the
+// parameters are parsed as documents of their own, to which the offsets
+// of the templates in them refer; depth limits how many
+// levels of templates in parameter values are parsed: with depth 1 only
+// the templates of the document are, with 2 also those in their
+// parameters, and so on. Templates that are not closed are left out.
+func Templates(doc *Document, depth int) []Template {
+	templates := make([]Template, 0, 4)
+	if depth < 1 {
+		return templates
+	}
+	offsets := itemOffsets(doc.Items)
+	for i := 0; i < len(doc.Items); i++ {
+		if doc.Items[i].Type != ItemLeftMeta {
+			continue
+		}
+		end, ok := closeTemplate(doc.Items, i)
+		if !ok {
+			continue
+		}
+		templates = append(templates, parseTemplate(doc.Items, i, end, offsets, depth-1, doc.siteOf()))
+		i = end - 1
+	}
+	return templates
+}
@@ -0,0 +1,249 @@
+// Rendering of wikitext as plain text
+
+package wikitext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// skipTemplate returns the index after the "}}" closing the template
+// whose "{{" is at items[i].
+func skipTemplate(items []Item, i int) int {
+	depth := 0
+	for ; i < len(items); i++ {
+		switch items[i].Type {
+		case ItemLeftMeta:
+			depth++
+		case ItemRightMeta:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// isMark reports whether items[i] is the mark val. Line breaks are
+// lexed into the following item, so they are ignored.
+func isMark(items []Item, i int, val string) bool {
+	return i < len(items) && items[i].Type == ItemMark && strings.TrimSpace(items[i].Val) == val
+}
+
+// skipTable returns the index after the "|}" closing the table whose
+// "{|" starts at items[i].
+func skipTable(items []Item, i int) int {
+	depth := 0
+	for ; i < len(items); i++ {
+		if isMark(items, i, "{") && isMark(items, i+1, "|") {
+			depth++
+			i++
+		} else if isMark(items, i, "|") && isMark(items, i+1, "}") {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// skipElement returns the index after the closing tag of the element
+// whose start tag is at items[i].
+func skipElement(items []Item, i int, name string) int {
+	for i++; i < len(items); i++ {
+		if items[i].Type == ItemXML {
+			if tag, ok := parseTag(items[i].Val); ok && tag.Closing && tag.Name == name {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// Elements whose content is not part of the text.
+var hiddenElements = map[string]bool{
+	"ref":      true,
+	"gallery":  true,
+	"math":     true,
+	"timeline": true,
+}
+
+// renderText appends the plain text of the items to text: templates,
+// tables, navboxes, references, bold and italic markup and the markup of links are
+// removed, links are replaced by their anchor text, external links by
+// their label, language links are left out, and list items are flattened
+// into paragraphs.
+func renderText(site *Site, text []string, items []Item) []string {
+	literals := quoteLiterals(items)
+	unclosed := 0 // the brackets of external links before it are text
+	for i := 0; i < len(items); {
+		s := items[i]
+		switch {
+		case s.Type == ItemLeftMeta:
+			i = skipTemplate(items, i)
+			continue
+		case isMark(items, i, "{") && isMark(items, i+1, "|"):
+			text = append(text, "\n")
+			i = skipTable(items, i)
+			continue
+		case s.Type == ItemLeftTag:
+			if _, next, ok := scanLanguageLink(site, items, i); ok {
+				// Language links are not part of the text.
+				text = append(text, strings.TrimSuffix(s.Val, "[["))
+				i = next
+				continue
+			}
+			var anchor string
+			_, anchor, i = scanLink(site, items, i+1, nil)
+			// The item starts with the newline or spaces before the link.
+			text = append(text, strings.TrimSuffix(s.Val, "[["), anchor)
+			continue
+		case isExternalLink(items, i) && i >= unclosed:
+			_, label, next, ok := scanExternalLink(items, i)
+			if ok {
+				text = renderText(site, text, label)
+				i = next
+				continue
+			}
+			unclosed = next
+			text = append(text, s.Val)
+		case s.Type == ItemXML:
+			tag, ok := parseTag(s.Val)
+			if ok && !tag.Closing && !tag.SelfClosing && hiddenElements[tag.Name] {
+				i = skipElement(items, i, tag.Name)
+				continue
+			}
+			if ok && !tag.Closing && !tag.SelfClosing && isNavboxClass(tag.Attr["class"], tag.Attr["role"]) {
+				// Navboxes of expanded wikitext are not part of the text.
+				i = skipNested(items, i, tag.Name)
+				continue
+			}
+			if ok && tag.Name == "br" {
+				text = append(text, "\n")
+			}
+		case s.Type == ItemList:
+			// Every list item becomes a paragraph of its own.
+			text = append(text, "\n\n")
+		case s.Type == ItemQuote:
+			text = append(text, strings.Repeat("'", literals[i]))
+		case s.Type == ItemComment, s.Type == ItemSwitch, s.Type == ItemRightMeta, s.Type == ItemRightTag, s.Type == ItemError:
+		default:
+			text = append(text, s.Val)
+		}
+		i++
+	}
+	return text
+}
+
+// render returns the plain text of the items of the document, with
+// character references decoded unless doc.KeepEntities is set.
+func (doc *Document) render(items []Item) string {
+	text := strings.Join(renderText(doc.siteOf(), nil, items), "")
+	if !doc.KeepEntities {
+		text = html.UnescapeString(text)
+	}
+	return text
+}
+
+// isBreakingSpace reports whether r is white space that paragraphs
+// normalize; no-break spaces, as from "&nbsp;", are kept.
+func isBreakingSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '\v'
+}
+
+var paragraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+
+// paragraphs splits rendered text at blank lines and normalizes the white
+// space within each paragraph. Empty paragraphs are dropped.
+func paragraphs(text string) []string {
+	result := make([]string, 0, 10)
+	for _, p := range paragraphBreak.Split(text, -1) {
+		p = strings.Join(strings.FieldsFunc(p, isBreakingSpace), " ")
+		if p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// PlainText returns the text of the document without markup, with the
+// headings and paragraphs of its sections separated by blank lines, up to
+// doc.Limit. Character references like "&ndash;" are decoded unless
+// doc.KeepEntities is set.
+func PlainText(doc *Document) string {
+	parts := make([]string, 0, 10)
+	b := budget{limit: doc.Limit}
+	// The headings of the sections without paragraphs so far, written
+	// with the next paragraph within the limit.
+	var headings []string
+	sectionParagraphs(doc, func(s Section, body []string) {
+		heading := s.Heading
+		if heading != "" && !doc.KeepEntities {
+			heading = html.UnescapeString(heading)
+		}
+		if b.unlimited() {
+			if heading != "" {
+				parts = append(parts, heading)
+			}
+			parts = append(parts, body...)
+			return
+		}
+		if heading != "" {
+			headings = append(headings, heading)
+		}
+		for _, p := range body {
+			if text := b.take(headings, p); text != "" {
+				parts = append(append(parts, headings...), text)
+				headings = headings[:0]
+			}
+		}
+	})
+	return strings.Join(parts, "\n\n")
+}
+
+// sectionParagraphs calls fn with every section of the document, in
+// document order, and the paragraphs of its text before its first
+// subsection.
+func sectionParagraphs(doc *Document, fn func(s Section, body []string)) {
+	var visit func(sections []Section)
+	visit = func(sections []Section) {
+		for _, s := range sections {
+			end := s.bodyEnd()
+			fn(s, paragraphs(doc.render(itemsIn(doc, s.Start, end))))
+			visit(s.Children)
+		}
+	}
+	visit(Sections(doc))
+}
+
+// firstSentences returns the first n sentences of the paragraph.
+func firstSentences(paragraph string, n int) string {
+	sentences := SplitSentences(paragraph)
+	if len(sentences) <= n {
+		return paragraph
+	}
+	return strings.Join(sentences[:n], " ")
+}
+
+// Abstract returns the first paragraph of the lead section without
+// markup, or only its first sentences if sentences is positive, up to
+// doc.Limit. The maintenance templates, infoboxes and images preceding
+// the lead text are skipped.
+func Abstract(doc *Document, sentences int) string {
+	lead := Sections(doc)[0]
+	text := doc.render(itemsIn(doc, lead.Start, lead.End))
+	for _, p := range paragraphs(text) {
+		if sentences > 0 {
+			p = firstSentences(p, sentences)
+		}
+		if b := (budget{limit: doc.Limit}); !b.unlimited() {
+			p = b.take(nil, p)
+		}
+		return p
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package wikitext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlainTextSubsections(t *testing.T) {
+	text := "Lead.\n\n== Mission ==\nLaunch.\n\n=== Landing ===\nLanded.\n\n==== Site ====\nTranquility.\n\n== Crew ==\nThree.\n"
+	doc, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := PlainText(doc)
+	if strings.Contains(got, "==") {
+		t.Errorf("PlainText contains heading markup: %q", got)
+	}
+	want := "Lead.\n\nMission\n\nLaunch.\n\nLanding\n\nLanded.\n\nSite\n\nTranquility.\n\nCrew\n\nThree."
+	if got != want {
+		t.Errorf("PlainText = %q, want %q", got, want)
+	}
+	for _, s := range Sentences(doc) {
+		if strings.Contains(s.Text, "==") {
+			t.Errorf("sentence contains heading markup: %q", s.Text)
+		}
+	}
+}
+
+func TestSkeletonSubsections(t *testing.T) {
+	// The link of the subheading is not one of the section above it.
+	doc, err := Parse("Lead [[Moon]].\n\n== Mission ==\nLaunch.\n\n=== [[Sea of Tranquility|Landing]] ===\nLanded.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[Moon]]\n\n== Mission ==\n\n=== [[Sea of Tranquility|Landing]] ==="
+	if got := Skeleton(doc); got != want {
+		t.Errorf("Skeleton = %q, want %q", got, want)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"links", "[[Moon|lunar]] and [[Mars#Orbit]] [[Category:Moons]]", "lunar and Mars#Orbit"},
+		{"formatting", "'''Apollo 11''' was ''the'' first", "Apollo 11 was the first"},
+		{"templates", "{{Infobox|name=[[A]]}}Text{{cite|x}}.", "Text."},
+		{"entities", "A &amp; B&nbsp;C", "A & B\u00a0C"},
+		{"nowiki", "<nowiki>[[x]]</nowiki> y", "[[x]] y"},
+		{"less than", "1 < 2", "1 < 2"},
+		{"unclosed tag", "a <b c", "a <b c"},
+		{"unclosed link", "[[Moon", "Moon"},
+		{"unclosed template", "Text {{cite|a=b", "Text"},
+		{"unclosed comment", "Text <!-- open", "Text"},
+		{"reference", "Landed.<ref>{{cite web|url=x}}</ref> Returned.", "Landed. Returned."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, _ := Parse(tt.text)
+			if got := PlainText(doc); got != tt.want {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainTextLimit(t *testing.T) {
+	text := "Ünïcödé wörds hère. Second sentence.\n\n== Mission ==\nLaunch."
+	tests := []struct {
+		limit TextLimit
+		want  string
+	}{
+		{TextLimit{Sentences: 1}, "Ünïcödé wörds hère."},
+		{TextLimit{Chars: 20}, "Ünïcödé wörds hère."},
+		{TextLimit{Words: 5}, "Ünïcödé wörds hère. Second sentence."},
+		{TextLimit{Chars: 54}, "Ünïcödé wörds hère. Second sentence.\n\nMission\n\nLaunch."},
+		{TextLimit{Chars: 53}, "Ünïcödé wörds hère. Second sentence."},
+		// Not even the first sentence fits: its first words, or the first
+		// characters of its first word.
+		{TextLimit{Chars: 15}, "Ünïcödé wörds"},
+		{TextLimit{Words: 1}, "Ünïcödé"},
+		{TextLimit{Chars: 5}, "Ünïcö"},
+		{TextLimit{Chars: 5, Words: 1}, "Ünïcö"},
+	}
+	for _, tt := range tests {
+		doc, _ := Parse(text)
+		doc.Limit = tt.limit
+		if got := PlainText(doc); got != tt.want {
+			t.Errorf("PlainText with %+v = %q, want %q", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// The table of contents of articles
+
+package wikitext
+
+import (
+	"strconv"
+	"strings"
+)
+
+// minTOCHeadings is the number of headings from which MediaWiki shows the
+// table of contents of a page without __FORCETOC__.
+const minTOCHeadings = 4
+
+// A TOCEntry is an entry of the table of contents of a document, for a
+// section and its subsections.
+type TOCEntry struct {
+	Number   string // like "2.1" for the first subsection of the second section
+	Level    int    // the level of the heading
+	Text     string // the heading as plain text
+	Anchor   string // the id of the heading, as in Section
+	Children []TOCEntry
+}
+
+// TOC returns the table of contents of the document: an entry for every
+// section but the lead, nested like the sections and numbered like
+// MediaWiki numbers them. Whether MediaWiki shows it on the page is told
+// by ShowsTOC.
+func TOC(doc *Document) []TOCEntry {
+	var entries func(sections []Section, prefix string) []TOCEntry
+	entries = func(sections []Section, prefix string) []TOCEntry {
+		toc := make([]TOCEntry, 0, len(sections))
+		for _, s := range sections {
+			if s.Level == 0 {
+				continue
+			}
+			number := prefix + strconv.Itoa(len(toc)+1)
+			heading, _ := Parse(s.Heading, WithSite(doc.siteOf()))
+			heading.KeepEntities = doc.KeepEntities
+			toc = append(toc, TOCEntry{
+				Number:   number,
+				Level:    s.Level,
+				Text:     strings.TrimSpace(heading.render(heading.Items)),
+				Anchor:   s.Anchor,
+				Children: entries(s.Children, number+"."),
+			})
+		}
+		return toc
+	}
+	return entries(Sections(doc), "")
+}
+
+// ShowsTOC reports whether MediaWiki shows the table of contents of the
+// document: with __FORCETOC__ or __TOC__, or from four headings on,
+// unless __NOTOC__ hides it.
+func ShowsTOC(doc *Document) bool {
+	forced := false
+	for _, m := range MagicWords(doc) {
+		switch {
+		case m.Switch && m.Name == "NOTOC":
+			return false
+		case m.Switch && (m.Name == "FORCETOC" || m.Name == "TOC"):
+			forced = true
+		}
+	}
+	return forced || len(findHeadings(doc)) >= minTOCHeadings
+}
+
+// FormatTOC returns the table of contents as text, an entry per line with
+// its number, text and anchor, indented by two spaces per level of
+// nesting:
+//
+//	1 History #History
+//	  1.1 Early life #Early_life
+func FormatTOC(toc []TOCEntry) string {
+	lines := make([]string, 0, len(toc))
+	var visit func(entries []TOCEntry, indent string)
+	visit = func(entries []TOCEntry, indent string) {
+		for _, e := range entries {
+			lines = append(lines, indent+e.Number+" "+e.Text+" #"+e.Anchor)
+			visit(e.Children, indent+"  ")
+		}
+	}
+	visit(toc, "")
+	return strings.Join(lines, "\n")
+}
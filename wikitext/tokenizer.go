@@ -0,0 +1,80 @@
+// Options for how the lexer splits text into words and numbers
+
+package wikitext
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Tokenizer sets how the lexer splits the text between the markup into
+// ItemWord and ItemNumber items. The zero Tokenizer, which Lex and Parse
+// use, keeps hyphens and apostrophes within words, like "well-known" and
+// "don't", and lexes numbers as words. Markup is lexed the same by all.
+//
+// Without Segment, a word is a run of letters, digits and underscores,
+// which suits alphabets with spaces between words but not, e.g., Chinese
+// and Japanese text, where a whole sentence is one run of letters, or
+// the scripts of India, whose vowel signs are marks that end the word.
+// With Segment, words are found by the word boundary rules of Unicode
+// Standard Annex #29 instead: marks and joiners stay with the letter
+// before them, every Han ideograph and Hiragana character is a word of
+// its own, runs of Katakana are words, and full stops, commas and the
+// like between letters or digits, as in "U.S.A" or "3.14", are within
+// the word. Hyphens and apostrophes between letters are within the word
+// unless SplitHyphens and SplitApostrophes are set. Runs of Thai and
+// other scripts without spaces, which need a dictionary to segment, are
+// one word each.
+type Tokenizer struct {
+	SplitHyphens     bool // hyphens end words and are marks of their own
+	SplitApostrophes bool // apostrophes end words, like "don" and "t"
+	Numbers          bool // numbers like "42", "3.14" or "1,000" are ItemNumber items
+	Segment          bool // words are segmented as by UAX #29
+}
+
+// tokenizerRules are the names of the fields of a Tokenizer for
+// ParseTokenizer.
+var tokenizerRules = map[string]func(*Tokenizer){
+	"splithyphens":     func(t *Tokenizer) { t.SplitHyphens = true },
+	"splitapostrophes": func(t *Tokenizer) { t.SplitApostrophes = true },
+	"numbers":          func(t *Tokenizer) { t.Numbers = true },
+	"segment":          func(t *Tokenizer) { t.Segment = true },
+}
+
+// ParseTokenizer returns the Tokenizer with the rules of the comma
+// separated list set, like "splithyphens,numbers": splithyphens,
+// splitapostrophes, numbers and segment. The empty list is the zero
+// Tokenizer.
+func ParseTokenizer(rules string) (Tokenizer, error) {
+	var t Tokenizer
+	for _, name := range strings.Split(rules, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		set, ok := tokenizerRules[name]
+		if !ok {
+			return Tokenizer{}, fmt.Errorf("unknown tokenizer rule %q, expected splithyphens, splitapostrophes, numbers or segment", name)
+		}
+		set(&t)
+	}
+	return t, nil
+}
+
+// LexContext is like the function LexContext, splitting words and
+// numbers as the tokenizer does.
+func (t Tokenizer) LexContext(ctx context.Context, input string) *Lexer {
+	l := newLexer(ctx, input, nil, t)
+	go l.run()
+	return l
+}
+
+// LexReaderContext is like the function LexReaderContext, splitting words
+// and numbers as the tokenizer does.
+func (t Tokenizer) LexReaderContext(ctx context.Context, r io.Reader) *Lexer {
+	l := newLexer(ctx, "", r, t)
+	go l.run()
+	return l
+}